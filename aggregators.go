@@ -0,0 +1,118 @@
+package main
+
+import (
+	"math"
+	"sort"
+
+	triflestats "github.com/trifle-io/trifle_stats_go"
+)
+
+// percentileAggregators maps the percentile aggregator names to their rank.
+var percentileAggregators = map[string]float64{
+	"p50": 50,
+	"p90": 90,
+	"p95": 95,
+	"p99": 99,
+}
+
+// aggregatePercentile computes the pth percentile of a path's bucket values
+// for each slice, interpolating linearly between samples.
+func aggregatePercentile(series triflestats.Series, path string, slices int, p float64) []any {
+	return aggregateSamples(series, path, slices, func(samples []float64) float64 {
+		return percentile(samples, p)
+	})
+}
+
+// aggregateVariance computes the population variance (divided by n, not
+// n-1) of a path's bucket values for each slice.
+func aggregateVariance(series triflestats.Series, path string, slices int) []any {
+	return aggregateSamples(series, path, slices, variance)
+}
+
+// aggregateStddev computes the population standard deviation of a path's
+// bucket values for each slice.
+func aggregateStddev(series triflestats.Series, path string, slices int) []any {
+	return aggregateSamples(series, path, slices, func(samples []float64) float64 {
+		return math.Sqrt(variance(samples))
+	})
+}
+
+// aggregateSamples applies fn to the numeric bucket values of each slice.
+// Non-numeric and missing values are skipped rather than counted as zero; a
+// slice with no samples yields nil.
+func aggregateSamples(series triflestats.Series, path string, slices int, fn func(samples []float64) float64) []any {
+	values := make([]any, 0, len(series.Values))
+	for _, row := range series.Values {
+		values = append(values, triflestats.FetchPath(row, path))
+	}
+
+	groups := sliceSeriesValues(values, slices)
+	results := make([]any, 0, len(groups))
+	for _, group := range groups {
+		samples := make([]float64, 0, len(group))
+		for _, value := range group {
+			if number, ok := triflestats.NormalizeNumeric(value).(float64); ok {
+				samples = append(samples, number)
+			}
+		}
+		if len(samples) == 0 {
+			results = append(results, nil)
+			continue
+		}
+		results = append(results, fn(samples))
+	}
+	return results
+}
+
+func variance(samples []float64) float64 {
+	mean := 0.0
+	for _, sample := range samples {
+		mean += sample
+	}
+	mean /= float64(len(samples))
+
+	sum := 0.0
+	for _, sample := range samples {
+		sum += (sample - mean) * (sample - mean)
+	}
+	return sum / float64(len(samples))
+}
+
+// percentile returns the pth percentile of samples (sorted in place) using
+// linear interpolation between closest ranks.
+func percentile(samples []float64, p float64) float64 {
+	sort.Float64s(samples)
+	if len(samples) == 1 {
+		return samples[0]
+	}
+	rank := p / 100 * float64(len(samples)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	if lower == upper {
+		return samples[lower]
+	}
+	return samples[lower] + (samples[upper]-samples[lower])*(rank-float64(lower))
+}
+
+// sliceSeriesValues splits values into equal slices the way the library's
+// aggregators do: leftover values at the start are dropped, and asking for
+// more slices than values keeps them all in one.
+func sliceSeriesValues(values []any, slices int) [][]any {
+	count := len(values)
+	if count == 0 {
+		return [][]any{}
+	}
+	size := 0
+	if slices > 1 {
+		size = count / slices
+	}
+	if size <= 0 {
+		return [][]any{values}
+	}
+	relevant := values[count-size*slices:]
+	out := make([][]any, 0, slices)
+	for i := 0; i+size <= len(relevant); i += size {
+		out = append(out, relevant[i:i+size])
+	}
+	return out
+}
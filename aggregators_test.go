@@ -0,0 +1,140 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	triflestats "github.com/trifle-io/trifle_stats_go"
+)
+
+func TestPercentile(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		samples []float64
+		p       float64
+		want    float64
+	}{
+		{name: "single point", samples: []float64{7}, p: 99, want: 7},
+		{name: "median even", samples: []float64{4, 1, 3, 2}, p: 50, want: 2.5},
+		{name: "median odd", samples: []float64{5, 1, 3}, p: 50, want: 3},
+		{name: "interpolated p90", samples: []float64{10, 20, 30, 40, 50}, p: 90, want: 46},
+		{name: "p99 of two", samples: []float64{0, 100}, p: 99, want: 99},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := percentile(tt.samples, tt.p); got != tt.want {
+				t.Fatalf("percentile(%v) = %v, want %v", tt.p, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAggregatePercentile(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	rows := []map[string]any{
+		{"latency": 10.0},
+		{},
+		{"latency": 30.0},
+		{"latency": 20.0},
+		{"latency": 40.0},
+	}
+	at := make([]time.Time, len(rows))
+	for i := range at {
+		at[i] = base.Add(time.Duration(i) * time.Hour)
+	}
+	series := triflestats.SeriesFromResult(triflestats.ValuesResult{At: at, Values: rows})
+
+	tests := []struct {
+		name   string
+		slices int
+		want   []any
+	}{
+		{name: "whole series skips nil", slices: 1, want: []any{25.0}},
+		// Five values in two slices drop the first bucket, like sum/mean.
+		{name: "two slices", slices: 2, want: []any{30.0, 30.0}},
+		{name: "more slices than samples", slices: 10, want: []any{25.0}},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got := aggregatePercentile(series, "latency", tt.slices, 50)
+			if len(got) != len(tt.want) {
+				t.Fatalf("aggregatePercentile = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("aggregatePercentile[%d] = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+
+	empty := aggregatePercentile(series, "missing", 1, 95)
+	if len(empty) != 1 || empty[0] != nil {
+		t.Fatalf("aggregatePercentile(missing) = %v, want [nil]", empty)
+	}
+}
+
+func TestAggregateStddevVariance(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	// 2, 4, 4, 4, 5, 5, 7, 9 has mean 5, population variance 4, stddev 2.
+	// The empty buckets must be skipped; as zeros they would shift both.
+	rows := []map[string]any{
+		{"v": 2.0}, {}, {"v": 4.0}, {"v": 4.0}, {"v": 4.0},
+		{"v": 5.0}, {"v": nil}, {"v": 5.0}, {"v": 7.0}, {"v": 9.0},
+	}
+	at := make([]time.Time, len(rows))
+	for i := range at {
+		at[i] = base.Add(time.Duration(i) * time.Hour)
+	}
+	series := triflestats.SeriesFromResult(triflestats.ValuesResult{At: at, Values: rows})
+
+	if got := aggregateVariance(series, "v", 1); len(got) != 1 || got[0] != 4.0 {
+		t.Fatalf("aggregateVariance = %v, want [4]", got)
+	}
+	if got := aggregateStddev(series, "v", 1); len(got) != 1 || got[0] != 2.0 {
+		t.Fatalf("aggregateStddev = %v, want [2]", got)
+	}
+
+	// Two slices of five: {2, 4, 4, 4} and {5, 5, 7, 9}.
+	got := aggregateVariance(series, "v", 2)
+	if len(got) != 2 || got[0] != 0.75 || got[1] != 2.75 {
+		t.Fatalf("aggregateVariance slices = %v, want [0.75 2.75]", got)
+	}
+
+	single := aggregateStddev(series, "v", 20)
+	if len(single) != 1 || single[0] != 2.0 {
+		t.Fatalf("aggregateStddev(20 slices) = %v, want [2]", single)
+	}
+	if got := aggregateStddev(series, "missing", 1); len(got) != 1 || got[0] != nil {
+		t.Fatalf("aggregateStddev(missing) = %v, want [nil]", got)
+	}
+}
+
+func TestAggregateSeriesPathAggregators(t *testing.T) {
+	t.Parallel()
+
+	series := triflestats.SeriesFromResult(triflestats.ValuesResult{
+		At:     []time.Time{time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+		Values: []map[string]any{{"v": 3.0}},
+	})
+	for _, name := range []string{"sum", "mean", "min", "max", "stddev", "variance", "p50", "p99"} {
+		if _, err := aggregateSeriesPath(series, name, "v", 1); err != nil {
+			t.Fatalf("aggregateSeriesPath(%s) error = %v", name, err)
+		}
+	}
+	if _, err := aggregateSeriesPath(series, "median", "v", 1); err == nil {
+		t.Fatal("aggregateSeriesPath(median) error = nil, want unsupported aggregator")
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	triflestats "github.com/trifle-io/trifle_stats_go"
+)
+
+// minAnomalyPoints is the smallest number of values a z-score is computed
+// over; fewer say nothing about what is unusual.
+const minAnomalyPoints = 3
+
+// anomalyPoint is a bucket whose value lies more than the threshold number of
+// standard deviations from its path's mean.
+type anomalyPoint struct {
+	At     string  `json:"at"`
+	Path   string  `json:"path"`
+	Value  float64 `json:"value"`
+	ZScore float64 `json:"z_score"`
+
+	row int
+}
+
+// validateAnomalyThreshold checks --anomalies; 0 turns detection off.
+func validateAnomalyThreshold(threshold float64) error {
+	if threshold < 0 || math.IsNaN(threshold) || math.IsInf(threshold, 0) {
+		return usageErrorf("--anomalies must be 0 (off) or a positive number of standard deviations (e.g. 3)")
+	}
+	return nil
+}
+
+// zScores returns each value's distance from the mean in population standard
+// deviations. Empty buckets stay nil and are left out of the mean. When there
+// are fewer than minAnomalyPoints values or they are all equal, every score
+// is nil.
+func zScores(values []any) []any {
+	out := make([]any, len(values))
+	numbers := make([]float64, 0, len(values))
+	for _, value := range values {
+		if number, ok := triflestats.NormalizeNumeric(value).(float64); ok && !math.IsNaN(number) {
+			numbers = append(numbers, number)
+		}
+	}
+	if len(numbers) < minAnomalyPoints {
+		return out
+	}
+
+	mean := 0.0
+	for _, number := range numbers {
+		mean += number
+	}
+	mean /= float64(len(numbers))
+	variance := 0.0
+	for _, number := range numbers {
+		variance += (number - mean) * (number - mean)
+	}
+	stddev := math.Sqrt(variance / float64(len(numbers)))
+	if stddev == 0 {
+		return out
+	}
+
+	for i, value := range values {
+		if number, ok := triflestats.NormalizeNumeric(value).(float64); ok && !math.IsNaN(number) {
+			out[i] = (number - mean) / stddev
+		}
+	}
+	return out
+}
+
+// findAnomalies flags the values of one path whose absolute z-score exceeds
+// threshold.
+func findAnomalies(path string, at []time.Time, values []any, threshold float64) []anomalyPoint {
+	var anomalies []anomalyPoint
+	for i, score := range zScores(values) {
+		z, ok := score.(float64)
+		if !ok || math.Abs(z) <= threshold {
+			continue
+		}
+		anomalies = append(anomalies, anomalyPoint{
+			At:     at[i].UTC().Format(time.RFC3339),
+			Path:   path,
+			Value:  triflestats.NormalizeNumeric(values[i]).(float64),
+			ZScore: z,
+			row:    i,
+		})
+	}
+	return anomalies
+}
+
+// timelineAnomalies scores each value column of a timeline table, or each
+// path of the result when there is no table. Sliced results are scored as
+// one continuous series. Call it before appendSmoothedColumns so moving
+// averages are not scored.
+func timelineAnomalies(data map[string]any, threshold float64) ([]anomalyPoint, error) {
+	anomalies := []anomalyPoint{}
+	if table, ok := data["table"].(map[string]any); ok {
+		columns, _ := table["columns"].([]any)
+		paths := make([]string, 0, len(columns))
+		for _, column := range columns {
+			paths = append(paths, fmt.Sprint(column))
+		}
+		column := 0
+		_, err := mapTableColumns(table, func(at []time.Time, values []any) []any {
+			column++
+			anomalies = append(anomalies, findAnomalies(paths[column], at, values, threshold)...)
+			return values
+		})
+		return anomalies, err
+	}
+
+	result, ok := data["result"].(map[string]any)
+	if !ok {
+		return anomalies, nil
+	}
+	for _, path := range mapKeys(result) {
+		points := flattenTimelinePoints(result[path])
+		at := make([]time.Time, len(points))
+		values := make([]any, len(points))
+		for i, point := range points {
+			parsed, err := parseBucketTime(point["at"])
+			if err != nil {
+				return nil, err
+			}
+			at[i] = parsed
+			values[i] = point["value"]
+		}
+		anomalies = append(anomalies, findAnomalies(path, at, values, threshold)...)
+	}
+	return anomalies, nil
+}
+
+// applyTimelineAnomalies adds the anomalies found above threshold to a
+// timeline payload, then appends the smoothed and anomaly table columns so
+// neither is scored. A threshold of 0 only appends the smoothed columns.
+func applyTimelineAnomalies(data map[string]any, threshold float64, smooth int) error {
+	var anomalies []anomalyPoint
+	if threshold > 0 {
+		var err error
+		if anomalies, err = timelineAnomalies(data, threshold); err != nil {
+			return err
+		}
+		data["anomaly_threshold"] = threshold
+		data["anomalies"] = anomalies
+	}
+	table, ok := data["table"].(map[string]any)
+	if !ok {
+		return nil
+	}
+	if smooth > 0 {
+		appendSmoothedColumns(table, smooth)
+	}
+	if threshold > 0 {
+		markAnomalyRows(table, anomalies)
+	}
+	return nil
+}
+
+// markAnomalyRows adds an "anomaly" column to a timeline table holding the
+// z-score of every flagged value in the row.
+func markAnomalyRows(table map[string]any, anomalies []anomalyPoint) {
+	columns, _ := table["columns"].([]any)
+	rows, _ := table["rows"].([]any)
+	if len(columns) < 2 {
+		return
+	}
+
+	marks := map[int][]string{}
+	for _, anomaly := range anomalies {
+		marks[anomaly.row] = append(marks[anomaly.row], fmt.Sprintf("%s z=%.2f", anomaly.Path, anomaly.ZScore))
+	}
+	table["columns"] = append(columns, "anomaly")
+	for i, raw := range rows {
+		if row, ok := raw.([]any); ok {
+			sort.Strings(marks[i])
+			rows[i] = append(row, strings.Join(marks[i], ", "))
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	triflestats "github.com/trifle-io/trifle_stats_go"
+)
+
+func TestZScores(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		values []any
+		want   []any
+	}{
+		{
+			name:   "scores numeric values and skips empty buckets",
+			values: []any{2, nil, 4, 6, "n/a"},
+			want:   []any{-math.Sqrt(1.5), nil, 0.0, math.Sqrt(1.5), nil},
+		},
+		{
+			name:   "too few points",
+			values: []any{1, nil, 100},
+			want:   []any{nil, nil, nil},
+		},
+		{
+			name:   "constant series",
+			values: []any{5, 5, 5, 5},
+			want:   []any{nil, nil, nil, nil},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := zScores(tt.values)
+			if len(got) != len(tt.want) {
+				t.Fatalf("zScores = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				want, ok := tt.want[i].(float64)
+				if !ok {
+					if got[i] != nil {
+						t.Fatalf("zScores[%d] = %v, want nil", i, got[i])
+					}
+					continue
+				}
+				if score, ok := got[i].(float64); !ok || math.Abs(score-want) > 1e-9 {
+					t.Fatalf("zScores[%d] = %v, want %v", i, got[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestTimelineSeriesPayloadAnomalies(t *testing.T) {
+	t.Parallel()
+
+	at := hourlyBuckets(10)
+	values := make([]map[string]any, len(at))
+	for i := range values {
+		values[i] = map[string]any{"count": 10}
+	}
+	values[3] = map[string]any{}
+	values[7] = map[string]any{"count": 100}
+
+	payload, err := timelineSeriesPayload(triflestats.SeriesFromResult(triflestats.ValuesResult{At: at, Values: values}), timelineSeriesRequest{
+		Key:       "event::logs",
+		ValuePath: "count",
+		Slices:    1,
+		Smooth:    2,
+		Anomalies: 2,
+	})
+	if err != nil {
+		t.Fatalf("timelineSeriesPayload error: %v", err)
+	}
+
+	anomalies := payload["anomalies"].([]anomalyPoint)
+	if len(anomalies) != 1 || anomalies[0].At != "2026-10-15T16:00:00Z" || anomalies[0].Value != 100 || anomalies[0].ZScore <= 2 {
+		t.Fatalf("anomalies = %+v, want the 100 at 16:00", anomalies)
+	}
+
+	table := payload["table"].(map[string]any)
+	if want := []any{"at", "count", "count (ma2)", "anomaly"}; !reflect.DeepEqual(table["columns"], want) {
+		t.Fatalf("columns = %v, want %v", table["columns"], want)
+	}
+	rows := table["rows"].([]any)
+	if mark := rows[7].([]any)[3]; mark != "count z=2.83" {
+		t.Fatalf("row 7 mark = %v, want count z=2.83", mark)
+	}
+	if mark := rows[6].([]any)[3]; mark != "" {
+		t.Fatalf("row 6 mark = %v, want empty", mark)
+	}
+}
+
+func TestTimelineAnomaliesFromResult(t *testing.T) {
+	t.Parallel()
+
+	data := map[string]any{
+		"result": map[string]any{
+			"count": []any{
+				map[string]any{"at": "2026-10-15T09:00:00Z", "value": 1.0},
+				map[string]any{"at": "2026-10-15T10:00:00Z", "value": 1.0},
+				map[string]any{"at": "2026-10-15T11:00:00Z", "value": 1.0},
+				map[string]any{"at": "2026-10-15T12:00:00Z", "value": 9.0},
+			},
+		},
+	}
+	anomalies, err := timelineAnomalies(data, 1.5)
+	if err != nil {
+		t.Fatalf("timelineAnomalies error: %v", err)
+	}
+	if len(anomalies) != 1 || anomalies[0].Path != "count" || anomalies[0].At != "2026-10-15T12:00:00Z" {
+		t.Fatalf("anomalies = %+v, want the 9 at 12:00", anomalies)
+	}
+}
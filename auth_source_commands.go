@@ -18,7 +18,7 @@ import (
 func runAuth(args []string) {
 	if len(args) == 0 {
 		authUsage()
-		os.Exit(1)
+		os.Exit(exitUsage)
 	}
 
 	switch args[0] {
@@ -33,14 +33,14 @@ func runAuth(args []string) {
 	default:
 		fmt.Fprintf(os.Stderr, "unknown auth command: %s\n", args[0])
 		authUsage()
-		os.Exit(1)
+		os.Exit(exitUsage)
 	}
 }
 
 func runSource(args []string) {
 	if len(args) == 0 {
 		sourceUsage()
-		os.Exit(1)
+		os.Exit(exitUsage)
 	}
 
 	switch args[0] {
@@ -59,7 +59,7 @@ func runSource(args []string) {
 	default:
 		fmt.Fprintf(os.Stderr, "unknown source command: %s\n", args[0])
 		sourceUsage()
-		os.Exit(1)
+		os.Exit(exitUsage)
 	}
 }
 
@@ -86,7 +86,7 @@ func authSignup(args []string) {
 		exitError(errors.New("missing base URL: set --url, TRIFLE_URL, or auth.url in config"))
 	}
 	if strings.TrimSpace(*email) == "" || strings.TrimSpace(*password) == "" {
-		exitError(errors.New("--email and --password are required"))
+		exitError(usageErrorf("--email and --password are required"))
 	}
 
 	client, err := api.New(baseURL, "", *timeout)
@@ -149,7 +149,7 @@ func authLogin(args []string) {
 		exitError(errors.New("missing base URL: set --url, TRIFLE_URL, or auth.url in config"))
 	}
 	if strings.TrimSpace(*email) == "" || strings.TrimSpace(*password) == "" {
-		exitError(errors.New("--email and --password are required"))
+		exitError(usageErrorf("--email and --password are required"))
 	}
 
 	client, err := api.New(baseURL, "", *timeout)
@@ -253,7 +253,7 @@ func sourceList(args []string) {
 func sourceCreate(args []string) {
 	if len(args) == 0 {
 		sourceCreateUsage()
-		os.Exit(1)
+		os.Exit(exitUsage)
 	}
 
 	switch args[0] {
@@ -266,7 +266,7 @@ func sourceCreate(args []string) {
 	default:
 		fmt.Fprintf(os.Stderr, "unknown source create command: %s\n", args[0])
 		sourceCreateUsage()
-		os.Exit(1)
+		os.Exit(exitUsage)
 	}
 }
 
@@ -298,14 +298,14 @@ func sourceCreateDatabase(args []string) {
 	fs.Parse(args)
 
 	if strings.TrimSpace(*displayName) == "" {
-		exitError(errors.New("--display-name is required"))
+		exitError(usageErrorf("--display-name is required"))
 	}
 	driverName := strings.ToLower(strings.TrimSpace(*driver))
 	if driverName == "" {
-		exitError(errors.New("--driver is required"))
+		exitError(usageErrorf("--driver is required"))
 	}
 	if strings.TrimSpace(*sqliteFile) != "" && driverName != "sqlite" {
-		exitError(errors.New("--sqlite-file is only supported with --driver sqlite"))
+		exitError(usageErrorf("--sqlite-file is only supported with --driver sqlite"))
 	}
 	if strings.TrimSpace(*sqliteFile) != "" {
 		if _, err := os.Stat(strings.TrimSpace(*sqliteFile)); err != nil {
@@ -405,7 +405,7 @@ func sourceCreateProject(args []string) {
 	fs.Parse(args)
 
 	if strings.TrimSpace(*name) == "" {
-		exitError(errors.New("--name is required"))
+		exitError(usageErrorf("--name is required"))
 	}
 
 	client, err := bootstrapClient(*url, *userToken, *timeout)
@@ -455,7 +455,7 @@ func sourceSetup(args []string) {
 	fs.Parse(args)
 
 	if strings.TrimSpace(*id) == "" {
-		exitError(errors.New("--id is required"))
+		exitError(usageErrorf("--id is required"))
 	}
 
 	client, err := bootstrapClient(*url, *userToken, *timeout)
@@ -476,7 +476,7 @@ func sourceSetup(args []string) {
 func sourceToken(args []string) {
 	if len(args) == 0 {
 		sourceTokenUsage()
-		os.Exit(1)
+		os.Exit(exitUsage)
 	}
 
 	switch args[0] {
@@ -487,7 +487,7 @@ func sourceToken(args []string) {
 	default:
 		fmt.Fprintf(os.Stderr, "unknown source token command: %s\n", args[0])
 		sourceTokenUsage()
-		os.Exit(1)
+		os.Exit(exitUsage)
 	}
 }
 
@@ -513,7 +513,7 @@ func sourceTokenCreate(args []string) {
 	fs.Parse(args)
 
 	if strings.TrimSpace(*sourceType) == "" || strings.TrimSpace(*sourceID) == "" {
-		exitError(errors.New("--source-type and --source-id are required"))
+		exitError(usageErrorf("--source-type and --source-id are required"))
 	}
 
 	client, err := bootstrapClient(*url, *userToken, *timeout)
@@ -597,7 +597,7 @@ func sourceUse(args []string) {
 	fs.Parse(args)
 
 	if strings.TrimSpace(*name) == "" {
-		exitError(errors.New("--name is required"))
+		exitError(usageErrorf("--name is required"))
 	}
 
 	cfg, path, err := loadConfigForWrite(*configPathFlag)
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	triflestats "github.com/trifle-io/trifle_stats_go"
+)
+
+// otherCategory names the entry that collects rolled-up categories.
+const otherCategory = "other"
+
+// categoryEntry is one category of a breakdown with its share of the total.
+type categoryEntry struct {
+	Category string  `json:"category"`
+	Value    float64 `json:"value"`
+	SharePct float64 `json:"share_pct"`
+}
+
+// categoryOptions holds the parsed --sort/--desc/--other-below/--top flags.
+type categoryOptions struct {
+	Sort       string
+	Desc       bool
+	OtherBelow float64
+	Top        int
+}
+
+// rollsUp reports whether any categories may be summed into "other".
+func (o categoryOptions) rollsUp() bool {
+	return o.OtherBelow > 0 || o.Top > 0
+}
+
+func newCategoryOptions(sortBy string, desc bool, otherBelow float64, top int) (categoryOptions, error) {
+	opts := categoryOptions{Sort: strings.ToLower(strings.TrimSpace(sortBy)), Desc: desc, OtherBelow: otherBelow, Top: top}
+	switch opts.Sort {
+	case "":
+		opts.Sort = "name"
+	case "name", "value":
+	default:
+		return opts, usageErrorf("unsupported --sort %q (use value or name)", sortBy)
+	}
+	if otherBelow < 0 || otherBelow > 100 {
+		return opts, usageErrorf("--other-below must be a percentage between 0 and 100")
+	}
+	if top < 0 {
+		return opts, usageErrorf("--top must be 0 or greater")
+	}
+	return opts, nil
+}
+
+// rankCategories turns one category map into entries with their share of
+// the total, sorted per opts. Categories under opts.OtherBelow percent, past
+// the opts.Top largest, or already named "other" are summed into a final
+// "other" entry; the count of categories rolled into it is returned.
+// Non-numeric values are skipped.
+func rankCategories(values map[string]any, opts categoryOptions) ([]categoryEntry, int) {
+	entries := make([]categoryEntry, 0, len(values))
+	var total float64
+	for category, raw := range values {
+		value, ok := triflestats.NormalizeNumeric(raw).(float64)
+		if !ok {
+			continue
+		}
+		entries = append(entries, categoryEntry{Category: category, Value: value})
+		total += value
+	}
+	for i := range entries {
+		if total != 0 {
+			entries[i].SharePct = roundTo(entries[i].Value/total*100, 2)
+		}
+	}
+
+	var other *categoryEntry
+	rolled := 0
+	if opts.rollsUp() {
+		// Largest first, so the top categories are the ones kept.
+		sort.Slice(entries, func(i, j int) bool {
+			if entries[i].Value != entries[j].Value {
+				return entries[i].Value > entries[j].Value
+			}
+			return entries[i].Category < entries[j].Category
+		})
+		kept := entries[:0]
+		for _, entry := range entries {
+			below := opts.OtherBelow > 0 && total != 0 && entry.Value/total*100 < opts.OtherBelow
+			beyond := opts.Top > 0 && len(kept) >= opts.Top
+			if !below && !beyond && entry.Category != otherCategory {
+				kept = append(kept, entry)
+				continue
+			}
+			if other == nil {
+				other = &categoryEntry{Category: otherCategory}
+			}
+			other.Value += entry.Value
+			if entry.Category != otherCategory {
+				rolled++
+			}
+		}
+		entries = kept
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if opts.Sort == "value" && a.Value != b.Value {
+			if opts.Desc {
+				return a.Value > b.Value
+			}
+			return a.Value < b.Value
+		}
+		if opts.Desc {
+			return a.Category > b.Category
+		}
+		return a.Category < b.Category
+	})
+
+	if other != nil {
+		if total != 0 {
+			other.SharePct = roundTo(other.Value/total*100, 2)
+		}
+		entries = append(entries, *other)
+	}
+	return entries, rolled
+}
+
+// categoryMaps returns the category maps of a formatted category result:
+// one for a single slice, one per slice otherwise.
+func categoryMaps(result any) ([]map[string]any, bool) {
+	switch value := result.(type) {
+	case map[string]any:
+		return []map[string]any{value}, false
+	case []map[string]any:
+		return value, true
+	case []any:
+		maps := make([]map[string]any, 0, len(value))
+		for _, entry := range value {
+			if mapEntry, ok := entry.(map[string]any); ok {
+				maps = append(maps, mapEntry)
+			}
+		}
+		return maps, true
+	}
+	return nil, false
+}
+
+// applyCategoryRanking adds the ranked "categories" of payload["result"] and
+// replaces the table with one row per category (and slice, when sliced).
+func applyCategoryRanking(payload map[string]any, opts categoryOptions) {
+	maps, sliced := categoryMaps(payload["result"])
+	if maps == nil {
+		return
+	}
+
+	rolled := 0
+	var rows []any
+	ranked := make([][]categoryEntry, len(maps))
+	for i, values := range maps {
+		entries, n := rankCategories(values, opts)
+		ranked[i] = entries
+		rolled += n
+		for _, entry := range entries {
+			row := []any{entry.Category, entry.Value, entry.SharePct}
+			if sliced {
+				row = append([]any{i + 1}, row...)
+			}
+			rows = append(rows, row)
+		}
+	}
+
+	columns := []any{"category", "value", "share_pct"}
+	if sliced {
+		columns = append([]any{"slice"}, columns...)
+		payload["categories"] = ranked
+	} else {
+		payload["categories"] = ranked[0]
+	}
+	if opts.rollsUp() {
+		payload["other_categories"] = rolled
+	}
+	if rows == nil {
+		rows = []any{}
+	}
+	payload["table"] = map[string]any{"columns": columns, "rows": rows}
+}
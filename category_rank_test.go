@@ -0,0 +1,136 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRankCategories(t *testing.T) {
+	t.Parallel()
+
+	values := map[string]any{"api": 60, "db": 30.0, "cache": 9, "queue": 1, "label": "n/a"}
+	tests := []struct {
+		name   string
+		opts   categoryOptions
+		want   []categoryEntry
+		rolled int
+	}{
+		{
+			name: "name ascending",
+			opts: categoryOptions{Sort: "name"},
+			want: []categoryEntry{
+				{Category: "api", Value: 60, SharePct: 60},
+				{Category: "cache", Value: 9, SharePct: 9},
+				{Category: "db", Value: 30, SharePct: 30},
+				{Category: "queue", Value: 1, SharePct: 1},
+			},
+		},
+		{
+			name: "value descending",
+			opts: categoryOptions{Sort: "value", Desc: true},
+			want: []categoryEntry{
+				{Category: "api", Value: 60, SharePct: 60},
+				{Category: "db", Value: 30, SharePct: 30},
+				{Category: "cache", Value: 9, SharePct: 9},
+				{Category: "queue", Value: 1, SharePct: 1},
+			},
+		},
+		{
+			name: "other below 10 percent stays last",
+			opts: categoryOptions{Sort: "value", OtherBelow: 10},
+			want: []categoryEntry{
+				{Category: "db", Value: 30, SharePct: 30},
+				{Category: "api", Value: 60, SharePct: 60},
+				{Category: "other", Value: 10, SharePct: 10},
+			},
+			rolled: 2,
+		},
+		{
+			name: "top 2 by name",
+			opts: categoryOptions{Sort: "name", Top: 2},
+			want: []categoryEntry{
+				{Category: "api", Value: 60, SharePct: 60},
+				{Category: "db", Value: 30, SharePct: 30},
+				{Category: "other", Value: 10, SharePct: 10},
+			},
+			rolled: 2,
+		},
+		{
+			name: "top larger than the breakdown",
+			opts: categoryOptions{Sort: "value", Desc: true, Top: 10},
+			want: []categoryEntry{
+				{Category: "api", Value: 60, SharePct: 60},
+				{Category: "db", Value: 30, SharePct: 30},
+				{Category: "cache", Value: 9, SharePct: 9},
+				{Category: "queue", Value: 1, SharePct: 1},
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, rolled := rankCategories(values, tt.opts)
+			if !reflect.DeepEqual(got, tt.want) || rolled != tt.rolled {
+				t.Fatalf("rankCategories = %v (%d rolled), want %v (%d rolled)", got, rolled, tt.want, tt.rolled)
+			}
+		})
+	}
+}
+
+func TestRankCategoriesMergesExistingOther(t *testing.T) {
+	t.Parallel()
+
+	got, rolled := rankCategories(map[string]any{"api": 95, "other": 4, "db": 1}, categoryOptions{Sort: "name", OtherBelow: 2})
+	want := []categoryEntry{{Category: "api", Value: 95, SharePct: 95}, {Category: "other", Value: 5, SharePct: 5}}
+	if !reflect.DeepEqual(got, want) || rolled != 1 {
+		t.Fatalf("rankCategories = %v (%d rolled), want %v (1 rolled)", got, rolled, want)
+	}
+}
+
+func TestApplyCategoryRankingSliced(t *testing.T) {
+	t.Parallel()
+
+	payload := map[string]any{
+		"result": []any{
+			map[string]any{"ok": 3.0, "error": 1.0},
+			map[string]any{"ok": 0.0},
+		},
+	}
+	applyCategoryRanking(payload, categoryOptions{Sort: "value", Desc: true})
+
+	table := payload["table"].(map[string]any)
+	wantColumns := []any{"slice", "category", "value", "share_pct"}
+	wantRows := []any{
+		[]any{1, "ok", 3.0, 75.0},
+		[]any{1, "error", 1.0, 25.0},
+		[]any{2, "ok", 0.0, 0.0},
+	}
+	if !reflect.DeepEqual(table["columns"], wantColumns) || !reflect.DeepEqual(table["rows"], wantRows) {
+		t.Fatalf("table = %v, want columns %v and rows %v", table, wantColumns, wantRows)
+	}
+	if categories := payload["categories"].([][]categoryEntry); len(categories) != 2 {
+		t.Fatalf("categories = %v, want one list per slice", categories)
+	}
+	if _, ok := payload["other_categories"]; ok {
+		t.Fatal("other_categories set without --other-below")
+	}
+}
+
+func TestNewCategoryOptions(t *testing.T) {
+	t.Parallel()
+
+	if _, err := newCategoryOptions("size", false, 0, 0); err == nil {
+		t.Fatal("expected an unsupported --sort error")
+	}
+	if _, err := newCategoryOptions("value", false, 120, 0); err == nil {
+		t.Fatal("expected an --other-below range error")
+	}
+	if _, err := newCategoryOptions("value", false, 0, -1); err == nil {
+		t.Fatal("expected a negative --top error")
+	}
+	opts, err := newCategoryOptions(" Value ", true, 1, 10)
+	if err != nil || opts.Sort != "value" || !opts.Desc || opts.OtherBelow != 1 || opts.Top != 10 {
+		t.Fatalf("opts = %+v, err = %v", opts, err)
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/trifle-io/trifle-cli/internal/api"
+	triflestats "github.com/trifle-io/trifle_stats_go"
+)
+
+// compareFlags holds the aggregate --compare options.
+type compareFlags struct {
+	Mode string
+	From string
+	To   string
+}
+
+func addCompareFlags(fs *flag.FlagSet) *compareFlags {
+	c := &compareFlags{}
+	fs.StringVar(&c.Mode, "compare", "", "Compare with another window: previous (the preceding window of equal length)")
+	fs.StringVar(&c.From, "compare-from", "", "Start of the comparison window (RFC3339, epoch, or relative); needs --compare-to")
+	fs.StringVar(&c.To, "compare-to", "", "End of the comparison window (RFC3339, epoch, or relative); needs --compare-from")
+	return c
+}
+
+func (c *compareFlags) enabled() bool {
+	return strings.TrimSpace(c.Mode) != "" || strings.TrimSpace(c.From) != "" || strings.TrimSpace(c.To) != ""
+}
+
+// validate rejects flag combinations that have no side-by-side rendering.
+func (c *compareFlags) validate(slices int, format string, quiet bool) error {
+	if !c.enabled() {
+		return nil
+	}
+	mode := strings.ToLower(strings.TrimSpace(c.Mode))
+	custom := strings.TrimSpace(c.From) != "" || strings.TrimSpace(c.To) != ""
+	switch {
+	case mode != "" && mode != "previous":
+		return usageErrorf("unsupported --compare %q (use previous, or --compare-from/--compare-to)", c.Mode)
+	case mode != "" && custom:
+		return usageErrorf("--compare cannot be combined with --compare-from/--compare-to")
+	case custom && (strings.TrimSpace(c.From) == "" || strings.TrimSpace(c.To) == ""):
+		return usageErrorf("--compare-from and --compare-to must be used together")
+	case slices != 1:
+		return usageErrorf("--compare cannot be combined with --slices")
+	case quiet:
+		return usageErrorf("--quiet cannot be combined with --compare")
+	case format == "prom" || format == "summary":
+		return usageErrorf("--compare cannot be combined with --format %s", format)
+	}
+	return nil
+}
+
+// window resolves the comparison window for the current from/to.
+func (c *compareFlags) window(fromValue, toValue, granularity string, opts *driverOptions) (string, string, error) {
+	if strings.TrimSpace(c.From) != "" {
+		return resolveTimeRange(c.From, c.To, "")
+	}
+	return previousWindow(fromValue, toValue, granularity, opts)
+}
+
+// previousWindow returns the window holding as many granularity buckets as
+// from..to, ending on the last second before from's bucket, so the two
+// windows never share a bucket.
+func previousWindow(fromValue, toValue, granularity string, opts *driverOptions) (string, string, error) {
+	fromTime, err := time.Parse(time.RFC3339Nano, fromValue)
+	if err != nil {
+		return "", "", err
+	}
+	toTime, err := time.Parse(time.RFC3339Nano, toValue)
+	if err != nil {
+		return "", "", err
+	}
+	cfg, err := bucketConfig(opts)
+	if err != nil {
+		return "", "", err
+	}
+
+	start, end, err := previousBuckets(fromTime, toTime, granularity, cfg)
+	if err != nil {
+		return "", "", err
+	}
+	return start.UTC().Format(time.RFC3339), end.UTC().Format(time.RFC3339), nil
+}
+
+func previousBuckets(from, to time.Time, granularity string, cfg *triflestats.Config) (time.Time, time.Time, error) {
+	parser := triflestats.NewParser(granularity)
+	if !parser.Valid() {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid granularity: %s", granularity)
+	}
+
+	buckets := triflestats.Timeline(from, to, parser.Offset, parser.Unit, cfg)
+	if len(buckets) == 0 {
+		return time.Time{}, time.Time{}, fmt.Errorf("no %s buckets between %s and %s", granularity, from.Format(time.RFC3339), to.Format(time.RFC3339))
+	}
+	first := triflestats.NewNocturnal(buckets[0], cfg)
+	start := first.Add(-parser.Offset*len(buckets), parser.Unit)
+	return start, buckets[0].Add(-time.Second), nil
+}
+
+// aggregateComparison is one value path aggregated over the current and the
+// comparison window.
+type aggregateComparison struct {
+	ValuePath string
+	Current   any
+	Previous  any
+}
+
+// change returns current minus previous, and that change as a percentage of
+// previous. Either is nil when a window has no data; the percentage is also
+// nil when previous is zero.
+func (c aggregateComparison) change() (any, any) {
+	current, ok := triflestats.NormalizeNumeric(c.Current).(float64)
+	if !ok {
+		return nil, nil
+	}
+	previous, ok := triflestats.NormalizeNumeric(c.Previous).(float64)
+	if !ok {
+		return nil, nil
+	}
+	change := current - previous
+	if previous == 0 {
+		return change, nil
+	}
+	return change, change / math.Abs(previous) * 100
+}
+
+func (c aggregateComparison) fields() map[string]any {
+	change, changePct := c.change()
+	return map[string]any{
+		"current":    triflestats.NormalizeNumeric(c.Current),
+		"previous":   triflestats.NormalizeNumeric(c.Previous),
+		"change":     change,
+		"change_pct": changePct,
+	}
+}
+
+// firstAggregateValue returns the single value of an unsliced aggregate.
+func firstAggregateValue(values []any) any {
+	if len(values) == 0 {
+		return nil
+	}
+	return triflestats.NormalizeNumeric(values[0])
+}
+
+// applyComparison adds current, previous, change, and change_pct to
+// payload, keyed by path for wildcards like the value field, and replaces
+// the table with one row per path.
+func applyComparison(payload map[string]any, comparisons []aggregateComparison, byPath bool, previousTimeframe map[string]string) {
+	names := []string{"current", "previous", "change", "change_pct"}
+	if byPath {
+		for _, name := range names {
+			payload[name] = map[string]any{}
+		}
+	}
+
+	rows := make([]any, 0, len(comparisons))
+	for _, comparison := range comparisons {
+		fields := comparison.fields()
+		row := []any{comparison.ValuePath}
+		for _, name := range names {
+			row = append(row, fields[name])
+			if byPath {
+				payload[name].(map[string]any)[comparison.ValuePath] = fields[name]
+			} else {
+				payload[name] = fields[name]
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	payload["compare_timeframe"] = previousTimeframe
+	payload["table"] = map[string]any{
+		"columns": []any{"path", "current", "previous", "change", "change_pct"},
+		"rows":    rows,
+	}
+}
+
+// queryMetricsPair runs the current and comparison queries concurrently.
+func queryMetricsPair(ctx context.Context, client *api.Client, current, previous map[string]any, weekStart string) (map[string]any, map[string]any, error) {
+	var (
+		wg                    sync.WaitGroup
+		currentData, prevData map[string]any
+		currentErr, prevErr   error
+	)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		currentData, currentErr = queryMetrics(ctx, client, current, weekStart)
+	}()
+	go func() {
+		defer wg.Done()
+		prevData, prevErr = queryMetrics(ctx, client, previous, weekStart)
+	}()
+	wg.Wait()
+
+	if currentErr != nil {
+		return nil, nil, currentErr
+	}
+	if prevErr != nil {
+		return nil, nil, fmt.Errorf("comparison window: %w", prevErr)
+	}
+	return currentData, prevData, nil
+}
+
+// compareLabel names the comparison timeframe in the payload.
+func compareLabel(c *compareFlags) string {
+	if strings.TrimSpace(c.From) != "" {
+		return "custom"
+	}
+	return "previous"
+}
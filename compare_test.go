@@ -0,0 +1,109 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	triflestats "github.com/trifle-io/trifle_stats_go"
+)
+
+func TestPreviousBuckets(t *testing.T) {
+	t.Parallel()
+
+	cfg := triflestats.DefaultConfig()
+	from := time.Date(2026, 10, 8, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 10, 14, 23, 59, 59, 0, time.UTC)
+
+	start, end, err := previousBuckets(from, to, "1d", cfg)
+	if err != nil {
+		t.Fatalf("previousBuckets error: %v", err)
+	}
+	if want := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC); !start.Equal(want) {
+		t.Fatalf("start = %s, want %s", start, want)
+	}
+	if want := time.Date(2026, 10, 7, 23, 59, 59, 0, time.UTC); !end.Equal(want) {
+		t.Fatalf("end = %s, want %s", end, want)
+	}
+
+	if _, _, err := previousBuckets(from, to, "daily", cfg); err == nil {
+		t.Fatalf("expected error for invalid granularity")
+	}
+}
+
+func TestAggregateComparisonChange(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		current    any
+		previous   any
+		wantChange any
+		wantPct    any
+	}{
+		{name: "increase", current: 150, previous: 100, wantChange: 50.0, wantPct: 50.0},
+		{name: "decrease from negative", current: -30.0, previous: -20.0, wantChange: -10.0, wantPct: -50.0},
+		{name: "previous zero", current: 5, previous: 0, wantChange: 5.0, wantPct: nil},
+		{name: "missing previous", current: 5, previous: nil, wantChange: nil, wantPct: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			change, pct := aggregateComparison{Current: tt.current, Previous: tt.previous}.change()
+			if change != tt.wantChange || pct != tt.wantPct {
+				t.Fatalf("change() = %v, %v, want %v, %v", change, pct, tt.wantChange, tt.wantPct)
+			}
+		})
+	}
+}
+
+func TestApplyComparison(t *testing.T) {
+	t.Parallel()
+
+	comparisons := []aggregateComparison{
+		{ValuePath: "count", Current: 12.0, Previous: 8.0},
+		{ValuePath: "errors", Current: 1.0, Previous: nil},
+	}
+
+	payload := map[string]any{}
+	applyComparison(payload, comparisons[:1], false, nil)
+	if payload["current"] != 12.0 || payload["previous"] != 8.0 || payload["change"] != 4.0 || payload["change_pct"] != 50.0 {
+		t.Fatalf("unexpected payload: %v", payload)
+	}
+
+	byPath := map[string]any{}
+	applyComparison(byPath, comparisons, true, nil)
+	if want := map[string]any{"count": 4.0, "errors": nil}; !reflect.DeepEqual(byPath["change"], want) {
+		t.Fatalf("change = %v, want %v", byPath["change"], want)
+	}
+	rows := byPath["table"].(map[string]any)["rows"].([]any)
+	if want := []any{"errors", 1.0, nil, nil, nil}; !reflect.DeepEqual(rows[1], want) {
+		t.Fatalf("row = %v, want %v", rows[1], want)
+	}
+}
+
+func TestCompareFlagsValidate(t *testing.T) {
+	t.Parallel()
+
+	valid := []compareFlags{{}, {Mode: "previous"}, {From: "2026-01-01T00:00:00Z", To: "2026-01-08T00:00:00Z"}}
+	for _, flags := range valid {
+		if err := flags.validate(1, "table", false); err != nil {
+			t.Fatalf("validate(%+v) error: %v", flags, err)
+		}
+	}
+
+	previous := compareFlags{Mode: "previous"}
+	for name, err := range map[string]error{
+		"unknown mode": (&compareFlags{Mode: "yesterday"}).validate(1, "json", false),
+		"mixed":        (&compareFlags{Mode: "previous", From: "-14d", To: "-7d"}).validate(1, "json", false),
+		"half custom":  (&compareFlags{From: "-14d"}).validate(1, "json", false),
+		"slices":       previous.validate(4, "json", false),
+		"quiet":        previous.validate(1, "json", true),
+		"summary":      previous.validate(1, "summary", false),
+	} {
+		if err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
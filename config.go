@@ -16,6 +16,7 @@ type cliConfig struct {
 	Source  string
 	Auth    *authConfig
 	Sources map[string]sourceConfig
+	Schemas map[string]keySchema
 }
 
 type authConfig struct {
@@ -27,33 +28,36 @@ type authConfig struct {
 }
 
 type sourceConfig struct {
-	Driver          string            `yaml:"driver"`
-	URL             string            `yaml:"url"`
-	Token           string            `yaml:"token"`
-	SourceType      string            `yaml:"source_type"`
-	SourceID        string            `yaml:"source_id"`
-	Timeout         string            `yaml:"timeout"`
-	DB              string            `yaml:"db"`
-	DSN             string            `yaml:"dsn"`
-	Host            string            `yaml:"host"`
-	Port            string            `yaml:"port"`
-	User            string            `yaml:"user"`
-	Password        string            `yaml:"password"`
-	Database        string            `yaml:"database"`
-	Table           string            `yaml:"table"`
-	Collection      string            `yaml:"collection"`
-	Prefix          string            `yaml:"prefix"`
-	Joined          string            `yaml:"joined"`
-	Separator       string            `yaml:"separator"`
-	TimeZone        string            `yaml:"timezone"`
-	WeekStart       string            `yaml:"week_start"`
-	Granularities   configStringSlice `yaml:"granularities"`
-	BufferMode      string            `yaml:"buffer_mode"`
-	BufferDrivers   configStringSlice `yaml:"buffer_drivers"`
-	BufferSize      int               `yaml:"buffer_size"`
-	BufferDuration  string            `yaml:"buffer_duration"`
-	BufferAggregate *bool             `yaml:"buffer_aggregate"`
-	BufferAsync     *bool             `yaml:"buffer_async"`
+	Driver           string            `yaml:"driver"`
+	URL              string            `yaml:"url"`
+	Token            string            `yaml:"token"`
+	SourceType       string            `yaml:"source_type"`
+	SourceID         string            `yaml:"source_id"`
+	Timeout          string            `yaml:"timeout"`
+	DB               string            `yaml:"db"`
+	DSN              string            `yaml:"dsn"`
+	Host             string            `yaml:"host"`
+	Port             string            `yaml:"port"`
+	User             string            `yaml:"user"`
+	Password         string            `yaml:"password"`
+	Database         string            `yaml:"database"`
+	Table            string            `yaml:"table"`
+	Collection       string            `yaml:"collection"`
+	Prefix           string            `yaml:"prefix"`
+	Joined           string            `yaml:"joined"`
+	Separator        string            `yaml:"separator"`
+	TimeZone         string            `yaml:"timezone"`
+	WeekStart        string            `yaml:"week_start"`
+	DisplayTimeZone  string            `yaml:"display_timezone"`
+	DefaultTimeframe string            `yaml:"default_timeframe"`
+	DefaultSlices    int               `yaml:"default_slices"`
+	Granularities    configStringSlice `yaml:"granularities"`
+	BufferMode       string            `yaml:"buffer_mode"`
+	BufferDrivers    configStringSlice `yaml:"buffer_drivers"`
+	BufferSize       int               `yaml:"buffer_size"`
+	BufferDuration   string            `yaml:"buffer_duration"`
+	BufferAggregate  *bool             `yaml:"buffer_aggregate"`
+	BufferAsync      *bool             `yaml:"buffer_async"`
 
 	TimeoutDuration time.Duration `yaml:"-"`
 	TimeoutSet      bool          `yaml:"-"`
@@ -103,6 +107,10 @@ func (c *cliConfig) UnmarshalYAML(value *yaml.Node) error {
 				}
 				c.Sources[name] = src
 			}
+		case "schemas":
+			if err := valNode.Decode(&c.Schemas); err != nil {
+				return err
+			}
 		case "auth":
 			var auth authConfig
 			if err := valNode.Decode(&auth); err != nil {
@@ -186,6 +194,18 @@ func (c *cliConfig) normalize() error {
 		}
 		c.Sources[name] = src
 	}
+	for key, schema := range c.Schemas {
+		for pattern, kind := range schema {
+			kind = strings.ToLower(strings.TrimSpace(kind))
+			if kind == "bool" {
+				kind = "boolean"
+			}
+			schema[pattern] = kind
+		}
+		if err := schema.validate(); err != nil {
+			return fmt.Errorf("schema %s: %w", key, err)
+		}
+	}
 	return nil
 }
 
@@ -356,18 +376,18 @@ func findConfigPath(args []string) (string, bool, error) {
 		}
 		if arg == "--config" {
 			if i+1 >= len(args) {
-				return "", true, fmt.Errorf("--config requires a value")
+				return "", true, usageErrorf("--config requires a value")
 			}
 			value := strings.TrimSpace(args[i+1])
 			if value == "" {
-				return "", true, fmt.Errorf("--config requires a value")
+				return "", true, usageErrorf("--config requires a value")
 			}
 			return value, true, nil
 		}
 		if strings.HasPrefix(arg, "--config=") {
 			value := strings.TrimSpace(strings.TrimPrefix(arg, "--config="))
 			if value == "" {
-				return "", true, fmt.Errorf("--config requires a value")
+				return "", true, usageErrorf("--config requires a value")
 			}
 			return value, true, nil
 		}
@@ -383,18 +403,18 @@ func findSourceName(args []string) (string, bool, error) {
 		}
 		if arg == "--source" {
 			if i+1 >= len(args) {
-				return "", true, fmt.Errorf("--source requires a value")
+				return "", true, usageErrorf("--source requires a value")
 			}
 			value := strings.TrimSpace(args[i+1])
 			if value == "" {
-				return "", true, fmt.Errorf("--source requires a value")
+				return "", true, usageErrorf("--source requires a value")
 			}
 			return value, true, nil
 		}
 		if strings.HasPrefix(arg, "--source=") {
 			value := strings.TrimSpace(strings.TrimPrefix(arg, "--source="))
 			if value == "" {
-				return "", true, fmt.Errorf("--source requires a value")
+				return "", true, usageErrorf("--source requires a value")
 			}
 			return value, true, nil
 		}
@@ -487,6 +507,7 @@ type persistedConfig struct {
 	Source  string                  `yaml:"source,omitempty"`
 	Auth    *authConfig             `yaml:"auth,omitempty"`
 	Sources map[string]sourceConfig `yaml:"sources,omitempty"`
+	Schemas map[string]keySchema    `yaml:"schemas,omitempty"`
 }
 
 func resolveConfigPathForWrite(configPath string) (string, error) {
@@ -540,6 +561,7 @@ func saveConfigFile(path string, cfg *cliConfig) error {
 		Source:  strings.TrimSpace(cfg.Source),
 		Auth:    cfg.Auth,
 		Sources: cfg.Sources,
+		Schemas: cfg.Schemas,
 	}
 
 	encoded, err := yaml.Marshal(&data)
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/trifle-io/trifle-cli/internal/api"
+)
+
+// Exit codes by error category, so scripts can tell bad invocations from
+// server trouble without parsing messages.
+const (
+	exitGeneral   = 1
+	exitUsage     = 2
+	exitAPIClient = 3
+	exitAPIServer = 4
+	exitDriver    = 5
+)
+
+// jsonErrors switches exitError to JSON on stderr. Commands enable it from
+// outputOptions.validate when --format json or --json-errors is passed.
+var jsonErrors bool
+
+// kindError tags an error with its category for reporting.
+type kindError struct {
+	kind string
+	err  error
+}
+
+func (e *kindError) Error() string { return e.err.Error() }
+func (e *kindError) Unwrap() error { return e.err }
+
+// usageErrorf reports a bad or missing flag.
+func usageErrorf(format string, args ...any) error {
+	return &kindError{kind: "usage", err: fmt.Errorf(format, args...)}
+}
+
+// driverError marks a failure talking to a local storage driver.
+func driverError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &kindError{kind: "driver", err: err}
+}
+
+// classifyError returns the kind, API status (0 when not an API error), and
+// exit code for err.
+func classifyError(err error) (string, int, int) {
+	var apiErr *api.Error
+	if errors.As(err, &apiErr) {
+		if apiErr.StatusCode >= 500 {
+			return "api", apiErr.StatusCode, exitAPIServer
+		}
+		return "api", apiErr.StatusCode, exitAPIClient
+	}
+
+	var tagged *kindError
+	if errors.As(err, &tagged) {
+		switch tagged.kind {
+		case "usage":
+			return "usage", 0, exitUsage
+		case "driver":
+			return "driver", 0, exitDriver
+		}
+	}
+	return "error", 0, exitGeneral
+}
+
+// reportError writes err to w, as JSON when asJSON is set, and returns the
+// exit code for its category.
+func reportError(w io.Writer, err error, asJSON bool) int {
+	if err == nil {
+		return exitGeneral
+	}
+	kind, status, code := classifyError(err)
+	if !asJSON {
+		fmt.Fprintln(w, err.Error())
+		return code
+	}
+
+	body := map[string]any{"message": err.Error(), "kind": kind}
+	if status != 0 {
+		body["status"] = status
+	}
+	encoded, marshalErr := json.Marshal(map[string]any{"error": body})
+	if marshalErr != nil {
+		fmt.Fprintln(w, err.Error())
+		return code
+	}
+	fmt.Fprintln(w, string(encoded))
+	return code
+}
+
+func exitError(err error) {
+	os.Exit(reportError(os.Stderr, err, jsonErrors))
+}
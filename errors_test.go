@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/trifle-io/trifle-cli/internal/api"
+)
+
+func TestClassifyError(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		err        error
+		wantKind   string
+		wantStatus int
+		wantCode   int
+	}{
+		{name: "plain", err: errors.New("boom"), wantKind: "error", wantCode: exitGeneral},
+		{name: "usage", err: usageErrorf("--key is required"), wantKind: "usage", wantCode: exitUsage},
+		{name: "api not found", err: &api.Error{StatusCode: 404}, wantKind: "api", wantStatus: 404, wantCode: exitAPIClient},
+		{name: "api server", err: &api.Error{StatusCode: 503}, wantKind: "api", wantStatus: 503, wantCode: exitAPIServer},
+		{name: "wrapped api", err: fmt.Errorf("query: %w", &api.Error{StatusCode: 401}), wantKind: "api", wantStatus: 401, wantCode: exitAPIClient},
+		{name: "driver", err: driverError(errors.New("no such table")), wantKind: "driver", wantCode: exitDriver},
+	}
+
+	for _, tt := range tests {
+		kind, status, code := classifyError(tt.err)
+		if kind != tt.wantKind || status != tt.wantStatus || code != tt.wantCode {
+			t.Fatalf("%s: classifyError = (%q, %d, %d), want (%q, %d, %d)", tt.name, kind, status, code, tt.wantKind, tt.wantStatus, tt.wantCode)
+		}
+	}
+
+	if driverError(nil) != nil {
+		t.Fatalf("driverError(nil) should be nil")
+	}
+}
+
+func TestReportError(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	code := reportError(&buf, &api.Error{StatusCode: 404, Body: "not found"}, true)
+	if code != exitAPIClient {
+		t.Fatalf("reportError code = %d, want %d", code, exitAPIClient)
+	}
+	var decoded struct {
+		Error struct {
+			Message string `json:"message"`
+			Status  int    `json:"status"`
+			Kind    string `json:"kind"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("reportError wrote invalid JSON %q: %v", buf.String(), err)
+	}
+	if decoded.Error.Message != "api request failed with status 404: not found" || decoded.Error.Status != 404 || decoded.Error.Kind != "api" {
+		t.Fatalf("reportError JSON = %s", buf.String())
+	}
+
+	buf.Reset()
+	if code := reportError(&buf, usageErrorf("--key is required"), false); code != exitUsage {
+		t.Fatalf("reportError code = %d, want %d", code, exitUsage)
+	}
+	if buf.String() != "--key is required\n" {
+		t.Fatalf("reportError text = %q", buf.String())
+	}
+
+	buf.Reset()
+	reportError(&buf, driverError(errors.New("no such table")), true)
+	if buf.String() != `{"error":{"kind":"driver","message":"no such table"}}`+"\n" {
+		t.Fatalf("reportError driver JSON = %q", buf.String())
+	}
+}
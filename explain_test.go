@@ -0,0 +1,95 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/trifle-io/trifle-cli/internal/api"
+)
+
+func TestRedactDSN(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		dsn  string
+		want string
+	}{
+		{name: "postgres url", dsn: "postgres://bob:s3cret@db:5432/stats?sslmode=disable", want: "postgres://bob:xxxxx@db:5432/stats?sslmode=disable"},
+		{name: "url query password", dsn: "mongodb://db:27017/?password=s3cret", want: "mongodb://db:27017/?password=xxxxx"},
+		{name: "url without password", dsn: "redis://db:6379/0", want: "redis://db:6379/0"},
+		{name: "key value", dsn: "host=db user=bob password=s3cret dbname=stats", want: "host=db user=bob password=xxxxx dbname=stats"},
+		{name: "key value quoted", dsn: "host=db password='s3 cret' dbname=stats", want: "host=db password=xxxxx dbname=stats"},
+		{name: "mysql", dsn: "root:p@ss@tcp(db:3306)/stats?parseTime=true", want: "root:xxxxx@tcp(db:3306)/stats?parseTime=true"},
+		{name: "plain address", dsn: "db:6379", want: "db:6379"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := redactDSN(tt.dsn); got != tt.want {
+				t.Fatalf("redactDSN(%q) = %q, want %q", tt.dsn, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaskToken(t *testing.T) {
+	t.Parallel()
+
+	if got := maskToken("short"); got != "****" {
+		t.Fatalf("maskToken(short) = %q, want %q", got, "****")
+	}
+	if got := maskToken("trf_0123456789abcd"); got != "****abcd" {
+		t.Fatalf("maskToken(long) = %q, want %q", got, "****abcd")
+	}
+}
+
+func TestLocalPlanRedactsPassword(t *testing.T) {
+	t.Parallel()
+
+	opts := &driverOptions{
+		Driver:          "postgres",
+		User:            "bob",
+		Password:        "s3cret",
+		Joined:          "full",
+		BeginningOfWeek: "monday",
+	}
+	local, err := prepareLocalConfig(opts)
+	if err != nil {
+		t.Fatalf("prepareLocalConfig returned error: %v", err)
+	}
+
+	plan := localPlan("metrics get", local, opts, map[string]any{"key": "event::logs"})
+	dsn, _ := plan["dsn"].(string)
+	if strings.Contains(dsn, "s3cret") || !strings.Contains(dsn, "bob:xxxxx@") {
+		t.Fatalf("dsn = %q, want password redacted", dsn)
+	}
+	buffer, _ := plan["buffer"].(map[string]any)
+	if buffer["enabled"] != true {
+		t.Fatalf("buffer.enabled = %v, want true", buffer["enabled"])
+	}
+}
+
+func TestExplainGetMetricsAddsWeekStart(t *testing.T) {
+	t.Parallel()
+
+	client, err := api.New("https://trifle.example", "token", 0)
+	if err != nil {
+		t.Fatalf("api.New returned error: %v", err)
+	}
+	params := map[string]string{"key": "event::logs", "granularity": "1w", "from": "a", "to": "b"}
+
+	request, err := explainGetMetrics(client, params, "sunday")
+	if err != nil {
+		t.Fatalf("explainGetMetrics returned error: %v", err)
+	}
+	want := "https://trifle.example/api/v1/metrics?from=a&granularity=1w&key=event%3A%3Alogs&to=b&week_start=sunday"
+	if request.URL != want {
+		t.Fatalf("URL = %q, want %q", request.URL, want)
+	}
+	if _, ok := params["week_start"]; ok {
+		t.Fatalf("params mutated: %v", params)
+	}
+}
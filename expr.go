@@ -0,0 +1,284 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// metricExpr is a parsed --expr: arithmetic over value paths with +, -, *,
+// /, parentheses, and numeric literals.
+type metricExpr struct {
+	Source string
+	root   exprNode
+	paths  []string
+}
+
+// exprNode evaluates to a number, or reports false when a path has no value
+// or a division by zero leaves the result undefined.
+type exprNode interface {
+	eval(values map[string]float64) (float64, bool)
+}
+
+type exprNumber float64
+
+func (n exprNumber) eval(map[string]float64) (float64, bool) {
+	return float64(n), true
+}
+
+type exprPath string
+
+func (p exprPath) eval(values map[string]float64) (float64, bool) {
+	value, ok := values[string(p)]
+	return value, ok
+}
+
+type exprNegate struct {
+	operand exprNode
+}
+
+func (n exprNegate) eval(values map[string]float64) (float64, bool) {
+	value, ok := n.operand.eval(values)
+	return -value, ok
+}
+
+type exprBinary struct {
+	op          byte
+	left, right exprNode
+}
+
+func (b exprBinary) eval(values map[string]float64) (float64, bool) {
+	left, ok := b.left.eval(values)
+	if !ok {
+		return 0, false
+	}
+	right, ok := b.right.eval(values)
+	if !ok {
+		return 0, false
+	}
+	switch b.op {
+	case '+':
+		return left + right, true
+	case '-':
+		return left - right, true
+	case '*':
+		return left * right, true
+	default:
+		if right == 0 {
+			return 0, false
+		}
+		return left / right, true
+	}
+}
+
+// Paths returns the value paths the expression reads, in order of first use.
+func (e *metricExpr) Paths() []string {
+	return e.paths
+}
+
+// Eval evaluates the expression; it returns nil when a path is missing from
+// values or a division by zero occurs.
+func (e *metricExpr) Eval(values map[string]float64) any {
+	result, ok := e.root.eval(values)
+	if !ok || math.IsNaN(result) || math.IsInf(result, 0) {
+		return nil
+	}
+	return result
+}
+
+// parseMetricExpr parses src with the usual precedence: unary minus binds
+// tightest, then * and /, then + and -, all left-associative. Paths are
+// runs of letters, digits, _, and . that do not start with a digit or dot.
+func parseMetricExpr(src string) (*metricExpr, error) {
+	p := &exprParser{src: src}
+	p.next()
+	if p.err != nil {
+		return nil, p.err
+	}
+	if p.tok.kind == exprEOF {
+		return nil, fmt.Errorf("expression is empty")
+	}
+	root := p.parseSum()
+	if p.err == nil && p.tok.kind != exprEOF {
+		p.fail("unexpected %s", p.tok)
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &metricExpr{Source: strings.TrimSpace(src), root: root, paths: p.paths}, nil
+}
+
+type exprTokenKind int
+
+const (
+	exprEOF exprTokenKind = iota
+	exprNumberToken
+	exprPathToken
+	exprOperator
+)
+
+type exprToken struct {
+	kind exprTokenKind
+	text string
+	pos  int
+}
+
+func (t exprToken) String() string {
+	if t.kind == exprEOF {
+		return "end of expression"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+type exprParser struct {
+	src   string
+	pos   int
+	tok   exprToken
+	err   error
+	paths []string
+}
+
+func (p *exprParser) fail(format string, args ...any) {
+	if p.err == nil {
+		p.err = fmt.Errorf("%s at position %d", fmt.Sprintf(format, args...), p.tok.pos+1)
+	}
+}
+
+func (p *exprParser) next() {
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+	start := p.pos
+	if p.pos >= len(p.src) {
+		p.tok = exprToken{kind: exprEOF, pos: start}
+		return
+	}
+
+	c := p.src[p.pos]
+	switch {
+	case strings.IndexByte("+-*/()", c) >= 0:
+		p.pos++
+		p.tok = exprToken{kind: exprOperator, text: string(c), pos: start}
+	case isExprDigit(c) || c == '.':
+		for p.pos < len(p.src) && (isExprDigit(p.src[p.pos]) || p.src[p.pos] == '.') {
+			p.pos++
+		}
+		// Exponents such as 1e3 or 2.5E-2.
+		if p.pos < len(p.src) && (p.src[p.pos] == 'e' || p.src[p.pos] == 'E') {
+			end := p.pos + 1
+			if end < len(p.src) && (p.src[end] == '+' || p.src[end] == '-') {
+				end++
+			}
+			if end < len(p.src) && isExprDigit(p.src[end]) {
+				for end < len(p.src) && isExprDigit(p.src[end]) {
+					end++
+				}
+				p.pos = end
+			}
+		}
+		p.tok = exprToken{kind: exprNumberToken, text: p.src[start:p.pos], pos: start}
+	case isExprPathStart(c):
+		for p.pos < len(p.src) && isExprPathChar(p.src[p.pos]) {
+			p.pos++
+		}
+		p.tok = exprToken{kind: exprPathToken, text: p.src[start:p.pos], pos: start}
+	default:
+		p.tok = exprToken{kind: exprOperator, text: string(c), pos: start}
+		p.fail("unexpected character %q", c)
+	}
+}
+
+func (p *exprParser) parseSum() exprNode {
+	node := p.parseProduct()
+	for p.err == nil && p.tok.kind == exprOperator && (p.tok.text == "+" || p.tok.text == "-") {
+		op := p.tok.text[0]
+		p.next()
+		node = exprBinary{op: op, left: node, right: p.parseProduct()}
+	}
+	return node
+}
+
+func (p *exprParser) parseProduct() exprNode {
+	node := p.parseUnary()
+	for p.err == nil && p.tok.kind == exprOperator && (p.tok.text == "*" || p.tok.text == "/") {
+		op := p.tok.text[0]
+		p.next()
+		node = exprBinary{op: op, left: node, right: p.parseUnary()}
+	}
+	return node
+}
+
+func (p *exprParser) parseUnary() exprNode {
+	if p.err == nil && p.tok.kind == exprOperator && (p.tok.text == "-" || p.tok.text == "+") {
+		negate := p.tok.text == "-"
+		p.next()
+		operand := p.parseUnary()
+		if negate {
+			return exprNegate{operand: operand}
+		}
+		return operand
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() exprNode {
+	if p.err != nil {
+		return nil
+	}
+	tok := p.tok
+	switch tok.kind {
+	case exprNumberToken:
+		value, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			p.fail("invalid number %q", tok.text)
+			return nil
+		}
+		p.next()
+		return exprNumber(value)
+	case exprPathToken:
+		if err := validateExprPath(tok.text); err != nil {
+			p.fail("%v", err)
+			return nil
+		}
+		p.next()
+		if !containsString(p.paths, tok.text) {
+			p.paths = append(p.paths, tok.text)
+		}
+		return exprPath(tok.text)
+	case exprOperator:
+		if tok.text == "(" {
+			p.next()
+			node := p.parseSum()
+			if p.err == nil && (p.tok.kind != exprOperator || p.tok.text != ")") {
+				p.fail("expected \")\" but found %s", p.tok)
+			}
+			p.next()
+			return node
+		}
+	}
+	p.fail("expected a number, path, or \"(\" but found %s", tok)
+	return nil
+}
+
+func validateExprPath(path string) error {
+	for _, segment := range strings.Split(path, ".") {
+		if segment == "" {
+			return fmt.Errorf("invalid path %q", path)
+		}
+	}
+	return nil
+}
+
+func isExprDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isExprPathStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isExprPathChar(c byte) bool {
+	return isExprPathStart(c) || isExprDigit(c) || c == '.'
+}
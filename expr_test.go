@@ -0,0 +1,104 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseMetricExprEval(t *testing.T) {
+	t.Parallel()
+
+	values := map[string]float64{
+		"status.ok":    30,
+		"status.error": 10,
+		"count":        4,
+		"duration.p95": 2.5,
+		"zero":         0,
+	}
+	tests := []struct {
+		expr  string
+		want  any
+		paths []string
+	}{
+		{expr: "status.error / (status.ok + status.error)", want: 0.25, paths: []string{"status.error", "status.ok"}},
+		{expr: "1 + 2 * 3", want: 7.0},
+		{expr: "(1 + 2) * 3", want: 9.0},
+		{expr: "10 - 4 - 3", want: 3.0},
+		{expr: "100 / 10 / 5", want: 2.0},
+		{expr: "-count + 10", want: 6.0, paths: []string{"count"}},
+		{expr: "--count", want: 4.0, paths: []string{"count"}},
+		{expr: "+count", want: 4.0, paths: []string{"count"}},
+		{expr: "2 * -count", want: -8.0, paths: []string{"count"}},
+		{expr: "count * count", want: 16.0, paths: []string{"count"}},
+		{expr: "duration.p95 * 1e3", want: 2500.0, paths: []string{"duration.p95"}},
+		{expr: "1.5E-1 * 10", want: 1.5},
+		{expr: ".5 + count", want: 4.5, paths: []string{"count"}},
+		{expr: "  ( ( count ) )  ", want: 4.0, paths: []string{"count"}},
+		{expr: "count / zero", want: nil, paths: []string{"count", "zero"}},
+		{expr: "count / (status.ok - 30)", want: nil, paths: []string{"count", "status.ok"}},
+		{expr: "0 / zero + 1", want: nil, paths: []string{"zero"}},
+		{expr: "missing + 1", want: nil, paths: []string{"missing"}},
+		{expr: "count + missing * 0", want: nil, paths: []string{"count", "missing"}},
+		{expr: "status_2xx.v1 - 1", want: nil, paths: []string{"status_2xx.v1"}},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.expr, func(t *testing.T) {
+			t.Parallel()
+
+			expr, err := parseMetricExpr(tt.expr)
+			if err != nil {
+				t.Fatalf("parseMetricExpr(%q) error: %v", tt.expr, err)
+			}
+			if got := expr.Eval(values); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Eval = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(expr.Paths(), tt.paths) {
+				t.Fatalf("Paths = %v, want %v", expr.Paths(), tt.paths)
+			}
+			if expr.Source != strings.TrimSpace(tt.expr) {
+				t.Fatalf("Source = %q, want %q", expr.Source, strings.TrimSpace(tt.expr))
+			}
+		})
+	}
+}
+
+func TestParseMetricExprErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{expr: "", want: "expression is empty"},
+		{expr: "   ", want: "expression is empty"},
+		{expr: "count +", want: "found end of expression at position 8"},
+		{expr: "(count", want: "expected \")\" but found end of expression"},
+		{expr: "count)", want: "unexpected \")\" at position 6"},
+		{expr: "count count", want: "unexpected \"count\" at position 7"},
+		{expr: "count % 2", want: "unexpected character '%' at position 7"},
+		{expr: "()", want: "found \")\" at position 2"},
+		{expr: "* count", want: "found \"*\" at position 1"},
+		{expr: "1..2", want: "invalid number \"1..2\""},
+		{expr: "status..ok", want: "invalid path \"status..ok\""},
+		{expr: "status. + 1", want: "invalid path \"status.\""},
+		{expr: "status.*", want: "invalid path \"status.\" at position 1"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.expr, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := parseMetricExpr(tt.expr)
+			if err == nil {
+				t.Fatalf("parseMetricExpr(%q) succeeded, want error containing %q", tt.expr, tt.want)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("error = %q, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
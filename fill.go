@@ -0,0 +1,191 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	triflestats "github.com/trifle-io/trifle_stats_go"
+)
+
+// fillOptions holds --fill and --fill-edges; an empty Mode turns filling off.
+type fillOptions struct {
+	Mode  string
+	Edges string
+}
+
+// newFillOptions validates --fill (zero|previous|linear) and --fill-edges
+// (none|zero|nearest).
+func newFillOptions(mode, edges string) (fillOptions, error) {
+	opts := fillOptions{
+		Mode:  strings.ToLower(strings.TrimSpace(mode)),
+		Edges: strings.ToLower(strings.TrimSpace(edges)),
+	}
+	switch opts.Mode {
+	case "", "zero", "previous", "linear":
+	default:
+		return fillOptions{}, usageErrorf("unsupported fill %q (use zero, previous, or linear)", mode)
+	}
+	switch opts.Edges {
+	case "":
+		opts.Edges = "none"
+	case "none", "zero", "nearest":
+	default:
+		return fillOptions{}, usageErrorf("unsupported fill edges %q (use none, zero, or nearest)", edges)
+	}
+	if opts.Mode == "" && opts.Edges != "none" {
+		return fillOptions{}, usageErrorf("--fill-edges requires --fill")
+	}
+	return opts, nil
+}
+
+func (o fillOptions) enabled() bool {
+	return o.Mode != ""
+}
+
+// fillValues fills the nil buckets of one path. Gaps between two numbers
+// are filled by mode; linear interpolates on bucket time. Leading and
+// trailing gaps follow Edges: none leaves them nil, zero writes 0, and
+// nearest repeats the closest number. Non-numeric values pass through and
+// never anchor a fill.
+func fillValues(opts fillOptions, at []time.Time, values []any) []any {
+	out := make([]any, len(values))
+	copy(out, values)
+
+	first, last := -1, -1
+	for i, value := range values {
+		if _, ok := triflestats.NormalizeNumeric(value).(float64); ok {
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+	}
+
+	edge := func(anchor int) any {
+		switch opts.Edges {
+		case "zero":
+			return float64(0)
+		case "nearest":
+			if anchor >= 0 {
+				return triflestats.NormalizeNumeric(values[anchor])
+			}
+		}
+		return nil
+	}
+
+	previous := -1
+	for i, value := range values {
+		if _, ok := triflestats.NormalizeNumeric(value).(float64); ok {
+			previous = i
+			continue
+		}
+		if value != nil {
+			continue
+		}
+		switch {
+		case first < 0 || i < first:
+			out[i] = edge(first)
+		case i > last:
+			out[i] = edge(last)
+		default:
+			out[i] = fillGap(opts.Mode, at, values, previous, i)
+		}
+	}
+	return out
+}
+
+// fillGap fills bucket i, which sits after the number at previous and
+// before at least one more number.
+func fillGap(mode string, at []time.Time, values []any, previous, i int) any {
+	switch mode {
+	case "zero":
+		return float64(0)
+	case "previous":
+		return triflestats.NormalizeNumeric(values[previous])
+	}
+
+	next := i + 1
+	for ; next < len(values); next++ {
+		if _, ok := triflestats.NormalizeNumeric(values[next]).(float64); ok {
+			break
+		}
+	}
+	from := triflestats.NormalizeNumeric(values[previous]).(float64)
+	to := triflestats.NormalizeNumeric(values[next]).(float64)
+
+	position := float64(i-previous) / float64(next-previous)
+	if next < len(at) {
+		if span := at[next].Sub(at[previous]); span > 0 {
+			position = float64(at[i].Sub(at[previous])) / float64(span)
+		}
+	}
+	return from + (to-from)*position
+}
+
+// fillSeries fills the given paths and keeps every other value as it was.
+func fillSeries(series triflestats.Series, paths []string, opts fillOptions) triflestats.Series {
+	if !opts.enabled() {
+		return series
+	}
+	packed := make([]map[string]any, len(series.At))
+	for i := range packed {
+		if i < len(series.Values) && series.Values[i] != nil {
+			packed[i] = triflestats.Pack(series.Values[i])
+		} else {
+			packed[i] = map[string]any{}
+		}
+	}
+
+	for _, path := range paths {
+		values := make([]any, len(packed))
+		for i, row := range packed {
+			values[i] = row[path]
+		}
+		for i, value := range fillValues(opts, series.At, values) {
+			if value != nil {
+				packed[i][path] = value
+			}
+		}
+	}
+
+	rows := make([]map[string]any, len(packed))
+	for i, row := range packed {
+		rows[i] = triflestats.Unpack(row)
+	}
+	return triflestats.Series{At: series.At, Values: rows}
+}
+
+// fillResult fills every value path found in a raw series.
+func fillResult(result triflestats.ValuesResult, opts fillOptions) triflestats.ValuesResult {
+	if !opts.enabled() {
+		return result
+	}
+	series := triflestats.SeriesFromResult(result)
+	filled := fillSeries(series, packedPaths(result.Values), opts)
+	return triflestats.ValuesResult{At: filled.At, Values: filled.Values}
+}
+
+func packedPaths(values []map[string]any) []string {
+	var paths []string
+	for _, row := range values {
+		for path := range triflestats.Pack(row) {
+			paths = append(paths, path)
+		}
+	}
+	return uniqueStrings(paths)
+}
+
+// fillQueryData fills the table columns and result points of a timeline
+// response from the API, in place.
+func fillQueryData(data map[string]any, opts fillOptions) error {
+	if !opts.enabled() {
+		return nil
+	}
+	fill := func(at []time.Time, values []any) []any {
+		return fillValues(opts, at, values)
+	}
+	if _, err := mapTableColumns(data["table"], fill); err != nil {
+		return err
+	}
+	return mapResultPoints(data["result"], fill)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	triflestats "github.com/trifle-io/trifle_stats_go"
+)
+
+func TestFillValues(t *testing.T) {
+	t.Parallel()
+
+	at := hourlyBuckets(6)
+	values := []any{nil, 2, nil, nil, 8, nil}
+
+	tests := []struct {
+		name  string
+		mode  string
+		edges string
+		want  []any
+	}{
+		{name: "zero", mode: "zero", want: []any{nil, 2, 0.0, 0.0, 8, nil}},
+		{name: "previous", mode: "previous", want: []any{nil, 2, 2.0, 2.0, 8, nil}},
+		{name: "linear", mode: "linear", want: []any{nil, 2, 4.0, 6.0, 8, nil}},
+		{name: "zero edges", mode: "linear", edges: "zero", want: []any{0.0, 2, 4.0, 6.0, 8, 0.0}},
+		{name: "nearest edges", mode: "previous", edges: "nearest", want: []any{2.0, 2, 2.0, 2.0, 8, 8.0}},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			opts, err := newFillOptions(tt.mode, tt.edges)
+			if err != nil {
+				t.Fatalf("newFillOptions returned error: %v", err)
+			}
+			if got := fillValues(opts, at, values); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("fillValues = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFillValuesLinearUsesBucketTime(t *testing.T) {
+	t.Parallel()
+
+	start := hourlyBuckets(1)[0]
+	at := []time.Time{start, start.Add(time.Hour), start.Add(4 * time.Hour)}
+	got := fillValues(fillOptions{Mode: "linear", Edges: "none"}, at, []any{0, nil, 8})
+	if want := []any{0, 2.0, 8}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("fillValues = %v, want %v", got, want)
+	}
+}
+
+func TestFillValuesWithoutNumbers(t *testing.T) {
+	t.Parallel()
+
+	at := hourlyBuckets(2)
+	if got := fillValues(fillOptions{Mode: "linear", Edges: "nearest"}, at, []any{nil, nil}); !reflect.DeepEqual(got, []any{nil, nil}) {
+		t.Fatalf("fillValues = %v, want nils", got)
+	}
+	if got := fillValues(fillOptions{Mode: "zero", Edges: "zero"}, at, []any{nil, nil}); !reflect.DeepEqual(got, []any{0.0, 0.0}) {
+		t.Fatalf("fillValues = %v, want zeros", got)
+	}
+}
+
+func TestNewFillOptionsErrors(t *testing.T) {
+	t.Parallel()
+
+	for _, args := range [][2]string{{"spline", ""}, {"zero", "wrap"}, {"", "zero"}} {
+		if _, err := newFillOptions(args[0], args[1]); err == nil {
+			t.Fatalf("newFillOptions(%q, %q) should fail", args[0], args[1])
+		}
+	}
+}
+
+func TestFillResultKeepsOtherValues(t *testing.T) {
+	t.Parallel()
+
+	result := triflestats.ValuesResult{
+		At: hourlyBuckets(3),
+		Values: []map[string]any{
+			{"count": 1, "status": "ok", "duration": map[string]any{"sum": 4}},
+			{},
+			{"count": 3, "duration": map[string]any{"sum": 8}},
+		},
+	}
+	filled := fillResult(result, fillOptions{Mode: "linear", Edges: "none"})
+	want := map[string]any{"count": 2.0, "duration": map[string]any{"sum": 6.0}}
+	if !reflect.DeepEqual(filled.Values[1], want) {
+		t.Fatalf("filled bucket = %v, want %v", filled.Values[1], want)
+	}
+	if filled.Values[0]["status"] != "ok" {
+		t.Fatalf("first bucket = %v, want status kept", filled.Values[0])
+	}
+	if len(result.Values[1]) != 0 {
+		t.Fatalf("fillResult modified its input: %v", result.Values[1])
+	}
+}
+
+func TestFillQueryData(t *testing.T) {
+	t.Parallel()
+
+	data := map[string]any{
+		"table": map[string]any{
+			"columns": []any{"at", "count"},
+			"rows": []any{
+				[]any{"2026-10-15T09:00:00Z", 1.0},
+				[]any{"2026-10-15T10:00:00Z", nil},
+				[]any{"2026-10-15T11:00:00Z", 5.0},
+			},
+		},
+		"result": map[string]any{
+			"count": []any{
+				map[string]any{"at": "2026-10-15T09:00:00Z", "value": 1.0},
+				map[string]any{"at": "2026-10-15T10:00:00Z", "value": nil},
+				map[string]any{"at": "2026-10-15T11:00:00Z", "value": 5.0},
+			},
+		},
+	}
+	if err := fillQueryData(data, fillOptions{Mode: "linear", Edges: "none"}); err != nil {
+		t.Fatalf("fillQueryData returned error: %v", err)
+	}
+	row := data["table"].(map[string]any)["rows"].([]any)[1].([]any)
+	if row[1] != 3.0 {
+		t.Fatalf("table row = %v, want 3", row)
+	}
+	point := data["result"].(map[string]any)["count"].([]any)[1].(map[string]any)
+	if point["value"] != 3.0 {
+		t.Fatalf("result point = %v, want 3", point)
+	}
+}
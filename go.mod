@@ -6,6 +6,7 @@ require (
 	github.com/redis/go-redis/v9 v9.17.3
 	github.com/trifle-io/trifle_stats_go v0.0.0-20260225110154-f997cca4e444
 	go.mongodb.org/mongo-driver v1.17.9
+	golang.org/x/sys v0.35.0
 	gopkg.in/yaml.v3 v3.0.1
 )
 
@@ -35,7 +36,6 @@ require (
 	golang.org/x/crypto v0.26.0 // indirect
 	golang.org/x/mod v0.27.0 // indirect
 	golang.org/x/sync v0.17.0 // indirect
-	golang.org/x/sys v0.35.0 // indirect
 	golang.org/x/text v0.29.0 // indirect
 	golang.org/x/tools v0.36.0 // indirect
 	lukechampine.com/uint128 v1.2.0 // indirect
@@ -12,6 +12,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -26,6 +27,16 @@ type Client struct {
 	token   string
 	host    string
 	http    *http.Client
+
+	statsMu sync.Mutex
+	stats   Stats
+}
+
+// Stats counts the requests a client has sent.
+type Stats struct {
+	Requests   int
+	Retries    int
+	LastStatus int
 }
 
 type Error struct {
@@ -69,6 +80,20 @@ func (c *Client) SetToken(token string) {
 	c.token = token
 }
 
+func (c *Client) Stats() Stats {
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+	return c.stats
+}
+
+// RecordRetry notes that the caller is re-sending a request the server
+// rejected.
+func (c *Client) RecordRetry() {
+	c.statsMu.Lock()
+	c.stats.Retries++
+	c.statsMu.Unlock()
+}
+
 func (c *Client) GetMetrics(ctx context.Context, params map[string]string, out any) error {
 	return c.doJSON(ctx, http.MethodGet, apiBasePath+"/metrics", params, out)
 }
@@ -85,8 +110,20 @@ func (c *Client) GetSource(ctx context.Context, out any) error {
 	return c.doJSON(ctx, http.MethodGet, apiBasePath+"/source", nil, out)
 }
 
-func (c *Client) GetTransponders(ctx context.Context, out any) error {
-	return c.doJSON(ctx, http.MethodGet, apiBasePath+"/transponders", nil, out)
+func (c *Client) GetTransponders(ctx context.Context, params map[string]string, out any) error {
+	return c.doJSON(ctx, http.MethodGet, apiBasePath+"/transponders", params, out)
+}
+
+func (c *Client) GetTransponder(ctx context.Context, id string, out any) error {
+	return c.doJSON(ctx, http.MethodGet, apiBasePath+"/transponders/"+id, nil, out)
+}
+
+func (c *Client) GetTransponderDeliveries(ctx context.Context, id string, params map[string]string, out any) error {
+	return c.doJSON(ctx, http.MethodGet, apiBasePath+"/transponders/"+id+"/deliveries", params, out)
+}
+
+func (c *Client) TestTransponder(ctx context.Context, id string, out any) error {
+	return c.doJSON(ctx, http.MethodPost, apiBasePath+"/transponders/"+id+"/test", nil, out)
 }
 
 func (c *Client) CreateTransponder(ctx context.Context, payload any, out any) error {
@@ -202,24 +239,60 @@ func (c *Client) BootstrapCreateSourceToken(ctx context.Context, payload any, ou
 	return c.doJSON(ctx, http.MethodPost, apiBasePath+"/bootstrap/source-tokens", payload, out)
 }
 
-func (c *Client) doJSON(ctx context.Context, method, path string, payload any, out any) error {
-	fullURL := c.baseURL + path
+// Request describes an API call without sending it.
+type Request struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	Body   any    `json:"body,omitempty"`
+}
 
-	var body io.Reader
-	if method == http.MethodGet {
-		if params, ok := payload.(map[string]string); ok && len(params) > 0 {
-			query := url.Values{}
-			for key, value := range params {
-				if value == "" {
-					continue
-				}
-				query.Set(key, value)
-			}
-			if encoded := query.Encode(); encoded != "" {
-				fullURL += "?" + encoded
+func (c *Client) DescribeGetMetrics(params map[string]string) Request {
+	return c.describe(http.MethodGet, apiBasePath+"/metrics", params)
+}
+
+func (c *Client) DescribePostMetrics(payload any) Request {
+	return c.describe(http.MethodPost, apiBasePath+"/metrics", payload)
+}
+
+func (c *Client) DescribeQueryMetrics(payload any) Request {
+	return c.describe(http.MethodPost, apiBasePath+"/metrics/query", payload)
+}
+
+func (c *Client) describe(method, path string, payload any) Request {
+	req := Request{Method: method, URL: c.requestURL(method, path, payload)}
+	if method != http.MethodGet {
+		req.Body = payload
+	}
+	return req
+}
+
+// requestURL builds the full URL for a call; GET params become the query
+// string, skipping empty values.
+func (c *Client) requestURL(method, path string, payload any) string {
+	fullURL := c.baseURL + path
+	if method != http.MethodGet {
+		return fullURL
+	}
+	if params, ok := payload.(map[string]string); ok && len(params) > 0 {
+		query := url.Values{}
+		for key, value := range params {
+			if value == "" {
+				continue
 			}
+			query.Set(key, value)
+		}
+		if encoded := query.Encode(); encoded != "" {
+			fullURL += "?" + encoded
 		}
-	} else if payload != nil {
+	}
+	return fullURL
+}
+
+func (c *Client) doJSON(ctx context.Context, method, path string, payload any, out any) error {
+	fullURL := c.requestURL(method, path, payload)
+
+	var body io.Reader
+	if method != http.MethodGet && payload != nil {
 		encoded, err := json.Marshal(payload)
 		if err != nil {
 			return fmt.Errorf("encode payload: %w", err)
@@ -248,6 +321,12 @@ func (c *Client) doJSON(ctx context.Context, method, path string, payload any, o
 
 func (c *Client) doRequest(req *http.Request, out any) error {
 	resp, err := c.http.Do(req)
+	c.statsMu.Lock()
+	c.stats.Requests++
+	if resp != nil {
+		c.stats.LastStatus = resp.StatusCode
+	}
+	c.statsMu.Unlock()
 	if err != nil {
 		return fmt.Errorf("request failed: %w", err)
 	}
@@ -0,0 +1,265 @@
+package output
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrEmptyChart is returned when a table has no numeric values to draw.
+var ErrEmptyChart = errors.New("no numeric values to chart")
+
+// ChartOptions sizes and labels an SVG chart.
+type ChartOptions struct {
+	Width    int
+	Height   int
+	Title    string
+	Subtitle string
+	// DisplayLoc labels the time axis in this location when set.
+	DisplayLoc *time.Location
+}
+
+var chartColors = []string{"#2563eb", "#dc2626", "#16a34a", "#d97706", "#7c3aed", "#0891b2", "#db2777", "#4b5563"}
+
+const (
+	chartMarginLeft   = 64
+	chartMarginRight  = 24
+	chartMarginTop    = 56
+	chartMarginBottom = 40
+	chartLegendRow    = 18
+	chartMinPlot      = 40
+)
+
+type chartSeries struct {
+	Name   string
+	Values []*float64
+}
+
+// WriteSVGChart draws one line per value column of table against its "at"
+// column, with a value axis, time labels, and a legend. Blank cells break a
+// line. It returns ErrEmptyChart when no column holds a number.
+func WriteSVGChart(w io.Writer, table Table, opts ChartOptions) error {
+	atIndex := -1
+	var series []chartSeries
+	for i, column := range table.Columns {
+		if column == "at" {
+			atIndex = i
+			continue
+		}
+		values := make([]*float64, len(table.Rows))
+		seen := false
+		for r, row := range table.Rows {
+			if i >= len(row) {
+				continue
+			}
+			if parsed, err := strconv.ParseFloat(strings.TrimSpace(row[i]), 64); err == nil && !math.IsNaN(parsed) && !math.IsInf(parsed, 0) {
+				values[r] = &parsed
+				seen = true
+			}
+		}
+		if seen {
+			series = append(series, chartSeries{Name: column, Values: values})
+		}
+	}
+	if len(series) == 0 {
+		return ErrEmptyChart
+	}
+
+	plotLeft := float64(chartMarginLeft)
+	plotTop := float64(chartMarginTop)
+	plotWidth := float64(opts.Width - chartMarginLeft - chartMarginRight)
+	plotHeight := float64(opts.Height - chartMarginTop - chartMarginBottom - chartLegendRow*len(series))
+	if plotWidth < chartMinPlot || plotHeight < chartMinPlot {
+		return fmt.Errorf("chart is too small for %d lines (%dx%d)", len(series), opts.Width, opts.Height)
+	}
+	plotBottom := plotTop + plotHeight
+
+	times := chartTimes(table, atIndex)
+	x := func(r int) float64 {
+		if len(table.Rows) < 2 {
+			return plotLeft + plotWidth/2
+		}
+		if times != nil && times[len(times)-1].After(times[0]) {
+			span := times[len(times)-1].Sub(times[0])
+			return plotLeft + plotWidth*float64(times[r].Sub(times[0]))/float64(span)
+		}
+		return plotLeft + plotWidth*float64(r)/float64(len(table.Rows)-1)
+	}
+
+	ticks := chartTicks(series)
+	low, high := ticks[0], ticks[len(ticks)-1]
+	y := func(value float64) float64 {
+		return plotBottom - plotHeight*(value-low)/(high-low)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="11">`+"\n", opts.Width, opts.Height, opts.Width, opts.Height)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="#ffffff"/>`+"\n", opts.Width, opts.Height)
+	if opts.Title != "" {
+		fmt.Fprintf(&b, `<text x="%d" y="22" font-size="15" font-weight="bold" fill="#111827">%s</text>`+"\n", chartMarginLeft, html.EscapeString(opts.Title))
+	}
+	if opts.Subtitle != "" {
+		fmt.Fprintf(&b, `<text x="%d" y="40" fill="#6b7280">%s</text>`+"\n", chartMarginLeft, html.EscapeString(opts.Subtitle))
+	}
+
+	for _, tick := range ticks {
+		ty := y(tick)
+		fmt.Fprintf(&b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#e5e7eb"/>`+"\n", plotLeft, ty, plotLeft+plotWidth, ty)
+		fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" text-anchor="end" fill="#374151">%s</text>`+"\n", plotLeft-6, ty+4, formatFloat(tick))
+	}
+	fmt.Fprintf(&b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#9ca3af"/>`+"\n", plotLeft, plotBottom, plotLeft+plotWidth, plotBottom)
+
+	for _, r := range chartLabelRows(len(table.Rows)) {
+		label := ""
+		if times != nil {
+			label = chartTimeLabel(times, r, opts.DisplayLoc)
+		} else if atIndex >= 0 && atIndex < len(table.Rows[r]) {
+			label = table.Rows[r][atIndex]
+		} else {
+			label = strconv.Itoa(r + 1)
+		}
+		anchor := "middle"
+		if len(table.Rows) > 1 && r == 0 {
+			anchor = "start"
+		} else if len(table.Rows) > 1 && r == len(table.Rows)-1 {
+			anchor = "end"
+		}
+		fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" text-anchor="%s" fill="#374151">%s</text>`+"\n", x(r), plotBottom+18, anchor, html.EscapeString(label))
+	}
+
+	for i, s := range series {
+		color := chartColors[i%len(chartColors)]
+		var run []string
+		flush := func() {
+			switch len(run) {
+			case 0:
+			case 1:
+				xy := strings.Split(run[0], ",")
+				fmt.Fprintf(&b, `<circle cx="%s" cy="%s" r="2.5" fill="%s"/>`+"\n", xy[0], xy[1], color)
+			default:
+				fmt.Fprintf(&b, `<polyline fill="none" stroke="%s" stroke-width="2" points="%s"/>`+"\n", color, strings.Join(run, " "))
+			}
+			run = run[:0]
+		}
+		for r, value := range s.Values {
+			if value == nil {
+				flush()
+				continue
+			}
+			run = append(run, fmt.Sprintf("%.1f,%.1f", x(r), y(*value)))
+		}
+		flush()
+
+		ly := plotBottom + float64(chartMarginBottom) + float64(chartLegendRow*i)
+		fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="12" height="3" fill="%s"/>`+"\n", plotLeft, ly-4, color)
+		fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" fill="#111827">%s</text>`+"\n", plotLeft+18, ly, html.EscapeString(s.Name))
+	}
+	b.WriteString("</svg>\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// chartTimes parses the "at" column, or returns nil when any cell is not
+// an RFC3339 time.
+func chartTimes(table Table, atIndex int) []time.Time {
+	if atIndex < 0 || len(table.Rows) == 0 {
+		return nil
+	}
+	times := make([]time.Time, len(table.Rows))
+	for r, row := range table.Rows {
+		if atIndex >= len(row) {
+			return nil
+		}
+		parsed, err := time.Parse(time.RFC3339Nano, row[atIndex])
+		if err != nil {
+			return nil
+		}
+		times[r] = parsed
+	}
+	return times
+}
+
+// chartTicks returns evenly spaced value-axis ticks covering every value,
+// at a step of 1, 2, or 5 times a power of ten.
+func chartTicks(series []chartSeries) []float64 {
+	low, high := math.Inf(1), math.Inf(-1)
+	for _, s := range series {
+		for _, value := range s.Values {
+			if value != nil {
+				low = math.Min(low, *value)
+				high = math.Max(high, *value)
+			}
+		}
+	}
+	if low == high {
+		pad := math.Max(math.Abs(low)*0.1, 1)
+		low, high = low-pad, high+pad
+	}
+
+	raw := (high - low) / 4
+	magnitude := math.Pow(10, math.Floor(math.Log10(raw)))
+	factor := 10.0
+	for _, candidate := range []float64{1, 2, 5} {
+		if raw <= candidate*magnitude {
+			factor = candidate
+			break
+		}
+	}
+	step := factor * magnitude
+	// Dividing by a whole power of ten keeps labels like 0.15 exact, where
+	// multiplying by 0.05 drifts to 0.15000000000000002.
+	tick := func(n float64) float64 {
+		if magnitude < 1 {
+			return n * factor / math.Round(1/magnitude)
+		}
+		return n * step
+	}
+
+	first := math.Floor(low / step)
+	last := math.Ceil(high / step)
+	ticks := make([]float64, 0, int(last-first)+1)
+	for n := first; n <= last; n++ {
+		ticks = append(ticks, tick(n))
+	}
+	return ticks
+}
+
+// chartLabelRows picks up to five evenly spaced rows to label on the time
+// axis, always including the first and last.
+func chartLabelRows(n int) []int {
+	if n <= 0 {
+		return nil
+	}
+	labels := min(n, 5)
+	if labels == 1 {
+		return []int{0}
+	}
+	rows := make([]int, labels)
+	for i := range rows {
+		rows[i] = int(math.Round(float64(i) * float64(n-1) / float64(labels-1)))
+	}
+	return rows
+}
+
+// chartTimeLabel formats times[r] with only the precision the series needs:
+// dates when every point falls on midnight, minutes otherwise.
+func chartTimeLabel(times []time.Time, r int, loc *time.Location) string {
+	if loc == nil {
+		loc = time.UTC
+	}
+	layout := "2006-01-02"
+	for _, at := range times {
+		local := at.In(loc)
+		if local.Hour() != 0 || local.Minute() != 0 {
+			layout = "2006-01-02 15:04"
+			break
+		}
+	}
+	return times[r].In(loc).Format(layout)
+}
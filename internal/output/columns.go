@@ -0,0 +1,43 @@
+package output
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SelectColumns keeps the named columns of table in the given order. Unknown
+// names are an error listing the columns that exist.
+func SelectColumns(table Table, names []string) (Table, error) {
+	index := make(map[string]int, len(table.Columns))
+	for i, column := range table.Columns {
+		index[column] = i
+	}
+
+	picked := make([]int, 0, len(names))
+	for _, name := range names {
+		i, ok := index[name]
+		if !ok {
+			return Table{}, fmt.Errorf("unknown column %q (available: %s)", name, strings.Join(table.Columns, ", "))
+		}
+		picked = append(picked, i)
+	}
+
+	columns := make([]string, len(picked))
+	for j, i := range picked {
+		columns[j] = table.Columns[i]
+	}
+	pick := func(source [][]string) [][]string {
+		if source == nil {
+			return nil
+		}
+		rows := make([][]string, len(source))
+		for r, row := range source {
+			rows[r] = make([]string, len(picked))
+			for j, i := range picked {
+				rows[r][j] = cellAt(row, i)
+			}
+		}
+		return rows
+	}
+	return Table{Columns: columns, Rows: pick(table.Rows), Footer: pick(table.Footer)}, nil
+}
@@ -0,0 +1,63 @@
+package output
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// File buffers writes in a temp file next to the target path and renames it
+// into place on Commit, so readers never observe a partially written result.
+type File struct {
+	path string
+	tmp  *os.File
+}
+
+// CreateFile prepares an atomic write to path, creating parent directories.
+func CreateFile(path string) (*File, error) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return nil, err
+	}
+	return &File{path: path, tmp: tmp}, nil
+}
+
+func (f *File) Write(p []byte) (int, error) {
+	return f.tmp.Write(p)
+}
+
+// Commit flushes the temp file to disk and renames it over the target path.
+func (f *File) Commit() error {
+	return f.CommitAs(f.path)
+}
+
+// CommitAs is Commit to another path, such as one that marks the output as
+// incomplete.
+func (f *File) CommitAs(path string) error {
+	if err := f.tmp.Sync(); err != nil {
+		f.Abort()
+		return err
+	}
+	if err := f.tmp.Chmod(0o644); err != nil {
+		f.Abort()
+		return err
+	}
+	if err := f.tmp.Close(); err != nil {
+		os.Remove(f.tmp.Name())
+		return err
+	}
+	if err := os.Rename(f.tmp.Name(), path); err != nil {
+		os.Remove(f.tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// Abort discards the temp file, leaving any existing target untouched.
+func (f *File) Abort() {
+	f.tmp.Close()
+	os.Remove(f.tmp.Name())
+}
@@ -0,0 +1,60 @@
+package output
+
+import "fmt"
+
+// LimitRows keeps the first limit rows of table, or the last tail rows when
+// tail is positive. Zero or negative values keep everything. It returns the
+// number of rows dropped.
+func LimitRows(table Table, limit, tail int) (Table, int) {
+	total := len(table.Rows)
+	switch {
+	case tail > 0 && total > tail:
+		table.Rows = table.Rows[total-tail:]
+		return table, total - tail
+	case tail <= 0 && limit > 0 && total > limit:
+		table.Rows = table.Rows[:limit]
+		return table, total - limit
+	default:
+		return table, 0
+	}
+}
+
+// LimitPayloadTable caps the rows of payload["table"] like LimitRows and
+// records the original count as "total_rows" when rows were dropped. The
+// table map is replaced rather than edited in place.
+func LimitPayloadTable(payload map[string]any, limit, tail int) int {
+	raw, ok := payload["table"].(map[string]any)
+	if !ok {
+		return 0
+	}
+	rows, ok := raw["rows"].([]any)
+	if !ok {
+		return 0
+	}
+
+	total := len(rows)
+	switch {
+	case tail > 0 && total > tail:
+		rows = rows[total-tail:]
+	case tail <= 0 && limit > 0 && total > limit:
+		rows = rows[:limit]
+	default:
+		return 0
+	}
+
+	table := make(map[string]any, len(raw)+1)
+	for key, value := range raw {
+		table[key] = value
+	}
+	table["rows"] = rows
+	table["total_rows"] = total
+	payload["table"] = table
+	return total - len(rows)
+}
+
+func limitNote(hidden int, opts TableOptions) string {
+	if opts.Tail > 0 {
+		return fmt.Sprintf("… %d earlier rows (use --tail 0 for all)", hidden)
+	}
+	return fmt.Sprintf("… %d more rows (use --limit 0 for all)", hidden)
+}
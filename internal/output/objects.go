@@ -0,0 +1,56 @@
+package output
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// TableFromObjects lays out a list of objects with one column per key. Keys
+// from preferred come first, in that order, when any object has them; the
+// rest follow sorted. Nested objects and lists are JSON-encoded into their
+// cell and missing keys are left blank.
+func TableFromObjects(objects []map[string]any, preferred []string) Table {
+	seen := map[string]struct{}{}
+	for _, object := range objects {
+		for key := range object {
+			seen[key] = struct{}{}
+		}
+	}
+
+	columns := make([]string, 0, len(seen))
+	for _, key := range preferred {
+		if _, ok := seen[key]; ok {
+			columns = append(columns, key)
+			delete(seen, key)
+		}
+	}
+	rest := make([]string, 0, len(seen))
+	for key := range seen {
+		rest = append(rest, key)
+	}
+	sort.Strings(rest)
+	columns = append(columns, rest...)
+
+	rows := make([][]string, 0, len(objects))
+	for _, object := range objects {
+		row := make([]string, len(columns))
+		for i, column := range columns {
+			row[i] = objectCell(object[column])
+		}
+		rows = append(rows, row)
+	}
+	return Table{Columns: columns, Rows: rows}
+}
+
+func objectCell(value any) string {
+	switch value.(type) {
+	case map[string]any, []any:
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return formatCell(value, TableOptions{Precision: -1})
+		}
+		return string(encoded)
+	default:
+		return formatCell(value, TableOptions{Precision: -1})
+	}
+}
@@ -1,18 +1,47 @@
 package output
 
 import (
+	"bufio"
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 )
 
 type Table struct {
 	Columns []string
 	Rows    [][]string
+	// Footer holds summary rows kept below the body: PrintTable sets them
+	// off with a separator, row limits never drop them, and the other
+	// formats print them as plain rows.
+	Footer [][]string
+}
+
+// TableOptions controls how payload tables are rendered. JSON output ignores
+// them.
+type TableOptions struct {
+	// DisplayLoc shows the "at" column in this location when set.
+	DisplayLoc *time.Location
+	// Precision rounds fractional numbers to this many decimals; negative
+	// keeps full precision. Integral values never gain a decimal point.
+	Precision int
+	// Transpose swaps rows and columns of table, csv, and markdown output.
+	// Transposed tables are trimmed to the terminal width.
+	Transpose bool
+	// Limit keeps only the first Limit rows; Tail keeps only the last Tail
+	// rows and wins over Limit. Zero keeps every row.
+	Limit int
+	Tail  int
+	// Columns keeps only these columns, in this order, when set.
+	Columns []string
+	// CSV is passed through to PrintCSV.
+	CSV CSVOptions
 }
 
 func PrintJSON(w io.Writer, value any) error {
@@ -25,60 +54,187 @@ func PrintJSON(w io.Writer, value any) error {
 	return err
 }
 
-func PrintTable(w io.Writer, table Table) {
-	if len(table.Columns) == 0 {
-		return
+// PrintCompactJSON writes value as a single line of JSON.
+func PrintCompactJSON(w io.Writer, value any) error {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return err
 	}
 
-	widths := make([]int, len(table.Columns))
-	for i, col := range table.Columns {
-		widths[i] = len(col)
+	_, err = w.Write(append(encoded, '\n'))
+	return err
+}
+
+// NDJSONWriter writes one JSON document per line. With flushEach set every
+// line is flushed as soon as it is written.
+type NDJSONWriter struct {
+	buf       *bufio.Writer
+	flushEach bool
+}
+
+func NewNDJSONWriter(w io.Writer, flushEach bool) *NDJSONWriter {
+	return &NDJSONWriter{buf: bufio.NewWriter(w), flushEach: flushEach}
+}
+
+func (n *NDJSONWriter) Write(value any) error {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return err
 	}
+	if _, err := n.buf.Write(append(encoded, '\n')); err != nil {
+		return err
+	}
+	if n.flushEach {
+		return n.buf.Flush()
+	}
+	return nil
+}
 
-	for _, row := range table.Rows {
-		for i, cell := range row {
-			if i >= len(widths) {
-				break
-			}
-			if len(cell) > widths[i] {
-				widths[i] = len(cell)
-			}
-		}
+func (n *NDJSONWriter) Flush() error {
+	return n.buf.Flush()
+}
+
+// PrintTable writes the table as padded columns. Columns whose cells are all
+// numbers are right-aligned so digits line up; headers stay left-aligned.
+func PrintTable(w io.Writer, table Table) {
+	if len(table.Columns) == 0 {
+		return
 	}
 
-	writeRow := func(values []string) {
+	widths := columnWidths(table)
+	numeric := numericColumns(table)
+
+	writeRow := func(values []string, alignNumbers bool) {
 		for i, value := range values {
 			if i > 0 {
 				fmt.Fprint(w, "  ")
 			}
-			fmt.Fprint(w, padRight(value, widths[i]))
+			if alignNumbers && numeric[i] {
+				fmt.Fprint(w, padLeft(value, widths[i]))
+			} else {
+				fmt.Fprint(w, padRight(value, widths[i]))
+			}
 		}
 		fmt.Fprint(w, "\n")
 	}
 
-	writeRow(table.Columns)
+	writeRow(table.Columns, false)
 	separators := make([]string, len(table.Columns))
 	for i, width := range widths {
 		separators[i] = strings.Repeat("-", width)
 	}
-	writeRow(separators)
+	writeRow(separators, false)
 
 	for _, row := range table.Rows {
 		normalized := make([]string, len(table.Columns))
 		copy(normalized, row)
-		writeRow(normalized)
+		writeRow(normalized, true)
+	}
+	if len(table.Footer) > 0 {
+		writeRow(separators, false)
+		for _, row := range table.Footer {
+			normalized := make([]string, len(table.Columns))
+			copy(normalized, row)
+			writeRow(normalized, true)
+		}
+	}
+}
+
+// numericColumns reports the columns whose non-blank cells all parse as
+// numbers. Columns with no values are not numeric.
+func numericColumns(table Table) []bool {
+	numeric := make([]bool, len(table.Columns))
+	for i := range table.Columns {
+		seen := false
+		numeric[i] = true
+		for _, row := range table.Rows {
+			cell := strings.TrimSpace(cellAt(row, i))
+			if cell == "" {
+				continue
+			}
+			if _, err := strconv.ParseFloat(cell, 64); err != nil {
+				numeric[i] = false
+				break
+			}
+			seen = true
+		}
+		numeric[i] = numeric[i] && seen
+	}
+	return numeric
+}
+
+// PrintMarkdown writes the table as a GitHub-flavored markdown table. Pipes
+// and line breaks in cells are escaped so each row stays on one line.
+func PrintMarkdown(w io.Writer, table Table) error {
+	if len(table.Columns) == 0 {
+		return nil
+	}
+
+	escape := strings.NewReplacer("|", "\\|", "\r\n", "<br>", "\n", "<br>")
+	header := make([]string, len(table.Columns))
+	widths := make([]int, len(table.Columns))
+	for i, col := range table.Columns {
+		header[i] = escape.Replace(col)
+		widths[i] = max(displayWidth(header[i]), 3)
+	}
+	body := append(append([][]string(nil), table.Rows...), table.Footer...)
+	rows := make([][]string, len(body))
+	for r, row := range body {
+		rows[r] = make([]string, len(table.Columns))
+		for i := range table.Columns {
+			if i < len(row) {
+				rows[r][i] = escape.Replace(row[i])
+			}
+			widths[i] = max(widths[i], displayWidth(rows[r][i]))
+		}
+	}
+
+	writeRow := func(values []string) error {
+		cells := make([]string, len(values))
+		for i, value := range values {
+			cells[i] = padRight(value, widths[i])
+		}
+		_, err := fmt.Fprintf(w, "| %s |\n", strings.Join(cells, " | "))
+		return err
 	}
+
+	if err := writeRow(header); err != nil {
+		return err
+	}
+	separators := make([]string, len(widths))
+	for i, width := range widths {
+		separators[i] = strings.Repeat("-", width)
+	}
+	if err := writeRow(separators); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if err := writeRow(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CSVOptions controls CSV output. A zero Delimiter means a comma.
+type CSVOptions struct {
+	Delimiter rune
 }
 
-func PrintCSV(w io.Writer, table Table) error {
+func PrintCSV(w io.Writer, table Table, opts CSVOptions) error {
 	writer := csv.NewWriter(w)
+	if opts.Delimiter != 0 {
+		writer.Comma = opts.Delimiter
+	}
 	if err := writer.Write(table.Columns); err != nil {
 		return err
 	}
 
-	for _, row := range table.Rows {
-		if err := writer.Write(row); err != nil {
-			return err
+	for _, rows := range [][][]string{table.Rows, table.Footer} {
+		for _, row := range rows {
+			if err := writer.Write(row); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -86,7 +242,7 @@ func PrintCSV(w io.Writer, table Table) error {
 	return writer.Error()
 }
 
-func ExtractTable(payload map[string]any) (Table, bool) {
+func ExtractTable(payload map[string]any, opts TableOptions) (Table, bool) {
 	raw, ok := payload["table"]
 	if !ok || raw == nil {
 		return Table{}, false
@@ -102,7 +258,7 @@ func ExtractTable(payload map[string]any) (Table, bool) {
 		return Table{}, false
 	}
 
-	columns := toStringSlice(columnsValue)
+	columns := toStringSlice(columnsValue, opts)
 	if len(columns) == 0 {
 		return Table{}, false
 	}
@@ -112,27 +268,115 @@ func ExtractTable(payload map[string]any) (Table, bool) {
 		return Table{}, false
 	}
 
-	rows := toStringMatrix(rowsValue, len(columns))
-	return Table{Columns: columns, Rows: rows}, true
+	rows := toStringMatrix(rowsValue, len(columns), opts)
+	table := Table{Columns: columns, Rows: rows}
+	if footer, ok := rawMap["footer"]; ok && footer != nil {
+		table.Footer = toStringMatrix(footer, len(columns), opts)
+	}
+	return table, true
 }
 
-func PrintTableOrJSON(payload map[string]any, format string) error {
-	if format == "table" || format == "csv" {
-		if table, ok := ExtractTable(payload); ok {
-			switch format {
-			case "table":
-				PrintTable(os.Stdout, table)
-				return nil
-			case "csv":
-				return PrintCSV(os.Stdout, table)
-			}
+// LocalizeColumn rewrites RFC3339 cells in the named column into loc. Cells
+// that do not parse are left as they are.
+func LocalizeColumn(table Table, column string, loc *time.Location) Table {
+	index := -1
+	for i, name := range table.Columns {
+		if name == column {
+			index = i
+			break
+		}
+	}
+	if index < 0 || loc == nil {
+		return table
+	}
+
+	rows := make([][]string, len(table.Rows))
+	for i, row := range table.Rows {
+		rows[i] = append([]string(nil), row...)
+		if index >= len(row) {
+			continue
+		}
+		if parsed, err := time.Parse(time.RFC3339Nano, row[index]); err == nil {
+			rows[i][index] = parsed.In(loc).Format(time.RFC3339)
+		}
+	}
+	return Table{Columns: table.Columns, Rows: rows, Footer: table.Footer}
+}
+
+// PrintTableOrJSON writes the payload table for table/csv/markdown/spark
+// formats, rendered with opts. JSON output is never converted. When rows are
+// cut by Limit or Tail a note follows the table; for CSV it goes to stderr so
+// the file stays parseable.
+func PrintTableOrJSON(w io.Writer, payload map[string]any, format string, opts TableOptions) error {
+	if format != "table" && format != "csv" && format != "markdown" && format != "spark" {
+		return PrintJSON(w, payload)
+	}
+	table, ok := ExtractTable(payload, opts)
+	if !ok {
+		return PrintJSON(w, payload)
+	}
+	return WriteTable(w, table, format, opts)
+}
+
+// WriteTable renders table as table, csv, markdown, or spark output, applying
+// the column selection, transposition, and row limits from opts.
+func WriteTable(w io.Writer, table Table, format string, opts TableOptions) error {
+	table = LocalizeColumn(table, "at", opts.DisplayLoc)
+	if len(opts.Columns) > 0 {
+		selected, err := SelectColumns(table, opts.Columns)
+		if err != nil {
+			return err
 		}
+		table = selected
 	}
+	if opts.Transpose && format != "spark" {
+		table = TransposeTable(table)
+	}
+	table, hiddenRows := LimitRows(table, opts.Limit, opts.Tail)
 
-	return PrintJSON(os.Stdout, payload)
+	var notes []string
+	var err error
+	switch format {
+	case "table":
+		hiddenColumns := 0
+		if opts.Transpose {
+			table, hiddenColumns = FitTableWidth(table, TerminalWidth(w))
+		}
+		PrintTable(w, table)
+		if hiddenColumns > 0 {
+			notes = append(notes, fmt.Sprintf("(%d earlier columns hidden to fit the terminal; use --format csv for all)", hiddenColumns))
+		}
+	case "csv":
+		if err := PrintCSV(w, table, opts.CSV); err != nil {
+			return err
+		}
+		if hiddenRows > 0 {
+			fmt.Fprintln(os.Stderr, limitNote(hiddenRows, opts))
+		}
+		return nil
+	case "markdown":
+		err = PrintMarkdown(w, table)
+	case "spark":
+		err = PrintSparklines(w, table, !SupportsUnicode())
+	default:
+		return fmt.Errorf("unsupported table format %q", format)
+	}
+	if err != nil {
+		return err
+	}
+
+	if hiddenRows > 0 {
+		notes = append(notes, limitNote(hiddenRows, opts))
+	}
+	for _, note := range notes {
+		if _, err := fmt.Fprintln(w, note); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func toStringSlice(value any) []string {
+func toStringSlice(value any, opts TableOptions) []string {
 	list, ok := value.([]any)
 	if !ok {
 		return nil
@@ -140,12 +384,12 @@ func toStringSlice(value any) []string {
 
 	out := make([]string, 0, len(list))
 	for _, item := range list {
-		out = append(out, formatCell(item))
+		out = append(out, formatCell(item, opts))
 	}
 	return out
 }
 
-func toStringMatrix(value any, columns int) [][]string {
+func toStringMatrix(value any, columns int, opts TableOptions) [][]string {
 	rowsRaw, ok := value.([]any)
 	if !ok {
 		return nil
@@ -159,7 +403,7 @@ func toStringMatrix(value any, columns int) [][]string {
 		}
 		row := make([]string, 0, columns)
 		for _, cell := range list {
-			row = append(row, formatCell(cell))
+			row = append(row, formatCell(cell, opts))
 		}
 		if len(row) < columns {
 			for len(row) < columns {
@@ -172,18 +416,23 @@ func toStringMatrix(value any, columns int) [][]string {
 	return rows
 }
 
-func formatCell(value any) string {
+func formatCell(value any, opts TableOptions) string {
 	if value == nil {
 		return ""
 	}
 
 	switch v := value.(type) {
 	case json.Number:
+		if opts.Precision >= 0 && strings.ContainsAny(v.String(), ".eE") {
+			if parsed, err := v.Float64(); err == nil {
+				return formatFloatCell(parsed, 64, opts)
+			}
+		}
 		return v.String()
 	case float64:
-		return strconv.FormatFloat(v, 'f', -1, 64)
+		return formatFloatCell(v, 64, opts)
 	case float32:
-		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+		return formatFloatCell(float64(v), 32, opts)
 	case int:
 		return strconv.FormatInt(int64(v), 10)
 	case int64:
@@ -209,9 +458,28 @@ func formatCell(value any) string {
 	}
 }
 
+func formatFloatCell(value float64, bitSize int, opts TableOptions) string {
+	if opts.Precision < 0 || value == math.Trunc(value) {
+		return strconv.FormatFloat(value, 'f', -1, bitSize)
+	}
+	return strconv.FormatFloat(value, 'f', opts.Precision, bitSize)
+}
+
 func padRight(value string, width int) string {
-	if len(value) >= width {
-		return value
+	if n := displayWidth(value); n < width {
+		return value + strings.Repeat(" ", width-n)
 	}
-	return value + strings.Repeat(" ", width-len(value))
+	return value
+}
+
+func padLeft(value string, width int) string {
+	if n := displayWidth(value); n < width {
+		return strings.Repeat(" ", width-n) + value
+	}
+	return value
+}
+
+// displayWidth counts runes rather than bytes so non-ASCII keys line up.
+func displayWidth(value string) int {
+	return utf8.RuneCountInString(value)
 }
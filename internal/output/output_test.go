@@ -0,0 +1,543 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrintTable(t *testing.T) {
+	t.Parallel()
+
+	table := Table{
+		Columns: []string{"metric_key", "observations", "note", "empty"},
+		Rows: [][]string{
+			{"event::logs", "42", "7", ""},
+			{"événement::clics", "1234.5", "n/a", ""},
+			{"短い", "", "3"},
+		},
+	}
+
+	var buf bytes.Buffer
+	PrintTable(&buf, table)
+	want := "" +
+		"metric_key        observations  note  empty\n" +
+		"----------------  ------------  ----  -----\n" +
+		"event::logs                 42  7          \n" +
+		"événement::clics        1234.5  n/a        \n" +
+		"短い                              3          \n"
+	if buf.String() != want {
+		t.Fatalf("PrintTable =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestPrintMarkdown(t *testing.T) {
+	t.Parallel()
+
+	table := Table{
+		Columns: []string{"metric_key", "observations"},
+		Rows: [][]string{
+			{"event::logs", "42"},
+			{"a|b", "line\nbreak"},
+			{"short"},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := PrintMarkdown(&buf, table); err != nil {
+		t.Fatalf("PrintMarkdown returned error: %v", err)
+	}
+	want := "| metric_key  | observations  |\n" +
+		"| ----------- | ------------- |\n" +
+		"| event::logs | 42            |\n" +
+		"| a\\|b        | line<br>break |\n" +
+		"| short       |               |\n"
+	if buf.String() != want {
+		t.Fatalf("PrintMarkdown =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestPrintSparklines(t *testing.T) {
+	t.Parallel()
+
+	table := Table{
+		Columns: []string{"at", "count", "flat", "empty"},
+		Rows: [][]string{
+			{"2026-01-01T00:00:00Z", "0", "5", ""},
+			{"2026-01-01T01:00:00Z", "", "5", ""},
+			{"2026-01-01T02:00:00Z", "7", "5", ""},
+			{"2026-01-01T03:00:00Z", "3.5", "5", ""},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := PrintSparklines(&buf, table, false); err != nil {
+		t.Fatalf("PrintSparklines returned error: %v", err)
+	}
+	want := "count  ▁ █▄  min=0 max=7 last=3.5\n" +
+		"flat   ▅▅▅▅  min=5 max=5 last=5\n" +
+		"empty  (no data)\n"
+	if buf.String() != want {
+		t.Fatalf("PrintSparklines =\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	buf.Reset()
+	if err := PrintSparklines(&buf, table, true); err != nil {
+		t.Fatalf("PrintSparklines returned error: %v", err)
+	}
+	if line := strings.SplitN(buf.String(), "\n", 2)[0]; line != "count  _ #:  min=0 max=7 last=3.5" {
+		t.Fatalf("ascii sparkline = %q", line)
+	}
+}
+
+func TestPrintPrometheus(t *testing.T) {
+	t.Parallel()
+
+	at := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	samples := []PromSample{
+		{
+			Labels: []PromLabel{{Name: "key", Value: "event::logs"}, {Name: "path", Value: "count"}, {Name: "aggregator", Value: "sum"}},
+			Value:  42,
+			At:     at,
+		},
+		{
+			Labels: []PromLabel{{Name: "key", Value: "a\"b\\c\nd"}},
+			Value:  0.25,
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := PrintPrometheus(&buf, "trifle::metric.count", samples); err != nil {
+		t.Fatalf("PrintPrometheus returned error: %v", err)
+	}
+	want := "# TYPE trifle_metric_count gauge\n" +
+		`trifle_metric_count{key="event::logs",path="count",aggregator="sum"} 42 1735689600000` + "\n" +
+		`trifle_metric_count{key="a\"b\\c\nd"} 0.25` + "\n"
+	if buf.String() != want {
+		t.Fatalf("PrintPrometheus =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestSanitizePromName(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]string{
+		"trifle_metric":   "trifle_metric",
+		"event::logs":     "event_logs",
+		"duration.p95":    "duration_p95",
+		"5xx-errors":      "_5xx_errors",
+		"::":              "_",
+		"http requests/s": "http_requests_s",
+	}
+	for input, want := range cases {
+		if got := SanitizePromName(input); got != want {
+			t.Fatalf("SanitizePromName(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestFormatNumber(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		value     float64
+		precision int
+		want      string
+	}{
+		{0, -1, "0"},
+		{999, -1, "999"},
+		{12345, -1, "12,345"},
+		{-1234567, -1, "-1,234,567"},
+		{1234.5678, -1, "1,234.5678"},
+		{1234.5678, 2, "1,234.57"},
+		{1000, 2, "1,000"},
+	}
+	for _, tc := range cases {
+		if got := FormatNumber(tc.value, tc.precision); got != tc.want {
+			t.Fatalf("FormatNumber(%v, %d) = %q, want %q", tc.value, tc.precision, got, tc.want)
+		}
+	}
+}
+
+func TestTransposeTable(t *testing.T) {
+	t.Parallel()
+
+	table := Table{
+		Columns: []string{"at", "count", "duration"},
+		Rows: [][]string{
+			{"01:00", "1", "2.5"},
+			{"02:00", "3"},
+		},
+	}
+	got := TransposeTable(table)
+	want := Table{
+		Columns: []string{"at", "01:00", "02:00"},
+		Rows: [][]string{
+			{"count", "1", "3"},
+			{"duration", "2.5", ""},
+		},
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("TransposeTable = %v, want %v", got, want)
+	}
+}
+
+func TestFitTableWidth(t *testing.T) {
+	t.Parallel()
+
+	table := Table{
+		Columns: []string{"at", "01:00", "02:00", "03:00"},
+		Rows:    [][]string{{"count", "1", "22", "333"}},
+	}
+
+	// "count" plus two 5-wide columns and their separators is 19 characters.
+	got, hidden := FitTableWidth(table, 19)
+	if hidden != 1 || fmt.Sprint(got.Columns) != "[at 02:00 03:00]" || fmt.Sprint(got.Rows) != "[[count 22 333]]" {
+		t.Fatalf("FitTableWidth = %v, %d", got, hidden)
+	}
+
+	if _, hidden := FitTableWidth(table, 0); hidden != 0 {
+		t.Fatalf("FitTableWidth with unknown width hid %d columns", hidden)
+	}
+	if got, hidden := FitTableWidth(table, 3); hidden != 2 || len(got.Columns) != 2 {
+		t.Fatalf("FitTableWidth on a narrow terminal = %v, %d; want one value column kept", got, hidden)
+	}
+}
+
+func TestLimitRows(t *testing.T) {
+	t.Parallel()
+
+	table := Table{Columns: []string{"n"}, Rows: [][]string{{"1"}, {"2"}, {"3"}}}
+
+	cases := []struct {
+		limit, tail int
+		want        string
+		hidden      int
+	}{
+		{0, 0, "[[1] [2] [3]]", 0},
+		{2, 0, "[[1] [2]]", 1},
+		{0, 2, "[[2] [3]]", 1},
+		{5, 0, "[[1] [2] [3]]", 0},
+	}
+	for _, tc := range cases {
+		got, hidden := LimitRows(table, tc.limit, tc.tail)
+		if fmt.Sprint(got.Rows) != tc.want || hidden != tc.hidden {
+			t.Fatalf("LimitRows(%d, %d) = %v, %d; want %s, %d", tc.limit, tc.tail, got.Rows, hidden, tc.want, tc.hidden)
+		}
+	}
+}
+
+func TestLimitPayloadTable(t *testing.T) {
+	t.Parallel()
+
+	original := map[string]any{
+		"columns": []any{"at", "count"},
+		"rows":    []any{[]any{"a", 1}, []any{"b", 2}, []any{"c", 3}},
+	}
+	payload := map[string]any{"table": original}
+	if hidden := LimitPayloadTable(payload, 1, 0); hidden != 2 {
+		t.Fatalf("LimitPayloadTable hidden = %d, want 2", hidden)
+	}
+	table := payload["table"].(map[string]any)
+	if len(table["rows"].([]any)) != 1 || table["total_rows"] != 3 {
+		t.Fatalf("limited table = %v", table)
+	}
+	if len(original["rows"].([]any)) != 3 {
+		t.Fatalf("LimitPayloadTable modified the original table")
+	}
+
+	if hidden := LimitPayloadTable(map[string]any{"values": []any{}}, 1, 0); hidden != 0 {
+		t.Fatalf("LimitPayloadTable without a table hid %d rows", hidden)
+	}
+}
+
+func TestPrintTableOrJSONLimit(t *testing.T) {
+	t.Parallel()
+
+	payload := map[string]any{
+		"table": map[string]any{
+			"columns": []any{"at", "count"},
+			"rows":    []any{[]any{"a", 1}, []any{"b", 2}, []any{"c", 3}},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := PrintTableOrJSON(&buf, payload, "markdown", TableOptions{Precision: -1, Tail: 1}); err != nil {
+		t.Fatalf("PrintTableOrJSON returned error: %v", err)
+	}
+	want := "| at  | count |\n| --- | ----- |\n| c   | 3     |\n… 2 earlier rows (use --tail 0 for all)\n"
+	if buf.String() != want {
+		t.Fatalf("PrintTableOrJSON =\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	buf.Reset()
+	if err := PrintTableOrJSON(&buf, payload, "csv", TableOptions{Precision: -1, Limit: 2}); err != nil {
+		t.Fatalf("PrintTableOrJSON returned error: %v", err)
+	}
+	if buf.String() != "at,count\na,1\nb,2\n" {
+		t.Fatalf("limited CSV = %q", buf.String())
+	}
+}
+
+func TestPrintTableOrJSONFooter(t *testing.T) {
+	t.Parallel()
+
+	payload := map[string]any{
+		"table": map[string]any{
+			"columns": []any{"at", "count"},
+			"rows":    []any{[]any{"a", 1}, []any{"b", 20}, []any{"c", 3}},
+			"footer":  []any{[]any{"max", 20}, []any{"last", 3}},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := PrintTableOrJSON(&buf, payload, "table", TableOptions{Precision: -1, Limit: 1}); err != nil {
+		t.Fatalf("PrintTableOrJSON returned error: %v", err)
+	}
+	want := "" +
+		"at    count\n" +
+		"----  -----\n" +
+		"a         1\n" +
+		"----  -----\n" +
+		"max      20\n" +
+		"last      3\n" +
+		"… 2 more rows (use --limit 0 for all)\n"
+	if buf.String() != want {
+		t.Fatalf("PrintTableOrJSON =\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	buf.Reset()
+	if err := PrintTableOrJSON(&buf, payload, "csv", TableOptions{Precision: -1, Columns: []string{"count"}}); err != nil {
+		t.Fatalf("PrintTableOrJSON returned error: %v", err)
+	}
+	if buf.String() != "count\n1\n20\n3\n20\n3\n" {
+		t.Fatalf("CSV with footer = %q", buf.String())
+	}
+
+	transposed := TransposeTable(Table{Columns: []string{"at", "count"}, Rows: [][]string{{"a", "1"}}, Footer: [][]string{{"max", "1"}}})
+	if strings.Join(transposed.Columns, ",") != "at,a,max" || len(transposed.Footer) != 0 {
+		t.Fatalf("TransposeTable with footer = %+v", transposed)
+	}
+}
+
+func TestSelectColumns(t *testing.T) {
+	t.Parallel()
+
+	table := Table{
+		Columns: []string{"at", "count", "duration.sum"},
+		Rows:    [][]string{{"01:00", "1", "2.5"}, {"02:00", "3"}},
+	}
+
+	got, err := SelectColumns(table, []string{"duration.sum", "at"})
+	if err != nil {
+		t.Fatalf("SelectColumns returned error: %v", err)
+	}
+	if fmt.Sprint(got.Columns) != "[duration.sum at]" || fmt.Sprint(got.Rows) != "[[2.5 01:00] [ 02:00]]" {
+		t.Fatalf("SelectColumns = %v", got)
+	}
+
+	_, err = SelectColumns(table, []string{"at", "duration"})
+	if err == nil || err.Error() != `unknown column "duration" (available: at, count, duration.sum)` {
+		t.Fatalf("SelectColumns unknown column error = %v", err)
+	}
+
+	payload := map[string]any{"table": map[string]any{
+		"columns": []any{"at", "count", "duration.sum"},
+		"rows":    []any{[]any{"01:00", 1, 2.5}},
+	}}
+	var buf bytes.Buffer
+	if err := PrintTableOrJSON(&buf, payload, "csv", TableOptions{Precision: -1, Columns: []string{"at", "count"}}); err != nil {
+		t.Fatalf("PrintTableOrJSON returned error: %v", err)
+	}
+	if buf.String() != "at,count\n01:00,1\n" {
+		t.Fatalf("selected CSV = %q", buf.String())
+	}
+}
+
+func TestPrintCSVDelimiter(t *testing.T) {
+	t.Parallel()
+
+	table := Table{
+		Columns: []string{"metric_key", "value"},
+		Rows:    [][]string{{"a;b", "1,5"}, {"tab\there", `say "hi"`}},
+	}
+
+	cases := []struct {
+		delimiter rune
+		want      string
+	}{
+		{0, "metric_key,value\na;b,\"1,5\"\ntab\there,\"say \"\"hi\"\"\"\n"},
+		{';', "metric_key;value\n\"a;b\";1,5\ntab\there;\"say \"\"hi\"\"\"\n"},
+		{'\t', "metric_key\tvalue\na;b\t1,5\n\"tab\there\"\t\"say \"\"hi\"\"\"\n"},
+	}
+	for _, tc := range cases {
+		var buf bytes.Buffer
+		if err := PrintCSV(&buf, table, CSVOptions{Delimiter: tc.delimiter}); err != nil {
+			t.Fatalf("PrintCSV(%q) returned error: %v", tc.delimiter, err)
+		}
+		if buf.String() != tc.want {
+			t.Fatalf("PrintCSV(%q) = %q, want %q", tc.delimiter, buf.String(), tc.want)
+		}
+	}
+}
+
+func TestTableFromObjects(t *testing.T) {
+	t.Parallel()
+
+	objects := []map[string]any{
+		{"name": "errors", "id": float64(7), "config": map[string]any{"path": "count"}, "enabled": true},
+		{"id": float64(8), "status": "active", "tags": []any{"a", "b"}, "zeta": nil},
+	}
+	table := TableFromObjects(objects, []string{"id", "name", "kind", "status"})
+
+	if got := fmt.Sprint(table.Columns); got != "[id name status config enabled tags zeta]" {
+		t.Fatalf("TableFromObjects columns = %s", got)
+	}
+	want := [][]string{
+		{"7", "errors", "", `{"path":"count"}`, "true", "", ""},
+		{"8", "", "active", "", "", `["a","b"]`, ""},
+	}
+	if fmt.Sprint(table.Rows) != fmt.Sprint(want) {
+		t.Fatalf("TableFromObjects rows = %q, want %q", table.Rows, want)
+	}
+}
+
+func TestFormatCellPrecision(t *testing.T) {
+	t.Parallel()
+
+	rounded := TableOptions{Precision: 2}
+	full := TableOptions{Precision: -1}
+
+	cases := []struct {
+		value any
+		opts  TableOptions
+		want  string
+	}{
+		{1.0 / 3, full, "0.3333333333333333"},
+		{1.0 / 3, rounded, "0.33"},
+		{float32(2.675), rounded, "2.67"},
+		{0.5, rounded, "0.50"},
+		{0.333, TableOptions{Precision: 0}, "0"},
+		{json.Number("0.33333"), rounded, "0.33"},
+		{json.Number("0.33333"), full, "0.33333"},
+		{json.Number("1.5e-3"), rounded, "0.00"},
+		{json.Number("42"), rounded, "42"},
+		{json.Number("9007199254740993"), rounded, "9007199254740993"},
+		{42.0, rounded, "42"},
+		{float64(1 << 40), rounded, "1099511627776"},
+		{7, rounded, "7"},
+		{int64(-12), rounded, "-12"},
+		{uint8(3), rounded, "3"},
+		{"0.123456", rounded, "0.123456"},
+	}
+
+	for _, tt := range cases {
+		if got := formatCell(tt.value, tt.opts); got != tt.want {
+			t.Fatalf("formatCell(%#v, precision %d) = %q, want %q", tt.value, tt.opts.Precision, got, tt.want)
+		}
+	}
+}
+
+func TestPrintTableOrJSONPrecision(t *testing.T) {
+	t.Parallel()
+
+	payload := map[string]any{
+		"value": 1.0 / 3,
+		"table": map[string]any{
+			"columns": []any{"at", "mean"},
+			"rows":    []any{[]any{"2026-01-01T00:00:00Z", 1.0 / 3}},
+		},
+	}
+
+	var csv bytes.Buffer
+	if err := PrintTableOrJSON(&csv, payload, "csv", TableOptions{Precision: 3}); err != nil {
+		t.Fatalf("PrintTableOrJSON returned error: %v", err)
+	}
+	if csv.String() != "at,mean\n2026-01-01T00:00:00Z,0.333\n" {
+		t.Fatalf("csv = %q", csv.String())
+	}
+
+	var out bytes.Buffer
+	if err := PrintTableOrJSON(&out, payload, "json", TableOptions{Precision: 3}); err != nil {
+		t.Fatalf("PrintTableOrJSON returned error: %v", err)
+	}
+	if !strings.Contains(out.String(), "0.3333333333333333") {
+		t.Fatalf("json output was rounded: %s", out.String())
+	}
+}
+
+func TestWriteSVGChart(t *testing.T) {
+	t.Parallel()
+
+	table := Table{
+		Columns: []string{"at", "ok", "error", "label"},
+		Rows: [][]string{
+			{"2026-01-01T00:00:00Z", "1", "", "a"},
+			{"2026-01-01T01:00:00Z", "3", "2", "b"},
+			{"2026-01-01T02:00:00Z", "", "", "c"},
+			{"2026-01-01T03:00:00Z", "4", "", "d"},
+		},
+	}
+	var buf bytes.Buffer
+	err := WriteSVGChart(&buf, table, ChartOptions{Width: 640, Height: 320, Title: "event::logs <status>", Subtitle: "last 4h"})
+	if err != nil {
+		t.Fatalf("WriteSVGChart returned error: %v", err)
+	}
+	svg := buf.String()
+	for _, fragment := range []string{
+		`<svg xmlns="http://www.w3.org/2000/svg" width="640" height="320"`,
+		"event::logs &lt;status&gt;",
+		">2026-01-01 00:00</text>",
+		">2026-01-01 03:00</text>",
+		">ok</text>",
+		">error</text>",
+	} {
+		if !strings.Contains(svg, fragment) {
+			t.Fatalf("svg missing %q:\n%s", fragment, svg)
+		}
+	}
+	// ok breaks at the blank third row into a line and a lone point; error
+	// has a single point.
+	if got := strings.Count(svg, "<polyline"); got != 1 {
+		t.Fatalf("polylines = %d, want 1:\n%s", got, svg)
+	}
+	if got := strings.Count(svg, "<circle"); got != 2 {
+		t.Fatalf("circles = %d, want 2:\n%s", got, svg)
+	}
+	if strings.Contains(svg, ">label</text>") {
+		t.Fatal("non-numeric column drawn in the legend")
+	}
+
+	empty := Table{Columns: []string{"at", "ok"}, Rows: [][]string{{"2026-01-01T00:00:00Z", ""}}}
+	if err := WriteSVGChart(&buf, empty, ChartOptions{Width: 640, Height: 320}); !errors.Is(err, ErrEmptyChart) {
+		t.Fatalf("empty chart error = %v, want ErrEmptyChart", err)
+	}
+	if err := WriteSVGChart(&buf, table, ChartOptions{Width: 100, Height: 80}); err == nil {
+		t.Fatal("expected a too-small error")
+	}
+}
+
+func TestChartTicks(t *testing.T) {
+	t.Parallel()
+
+	value := func(v float64) *float64 { return &v }
+	tests := []struct {
+		values []*float64
+		want   []float64
+	}{
+		{values: []*float64{value(0), value(11)}, want: []float64{0, 5, 10, 15}},
+		{values: []*float64{value(0.1), value(0.3)}, want: []float64{0.1, 0.15, 0.2, 0.25, 0.3}},
+		{values: []*float64{value(5), nil, value(5)}, want: []float64{4, 4.5, 5, 5.5, 6}},
+	}
+	for _, tt := range tests {
+		got := chartTicks([]chartSeries{{Name: "v", Values: tt.values}})
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("chartTicks(%v) = %v, want %v", tt.values, got, tt.want)
+		}
+	}
+}
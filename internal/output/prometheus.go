@@ -0,0 +1,91 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PromLabel is one label of a Prometheus sample; order is preserved.
+type PromLabel struct {
+	Name  string
+	Value string
+}
+
+// PromSample is one line of the Prometheus text exposition format.
+type PromSample struct {
+	Labels []PromLabel
+	Value  float64
+	At     time.Time
+}
+
+var (
+	promInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9_]+`)
+	promUnderscores  = regexp.MustCompile(`_{2,}`)
+)
+
+// SanitizePromName maps s onto [a-zA-Z_][a-zA-Z0-9_]*, replacing runs of
+// other characters (including "::" and dots) with a single underscore.
+// Colons are dropped too since Prometheus reserves them for recording rules.
+func SanitizePromName(s string) string {
+	name := promInvalidChars.ReplaceAllString(s, "_")
+	name = promUnderscores.ReplaceAllString(name, "_")
+	name = strings.Trim(name, "_")
+	if name == "" {
+		return "_"
+	}
+	if name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+	return name
+}
+
+// PrintPrometheus writes samples as a gauge named name in the Prometheus text
+// exposition format, with millisecond timestamps.
+func PrintPrometheus(w io.Writer, name string, samples []PromSample) error {
+	name = SanitizePromName(name)
+	if _, err := fmt.Fprintf(w, "# TYPE %s gauge\n", name); err != nil {
+		return err
+	}
+	for _, sample := range samples {
+		labels := make([]string, len(sample.Labels))
+		for i, label := range sample.Labels {
+			labels[i] = fmt.Sprintf(`%s="%s"`, SanitizePromName(label.Name), escapePromLabel(label.Value))
+		}
+		line := name
+		if len(labels) > 0 {
+			line += "{" + strings.Join(labels, ",") + "}"
+		}
+		line += " " + formatPromValue(sample.Value)
+		if !sample.At.IsZero() {
+			line += " " + strconv.FormatInt(sample.At.UnixMilli(), 10)
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var promLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapePromLabel(value string) string {
+	return promLabelEscaper.Replace(value)
+}
+
+func formatPromValue(value float64) string {
+	switch {
+	case math.IsNaN(value):
+		return "NaN"
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	}
+}
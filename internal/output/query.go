@@ -0,0 +1,139 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type pathSegment struct {
+	key      string
+	wildcard bool
+}
+
+// ExtractPath selects part of a JSON-compatible value. Segments are separated
+// by dots; array elements are addressed as "values.0" or "values[0]", and
+// "values[]" applies the rest of the path to every element. Elements missing
+// the rest of a wildcard path yield null; any other missing segment is an
+// error.
+func ExtractPath(value any, path string) (any, error) {
+	segments, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+	var doc any
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	return walkPath(doc, segments, "")
+}
+
+// FormatScalar returns the raw text of a string, number, bool, or null.
+func FormatScalar(value any) (string, bool) {
+	switch v := value.(type) {
+	case nil:
+		return "null", true
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	case bool:
+		return strconv.FormatBool(v), true
+	default:
+		return "", false
+	}
+}
+
+func parsePath(path string) ([]pathSegment, error) {
+	trimmed := strings.TrimPrefix(strings.TrimSpace(path), ".")
+	if trimmed == "" {
+		return nil, fmt.Errorf("query path is empty")
+	}
+
+	var segments []pathSegment
+	for _, part := range strings.Split(trimmed, ".") {
+		key, rest, _ := strings.Cut(part, "[")
+		if key != "" {
+			segments = append(segments, pathSegment{key: key})
+		}
+		for rest != "" {
+			index, after, ok := strings.Cut(rest, "]")
+			if !ok {
+				return nil, fmt.Errorf("invalid query path %q: unclosed [", path)
+			}
+			segments = append(segments, pathSegment{key: index, wildcard: index == ""})
+			if after == "" {
+				break
+			}
+			if !strings.HasPrefix(after, "[") {
+				return nil, fmt.Errorf("invalid query path %q: unexpected %q after ]", path, after)
+			}
+			rest = after[1:]
+		}
+		if key == "" && !strings.Contains(part, "[") {
+			return nil, fmt.Errorf("invalid query path %q: empty segment", path)
+		}
+	}
+	return segments, nil
+}
+
+func walkPath(node any, segments []pathSegment, walked string) (any, error) {
+	if len(segments) == 0 {
+		return node, nil
+	}
+	segment := segments[0]
+
+	if segment.wildcard {
+		list, ok := node.([]any)
+		if !ok {
+			return nil, fmt.Errorf("path %s[] is not an array", walked)
+		}
+		out := make([]any, 0, len(list))
+		for i, item := range list {
+			value, err := walkPath(item, segments[1:], walked+"["+strconv.Itoa(i)+"]")
+			if err != nil {
+				value = nil
+			}
+			out = append(out, value)
+		}
+		return out, nil
+	}
+
+	next := segment.key
+	if walked != "" {
+		next = walked + "." + segment.key
+	}
+
+	switch v := node.(type) {
+	case map[string]any:
+		child, ok := v[segment.key]
+		if !ok {
+			return nil, fmt.Errorf("path %s not found", next)
+		}
+		return walkPath(child, segments[1:], next)
+	case []any:
+		index, err := strconv.Atoi(segment.key)
+		if err != nil {
+			return nil, fmt.Errorf("path %s: %q is not an array index", next, segment.key)
+		}
+		if index < 0 {
+			index += len(v)
+		}
+		if index < 0 || index >= len(v) {
+			return nil, fmt.Errorf("path %s not found: index out of range (length %d)", next, len(v))
+		}
+		return walkPath(v[index], segments[1:], next)
+	default:
+		return nil, fmt.Errorf("path %s not found", next)
+	}
+}
@@ -0,0 +1,72 @@
+package output
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestExtractPath(t *testing.T) {
+	t.Parallel()
+
+	doc := map[string]any{
+		"data": map[string]any{
+			"at": []time.Time{time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+			"values": []map[string]any{
+				{"count": 3, "duration": map[string]any{"sum": 1.5}},
+				{"count": 1},
+				nil,
+			},
+		},
+		"status": "ok",
+	}
+
+	cases := []struct {
+		path string
+		want string
+	}{
+		{"status", `"ok"`},
+		{".status", `"ok"`},
+		{"data.values.0.count", "3"},
+		{"data.values[1].count", "1"},
+		{"data.values.-3.duration.sum", "1.5"},
+		{"data.at[0]", `"2026-01-01T00:00:00Z"`},
+		{"data.values[].count", "[3,1,null]"},
+		{"data.values[0]", `{"count":3,"duration":{"sum":1.5}}`},
+		{"data.values[2]", "null"},
+	}
+
+	for _, tt := range cases {
+		value, err := ExtractPath(doc, tt.path)
+		if err != nil {
+			t.Fatalf("ExtractPath(%q) returned error: %v", tt.path, err)
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			t.Fatalf("marshal %q: %v", tt.path, err)
+		}
+		if string(encoded) != tt.want {
+			t.Fatalf("ExtractPath(%q) = %s, want %s", tt.path, encoded, tt.want)
+		}
+	}
+}
+
+func TestExtractPathErrors(t *testing.T) {
+	t.Parallel()
+
+	doc := map[string]any{"data": map[string]any{"values": []any{map[string]any{"count": 1}}}}
+	for _, path := range []string{
+		"",
+		"data.missing",
+		"data.values.1",
+		"data.values.count",
+		"data.values[0].count.deeper",
+		"data[].values",
+		"data..values",
+		"data.values[0",
+	} {
+		if _, err := ExtractPath(doc, path); err == nil {
+			t.Fatalf("ExtractPath(%q) expected error", path)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var (
+	unicodeSparkLevels = []rune("▁▂▃▄▅▆▇█")
+	asciiSparkLevels   = []rune("_.-:=+*#")
+)
+
+// PrintSparklines writes one line per value column of table: the column
+// name, a sparkline scaled to the column's min/max, and its min, max and last
+// values. Blank cells are drawn as gaps. The "at" column is skipped.
+func PrintSparklines(w io.Writer, table Table, ascii bool) error {
+	levels := unicodeSparkLevels
+	if ascii {
+		levels = asciiSparkLevels
+	}
+
+	width := 0
+	for _, column := range table.Columns {
+		if column != "at" && displayWidth(column) > width {
+			width = displayWidth(column)
+		}
+	}
+
+	for i, column := range table.Columns {
+		if column == "at" {
+			continue
+		}
+		values := make([]*float64, len(table.Rows))
+		for r, row := range table.Rows {
+			if i >= len(row) {
+				continue
+			}
+			if parsed, err := strconv.ParseFloat(strings.TrimSpace(row[i]), 64); err == nil {
+				values[r] = &parsed
+			}
+		}
+		if _, err := fmt.Fprintf(w, "%s  %s\n", padRight(column, width), sparkline(values, levels)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sparkline(values []*float64, levels []rune) string {
+	var minValue, maxValue, last float64
+	seen := false
+	for _, value := range values {
+		if value == nil {
+			continue
+		}
+		if !seen || *value < minValue {
+			minValue = *value
+		}
+		if !seen || *value > maxValue {
+			maxValue = *value
+		}
+		last = *value
+		seen = true
+	}
+	if !seen {
+		return "(no data)"
+	}
+
+	var line strings.Builder
+	for _, value := range values {
+		switch {
+		case value == nil:
+			line.WriteRune(' ')
+		case maxValue == minValue:
+			line.WriteRune(levels[len(levels)/2])
+		default:
+			level := int((*value - minValue) / (maxValue - minValue) * float64(len(levels)-1))
+			line.WriteRune(levels[level])
+		}
+	}
+
+	return fmt.Sprintf("%s  min=%s max=%s last=%s", line.String(), formatFloat(minValue), formatFloat(maxValue), formatFloat(last))
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// SupportsUnicode reports whether the locale environment asks for UTF-8.
+func SupportsUnicode() bool {
+	for _, name := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+		value = strings.ToLower(value)
+		return strings.Contains(value, "utf-8") || strings.Contains(value, "utf8")
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package output
+
+import (
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// FormatNumber renders value with thousands separators. A non-negative
+// precision rounds the fraction to that many decimals; integral values never
+// gain a decimal point.
+func FormatNumber(value float64, precision int) string {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+	if precision >= 0 {
+		scale := math.Pow(10, float64(precision))
+		value = math.Round(value*scale) / scale
+	}
+
+	text := strconv.FormatFloat(value, 'f', -1, 64)
+	sign := ""
+	if strings.HasPrefix(text, "-") {
+		sign, text = "-", text[1:]
+	}
+	whole, frac, hasFrac := strings.Cut(text, ".")
+
+	var grouped strings.Builder
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			grouped.WriteByte(',')
+		}
+		grouped.WriteRune(digit)
+	}
+	if hasFrac {
+		return sign + grouped.String() + "." + frac
+	}
+	return sign + grouped.String()
+}
+
+// ColorEnabled reports whether ANSI styling should be written to w: only for
+// terminals, and never when NO_COLOR is set.
+func ColorEnabled(w io.Writer) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	_, ok := terminalFile(w)
+	return ok
+}
+
+// Bold wraps text in ANSI bold when enabled.
+func Bold(text string, enabled bool) string {
+	if !enabled {
+		return text
+	}
+	return "\x1b[1m" + text + "\x1b[0m"
+}
@@ -0,0 +1,131 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+var templateFuncs = template.FuncMap{
+	"add":        func(a, b any) (float64, error) { return binaryFloat(a, b, func(x, y float64) float64 { return x + y }) },
+	"sub":        func(a, b any) (float64, error) { return binaryFloat(a, b, func(x, y float64) float64 { return x - y }) },
+	"mul":        func(a, b any) (float64, error) { return binaryFloat(a, b, func(x, y float64) float64 { return x * y }) },
+	"div":        templateDiv,
+	"round":      templateRound,
+	"formatTime": templateFormatTime,
+	"toJSON":     templateToJSON,
+}
+
+// ParseTemplate parses a Go text/template with the CLI helper functions:
+// add, sub, mul, div, round, formatTime, and toJSON.
+func ParseTemplate(text string) (*template.Template, error) {
+	return template.New("output").Funcs(templateFuncs).Parse(text)
+}
+
+// ExecuteTemplate renders value through tmpl after converting it to plain
+// JSON types, so templates see the same field names as the JSON output.
+// Nothing is written when execution fails.
+func ExecuteTemplate(w io.Writer, tmpl *template.Template, value any) error {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	var doc any
+	if err := json.Unmarshal(encoded, &doc); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, doc); err != nil {
+		return err
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+func toFloat(value any) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case int:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case json.Number:
+		return v.Float64()
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(v), 64)
+	case nil:
+		return 0, nil
+	default:
+		return 0, fmt.Errorf("cannot use %T as a number", value)
+	}
+}
+
+func binaryFloat(a, b any, op func(x, y float64) float64) (float64, error) {
+	x, err := toFloat(a)
+	if err != nil {
+		return 0, err
+	}
+	y, err := toFloat(b)
+	if err != nil {
+		return 0, err
+	}
+	return op(x, y), nil
+}
+
+func templateDiv(a, b any) (float64, error) {
+	y, err := toFloat(b)
+	if err != nil {
+		return 0, err
+	}
+	if y == 0 {
+		return 0, fmt.Errorf("division by zero")
+	}
+	return binaryFloat(a, y, func(x, y float64) float64 { return x / y })
+}
+
+func templateRound(places int, value any) (float64, error) {
+	x, err := toFloat(value)
+	if err != nil {
+		return 0, err
+	}
+	scale := math.Pow(10, float64(places))
+	return math.Round(x*scale) / scale, nil
+}
+
+// templateFormatTime formats an RFC3339 string or epoch seconds with a Go
+// time layout.
+func templateFormatTime(layout string, value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		parsed, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return "", err
+		}
+		return parsed.Format(layout), nil
+	default:
+		seconds, err := toFloat(value)
+		if err != nil {
+			return "", err
+		}
+		whole, frac := math.Modf(seconds)
+		return time.Unix(int64(whole), int64(frac*1e9)).UTC().Format(layout), nil
+	}
+}
+
+func templateToJSON(value any) (string, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
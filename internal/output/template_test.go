@@ -0,0 +1,67 @@
+package output
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExecuteTemplate(t *testing.T) {
+	t.Parallel()
+
+	doc := map[string]any{
+		"data": map[string]any{
+			"at":     []time.Time{time.Date(2026, 1, 1, 9, 30, 0, 0, time.UTC)},
+			"values": []map[string]any{{"count": 3, "duration": 2.5}},
+		},
+	}
+
+	cases := []struct {
+		text string
+		want string
+	}{
+		{`{{range .data.values}}{{.count}}{{"\n"}}{{end}}`, "3\n"},
+		{`{{range .data.values}}{{add .count 2}}{{end}}`, "5"},
+		{`{{range .data.values}}{{div .duration 2 | round 2}}{{end}}`, "1.25"},
+		{`{{range .data.values}}{{printf "%.1f" .duration}}{{end}}`, "2.5"},
+		{`{{range .data.at}}{{formatTime "2006-01-02 15:04" .}}{{end}}`, "2026-01-01 09:30"},
+		{`{{formatTime "15:04" 1767259800}}`, "09:30"},
+		{`{{index .data.values 0 | toJSON}}`, `{"count":3,"duration":2.5}`},
+	}
+
+	for _, tt := range cases {
+		tmpl, err := ParseTemplate(tt.text)
+		if err != nil {
+			t.Fatalf("ParseTemplate(%q) returned error: %v", tt.text, err)
+		}
+		var buf bytes.Buffer
+		if err := ExecuteTemplate(&buf, tmpl, doc); err != nil {
+			t.Fatalf("ExecuteTemplate(%q) returned error: %v", tt.text, err)
+		}
+		if buf.String() != tt.want {
+			t.Fatalf("ExecuteTemplate(%q) = %q, want %q", tt.text, buf.String(), tt.want)
+		}
+	}
+}
+
+func TestExecuteTemplateErrors(t *testing.T) {
+	t.Parallel()
+
+	if _, err := ParseTemplate("{{range .data}"); err == nil {
+		t.Fatalf("ParseTemplate accepted an unterminated action")
+	}
+
+	tmpl, err := ParseTemplate("ok\n{{div 1 .zero}}")
+	if err != nil {
+		t.Fatalf("ParseTemplate returned error: %v", err)
+	}
+	var buf bytes.Buffer
+	err = ExecuteTemplate(&buf, tmpl, map[string]any{"zero": 0})
+	if err == nil || !strings.Contains(err.Error(), "output:2:") {
+		t.Fatalf("ExecuteTemplate error = %v, want one naming line 2", err)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("failed template wrote %q", buf.String())
+	}
+}
@@ -0,0 +1,38 @@
+package output
+
+import (
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// terminalFile returns w as a file when it is attached to a terminal.
+func terminalFile(w io.Writer) (*os.File, bool) {
+	file, ok := w.(*os.File)
+	if !ok {
+		return nil, false
+	}
+	info, err := file.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return nil, false
+	}
+	return file, true
+}
+
+// TerminalWidth returns the column count of the terminal w writes to, or 0
+// when w is not a terminal or its size is unknown. COLUMNS is used when the
+// terminal cannot be queried.
+func TerminalWidth(w io.Writer) int {
+	file, ok := terminalFile(w)
+	if !ok {
+		return 0
+	}
+	if width := terminalColumns(file); width > 0 {
+		return width
+	}
+	if width, err := strconv.Atoi(strings.TrimSpace(os.Getenv("COLUMNS"))); err == nil && width > 0 {
+		return width
+	}
+	return 0
+}
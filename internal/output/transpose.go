@@ -0,0 +1,94 @@
+package output
+
+// TransposeTable swaps rows and columns: the first column's values become the
+// header and every other column becomes a row led by its name. Footer rows
+// become the last columns.
+func TransposeTable(table Table) Table {
+	if len(table.Columns) == 0 {
+		return table
+	}
+	if len(table.Footer) > 0 {
+		table.Rows = append(append([][]string(nil), table.Rows...), table.Footer...)
+	}
+
+	columns := make([]string, 0, len(table.Rows)+1)
+	columns = append(columns, table.Columns[0])
+	for _, row := range table.Rows {
+		columns = append(columns, cellAt(row, 0))
+	}
+
+	rows := make([][]string, 0, len(table.Columns)-1)
+	for i := 1; i < len(table.Columns); i++ {
+		row := make([]string, 0, len(table.Rows)+1)
+		row = append(row, table.Columns[i])
+		for _, source := range table.Rows {
+			row = append(row, cellAt(source, i))
+		}
+		rows = append(rows, row)
+	}
+	return Table{Columns: columns, Rows: rows}
+}
+
+// FitTableWidth drops columns after the first until the table, as laid out by
+// PrintTable, fits in width characters. The last columns are kept since they
+// hold the most recent buckets of a transposed series. It returns the number
+// of columns dropped.
+func FitTableWidth(table Table, width int) (Table, int) {
+	if width <= 0 || len(table.Columns) <= 2 {
+		return table, 0
+	}
+
+	widths := columnWidths(table)
+	used := widths[0]
+	first := len(table.Columns)
+	for first > 1 && used+2+widths[first-1] <= width {
+		first--
+		used += 2 + widths[first]
+	}
+	if first == len(table.Columns) {
+		// Always keep at least one value column.
+		first--
+	}
+	hidden := first - 1
+	if hidden == 0 {
+		return table, 0
+	}
+
+	keep := func(values []string) []string {
+		out := make([]string, 0, len(table.Columns)-hidden)
+		out = append(out, cellAt(values, 0))
+		for i := first; i < len(table.Columns); i++ {
+			out = append(out, cellAt(values, i))
+		}
+		return out
+	}
+	rows := make([][]string, len(table.Rows))
+	for i, row := range table.Rows {
+		rows[i] = keep(row)
+	}
+	return Table{Columns: keep(table.Columns), Rows: rows}, hidden
+}
+
+func columnWidths(table Table) []int {
+	widths := make([]int, len(table.Columns))
+	for i, column := range table.Columns {
+		widths[i] = displayWidth(column)
+	}
+	for _, rows := range [][][]string{table.Rows, table.Footer} {
+		for _, row := range rows {
+			for i, cell := range row {
+				if i < len(widths) && displayWidth(cell) > widths[i] {
+					widths[i] = displayWidth(cell)
+				}
+			}
+		}
+	}
+	return widths
+}
+
+func cellAt(row []string, i int) string {
+	if i < len(row) {
+		return row[i]
+	}
+	return ""
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/trifle-io/trifle-cli/internal/output"
+)
+
+// keysChurnCategories are the sections of a keys comparison, in order.
+var keysChurnCategories = []string{"added", "removed", "changed"}
+
+// keysChurnEntry is a key whose presence or observation count differs
+// between the comparison window and the current one.
+type keysChurnEntry struct {
+	MetricKey string `json:"metric_key"`
+	Previous  int64  `json:"previous_observations"`
+	Current   int64  `json:"current_observations"`
+	Delta     int64  `json:"delta"`
+}
+
+// keysChurn sorts keys into those that only appear in the current window,
+// only in the previous one, or in both with a different observation count.
+type keysChurn struct {
+	Added     []keysChurnEntry `json:"added"`
+	Removed   []keysChurnEntry `json:"removed"`
+	Changed   []keysChurnEntry `json:"changed"`
+	Unchanged int              `json:"unchanged"`
+}
+
+func (c keysChurn) category(name string) []keysChurnEntry {
+	switch name {
+	case "added":
+		return c.Added
+	case "removed":
+		return c.Removed
+	default:
+		return c.Changed
+	}
+}
+
+// compareKeysEntries builds the churn between two keys listings. Added and
+// removed keys are sorted by name; changed keys by the size of their delta,
+// largest first.
+func compareKeysEntries(current, previous []keysEntry) keysChurn {
+	before := make(map[string]int64, len(previous))
+	for _, entry := range previous {
+		before[entry.MetricKey] = entry.Observations
+	}
+	churn := keysChurn{Added: []keysChurnEntry{}, Removed: []keysChurnEntry{}, Changed: []keysChurnEntry{}}
+	seen := make(map[string]bool, len(current))
+	for _, entry := range current {
+		seen[entry.MetricKey] = true
+		count, ok := before[entry.MetricKey]
+		change := keysChurnEntry{MetricKey: entry.MetricKey, Previous: count, Current: entry.Observations, Delta: entry.Observations - count}
+		switch {
+		case !ok:
+			churn.Added = append(churn.Added, change)
+		case change.Delta != 0:
+			churn.Changed = append(churn.Changed, change)
+		default:
+			churn.Unchanged++
+		}
+	}
+	for _, entry := range previous {
+		if !seen[entry.MetricKey] {
+			churn.Removed = append(churn.Removed, keysChurnEntry{MetricKey: entry.MetricKey, Previous: entry.Observations, Delta: -entry.Observations})
+		}
+	}
+
+	byName := func(entries []keysChurnEntry) {
+		sort.Slice(entries, func(i, j int) bool { return entries[i].MetricKey < entries[j].MetricKey })
+	}
+	byName(churn.Added)
+	byName(churn.Removed)
+	sort.Slice(churn.Changed, func(i, j int) bool {
+		a, b := churn.Changed[i], churn.Changed[j]
+		if abs64(a.Delta) != abs64(b.Delta) {
+			return abs64(a.Delta) > abs64(b.Delta)
+		}
+		return a.MetricKey < b.MetricKey
+	})
+	return churn
+}
+
+func abs64(value int64) int64 {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
+
+// validateKeysCompare rejects the keys flags a comparison has no use for.
+func validateKeysCompare(compare *compareFlags, format string, quiet, withPaths bool, limit int) error {
+	if err := compare.validate(1, format, quiet); err != nil {
+		return err
+	}
+	if !compare.enabled() {
+		return nil
+	}
+	switch {
+	case withPaths:
+		return usageErrorf("--with-paths cannot be combined with --compare")
+	case limit != 0:
+		return usageErrorf("--limit cannot be combined with --compare")
+	}
+	return nil
+}
+
+// keysChurnPayload is the JSON output of a keys comparison.
+func keysChurnPayload(churn keysChurn, timeframe, compareTimeframe map[string]string) map[string]any {
+	return map[string]any{
+		"status":    "ok",
+		"timeframe": timeframe,
+		"compare":   compareTimeframe,
+		"added":     churn.Added,
+		"removed":   churn.Removed,
+		"changed":   churn.Changed,
+		"unchanged": churn.Unchanged,
+	}
+}
+
+// writeKeysChurn prints one table per category for table and markdown
+// output, and a single table with a change column for CSV.
+func writeKeysChurn(outputOpts *outputOptions, payload map[string]any, churn keysChurn, format string, csvOpts output.CSVOptions) error {
+	format = strings.ToLower(format)
+	columns := []string{"metric_key", "previous", "current", "delta"}
+	row := func(entry keysChurnEntry) []string {
+		return []string{entry.MetricKey, fmt.Sprint(entry.Previous), fmt.Sprint(entry.Current), fmt.Sprintf("%+d", entry.Delta)}
+	}
+
+	switch format {
+	case "csv":
+		table := output.Table{Columns: append([]string{"change"}, columns...)}
+		for _, category := range keysChurnCategories {
+			for _, entry := range churn.category(category) {
+				table.Rows = append(table.Rows, append([]string{category}, row(entry)...))
+			}
+		}
+		return writeCommandOutput(outputOpts, func(w io.Writer) error {
+			return output.PrintCSV(w, table, csvOpts)
+		})
+	case "table", "markdown":
+		return writeCommandOutput(outputOpts, func(w io.Writer) error {
+			for i, category := range keysChurnCategories {
+				entries := churn.category(category)
+				heading := fmt.Sprintf("%s (%d)", category, len(entries))
+				if format == "markdown" {
+					heading = "### " + heading
+				}
+				if i > 0 {
+					fmt.Fprintln(w)
+				}
+				fmt.Fprintln(w, heading)
+				if len(entries) == 0 {
+					continue
+				}
+				table := output.Table{Columns: columns}
+				for _, entry := range entries {
+					table.Rows = append(table.Rows, row(entry))
+				}
+				if format == "markdown" {
+					fmt.Fprintln(w)
+					if err := output.PrintMarkdown(w, table); err != nil {
+						return err
+					}
+					continue
+				}
+				output.PrintTable(w, table)
+			}
+			fmt.Fprintf(w, "\n%d keys unchanged\n", churn.Unchanged)
+			return nil
+		})
+	default:
+		return writeJSONOutput(outputOpts, payload)
+	}
+}
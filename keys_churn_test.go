@@ -0,0 +1,79 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompareKeysEntries(t *testing.T) {
+	t.Parallel()
+
+	previous := []keysEntry{
+		{MetricKey: "event::logs", Observations: 10},
+		{MetricKey: "event::signups", Observations: 4},
+		{MetricKey: "event::legacy", Observations: 2},
+		{MetricKey: "event::clicks", Observations: 5},
+	}
+	current := []keysEntry{
+		{MetricKey: "event::logs", Observations: 12},
+		{MetricKey: "event::signups", Observations: 1},
+		{MetricKey: "event::clicks", Observations: 5},
+		{MetricKey: "event::orders", Observations: 3},
+		{MetricKey: "event::api", Observations: 7},
+	}
+
+	got := compareKeysEntries(current, previous)
+	want := keysChurn{
+		Added: []keysChurnEntry{
+			{MetricKey: "event::api", Current: 7, Delta: 7},
+			{MetricKey: "event::orders", Current: 3, Delta: 3},
+		},
+		Removed: []keysChurnEntry{
+			{MetricKey: "event::legacy", Previous: 2, Delta: -2},
+		},
+		Changed: []keysChurnEntry{
+			{MetricKey: "event::signups", Previous: 4, Current: 1, Delta: -3},
+			{MetricKey: "event::logs", Previous: 10, Current: 12, Delta: 2},
+		},
+		Unchanged: 1,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("compareKeysEntries() = %+v, want %+v", got, want)
+	}
+
+	empty := compareKeysEntries(nil, nil)
+	if empty.Added == nil || empty.Removed == nil || empty.Changed == nil {
+		t.Fatalf("empty churn should hold empty lists for JSON output: %+v", empty)
+	}
+}
+
+func TestValidateKeysCompare(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		compare   compareFlags
+		withPaths bool
+		limit     int
+		quiet     bool
+		wantErr   bool
+	}{
+		{name: "disabled", compare: compareFlags{}, withPaths: true, limit: 5},
+		{name: "previous", compare: compareFlags{Mode: "previous"}},
+		{name: "custom window", compare: compareFlags{From: "-14d", To: "-7d"}},
+		{name: "unknown mode", compare: compareFlags{Mode: "yesterday"}, wantErr: true},
+		{name: "with paths", compare: compareFlags{Mode: "previous"}, withPaths: true, wantErr: true},
+		{name: "limit", compare: compareFlags{Mode: "previous"}, limit: 5, wantErr: true},
+		{name: "quiet", compare: compareFlags{Mode: "previous"}, quiet: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := validateKeysCompare(&tt.compare, "json", tt.quiet, tt.withPaths, tt.limit)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateKeysCompare() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
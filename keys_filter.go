@@ -0,0 +1,65 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// keysFilter narrows keys listings by name prefix and/or regexp.
+type keysFilter struct {
+	Prefix string
+	Match  string
+	re     *regexp.Regexp
+}
+
+// newKeysFilter compiles match up front so a bad pattern is rejected before
+// any query runs.
+func newKeysFilter(prefix, match string) (keysFilter, error) {
+	filter := keysFilter{Prefix: prefix, Match: strings.TrimSpace(match)}
+	if filter.Match != "" {
+		re, err := regexp.Compile(filter.Match)
+		if err != nil {
+			return keysFilter{}, usageErrorf("invalid match pattern %q: %v", filter.Match, err)
+		}
+		filter.re = re
+	}
+	return filter, nil
+}
+
+func (f keysFilter) active() bool {
+	return f.Prefix != "" || f.re != nil
+}
+
+func (f keysFilter) apply(entries []keysEntry) []keysEntry {
+	if !f.active() {
+		return entries
+	}
+	filtered := make([]keysEntry, 0, len(entries))
+	for _, entry := range entries {
+		if f.Prefix != "" && !strings.HasPrefix(entry.MetricKey, f.Prefix) {
+			continue
+		}
+		if f.re != nil && !f.re.MatchString(entry.MetricKey) {
+			continue
+		}
+		filtered = append(filtered, entry)
+	}
+	return filtered
+}
+
+// annotate records the applied filter and how many entries it kept next to
+// the existing total_paths count.
+func (f keysFilter) annotate(payload map[string]any, filtered int) {
+	if !f.active() {
+		return
+	}
+	applied := map[string]string{}
+	if f.Prefix != "" {
+		applied["prefix"] = f.Prefix
+	}
+	if f.Match != "" {
+		applied["match"] = f.Match
+	}
+	payload["filter"] = applied
+	payload["filtered_paths"] = filtered
+}
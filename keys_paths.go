@@ -0,0 +1,71 @@
+package main
+
+import (
+	"time"
+
+	triflestats "github.com/trifle-io/trifle_stats_go"
+)
+
+// keysPathsConcurrency caps the per-key sample fetches behind --with-paths.
+const keysPathsConcurrency = 4
+
+// lastSeenBuckets maps each key in a system series to the latest bucket it
+// was tracked in, so --with-paths can sample a single bucket that is known
+// to hold data.
+func lastSeenBuckets(at []time.Time, values []map[string]any) map[string]time.Time {
+	seen := map[string]time.Time{}
+	for i, row := range values {
+		if i >= len(at) {
+			break
+		}
+		keysMap, ok := row["keys"].(map[string]any)
+		if !ok {
+			continue
+		}
+		for key, count := range keysMap {
+			if toInt64(count) <= 0 {
+				continue
+			}
+			if last, ok := seen[key]; !ok || at[i].After(last) {
+				seen[key] = at[i]
+			}
+		}
+	}
+	return seen
+}
+
+// parseSeriesTimes parses the at list of an API series response.
+func parseSeriesTimes(values []string) ([]time.Time, error) {
+	at := make([]time.Time, len(values))
+	for i, value := range values {
+		parsed, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return nil, err
+		}
+		at[i] = parsed
+	}
+	return at, nil
+}
+
+// attachValuePaths fills ValuePaths for each entry using fetch, which
+// returns the rows of a key's series for one bucket.
+func attachValuePaths(entries []keysEntry, lastSeen map[string]time.Time, fetch func(key string, at time.Time) ([]map[string]any, error)) error {
+	paths, err := mapKeysConcurrently(keysEntryNames(entries), keysPathsConcurrency, func(key string, _ int) (any, error) {
+		at, ok := lastSeen[key]
+		if !ok {
+			return []string{}, nil
+		}
+		rows, err := fetch(key, at)
+		if err != nil {
+			return nil, err
+		}
+		return triflestats.Series{Values: rows}.AvailablePaths(), nil
+	})
+	if err != nil {
+		return err
+	}
+	for i := range entries {
+		entries[i].ValuePaths = paths[i].([]string)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLastSeenBuckets(t *testing.T) {
+	t.Parallel()
+
+	at := hourlyBuckets(3)
+	values := []map[string]any{
+		{"keys": map[string]any{"logs": 2, "signups": 1}},
+		{"keys": map[string]any{"logs": 1, "signups": 0}},
+		{},
+	}
+
+	got := lastSeenBuckets(at, values)
+	want := map[string]time.Time{"logs": at[1], "signups": at[0]}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("lastSeenBuckets = %v, want %v", got, want)
+	}
+}
+
+func TestAttachValuePaths(t *testing.T) {
+	t.Parallel()
+
+	at := hourlyBuckets(2)
+	entries := []keysEntry{{MetricKey: "logs"}, {MetricKey: "gone"}}
+	lastSeen := map[string]time.Time{"logs": at[1]}
+
+	var sampled []time.Time
+	err := attachValuePaths(entries, lastSeen, func(key string, bucket time.Time) ([]map[string]any, error) {
+		sampled = append(sampled, bucket)
+		return []map[string]any{{"count": 3, "duration": map[string]any{"sum": 1.5, "label": "slow"}}}, nil
+	})
+	if err != nil {
+		t.Fatalf("attachValuePaths error: %v", err)
+	}
+	if want := []string{"count", "duration.sum"}; !reflect.DeepEqual(entries[0].ValuePaths, want) {
+		t.Fatalf("paths = %v, want %v", entries[0].ValuePaths, want)
+	}
+	if len(entries[1].ValuePaths) != 0 {
+		t.Fatalf("unseen key paths = %v, want none", entries[1].ValuePaths)
+	}
+	if len(sampled) != 1 || !sampled[0].Equal(at[1]) {
+		t.Fatalf("sampled buckets = %v, want only %s", sampled, at[1])
+	}
+}
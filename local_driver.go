@@ -12,6 +12,7 @@ import (
 
 	"github.com/redis/go-redis/v9"
 	triflestats "github.com/trifle-io/trifle_stats_go"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
@@ -22,6 +23,8 @@ type localDriverRuntime struct {
 	DriverName string
 	TableName  string
 	setupFn    func() error
+	existsFn   func(ctx context.Context) (bool, error)
+	joined     triflestats.JoinedIdentifier
 }
 
 func (r *localDriverRuntime) Setup() error {
@@ -31,6 +34,27 @@ func (r *localDriverRuntime) Setup() error {
 	return r.setupFn()
 }
 
+// StorageExists reports whether the driver's table or collection is there.
+// Drivers with nothing to set up, like redis, always report true.
+func (r *localDriverRuntime) StorageExists(ctx context.Context) (bool, error) {
+	if r == nil || r.existsFn == nil {
+		return true, nil
+	}
+	return r.existsFn(ctx)
+}
+
+// sqlTableExists runs query, which selects a count for the table name
+// bound to its one parameter, and reports whether the count is positive.
+func sqlTableExists(db *sql.DB, query, table string) func(ctx context.Context) (bool, error) {
+	return func(ctx context.Context) (bool, error) {
+		var count int
+		if err := db.QueryRowContext(ctx, query, table).Scan(&count); err != nil {
+			return false, err
+		}
+		return count > 0, nil
+	}
+}
+
 func isLocalDriver(name string) bool {
 	switch strings.ToLower(strings.TrimSpace(name)) {
 	case "sqlite", "postgres", "mysql", "redis", "mongo", "mongodb":
@@ -49,6 +73,20 @@ func normalizeDriverName(name string) string {
 }
 
 func loadLocalConfig(opts *driverOptions) (*localDriverRuntime, error) {
+	runtime, err := prepareLocalConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := runtime.connect(opts); err != nil {
+		return nil, err
+	}
+	return runtime, nil
+}
+
+// prepareLocalConfig resolves the stats config for a local driver without
+// opening a connection, so commands can resolve timeframes and granularities
+// (and --explain them) before touching the database.
+func prepareLocalConfig(opts *driverOptions) (*localDriverRuntime, error) {
 	if opts == nil {
 		return nil, fmt.Errorf("driver options required")
 	}
@@ -57,6 +95,9 @@ func loadLocalConfig(opts *driverOptions) (*localDriverRuntime, error) {
 	if !isLocalDriver(driverName) {
 		return nil, fmt.Errorf("unsupported local driver: %s", opts.Driver)
 	}
+	if driverName == "sqlite" && strings.TrimSpace(opts.DBPath) == "" {
+		return nil, usageErrorf("--db is required for sqlite driver")
+	}
 
 	joined, err := parseJoinedIdentifier(opts.Joined)
 	if err != nil {
@@ -75,82 +116,93 @@ func loadLocalConfig(opts *driverOptions) (*localDriverRuntime, error) {
 	cfg.Granularities = parseGranularities(opts.Granularities)
 	applyBufferOptions(cfg, opts, driverName)
 
-	runtime := &localDriverRuntime{
+	return &localDriverRuntime{
 		Config:     cfg,
 		DriverName: driverName,
 		TableName:  strings.TrimSpace(opts.Table),
-	}
+		joined:     joined,
+	}, nil
+}
 
-	switch driverName {
+// connect opens the driver connection and attaches it to r.Config.
+func (r *localDriverRuntime) connect(opts *driverOptions) error {
+	cfg := r.Config
+	joined := r.joined
+
+	switch r.DriverName {
 	case "sqlite":
-		if strings.TrimSpace(opts.DBPath) == "" {
-			return nil, fmt.Errorf("--db is required for sqlite driver")
-		}
 		db, err := sql.Open("sqlite", opts.DBPath)
 		if err != nil {
-			return nil, err
+			return driverError(err)
 		}
 		driver := triflestats.NewSQLiteDriver(db, opts.Table, joined)
 		driver.Separator = opts.Separator
 		cfg.Driver = driver
-		runtime.setupFn = driver.Setup
-		runtime.TableName = driver.TableName
-		return runtime, nil
+		r.setupFn = driver.Setup
+		r.existsFn = sqlTableExists(db, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", driver.TableName)
+		r.TableName = driver.TableName
+		return nil
 
 	case "postgres":
 		dsn := buildPostgresDSN(opts)
 		db, err := sql.Open("pgx", dsn)
 		if err != nil {
-			return nil, err
+			return driverError(err)
 		}
 		driver := triflestats.NewPostgresDriver(db, opts.Table, joined)
 		driver.Separator = opts.Separator
 		cfg.Driver = driver
-		runtime.setupFn = driver.Setup
-		runtime.TableName = driver.TableName
-		return runtime, nil
+		r.setupFn = driver.Setup
+		r.existsFn = sqlTableExists(db, "SELECT COUNT(*) WHERE to_regclass($1) IS NOT NULL", driver.TableName)
+		r.TableName = driver.TableName
+		return nil
 
 	case "mysql":
 		dsn := buildMySQLDSN(opts)
 		db, err := sql.Open("mysql", dsn)
 		if err != nil {
-			return nil, err
+			return driverError(err)
 		}
 		driver := triflestats.NewMySQLDriver(db, opts.Table, joined)
 		driver.Separator = opts.Separator
 		cfg.Driver = driver
-		runtime.setupFn = driver.Setup
-		runtime.TableName = driver.TableName
-		return runtime, nil
+		r.setupFn = driver.Setup
+		r.existsFn = sqlTableExists(db, "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?", driver.TableName)
+		r.TableName = driver.TableName
+		return nil
 
 	case "redis":
 		client, err := buildRedisClient(opts)
 		if err != nil {
-			return nil, err
+			return driverError(err)
 		}
 		driver := triflestats.NewRedisDriver(client, strings.TrimSpace(opts.Prefix))
 		driver.Separator = opts.Separator
 		cfg.Driver = driver
-		runtime.TableName = strings.TrimSpace(opts.Prefix)
-		return runtime, nil
+		r.TableName = strings.TrimSpace(opts.Prefix)
+		return nil
 
 	case "mongo":
 		client, databaseName, collectionName, err := buildMongoCollection(opts)
 		if err != nil {
-			return nil, err
+			return driverError(err)
 		}
 		collection := client.Database(databaseName).Collection(collectionName)
 		driver := triflestats.NewMongoDriver(collection, joined)
 		driver.Separator = opts.Separator
 		cfg.Driver = driver
-		runtime.setupFn = func() error {
+		r.setupFn = func() error {
 			return driver.Setup(context.Background())
 		}
-		runtime.TableName = collectionName
-		return runtime, nil
+		r.existsFn = func(ctx context.Context) (bool, error) {
+			names, err := client.Database(databaseName).ListCollectionNames(ctx, bson.M{"name": collectionName})
+			return len(names) > 0, err
+		}
+		r.TableName = collectionName
+		return nil
 
 	default:
-		return nil, fmt.Errorf("unsupported local driver: %s", driverName)
+		return fmt.Errorf("unsupported local driver: %s", r.DriverName)
 	}
 }
 
@@ -250,7 +302,7 @@ func buildRedisClient(opts *driverOptions) (*redis.Client, error) {
 	}), nil
 }
 
-func buildMongoCollection(opts *driverOptions) (*mongo.Client, string, string, error) {
+func mongoURI(opts *driverOptions) string {
 	uri := strings.TrimSpace(opts.DSN)
 	if uri == "" {
 		uri = firstNonEmpty(opts.Host, "mongodb://127.0.0.1:27017")
@@ -258,6 +310,11 @@ func buildMongoCollection(opts *driverOptions) (*mongo.Client, string, string, e
 			uri = "mongodb://" + uri
 		}
 	}
+	return uri
+}
+
+func buildMongoCollection(opts *driverOptions) (*mongo.Client, string, string, error) {
+	uri := mongoURI(opts)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
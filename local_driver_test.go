@@ -153,6 +153,23 @@ func TestLoadLocalConfigSQLiteRequiresDB(t *testing.T) {
 	}
 }
 
+func TestPrepareLocalConfigDoesNotConnect(t *testing.T) {
+	t.Parallel()
+
+	local, err := prepareLocalConfig(&driverOptions{
+		Driver:          "mongo",
+		Host:            "mongodb://127.0.0.1:1",
+		Joined:          "full",
+		BeginningOfWeek: "monday",
+	})
+	if err != nil {
+		t.Fatalf("prepareLocalConfig returned error: %v", err)
+	}
+	if local.Config.Driver != nil {
+		t.Fatalf("Config.Driver = %T, want nil before connect", local.Config.Driver)
+	}
+}
+
 func TestLoadLocalConfigSQLiteMemory(t *testing.T) {
 	t.Parallel()
 
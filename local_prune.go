@@ -0,0 +1,450 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	triflestats "github.com/trifle-io/trifle_stats_go"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// pruneBatchSize bounds the identifiers sent in one delete statement.
+const pruneBatchSize = 500
+
+// pruneRange selects the buckets of one key and granularity whose start lies
+// in From..To.
+type pruneRange struct {
+	Key         string
+	Granularity string
+	From        time.Time
+	To          time.Time
+}
+
+func (r pruneRange) contains(at time.Time) bool {
+	return !at.Before(r.From) && !at.After(r.To)
+}
+
+// planPruneRanges returns one range per granularity, starting at the bucket
+// that holds from so a range never cuts a bucket in half at the start.
+func planPruneRanges(key string, from, to time.Time, granularities []string, cfg *triflestats.Config) ([]pruneRange, error) {
+	ranges := make([]pruneRange, 0, len(granularities))
+	for _, granularity := range granularities {
+		parser := triflestats.NewParser(granularity)
+		if !parser.Valid() {
+			return nil, fmt.Errorf("invalid granularity: %s", granularity)
+		}
+		start := triflestats.NewNocturnal(from, cfg).Floor(parser.Offset, parser.Unit)
+		ranges = append(ranges, pruneRange{Key: key, Granularity: granularity, From: start.UTC(), To: to.UTC()})
+	}
+	return ranges, nil
+}
+
+// metricsPruner counts and deletes stored buckets for a local driver.
+// forget takes r.Key out of the system key rows of r's buckets: the rows are
+// shared by every key, so the key's entry is removed and its observations
+// subtracted from the total in one atomic update per row.
+type metricsPruner interface {
+	count(ctx context.Context, r pruneRange) (int64, error)
+	delete(ctx context.Context, r pruneRange) (int64, error)
+	forget(ctx context.Context, r pruneRange) (int64, error)
+}
+
+// systemRange selects the system key rows for the buckets of r.
+func systemRange(r pruneRange) pruneRange {
+	r.Key = systemMetricsKey
+	return r
+}
+
+// newMetricsPruner returns the pruner for the connected driver in cfg.
+func newMetricsPruner(cfg *triflestats.Config) (metricsPruner, error) {
+	switch driver := cfg.Driver.(type) {
+	case *triflestats.SQLiteDriver:
+		return &sqlPruner{db: driver.DB, table: driver.TableName, dialect: "sqlite", joined: driver.JoinedIdentifier, separator: driver.Separator}, nil
+	case *triflestats.PostgresDriver:
+		return &sqlPruner{db: driver.DB, table: driver.TableName, dialect: "postgres", joined: driver.JoinedIdentifier, separator: driver.Separator}, nil
+	case *triflestats.MySQLDriver:
+		return &sqlPruner{db: driver.DB, table: driver.TableName, dialect: "mysql", joined: driver.JoinedIdentifier, separator: driver.Separator}, nil
+	case *triflestats.RedisDriver:
+		return &redisPruner{client: driver.Client, prefix: driver.Prefix, separator: driver.Separator}, nil
+	case *triflestats.MongoDriver:
+		return &mongoPruner{collection: driver.Collection, joined: driver.JoinedIdentifier, separator: driver.Separator}, nil
+	default:
+		return nil, fmt.Errorf("prune is not supported for driver %T", cfg.Driver)
+	}
+}
+
+// fullKeyPrefix is the joined identifier of a bucket without its timestamp,
+// followed by the separator: key::1h:: for key::1h::1760518800.
+func fullKeyPrefix(r pruneRange, prefix, separator string) string {
+	return triflestats.Key{Prefix: prefix, Key: r.Key, Granularity: r.Granularity}.Join(separator) + separator
+}
+
+// fullKeyInRange reports whether a joined identifier starting with keyPrefix
+// ends in a unix timestamp inside r.
+func fullKeyInRange(joined, keyPrefix string, r pruneRange) bool {
+	rest, ok := strings.CutPrefix(joined, keyPrefix)
+	if !ok {
+		return false
+	}
+	unix, err := strconv.ParseInt(rest, 10, 64)
+	if err != nil {
+		return false
+	}
+	return r.contains(time.Unix(unix, 0))
+}
+
+// sqlPruner prunes the sqlite, postgres, and mysql tables. Full identifiers
+// pack the timestamp into the key column, so those rows are matched by key
+// prefix and filtered here; partial and separated rows are matched on the
+// at column directly.
+type sqlPruner struct {
+	db        *sql.DB
+	table     string
+	dialect   string
+	joined    triflestats.JoinedIdentifier
+	separator string
+}
+
+func (p *sqlPruner) placeholder(n int) string {
+	if p.dialect == "postgres" {
+		return "$" + strconv.Itoa(n)
+	}
+	return "?"
+}
+
+func (p *sqlPruner) column(name string) string {
+	if p.dialect == "mysql" {
+		return "`" + name + "`"
+	}
+	return name
+}
+
+// atValue formats a bucket time the way the driver stores it: sqlite keeps
+// RFC3339 text, postgres and mysql native timestamps.
+func (p *sqlPruner) atValue(at time.Time) any {
+	if p.dialect == "sqlite" {
+		return at.UTC().Format(time.RFC3339)
+	}
+	return at.UTC()
+}
+
+// rangeCondition builds the WHERE clause for partial and separated rows,
+// numbering its placeholders from first.
+func (p *sqlPruner) rangeCondition(r pruneRange, first int) (string, []any) {
+	at := p.column("at")
+	if p.joined == triflestats.JoinedSeparated {
+		return fmt.Sprintf("%s = %s AND %s = %s AND %s >= %s AND %s <= %s",
+				p.column("key"), p.placeholder(first), p.column("granularity"), p.placeholder(first+1), at, p.placeholder(first+2), at, p.placeholder(first+3)),
+			[]any{r.Key, r.Granularity, p.atValue(r.From), p.atValue(r.To)}
+	}
+	partial := triflestats.Key{Key: r.Key, Granularity: r.Granularity}.PartialJoin(p.separator)
+	return fmt.Sprintf("%s = %s AND %s >= %s AND %s <= %s",
+			p.column("key"), p.placeholder(first), at, p.placeholder(first+1), at, p.placeholder(first+2)),
+		[]any{partial, p.atValue(r.From), p.atValue(r.To)}
+}
+
+// inCondition matches the rows whose key column is one of keys, numbering
+// its placeholders from first.
+func (p *sqlPruner) inCondition(keys []string, first int) (string, []any) {
+	placeholders := make([]string, len(keys))
+	args := make([]any, len(keys))
+	for i, key := range keys {
+		placeholders[i] = p.placeholder(first + i)
+		args[i] = key
+	}
+	return fmt.Sprintf("%s IN (%s)", p.column("key"), strings.Join(placeholders, ", ")), args
+}
+
+// fullKeys lists the full identifiers of r's buckets.
+func (p *sqlPruner) fullKeys(ctx context.Context, r pruneRange) ([]string, error) {
+	keyPrefix := fullKeyPrefix(r, "", p.separator)
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s LIKE %s ESCAPE '!'", p.column("key"), p.table, p.column("key"), p.placeholder(1))
+	rows, err := p.db.QueryContext(ctx, query, escapeLikePattern(keyPrefix)+"%")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		if fullKeyInRange(key, keyPrefix, r) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, rows.Err()
+}
+
+func (p *sqlPruner) count(ctx context.Context, r pruneRange) (int64, error) {
+	if p.joined == triflestats.JoinedFull {
+		keys, err := p.fullKeys(ctx, r)
+		return int64(len(keys)), err
+	}
+	condition, args := p.rangeCondition(r, 1)
+	var count int64
+	err := p.db.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s", p.table, condition), args...).Scan(&count)
+	return count, err
+}
+
+func (p *sqlPruner) delete(ctx context.Context, r pruneRange) (int64, error) {
+	if p.joined != triflestats.JoinedFull {
+		condition, args := p.rangeCondition(r, 1)
+		result, err := p.db.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE %s", p.table, condition), args...)
+		if err != nil {
+			return 0, err
+		}
+		return result.RowsAffected()
+	}
+
+	keys, err := p.fullKeys(ctx, r)
+	if err != nil {
+		return 0, err
+	}
+	var deleted int64
+	for start := 0; start < len(keys); start += pruneBatchSize {
+		condition, args := p.inCondition(keys[start:min(start+pruneBatchSize, len(keys))], 1)
+		query := fmt.Sprintf("DELETE FROM %s WHERE %s", p.table, condition)
+		result, err := p.db.ExecContext(ctx, query, args...)
+		if err != nil {
+			return deleted, err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return deleted, err
+		}
+		deleted += affected
+	}
+	return deleted, nil
+}
+
+// forgetUpdate builds the SET expression and guard condition that take key
+// out of a system row, with their placeholders numbered from 1. Rows hold a
+// key's observations under the packed field keys.<key>; sqlite also nests
+// later increments under keys, so it clears both and subtracts their sum.
+func (p *sqlPruner) forgetUpdate(key string) (string, string, []any) {
+	field := "keys." + key
+	switch p.dialect {
+	case "postgres":
+		return "(data - $1::text) || jsonb_build_object('count', COALESCE((data->>'count')::numeric, 0) - COALESCE((data->>$1::text)::numeric, 0))",
+			"jsonb_exists(data, $1::text)", []any{field}
+	case "mysql":
+		path := `$."` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(field) + `"`
+		return "JSON_SET(JSON_REMOVE(`data`, ?), '$.count', COALESCE(CAST(JSON_UNQUOTE(JSON_EXTRACT(`data`, '$.count')) AS DECIMAL(65,10)), 0) - COALESCE(CAST(JSON_UNQUOTE(JSON_EXTRACT(`data`, ?)) AS DECIMAL(65,10)), 0))",
+			"JSON_CONTAINS_PATH(`data`, 'one', ?)", []any{path, path, path}
+	default:
+		flat := `$."` + field + `"`
+		nested := `$.keys."` + key + `"`
+		return "json_set(json_remove(data, ?, ?), '$.count', IFNULL(json_extract(data, '$.count'), 0) - IFNULL(json_extract(data, ?), 0) - IFNULL(json_extract(data, ?), 0))",
+			"(json_extract(data, ?) IS NOT NULL OR json_extract(data, ?) IS NOT NULL)", []any{flat, nested, flat, nested, flat, nested}
+	}
+}
+
+func (p *sqlPruner) forget(ctx context.Context, r pruneRange) (int64, error) {
+	update, guard, args := p.forgetUpdate(r.Key)
+	first := len(args) + 1
+	if p.dialect == "postgres" {
+		first = 2
+	}
+	run := func(condition string, conditionArgs []any) (int64, error) {
+		query := fmt.Sprintf("UPDATE %s SET %s = %s WHERE %s AND %s", p.table, p.column("data"), update, guard, condition)
+		result, err := p.db.ExecContext(ctx, query, append(append([]any{}, args...), conditionArgs...)...)
+		if err != nil {
+			return 0, err
+		}
+		return result.RowsAffected()
+	}
+
+	rows := systemRange(r)
+	if p.joined != triflestats.JoinedFull {
+		return run(p.rangeCondition(rows, first))
+	}
+	keys, err := p.fullKeys(ctx, rows)
+	if err != nil {
+		return 0, err
+	}
+	var forgotten int64
+	for start := 0; start < len(keys); start += pruneBatchSize {
+		affected, err := run(p.inCondition(keys[start:min(start+pruneBatchSize, len(keys))], first))
+		forgotten += affected
+		if err != nil {
+			return forgotten, err
+		}
+	}
+	return forgotten, nil
+}
+
+// escapeLikePattern escapes LIKE wildcards with "!".
+func escapeLikePattern(value string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(value)
+}
+
+// redisPruner prunes redis hashes, one per bucket, found by a prefix scan.
+type redisPruner struct {
+	client    redis.UniversalClient
+	prefix    string
+	separator string
+}
+
+func (p *redisPruner) keys(ctx context.Context, r pruneRange) ([]string, error) {
+	keyPrefix := fullKeyPrefix(r, p.prefix, p.separator)
+	var keys []string
+	iter := p.client.Scan(ctx, 0, escapeGlobPattern(keyPrefix)+"*", 1000).Iterator()
+	for iter.Next(ctx) {
+		if fullKeyInRange(iter.Val(), keyPrefix, r) {
+			keys = append(keys, iter.Val())
+		}
+	}
+	return keys, iter.Err()
+}
+
+func (p *redisPruner) count(ctx context.Context, r pruneRange) (int64, error) {
+	keys, err := p.keys(ctx, r)
+	return int64(len(keys)), err
+}
+
+func (p *redisPruner) delete(ctx context.Context, r pruneRange) (int64, error) {
+	keys, err := p.keys(ctx, r)
+	if err != nil {
+		return 0, err
+	}
+	var deleted int64
+	for start := 0; start < len(keys); start += pruneBatchSize {
+		count, err := p.client.Del(ctx, keys[start:min(start+pruneBatchSize, len(keys))]...).Result()
+		if err != nil {
+			return deleted, err
+		}
+		deleted += count
+	}
+	return deleted, nil
+}
+
+// forgetScript removes a key's field from a system hash and subtracts its
+// value from count, atomically.
+var forgetScript = redis.NewScript(`
+local value = redis.call('HGET', KEYS[1], ARGV[1])
+if not value then
+	return 0
+end
+local n = tonumber(value)
+redis.call('HDEL', KEYS[1], ARGV[1])
+if n == math.floor(n) then
+	redis.call('HINCRBY', KEYS[1], 'count', -n)
+else
+	redis.call('HINCRBYFLOAT', KEYS[1], 'count', -n)
+end
+return 1
+`)
+
+func (p *redisPruner) forget(ctx context.Context, r pruneRange) (int64, error) {
+	keys, err := p.keys(ctx, systemRange(r))
+	if err != nil {
+		return 0, err
+	}
+	var forgotten int64
+	for _, key := range keys {
+		n, err := forgetScript.Run(ctx, p.client, []string{key}, "keys."+r.Key).Int64()
+		if err != nil {
+			return forgotten, err
+		}
+		forgotten += n
+	}
+	return forgotten, nil
+}
+
+// escapeGlobPattern escapes redis SCAN MATCH special characters.
+func escapeGlobPattern(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`).Replace(value)
+}
+
+// mongoPruner prunes mongo documents, matching full identifiers by key
+// prefix and the others on the at field.
+type mongoPruner struct {
+	collection *mongo.Collection
+	joined     triflestats.JoinedIdentifier
+	separator  string
+}
+
+func (p *mongoPruner) filter(ctx context.Context, r pruneRange) (bson.M, error) {
+	at := bson.M{"$gte": r.From, "$lte": r.To}
+	switch p.joined {
+	case triflestats.JoinedSeparated:
+		return bson.M{"key": r.Key, "granularity": r.Granularity, "at": at}, nil
+	case triflestats.JoinedPartial:
+		return bson.M{"key": triflestats.Key{Key: r.Key, Granularity: r.Granularity}.PartialJoin(p.separator), "at": at}, nil
+	}
+
+	keyPrefix := fullKeyPrefix(r, "", p.separator)
+	cursor, err := p.collection.Find(ctx, bson.M{"key": bson.M{"$regex": "^" + regexp.QuoteMeta(keyPrefix)}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	keys := []string{}
+	for cursor.Next(ctx) {
+		var doc struct {
+			Key string `bson:"key"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, err
+		}
+		if fullKeyInRange(doc.Key, keyPrefix, r) {
+			keys = append(keys, doc.Key)
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+	return bson.M{"key": bson.M{"$in": keys}}, nil
+}
+
+func (p *mongoPruner) count(ctx context.Context, r pruneRange) (int64, error) {
+	filter, err := p.filter(ctx, r)
+	if err != nil {
+		return 0, err
+	}
+	return p.collection.CountDocuments(ctx, filter)
+}
+
+func (p *mongoPruner) delete(ctx context.Context, r pruneRange) (int64, error) {
+	filter, err := p.filter(ctx, r)
+	if err != nil {
+		return 0, err
+	}
+	result, err := p.collection.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+func (p *mongoPruner) forget(ctx context.Context, r pruneRange) (int64, error) {
+	filter, err := p.filter(ctx, systemRange(r))
+	if err != nil {
+		return 0, err
+	}
+	field := "data.keys." + r.Key
+	filter[field] = bson.M{"$exists": true}
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"data.count": bson.M{"$subtract": bson.A{
+			bson.M{"$ifNull": bson.A{"$data.count", 0}},
+			bson.M{"$ifNull": bson.A{"$" + field, 0}},
+		}}}}},
+		{{Key: "$unset", Value: field}},
+	}
+	result, err := p.collection.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
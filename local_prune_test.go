@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	triflestats "github.com/trifle-io/trifle_stats_go"
+)
+
+func TestSQLitePrune(t *testing.T) {
+	t.Parallel()
+
+	for _, joined := range []string{"full", "partial", "separated"} {
+		joined := joined
+		t.Run(joined, func(t *testing.T) {
+			t.Parallel()
+
+			local, err := loadLocalConfig(&driverOptions{
+				Driver:          "sqlite",
+				DBPath:          filepath.Join(t.TempDir(), "stats.db"),
+				Table:           "metrics",
+				Joined:          joined,
+				Separator:       "::",
+				TimeZone:        "UTC",
+				BeginningOfWeek: "monday",
+				Granularities:   "1h,1d",
+				BufferMode:      "off",
+			})
+			if err != nil {
+				t.Fatalf("loadLocalConfig returned error: %v", err)
+			}
+			if err := local.Setup(); err != nil {
+				t.Fatalf("local.Setup returned error: %v", err)
+			}
+			cfg := local.Config
+
+			for _, at := range hourlyBuckets(4) {
+				for _, key := range []string{"event::logs", "event::logs::errors"} {
+					if err := triflestats.Track(cfg, key, at.Add(30*time.Minute), map[string]any{"count": 1}); err != nil {
+						t.Fatalf("Track returned error: %v", err)
+					}
+				}
+			}
+
+			pruner, err := newMetricsPruner(cfg)
+			if err != nil {
+				t.Fatalf("newMetricsPruner returned error: %v", err)
+			}
+			from := time.Date(2026, 10, 15, 10, 15, 0, 0, time.UTC)
+			to := time.Date(2026, 10, 15, 11, 59, 59, 0, time.UTC)
+			ranges, err := planPruneRanges("event::logs", from, to, []string{"1h"}, cfg)
+			if err != nil {
+				t.Fatalf("planPruneRanges returned error: %v", err)
+			}
+			if !ranges[0].From.Equal(time.Date(2026, 10, 15, 10, 0, 0, 0, time.UTC)) {
+				t.Fatalf("range from = %s, want the 10:00 bucket", ranges[0].From)
+			}
+
+			ctx := context.Background()
+			count, err := pruner.count(ctx, ranges[0])
+			if err != nil || count != 2 {
+				t.Fatalf("count = %d (err %v), want 2", count, err)
+			}
+			deleted, err := pruner.delete(ctx, ranges[0])
+			if err != nil || deleted != 2 {
+				t.Fatalf("deleted = %d (err %v), want 2", deleted, err)
+			}
+
+			series, err := triflestats.Values(cfg, "event::logs", hourlyBuckets(1)[0], hourlyBuckets(4)[3], "1h", false)
+			if err != nil {
+				t.Fatalf("Values returned error: %v", err)
+			}
+			for i, want := range []bool{true, false, false, true} {
+				if got := len(series.Values[i]) > 0; got != want {
+					t.Fatalf("bucket %s present = %v, want %v", series.At[i], got, want)
+				}
+			}
+
+			for _, check := range []struct {
+				key         string
+				granularity string
+				want        int64
+			}{
+				{key: "event::logs::errors", granularity: "1h", want: 2},
+				{key: "event::logs", granularity: "1d", want: 1},
+			} {
+				others, err := planPruneRanges(check.key, from, to, []string{check.granularity}, cfg)
+				if err != nil {
+					t.Fatalf("planPruneRanges returned error: %v", err)
+				}
+				count, err := pruner.count(ctx, others[0])
+				if err != nil || count != check.want {
+					t.Fatalf("%s %s count = %d (err %v), want %d untouched", check.key, check.granularity, count, err, check.want)
+				}
+			}
+		})
+	}
+}
+
+func TestFullKeyInRange(t *testing.T) {
+	t.Parallel()
+
+	r := pruneRange{
+		Key:         "event::logs",
+		Granularity: "1h",
+		From:        time.Unix(1792054800, 0),
+		To:          time.Unix(1792058400, 0),
+	}
+	prefix := fullKeyPrefix(r, "trfl", "::")
+	if prefix != "trfl::event::logs::1h::" {
+		t.Fatalf("prefix = %q", prefix)
+	}
+
+	tests := map[string]bool{
+		"trfl::event::logs::1h::1792054800":         true,
+		"trfl::event::logs::1h::1792058400":         true,
+		"trfl::event::logs::1h::1792062000":         false,
+		"trfl::event::logs::1h::errors::1792054800": false,
+		"trfl::event::logs::1d::1792054800":         false,
+	}
+	for key, want := range tests {
+		if got := fullKeyInRange(key, prefix, r); got != want {
+			t.Fatalf("fullKeyInRange(%q) = %v, want %v", key, got, want)
+		}
+	}
+}
@@ -7,13 +7,18 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
 	"path/filepath"
 	"regexp"
 	"runtime/debug"
 	"sort"
+	"strconv"
 	"strings"
+	"text/template"
 	"time"
+	"unicode/utf8"
 
 	"github.com/trifle-io/trifle-cli/internal/api"
 	"github.com/trifle-io/trifle-cli/internal/output"
@@ -34,10 +39,12 @@ func resolveVersion() string {
 
 var granularityPattern = regexp.MustCompile(`^\d+(s|m|h|d|w|mo|q|y)$`)
 
+var relativeTimePattern = regexp.MustCompile(`^(now)?(?:([+-])(\d+)(s|m|h|d|w|mo|q|y))?$`)
+
 func main() {
 	if len(os.Args) < 2 {
 		usage()
-		os.Exit(1)
+		os.Exit(exitUsage)
 	}
 
 	switch os.Args[1] {
@@ -58,7 +65,7 @@ func main() {
 	default:
 		fmt.Fprintf(os.Stderr, "unknown command: %s\n", os.Args[1])
 		usage()
-		os.Exit(1)
+		os.Exit(exitUsage)
 	}
 }
 
@@ -117,10 +124,209 @@ func newClient(opts *commonOptions) (*api.Client, error) {
 	return api.New(opts.BaseURL, opts.Token, opts.Timeout)
 }
 
+type outputOptions struct {
+	Path       string
+	Query      string
+	Compact    bool
+	Template   string
+	JSONErrors bool
+
+	fs   *flag.FlagSet
+	tmpl *template.Template
+}
+
+func addOutputFlags(fs *flag.FlagSet) *outputOptions {
+	opts := &outputOptions{fs: fs}
+	fs.StringVar(&opts.Path, "output", "", "Write the result to this file instead of stdout (- for stdout)")
+	fs.StringVar(&opts.Query, "query", "", "Print only this path of the JSON result (e.g. data.values[].count)")
+	fs.BoolVar(&opts.Compact, "compact", false, "Print JSON on a single line without indentation")
+	fs.StringVar(&opts.Template, "template", "", "Render the JSON result through a Go text/template (e.g. '{{.status}}')")
+	fs.BoolVar(&opts.JSONErrors, "json-errors", false, "Print errors to stderr as JSON (implied by an explicit --format json)")
+	return opts
+}
+
+// validate checks the output flags against the command's format before any
+// request is made, parsing --template so syntax errors surface early. An
+// empty format stands for commands that only print JSON. It also switches
+// error reporting to JSON when asked for.
+func (o *outputOptions) validate(format string) error {
+	format = strings.ToLower(strings.TrimSpace(format))
+	if o.JSONErrors || (format == "json" && o.flagPassed("format")) {
+		jsonErrors = true
+	}
+
+	if o.Query != "" && o.Template != "" {
+		return usageErrorf("--query and --template cannot be combined")
+	}
+	if format != "" && format != "json" {
+		if o.Query != "" {
+			return usageErrorf("--query requires --format json")
+		}
+		if o.Template != "" {
+			return usageErrorf("--template requires --format json")
+		}
+	}
+	if o.Template != "" {
+		tmpl, err := output.ParseTemplate(o.Template)
+		if err != nil {
+			return usageErrorf("parse template: %w", err)
+		}
+		o.tmpl = tmpl
+	}
+	return nil
+}
+
+func (o *outputOptions) flagPassed(name string) bool {
+	if o.fs == nil {
+		return false
+	}
+	passed := false
+	o.fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			passed = true
+		}
+	})
+	return passed
+}
+
+// writeCommandOutput hands write the command's destination: stdout when
+// --output is empty or "-", otherwise a temp file that replaces the target
+// once write succeeds. A failed write leaves any existing file untouched.
+func writeCommandOutput(opts *outputOptions, write func(w io.Writer) error) error {
+	path := opts.Path
+	if path == "" || path == "-" {
+		return write(os.Stdout)
+	}
+
+	file, err := output.CreateFile(path)
+	if err != nil {
+		return fmt.Errorf("create output %s: %w", path, err)
+	}
+	if err := write(file); err != nil {
+		file.Abort()
+		return err
+	}
+	if err := file.Commit(); err != nil {
+		return fmt.Errorf("write output %s: %w", path, err)
+	}
+	fmt.Fprintf(os.Stderr, "wrote %s\n", path)
+	return nil
+}
+
+func (o *outputOptions) printJSON(w io.Writer, value any) error {
+	if o.Compact {
+		return output.PrintCompactJSON(w, value)
+	}
+	return output.PrintJSON(w, value)
+}
+
+// writeJSONOutput prints value as JSON, through --template, or only the
+// --query selection. Selected scalars are printed raw.
+func writeJSONOutput(opts *outputOptions, value any) error {
+	if opts.tmpl != nil {
+		return writeCommandOutput(opts, func(w io.Writer) error {
+			if err := output.ExecuteTemplate(w, opts.tmpl, value); err != nil {
+				return fmt.Errorf("render template: %w", err)
+			}
+			return nil
+		})
+	}
+	if opts.Query == "" {
+		return writeCommandOutput(opts, func(w io.Writer) error { return opts.printJSON(w, value) })
+	}
+	selected, err := output.ExtractPath(value, opts.Query)
+	if err != nil {
+		return err
+	}
+	return writeCommandOutput(opts, func(w io.Writer) error {
+		if text, ok := output.FormatScalar(selected); ok {
+			_, err := fmt.Fprintln(w, text)
+			return err
+		}
+		return opts.printJSON(w, selected)
+	})
+}
+
+type tableFlags struct {
+	Precision    int
+	Transpose    bool
+	Limit        int
+	Tail         int
+	Columns      string
+	CSVDelimiter string
+}
+
+func addTableFlags(fs *flag.FlagSet) *tableFlags {
+	flags := &tableFlags{}
+	fs.IntVar(&flags.Precision, "precision", -1, "Round fractional numbers in table/csv/markdown cells to N decimals (-1 keeps full precision)")
+	fs.BoolVar(&flags.Transpose, "transpose", false, "Swap rows and columns in table/csv/markdown output (timestamps become columns)")
+	fs.IntVar(&flags.Limit, "limit", 0, "Print only the first N table/csv/markdown rows (0 prints all)")
+	fs.IntVar(&flags.Tail, "tail", 0, "Print only the last N table/csv/markdown rows (0 prints all)")
+	fs.StringVar(&flags.Columns, "columns", "", "Comma-separated table/csv/markdown columns to print, in order (e.g. at,count)")
+	addCSVDelimiterFlag(fs, &flags.CSVDelimiter)
+	return flags
+}
+
+func addCSVDelimiterFlag(fs *flag.FlagSet, target *string) {
+	fs.StringVar(target, "csv-delimiter", ",", `Field delimiter for --format csv: a single character, or \t for tab`)
+}
+
+// parseCSVDelimiter accepts a single character, or \t for tab. Characters the
+// CSV writer cannot use as a separator are rejected.
+func parseCSVDelimiter(value string) (output.CSVOptions, error) {
+	if value == "" {
+		return output.CSVOptions{}, nil
+	}
+	if value == `\t` {
+		value = "\t"
+	}
+	delimiter, size := utf8.DecodeRuneInString(value)
+	if size != len(value) || delimiter == utf8.RuneError || delimiter == '"' || delimiter == '\r' || delimiter == '\n' {
+		return output.CSVOptions{}, usageErrorf("--csv-delimiter must be a single character other than a quote or newline (got %q)", value)
+	}
+	return output.CSVOptions{Delimiter: delimiter}, nil
+}
+
+// options checks the table flags and combines them with the display
+// location. JSON output ignores them.
+func (t *tableFlags) options(displayLoc *time.Location) (output.TableOptions, error) {
+	if t.Limit < 0 || t.Tail < 0 {
+		return output.TableOptions{}, usageErrorf("--limit and --tail must be zero or positive")
+	}
+	if t.Limit > 0 && t.Tail > 0 {
+		return output.TableOptions{}, usageErrorf("--limit and --tail cannot be combined")
+	}
+	csvOpts, err := parseCSVDelimiter(t.CSVDelimiter)
+	if err != nil {
+		return output.TableOptions{}, err
+	}
+	return output.TableOptions{
+		DisplayLoc: displayLoc,
+		Precision:  t.Precision,
+		Transpose:  t.Transpose,
+		Limit:      t.Limit,
+		Tail:       t.Tail,
+		Columns:    normalizeStringList(strings.Split(t.Columns, ",")),
+		CSV:        csvOpts,
+	}, nil
+}
+
+func writeTableOrJSONOutput(opts *outputOptions, payload map[string]any, format string, tableOpts output.TableOptions) error {
+	switch format {
+	case "table", "csv", "markdown", "spark":
+		if opts.Query == "" && opts.tmpl == nil {
+			return writeCommandOutput(opts, func(w io.Writer) error {
+				return output.PrintTableOrJSON(w, payload, format, tableOpts)
+			})
+		}
+	}
+	return writeJSONOutput(opts, payload)
+}
+
 func runMetrics(args []string) {
 	if len(args) == 0 {
 		metricsUsage()
-		os.Exit(1)
+		os.Exit(exitUsage)
 	}
 
 	switch args[0] {
@@ -128,12 +334,38 @@ func runMetrics(args []string) {
 		metricsGet(args[1:])
 	case "keys":
 		metricsKeys(args[1:])
+	case "top":
+		metricsTop(args[1:])
 	case "aggregate":
 		metricsAggregate(args[1:])
 	case "timeline":
 		metricsTimeline(args[1:])
 	case "category":
 		metricsCategory(args[1:])
+	case "export":
+		metricsExport(args[1:])
+	case "import":
+		metricsImport(args[1:])
+	case "copy":
+		metricsCopy(args[1:])
+	case "prune":
+		metricsPrune(args[1:])
+	case "check":
+		metricsCheck(args[1:])
+	case "describe":
+		metricsDescribe(args[1:])
+	case "diff":
+		metricsDiff(args[1:])
+	case "histogram":
+		metricsHistogram(args[1:])
+	case "latest":
+		metricsLatest(args[1:])
+	case "validate":
+		metricsValidate(args[1:])
+	case "bench":
+		metricsBench(args[1:])
+	case "selftest":
+		metricsSelftest(args[1:])
 	case "push":
 		metricsPush(args[1:])
 	case "setup":
@@ -143,7 +375,7 @@ func runMetrics(args []string) {
 	default:
 		fmt.Fprintf(os.Stderr, "unknown metrics command: %s\n", args[0])
 		metricsUsage()
-		os.Exit(1)
+		os.Exit(exitUsage)
 	}
 }
 
@@ -159,33 +391,96 @@ func metricsGet(args []string) {
 	opts := addCommonFlags(fs, &rc.Source)
 	driverOpts := addDriverFlags(fs, &rc.Source)
 	key := fs.String("key", "", "Metrics key (optional)")
-	from := fs.String("from", "", "RFC3339 start timestamp")
-	to := fs.String("to", "", "RFC3339 end timestamp")
+	timeRange := addTimeRangeFlags(fs)
 	granularity := fs.String("granularity", "", "Granularity (e.g. 1h, 1d)")
-	skipBlanks := fs.Bool("skip-blanks", false, "Skip empty data points (local drivers)")
-	fs.Parse(args)
-
-	driverName := strings.ToLower(strings.TrimSpace(driverOpts.Driver))
-	if driverName == "" {
-		driverName = "api"
+	align := fs.Bool("align", false, "Snap --from/--to outward to whole granularity buckets")
+	skipBlanks := fs.Bool("skip-blanks", false, "Skip empty data points")
+	maxPoints := fs.Int("max-points", maxTimeframePoints, "Split ranges estimated above this many points into sequential queries (0 disables)")
+	chunkSize := fs.Int("chunk-size", 0, "Points per sub-query; always splits the range when set")
+	format := fs.String("format", "json", "Output format: json|ndjson|table|csv|markdown")
+	flush := fs.Bool("flush", false, "Flush each ndjson line as soon as it is written")
+	fill := fs.String("fill", "", "Fill empty buckets: zero|previous|linear")
+	fillEdges := fs.String("fill-edges", "none", "Leading/trailing gaps with --fill: none|zero|nearest")
+	resample := fs.String("resample", "", "Regroup fetched buckets into this coarser granularity client-side (e.g. 1d)")
+	resampleAgg := fs.String("resample-agg", "sum", "How --resample combines buckets: sum|mean|max|min|last")
+	allColumns := fs.Bool("all-columns", false, fmt.Sprintf("Show every value path in table output (default caps at %d)", maxTableColumns))
+	tableFlags := addTableFlags(fs)
+	explain := addExplainFlag(fs)
+	showStats := addStatsFlag(fs)
+	outputOpts := addOutputFlags(fs)
+	if err := parseKeyArgs(fs, args, key); err != nil {
+		exitError(err)
 	}
 
-	fromValue, toValue, err := resolveTimeRange(*from, *to)
+	// Validate the output flags first so --json-errors also covers a bad
+	// --format.
+	outputFormat := strings.ToLower(strings.TrimSpace(*format))
+	if err := outputOpts.validate(outputFormat); err != nil {
+		exitError(err)
+	}
+	switch outputFormat {
+	case "json", "ndjson", "table", "csv", "markdown":
+	default:
+		exitError(usageErrorf("unsupported format %q (use json, ndjson, table, csv, or markdown)", *format))
+	}
+	fillOpts, err := newFillOptions(*fill, *fillEdges)
+	if err != nil {
+		exitError(err)
+	}
+	resampleOpts, err := newResampleOptions(*resample, *resampleAgg)
+	if err != nil {
+		exitError(err)
+	}
+	if (fillOpts.enabled() || resampleOpts.enabled()) && outputFormat == "ndjson" {
+		exitError(usageErrorf("--fill and --resample need the whole series and cannot stream ndjson; use json, table, csv, or markdown"))
+	}
+	tableOpts, err := tableFlags.options(nil)
 	if err != nil {
 		exitError(err)
 	}
 
+	driverName := strings.ToLower(strings.TrimSpace(driverOpts.Driver))
+	if driverName == "" {
+		driverName = "api"
+	}
+	stats := newCommandStats(*showStats && !*explain, statsDriverName(driverName))
+	defer stats.finish()
+
 	if isLocalDriver(driverName) {
-		local, err := loadLocalConfig(driverOpts)
+		local, err := prepareLocalConfig(driverOpts)
 		if err != nil {
 			exitError(err)
 		}
 		cfg := local.Config
 
+		timeRange.Default = rc.Source.DefaultTimeframe
+		fromValue, toValue, err := resolveCommandTimeRange(timeRange, driverOpts)
+		if err != nil {
+			exitError(err)
+		}
+
 		granularityValue, err := resolveGranularityLocal(*granularity, cfg)
 		if err != nil {
 			exitError(err)
 		}
+		if resampleOpts.enabled() {
+			if err := resampleOpts.checkSource(granularityValue); err != nil {
+				exitError(err)
+			}
+		}
+
+		if *align {
+			fromValue, toValue, err = alignTimeframe(fromValue, toValue, granularityValue, driverOpts)
+			if err != nil {
+				exitError(err)
+			}
+		}
+		// Chunked fetches keep each query small, so the point warning only
+		// applies to a single query.
+		chunkPoints := resolveChunkPoints(*chunkSize, *maxPoints, fromValue, toValue, granularityValue)
+		if chunkPoints == 0 {
+			warnTimeframePoints(fromValue, toValue, granularityValue)
+		}
 
 		fromTime, err := time.Parse(time.RFC3339Nano, fromValue)
 		if err != nil {
@@ -197,12 +492,68 @@ func metricsGet(args []string) {
 		}
 
 		if *key == "" {
-			exitError(errors.New("--key is required for local drivers"))
+			exitError(usageErrorf("--key is required for local drivers"))
+		}
+
+		chunks := planChunks(fromTime, toTime, granularityValue, cfg, chunkPoints)
+		if *explain {
+			plan := localPlan("metrics get", local, driverOpts, map[string]any{
+				"key":         *key,
+				"from":        fromValue,
+				"to":          toValue,
+				"granularity": granularityValue,
+				"skip_blanks": *skipBlanks,
+				"chunks":      len(chunks),
+			})
+			if resampleOpts.enabled() {
+				plan["resample"] = resampleOpts.Granularity
+				plan["resample_agg"] = resampleOpts.Aggregator
+			}
+			if err := writeJSONOutput(outputOpts, plan); err != nil {
+				exitError(err)
+			}
+			return
+		}
+		if err := local.connect(driverOpts); err != nil {
+			exitError(err)
+		}
+		stats.granularity = granularityValue
+		fetch := func(from, to time.Time) (result triflestats.ValuesResult, err error) {
+			err = stats.timeQuery(func() error {
+				result, err = triflestats.Values(cfg, *key, from, to, granularityValue, *skipBlanks)
+				return err
+			})
+			return result, err
+		}
+
+		if outputFormat == "ndjson" {
+			err := writeCommandOutput(outputOpts, func(w io.Writer) error {
+				writer := output.NewNDJSONWriter(w, *flush)
+				err := streamChunked(chunks, fetch, stats.countPoints(ndjsonPoints(writer)))
+				if flushErr := writer.Flush(); err == nil {
+					err = flushErr
+				}
+				return err
+			})
+			if err != nil {
+				exitError(driverError(maybeSuggestSetup(err, local.DriverName, local.TableName)))
+			}
+			return
 		}
 
-		result, err := triflestats.Values(cfg, *key, fromTime, toTime, granularityValue, *skipBlanks)
+		result, err := fetchChunked(chunks, fetch)
 		if err != nil {
-			exitError(maybeSuggestSetup(err, local.DriverName, local.TableName))
+			exitError(driverError(maybeSuggestSetup(err, local.DriverName, local.TableName)))
+		}
+		stats.points = len(result.At)
+		result = fillResult(resampleResult(result, resampleOpts, cfg), fillOpts)
+		if outputFormat != "json" {
+			if err := writeCommandOutput(outputOpts, func(w io.Writer) error {
+				return printValuesTable(w, result, outputFormat, *allColumns, tableOpts)
+			}); err != nil {
+				exitError(err)
+			}
+			return
 		}
 
 		response := map[string]any{
@@ -211,25 +562,60 @@ func metricsGet(args []string) {
 				"values": result.Values,
 			},
 		}
-		if err := output.PrintJSON(os.Stdout, response); err != nil {
+		if *align || timeRange.Timeframe != "" || resampleOpts.enabled() {
+			response["timeframe"] = resampleOpts.timeframe(buildTimeframePayload(fromValue, toValue, granularityValue, timeRange.Timeframe), granularityValue)
+		}
+		if err := writeJSONOutput(outputOpts, response); err != nil {
 			exitError(err)
 		}
 		return
 	}
 
-	if err := ensureToken(opts, true); err != nil {
-		exitError(err)
+	// Explain never prompts; the source lookup below only needs a token
+	// when --timeframe or --granularity fall back to source defaults.
+	if !*explain {
+		if err := ensureToken(opts, true); err != nil {
+			exitError(err)
+		}
 	}
 
 	client, err := newClient(opts)
 	if err != nil {
 		exitError(err)
 	}
+	source := newSourceLookup(client)
+	stats.client = client
+
+	fromValue, toValue, err := resolveSourceTimeRange(context.Background(), source, timeRange, driverOpts)
+	if err != nil {
+		exitError(err)
+	}
 
-	granularityValue, err := resolveGranularityValue(context.Background(), client, *granularity)
+	granularityValue, err := resolveGranularityValue(context.Background(), source, *granularity)
 	if err != nil {
 		exitError(err)
 	}
+	var resampleCfg *triflestats.Config
+	if resampleOpts.enabled() {
+		if err := resampleOpts.checkSource(granularityValue); err != nil {
+			exitError(err)
+		}
+		if resampleCfg, err = bucketConfig(driverOpts); err != nil {
+			exitError(err)
+		}
+	}
+
+	if *align {
+		fromValue, toValue, err = alignTimeframe(fromValue, toValue, granularityValue, driverOpts)
+		if err != nil {
+			exitError(err)
+		}
+	}
+	chunkPoints := resolveChunkPoints(*chunkSize, *maxPoints, fromValue, toValue, granularityValue)
+	if chunkPoints == 0 {
+		warnTimeframePoints(fromValue, toValue, granularityValue)
+	}
+	stats.granularity = granularityValue
 
 	params := map[string]string{
 		"from":        fromValue,
@@ -243,16 +629,91 @@ func metricsGet(args []string) {
 		params["key"] = *key
 	}
 
+	if *explain {
+		request, err := explainGetMetrics(client, params, driverOpts.BeginningOfWeek)
+		if err != nil {
+			exitError(err)
+		}
+		plan := apiPlan("metrics get", opts, request)
+		if chunkPoints > 0 {
+			plan["chunk_points"] = chunkPoints
+		}
+		if err := writeJSONOutput(outputOpts, plan); err != nil {
+			exitError(err)
+		}
+		return
+	}
+
+	if outputFormat == "ndjson" {
+		err := writeCommandOutput(outputOpts, func(w io.Writer) error {
+			writer := output.NewNDJSONWriter(w, *flush)
+			err := streamMetricsChunked(context.Background(), client, params, driverOpts, chunkPoints, stats.countPoints(ndjsonPoints(writer)))
+			if flushErr := writer.Flush(); err == nil {
+				err = flushErr
+			}
+			return err
+		})
+		if err != nil {
+			exitError(err)
+		}
+		return
+	}
+
+	if outputFormat != "json" {
+		result, err := fetchMetricsChunked(context.Background(), client, params, driverOpts, chunkPoints)
+		if err != nil {
+			exitError(err)
+		}
+		stats.points = len(result.At)
+		result = fillResult(resampleResult(result, resampleOpts, resampleCfg), fillOpts)
+		if err := writeCommandOutput(outputOpts, func(w io.Writer) error {
+			return printValuesTable(w, result, outputFormat, *allColumns, tableOpts)
+		}); err != nil {
+			exitError(err)
+		}
+		return
+	}
+
 	var response map[string]any
-	if err := client.GetMetrics(context.Background(), params, &response); err != nil {
+	if chunkPoints > 0 || fillOpts.enabled() || resampleOpts.enabled() {
+		result, fields, err := fetchMetricsResponseChunked(context.Background(), client, params, driverOpts, chunkPoints)
+		if err != nil {
+			exitError(err)
+		}
+		result = fillResult(resampleResult(result, resampleOpts, resampleCfg), fillOpts)
+		response = fields
+		response["data"] = map[string]any{
+			"at":     result.At,
+			"values": result.Values,
+		}
+	} else if err := getMetrics(context.Background(), client, params, driverOpts.BeginningOfWeek, &response); err != nil {
 		exitError(err)
 	}
+	if *align || timeRange.Timeframe != "" || resampleOpts.enabled() {
+		response["timeframe"] = resampleOpts.timeframe(buildTimeframePayload(fromValue, toValue, granularityValue, timeRange.Timeframe), granularityValue)
+	}
+	stats.points = seriesPoints(response)
 
-	if err := output.PrintJSON(os.Stdout, response); err != nil {
+	if err := writeJSONOutput(outputOpts, response); err != nil {
 		exitError(err)
 	}
 }
 
+// printValuesTable renders a raw series as a table, CSV, or markdown. Tables
+// are capped at maxTableColumns value paths unless allColumns is set; CSV and
+// explicit --columns selections keep them all.
+func printValuesTable(w io.Writer, result triflestats.ValuesResult, format string, allColumns bool, tableOpts output.TableOptions) error {
+	maxColumns := maxTableColumns
+	if allColumns || format == "csv" || len(tableOpts.Columns) > 0 {
+		maxColumns = 0
+	}
+	table, hidden := buildValuesTable(result, maxColumns)
+	if hidden > 0 {
+		fmt.Fprintf(os.Stderr, "note: %d more value paths hidden; pass --all-columns to show them\n", hidden)
+	}
+	return output.PrintTableOrJSON(w, map[string]any{"table": table}, format, tableOpts)
+}
+
 func metricsKeys(args []string) {
 	rc, err := resolveCommandConfig(args)
 	if err != nil {
@@ -265,20 +726,67 @@ func metricsKeys(args []string) {
 	opts := addCommonFlags(fs, &rc.Source)
 	driverOpts := addDriverFlags(fs, &rc.Source)
 	key := fs.String("key", "", "Metrics key (local drivers default to system keys)")
-	from := fs.String("from", "", "RFC3339 start timestamp")
-	to := fs.String("to", "", "RFC3339 end timestamp")
+	timeRange := addTimeRangeFlags(fs)
 	granularity := fs.String("granularity", "", "Granularity (e.g. 1h, 1d)")
-	format := fs.String("format", "json", "Output format: json|table|csv")
+	format := fs.String("format", "json", "Output format: json|table|csv|markdown")
+	sortBy := fs.String("sort", "name", "Sort keys by: name|observations")
+	desc := fs.Bool("desc", false, "Sort in descending order")
+	limit := fs.Int("limit", 0, "Print at most this many keys (0 for all)")
+	prefix := fs.String("key-prefix", "", "Only list keys starting with this prefix (e.g. event::); not --prefix, which is the redis driver's key prefix")
+	match := fs.String("match", "", "Only list keys matching this Go regexp (e.g. '^event::logs::.*$')")
+	withPaths := fs.Bool("with-paths", false, "Sample each listed key's latest bucket and list its value paths")
+	skipBlanks := fs.Bool("skip-blanks", false, "Skip empty data points (local drivers always do)")
+	compare := addCompareFlags(fs)
+	var csvDelimiter string
+	addCSVDelimiterFlag(fs, &csvDelimiter)
+	displayTZ := addDisplayTimeZoneFlag(fs, &rc.Source)
+	explain := addExplainFlag(fs)
+	showStats := addStatsFlag(fs)
+	quiet := addQuietFlag(fs)
+	outputOpts := addOutputFlags(fs)
 	fs.Parse(args)
 
+	if err := outputOpts.validate(*format); err != nil {
+		exitError(err)
+	}
+	if *quiet {
+		if err := validateQuiet(outputOpts, strings.ToLower(*format)); err != nil {
+			exitError(err)
+		}
+	}
+	if err := validateKeysSelection(*sortBy, *limit); err != nil {
+		exitError(err)
+	}
+	if err := validateKeysCompare(compare, strings.ToLower(*format), *quiet, *withPaths, *limit); err != nil {
+		exitError(err)
+	}
+	filter, err := newKeysFilter(*prefix, *match)
+	if err != nil {
+		exitError(err)
+	}
+	csvOpts, err := parseCSVDelimiter(csvDelimiter)
+	if err != nil {
+		exitError(err)
+	}
+
+	// Keys tables have no timestamp column; the zone is still validated so
+	// shared configs fail the same way everywhere.
+	if _, err := resolveDisplayLocation(*displayTZ); err != nil {
+		exitError(err)
+	}
+
+	stats := newCommandStats(*showStats && !*explain, statsDriverName(driverOpts.Driver))
+	defer stats.finish()
+
 	if isLocalDriver(driverOpts.Driver) {
-		local, err := loadLocalConfig(driverOpts)
+		local, err := prepareLocalConfig(driverOpts)
 		if err != nil {
 			exitError(err)
 		}
 		cfg := local.Config
 
-		fromValue, toValue, err := resolveTimeRange(*from, *to)
+		timeRange.Default = rc.Source.DefaultTimeframe
+		fromValue, toValue, err := resolveCommandTimeRange(timeRange, driverOpts)
 		if err != nil {
 			exitError(err)
 		}
@@ -287,6 +795,8 @@ func metricsKeys(args []string) {
 		if err != nil {
 			exitError(err)
 		}
+		warnTimeframePoints(fromValue, toValue, granularityValue)
+		stats.granularity = granularityValue
 
 		fromTime, err := time.Parse(time.RFC3339Nano, fromValue)
 		if err != nil {
@@ -301,17 +811,97 @@ func metricsKeys(args []string) {
 		if metricKey == "" {
 			metricKey = systemMetricsKey
 		}
+		if *withPaths && metricKey != systemMetricsKey {
+			exitError(usageErrorf("--with-paths cannot be combined with --key"))
+		}
+		var compareFrom, compareTo string
+		if compare.enabled() {
+			compareFrom, compareTo, err = compare.window(fromValue, toValue, granularityValue, driverOpts)
+			if err != nil {
+				exitError(err)
+			}
+		}
+		if *explain {
+			request := map[string]any{
+				"key":         metricKey,
+				"from":        fromValue,
+				"to":          toValue,
+				"granularity": granularityValue,
+				"with_paths":  *withPaths,
+			}
+			if compare.enabled() {
+				request["compare_from"] = compareFrom
+				request["compare_to"] = compareTo
+			}
+			plan := localPlan("metrics keys", local, driverOpts, request)
+			if err := writeJSONOutput(outputOpts, plan); err != nil {
+				exitError(err)
+			}
+			return
+		}
+		if err := local.connect(driverOpts); err != nil {
+			exitError(err)
+		}
 
-		result, err := triflestats.Values(cfg, metricKey, fromTime, toTime, granularityValue, true)
+		var result triflestats.ValuesResult
+		err = stats.timeQuery(func() (err error) {
+			result, err = triflestats.Values(cfg, metricKey, fromTime, toTime, granularityValue, true)
+			return err
+		})
 		if err != nil {
-			exitError(maybeSuggestSetup(err, local.DriverName, local.TableName))
+			exitError(driverError(maybeSuggestSetup(err, local.DriverName, local.TableName)))
 		}
+		stats.points = len(result.At)
 
-		var entries []keysEntry
+		summarize := summarizeValuePaths
 		if metricKey == systemMetricsKey {
-			entries = summarizeSystemKeys(result.Values)
-		} else {
-			entries = summarizeValuePaths(result.Values)
+			summarize = summarizeSystemKeys
+		}
+		entries := summarize(result.Values)
+		if compare.enabled() {
+			compareFromTime, err := time.Parse(time.RFC3339Nano, compareFrom)
+			if err != nil {
+				exitError(err)
+			}
+			compareToTime, err := time.Parse(time.RFC3339Nano, compareTo)
+			if err != nil {
+				exitError(err)
+			}
+			var previous triflestats.ValuesResult
+			err = stats.timeQuery(func() (err error) {
+				previous, err = triflestats.Values(cfg, metricKey, compareFromTime, compareToTime, granularityValue, true)
+				return err
+			})
+			if err != nil {
+				exitError(driverError(maybeSuggestSetup(err, local.DriverName, local.TableName)))
+			}
+			stats.points += len(previous.At)
+
+			churn := compareKeysEntries(filter.apply(entries), filter.apply(summarize(previous.Values)))
+			payload := keysChurnPayload(churn,
+				map[string]string{"from": fromValue, "to": toValue, "granularity": granularityValue},
+				map[string]string{"from": compareFrom, "to": compareTo, "granularity": granularityValue})
+			if err := writeKeysChurn(outputOpts, payload, churn, *format, csvOpts); err != nil {
+				exitError(err)
+			}
+			return
+		}
+		filtered := filter.apply(entries)
+		selected := selectKeysEntries(filtered, *sortBy, *desc, *limit)
+		if *withPaths && !*quiet {
+			lastSeen := lastSeenBuckets(result.At, result.Values)
+			err := stats.timeQuery(func() error {
+				return attachValuePaths(selected, lastSeen, func(key string, at time.Time) ([]map[string]any, error) {
+					sample, err := triflestats.Values(cfg, key, at, at, granularityValue, true)
+					if err != nil {
+						return nil, driverError(err)
+					}
+					return sample.Values, nil
+				})
+			})
+			if err != nil {
+				exitError(err)
+			}
 		}
 		payload := map[string]any{
 			"status": "ok",
@@ -320,86 +910,192 @@ func metricsKeys(args []string) {
 				"to":          toValue,
 				"granularity": granularityValue,
 			},
-			"paths":       entries,
-			"total_paths": len(entries),
+			"paths":          selected,
+			"total_paths":    len(entries),
+			"returned_paths": len(selected),
 		}
+		filter.annotate(payload, len(filtered))
 
-		switch strings.ToLower(*format) {
-		case "table", "csv":
-			table := output.Table{Columns: []string{"metric_key", "observations"}}
-			for _, entry := range entries {
-				table.Rows = append(table.Rows, []string{entry.MetricKey, fmt.Sprint(entry.Observations)})
-			}
-			if *format == "table" {
-				output.PrintTable(os.Stdout, table)
-			} else if err := output.PrintCSV(os.Stdout, table); err != nil {
-				exitError(err)
-			}
-		default:
-			if err := output.PrintJSON(os.Stdout, payload); err != nil {
+		if *quiet {
+			if err := writeQuietLines(outputOpts, keysEntryNames(selected)); err != nil {
 				exitError(err)
 			}
+			return
+		}
+		if err := writeKeysOutput(outputOpts, payload, selected, *format, *withPaths, csvOpts); err != nil {
+			exitError(err)
 		}
 		return
 	}
 
-	if err := ensureToken(opts, true); err != nil {
-		exitError(err)
+	if !*explain {
+		if err := ensureToken(opts, true); err != nil {
+			exitError(err)
+		}
 	}
 
-	fromValue, toValue, err := resolveTimeRange(*from, *to)
+	client, err := newClient(opts)
 	if err != nil {
 		exitError(err)
 	}
+	source := newSourceLookup(client)
+	stats.client = client
 
-	client, err := newClient(opts)
+	fromValue, toValue, err := resolveSourceTimeRange(context.Background(), source, timeRange, driverOpts)
 	if err != nil {
 		exitError(err)
 	}
 
-	granularityValue, err := resolveGranularityValue(context.Background(), client, *granularity)
+	granularityValue, err := resolveGranularityValue(context.Background(), source, *granularity)
 	if err != nil {
 		exitError(err)
 	}
+	warnTimeframePoints(fromValue, toValue, granularityValue)
+	stats.granularity = granularityValue
 
 	params := map[string]string{
 		"from":        fromValue,
 		"to":          toValue,
 		"granularity": granularityValue,
 	}
+	var compareParams map[string]string
+	if compare.enabled() {
+		compareFrom, compareTo, err := compare.window(fromValue, toValue, granularityValue, driverOpts)
+		if err != nil {
+			exitError(err)
+		}
+		compareParams = map[string]string{
+			"from":        compareFrom,
+			"to":          compareTo,
+			"granularity": granularityValue,
+		}
+	}
+	if *skipBlanks {
+		params["skip_blanks"] = "true"
+		if compareParams != nil {
+			compareParams["skip_blanks"] = "true"
+		}
+	}
+
+	if *explain {
+		request, err := explainGetMetrics(client, params, driverOpts.BeginningOfWeek)
+		if err != nil {
+			exitError(err)
+		}
+		plan := apiPlan("metrics keys", opts, request)
+		if compareParams != nil {
+			compareRequest, err := explainGetMetrics(client, compareParams, driverOpts.BeginningOfWeek)
+			if err != nil {
+				exitError(err)
+			}
+			plan["compare"] = apiPlan("metrics keys", opts, compareRequest)
+		}
+		if err := writeJSONOutput(outputOpts, plan); err != nil {
+			exitError(err)
+		}
+		return
+	}
 
 	var response metricsResponse
-	if err := client.GetMetrics(context.Background(), params, &response); err != nil {
+	if err := getMetrics(context.Background(), client, params, driverOpts.BeginningOfWeek, &response); err != nil {
 		exitError(err)
 	}
+	stats.points = len(response.Data.At)
 
 	entries := summarizeKeys(response.Data.Values)
-	payload := map[string]any{
-		"status": "ok",
-		"timeframe": map[string]string{
-			"from":        fromValue,
+	if compareParams != nil {
+		var previous metricsResponse
+		if err := getMetrics(context.Background(), client, compareParams, driverOpts.BeginningOfWeek, &previous); err != nil {
+			exitError(err)
+		}
+		stats.points += len(previous.Data.At)
+
+		churn := compareKeysEntries(filter.apply(entries), filter.apply(summarizeKeys(previous.Data.Values)))
+		payload := keysChurnPayload(churn,
+			map[string]string{"from": fromValue, "to": toValue, "granularity": granularityValue},
+			map[string]string{"from": compareParams["from"], "to": compareParams["to"], "granularity": granularityValue})
+		if err := writeKeysChurn(outputOpts, payload, churn, *format, csvOpts); err != nil {
+			exitError(err)
+		}
+		return
+	}
+	filtered := filter.apply(entries)
+	selected := selectKeysEntries(filtered, *sortBy, *desc, *limit)
+	if *withPaths && !*quiet {
+		at, err := parseSeriesTimes(response.Data.At)
+		if err != nil {
+			exitError(err)
+		}
+		err = attachValuePaths(selected, lastSeenBuckets(at, response.Data.Values), func(key string, at time.Time) ([]map[string]any, error) {
+			bucket := at.UTC().Format(time.RFC3339)
+			sampleParams := map[string]string{
+				"key":         key,
+				"from":        bucket,
+				"to":          bucket,
+				"granularity": granularityValue,
+			}
+			var sample metricsResponse
+			if err := getMetrics(context.Background(), client, sampleParams, driverOpts.BeginningOfWeek, &sample); err != nil {
+				return nil, err
+			}
+			return sample.Data.Values, nil
+		})
+		if err != nil {
+			exitError(err)
+		}
+	}
+	payload := map[string]any{
+		"status": "ok",
+		"timeframe": map[string]string{
+			"from":        fromValue,
 			"to":          toValue,
 			"granularity": granularityValue,
 		},
-		"paths":       entries,
-		"total_paths": len(entries),
+		"paths":          selected,
+		"total_paths":    len(entries),
+		"returned_paths": len(selected),
+	}
+	filter.annotate(payload, len(filtered))
+
+	if *quiet {
+		if err := writeQuietLines(outputOpts, keysEntryNames(selected)); err != nil {
+			exitError(err)
+		}
+		return
+	}
+	if err := writeKeysOutput(outputOpts, payload, selected, *format, *withPaths, csvOpts); err != nil {
+		exitError(err)
 	}
+}
 
-	switch strings.ToLower(*format) {
-	case "table", "csv":
+func writeKeysOutput(outputOpts *outputOptions, payload map[string]any, entries []keysEntry, format string, withPaths bool, csvOpts output.CSVOptions) error {
+	format = strings.ToLower(format)
+	switch format {
+	case "table", "csv", "markdown":
 		table := output.Table{Columns: []string{"metric_key", "observations"}}
-		for _, entry := range entries {
-			table.Rows = append(table.Rows, []string{entry.MetricKey, fmt.Sprint(entry.Observations)})
+		if withPaths {
+			table.Columns = append(table.Columns, "paths")
 		}
-		if *format == "table" {
-			output.PrintTable(os.Stdout, table)
-		} else if err := output.PrintCSV(os.Stdout, table); err != nil {
-			exitError(err)
+		for _, entry := range entries {
+			row := []string{entry.MetricKey, fmt.Sprint(entry.Observations)}
+			if withPaths {
+				row = append(row, strings.Join(entry.ValuePaths, ","))
+			}
+			table.Rows = append(table.Rows, row)
 		}
+		return writeCommandOutput(outputOpts, func(w io.Writer) error {
+			switch format {
+			case "table":
+				output.PrintTable(w, table)
+				return nil
+			case "markdown":
+				return output.PrintMarkdown(w, table)
+			default:
+				return output.PrintCSV(w, table, csvOpts)
+			}
+		})
 	default:
-		if err := output.PrintJSON(os.Stdout, payload); err != nil {
-			exitError(err)
-		}
+		return writeJSONOutput(outputOpts, payload)
 	}
 }
 
@@ -415,30 +1111,148 @@ func metricsAggregate(args []string) {
 	opts := addCommonFlags(fs, &rc.Source)
 	driverOpts := addDriverFlags(fs, &rc.Source)
 	key := fs.String("key", "", "Metrics key")
-	valuePath := fs.String("value-path", "", "Value path")
-	aggregator := fs.String("aggregator", "", "Aggregator (sum|mean|min|max)")
-	from := fs.String("from", "", "RFC3339 start timestamp")
-	to := fs.String("to", "", "RFC3339 end timestamp")
+	var valuePaths valuePathsFlag
+	fs.Var(&valuePaths, "value-path", "Value path (repeatable to aggregate several; a single path may use * segments on local drivers, e.g. duration.*.p95)")
+	exprFlag := fs.String("expr", "", "Aggregate the paths an arithmetic expression reads, then evaluate it per slice (e.g. 'status.error / (status.ok + status.error)')")
+	aggregator := fs.String("aggregator", "", "Aggregator (sum|mean|min|max|stddev|variance|p50|p90|p95|p99; stddev and variance are population statistics)")
+	timeRange := addTimeRangeFlags(fs)
 	granularity := fs.String("granularity", "", "Granularity (e.g. 1h, 1d)")
-	slices := fs.Int("slices", 1, "Optional number of slices")
-	format := fs.String("format", "json", "Output format: json|table|csv")
-	fs.Parse(args)
+	align := fs.Bool("align", false, "Snap --from/--to outward to whole granularity buckets")
+	excludePartial := fs.Bool("exclude-partial", false, "Drop the final bucket when it is still in progress")
+	fs.BoolVar(excludePartial, "until-complete", false, "Alias for --exclude-partial")
+	slices := addSlicesFlag(fs, &rc.Source)
+	format := fs.String("format", "json", "Output format: json|table|csv|markdown|prom|summary")
+	metricName := fs.String("metric-name", defaultPromMetricName, "Metric name for --format prom")
+	keyPrefix := fs.String("key-prefix", "", "Aggregate every key starting with this prefix instead of --key, then combine them with --combine")
+	combine := fs.String("combine", "", "Combine per-key values across --key-prefix keys: sum|mean|min|max")
+	maxKeys := fs.Int("max-keys", defaultMaxCombineKeys, "Refuse --key-prefix when more keys match (0 for no limit)")
+	concurrency := fs.Int("concurrency", defaultTopConcurrency, "Number of --key-prefix keys to query at once")
+	compare := addCompareFlags(fs)
+	displayTZ := addDisplayTimeZoneFlag(fs, &rc.Source)
+	tableFlags := addTableFlags(fs)
+	explain := addExplainFlag(fs)
+	showStats := addStatsFlag(fs)
+	quiet := addQuietFlag(fs)
+	outputOpts := addOutputFlags(fs)
+	if err := parseKeyArgs(fs, args, key); err != nil {
+		exitError(err)
+	}
 
-	if isLocalDriver(driverOpts.Driver) {
-		if *key == "" || *valuePath == "" || *aggregator == "" {
-			exitError(errors.New("--key, --value-path, and --aggregator are required"))
+	valuePath := valuePaths.first()
+	paths := []string(valuePaths)
+	multiplePaths := len(valuePaths) > 1
+	if err := outputOpts.validate(*format); err != nil {
+		exitError(err)
+	}
+	var expr *metricExpr
+	if strings.TrimSpace(*exprFlag) != "" {
+		if len(valuePaths) > 0 {
+			exitError(usageErrorf("--expr cannot be combined with --value-path"))
 		}
-		if err := ensureNoWildcards(*valuePath); err != nil {
+		if expr, err = parseMetricExpr(*exprFlag); err != nil {
+			exitError(usageErrorf("invalid --expr: %v", err))
+		}
+		if len(expr.Paths()) == 0 {
+			exitError(usageErrorf("--expr must read at least one value path"))
+		}
+		paths = expr.Paths()
+		multiplePaths = true
+	}
+	if multiplePaths {
+		if err := validateMultiplePaths(paths, compare.enabled(), *quiet); err != nil {
+			exitError(err)
+		}
+	}
+	if err := compare.validate(*slices, strings.ToLower(*format), *quiet); err != nil {
+		exitError(err)
+	}
+	if *quiet {
+		if err := validateQuiet(outputOpts, strings.ToLower(*format)); err != nil {
+			exitError(err)
+		}
+	}
+
+	displayLoc, err := resolveDisplayLocation(*displayTZ)
+	if err != nil {
+		exitError(err)
+	}
+	tableOpts, err := tableFlags.options(displayLoc)
+	if err != nil {
+		exitError(err)
+	}
+
+	stats := newCommandStats(*showStats && !*explain, statsDriverName(driverOpts.Driver))
+	defer stats.finish()
+
+	if *keyPrefix != "" {
+		combineName, err := validateCombine(*combine)
+		if err != nil {
 			exitError(err)
 		}
+		switch {
+		case *key != "":
+			exitError(usageErrorf("--key-prefix cannot be combined with --key"))
+		case valuePath == "" || *aggregator == "":
+			exitError(usageErrorf("--value-path and --aggregator are required"))
+		case multiplePaths || expr != nil:
+			exitError(usageErrorf("--key-prefix takes a single --value-path"))
+		case compare.enabled():
+			exitError(usageErrorf("--compare cannot be combined with --key-prefix"))
+		case *slices != 1:
+			exitError(usageErrorf("--slices cannot be combined with --key-prefix"))
+		case *maxKeys < 0:
+			exitError(usageErrorf("--max-keys must be 0 or greater"))
+		case *concurrency < 1:
+			exitError(usageErrorf("--concurrency must be at least 1"))
+		}
+		if err := ensureNoWildcards(valuePath); err != nil {
+			exitError(usageErrorf("%v", err))
+		}
+		outputFormat := strings.ToLower(*format)
+		if outputFormat == "prom" || outputFormat == "summary" {
+			exitError(usageErrorf("--format %s is not supported with --key-prefix (use json, table, csv, or markdown)", outputFormat))
+		}
+		multi := &multiKeyAggregate{
+			opts:           opts,
+			driverOpts:     driverOpts,
+			timeRange:      timeRange,
+			defaultRange:   rc.Source.DefaultTimeframe,
+			granularity:    *granularity,
+			align:          *align,
+			excludePartial: *excludePartial,
+			keyPrefix:      *keyPrefix,
+			valuePath:      valuePath,
+			aggregator:     strings.ToLower(strings.TrimSpace(*aggregator)),
+			combine:        combineName,
+			maxKeys:        *maxKeys,
+			concurrency:    *concurrency,
+			format:         outputFormat,
+			quiet:          *quiet,
+			explain:        *explain,
+			outputOpts:     outputOpts,
+			tableOpts:      tableOpts,
+			stats:          stats,
+		}
+		multi.run()
+		return
+	}
+	if *combine != "" {
+		exitError(usageErrorf("--combine needs --key-prefix"))
+	}
+
+	if isLocalDriver(driverOpts.Driver) {
+		if *key == "" || (valuePath == "" && expr == nil) || *aggregator == "" {
+			exitError(usageErrorf("--key, --value-path (or --expr), and --aggregator are required"))
+		}
 
-		local, err := loadLocalConfig(driverOpts)
+		local, err := prepareLocalConfig(driverOpts)
 		if err != nil {
 			exitError(err)
 		}
 		cfg := local.Config
 
-		fromValue, toValue, err := resolveTimeRange(*from, *to)
+		timeRange.Default = rc.Source.DefaultTimeframe
+		fromValue, toValue, err := resolveCommandTimeRange(timeRange, driverOpts)
 		if err != nil {
 			exitError(err)
 		}
@@ -448,6 +1262,25 @@ func metricsAggregate(args []string) {
 			exitError(err)
 		}
 
+		if *align {
+			fromValue, toValue, err = alignTimeframe(fromValue, toValue, granularityValue, driverOpts)
+			if err != nil {
+				exitError(err)
+			}
+		}
+		partialExcluded := false
+		if *excludePartial {
+			toValue, partialExcluded, err = excludePartialBucket(fromValue, toValue, granularityValue, driverOpts)
+			if err != nil {
+				exitError(err)
+			}
+		}
+		warnTimeframePoints(fromValue, toValue, granularityValue)
+		if err := validateSlices(*slices, fromValue, toValue, granularityValue); err != nil {
+			exitError(err)
+		}
+		stats.granularity = granularityValue
+
 		fromTime, err := time.Parse(time.RFC3339Nano, fromValue)
 		if err != nil {
 			exitError(err)
@@ -457,107 +1290,463 @@ func metricsAggregate(args []string) {
 			exitError(err)
 		}
 
-		seriesResult, err := triflestats.Values(cfg, *key, fromTime, toTime, granularityValue, false)
+		var compareFrom, compareTo string
+		if compare.enabled() {
+			compareFrom, compareTo, err = compare.window(fromValue, toValue, granularityValue, driverOpts)
+			if err != nil {
+				exitError(err)
+			}
+		}
+
+		if *explain {
+			request := map[string]any{
+				"key":         *key,
+				"value_path":  valuePath,
+				"aggregator":  strings.ToLower(strings.TrimSpace(*aggregator)),
+				"from":        fromValue,
+				"to":          toValue,
+				"granularity": granularityValue,
+				"slices":      *slices,
+			}
+			if compare.enabled() {
+				request["compare_from"] = compareFrom
+				request["compare_to"] = compareTo
+			}
+			if multiplePaths {
+				delete(request, "value_path")
+				request["value_paths"] = paths
+				if expr != nil {
+					request["expr"] = expr.Source
+				}
+			}
+			plan := localPlan("metrics aggregate", local, driverOpts, request)
+			if err := writeJSONOutput(outputOpts, plan); err != nil {
+				exitError(err)
+			}
+			return
+		}
+		if err := local.connect(driverOpts); err != nil {
+			exitError(err)
+		}
+
+		var seriesResult triflestats.ValuesResult
+		err = stats.timeQuery(func() (err error) {
+			seriesResult, err = triflestats.Values(cfg, *key, fromTime, toTime, granularityValue, false)
+			return err
+		})
 		if err != nil {
-			exitError(maybeSuggestSetup(err, local.DriverName, local.TableName))
+			exitError(driverError(maybeSuggestSetup(err, local.DriverName, local.TableName)))
 		}
+		stats.points = len(seriesResult.At)
 
 		series := triflestats.SeriesFromResult(seriesResult)
+		if multiplePaths {
+			aggName := strings.ToLower(strings.TrimSpace(*aggregator))
+			base := map[string]any{
+				"status":          "ok",
+				"aggregator":      aggName,
+				"metric_key":      *key,
+				"value_paths":     paths,
+				"slices":          *slices,
+				"timeframe":       buildTimeframePayload(fromValue, toValue, granularityValue, timeRange.Timeframe),
+				"available_paths": series.AvailablePaths(),
+			}
+			if expr != nil {
+				base["expr"] = expr.Source
+			}
+			if *excludePartial {
+				base["partial_excluded"] = partialExcluded
+			}
+			template := aggregateResult{Key: *key, Aggregator: aggName, Granularity: granularityValue}
+			ranges := func(count int) []timeChunk {
+				return aggregateSliceRanges(fromTime, toTime, granularityValue, cfg, count)
+			}
+			outcomes := localPathAggregates(series, aggName, paths, *slices)
+			if err := writePathAggregates(outputOpts, strings.ToLower(*format), *metricName, tableOpts, outcomes, *slices, expr, base, template, ranges); err != nil {
+				exitError(err)
+			}
+			return
+		}
 		available := series.AvailablePaths()
 		if len(available) == 0 {
-			exitError(fmt.Errorf("no data available for path %s in the selected timeframe", *valuePath))
+			exitError(fmt.Errorf("no data available for path %s in the selected timeframe", valuePath))
 		}
-		if !containsString(available, *valuePath) {
-			exitError(fmt.Errorf("unknown path: %s", *valuePath))
+		wildcard := hasPathWildcard(valuePath)
+		paths, err := resolveValuePaths(valuePath, available)
+		if err != nil {
+			exitError(err)
+		}
+		if !wildcard && !containsString(available, valuePath) {
+			exitError(fmt.Errorf("unknown path: %s", valuePath))
 		}
 
 		aggName := strings.ToLower(strings.TrimSpace(*aggregator))
-		var values []any
-		switch aggName {
-		case "sum":
-			values = series.AggregateSum(*valuePath, *slices)
-		case "mean":
-			values = series.AggregateMean(*valuePath, *slices)
-		case "min":
-			values = series.AggregateMin(*valuePath, *slices)
-		case "max":
-			values = series.AggregateMax(*valuePath, *slices)
-		default:
-			exitError(fmt.Errorf("unsupported aggregator %q", *aggregator))
+		results := make([]aggregateResult, 0, len(paths))
+		for _, path := range paths {
+			values, err := aggregateSeriesPath(series, aggName, path, *slices)
+			if err != nil {
+				exitError(err)
+			}
+			results = append(results, aggregateResult{
+				Key:         *key,
+				ValuePath:   path,
+				Aggregator:  aggName,
+				Granularity: granularityValue,
+				Values:      values,
+				Ranges:      aggregateSliceRanges(fromTime, toTime, granularityValue, cfg, len(values)),
+			})
+		}
+
+		if compare.enabled() {
+			compareFromTime, err := time.Parse(time.RFC3339Nano, compareFrom)
+			if err != nil {
+				exitError(err)
+			}
+			compareToTime, err := time.Parse(time.RFC3339Nano, compareTo)
+			if err != nil {
+				exitError(err)
+			}
+			var previousResult triflestats.ValuesResult
+			err = stats.timeQuery(func() (err error) {
+				previousResult, err = triflestats.Values(cfg, *key, compareFromTime, compareToTime, granularityValue, false)
+				return err
+			})
+			if err != nil {
+				exitError(driverError(maybeSuggestSetup(err, local.DriverName, local.TableName)))
+			}
+			stats.points += len(previousResult.At)
+
+			previousSeries := triflestats.SeriesFromResult(previousResult)
+			comparisons := make([]aggregateComparison, 0, len(results))
+			for _, result := range results {
+				previous, err := aggregateSeriesPath(previousSeries, aggName, result.ValuePath, 1)
+				if err != nil {
+					exitError(err)
+				}
+				comparisons = append(comparisons, aggregateComparison{
+					ValuePath: result.ValuePath,
+					Current:   firstAggregateValue(result.Values),
+					Previous:  firstAggregateValue(previous),
+				})
+			}
+
+			payload := map[string]any{
+				"status":          "ok",
+				"aggregator":      aggName,
+				"metric_key":      *key,
+				"value_path":      valuePath,
+				"slices":          *slices,
+				"timeframe":       buildTimeframePayload(fromValue, toValue, granularityValue, timeRange.Timeframe),
+				"available_paths": available,
+				"matched_paths":   paths,
+			}
+			if *excludePartial {
+				payload["partial_excluded"] = partialExcluded
+			}
+			applyComparison(payload, comparisons, wildcard, buildTimeframePayload(compareFrom, compareTo, granularityValue, compareLabel(compare)))
+			if err := writeTableOrJSONOutput(outputOpts, payload, strings.ToLower(*format), tableOpts); err != nil {
+				exitError(err)
+			}
+			return
+		}
+
+		if outputFormat := strings.ToLower(*format); outputFormat == "prom" || outputFormat == "summary" {
+			if err := writeAggregateText(outputOpts, outputFormat, *metricName, results, tableOpts); err != nil {
+				exitError(err)
+			}
+			return
 		}
 
-		values = normalizeNumericSlice(values)
-		if len(values) == 0 {
-			exitError(fmt.Errorf("no data available for path %s in the selected timeframe", *valuePath))
+		matched := make([]string, 0, len(results))
+		valuesByPath := map[string]any{}
+		valueByPath := map[string]any{}
+		var quietLines []string
+		for _, result := range results {
+			values := normalizeNumericSlice(result.Values)
+			if len(values) == 0 {
+				continue
+			}
+			matched = append(matched, result.ValuePath)
+			valuesByPath[result.ValuePath] = values
+			if *slices == 1 {
+				valueByPath[result.ValuePath] = values[0]
+			}
+			for _, line := range quietAggregateLines(values) {
+				if wildcard {
+					line = result.ValuePath + "\t" + line
+				}
+				quietLines = append(quietLines, line)
+			}
+		}
+		if len(matched) == 0 {
+			if wildcard {
+				exitError(noMatchingPathError(valuePath, available))
+			}
+			exitError(fmt.Errorf("no data available for path %s in the selected timeframe", valuePath))
+		}
+		if *quiet {
+			if err := writeQuietLines(outputOpts, quietLines); err != nil {
+				exitError(err)
+			}
+			return
 		}
 
 		payload := map[string]any{
 			"status":          "ok",
 			"aggregator":      aggName,
 			"metric_key":      *key,
-			"value_path":      *valuePath,
+			"value_path":      valuePath,
 			"slices":          *slices,
-			"values":          values,
-			"count":           len(values),
-			"timeframe":       buildTimeframePayload(fromValue, toValue, granularityValue),
+			"timeframe":       buildTimeframePayload(fromValue, toValue, granularityValue, timeRange.Timeframe),
 			"available_paths": available,
-			"matched_paths":   []string{*valuePath},
+			"matched_paths":   matched,
 		}
-
-		if *slices == 1 && len(values) > 0 && values[0] != nil {
-			payload["value"] = values[0]
+		if wildcard {
+			// Wildcards key values (and value, for a single slice) by path.
+			payload["values"] = valuesByPath
+			if *slices == 1 {
+				payload["value"] = valueByPath
+			}
+		} else {
+			values := valuesByPath[valuePath].([]any)
+			payload["values"] = values
+			payload["count"] = len(values)
+			if *slices == 1 && values[0] != nil {
+				payload["value"] = values[0]
+			}
+		}
+		if *excludePartial {
+			payload["partial_excluded"] = partialExcluded
 		}
 
-		if table := buildSeriesTable(series, []string{*valuePath}); table != nil {
+		if table := buildSeriesTable(series, matched); table != nil {
 			payload["table"] = table
 		}
+		if *slices > 1 {
+			sliceValues := map[string][]any{}
+			count := 0
+			for _, result := range results {
+				sliceValues[result.ValuePath] = normalizeAggregateValues(result.Values)
+				count = max(count, len(result.Values))
+			}
+			chunks := aggregateSliceRanges(fromTime, toTime, granularityValue, cfg, count)
+			applySliceRanges(payload, sliceRangesPayload(chunks), matched, sliceValues)
+		}
 
-		if err := output.PrintTableOrJSON(payload, strings.ToLower(*format)); err != nil {
+		if err := writeTableOrJSONOutput(outputOpts, payload, strings.ToLower(*format), tableOpts); err != nil {
 			exitError(err)
 		}
 		return
 	}
 
-	if *key == "" || *valuePath == "" || *aggregator == "" {
-		exitError(errors.New("--key, --value-path, and --aggregator are required"))
+	if *key == "" || (valuePath == "" && expr == nil) || *aggregator == "" {
+		exitError(usageErrorf("--key, --value-path (or --expr), and --aggregator are required"))
 	}
 
-	if err := ensureToken(opts, true); err != nil {
-		exitError(err)
+	if !*explain {
+		if err := ensureToken(opts, true); err != nil {
+			exitError(err)
+		}
 	}
 
-	fromValue, toValue, err := resolveTimeRange(*from, *to)
+	client, err := newClient(opts)
 	if err != nil {
 		exitError(err)
 	}
+	source := newSourceLookup(client)
+	stats.client = client
 
-	client, err := newClient(opts)
+	fromValue, toValue, err := resolveSourceTimeRange(context.Background(), source, timeRange, driverOpts)
 	if err != nil {
 		exitError(err)
 	}
 
-	granularityValue, err := resolveGranularityValue(context.Background(), client, *granularity)
+	granularityValue, err := resolveGranularityValue(context.Background(), source, *granularity)
 	if err != nil {
 		exitError(err)
 	}
 
+	if *align {
+		fromValue, toValue, err = alignTimeframe(fromValue, toValue, granularityValue, driverOpts)
+		if err != nil {
+			exitError(err)
+		}
+	}
+	partialExcluded := false
+	if *excludePartial {
+		toValue, partialExcluded, err = excludePartialBucket(fromValue, toValue, granularityValue, driverOpts)
+		if err != nil {
+			exitError(err)
+		}
+	}
+	warnTimeframePoints(fromValue, toValue, granularityValue)
+	if err := validateSlices(*slices, fromValue, toValue, granularityValue); err != nil {
+		exitError(err)
+	}
+	stats.granularity = granularityValue
+
 	payload := map[string]any{
 		"mode":        "aggregate",
 		"key":         *key,
-		"value_path":  *valuePath,
+		"value_path":  valuePath,
 		"aggregator":  *aggregator,
 		"from":        fromValue,
 		"to":          toValue,
 		"granularity": granularityValue,
 		"slices":      *slices,
 	}
+	if *excludePartial {
+		payload["exclude_partial"] = true
+	}
+
+	var comparePayload map[string]any
+	var compareFrom, compareTo string
+	if compare.enabled() {
+		compareFrom, compareTo, err = compare.window(fromValue, toValue, granularityValue, driverOpts)
+		if err != nil {
+			exitError(err)
+		}
+		comparePayload = make(map[string]any, len(payload))
+		for name, value := range payload {
+			comparePayload[name] = value
+		}
+		comparePayload["from"] = compareFrom
+		comparePayload["to"] = compareTo
+		delete(comparePayload, "exclude_partial")
+	}
+
+	if multiplePaths {
+		if *explain {
+			payload["value_path"] = "<value_path>"
+			request, err := explainQueryMetrics(client, payload, driverOpts.BeginningOfWeek)
+			if err != nil {
+				exitError(err)
+			}
+			plan := apiPlan("metrics aggregate", opts, request)
+			plan["value_paths"] = paths
+			plan["concurrency"] = min(defaultTopConcurrency, len(paths))
+			if expr != nil {
+				plan["expr"] = expr.Source
+			}
+			if err := writeJSONOutput(outputOpts, plan); err != nil {
+				exitError(err)
+			}
+			return
+		}
+
+		outcomes := apiPathAggregates(context.Background(), client, payload, paths, driverOpts.BeginningOfWeek)
+		base := map[string]any{
+			"status":      "ok",
+			"aggregator":  strings.ToLower(*aggregator),
+			"metric_key":  *key,
+			"value_paths": paths,
+			"slices":      *slices,
+			"timeframe":   buildTimeframePayload(fromValue, toValue, granularityValue, timeRange.Timeframe),
+		}
+		if expr != nil {
+			base["expr"] = expr.Source
+		}
+		if *excludePartial {
+			base["partial_excluded"] = partialExcluded
+		}
+		template := aggregateResult{Key: *key, Aggregator: strings.ToLower(*aggregator), Granularity: granularityValue}
+		ranges := func(count int) []timeChunk {
+			chunks, err := apiAggregateSliceRanges(fromValue, toValue, granularityValue, driverOpts, count)
+			if err != nil {
+				exitError(err)
+			}
+			return chunks
+		}
+		if err := writePathAggregates(outputOpts, strings.ToLower(*format), *metricName, tableOpts, outcomes, *slices, expr, base, template, ranges); err != nil {
+			exitError(err)
+		}
+		return
+	}
+
+	if *explain {
+		request, err := explainQueryMetrics(client, payload, driverOpts.BeginningOfWeek)
+		if err != nil {
+			exitError(err)
+		}
+		plan := apiPlan("metrics aggregate", opts, request)
+		if comparePayload != nil {
+			compareRequest, err := explainQueryMetrics(client, comparePayload, driverOpts.BeginningOfWeek)
+			if err != nil {
+				exitError(err)
+			}
+			plan["compare"] = apiPlan("metrics aggregate", opts, compareRequest)
+		}
+		if err := writeJSONOutput(outputOpts, plan); err != nil {
+			exitError(err)
+		}
+		return
+	}
 
-	data, err := queryMetrics(context.Background(), client, payload)
+	var data, compareData map[string]any
+	if comparePayload != nil {
+		data, compareData, err = queryMetricsPair(context.Background(), client, payload, comparePayload, driverOpts.BeginningOfWeek)
+	} else {
+		data, err = queryMetrics(context.Background(), client, payload, driverOpts.BeginningOfWeek)
+	}
 	if err != nil {
 		exitError(err)
 	}
+	stats.points = responsePoints(data, fromValue, toValue, granularityValue)
+	if *excludePartial {
+		data["partial_excluded"] = partialExcluded
+	}
+	if *align || timeRange.Timeframe != "" || partialExcluded {
+		data["timeframe"] = buildTimeframePayload(fromValue, toValue, granularityValue, timeRange.Timeframe)
+	}
+	if compareData != nil {
+		stats.points += responsePoints(compareData, compareFrom, compareTo, granularityValue)
+		comparison := aggregateComparison{
+			ValuePath: valuePath,
+			Current:   firstAggregateValue(aggregateResponseValues(data)),
+			Previous:  firstAggregateValue(aggregateResponseValues(compareData)),
+		}
+		applyComparison(data, []aggregateComparison{comparison}, false, buildTimeframePayload(compareFrom, compareTo, granularityValue, compareLabel(compare)))
+	}
+
+	if *quiet {
+		if err := writeQuietLines(outputOpts, quietAggregateLines(aggregateResponseValues(data))); err != nil {
+			exitError(err)
+		}
+		return
+	}
+
+	if outputFormat := strings.ToLower(*format); outputFormat == "prom" || outputFormat == "summary" {
+		values := aggregateResponseValues(data)
+		ranges, err := apiAggregateSliceRanges(fromValue, toValue, granularityValue, driverOpts, len(values))
+		if err != nil {
+			exitError(err)
+		}
+		result := aggregateResult{
+			Key:         *key,
+			ValuePath:   valuePath,
+			Aggregator:  strings.ToLower(*aggregator),
+			Granularity: granularityValue,
+			Values:      values,
+			Ranges:      ranges,
+		}
+		if err := writeAggregateText(outputOpts, outputFormat, *metricName, []aggregateResult{result}, tableOpts); err != nil {
+			exitError(err)
+		}
+		return
+	}
+
+	if _, ok := data["slice_ranges"]; !ok && *slices > 1 {
+		// The API does not send slice bounds yet; derive them the same way
+		// the local drivers do.
+		values := normalizeAggregateValues(aggregateResponseValues(data))
+		chunks, err := apiAggregateSliceRanges(fromValue, toValue, granularityValue, driverOpts, len(values))
+		if err != nil {
+			exitError(err)
+		}
+		applySliceRanges(data, sliceRangesPayload(chunks), []string{valuePath}, map[string][]any{valuePath: values})
+	}
 
-	if err := output.PrintTableOrJSON(data, strings.ToLower(*format)); err != nil {
+	if err := writeTableOrJSONOutput(outputOpts, data, strings.ToLower(*format), tableOpts); err != nil {
 		exitError(err)
 	}
 }
@@ -574,29 +1763,127 @@ func metricsTimeline(args []string) {
 	opts := addCommonFlags(fs, &rc.Source)
 	driverOpts := addDriverFlags(fs, &rc.Source)
 	key := fs.String("key", "", "Metrics key")
-	valuePath := fs.String("value-path", "", "Value path")
-	from := fs.String("from", "", "RFC3339 start timestamp")
-	to := fs.String("to", "", "RFC3339 end timestamp")
+	valuePath := fs.String("value-path", "", "Value path (local drivers accept * segments, e.g. duration.*.p95)")
+	timeRange := addTimeRangeFlags(fs)
 	granularity := fs.String("granularity", "", "Granularity (e.g. 1h, 1d)")
-	slices := fs.Int("slices", 1, "Optional number of slices")
-	format := fs.String("format", "json", "Output format: json|table|csv")
-	fs.Parse(args)
+	align := fs.Bool("align", false, "Snap --from/--to outward to whole granularity buckets")
+	skipBlanks := fs.Bool("skip-blanks", false, "Skip empty data points")
+	excludePartial := fs.Bool("exclude-partial", false, "Drop the final bucket when it is still in progress")
+	fs.BoolVar(excludePartial, "until-complete", false, "Alias for --exclude-partial")
+	slices := addSlicesFlag(fs, &rc.Source)
+	format := fs.String("format", "json", "Output format: json|table|csv|markdown|spark")
+	transform := fs.String("transform", "", "Post-process values: delta|rate|cumsum (rate is per second)")
+	strictNil := fs.Bool("strict-nil", false, "With --transform cumsum, leave empty buckets empty instead of counting them as 0")
+	smooth := fs.Int("smooth", 0, "Add a trailing moving average over N buckets (e.g. 7)")
+	anomalies := fs.Float64("anomalies", 0, "Flag buckets more than N standard deviations from the path's mean (e.g. 3)")
+	summary := fs.Bool("summary", false, "Append min, max, mean, and last rows to the table (and a summary object to JSON)")
+	fill := fs.String("fill", "", "Fill empty buckets before formatting: zero|previous|linear")
+	fillEdges := fs.String("fill-edges", "none", "Leading/trailing gaps with --fill: none|zero|nearest")
+	resample := fs.String("resample", "", "Regroup fetched buckets into this coarser granularity client-side (e.g. 1d)")
+	resampleAgg := fs.String("resample-agg", "sum", "How --resample combines buckets: sum|mean|max|min|last")
+	keyPrefix := fs.String("key-prefix", "", "Key prefix to group by (with --group-by-suffix, e.g. event::logs::)")
+	groupBySuffix := fs.Bool("group-by-suffix", false, "Fetch every key under --key-prefix and return one timeline per key suffix")
+	maxGroups := fs.Int("max-groups", defaultMaxTimelineGroups, "Fail when --group-by-suffix matches more keys than this")
+	concurrency := fs.Int("concurrency", defaultTopConcurrency, "Number of grouped keys to fetch at once")
+	chart := addChartFlags(fs)
+	displayTZ := addDisplayTimeZoneFlag(fs, &rc.Source)
+	tableFlags := addTableFlags(fs)
+	explain := addExplainFlag(fs)
+	showStats := addStatsFlag(fs)
+	outputOpts := addOutputFlags(fs)
+	if err := parseKeyArgs(fs, args, key); err != nil {
+		exitError(err)
+	}
 
-	if isLocalDriver(driverOpts.Driver) {
-		if *key == "" || *valuePath == "" {
-			exitError(errors.New("--key and --value-path are required"))
-		}
-		if err := ensureNoWildcards(*valuePath); err != nil {
+	if err := outputOpts.validate(*format); err != nil {
+		exitError(err)
+	}
+	if err := chart.validate(outputOpts); err != nil {
+		exitError(err)
+	}
+
+	transformOpts, err := newTransformOptions(*transform, *strictNil)
+	if err != nil {
+		exitError(err)
+	}
+	if err := validateSmoothWindow(*smooth); err != nil {
+		exitError(err)
+	}
+	if err := validateAnomalyThreshold(*anomalies); err != nil {
+		exitError(err)
+	}
+	fillOpts, err := newFillOptions(*fill, *fillEdges)
+	if err != nil {
+		exitError(err)
+	}
+	resampleOpts, err := newResampleOptions(*resample, *resampleAgg)
+	if err != nil {
+		exitError(err)
+	}
+	seriesRequest := timelineSeriesRequest{
+		Key:       *key,
+		ValuePath: *valuePath,
+		Slices:    *slices,
+		Fill:      fillOpts,
+		Transform: transformOpts,
+		Smooth:    *smooth,
+		Anomalies: *anomalies,
+		Summary:   *summary,
+	}
+	groupOpts := timelineGroupOptions{
+		Prefix:      *keyPrefix,
+		ValuePath:   *valuePath,
+		Slices:      *slices,
+		MaxGroups:   *maxGroups,
+		Concurrency: *concurrency,
+		Fill:        fillOpts,
+		Summary:     *summary,
+	}
+	if *groupBySuffix {
+		if err := groupOpts.validate(); err != nil {
 			exitError(err)
 		}
+		if *key != "" {
+			exitError(usageErrorf("--key cannot be combined with --group-by-suffix"))
+		}
+		if transformOpts.enabled() || *smooth > 0 || *anomalies > 0 {
+			exitError(usageErrorf("--transform, --smooth, and --anomalies are not supported with --group-by-suffix"))
+		}
+	} else if *keyPrefix != "" {
+		exitError(usageErrorf("--key-prefix requires --group-by-suffix"))
+	}
+
+	displayLoc, err := resolveDisplayLocation(*displayTZ)
+	if err != nil {
+		exitError(err)
+	}
+	tableOpts, err := tableFlags.options(displayLoc)
+	if err != nil {
+		exitError(err)
+	}
+	writeTimeline := func(payload map[string]any) error {
+		if chart.enabled() {
+			return chart.write(payload, displayLoc)
+		}
+		return writeTableOrJSONOutput(outputOpts, payload, strings.ToLower(*format), tableOpts)
+	}
+
+	stats := newCommandStats(*showStats && !*explain, statsDriverName(driverOpts.Driver))
+	defer stats.finish()
 
-		local, err := loadLocalConfig(driverOpts)
+	if isLocalDriver(driverOpts.Driver) {
+		if !*groupBySuffix && (*key == "" || *valuePath == "") {
+			exitError(usageErrorf("--key and --value-path are required"))
+		}
+
+		local, err := prepareLocalConfig(driverOpts)
 		if err != nil {
 			exitError(err)
 		}
 		cfg := local.Config
 
-		fromValue, toValue, err := resolveTimeRange(*from, *to)
+		timeRange.Default = rc.Source.DefaultTimeframe
+		fromValue, toValue, err := resolveCommandTimeRange(timeRange, driverOpts)
 		if err != nil {
 			exitError(err)
 		}
@@ -605,6 +1892,30 @@ func metricsTimeline(args []string) {
 		if err != nil {
 			exitError(err)
 		}
+		if resampleOpts.enabled() {
+			if err := resampleOpts.checkSource(granularityValue); err != nil {
+				exitError(err)
+			}
+		}
+
+		if *align {
+			fromValue, toValue, err = alignTimeframe(fromValue, toValue, granularityValue, driverOpts)
+			if err != nil {
+				exitError(err)
+			}
+		}
+		partialExcluded := false
+		if *excludePartial {
+			toValue, partialExcluded, err = excludePartialBucket(fromValue, toValue, granularityValue, driverOpts)
+			if err != nil {
+				exitError(err)
+			}
+		}
+		warnTimeframePoints(fromValue, toValue, granularityValue)
+		if err := validateSlices(*slices, fromValue, toValue, granularityValue); err != nil {
+			exitError(err)
+		}
+		stats.granularity = granularityValue
 
 		fromTime, err := time.Parse(time.RFC3339Nano, fromValue)
 		if err != nil {
@@ -615,63 +1926,257 @@ func metricsTimeline(args []string) {
 			exitError(err)
 		}
 
-		seriesResult, err := triflestats.Values(cfg, *key, fromTime, toTime, granularityValue, false)
-		if err != nil {
-			exitError(maybeSuggestSetup(err, local.DriverName, local.TableName))
+		if *explain {
+			query := map[string]any{
+				"key":         *key,
+				"value_path":  *valuePath,
+				"from":        fromValue,
+				"to":          toValue,
+				"granularity": granularityValue,
+				"slices":      *slices,
+				"skip_blanks": *skipBlanks,
+			}
+			if resampleOpts.enabled() {
+				query["resample"] = resampleOpts.Granularity
+				query["resample_agg"] = resampleOpts.Aggregator
+			}
+			if *groupBySuffix {
+				query["key"] = systemMetricsKey
+				query["key_prefix"] = *keyPrefix
+				query["max_groups"] = *maxGroups
+				query["concurrency"] = *concurrency
+			}
+			plan := localPlan("metrics timeline", local, driverOpts, query)
+			if err := writeJSONOutput(outputOpts, plan); err != nil {
+				exitError(err)
+			}
+			return
+		}
+		if err := local.connect(driverOpts); err != nil {
+			exitError(err)
 		}
 
-		series := triflestats.SeriesFromResult(seriesResult)
-		available := series.AvailablePaths()
-		formatted := series.FormatTimeline(*valuePath, *slices, nil)
-		matched := filterAvailable(mapKeys(formatted), available)
-		if len(matched) == 0 {
-			exitError(fmt.Errorf("no matching data found for path %s in the selected timeframe", *valuePath))
+		if *groupBySuffix {
+			var keysResult triflestats.ValuesResult
+			err = stats.timeQuery(func() (err error) {
+				keysResult, err = triflestats.Values(cfg, systemMetricsKey, fromTime, toTime, granularityValue, true)
+				return err
+			})
+			if err != nil {
+				exitError(driverError(maybeSuggestSetup(err, local.DriverName, local.TableName)))
+			}
+			keys, err := groupKeysBySuffix(keysEntryNames(summarizeSystemKeys(keysResult.Values)), *keyPrefix, *maxGroups)
+			if err != nil {
+				exitError(err)
+			}
+			var payload map[string]any
+			err = stats.timeQuery(func() (err error) {
+				payload, stats.points, err = buildTimelineGroups(keys, groupOpts, func(key string) (triflestats.ValuesResult, error) {
+					result, err := triflestats.Values(cfg, key, fromTime, toTime, granularityValue, *skipBlanks)
+					return resampleResult(result, resampleOpts, cfg), driverError(err)
+				})
+				return err
+			})
+			if err != nil {
+				exitError(err)
+			}
+			payload["timeframe"] = resampleOpts.timeframe(buildTimeframePayload(fromValue, toValue, granularityValue, timeRange.Timeframe), granularityValue)
+			if *excludePartial {
+				payload["partial_excluded"] = partialExcluded
+			}
+			if err := writeTimeline(payload); err != nil {
+				exitError(err)
+			}
+			return
 		}
 
-		payload := map[string]any{
-			"status":          "ok",
-			"formatter":       "timeline",
-			"metric_key":      *key,
-			"value_path":      *valuePath,
-			"slices":          *slices,
-			"timeframe":       buildTimeframePayload(fromValue, toValue, granularityValue),
-			"result":          formatted,
-			"available_paths": available,
-			"matched_paths":   matched,
+		var seriesResult triflestats.ValuesResult
+		err = stats.timeQuery(func() (err error) {
+			seriesResult, err = triflestats.Values(cfg, *key, fromTime, toTime, granularityValue, *skipBlanks)
+			return err
+		})
+		if err != nil {
+			exitError(driverError(maybeSuggestSetup(err, local.DriverName, local.TableName)))
 		}
+		stats.points = len(seriesResult.At)
 
-		if table := buildSeriesTable(series, matched); table != nil {
-			payload["table"] = table
+		payload, err := timelineSeriesPayload(triflestats.SeriesFromResult(resampleResult(seriesResult, resampleOpts, cfg)), seriesRequest)
+		if err != nil {
+			exitError(err)
+		}
+		payload["timeframe"] = resampleOpts.timeframe(buildTimeframePayload(fromValue, toValue, granularityValue, timeRange.Timeframe), granularityValue)
+		if *excludePartial {
+			payload["partial_excluded"] = partialExcluded
 		}
 
-		if err := output.PrintTableOrJSON(payload, strings.ToLower(*format)); err != nil {
+		if err := writeTimeline(payload); err != nil {
 			exitError(err)
 		}
 		return
 	}
 
-	if *key == "" || *valuePath == "" {
-		exitError(errors.New("--key and --value-path are required"))
+	if !*groupBySuffix && (*key == "" || *valuePath == "") {
+		exitError(usageErrorf("--key and --value-path are required"))
 	}
 
-	if err := ensureToken(opts, true); err != nil {
-		exitError(err)
+	if !*explain {
+		if err := ensureToken(opts, true); err != nil {
+			exitError(err)
+		}
 	}
 
-	fromValue, toValue, err := resolveTimeRange(*from, *to)
+	client, err := newClient(opts)
 	if err != nil {
 		exitError(err)
 	}
+	source := newSourceLookup(client)
+	stats.client = client
 
-	client, err := newClient(opts)
+	fromValue, toValue, err := resolveSourceTimeRange(context.Background(), source, timeRange, driverOpts)
 	if err != nil {
 		exitError(err)
 	}
 
-	granularityValue, err := resolveGranularityValue(context.Background(), client, *granularity)
+	granularityValue, err := resolveGranularityValue(context.Background(), source, *granularity)
 	if err != nil {
 		exitError(err)
 	}
+	var resampleCfg *triflestats.Config
+	if resampleOpts.enabled() {
+		if err := resampleOpts.checkSource(granularityValue); err != nil {
+			exitError(err)
+		}
+		if resampleCfg, err = bucketConfig(driverOpts); err != nil {
+			exitError(err)
+		}
+	}
+
+	if *align {
+		fromValue, toValue, err = alignTimeframe(fromValue, toValue, granularityValue, driverOpts)
+		if err != nil {
+			exitError(err)
+		}
+	}
+	partialExcluded := false
+	if *excludePartial {
+		toValue, partialExcluded, err = excludePartialBucket(fromValue, toValue, granularityValue, driverOpts)
+		if err != nil {
+			exitError(err)
+		}
+	}
+	warnTimeframePoints(fromValue, toValue, granularityValue)
+	if err := validateSlices(*slices, fromValue, toValue, granularityValue); err != nil {
+		exitError(err)
+	}
+	stats.granularity = granularityValue
+
+	if *groupBySuffix {
+		params := map[string]string{
+			"from":        fromValue,
+			"to":          toValue,
+			"granularity": granularityValue,
+		}
+		if *skipBlanks {
+			params["skip_blanks"] = "true"
+		}
+		perKey := func(key string) map[string]string {
+			keyParams := map[string]string{"key": key}
+			for name, value := range params {
+				keyParams[name] = value
+			}
+			return keyParams
+		}
+
+		if *explain {
+			request, err := explainGetMetrics(client, params, driverOpts.BeginningOfWeek)
+			if err != nil {
+				exitError(err)
+			}
+			plan := apiPlan("metrics timeline", opts, request)
+			keyRequest, err := explainGetMetrics(client, perKey("<key>"), driverOpts.BeginningOfWeek)
+			if err != nil {
+				exitError(err)
+			}
+			plan["per_key"] = apiPlan("metrics timeline", opts, keyRequest)
+			plan["key_prefix"] = *keyPrefix
+			plan["max_groups"] = *maxGroups
+			plan["concurrency"] = *concurrency
+			if err := writeJSONOutput(outputOpts, plan); err != nil {
+				exitError(err)
+			}
+			return
+		}
+
+		var response metricsResponse
+		if err := getMetrics(context.Background(), client, params, driverOpts.BeginningOfWeek, &response); err != nil {
+			exitError(err)
+		}
+		keys, err := groupKeysBySuffix(keysEntryNames(summarizeKeys(response.Data.Values)), *keyPrefix, *maxGroups)
+		if err != nil {
+			exitError(err)
+		}
+		payload, points, err := buildTimelineGroups(keys, groupOpts, func(key string) (triflestats.ValuesResult, error) {
+			result, err := fetchMetricsChunked(context.Background(), client, perKey(key), driverOpts, 0)
+			return resampleResult(result, resampleOpts, resampleCfg), err
+		})
+		if err != nil {
+			exitError(err)
+		}
+		stats.points = len(response.Data.At) + points
+		payload["timeframe"] = resampleOpts.timeframe(buildTimeframePayload(fromValue, toValue, granularityValue, timeRange.Timeframe), granularityValue)
+		if *excludePartial {
+			payload["partial_excluded"] = partialExcluded
+		}
+		if err := writeTimeline(payload); err != nil {
+			exitError(err)
+		}
+		return
+	}
+
+	// The API has no resampling, so fetch the raw series and format it
+	// here the way local drivers do.
+	if resampleOpts.enabled() {
+		params := map[string]string{
+			"key":         *key,
+			"from":        fromValue,
+			"to":          toValue,
+			"granularity": granularityValue,
+		}
+		if *skipBlanks {
+			params["skip_blanks"] = "true"
+		}
+		if *explain {
+			request, err := explainGetMetrics(client, params, driverOpts.BeginningOfWeek)
+			if err != nil {
+				exitError(err)
+			}
+			plan := apiPlan("metrics timeline", opts, request)
+			plan["resample"] = resampleOpts.Granularity
+			plan["resample_agg"] = resampleOpts.Aggregator
+			if err := writeJSONOutput(outputOpts, plan); err != nil {
+				exitError(err)
+			}
+			return
+		}
+
+		result, err := fetchMetricsChunked(context.Background(), client, params, driverOpts, 0)
+		if err != nil {
+			exitError(err)
+		}
+		stats.points = len(result.At)
+		payload, err := timelineSeriesPayload(triflestats.SeriesFromResult(resampleResult(result, resampleOpts, resampleCfg)), seriesRequest)
+		if err != nil {
+			exitError(err)
+		}
+		payload["timeframe"] = resampleOpts.timeframe(buildTimeframePayload(fromValue, toValue, granularityValue, timeRange.Timeframe), granularityValue)
+		if *excludePartial {
+			payload["partial_excluded"] = partialExcluded
+		}
+		if err := writeTimeline(payload); err != nil {
+			exitError(err)
+		}
+		return
+	}
 
 	payload := map[string]any{
 		"mode":        "timeline",
@@ -682,13 +2187,68 @@ func metricsTimeline(args []string) {
 		"granularity": granularityValue,
 		"slices":      *slices,
 	}
+	if *skipBlanks {
+		payload["skip_blanks"] = true
+	}
+	if *excludePartial {
+		payload["exclude_partial"] = true
+	}
+	if *smooth > 0 {
+		payload["smooth"] = *smooth
+	}
 
-	data, err := queryMetrics(context.Background(), client, payload)
+	if *explain {
+		request, err := explainQueryMetrics(client, payload, driverOpts.BeginningOfWeek)
+		if err != nil {
+			exitError(err)
+		}
+		if err := writeJSONOutput(outputOpts, apiPlan("metrics timeline", opts, request)); err != nil {
+			exitError(err)
+		}
+		return
+	}
+
+	data, err := queryMetrics(context.Background(), client, payload, driverOpts.BeginningOfWeek)
 	if err != nil {
 		exitError(err)
 	}
+	stats.points = responsePoints(data, fromValue, toValue, granularityValue)
+	if *excludePartial {
+		data["partial_excluded"] = partialExcluded
+	}
+	if fillOpts.enabled() {
+		if err := fillQueryData(data, fillOpts); err != nil {
+			exitError(err)
+		}
+		data["fill"] = fillOpts.Mode
+		data["fill_edges"] = fillOpts.Edges
+	}
+	if transformOpts.enabled() {
+		resets, err := transformQueryData(data, transformOpts)
+		if err != nil {
+			exitError(err)
+		}
+		data["transform"] = transformOpts.Mode
+		data["resets"] = resets
+		if transformOpts.StrictNil {
+			data["strict_nil"] = true
+		}
+	}
+	if *smooth > 0 {
+		data["smooth"] = *smooth
+		data["result_smoothed"] = smoothResultData(data["result"], *smooth)
+	}
+	if err := applyTimelineAnomalies(data, *anomalies, *smooth); err != nil {
+		exitError(err)
+	}
+	if *summary {
+		applyTimelineSummary(data)
+	}
+	if *align || timeRange.Timeframe != "" || partialExcluded || chart.enabled() {
+		data["timeframe"] = buildTimeframePayload(fromValue, toValue, granularityValue, timeRange.Timeframe)
+	}
 
-	if err := output.PrintTableOrJSON(data, strings.ToLower(*format)); err != nil {
+	if err := writeTimeline(data); err != nil {
 		exitError(err)
 	}
 }
@@ -705,58 +2265,137 @@ func metricsCategory(args []string) {
 	opts := addCommonFlags(fs, &rc.Source)
 	driverOpts := addDriverFlags(fs, &rc.Source)
 	key := fs.String("key", "", "Metrics key")
-	valuePath := fs.String("value-path", "", "Value path")
-	from := fs.String("from", "", "RFC3339 start timestamp")
-	to := fs.String("to", "", "RFC3339 end timestamp")
+	valuePath := fs.String("value-path", "", "Value path (local drivers accept * segments, e.g. duration.*.p95)")
+	timeRange := addTimeRangeFlags(fs)
 	granularity := fs.String("granularity", "", "Granularity (e.g. 1h, 1d)")
-	slices := fs.Int("slices", 1, "Optional number of slices")
-	format := fs.String("format", "json", "Output format: json|table|csv")
-	fs.Parse(args)
+	align := fs.Bool("align", false, "Snap --from/--to outward to whole granularity buckets")
+	skipBlanks := fs.Bool("skip-blanks", false, "Skip empty data points")
+	excludePartial := fs.Bool("exclude-partial", false, "Drop the final bucket when it is still in progress")
+	fs.BoolVar(excludePartial, "until-complete", false, "Alias for --exclude-partial")
+	slices := addSlicesFlag(fs, &rc.Source)
+	sortBy := fs.String("sort", "name", "Sort categories by: value|name")
+	desc := fs.Bool("desc", false, "Sort in descending order")
+	otherBelow := fs.Float64("other-below", 0, "Collapse categories under this percentage of the total into \"other\" (e.g. 1)")
+	top := fs.Int("top", 0, "Keep the N largest categories and sum the rest into \"other\" (0 keeps all)")
+	format := fs.String("format", "json", "Output format: json|table|csv|markdown")
+	displayTZ := addDisplayTimeZoneFlag(fs, &rc.Source)
+	tableFlags := addTableFlags(fs)
+	explain := addExplainFlag(fs)
+	showStats := addStatsFlag(fs)
+	outputOpts := addOutputFlags(fs)
+	if err := parseKeyArgs(fs, args, key); err != nil {
+		exitError(err)
+	}
+
+	if err := outputOpts.validate(*format); err != nil {
+		exitError(err)
+	}
+	categoryOpts, err := newCategoryOptions(*sortBy, *desc, *otherBelow, *top)
+	if err != nil {
+		exitError(err)
+	}
+
+	displayLoc, err := resolveDisplayLocation(*displayTZ)
+	if err != nil {
+		exitError(err)
+	}
+	tableOpts, err := tableFlags.options(displayLoc)
+	if err != nil {
+		exitError(err)
+	}
+
+	stats := newCommandStats(*showStats && !*explain, statsDriverName(driverOpts.Driver))
+	defer stats.finish()
 
 	if isLocalDriver(driverOpts.Driver) {
 		if *key == "" || *valuePath == "" {
-			exitError(errors.New("--key and --value-path are required"))
+			exitError(usageErrorf("--key and --value-path are required"))
+		}
+
+		local, err := prepareLocalConfig(driverOpts)
+		if err != nil {
+			exitError(err)
 		}
-		if err := ensureNoWildcards(*valuePath); err != nil {
+		cfg := local.Config
+
+		timeRange.Default = rc.Source.DefaultTimeframe
+		fromValue, toValue, err := resolveCommandTimeRange(timeRange, driverOpts)
+		if err != nil {
 			exitError(err)
 		}
 
-		local, err := loadLocalConfig(driverOpts)
+		granularityValue, err := resolveGranularityLocal(*granularity, cfg)
 		if err != nil {
 			exitError(err)
 		}
-		cfg := local.Config
 
-		fromValue, toValue, err := resolveTimeRange(*from, *to)
+		if *align {
+			fromValue, toValue, err = alignTimeframe(fromValue, toValue, granularityValue, driverOpts)
+			if err != nil {
+				exitError(err)
+			}
+		}
+		partialExcluded := false
+		if *excludePartial {
+			toValue, partialExcluded, err = excludePartialBucket(fromValue, toValue, granularityValue, driverOpts)
+			if err != nil {
+				exitError(err)
+			}
+		}
+		warnTimeframePoints(fromValue, toValue, granularityValue)
+		if err := validateSlices(*slices, fromValue, toValue, granularityValue); err != nil {
+			exitError(err)
+		}
+		stats.granularity = granularityValue
+
+		fromTime, err := time.Parse(time.RFC3339Nano, fromValue)
 		if err != nil {
 			exitError(err)
 		}
-
-		granularityValue, err := resolveGranularityLocal(*granularity, cfg)
+		toTime, err := time.Parse(time.RFC3339Nano, toValue)
 		if err != nil {
 			exitError(err)
 		}
 
-		fromTime, err := time.Parse(time.RFC3339Nano, fromValue)
-		if err != nil {
-			exitError(err)
+		if *explain {
+			plan := localPlan("metrics category", local, driverOpts, map[string]any{
+				"key":         *key,
+				"value_path":  *valuePath,
+				"from":        fromValue,
+				"to":          toValue,
+				"granularity": granularityValue,
+				"slices":      *slices,
+				"skip_blanks": *skipBlanks,
+			})
+			if err := writeJSONOutput(outputOpts, plan); err != nil {
+				exitError(err)
+			}
+			return
 		}
-		toTime, err := time.Parse(time.RFC3339Nano, toValue)
-		if err != nil {
+		if err := local.connect(driverOpts); err != nil {
 			exitError(err)
 		}
 
-		seriesResult, err := triflestats.Values(cfg, *key, fromTime, toTime, granularityValue, false)
+		var seriesResult triflestats.ValuesResult
+		err = stats.timeQuery(func() (err error) {
+			seriesResult, err = triflestats.Values(cfg, *key, fromTime, toTime, granularityValue, *skipBlanks)
+			return err
+		})
 		if err != nil {
-			exitError(maybeSuggestSetup(err, local.DriverName, local.TableName))
+			exitError(driverError(maybeSuggestSetup(err, local.DriverName, local.TableName)))
 		}
+		stats.points = len(seriesResult.At)
 
 		series := triflestats.SeriesFromResult(seriesResult)
 		available := series.AvailablePaths()
-		formatted := series.FormatCategory(*valuePath, *slices, nil)
+		paths, err := resolveValuePaths(*valuePath, available)
+		if err != nil {
+			exitError(err)
+		}
+		formatted := formatCategoryPaths(series, paths, *slices)
 		matched := filterAvailable(extractCategoryPaths(formatted), available)
 		if len(matched) == 0 {
-			exitError(fmt.Errorf("no matching data found for path %s in the selected timeframe", *valuePath))
+			exitError(noMatchingPathError(*valuePath, available))
 		}
 
 		payload := map[string]any{
@@ -765,45 +2404,68 @@ func metricsCategory(args []string) {
 			"metric_key":      *key,
 			"value_path":      *valuePath,
 			"slices":          *slices,
-			"timeframe":       buildTimeframePayload(fromValue, toValue, granularityValue),
+			"timeframe":       buildTimeframePayload(fromValue, toValue, granularityValue, timeRange.Timeframe),
 			"result":          formatted,
 			"available_paths": available,
 			"matched_paths":   matched,
 		}
-
-		if table := buildSeriesTable(series, matched); table != nil {
-			payload["table"] = table
+		if *excludePartial {
+			payload["partial_excluded"] = partialExcluded
 		}
+		applyCategoryRanking(payload, categoryOpts)
 
-		if err := output.PrintTableOrJSON(payload, strings.ToLower(*format)); err != nil {
+		if err := writeTableOrJSONOutput(outputOpts, payload, strings.ToLower(*format), tableOpts); err != nil {
 			exitError(err)
 		}
 		return
 	}
 
 	if *key == "" || *valuePath == "" {
-		exitError(errors.New("--key and --value-path are required"))
+		exitError(usageErrorf("--key and --value-path are required"))
 	}
 
-	if err := ensureToken(opts, true); err != nil {
-		exitError(err)
+	if !*explain {
+		if err := ensureToken(opts, true); err != nil {
+			exitError(err)
+		}
 	}
 
-	fromValue, toValue, err := resolveTimeRange(*from, *to)
+	client, err := newClient(opts)
 	if err != nil {
 		exitError(err)
 	}
+	source := newSourceLookup(client)
+	stats.client = client
 
-	client, err := newClient(opts)
+	fromValue, toValue, err := resolveSourceTimeRange(context.Background(), source, timeRange, driverOpts)
 	if err != nil {
 		exitError(err)
 	}
 
-	granularityValue, err := resolveGranularityValue(context.Background(), client, *granularity)
+	granularityValue, err := resolveGranularityValue(context.Background(), source, *granularity)
 	if err != nil {
 		exitError(err)
 	}
 
+	if *align {
+		fromValue, toValue, err = alignTimeframe(fromValue, toValue, granularityValue, driverOpts)
+		if err != nil {
+			exitError(err)
+		}
+	}
+	partialExcluded := false
+	if *excludePartial {
+		toValue, partialExcluded, err = excludePartialBucket(fromValue, toValue, granularityValue, driverOpts)
+		if err != nil {
+			exitError(err)
+		}
+	}
+	warnTimeframePoints(fromValue, toValue, granularityValue)
+	if err := validateSlices(*slices, fromValue, toValue, granularityValue); err != nil {
+		exitError(err)
+	}
+	stats.granularity = granularityValue
+
 	payload := map[string]any{
 		"mode":        "category",
 		"key":         *key,
@@ -813,13 +2475,38 @@ func metricsCategory(args []string) {
 		"granularity": granularityValue,
 		"slices":      *slices,
 	}
+	if *skipBlanks {
+		payload["skip_blanks"] = true
+	}
+	if *excludePartial {
+		payload["exclude_partial"] = true
+	}
+
+	if *explain {
+		request, err := explainQueryMetrics(client, payload, driverOpts.BeginningOfWeek)
+		if err != nil {
+			exitError(err)
+		}
+		if err := writeJSONOutput(outputOpts, apiPlan("metrics category", opts, request)); err != nil {
+			exitError(err)
+		}
+		return
+	}
 
-	data, err := queryMetrics(context.Background(), client, payload)
+	data, err := queryMetrics(context.Background(), client, payload, driverOpts.BeginningOfWeek)
 	if err != nil {
 		exitError(err)
 	}
+	stats.points = responsePoints(data, fromValue, toValue, granularityValue)
+	if *excludePartial {
+		data["partial_excluded"] = partialExcluded
+	}
+	if *align || timeRange.Timeframe != "" || partialExcluded {
+		data["timeframe"] = buildTimeframePayload(fromValue, toValue, granularityValue, timeRange.Timeframe)
+	}
+	applyCategoryRanking(data, categoryOpts)
 
-	if err := output.PrintTableOrJSON(data, strings.ToLower(*format)); err != nil {
+	if err := writeTableOrJSONOutput(outputOpts, data, strings.ToLower(*format), tableOpts); err != nil {
 		exitError(err)
 	}
 }
@@ -836,29 +2523,75 @@ func metricsPush(args []string) {
 	opts := addCommonFlags(fs, &rc.Source)
 	driverOpts := addDriverFlags(fs, &rc.Source)
 	key := fs.String("key", "", "Metrics key")
-	at := fs.String("at", "", "RFC3339 timestamp (default: now)")
-	valuesJSON := fs.String("values", "", "Values payload as JSON")
-	valuesFile := fs.String("values-file", "", "Path to JSON file with values payload")
-	mode := fs.String("mode", "track", "Mode: track|assert (local drivers)")
-	fs.Parse(args)
-
-	if *key == "" {
-		exitError(errors.New("--key is required"))
+	at := fs.String("at", "", "RFC3339 or epoch timestamp (default: now)")
+	valuesJSON := fs.String("values", "", "Values payload as JSON (- reads it from stdin)")
+	valuesFile := fs.String("values-file", "", "Path to JSON file with values payload, or an array of {at, values} points (- for stdin)")
+	mode := fs.String("mode", "track", "Mode: track|assert|if-absent (assert and if-absent need a local driver; if-absent skips the write when the bucket already has values)")
+	failFast := fs.Bool("fail-fast", false, "Stop at the first failing point when pushing an array of points")
+	stream := fs.Bool("stream", false, "Read NDJSON {at, values} points from stdin and push each as it arrives")
+	var sets setFlag
+	fs.Var(&sets, "set", "Set one value path, e.g. count=1 or status.ok=1 (repeatable; wins over --values)")
+	validate := fs.Bool("validate", false, "Reject values that break the key's schema in the config before writing")
+	explain := addExplainFlag(fs)
+	outputOpts := addOutputFlags(fs)
+	if err := parseKeyArgs(fs, args, key); err != nil {
+		exitError(err)
 	}
 
-	values, err := loadJSONPayload(*valuesJSON, *valuesFile)
-	if err != nil {
+	if err := outputOpts.validate(""); err != nil {
 		exitError(err)
 	}
 
-	if values == nil {
-		exitError(errors.New("--values or --values-file is required"))
+	if *key == "" {
+		exitError(usageErrorf("--key is required"))
+	}
+	var schema keySchema
+	if *validate {
+		if schema, err = lookupSchema(rc.Config, *key); err != nil {
+			exitError(err)
+		}
+	}
+
+	var values any
+	if *stream {
+		if (*valuesJSON != "" && *valuesJSON != stdinArg) || (*valuesFile != "" && *valuesFile != stdinArg) {
+			exitError(usageErrorf("--stream reads points from stdin; drop --values/--values-file or set them to -"))
+		}
+		if len(sets) > 0 {
+			exitError(usageErrorf("--set cannot be combined with --stream"))
+		}
+	} else {
+		values, err = readJSONPayload(os.Stdin, *valuesJSON, *valuesFile)
+		if err != nil {
+			exitError(err)
+		}
+
+		if len(sets) > 0 {
+			setValues, err := buildSetValues(sets)
+			if err != nil {
+				exitError(usageErrorf("%v", err))
+			}
+			if values == nil {
+				values = setValues
+			} else {
+				base, ok := values.(map[string]any)
+				if !ok {
+					exitError(usageErrorf("--set can only be combined with a values object"))
+				}
+				values = mergeSetValues(base, setValues)
+			}
+		}
+
+		if values == nil {
+			exitError(usageErrorf("--values, --values-file, or --set is required"))
+		}
+	}
+	streamAt := func() (string, error) {
+		return resolvePushAt(*at)
 	}
 
-	atValue := strings.TrimSpace(*at)
-	if atValue == "" {
-		atValue = time.Now().UTC().Format(time.RFC3339)
-	} else if err := validateTimestamp("at", atValue); err != nil {
+	atValue, err := resolvePushAt(*at)
+	if err != nil {
 		exitError(err)
 	}
 
@@ -867,13 +2600,88 @@ func metricsPush(args []string) {
 		driverName = "api"
 	}
 
+	modeName, err := resolvePushMode(*mode, isLocalDriver(driverName))
+	if err != nil {
+		exitError(err)
+	}
+
+	points, batch := values.([]any)
+	if schema != nil && !*stream && !batch {
+		valuesMap, err := ensureValuesMap(values)
+		if err != nil {
+			exitError(err)
+		}
+		if err := checkSchemaValues(schema, *key, valuesMap); err != nil {
+			exitError(err)
+		}
+	}
+
 	if isLocalDriver(driverName) {
-		local, err := loadLocalConfig(driverOpts)
+		local, err := prepareLocalConfig(driverOpts)
 		if err != nil {
 			exitError(err)
 		}
 		cfg := local.Config
 
+		if *explain {
+			request := map[string]any{"key": *key, "mode": modeName}
+			if *stream {
+				request["points"] = "stdin"
+			} else if batch {
+				request["points"] = len(points)
+			} else {
+				request["at"] = atValue
+				request["values"] = values
+			}
+			if err := writeJSONOutput(outputOpts, localPlan("metrics push", local, driverOpts, request)); err != nil {
+				exitError(err)
+			}
+			return
+		}
+		if err := local.connect(driverOpts); err != nil {
+			exitError(err)
+		}
+
+		// writePoint reports whether an if-absent point was skipped.
+		writePoint := func(atTime time.Time, values map[string]any) (bool, error) {
+			return false, performLocalWrite(cfg, modeName, *key, atTime, values)
+		}
+		if modeName == "if-absent" {
+			writer, err := newIfAbsentWriter(cfg)
+			if err != nil {
+				exitError(err)
+			}
+			writePoint = func(atTime time.Time, values map[string]any) (bool, error) {
+				return writer.write(*key, atTime, values)
+			}
+		}
+
+		if *stream || batch {
+			write := schemaCheckedWrite(schema, *key, func(at string, values map[string]any) error {
+				atTime, err := time.Parse(time.RFC3339Nano, at)
+				if err != nil {
+					return err
+				}
+				skipped, err := writePoint(atTime, values)
+				if err == nil && skipped {
+					return errPointSkipped
+				}
+				return err
+			})
+			var summary pushSummary
+			if *stream {
+				summary = pushStream(*key, os.Stdin, streamAt, *failFast, write)
+			} else {
+				summary = pushBatch(*key, points, atValue, *failFast, write)
+			}
+			if err := cfg.ShutdownBuffer(); err != nil {
+				summary.FlushError = err.Error()
+				summary.flushErr = driverError(maybeSuggestSetup(err, local.DriverName, local.TableName))
+			}
+			printPushSummary(outputOpts, summary)
+			return
+		}
+
 		atTime, err := time.Parse(time.RFC3339Nano, atValue)
 		if err != nil {
 			exitError(err)
@@ -884,25 +2692,33 @@ func metricsPush(args []string) {
 			exitError(err)
 		}
 
-		if err := performLocalWrite(cfg, *mode, *key, atTime, valuesMap); err != nil {
-			exitError(maybeSuggestSetup(err, local.DriverName, local.TableName))
+		skipped, err := writePoint(atTime, valuesMap)
+		if err != nil {
+			exitError(driverError(maybeSuggestSetup(err, local.DriverName, local.TableName)))
+		}
+		if err := cfg.ShutdownBuffer(); err != nil {
+			exitError(driverError(maybeSuggestSetup(err, local.DriverName, local.TableName)))
 		}
 
-		response := map[string]any{
-			"data": map[string]any{
-				"key":    *key,
-				"at":     atTime,
-				"values": valuesMap,
-			},
+		data := map[string]any{
+			"key":    *key,
+			"at":     atTime,
+			"values": valuesMap,
 		}
-		if err := output.PrintJSON(os.Stdout, response); err != nil {
+		if modeName == "if-absent" {
+			data["skipped"] = skipped
+		}
+		response := map[string]any{"data": data}
+		if err := writeJSONOutput(outputOpts, response); err != nil {
 			exitError(err)
 		}
 		return
 	}
 
-	if err := ensureToken(opts, true); err != nil {
-		exitError(err)
+	if !*explain {
+		if err := ensureToken(opts, true); err != nil {
+			exitError(err)
+		}
 	}
 
 	client, err := newClient(opts)
@@ -910,6 +2726,44 @@ func metricsPush(args []string) {
 		exitError(err)
 	}
 
+	if *explain {
+		var plan map[string]any
+		if *stream {
+			plan = apiPlan("metrics push", opts, client.DescribePostMetrics(nil))
+			plan["points"] = "stdin"
+		} else if batch {
+			plan = apiPlan("metrics push", opts, client.DescribePostMetrics(nil))
+			plan["points"] = len(points)
+		} else {
+			plan = apiPlan("metrics push", opts, client.DescribePostMetrics(map[string]any{
+				"key":    *key,
+				"at":     atValue,
+				"values": values,
+			}))
+		}
+		if err := writeJSONOutput(outputOpts, plan); err != nil {
+			exitError(err)
+		}
+		return
+	}
+
+	if *stream || batch {
+		write := schemaCheckedWrite(schema, *key, func(at string, values map[string]any) error {
+			payload := map[string]any{
+				"key":    *key,
+				"at":     at,
+				"values": values,
+			}
+			return client.PostMetrics(context.Background(), payload, nil)
+		})
+		if *stream {
+			printPushSummary(outputOpts, pushStream(*key, os.Stdin, streamAt, *failFast, write))
+		} else {
+			printPushSummary(outputOpts, pushBatch(*key, points, atValue, *failFast, write))
+		}
+		return
+	}
+
 	payload := map[string]any{
 		"key":    *key,
 		"at":     atValue,
@@ -921,9 +2775,101 @@ func metricsPush(args []string) {
 		exitError(err)
 	}
 
-	if err := output.PrintJSON(os.Stdout, response); err != nil {
+	if err := writeJSONOutput(outputOpts, response); err != nil {
+		exitError(err)
+	}
+}
+
+type pushFailure struct {
+	Index int    `json:"index"`
+	At    string `json:"at,omitempty"`
+	Error string `json:"error"`
+}
+
+type pushSummary struct {
+	Key      string        `json:"key"`
+	Total    int           `json:"total"`
+	Written  int           `json:"written"`
+	Skipped  int           `json:"skipped,omitempty"`
+	Failed   int           `json:"failed"`
+	Failures []pushFailure `json:"failures"`
+	// FlushError is set when buffered writes could not be flushed, so points
+	// counted as written may not have been stored.
+	FlushError string `json:"flush_error,omitempty"`
+
+	flushErr error
+}
+
+// pushBatch writes each {at, values} point and records failures instead of
+// stopping, unless failFast is set. Points without at use defaultAt; points
+// the write skips with errPointSkipped are counted apart from failures.
+func pushBatch(key string, points []any, defaultAt string, failFast bool, write func(at string, values map[string]any) error) pushSummary {
+	summary := pushSummary{Key: key, Total: len(points), Failures: []pushFailure{}}
+	for i, point := range points {
+		at, values, err := parsePushPoint(point, defaultAt)
+		if err == nil {
+			err = write(at, values)
+		}
+		if errors.Is(err, errPointSkipped) {
+			summary.Skipped++
+			continue
+		}
+		if err != nil {
+			summary.Failures = append(summary.Failures, pushFailure{Index: i, At: at, Error: err.Error()})
+			if failFast {
+				break
+			}
+			continue
+		}
+		summary.Written++
+	}
+	summary.Failed = len(summary.Failures)
+	return summary
+}
+
+func parsePushPoint(point any, defaultAt string) (string, map[string]any, error) {
+	entry, ok := point.(map[string]any)
+	if !ok {
+		return "", nil, fmt.Errorf("point must be an object with at and values")
+	}
+
+	at := defaultAt
+	switch raw := entry["at"].(type) {
+	case nil:
+	case string:
+		if strings.TrimSpace(raw) != "" {
+			at = strings.TrimSpace(raw)
+		}
+	case float64:
+		at = strconv.FormatFloat(raw, 'f', -1, 64)
+	default:
+		return "", nil, fmt.Errorf("at must be a string or epoch number")
+	}
+
+	resolved, err := validateTimestamp("at", at)
+	if err != nil {
+		return at, nil, err
+	}
+
+	values, err := ensureValuesMap(entry["values"])
+	if err != nil {
+		return resolved, nil, err
+	}
+	return resolved, values, nil
+}
+
+// printPushSummary writes the summary and exits non-zero when the buffer
+// flush or any point failed.
+func printPushSummary(outputOpts *outputOptions, summary pushSummary) {
+	if err := writeJSONOutput(outputOpts, map[string]any{"data": summary}); err != nil {
 		exitError(err)
 	}
+	if summary.flushErr != nil {
+		exitError(summary.flushErr)
+	}
+	if summary.Failed > 0 {
+		os.Exit(1)
+	}
 }
 
 func metricsSetup(args []string) {
@@ -936,6 +2882,7 @@ func metricsSetup(args []string) {
 	addConfigFlag(fs, rc.ConfigPath)
 	addSourceFlag(fs, rc.SourceName)
 	driverOpts := addDriverFlags(fs, &rc.Source)
+	explain := addExplainFlag(fs)
 	fs.Parse(args)
 
 	driverName := strings.ToLower(strings.TrimSpace(driverOpts.Driver))
@@ -947,10 +2894,20 @@ func metricsSetup(args []string) {
 		exitError(fmt.Errorf("setup is only supported for local drivers (sqlite, postgres, mysql, redis, mongo)"))
 	}
 
-	local, err := loadLocalConfig(driverOpts)
+	local, err := prepareLocalConfig(driverOpts)
 	if err != nil {
 		exitError(err)
 	}
+	if *explain {
+		plan := localPlan("metrics setup", local, driverOpts, map[string]any{"action": "setup"})
+		if err := output.PrintJSON(os.Stdout, plan); err != nil {
+			exitError(err)
+		}
+		return
+	}
+	if err := local.connect(driverOpts); err != nil {
+		exitError(err)
+	}
 
 	if err := local.Setup(); err != nil {
 		exitError(err)
@@ -970,7 +2927,7 @@ func metricsSetup(args []string) {
 func runTransponders(args []string) {
 	if len(args) == 0 {
 		transponderUsage()
-		os.Exit(1)
+		os.Exit(exitUsage)
 	}
 
 	switch args[0] {
@@ -982,12 +2939,28 @@ func runTransponders(args []string) {
 		transpondersUpdate(args[1:])
 	case "delete":
 		transpondersDelete(args[1:])
+	case "clone":
+		transpondersClone(args[1:])
+	case "validate":
+		transpondersValidate(args[1:])
+	case "logs":
+		transpondersLogs(args[1:])
+	case "test":
+		transpondersTest(args[1:])
+	case "export":
+		transpondersExport(args[1:])
+	case "apply":
+		transpondersApply(args[1:])
+	case "pause":
+		transpondersSetStatus("pause", transponderPaused, args[1:])
+	case "resume":
+		transpondersSetStatus("resume", transponderActive, args[1:])
 	case "help", "-h", "--help":
 		transponderUsage()
 	default:
 		fmt.Fprintf(os.Stderr, "unknown transponders command: %s\n", args[0])
 		transponderUsage()
-		os.Exit(1)
+		os.Exit(exitUsage)
 	}
 }
 
@@ -1001,8 +2974,31 @@ func transpondersList(args []string) {
 	addConfigFlag(fs, rc.ConfigPath)
 	addSourceFlag(fs, rc.SourceName)
 	opts := addCommonFlags(fs, &rc.Source)
+	listOpts := addTransponderListFlags(fs)
+	format := fs.String("format", "json", "Output format: json|table|csv|markdown")
+	wide := fs.Bool("wide", false, "Show every field in table/csv/markdown output, not just id, name, kind, status, key, and updated_at")
+	var csvDelimiter string
+	addCSVDelimiterFlag(fs, &csvDelimiter)
+	outputOpts := addOutputFlags(fs)
 	fs.Parse(args)
 
+	outputFormat := strings.ToLower(strings.TrimSpace(*format))
+	switch outputFormat {
+	case "json", "table", "csv", "markdown":
+	default:
+		exitError(usageErrorf("unsupported format %q (use json, table, csv, or markdown)", *format))
+	}
+	if err := outputOpts.validate(outputFormat); err != nil {
+		exitError(err)
+	}
+	if err := listOpts.validate(); err != nil {
+		exitError(err)
+	}
+	csvOpts, err := parseCSVDelimiter(csvDelimiter)
+	if err != nil {
+		exitError(err)
+	}
+
 	if driverNameFromSource(rc.Source) != "api" {
 		exitError(errors.New("transponders are only available for api drivers (use --source <api-source>)"))
 	}
@@ -1016,16 +3012,46 @@ func transpondersList(args []string) {
 		exitError(err)
 	}
 
-	var response map[string]any
-	if err := client.GetTransponders(context.Background(), &response); err != nil {
+	response, err := listTransponders(context.Background(), client, listOpts)
+	if err != nil {
 		exitError(err)
 	}
 
-	if err := output.PrintJSON(os.Stdout, response); err != nil {
+	if err := writeTranspondersOutput(outputOpts, response, outputFormat, *wide, output.TableOptions{CSV: csvOpts}); err != nil {
 		exitError(err)
 	}
 }
 
+// writeObjectsOutput prints the response's data array as a table for the
+// table formats, and the whole response as JSON otherwise or when data is
+// not a list of objects.
+func writeObjectsOutput(opts *outputOptions, response map[string]any, format string, preferred []string, tableOpts output.TableOptions) error {
+	objects, ok := responseObjects(response["data"])
+	if format == "json" || !ok {
+		return writeJSONOutput(opts, response)
+	}
+	table := output.TableFromObjects(objects, preferred)
+	return writeCommandOutput(opts, func(w io.Writer) error {
+		return output.WriteTable(w, table, format, tableOpts)
+	})
+}
+
+func responseObjects(value any) ([]map[string]any, bool) {
+	list, ok := value.([]any)
+	if !ok {
+		return nil, false
+	}
+	objects := make([]map[string]any, 0, len(list))
+	for _, item := range list {
+		object, ok := item.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		objects = append(objects, object)
+	}
+	return objects, true
+}
+
 func transpondersCreate(args []string) {
 	rc, err := resolveCommandConfig(args)
 	if err != nil {
@@ -1038,8 +3064,21 @@ func transpondersCreate(args []string) {
 	opts := addCommonFlags(fs, &rc.Source)
 	payloadJSON := fs.String("payload", "", "JSON payload for transponder")
 	payloadFile := fs.String("payload-file", "", "Path to JSON file for payload")
+	noValidate := fs.Bool("no-validate", false, "Send the payload without checking it against the transponder schema")
+	shorthand := addTransponderFlags(fs)
+	quiet := addQuietFlag(fs)
+	outputOpts := addOutputFlags(fs)
 	fs.Parse(args)
 
+	if err := outputOpts.validate(""); err != nil {
+		exitError(err)
+	}
+	if *quiet {
+		if err := validateQuiet(outputOpts, ""); err != nil {
+			exitError(err)
+		}
+	}
+
 	if driverNameFromSource(rc.Source) != "api" {
 		exitError(errors.New("transponders are only available for api drivers (use --source <api-source>)"))
 	}
@@ -1052,12 +3091,23 @@ func transpondersCreate(args []string) {
 	if err != nil {
 		exitError(err)
 	}
-	if payload == nil {
-		exitError(errors.New("--payload or --payload-file is required"))
+	if payload == nil && !shorthand.set() {
+		exitError(usageErrorf("--payload, --payload-file, or --name and --kind are required"))
 	}
-	payloadMap, ok := payload.(map[string]any)
-	if !ok {
-		exitError(errors.New("payload must be a JSON object"))
+	var payloadMap map[string]any
+	if payload != nil {
+		var ok bool
+		if payloadMap, ok = payload.(map[string]any); !ok {
+			exitError(errors.New("payload must be a JSON object"))
+		}
+	}
+	if payloadMap, err = shorthand.merge(payloadMap); err != nil {
+		exitError(err)
+	}
+	if !*noValidate {
+		if err := checkTransponderPayload(payloadMap, false); err != nil {
+			exitError(err)
+		}
 	}
 
 	client, err := newClient(opts)
@@ -1070,7 +3120,18 @@ func transpondersCreate(args []string) {
 		exitError(err)
 	}
 
-	if err := output.PrintJSON(os.Stdout, response); err != nil {
+	if *quiet {
+		id, err := transponderID(response)
+		if err != nil {
+			exitError(err)
+		}
+		if err := writeQuietLines(outputOpts, []string{id}); err != nil {
+			exitError(err)
+		}
+		return
+	}
+
+	if err := writeJSONOutput(outputOpts, response); err != nil {
 		exitError(err)
 	}
 }
@@ -1088,14 +3149,20 @@ func transpondersUpdate(args []string) {
 	id := fs.String("id", "", "Transponder ID")
 	payloadJSON := fs.String("payload", "", "JSON payload for transponder")
 	payloadFile := fs.String("payload-file", "", "Path to JSON file for payload")
+	noValidate := fs.Bool("no-validate", false, "Send the payload without checking it against the transponder schema")
+	outputOpts := addOutputFlags(fs)
 	fs.Parse(args)
 
+	if err := outputOpts.validate(""); err != nil {
+		exitError(err)
+	}
+
 	if driverNameFromSource(rc.Source) != "api" {
 		exitError(errors.New("transponders are only available for api drivers (use --source <api-source>)"))
 	}
 
 	if *id == "" {
-		exitError(errors.New("--id is required"))
+		exitError(usageErrorf("--id is required"))
 	}
 
 	if err := ensureToken(opts, true); err != nil {
@@ -1106,52 +3173,17 @@ func transpondersUpdate(args []string) {
 	if err != nil {
 		exitError(err)
 	}
-	if payload == nil {
-		exitError(errors.New("--payload or --payload-file is required"))
-	}
-	payloadMap, ok := payload.(map[string]any)
-	if !ok {
-		exitError(errors.New("payload must be a JSON object"))
-	}
-
-	client, err := newClient(opts)
-	if err != nil {
-		exitError(err)
-	}
-
-	var response map[string]any
-	if err := client.UpdateTransponder(context.Background(), *id, payloadMap, &response); err != nil {
-		exitError(err)
-	}
-
-	if err := output.PrintJSON(os.Stdout, response); err != nil {
-		exitError(err)
-	}
-}
-
-func transpondersDelete(args []string) {
-	rc, err := resolveCommandConfig(args)
-	if err != nil {
-		exitError(err)
-	}
-
-	fs := flag.NewFlagSet("transponders delete", flag.ExitOnError)
-	addConfigFlag(fs, rc.ConfigPath)
-	addSourceFlag(fs, rc.SourceName)
-	opts := addCommonFlags(fs, &rc.Source)
-	id := fs.String("id", "", "Transponder ID")
-	fs.Parse(args)
-
-	if driverNameFromSource(rc.Source) != "api" {
-		exitError(errors.New("transponders are only available for api drivers (use --source <api-source>)"))
+	if payload == nil {
+		exitError(usageErrorf("--payload or --payload-file is required"))
 	}
-
-	if *id == "" {
-		exitError(errors.New("--id is required"))
+	payloadMap, ok := payload.(map[string]any)
+	if !ok {
+		exitError(errors.New("payload must be a JSON object"))
 	}
-
-	if err := ensureToken(opts, true); err != nil {
-		exitError(err)
+	if !*noValidate {
+		if err := checkTransponderPayload(payloadMap, true); err != nil {
+			exitError(err)
+		}
 	}
 
 	client, err := newClient(opts)
@@ -1160,11 +3192,11 @@ func transpondersDelete(args []string) {
 	}
 
 	var response map[string]any
-	if err := client.DeleteTransponder(context.Background(), *id, &response); err != nil {
+	if err := client.UpdateTransponder(context.Background(), *id, payloadMap, &response); err != nil {
 		exitError(err)
 	}
 
-	if err := output.PrintJSON(os.Stdout, response); err != nil {
+	if err := writeJSONOutput(outputOpts, response); err != nil {
 		exitError(err)
 	}
 }
@@ -1179,8 +3211,9 @@ type seriesData struct {
 }
 
 type keysEntry struct {
-	MetricKey    string `json:"metric_key"`
-	Observations int64  `json:"observations"`
+	MetricKey    string   `json:"metric_key"`
+	Observations int64    `json:"observations"`
+	ValuePaths   []string `json:"value_paths,omitempty"`
 }
 
 type sourceResponse struct {
@@ -1189,9 +3222,33 @@ type sourceResponse struct {
 
 type sourceResponseData struct {
 	DefaultGranularity     string   `json:"default_granularity"`
+	DefaultTimeframe       string   `json:"default_timeframe"`
 	AvailableGranularities []string `json:"available_granularities"`
 }
 
+// sourceLookup fetches the API source at most once so granularity and
+// timeframe defaults share a single GetSource call.
+type sourceLookup struct {
+	client *api.Client
+	data   *sourceResponseData
+}
+
+func newSourceLookup(client *api.Client) *sourceLookup {
+	return &sourceLookup{client: client}
+}
+
+func (s *sourceLookup) Get(ctx context.Context) (sourceResponseData, error) {
+	if s.data != nil {
+		return *s.data, nil
+	}
+	var response sourceResponse
+	if err := s.client.GetSource(ctx, &response); err != nil {
+		return sourceResponseData{}, err
+	}
+	s.data = &response.Data
+	return response.Data, nil
+}
+
 func summarizeKeys(values []map[string]interface{}) []keysEntry {
 	counts := map[string]int64{}
 
@@ -1282,6 +3339,42 @@ func summarizeValuePaths(values []map[string]any) []keysEntry {
 	return entries
 }
 
+func validateKeysSelection(sortBy string, limit int) error {
+	switch strings.ToLower(strings.TrimSpace(sortBy)) {
+	case "", "name", "observations":
+	default:
+		return usageErrorf("unsupported sort %q (use name or observations)", sortBy)
+	}
+	if limit < 0 {
+		return usageErrorf("limit must be zero or positive, got %d", limit)
+	}
+	return nil
+}
+
+// selectKeysEntries orders entries by name or observation count, breaking
+// ties by name, and keeps the first limit entries when limit is positive.
+func selectKeysEntries(entries []keysEntry, sortBy string, desc bool, limit int) []keysEntry {
+	selected := append([]keysEntry(nil), entries...)
+	byObservations := strings.ToLower(strings.TrimSpace(sortBy)) == "observations"
+	sort.SliceStable(selected, func(i, j int) bool {
+		a, b := selected[i], selected[j]
+		if byObservations && a.Observations != b.Observations {
+			if desc {
+				return a.Observations > b.Observations
+			}
+			return a.Observations < b.Observations
+		}
+		if desc && !byObservations {
+			return a.MetricKey > b.MetricKey
+		}
+		return a.MetricKey < b.MetricKey
+	})
+	if limit > 0 && len(selected) > limit {
+		selected = selected[:limit]
+	}
+	return selected
+}
+
 func toInt64(value interface{}) int64 {
 	switch v := value.(type) {
 	case float64:
@@ -1301,42 +3394,501 @@ func toInt64(value interface{}) int64 {
 	return 0
 }
 
-func resolveTimeRange(from, to string) (string, string, error) {
+type timeRangeOptions struct {
+	From      string
+	To        string
+	Last      string
+	Timeframe string
+	// Default is the source default_timeframe, used when no range flag is set.
+	Default string
+}
+
+// needsDefault reports whether the default timeframe shapes the range: no
+// flags at all, or only --to.
+func (o *timeRangeOptions) needsDefault() bool {
+	return strings.TrimSpace(o.From) == "" && strings.TrimSpace(o.Last) == "" && strings.TrimSpace(o.Timeframe) == ""
+}
+
+func (o *timeRangeOptions) isEmpty() bool {
+	for _, value := range []string{o.From, o.To, o.Last, o.Timeframe} {
+		if strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func addTimeRangeFlags(fs *flag.FlagSet) *timeRangeOptions {
+	opts := &timeRangeOptions{}
+	fs.StringVar(&opts.From, "from", "", "Start timestamp (RFC3339, epoch, or relative, e.g. -7d, now-24h); alone runs to now")
+	fs.StringVar(&opts.To, "to", "", "End timestamp (RFC3339, epoch, or relative, e.g. now); alone looks back the default window")
+	fs.StringVar(&opts.Last, "last", "", "Window ending now (e.g. 6h, 7d); replaces --from/--to")
+	fs.StringVar(&opts.Timeframe, "timeframe", "", "Named timeframe ("+strings.Join(timeframePresets, ", ")+"); replaces --from/--to")
+	return opts
+}
+
+// resolveCommandTimeRange resolves the time range flags, expanding a
+// --timeframe preset in the driver time zone and week start. The preset name
+// is normalized in place so it can be used as the timeframe label.
+func resolveCommandTimeRange(timeRange *timeRangeOptions, driverOpts *driverOptions) (string, string, error) {
+	return resolveCommandTimeRangeAt(timeRange, driverOpts, time.Now().UTC())
+}
+
+// resolveSourceTimeRange looks up the API source default_timeframe only when
+// the range has no start.
+func resolveSourceTimeRange(ctx context.Context, source *sourceLookup, timeRange *timeRangeOptions, driverOpts *driverOptions) (string, string, error) {
+	if timeRange.needsDefault() {
+		data, err := source.Get(ctx)
+		if err != nil {
+			return "", "", err
+		}
+		timeRange.Default = data.DefaultTimeframe
+	}
+	return resolveCommandTimeRange(timeRange, driverOpts)
+}
+
+func resolveCommandTimeRangeAt(timeRange *timeRangeOptions, driverOpts *driverOptions, now time.Time) (string, string, error) {
+	if timeRange.isEmpty() && strings.TrimSpace(timeRange.Default) != "" {
+		return resolveDefaultTimeframeAt(timeRange.Default, driverOpts, now)
+	}
+
+	timeRange.Timeframe = strings.ToLower(strings.TrimSpace(timeRange.Timeframe))
+	if timeRange.Timeframe == "" {
+		return resolveTimeRangeWindowAt(timeRange.From, timeRange.To, timeRange.Last, timeRange.Default, now)
+	}
+	if strings.TrimSpace(timeRange.From) != "" || strings.TrimSpace(timeRange.To) != "" || strings.TrimSpace(timeRange.Last) != "" {
+		return "", "", fmt.Errorf("timeframe cannot be combined with from/to/last")
+	}
+	return resolveTimeframePresetAt(timeRange.Timeframe, driverOpts, now)
+}
+
+// resolveDefaultTimeframeAt accepts a default_timeframe given either as a
+// window like 7d or as a preset name like this-week.
+func resolveDefaultTimeframeAt(value string, driverOpts *driverOptions, now time.Time) (string, string, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if containsString(timeframePresets, value) {
+		return resolveTimeframePresetAt(value, driverOpts, now)
+	}
+	if !granularityPattern.MatchString(value) {
+		return "", "", fmt.Errorf("invalid default_timeframe %q (use a window like 7d or one of %s)", value, strings.Join(timeframePresets, ", "))
+	}
+	return resolveTimeRangeAt("", "", value, now)
+}
+
+var timeframePresets = []string{
+	"today", "yesterday", "this-week", "last-week", "this-month", "last-month",
+	"this-quarter", "this-year", "last-7d", "last-30d",
+}
+
+// resolveTimeframePresetAt expands a preset to from/to. Current periods end at
+// now; closed periods end on their last second, matching --align.
+func resolveTimeframePresetAt(name string, driverOpts *driverOptions, now time.Time) (string, string, error) {
+	cfg, err := bucketConfig(driverOpts)
+	if err != nil {
+		return "", "", err
+	}
+
+	current := func(unit triflestats.Unit) (time.Time, time.Time) {
+		return triflestats.NewNocturnal(now, cfg).Floor(1, unit), now
+	}
+	previous := func(unit triflestats.Unit) (time.Time, time.Time) {
+		end := triflestats.NewNocturnal(now, cfg).Floor(1, unit).Add(-time.Second)
+		return triflestats.NewNocturnal(end, cfg).Floor(1, unit), end
+	}
+
+	var from, to time.Time
+	switch name {
+	case "today":
+		from, to = current(triflestats.UnitDay)
+	case "yesterday":
+		from, to = previous(triflestats.UnitDay)
+	case "this-week":
+		from, to = current(triflestats.UnitWeek)
+	case "last-week":
+		from, to = previous(triflestats.UnitWeek)
+	case "this-month":
+		from, to = current(triflestats.UnitMonth)
+	case "last-month":
+		from, to = previous(triflestats.UnitMonth)
+	case "this-quarter":
+		from, to = current(triflestats.UnitQuarter)
+	case "this-year":
+		from, to = current(triflestats.UnitYear)
+	case "last-7d":
+		from, to = now.AddDate(0, 0, -7), now
+	case "last-30d":
+		from, to = now.AddDate(0, 0, -30), now
+	default:
+		return "", "", fmt.Errorf("unknown timeframe %q (use %s)", name, strings.Join(timeframePresets, ", "))
+	}
+
+	return from.UTC().Format(time.RFC3339), to.UTC().Format(time.RFC3339), nil
+}
+
+func resolveTimeRange(from, to, last string) (string, string, error) {
+	return resolveTimeRangeAt(from, to, last, time.Now().UTC())
+}
+
+// resolveTimeRangeAt resolves from/to against a single anchor so relative
+// expressions on both ends agree on what "now" means.
+func resolveTimeRangeAt(from, to, last string, now time.Time) (string, string, error) {
+	return resolveTimeRangeWindowAt(from, to, last, "", now)
+}
+
+// resolveTimeRangeWindowAt also fills open-ended ranges: a lone from runs to
+// now, and a lone to looks back by window (24h when window is not a
+// duration like 7d).
+func resolveTimeRangeWindowAt(from, to, last, window string, now time.Time) (string, string, error) {
 	from = strings.TrimSpace(from)
 	to = strings.TrimSpace(to)
+	last = strings.ToLower(strings.TrimSpace(last))
+
+	if last != "" {
+		if from != "" || to != "" {
+			return "", "", fmt.Errorf("last cannot be combined with from/to")
+		}
+		start, err := resolveLastWindow(last, now)
+		if err != nil {
+			return "", "", err
+		}
+		return start.Format(time.RFC3339), now.Format(time.RFC3339), nil
+	}
 
 	if from == "" && to == "" {
-		now := time.Now().UTC()
 		from = now.Add(-24 * time.Hour).Format(time.RFC3339)
 		to = now.Format(time.RFC3339)
 		return from, to, nil
 	}
 
-	if from == "" || to == "" {
-		return "", "", fmt.Errorf("from and to are required together (RFC3339, e.g. 2024-01-02T15:04:05Z)")
+	if to == "" {
+		to = now.Format(time.RFC3339)
+	}
+	toValue, err := resolveTimestamp("to", to, now)
+	if err != nil {
+		return "", "", err
+	}
+
+	if from == "" {
+		anchor, err := time.Parse(time.RFC3339, toValue)
+		if err != nil {
+			return "", "", err
+		}
+		start := anchor.Add(-24 * time.Hour)
+		if windowStart, err := resolveLastWindow(strings.ToLower(strings.TrimSpace(window)), anchor); err == nil {
+			start = windowStart
+		}
+		from = start.Format(time.RFC3339)
+	}
+	fromValue, err := resolveTimestamp("from", from, now)
+	if err != nil {
+		return "", "", err
 	}
 
-	if err := validateTimestamp("from", from); err != nil {
+	fromTime, err := time.Parse(time.RFC3339, fromValue)
+	if err != nil {
 		return "", "", err
 	}
-	if err := validateTimestamp("to", to); err != nil {
+	toTime, err := time.Parse(time.RFC3339, toValue)
+	if err != nil {
 		return "", "", err
 	}
+	if !fromTime.Before(toTime) {
+		return "", "", fmt.Errorf("from (%s) must be before to (%s)", fromValue, toValue)
+	}
 
-	return from, to, nil
+	return fromValue, toValue, nil
 }
 
-func validateTimestamp(label, value string) error {
-	if _, err := time.Parse(time.RFC3339Nano, value); err != nil {
-		return fmt.Errorf("%s must be RFC3339 (e.g. 2024-01-02T15:04:05Z or 2024-01-02T15:04:05+00:00)", label)
+const maxTimeframePoints = 10000
+
+// maxTableColumns caps how many value paths metrics get shows in a table.
+const maxTableColumns = 12
+
+// approxUnitDurations sizes calendar units for point estimates only.
+var approxUnitDurations = map[triflestats.Unit]time.Duration{
+	triflestats.UnitSecond:  time.Second,
+	triflestats.UnitMinute:  time.Minute,
+	triflestats.UnitHour:    time.Hour,
+	triflestats.UnitDay:     24 * time.Hour,
+	triflestats.UnitWeek:    7 * 24 * time.Hour,
+	triflestats.UnitMonth:   30 * 24 * time.Hour,
+	triflestats.UnitQuarter: 91 * 24 * time.Hour,
+	triflestats.UnitYear:    365 * 24 * time.Hour,
+}
+
+// estimateTimeframePoints approximates the bucket count for from..to, or 0
+// when the inputs cannot be parsed.
+func estimateTimeframePoints(from, to, granularity string) int64 {
+	fromTime, err := time.Parse(time.RFC3339Nano, from)
+	if err != nil {
+		return 0
+	}
+	toTime, err := time.Parse(time.RFC3339Nano, to)
+	if err != nil {
+		return 0
+	}
+	parser := triflestats.NewParser(granularity)
+	if !parser.Valid() {
+		return 0
+	}
+	bucket := approxUnitDurations[parser.Unit] * time.Duration(parser.Offset)
+	if bucket <= 0 {
+		return 0
+	}
+	return int64(toTime.Sub(fromTime)/bucket) + 1
+}
+
+// timeframePointsWarning returns a hint when the timeframe would produce more
+// than maxTimeframePoints buckets at the given granularity, or "" otherwise.
+func timeframePointsWarning(from, to, granularity string) string {
+	points := estimateTimeframePoints(from, to, granularity)
+	if points <= maxTimeframePoints {
+		return ""
+	}
+	return fmt.Sprintf("timeframe %s..%s at %s granularity spans about %d points; consider a coarser granularity", from, to, granularity, points)
+}
+
+func warnTimeframePoints(from, to, granularity string) {
+	if warning := timeframePointsWarning(from, to, granularity); warning != "" {
+		fmt.Fprintln(os.Stderr, "warning: "+warning)
+	}
+}
+
+// validateSlices rejects slice counts below one or above the number of
+// buckets in the timeframe, which would only produce empty slices.
+func validateSlices(slices int, from, to, granularity string) error {
+	if slices < 1 {
+		return usageErrorf("--slices must be at least 1")
+	}
+	if buckets, over := slicesExceedBuckets(slices, from, to, granularity); over {
+		return usageErrorf("--slices %d exceeds the %d %s buckets in %s..%s; use at most %d", slices, buckets, granularity, from, to, buckets)
 	}
 	return nil
 }
 
-func resolveGranularityValue(ctx context.Context, client *api.Client, granularity string) (string, error) {
+// slicesExceedBuckets reports whether slices is more than the estimated
+// bucket count of the timeframe, along with that count.
+func slicesExceedBuckets(slices int, from, to, granularity string) (int64, bool) {
+	buckets := estimateTimeframePoints(from, to, granularity)
+	return buckets, buckets > 0 && int64(slices) > buckets
+}
+
+func resolveLastWindow(last string, now time.Time) (time.Time, error) {
+	match := granularityPattern.FindStringSubmatch(last)
+	if match == nil {
+		return time.Time{}, fmt.Errorf("last must be <number><unit> using s, m, h, d, w, mo, q, y (e.g. 6h, 7d)")
+	}
+	amount, err := strconv.Atoi(strings.TrimSuffix(last, match[1]))
+	if err != nil || amount <= 0 {
+		return time.Time{}, fmt.Errorf("last must be a positive duration (e.g. 6h, 7d)")
+	}
+	return shiftTime(now, -amount, match[1]), nil
+}
+
+func resolveTimestamp(label, value string, now time.Time) (string, error) {
+	if resolved, ok := parseRelativeTime(value, now); ok {
+		return resolved.Format(time.RFC3339), nil
+	}
+	resolved, err := validateTimestamp(label, value)
+	if err != nil {
+		return "", fmt.Errorf("%w; relative values like now, -7d, or now-24h are also accepted", err)
+	}
+	return resolved, nil
+}
+
+// parseRelativeTime understands "now", "-7d", "now-24h" and "now+1h" using the
+// same unit suffixes as granularities.
+func parseRelativeTime(value string, now time.Time) (time.Time, bool) {
+	match := relativeTimePattern.FindStringSubmatch(strings.ToLower(strings.TrimSpace(value)))
+	if match == nil || (match[1] == "" && match[2] == "") {
+		return time.Time{}, false
+	}
+	if match[2] == "" {
+		return now, true
+	}
+
+	amount, err := strconv.Atoi(match[3])
+	if err != nil {
+		return time.Time{}, false
+	}
+	if match[2] == "-" {
+		amount = -amount
+	}
+	return shiftTime(now, amount, match[4]), true
+}
+
+func shiftTime(t time.Time, amount int, unit string) time.Time {
+	switch unit {
+	case "s":
+		return t.Add(time.Duration(amount) * time.Second)
+	case "m":
+		return t.Add(time.Duration(amount) * time.Minute)
+	case "h":
+		return t.Add(time.Duration(amount) * time.Hour)
+	case "d":
+		return t.AddDate(0, 0, amount)
+	case "w":
+		return t.AddDate(0, 0, amount*7)
+	case "mo":
+		return addMonthsClamped(t, amount)
+	case "q":
+		return addMonthsClamped(t, amount*3)
+	case "y":
+		return addMonthsClamped(t, amount*12)
+	default:
+		return t
+	}
+}
+
+// addMonthsClamped keeps the day of month within the target month instead of
+// overflowing like time.AddDate (Mar 31 - 1mo is Feb 28/29, not Mar 3).
+func addMonthsClamped(t time.Time, months int) time.Time {
+	total := t.Year()*12 + int(t.Month()) - 1 + months
+	year := total / 12
+	month := time.Month(total%12 + 1)
+	day := t.Day()
+	if last := time.Date(year, month+1, 0, 0, 0, 0, 0, t.Location()).Day(); day > last {
+		day = last
+	}
+	return time.Date(year, month, day, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+}
+
+// resolvePushAt defaults to now and otherwise keeps the timestamp's original
+// sub-second precision.
+func resolvePushAt(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Now().UTC().Format(time.RFC3339Nano), nil
+	}
+	return validateTimestamp("at", value)
+}
+
+// epochMillisCutoff separates epoch seconds from milliseconds. As seconds it
+// lands in the year 5138; as milliseconds it is March 1973.
+const epochMillisCutoff = 100_000_000_000
+
+// validateTimestamp accepts RFC3339 or integer epoch seconds/milliseconds and
+// returns the value as RFC3339. Epoch values are converted to UTC.
+func validateTimestamp(label, value string) (string, error) {
+	if epoch, ok := parseEpoch(value); ok {
+		return epoch.Format(time.RFC3339Nano), nil
+	}
+	if _, err := time.Parse(time.RFC3339Nano, value); err != nil {
+		return "", fmt.Errorf("%s must be RFC3339 (e.g. 2024-01-02T15:04:05Z or 2024-01-02T15:04:05+00:00) or epoch seconds/milliseconds", label)
+	}
+	return value, nil
+}
+
+func parseEpoch(value string) (time.Time, bool) {
+	if value == "" || strings.TrimLeft(value, "0123456789") != "" {
+		return time.Time{}, false
+	}
+	epoch, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if epoch >= epochMillisCutoff {
+		return time.UnixMilli(epoch).UTC(), true
+	}
+	return time.Unix(epoch, 0).UTC(), true
+}
+
+// alignTimeframe widens from/to to whole granularity buckets using the
+// driver time zone and week start. Timelines include the bucket that `to`
+// falls in, so `to` moves to the last second of that bucket rather than the
+// next boundary, which would pull in an extra bucket.
+func alignTimeframe(fromValue, toValue, granularity string, opts *driverOptions) (string, string, error) {
+	fromTime, err := time.Parse(time.RFC3339Nano, fromValue)
+	if err != nil {
+		return "", "", err
+	}
+	toTime, err := time.Parse(time.RFC3339Nano, toValue)
+	if err != nil {
+		return "", "", err
+	}
+
+	cfg, err := bucketConfig(opts)
+	if err != nil {
+		return "", "", err
+	}
+
+	start, end, err := alignToBuckets(fromTime, toTime, granularity, cfg)
+	if err != nil {
+		return "", "", err
+	}
+	return start.UTC().Format(time.RFC3339), end.UTC().Format(time.RFC3339), nil
+}
+
+func alignToBuckets(from, to time.Time, granularity string, cfg *triflestats.Config) (time.Time, time.Time, error) {
+	parser := triflestats.NewParser(granularity)
+	if !parser.Valid() {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid granularity: %s", granularity)
+	}
+
+	start := triflestats.NewNocturnal(from, cfg).Floor(parser.Offset, parser.Unit)
+	lastBucket := triflestats.NewNocturnal(to, cfg).Floor(parser.Offset, parser.Unit)
+	end := triflestats.NewNocturnal(lastBucket, cfg).Add(parser.Offset, parser.Unit).Add(-time.Second)
+	return start, end, nil
+}
+
+// excludePartialBucket pulls to back to the end of the last complete bucket
+// when it falls inside the bucket that is still in progress.
+func excludePartialBucket(fromValue, toValue, granularity string, opts *driverOptions) (string, bool, error) {
+	return excludePartialBucketAt(fromValue, toValue, granularity, opts, time.Now().UTC())
+}
+
+func excludePartialBucketAt(fromValue, toValue, granularity string, opts *driverOptions, now time.Time) (string, bool, error) {
+	toTime, err := time.Parse(time.RFC3339Nano, toValue)
+	if err != nil {
+		return "", false, err
+	}
+	fromTime, err := time.Parse(time.RFC3339Nano, fromValue)
+	if err != nil {
+		return "", false, err
+	}
+	parser := triflestats.NewParser(granularity)
+	if !parser.Valid() {
+		return "", false, fmt.Errorf("invalid granularity: %s", granularity)
+	}
+	cfg, err := bucketConfig(opts)
+	if err != nil {
+		return "", false, err
+	}
+
+	current := triflestats.NewNocturnal(now, cfg).Floor(parser.Offset, parser.Unit)
+	if toTime.Before(current) {
+		return toValue, false, nil
+	}
+	trimmed := current.Add(-time.Second)
+	if !fromTime.Before(trimmed) {
+		return "", false, fmt.Errorf("no complete %s buckets between %s and %s", granularity, fromValue, toValue)
+	}
+	return trimmed.UTC().Format(time.RFC3339), true, nil
+}
+
+// bucketConfig carries the time zone and week start used for bucket
+// boundaries, for both local drivers and the API driver.
+func bucketConfig(opts *driverOptions) (*triflestats.Config, error) {
+	cfg := triflestats.DefaultConfig()
+	if opts == nil {
+		return cfg, nil
+	}
+	weekStart, err := parseWeekday(opts.BeginningOfWeek)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := time.LoadLocation(opts.TimeZone); err != nil {
+		return nil, fmt.Errorf("invalid timezone: %s", opts.TimeZone)
+	}
+	cfg.TimeZone = opts.TimeZone
+	cfg.BeginningOfWeek = weekStart
+	return cfg, nil
+}
+
+func resolveGranularityValue(ctx context.Context, source *sourceLookup, granularity string) (string, error) {
 	granularity = strings.TrimSpace(granularity)
 	if granularity == "" {
-		return resolveGranularity(ctx, client)
+		return resolveGranularity(ctx, source)
 	}
 	return validateGranularity(granularity)
 }
@@ -1352,17 +3904,17 @@ func validateGranularity(value string) (string, error) {
 	return normalized, nil
 }
 
-func resolveGranularity(ctx context.Context, client *api.Client) (string, error) {
-	var response sourceResponse
-	if err := client.GetSource(ctx, &response); err != nil {
+func resolveGranularity(ctx context.Context, source *sourceLookup) (string, error) {
+	data, err := source.Get(ctx)
+	if err != nil {
 		return "", err
 	}
 
-	if response.Data.DefaultGranularity != "" {
-		return response.Data.DefaultGranularity, nil
+	if data.DefaultGranularity != "" {
+		return data.DefaultGranularity, nil
 	}
 
-	available := response.Data.AvailableGranularities
+	available := data.AvailableGranularities
 	for _, candidate := range []string{"1h", "1d"} {
 		for _, value := range available {
 			if value == candidate {
@@ -1378,9 +3930,26 @@ func resolveGranularity(ctx context.Context, client *api.Client) (string, error)
 	return "1h", nil
 }
 
-func queryMetrics(ctx context.Context, client *api.Client, payload map[string]any) (map[string]any, error) {
+func queryMetrics(ctx context.Context, client *api.Client, payload map[string]any, weekStart string) (map[string]any, error) {
+	granularity, _ := payload["granularity"].(string)
+	weekStartValue, err := weekStartParam(granularity, weekStart)
+	if err != nil {
+		return nil, err
+	}
+	if weekStartValue != "" {
+		payload["week_start"] = weekStartValue
+	}
+
 	var response map[string]any
-	if err := client.QueryMetrics(ctx, payload, &response); err != nil {
+	err = client.QueryMetrics(ctx, payload, &response)
+	if weekStartValue != "" && isWeekStartRejected(err) {
+		warnWeekStartIgnored()
+		client.RecordRetry()
+		delete(payload, "week_start")
+		response = nil
+		err = client.QueryMetrics(ctx, payload, &response)
+	}
+	if err != nil {
 		return nil, err
 	}
 
@@ -1397,6 +3966,61 @@ func queryMetrics(ctx context.Context, client *api.Client, payload map[string]an
 	return data, nil
 }
 
+// getMetrics sends week_start alongside weekly granularities so API buckets
+// match local drivers, retrying without it if the server rejects it.
+func getMetrics(ctx context.Context, client *api.Client, params map[string]string, weekStart string, out any) error {
+	weekStartValue, err := weekStartParam(params["granularity"], weekStart)
+	if err != nil {
+		return err
+	}
+	if weekStartValue == "" {
+		return client.GetMetrics(ctx, params, out)
+	}
+
+	withWeekStart := make(map[string]string, len(params)+1)
+	for key, value := range params {
+		withWeekStart[key] = value
+	}
+	withWeekStart["week_start"] = weekStartValue
+
+	err = client.GetMetrics(ctx, withWeekStart, out)
+	if isWeekStartRejected(err) {
+		warnWeekStartIgnored()
+		client.RecordRetry()
+		return client.GetMetrics(ctx, params, out)
+	}
+	return err
+}
+
+// weekStartParam returns the normalized week start for weekly granularities
+// and "" for everything else.
+func weekStartParam(granularity, weekStart string) (string, error) {
+	parser := triflestats.NewParser(granularity)
+	if !parser.Valid() || parser.Unit != triflestats.UnitWeek || strings.TrimSpace(weekStart) == "" {
+		return "", nil
+	}
+	weekday, err := parseWeekday(weekStart)
+	if err != nil {
+		return "", err
+	}
+	return strings.ToLower(weekday.String()), nil
+}
+
+func isWeekStartRejected(err error) bool {
+	var apiErr *api.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	if apiErr.StatusCode != http.StatusBadRequest && apiErr.StatusCode != http.StatusUnprocessableEntity {
+		return false
+	}
+	return strings.Contains(apiErr.Body, "week_start")
+}
+
+func warnWeekStartIgnored() {
+	fmt.Fprintln(os.Stderr, "warning: server does not support week_start; weekly buckets use the server default")
+}
+
 type driverOptions struct {
 	Driver          string
 	DBPath          string
@@ -1422,6 +4046,39 @@ type driverOptions struct {
 	BufferAsync     bool
 }
 
+func addDisplayTimeZoneFlag(fs *flag.FlagSet, cfg *sourceConfig) *string {
+	var cfgDisplayTimeZone string
+	if cfg != nil {
+		cfgDisplayTimeZone = cfg.DisplayTimeZone
+	}
+	return fs.String("display-tz", pickString(os.Getenv("TRIFLE_DISPLAY_TIMEZONE"), cfgDisplayTimeZone, ""), "Time zone for table/csv timestamps; JSON stays UTC (or TRIFLE_DISPLAY_TIMEZONE / config)")
+}
+
+func addSlicesFlag(fs *flag.FlagSet, cfg *sourceConfig) *int {
+	slices := 1
+	if cfg != nil && cfg.DefaultSlices > 0 {
+		slices = cfg.DefaultSlices
+	}
+	return fs.Int("slices", slices, "Optional number of slices (or default_slices in config)")
+}
+
+// resolveDisplayLocation returns nil when no display zone is set, leaving
+// table timestamps as returned.
+func resolveDisplayLocation(name string) (*time.Location, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, nil
+	}
+	if strings.EqualFold(name, "local") {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid display timezone %q (use an IANA name like Europe/Bratislava)", name)
+	}
+	return loc, nil
+}
+
 func addDriverFlags(fs *flag.FlagSet, cfg *sourceConfig) *driverOptions {
 	var cfgDriver string
 	var cfgDB string
@@ -1531,7 +4188,7 @@ func addDriverFlags(fs *flag.FlagSet, cfg *sourceConfig) *driverOptions {
 	fs.StringVar(&opts.Joined, "joined", opts.Joined, "Identifier mode: full|partial|separated (or TRIFLE_JOINED / config)")
 	fs.StringVar(&opts.Separator, "separator", opts.Separator, "Key separator (or TRIFLE_SEPARATOR / config)")
 	fs.StringVar(&opts.TimeZone, "timezone", opts.TimeZone, "Time zone (or TRIFLE_TIMEZONE / config)")
-	fs.StringVar(&opts.BeginningOfWeek, "week-start", opts.BeginningOfWeek, "Week start: monday..sunday (or TRIFLE_WEEK_START / config)")
+	fs.StringVar(&opts.BeginningOfWeek, "week-start", opts.BeginningOfWeek, "Week start for weekly buckets on local and API drivers: monday..sunday (or TRIFLE_WEEK_START / config)")
 	fs.StringVar(&opts.Granularities, "granularities", opts.Granularities, "Comma-separated granularities (or TRIFLE_GRANULARITIES / config)")
 	fs.StringVar(&opts.BufferMode, "buffer-mode", opts.BufferMode, "Buffer mode: auto|on|off")
 	fs.StringVar(&opts.BufferDrivers, "buffer-drivers", opts.BufferDrivers, "Comma-separated drivers allowed to buffer when mode is auto/on")
@@ -1620,21 +4277,27 @@ func driverNameFromSource(source sourceConfig) string {
 	return strings.ToLower(strings.TrimSpace(pickString(os.Getenv("TRIFLE_DRIVER"), source.Driver, "api")))
 }
 
+// isMissingStorageError reports whether err looks like the driver's table
+// has not been created yet.
+func isMissingStorageError(err error) bool {
+	messageLower := strings.ToLower(err.Error())
+	return strings.Contains(messageLower, "no such table") ||
+		strings.Contains(messageLower, "doesn't exist") ||
+		strings.Contains(messageLower, "relation")
+}
+
 func maybeSuggestSetup(err error, driverName, targetName string) error {
 	if err == nil {
 		return nil
 	}
 	message := err.Error()
-	messageLower := strings.ToLower(message)
 
 	normalizedDriver := normalizeDriverName(driverName)
 	if normalizedDriver == "" {
 		normalizedDriver = "sqlite"
 	}
 
-	if !strings.Contains(messageLower, "no such table") &&
-		!strings.Contains(messageLower, "doesn't exist") &&
-		!strings.Contains(messageLower, "relation") {
+	if !isMissingStorageError(err) {
 		return err
 	}
 
@@ -1680,6 +4343,24 @@ func ensureValuesMap(values any) (map[string]any, error) {
 	}
 }
 
+// resolveWriteMode normalizes a track/assert write mode. The API metrics
+// endpoint only tracks, so assert is rejected there rather than silently
+// sent as a track.
+func resolveWriteMode(mode string, local bool) (string, error) {
+	value := strings.ToLower(strings.TrimSpace(mode))
+	switch value {
+	case "", "track":
+		return "track", nil
+	case "assert":
+		if !local {
+			return "", usageErrorf("assert mode is not supported by the api driver (the metrics endpoint only tracks); use a local driver to overwrite values")
+		}
+		return value, nil
+	default:
+		return "", usageErrorf("invalid mode: %s (expected track or assert)", mode)
+	}
+}
+
 func performLocalWrite(cfg *triflestats.Config, mode, key string, at time.Time, values map[string]any) error {
 	switch strings.ToLower(strings.TrimSpace(mode)) {
 	case "", "track":
@@ -1723,17 +4404,71 @@ func usage() {
 	fmt.Println()
 	fmt.Println("Read metrics:")
 	fmt.Println("  trifle metrics get --key event::logs --from 2026-01-01T00:00:00Z --to 2026-01-31T00:00:00Z --granularity 1d")
+	fmt.Println("  trifle metrics get --key event::logs --from -7d --to now --granularity 1h")
+	fmt.Println("  trifle metrics get --key event::logs --last 6h")
+	fmt.Println("  trifle metrics get event::logs --last 6h   # the key may be given without --key")
+	fmt.Println("  trifle metrics get --key event::logs --last 7d --granularity 1m --format ndjson --flush | jq -c .values")
+	fmt.Println("  trifle metrics get --key event::logs --from -7d --to now --granularity 1d --align")
+	fmt.Println("  trifle metrics get --key event::logs --last 24h --granularity 1h --format table")
+	fmt.Println("  trifle metrics keys --last 7d --format markdown")
+	fmt.Println("  trifle metrics keys --last 7d --key-prefix event:: --format table   # --prefix is the redis driver's key prefix")
+	fmt.Println("  trifle metrics keys --last 7d --match '^event::logs::.*$' --format table")
+	fmt.Println("  trifle metrics keys --last 24h --with-paths --format table")
+	fmt.Println("  trifle metrics get --key event::logs --last 24h --granularity 1h --query 'data.values[].count'")
+	fmt.Println("  trifle metrics get --key event::logs --last 30d --granularity 1h --compact > series.json")
+	fmt.Println("  trifle metrics get --key event::logs --last 24h --granularity 1h --template '{{range $i, $at := .data.at}}{{formatTime \"15:04\" $at}} {{(index $.data.values $i).count}}{{\"\\n\"}}{{end}}'")
+	fmt.Println("  trifle metrics timeline --key event::logs --value-path count --last 30d --granularity 1d --format csv --output reports/daily.csv")
+	fmt.Println("  trifle metrics aggregate --key event::logs --value-path count --aggregator sum --timeframe today")
+	fmt.Println("  trifle metrics get --key event::logs --last 24h --granularity 1h --explain")
+	fmt.Println("  trifle metrics get --key event::logs --last 30d --granularity 1h --stats > series.json")
+	fmt.Println("  trifle metrics export --key event::logs --from 2026-01-01T00:00:00Z --to 2026-02-01T00:00:00Z --granularity 1h --out dump.ndjson")
+	fmt.Println("  trifle metrics export --driver sqlite --db ./stats.db --all-keys --last 30d --granularity 1h --out dump.ndjson")
 	fmt.Println()
 	fmt.Println("Format series:")
 	fmt.Println("  trifle metrics timeline --key event::logs --value-path count --from 2026-01-01T00:00:00Z --to 2026-01-31T00:00:00Z --granularity 1d")
+	fmt.Println("  trifle metrics timeline --key event::logs --value-path count --last 24h --granularity 1h --format spark")
+	fmt.Println("  trifle metrics timeline --key event::logs --value-path status --last 7d --granularity 1h --chart status.svg")
 	fmt.Println("  trifle metrics category --key event::logs --value-path duration --from 2026-01-01T00:00:00Z --to 2026-01-31T00:00:00Z --granularity 1d")
+	fmt.Println("  trifle metrics category --key event::logs --value-path status --last 7d --sort value --desc --other-below 1 --format table")
+	fmt.Println("  trifle metrics category --key api::requests --value-path endpoints --last 24h --top 10 --sort value --desc --format table")
+	fmt.Println("  trifle metrics timeline --key event::logs --value-path duration --last 7d --granularity 1d --format table --precision 2")
+	fmt.Println("  trifle metrics timeline --key event::logs --value-path count --last 30d --granularity 1h --format table --transpose")
+	fmt.Println("  trifle metrics get --key event::logs --last 30d --granularity 1h --format table --tail 24")
+	fmt.Println("  trifle metrics timeline --key event::logs --value-path duration --last 7d --granularity 1d --format csv --columns at,duration.sum")
+	fmt.Println("  trifle metrics timeline --key event::logs --value-path count --last 30d --granularity 1d --format csv --csv-delimiter ';' --output daily.csv")
+	fmt.Println("  trifle metrics timeline --driver sqlite --db ./stats.db --key event::logs --value-path 'duration.*.p95' --last 7d --granularity 1d --format table")
+	fmt.Println("  trifle metrics timeline --key api::requests_total --value-path count --last 24h --granularity 1h --transform rate --format table")
+	fmt.Println("  trifle metrics timeline --key event::logs --value-path count --last 30d --granularity 1d --smooth 7 --format table")
+	fmt.Println("  trifle metrics timeline --key event::logs --value-path count --last 7d --granularity 1h --anomalies 3 --format table")
+	fmt.Println("  trifle metrics timeline --key event::logs --value-path count --last 7d --granularity 1h --summary --format table")
+	fmt.Println("  trifle metrics keys --last 7d --granularity 1d --compare previous --format table")
 	fmt.Println()
 	fmt.Println("Aggregate series:")
 	fmt.Println("  trifle metrics aggregate --key event::logs --value-path count --aggregator sum --from 2026-01-01T00:00:00Z --to 2026-01-31T00:00:00Z --granularity 1d")
+	fmt.Println("  trifle metrics aggregate --key event::logs --value-path count --aggregator sum --last 7d --granularity 1d --compare previous --format table")
+	fmt.Println("  trifle metrics aggregate --key event::logs --value-path count --aggregator sum --last 24h --granularity 1h --slices 4 --format prom")
+	fmt.Println("  trifle metrics aggregate --key event::logs --value-path count --aggregator sum --from 2026-01-01T00:00:00Z --to 2026-01-31T00:00:00Z --granularity 1d --format summary")
+	fmt.Println("  trifle metrics aggregate --key event::logs --value-path status.ok --value-path status.error --aggregator sum --last 24h --format table")
+	fmt.Println("  trifle metrics aggregate --key event::logs --expr 'status.error / (status.ok + status.error)' --aggregator sum --last 7d --granularity 1d --slices 7")
+	fmt.Println("  trifle metrics aggregate --key-prefix event::logs:: --value-path count --aggregator sum --combine sum --last 7d --format table")
+	fmt.Println("  trifle metrics aggregate --key api::requests --value-path duration --aggregator stddev --last 7d --granularity 1h")
+	fmt.Println("  trifle metrics aggregate --key api::requests --value-path duration --aggregator p95 --last 24h --granularity 5m --slices 24")
+	fmt.Println("  TOTAL=$(trifle metrics aggregate --key event::logs --value-path count --aggregator sum --timeframe today --quiet)")
+	fmt.Println("  trifle metrics top --value-path count --aggregator sum --limit 10 --last 7d --granularity 1d --key-prefix event:: --format table")
 	fmt.Println()
 	fmt.Println("Submit data:")
 	fmt.Println("  trifle metrics push --key event::logs --values '{\"count\":1,\"duration\":2.4}'")
 	fmt.Println("  trifle metrics push --key event::logs --values '{\"count\":1}' --at 2026-01-01T12:00:00Z")
+	fmt.Println("  trifle metrics push --key event::logs --set count=1 --set duration=2.4 --set status.ok=1")
+	fmt.Println("  trifle metrics push --key event::logs --values-file backfill.json   # [{\"at\":\"2026-01-01T12:00:00Z\",\"values\":{\"count\":1}}, ...]")
+	fmt.Println("  echo '{\"count\":3}' | trifle metrics push --key event::logs --values -")
+	fmt.Println("  trifle metrics push --driver sqlite --db ./stats.db --key event::logs --values-file backfill.json --mode if-absent   # rerun safely")
+	fmt.Println("  trifle metrics bench --driver postgres --duration 30s --concurrency 8 --payload '{\"count\":1}' --format summary --cleanup")
+	fmt.Println("  trifle metrics validate --key event::logs --values '{\"cout\":1}'   # checks schemas: in the config")
+	fmt.Println("  tail -f points.ndjson | trifle metrics push --key event::logs --stream")
+	fmt.Println("  trifle metrics import --driver sqlite --db ./stats.db --file dump.ndjson")
+	fmt.Println("  trifle metrics import --file dump.ndjson --dry-run")
+	fmt.Println("  trifle metrics copy --from-source sqlite-local --to-source pg-prod --key event::logs --last 30d --granularity 1h")
 	fmt.Println()
 	fmt.Println("Local drivers:")
 	fmt.Println("  trifle metrics setup --driver sqlite --db ./stats.db")
@@ -1741,6 +4476,15 @@ func usage() {
 	fmt.Println("  trifle metrics setup --driver postgres --host 127.0.0.1 --port 5432 --user postgres --password password --database trifle_stats")
 	fmt.Println("  trifle metrics setup --driver mysql --host 127.0.0.1 --port 3306 --user root --password password --database trifle_stats")
 	fmt.Println("  trifle metrics setup --driver mongo --dsn mongodb://127.0.0.1:27017 --database trifle_stats --collection trifle_stats")
+	fmt.Println("  trifle metrics selftest --driver postgres --host 127.0.0.1 --port 5432 --user postgres --password password --database trifle_stats --format summary")
+	fmt.Println("  trifle metrics prune --driver sqlite --db ./stats.db --key event::logs --from 2026-01-01T00:00:00Z --to 2026-01-02T00:00:00Z --granularity 1h --dry-run")
+	fmt.Println("  trifle metrics check --key event::errors --value-path count --aggregator sum --last 1h --warn 100 --crit 500")
+	fmt.Println("  trifle metrics timeline --driver sqlite --db ./stats.db --key-prefix event::logs:: --group-by-suffix --value-path count --last 1d --format table")
+	fmt.Println("  trifle metrics get --driver sqlite --db ./stats.db --key event::logs --last 7d --granularity 1h --resample 1d --resample-agg sum --format table")
+	fmt.Println("  trifle metrics diff --key event::logs --value-path count --source-a sqlite-local --source-b pg-prod --last 7d --granularity 1h")
+	fmt.Println("  trifle metrics describe --key event::logs --last 7d --granularity 1d --format table")
+	fmt.Println("  trifle metrics latest --key system::queue_depth --value-path depth --window 6h --quiet")
+	fmt.Println("  trifle metrics histogram --key event::logs --value-path duration --last 24h --buckets 0,0.1,0.5,1,5 --format table")
 	fmt.Println("  trifle metrics get --driver redis --prefix trifle:metrics --key event::logs --from 2026-01-01T00:00:00Z --to 2026-01-31T00:00:00Z --granularity 1d")
 	fmt.Println()
 	fmt.Println("Commands:")
@@ -1761,10 +4505,23 @@ func metricsUsage() {
 	fmt.Println("Commands:")
 	fmt.Println("  get       Fetch raw timeseries data")
 	fmt.Println("  keys      List available metric keys")
+	fmt.Println("  top       Rank keys by an aggregated value")
 	fmt.Println("  aggregate Aggregate a metric series")
 	fmt.Println("  timeline  Format a metric timeline")
 	fmt.Println("  category  Format a metric category breakdown")
+	fmt.Println("  export    Dump series to NDJSON for backup or migration")
+	fmt.Println("  import    Write an NDJSON dump back into a source")
+	fmt.Println("  copy      Copy a key between two configured sources")
+	fmt.Println("  prune     Delete stored buckets for a key (local drivers)")
+	fmt.Println("  diff      Compare a key across two sources (exits 1 on differences)")
+	fmt.Println("  describe  Profile a key's value paths and non-empty buckets")
+	fmt.Println("  histogram Count a value path's points per value range")
+	fmt.Println("  latest    Print the most recent value of a path (e.g. a gauge)")
+	fmt.Println("  bench     Measure write or read throughput of a local driver")
+	fmt.Println("  selftest  Push a value, read it back, and clean up (local drivers)")
+	fmt.Println("  check     Compare an aggregate against thresholds (exit 0 OK, 1 WARNING, 2 CRITICAL, 3 UNKNOWN)")
 	fmt.Println("  push      Submit a metric payload")
+	fmt.Println("  validate  Check a payload against the key's schema without writing")
 	fmt.Println("  setup     Initialize local storage (sqlite/postgres/mysql/mongo; redis is no-op)")
 }
 
@@ -1772,18 +4529,33 @@ func transponderUsage() {
 	fmt.Println("trifle transponders <command> [options]")
 	fmt.Println()
 	fmt.Println("Commands:")
-	fmt.Println("  list    List transponders")
-	fmt.Println("  create  Create a transponder")
-	fmt.Println("  update  Update a transponder")
-	fmt.Println("  delete  Delete a transponder")
-}
-
-func exitError(err error) {
-	var apiErr *api.Error
-	if errors.As(err, &apiErr) {
-		fmt.Fprintln(os.Stderr, apiErr.Error())
-	} else if err != nil {
-		fmt.Fprintln(os.Stderr, err.Error())
-	}
-	os.Exit(1)
+	fmt.Println("  list      List transponders")
+	fmt.Println("  create    Create a transponder")
+	fmt.Println("  update    Update a transponder")
+	fmt.Println("  delete    Delete transponders by --id or --match")
+	fmt.Println("  clone     Copy a transponder under a new name")
+	fmt.Println("  pause     Pause a transponder")
+	fmt.Println("  resume    Resume a paused transponder")
+	fmt.Println("  validate  Check a transponder payload without calling the API")
+	fmt.Println("  logs      Show a transponder's recent deliveries")
+	fmt.Println("  test      Fire a transponder once and report the delivery")
+	fmt.Println("  export    Write every transponder to a YAML bundle")
+	fmt.Println("  apply     Create, update, and optionally prune transponders from a YAML bundle")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  trifle transponders list --status paused --name-contains billing --format table")
+	fmt.Println("  trifle transponders list --all --limit 100")
+	fmt.Println("  trifle transponders list --format csv --wide --output transponders.csv")
+	fmt.Println("  trifle transponders create --name 'High error rate' --kind threshold --key event::logs --value-path status.error --threshold 50 --interval 5m")
+	fmt.Println("  trifle transponders create --name 'Ingest heartbeat' --kind heartbeat --key event::logs --interval 15m")
+	fmt.Println("  trifle transponders create --name Custom --kind webhook --payload-file webhook.json   # kinds without shorthand need --payload")
+	fmt.Println("  trifle transponders pause --id 42")
+	fmt.Println("  trifle transponders delete --match 'name~^loadtest-' --yes")
+	fmt.Println("  trifle transponders clone --id 42 --name 'Checkout errors' --set key=event::checkout --set config.threshold=20")
+	fmt.Println("  trifle transponders validate --payload-file transponder.json")
+	fmt.Println("  trifle transponders logs --id 42 --limit 20")
+	fmt.Println("  trifle transponders test --id 42")
+	fmt.Println("  trifle transponders export --out transponders.yaml")
+	fmt.Println("  trifle transponders apply --file transponders.yaml --prune --dry-run")
+	fmt.Println("  trifle transponders apply --file transponders.yaml --prune --yes")
 }
@@ -0,0 +1,1164 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/trifle-io/trifle-cli/internal/api"
+	"github.com/trifle-io/trifle-cli/internal/output"
+	triflestats "github.com/trifle-io/trifle_stats_go"
+)
+
+func TestParseRelativeTime(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 3, 31, 12, 30, 0, 0, time.UTC)
+
+	cases := []struct {
+		input string
+		want  time.Time
+	}{
+		{input: "now", want: now},
+		{input: "NOW", want: now},
+		{input: "-7d", want: time.Date(2026, 3, 24, 12, 30, 0, 0, time.UTC)},
+		{input: "now-24h", want: time.Date(2026, 3, 30, 12, 30, 0, 0, time.UTC)},
+		{input: "now+1h", want: time.Date(2026, 3, 31, 13, 30, 0, 0, time.UTC)},
+		{input: "-15m", want: time.Date(2026, 3, 31, 12, 15, 0, 0, time.UTC)},
+		{input: "-30s", want: time.Date(2026, 3, 31, 12, 29, 30, 0, time.UTC)},
+		{input: "-2w", want: time.Date(2026, 3, 17, 12, 30, 0, 0, time.UTC)},
+		{input: "-1mo", want: time.Date(2026, 2, 28, 12, 30, 0, 0, time.UTC)},
+		{input: "now-1q", want: time.Date(2025, 12, 31, 12, 30, 0, 0, time.UTC)},
+		{input: "-1y", want: time.Date(2025, 3, 31, 12, 30, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range cases {
+		got, ok := parseRelativeTime(tt.input, now)
+		if !ok {
+			t.Fatalf("parseRelativeTime(%q) not recognized", tt.input)
+		}
+		if !got.Equal(tt.want) {
+			t.Fatalf("parseRelativeTime(%q) = %s, want %s", tt.input, got, tt.want)
+		}
+	}
+
+	for _, input := range []string{"", "7d", "now-", "-7x", "yesterday", "2026-01-01T00:00:00Z"} {
+		if _, ok := parseRelativeTime(input, now); ok {
+			t.Fatalf("parseRelativeTime(%q) should not be recognized", input)
+		}
+	}
+}
+
+func TestResolveTimeRangeAt(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC)
+
+	from, to, err := resolveTimeRangeAt("-7d", "now", "", now)
+	if err != nil {
+		t.Fatalf("relative range returned error: %v", err)
+	}
+	if from != "2026-01-03T00:00:00Z" || to != "2026-01-10T00:00:00Z" {
+		t.Fatalf("relative range = %s..%s", from, to)
+	}
+
+	from, to, err = resolveTimeRangeAt("2026-01-01T00:00:00Z", "now-1d", "", now)
+	if err != nil {
+		t.Fatalf("mixed range returned error: %v", err)
+	}
+	if from != "2026-01-01T00:00:00Z" || to != "2026-01-09T00:00:00Z" {
+		t.Fatalf("mixed range = %s..%s", from, to)
+	}
+
+	from, to, err = resolveTimeRangeAt("", "", "", now)
+	if err != nil {
+		t.Fatalf("default range returned error: %v", err)
+	}
+	if from != "2026-01-09T00:00:00Z" || to != "2026-01-10T00:00:00Z" {
+		t.Fatalf("default range = %s..%s", from, to)
+	}
+
+	for _, tt := range []struct{ from, to string }{{"now", "-7d"}, {"now", "now"}, {"2026-01-02T00:00:00Z", "2026-01-01T00:00:00Z"}} {
+		_, _, err := resolveTimeRangeAt(tt.from, tt.to, "", now)
+		if err == nil || !strings.Contains(err.Error(), "must be before to") {
+			t.Fatalf("expected ordering error for from=%q to=%q, got %v", tt.from, tt.to, err)
+		}
+	}
+
+	_, _, err = resolveTimeRangeAt("last tuesday", "now", "", now)
+	if err == nil || !strings.Contains(err.Error(), "from must be RFC3339") {
+		t.Fatalf("expected RFC3339 error, got %v", err)
+	}
+}
+
+func TestResolveTimeRangeAtLast(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC)
+
+	from, to, err := resolveTimeRangeAt("", "", "6h", now)
+	if err != nil {
+		t.Fatalf("last returned error: %v", err)
+	}
+	if from != "2026-01-10T06:00:00Z" || to != "2026-01-10T12:00:00Z" {
+		t.Fatalf("last range = %s..%s", from, to)
+	}
+
+	from, _, err = resolveTimeRangeAt("", "", "1mo", now)
+	if err != nil || from != "2025-12-10T12:00:00Z" {
+		t.Fatalf("last 1mo = %s (err %v)", from, err)
+	}
+
+	for _, tt := range []struct{ from, to string }{{"-1d", ""}, {"", "now"}, {"-1d", "now"}} {
+		_, _, err := resolveTimeRangeAt(tt.from, tt.to, "6h", now)
+		if err == nil || !strings.Contains(err.Error(), "cannot be combined") {
+			t.Fatalf("expected conflict error for from=%q to=%q, got %v", tt.from, tt.to, err)
+		}
+	}
+
+	for _, last := range []string{"6", "h", "-6h", "0h", "6x"} {
+		if _, _, err := resolveTimeRangeAt("", "", last, now); err == nil {
+			t.Fatalf("expected error for last=%q", last)
+		}
+	}
+}
+
+func TestAlignTimeframe(t *testing.T) {
+	t.Parallel()
+
+	monday := &driverOptions{TimeZone: "UTC", BeginningOfWeek: "monday"}
+	sunday := &driverOptions{TimeZone: "UTC", BeginningOfWeek: "sunday"}
+	bratislava := &driverOptions{TimeZone: "Europe/Bratislava", BeginningOfWeek: "monday"}
+
+	cases := []struct {
+		name        string
+		from, to    string
+		granularity string
+		opts        *driverOptions
+		wantFrom    string
+		wantTo      string
+	}{
+		{"hour", "2026-01-10T10:17:00Z", "2026-01-10T12:05:00Z", "1h", monday, "2026-01-10T10:00:00Z", "2026-01-10T12:59:59Z"},
+		{"hour on boundary", "2026-01-10T10:00:00Z", "2026-01-10T12:00:00Z", "1h", monday, "2026-01-10T10:00:00Z", "2026-01-10T12:59:59Z"},
+		{"quarter hour", "2026-01-10T10:17:00Z", "2026-01-10T10:31:00Z", "15m", monday, "2026-01-10T10:15:00Z", "2026-01-10T10:44:59Z"},
+		{"day", "2026-01-10T10:17:00Z", "2026-01-12T00:00:01Z", "1d", monday, "2026-01-10T00:00:00Z", "2026-01-12T23:59:59Z"},
+		{"week monday", "2026-01-07T12:00:00Z", "2026-01-14T12:00:00Z", "1w", monday, "2026-01-05T00:00:00Z", "2026-01-18T23:59:59Z"},
+		{"week sunday", "2026-01-07T12:00:00Z", "2026-01-14T12:00:00Z", "1w", sunday, "2026-01-04T00:00:00Z", "2026-01-17T23:59:59Z"},
+		{"month end", "2026-01-31T23:00:00Z", "2026-02-01T00:00:00Z", "1mo", monday, "2026-01-01T00:00:00Z", "2026-02-28T23:59:59Z"},
+		{"leap month", "2028-02-29T12:00:00Z", "2028-02-29T12:00:00Z", "1mo", monday, "2028-02-01T00:00:00Z", "2028-02-29T23:59:59Z"},
+		{"day in zone", "2026-01-10T23:30:00Z", "2026-01-11T10:00:00Z", "1d", bratislava, "2026-01-10T23:00:00Z", "2026-01-11T22:59:59Z"},
+	}
+
+	for _, tt := range cases {
+		from, to, err := alignTimeframe(tt.from, tt.to, tt.granularity, tt.opts)
+		if err != nil {
+			t.Fatalf("%s: alignTimeframe returned error: %v", tt.name, err)
+		}
+		if from != tt.wantFrom || to != tt.wantTo {
+			t.Fatalf("%s: alignTimeframe = %s..%s, want %s..%s", tt.name, from, to, tt.wantFrom, tt.wantTo)
+		}
+	}
+
+	if _, _, err := alignTimeframe("2026-01-10T10:00:00Z", "2026-01-10T12:00:00Z", "hourly", monday); err == nil {
+		t.Fatalf("expected error for invalid granularity")
+	}
+	if _, _, err := alignTimeframe("2026-01-10T10:00:00Z", "2026-01-10T12:00:00Z", "1h", &driverOptions{TimeZone: "Mars/Olympus", BeginningOfWeek: "monday"}); err == nil {
+		t.Fatalf("expected error for invalid timezone")
+	}
+}
+
+func TestTimeframePointsWarning(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		from, to    string
+		granularity string
+		warn        bool
+	}{
+		{"2026-01-01T00:00:00Z", "2026-01-31T00:00:00Z", "1h", false},
+		{"2026-01-01T00:00:00Z", "2026-01-31T00:00:00Z", "1m", true},
+		{"2026-01-01T00:00:00Z", "2026-01-07T22:39:00Z", "1m", false},
+		{"2026-01-01T00:00:00Z", "2026-01-07T22:40:00Z", "1m", true},
+		{"2016-01-01T00:00:00Z", "2026-01-01T00:00:00Z", "1d", false},
+		{"2016-01-01T00:00:00Z", "2026-01-01T00:00:00Z", "1h", true},
+		{"2016-01-01T00:00:00Z", "2026-01-01T00:00:00Z", "bogus", false},
+	}
+
+	for _, tt := range cases {
+		warning := timeframePointsWarning(tt.from, tt.to, tt.granularity)
+		if (warning != "") != tt.warn {
+			t.Fatalf("timeframePointsWarning(%s, %s, %s) = %q, want warning %v", tt.from, tt.to, tt.granularity, warning, tt.warn)
+		}
+		if tt.warn && !strings.Contains(warning, "coarser granularity") {
+			t.Fatalf("timeframePointsWarning(%s, %s, %s) = %q, want a coarser granularity hint", tt.from, tt.to, tt.granularity, warning)
+		}
+	}
+}
+
+func TestValidateSlices(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		slices      int
+		granularity string
+		wantErr     string
+	}{
+		{1, "1h", ""},
+		{25, "1h", ""},
+		{26, "1h", "--slices 26 exceeds the 25 1h buckets"},
+		{100000, "1d", "--slices 100000 exceeds the 2 1d buckets"},
+		{0, "1h", "--slices must be at least 1"},
+		{100000, "bogus", ""},
+	}
+
+	for _, tt := range cases {
+		err := validateSlices(tt.slices, "2026-01-01T00:00:00Z", "2026-01-02T00:00:00Z", tt.granularity)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Fatalf("validateSlices(%d, %s) returned error: %v", tt.slices, tt.granularity, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Fatalf("validateSlices(%d, %s) = %v, want %q", tt.slices, tt.granularity, err, tt.wantErr)
+		}
+	}
+}
+
+func TestAddSlicesFlagDefault(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		cfg  *sourceConfig
+		want int
+	}{
+		{nil, 1},
+		{&sourceConfig{}, 1},
+		{&sourceConfig{DefaultSlices: 4}, 4},
+	}
+
+	for _, tt := range cases {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		slices := addSlicesFlag(fs, tt.cfg)
+		if err := fs.Parse(nil); err != nil {
+			t.Fatalf("parse returned error: %v", err)
+		}
+		if *slices != tt.want {
+			t.Fatalf("slices = %d, want %d", *slices, tt.want)
+		}
+	}
+}
+
+func TestValidateTimestampEpoch(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		input string
+		want  string
+	}{
+		{input: "1735689600", want: "2025-01-01T00:00:00Z"},
+		{input: "1735689600000", want: "2025-01-01T00:00:00Z"},
+		{input: "1735689600123", want: "2025-01-01T00:00:00.123Z"},
+		{input: "0", want: "1970-01-01T00:00:00Z"},
+		{input: "99999999999", want: "5138-11-16T09:46:39Z"},
+		{input: "100000000000", want: "1973-03-03T09:46:40Z"},
+		{input: "2025-01-01T00:00:00Z", want: "2025-01-01T00:00:00Z"},
+		{input: "2025-01-01T01:00:00+01:00", want: "2025-01-01T01:00:00+01:00"},
+	}
+
+	for _, tt := range cases {
+		got, err := validateTimestamp("at", tt.input)
+		if err != nil {
+			t.Fatalf("validateTimestamp(%q) returned error: %v", tt.input, err)
+		}
+		if got != tt.want {
+			t.Fatalf("validateTimestamp(%q) = %s, want %s", tt.input, got, tt.want)
+		}
+	}
+
+	for _, input := range []string{"", "1735689600.5", "+1735689600", "17356x", "99999999999999999999"} {
+		if _, err := validateTimestamp("at", input); err == nil {
+			t.Fatalf("validateTimestamp(%q) should fail", input)
+		}
+	}
+
+	now := time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC)
+	from, to, err := resolveTimeRangeAt("1735689600", "1735776000000", "", now)
+	if err != nil {
+		t.Fatalf("epoch range returned error: %v", err)
+	}
+	if from != "2025-01-01T00:00:00Z" || to != "2025-01-02T00:00:00Z" {
+		t.Fatalf("epoch range = %s..%s", from, to)
+	}
+}
+
+func TestResolveTimeframePreset(t *testing.T) {
+	t.Parallel()
+
+	// Wednesday afternoon UTC, already Thursday in Tokyo.
+	now := time.Date(2026, 2, 18, 16, 30, 0, 0, time.UTC)
+	utc := &driverOptions{TimeZone: "UTC", BeginningOfWeek: "monday"}
+	sunday := &driverOptions{TimeZone: "UTC", BeginningOfWeek: "sunday"}
+	tokyo := &driverOptions{TimeZone: "Asia/Tokyo", BeginningOfWeek: "monday"}
+
+	cases := []struct {
+		preset   string
+		opts     *driverOptions
+		wantFrom string
+		wantTo   string
+	}{
+		{"today", utc, "2026-02-18T00:00:00Z", "2026-02-18T16:30:00Z"},
+		{"yesterday", utc, "2026-02-17T00:00:00Z", "2026-02-17T23:59:59Z"},
+		{"this-week", utc, "2026-02-16T00:00:00Z", "2026-02-18T16:30:00Z"},
+		{"this-week", sunday, "2026-02-15T00:00:00Z", "2026-02-18T16:30:00Z"},
+		{"last-week", utc, "2026-02-09T00:00:00Z", "2026-02-15T23:59:59Z"},
+		{"this-month", utc, "2026-02-01T00:00:00Z", "2026-02-18T16:30:00Z"},
+		{"last-month", utc, "2026-01-01T00:00:00Z", "2026-01-31T23:59:59Z"},
+		{"this-quarter", utc, "2026-01-01T00:00:00Z", "2026-02-18T16:30:00Z"},
+		{"this-year", utc, "2026-01-01T00:00:00Z", "2026-02-18T16:30:00Z"},
+		{"last-7d", utc, "2026-02-11T16:30:00Z", "2026-02-18T16:30:00Z"},
+		{"last-30d", utc, "2026-01-19T16:30:00Z", "2026-02-18T16:30:00Z"},
+		{"today", tokyo, "2026-02-18T15:00:00Z", "2026-02-18T16:30:00Z"},
+		{"yesterday", tokyo, "2026-02-17T15:00:00Z", "2026-02-18T14:59:59Z"},
+	}
+
+	for _, tt := range cases {
+		from, to, err := resolveTimeframePresetAt(tt.preset, tt.opts, now)
+		if err != nil {
+			t.Fatalf("%s (%s): returned error: %v", tt.preset, tt.opts.TimeZone, err)
+		}
+		if from != tt.wantFrom || to != tt.wantTo {
+			t.Fatalf("%s (%s) = %s..%s, want %s..%s", tt.preset, tt.opts.TimeZone, from, to, tt.wantFrom, tt.wantTo)
+		}
+	}
+
+	if _, _, err := resolveTimeframePresetAt("fortnight", utc, now); err == nil {
+		t.Fatalf("expected error for unknown preset")
+	}
+
+	timeRange := &timeRangeOptions{Timeframe: " Today "}
+	if _, _, err := resolveCommandTimeRangeAt(timeRange, utc, now); err != nil || timeRange.Timeframe != "today" {
+		t.Fatalf("resolveCommandTimeRangeAt normalized %q (err %v), want today", timeRange.Timeframe, err)
+	}
+	for _, timeRange := range []*timeRangeOptions{
+		{Timeframe: "today", From: "-1d"},
+		{Timeframe: "today", To: "now"},
+		{Timeframe: "today", Last: "6h"},
+	} {
+		if _, _, err := resolveCommandTimeRangeAt(timeRange, utc, now); err == nil || !strings.Contains(err.Error(), "cannot be combined") {
+			t.Fatalf("expected conflict error for %+v, got %v", timeRange, err)
+		}
+	}
+}
+
+func TestDisplayTimeZone(t *testing.T) {
+	t.Parallel()
+
+	if loc, err := resolveDisplayLocation(""); err != nil || loc != nil {
+		t.Fatalf("resolveDisplayLocation(\"\") = %v, %v, want nil, nil", loc, err)
+	}
+	if loc, err := resolveDisplayLocation("local"); err != nil || loc != time.Local {
+		t.Fatalf("resolveDisplayLocation(local) = %v, %v, want time.Local", loc, err)
+	}
+	if _, err := resolveDisplayLocation("Europe/Nowhere"); err == nil || !strings.Contains(err.Error(), "invalid display timezone") {
+		t.Fatalf("expected invalid display timezone error, got %v", err)
+	}
+
+	loc, err := resolveDisplayLocation("Europe/Bratislava")
+	if err != nil {
+		t.Fatalf("resolveDisplayLocation returned error: %v", err)
+	}
+
+	table := output.Table{
+		Columns: []string{"at", "count"},
+		Rows: [][]string{
+			{"2026-01-10T23:00:00Z", "3"},
+			{"2026-07-10T23:00:00Z", "2026-07-10T23:00:00Z"},
+			{"not-a-time", "1"},
+		},
+	}
+	got := output.LocalizeColumn(table, "at", loc)
+	want := [][]string{
+		{"2026-01-11T00:00:00+01:00", "3"},
+		{"2026-07-11T01:00:00+02:00", "2026-07-10T23:00:00Z"},
+		{"not-a-time", "1"},
+	}
+	for i := range want {
+		for j := range want[i] {
+			if got.Rows[i][j] != want[i][j] {
+				t.Fatalf("LocalizeColumn row %d col %d = %s, want %s", i, j, got.Rows[i][j], want[i][j])
+			}
+		}
+	}
+	if table.Rows[0][0] != "2026-01-10T23:00:00Z" {
+		t.Fatalf("LocalizeColumn modified its input: %v", table.Rows[0])
+	}
+}
+
+func TestResolveDefaultTimeframe(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 2, 18, 16, 30, 0, 0, time.UTC)
+	utc := &driverOptions{TimeZone: "UTC", BeginningOfWeek: "monday"}
+
+	cases := []struct {
+		def      string
+		wantFrom string
+	}{
+		{"7d", "2026-02-11T16:30:00Z"},
+		{"24h", "2026-02-17T16:30:00Z"},
+		{"this-month", "2026-02-01T00:00:00Z"},
+	}
+	for _, tt := range cases {
+		from, to, err := resolveCommandTimeRangeAt(&timeRangeOptions{Default: tt.def}, utc, now)
+		if err != nil {
+			t.Fatalf("default %s returned error: %v", tt.def, err)
+		}
+		if from != tt.wantFrom || to != "2026-02-18T16:30:00Z" {
+			t.Fatalf("default %s = %s..%s, want %s..now", tt.def, from, to, tt.wantFrom)
+		}
+	}
+
+	from, _, err := resolveCommandTimeRangeAt(&timeRangeOptions{Default: "7d", Last: "1h"}, utc, now)
+	if err != nil || from != "2026-02-18T15:30:00Z" {
+		t.Fatalf("explicit --last should win over default, got %s (err %v)", from, err)
+	}
+
+	if _, _, err := resolveCommandTimeRangeAt(&timeRangeOptions{Default: "forever"}, utc, now); err == nil {
+		t.Fatalf("expected error for invalid default_timeframe")
+	}
+}
+
+func TestSourceLookupFetchesOnce(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/source" {
+			t.Fatalf("path = %s", r.URL.Path)
+		}
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"data":{"default_granularity":"1d","default_timeframe":"7d"}}`))
+	}))
+	defer server.Close()
+
+	client, err := api.New(server.URL, "token", 5*time.Second)
+	if err != nil {
+		t.Fatalf("api.New returned error: %v", err)
+	}
+	source := newSourceLookup(client)
+	ctx := context.Background()
+
+	timeRange := &timeRangeOptions{}
+	from, to, err := resolveSourceTimeRange(ctx, source, timeRange, &driverOptions{TimeZone: "UTC", BeginningOfWeek: "monday"})
+	if err != nil {
+		t.Fatalf("resolveSourceTimeRange returned error: %v", err)
+	}
+	fromTime, _ := time.Parse(time.RFC3339, from)
+	toTime, _ := time.Parse(time.RFC3339, to)
+	if toTime.Sub(fromTime) != 7*24*time.Hour {
+		t.Fatalf("default window = %s, want 168h", toTime.Sub(fromTime))
+	}
+
+	granularity, err := resolveGranularityValue(ctx, source, "")
+	if err != nil || granularity != "1d" {
+		t.Fatalf("resolveGranularityValue = %q (err %v), want 1d", granularity, err)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("GetSource calls = %d, want 1", got)
+	}
+
+	if _, _, err := resolveSourceTimeRange(ctx, newSourceLookup(client), &timeRangeOptions{Last: "1h"}, nil); err != nil {
+		t.Fatalf("resolveSourceTimeRange with --last returned error: %v", err)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("explicit range should skip GetSource, calls = %d", got)
+	}
+}
+
+func TestPushBatch(t *testing.T) {
+	t.Parallel()
+
+	points := []any{
+		map[string]any{"at": "2026-01-01T00:00:00Z", "values": map[string]any{"count": 1}},
+		map[string]any{"at": float64(1767229200), "values": map[string]any{"count": 2}},
+		map[string]any{"values": map[string]any{"count": 3}},
+		map[string]any{"at": "yesterday", "values": map[string]any{"count": 4}},
+		map[string]any{"at": "2026-01-01T03:00:00Z", "values": "count"},
+		"not-a-point",
+		map[string]any{"at": "2026-01-01T04:00:00Z", "values": map[string]any{"count": 5}},
+	}
+
+	var written []string
+	summary := pushBatch("event::logs", points, "2026-01-01T02:00:00Z", false, func(at string, values map[string]any) error {
+		written = append(written, at)
+		return nil
+	})
+	if summary.Total != 7 || summary.Written != 4 || summary.Failed != 3 {
+		t.Fatalf("summary = %+v, want 7 total, 4 written, 3 failed", summary)
+	}
+	wantWritten := []string{"2026-01-01T00:00:00Z", "2026-01-01T01:00:00Z", "2026-01-01T02:00:00Z", "2026-01-01T04:00:00Z"}
+	if strings.Join(written, ",") != strings.Join(wantWritten, ",") {
+		t.Fatalf("written = %v, want %v", written, wantWritten)
+	}
+	for i, index := range []int{3, 4, 5} {
+		if summary.Failures[i].Index != index {
+			t.Fatalf("failure %d index = %d, want %d", i, summary.Failures[i].Index, index)
+		}
+	}
+
+	calls := 0
+	summary = pushBatch("event::logs", points, "2026-01-01T02:00:00Z", true, func(at string, values map[string]any) error {
+		calls++
+		if calls == 2 {
+			return errors.New("write failed")
+		}
+		return nil
+	})
+	if calls != 2 || summary.Written != 1 || summary.Failed != 1 || summary.Failures[0].Error != "write failed" {
+		t.Fatalf("fail-fast summary = %+v after %d calls", summary, calls)
+	}
+}
+
+func TestResolveWriteMode(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		mode    string
+		local   bool
+		want    string
+		wantErr string
+	}{
+		{mode: "", local: false, want: "track"},
+		{mode: " Track ", local: false, want: "track"},
+		{mode: "assert", local: true, want: "assert"},
+		{mode: "assert", local: false, wantErr: "not supported by the api driver"},
+		{mode: "replace", local: true, wantErr: "invalid mode"},
+	}
+	for _, tt := range tests {
+		got, err := resolveWriteMode(tt.mode, tt.local)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("resolveWriteMode(%q, %v) error = %v, want %q", tt.mode, tt.local, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Fatalf("resolveWriteMode(%q, %v) = %q (err %v), want %q", tt.mode, tt.local, got, err, tt.want)
+		}
+	}
+}
+
+func TestGetMetricsWeekStart(t *testing.T) {
+	t.Parallel()
+
+	var seen []string
+	rejectWeekStart := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		weekStart := r.URL.Query().Get("week_start")
+		seen = append(seen, weekStart)
+		if rejectWeekStart && weekStart != "" {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			_, _ = w.Write([]byte(`{"errors":{"week_start":["is not a permitted parameter"]}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":{"at":[],"values":[]}}`))
+	}))
+	defer server.Close()
+
+	client, err := api.New(server.URL, "token", 5*time.Second)
+	if err != nil {
+		t.Fatalf("api.New returned error: %v", err)
+	}
+	ctx := context.Background()
+
+	cases := []struct {
+		granularity string
+		weekStart   string
+		want        string
+	}{
+		{"1w", "Sun", "sunday"},
+		{"2w", "monday", "monday"},
+		{"1d", "sunday", ""},
+		{"1w", "", ""},
+	}
+	for _, tt := range cases {
+		seen = nil
+		var response map[string]any
+		params := map[string]string{"granularity": tt.granularity}
+		if err := getMetrics(ctx, client, params, tt.weekStart, &response); err != nil {
+			t.Fatalf("getMetrics(%s, %s) returned error: %v", tt.granularity, tt.weekStart, err)
+		}
+		if len(seen) != 1 || seen[0] != tt.want {
+			t.Fatalf("getMetrics(%s, %s) sent week_start %v, want %q", tt.granularity, tt.weekStart, seen, tt.want)
+		}
+		if _, ok := params["week_start"]; ok {
+			t.Fatalf("getMetrics mutated params: %v", params)
+		}
+	}
+
+	rejectWeekStart = true
+	seen = nil
+	var response map[string]any
+	if err := getMetrics(ctx, client, map[string]string{"granularity": "1w"}, "sunday", &response); err != nil {
+		t.Fatalf("getMetrics should retry without week_start, got %v", err)
+	}
+	if strings.Join(seen, ",") != "sunday," {
+		t.Fatalf("requests = %q, want a retry without week_start", seen)
+	}
+
+	if err := getMetrics(ctx, client, map[string]string{"granularity": "1w"}, "someday", &response); err == nil {
+		t.Fatalf("expected error for invalid week start")
+	}
+}
+
+func TestResolveTimeRangeOpenEnded(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name     string
+		from, to string
+		window   string
+		wantFrom string
+		wantTo   string
+	}{
+		{"neither", "", "", "", "2026-01-09T12:00:00Z", "2026-01-10T12:00:00Z"},
+		{"both", "2026-01-01T00:00:00Z", "2026-01-02T00:00:00Z", "", "2026-01-01T00:00:00Z", "2026-01-02T00:00:00Z"},
+		{"only from", "2026-01-01T00:00:00Z", "", "", "2026-01-01T00:00:00Z", "2026-01-10T12:00:00Z"},
+		{"only relative from", "-3d", "", "", "2026-01-07T12:00:00Z", "2026-01-10T12:00:00Z"},
+		{"only to", "", "2026-01-05T00:00:00Z", "", "2026-01-04T00:00:00Z", "2026-01-05T00:00:00Z"},
+		{"only to with window", "", "2026-01-05T00:00:00Z", "7d", "2025-12-29T00:00:00Z", "2026-01-05T00:00:00Z"},
+		{"only to with preset window", "", "2026-01-05T00:00:00Z", "this-week", "2026-01-04T00:00:00Z", "2026-01-05T00:00:00Z"},
+	}
+
+	for _, tt := range cases {
+		from, to, err := resolveTimeRangeWindowAt(tt.from, tt.to, "", tt.window, now)
+		if err != nil {
+			t.Fatalf("%s: returned error: %v", tt.name, err)
+		}
+		if from != tt.wantFrom || to != tt.wantTo {
+			t.Fatalf("%s: range = %s..%s, want %s..%s", tt.name, from, to, tt.wantFrom, tt.wantTo)
+		}
+	}
+
+	if _, _, err := resolveTimeRangeAt("now+1d", "", "", now); err == nil || !strings.Contains(err.Error(), "must be before to") {
+		t.Fatalf("expected ordering error for a future lone from, got %v", err)
+	}
+}
+
+func TestExcludePartialBucket(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 1, 10, 15, 20, 0, 0, time.UTC)
+	utc := &driverOptions{TimeZone: "UTC", BeginningOfWeek: "monday"}
+	bratislava := &driverOptions{TimeZone: "Europe/Bratislava", BeginningOfWeek: "monday"}
+
+	cases := []struct {
+		name        string
+		from, to    string
+		granularity string
+		opts        *driverOptions
+		wantTo      string
+		wantTrimmed bool
+	}{
+		{"daily to now", "2026-01-01T00:00:00Z", "2026-01-10T15:20:00Z", "1d", utc, "2026-01-09T23:59:59Z", true},
+		{"hourly to now", "2026-01-10T00:00:00Z", "2026-01-10T15:20:00Z", "1h", utc, "2026-01-10T14:59:59Z", true},
+		{"to already complete", "2026-01-01T00:00:00Z", "2026-01-09T12:00:00Z", "1d", utc, "2026-01-09T12:00:00Z", false},
+		{"to in the future", "2026-01-01T00:00:00Z", "2026-01-12T00:00:00Z", "1d", utc, "2026-01-09T23:59:59Z", true},
+		{"daily in zone", "2026-01-01T00:00:00Z", "2026-01-10T15:20:00Z", "1d", bratislava, "2026-01-09T22:59:59Z", true},
+	}
+
+	for _, tt := range cases {
+		to, trimmed, err := excludePartialBucketAt(tt.from, tt.to, tt.granularity, tt.opts, now)
+		if err != nil {
+			t.Fatalf("%s: returned error: %v", tt.name, err)
+		}
+		if to != tt.wantTo || trimmed != tt.wantTrimmed {
+			t.Fatalf("%s: = %s, %v, want %s, %v", tt.name, to, trimmed, tt.wantTo, tt.wantTrimmed)
+		}
+	}
+
+	if _, _, err := excludePartialBucketAt("2026-01-10T02:00:00Z", "2026-01-10T15:20:00Z", "1d", utc, now); err == nil {
+		t.Fatalf("expected error when only the partial bucket is selected")
+	}
+}
+
+func TestWriteJSONOutputQuery(t *testing.T) {
+	t.Parallel()
+
+	doc := map[string]any{
+		"status": "ok",
+		"data":   map[string]any{"values": []map[string]any{{"count": 3}, {"count": 1}}},
+	}
+	cases := []struct {
+		query string
+		want  string
+	}{
+		{"status", "ok\n"},
+		{"data.values[0].count", "3\n"},
+		{"data.values[].count", "[\n  3,\n  1\n]\n"},
+		{"data.values[0]", "{\n  \"count\": 3\n}\n"},
+	}
+	for _, tt := range cases {
+		path := filepath.Join(t.TempDir(), "query.json")
+		if err := writeJSONOutput(&outputOptions{Path: path, Query: tt.query}, doc); err != nil {
+			t.Fatalf("writeJSONOutput(%q) returned error: %v", tt.query, err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("read output: %v", err)
+		}
+		if string(data) != tt.want {
+			t.Fatalf("--query %s printed %q, want %q", tt.query, data, tt.want)
+		}
+	}
+}
+
+func TestWriteCommandOutputFile(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "reports", "daily", "metrics.json")
+	if err := writeJSONOutput(&outputOptions{Path: path}, map[string]any{"count": 1}); err != nil {
+		t.Fatalf("writeJSONOutput returned error: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read output: %v", err)
+	}
+	if string(data) != "{\n  \"count\": 1\n}\n" {
+		t.Fatalf("output = %q", data)
+	}
+
+	failure := errors.New("query failed")
+	err = writeCommandOutput(&outputOptions{Path: path}, func(w io.Writer) error {
+		io.WriteString(w, "partial")
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("writeCommandOutput error = %v, want %v", err, failure)
+	}
+	if after, _ := os.ReadFile(path); string(after) != string(data) {
+		t.Fatalf("failed write replaced output with %q", after)
+	}
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatalf("read dir: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("output dir has %d entries, want only the result file", len(entries))
+	}
+}
+
+func TestBuildValuesTable(t *testing.T) {
+	t.Parallel()
+
+	at := []time.Time{
+		time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2026, 1, 1, 1, 0, 0, 0, time.UTC),
+	}
+	result := triflestats.ValuesResult{
+		At: at,
+		Values: []map[string]any{
+			{"count": 3, "duration": map[string]any{"sum": 1.5}},
+			{"count": 1, "status": map[string]any{"ok": 1}},
+		},
+	}
+
+	payload, hidden := buildValuesTable(result, 0)
+	table, ok := output.ExtractTable(map[string]any{"table": payload}, output.TableOptions{Precision: -1})
+	if !ok || hidden != 0 {
+		t.Fatalf("buildValuesTable returned %v (hidden %d)", payload, hidden)
+	}
+	wantColumns := []string{"at", "count", "duration.sum", "status.ok"}
+	if strings.Join(table.Columns, ",") != strings.Join(wantColumns, ",") {
+		t.Fatalf("columns = %v, want %v", table.Columns, wantColumns)
+	}
+	wantRows := [][]string{
+		{"2026-01-01T00:00:00Z", "3", "1.5", ""},
+		{"2026-01-01T01:00:00Z", "1", "", "1"},
+	}
+	for i, row := range wantRows {
+		if strings.Join(table.Rows[i], ",") != strings.Join(row, ",") {
+			t.Fatalf("row %d = %v, want %v", i, table.Rows[i], row)
+		}
+	}
+
+	payload, hidden = buildValuesTable(result, 2)
+	table, _ = output.ExtractTable(map[string]any{"table": payload}, output.TableOptions{Precision: -1})
+	if hidden != 1 || len(table.Columns) != 3 {
+		t.Fatalf("capped table has columns %v and %d hidden, want 3 columns and 1 hidden", table.Columns, hidden)
+	}
+
+	payload, _ = buildValuesTable(triflestats.ValuesResult{At: at, Values: []map[string]any{nil, nil}}, 0)
+	table, _ = output.ExtractTable(map[string]any{"table": payload}, output.TableOptions{Precision: -1})
+	if len(table.Columns) != 1 || len(table.Rows) != 2 {
+		t.Fatalf("empty series table = %v, want an at column with 2 rows", table)
+	}
+}
+
+func TestSelectKeysEntries(t *testing.T) {
+	t.Parallel()
+
+	entries := []keysEntry{
+		{MetricKey: "api::latency", Observations: 5},
+		{MetricKey: "event::logs", Observations: 40},
+		{MetricKey: "event::signups", Observations: 5},
+		{MetricKey: "jobs::failed", Observations: 12},
+	}
+
+	cases := []struct {
+		sortBy string
+		desc   bool
+		limit  int
+		want   []string
+	}{
+		{"name", false, 0, []string{"api::latency", "event::logs", "event::signups", "jobs::failed"}},
+		{"name", true, 2, []string{"jobs::failed", "event::signups"}},
+		{"observations", false, 0, []string{"api::latency", "event::signups", "jobs::failed", "event::logs"}},
+		{"observations", true, 3, []string{"event::logs", "jobs::failed", "api::latency"}},
+		{"", false, 10, []string{"api::latency", "event::logs", "event::signups", "jobs::failed"}},
+	}
+
+	for _, tt := range cases {
+		selected := selectKeysEntries(entries, tt.sortBy, tt.desc, tt.limit)
+		got := make([]string, len(selected))
+		for i, entry := range selected {
+			got[i] = entry.MetricKey
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Fatalf("selectKeysEntries(%q, %v, %d) = %v, want %v", tt.sortBy, tt.desc, tt.limit, got, tt.want)
+		}
+	}
+	if entries[0].MetricKey != "api::latency" || entries[1].MetricKey != "event::logs" {
+		t.Fatalf("selectKeysEntries reordered its input: %v", entries)
+	}
+
+	for _, tc := range []struct {
+		sortBy string
+		limit  int
+	}{{"size", 0}, {"name", -1}} {
+		err := validateKeysSelection(tc.sortBy, tc.limit)
+		if err == nil {
+			t.Fatalf("validateKeysSelection(%q, %d) accepted bad input", tc.sortBy, tc.limit)
+		}
+		if _, _, code := classifyError(err); code != exitUsage {
+			t.Fatalf("validateKeysSelection(%q, %d) exit code = %d, want %d", tc.sortBy, tc.limit, code, exitUsage)
+		}
+	}
+}
+
+func TestOutputOptionsValidate(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		opts    outputOptions
+		format  string
+		wantErr string
+	}{
+		{outputOptions{Query: "data"}, "json", ""},
+		{outputOptions{Query: "data"}, "table", "--query requires --format json"},
+		{outputOptions{Template: "{{.data}}"}, "", ""},
+		{outputOptions{Template: "{{.data}}"}, "csv", "--template requires --format json"},
+		{outputOptions{Template: "{{.data"}, "json", "parse template"},
+		{outputOptions{Query: "data", Template: "{{.data}}"}, "json", "cannot be combined"},
+	}
+
+	for _, tt := range cases {
+		err := tt.opts.validate(tt.format)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Fatalf("validate(%+v, %q) returned error: %v", tt.opts, tt.format, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Fatalf("validate(%+v, %q) error = %v, want %q", tt.opts, tt.format, err, tt.wantErr)
+		}
+	}
+}
+
+func TestPrintValuesTableCSV(t *testing.T) {
+	t.Parallel()
+
+	result := triflestats.ValuesResult{
+		At: []time.Time{
+			time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(2026, 1, 1, 1, 0, 0, 0, time.UTC),
+			time.Date(2026, 1, 1, 2, 0, 0, 0, time.UTC),
+		},
+		Values: []map[string]any{
+			{"count": int64(2), "status": map[string]any{"label": "ok, \"fine\""}},
+			nil,
+			{"count": 0.25, "tags": []any{"a", "b"}},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := printValuesTable(&buf, result, "csv", false, output.TableOptions{Precision: -1}); err != nil {
+		t.Fatalf("printValuesTable returned error: %v", err)
+	}
+	want := "at,count,status.label,tags\n" +
+		"2026-01-01T00:00:00Z,2,\"ok, \"\"fine\"\"\",\n" +
+		"2026-01-01T01:00:00Z,,,\n" +
+		"2026-01-01T02:00:00Z,0.25,,\"[\"\"a\"\",\"\"b\"\"]\"\n"
+	if buf.String() != want {
+		t.Fatalf("csv =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestAggregateSliceRanges(t *testing.T) {
+	t.Parallel()
+
+	cfg := triflestats.DefaultConfig()
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 1, 1, 9, 30, 0, 0, time.UTC)
+
+	single := aggregateSliceRanges(from, to, "1h", cfg, 1)
+	if len(single) != 1 || !single[0].From.Equal(from) || !single[0].To.Equal(to) {
+		t.Fatalf("single slice ranges = %v, want [%v..%v]", single, from, to)
+	}
+
+	// Ten hourly buckets split into three slices drop the first bucket.
+	ranges := aggregateSliceRanges(from, to, "1h", cfg, 3)
+	want := []timeChunk{
+		{From: from.Add(1 * time.Hour), To: from.Add(4 * time.Hour)},
+		{From: from.Add(4 * time.Hour), To: from.Add(7 * time.Hour)},
+		{From: from.Add(7 * time.Hour), To: to},
+	}
+	if len(ranges) != len(want) {
+		t.Fatalf("slice ranges = %v, want %v", ranges, want)
+	}
+	for i := range want {
+		if !ranges[i].From.Equal(want[i].From) || !ranges[i].To.Equal(want[i].To) {
+			t.Fatalf("slice ranges[%d] = %v, want %v", i, ranges[i], want[i])
+		}
+	}
+
+	// A to on a bucket boundary includes that bucket, so the last slice
+	// ends an hour after it.
+	boundary := from.Add(7 * time.Hour)
+	for count, last := range map[int]timeChunk{
+		8: {From: boundary, To: from.Add(8 * time.Hour)},
+		3: {From: from.Add(6 * time.Hour), To: from.Add(8 * time.Hour)},
+	} {
+		got := aggregateSliceRanges(from, boundary, "1h", cfg, count)
+		if n := len(got); n != count || !got[n-1].From.Equal(last.From) || !got[n-1].To.Equal(last.To) {
+			t.Fatalf("%d slices to %v = %v, want last %v", count, boundary, got, last)
+		}
+	}
+
+	samples := buildAggregateSamples(aggregateResult{Key: "event::logs", ValuePath: "count", Aggregator: "sum", Values: []any{1, nil, 2.5}, Ranges: ranges})
+	if len(samples) != 2 || samples[1].Value != 2.5 || !samples[1].At.Equal(want[2].To) {
+		t.Fatalf("buildAggregateSamples = %+v", samples)
+	}
+}
+
+func TestSliceRangesPayload(t *testing.T) {
+	t.Parallel()
+
+	cfg := triflestats.DefaultConfig()
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 1, 1, 9, 30, 0, 0, time.UTC)
+
+	ranges := sliceRangesPayload(aggregateSliceRanges(from, to, "1h", cfg, 3))
+	want := []any{
+		map[string]any{"from": "2026-01-01T01:00:00Z", "to": "2026-01-01T04:00:00Z"},
+		map[string]any{"from": "2026-01-01T04:00:00Z", "to": "2026-01-01T07:00:00Z"},
+		map[string]any{"from": "2026-01-01T07:00:00Z", "to": "2026-01-01T09:30:00Z"},
+	}
+	if !reflect.DeepEqual(ranges, want) {
+		t.Fatalf("sliceRangesPayload = %v, want %v", ranges, want)
+	}
+
+	single := sliceRangesPayload(aggregateSliceRanges(from, to, "1h", cfg, 1))
+	if !reflect.DeepEqual(single, []any{map[string]any{"from": "2026-01-01T00:00:00Z", "to": "2026-01-01T09:30:00Z"}}) {
+		t.Fatalf("single slice = %v", single)
+	}
+
+	// More slices than buckets leave the bounds empty.
+	empty := sliceRangesPayload(aggregateSliceRanges(from, from.Add(time.Hour), "1h", cfg, 3))
+	if len(empty) != 3 || empty[0].(map[string]any)["from"] != nil {
+		t.Fatalf("empty slices = %v", empty)
+	}
+
+	payload := map[string]any{"table": map[string]any{"columns": []any{"at", "count"}}}
+	applySliceRanges(payload, ranges, []string{"count"}, map[string][]any{"count": {3.0, nil, 5.0}})
+	table := payload["table"].(map[string]any)
+	if !reflect.DeepEqual(table["columns"], []any{"slice", "from", "to", "count"}) {
+		t.Fatalf("columns = %v", table["columns"])
+	}
+	rows := table["rows"].([]any)
+	if !reflect.DeepEqual(rows[1], []any{2, "2026-01-01T04:00:00Z", "2026-01-01T07:00:00Z", nil}) {
+		t.Fatalf("rows[1] = %v", rows[1])
+	}
+	if !reflect.DeepEqual(payload["slice_ranges"], ranges) {
+		t.Fatalf("slice_ranges = %v", payload["slice_ranges"])
+	}
+}
+
+func TestPrintAggregateSummary(t *testing.T) {
+	t.Parallel()
+
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	result := aggregateResult{
+		Key:         "event::logs",
+		ValuePath:   "count",
+		Aggregator:  "sum",
+		Granularity: "1d",
+		Values:      []any{12345.0, nil},
+		Ranges: []timeChunk{
+			{From: from, To: from.AddDate(0, 0, 30)},
+			{From: from.Add(time.Hour), To: from.Add(90 * time.Minute)},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := printAggregateSummary(&buf, result, nil, -1, false); err != nil {
+		t.Fatalf("printAggregateSummary returned error: %v", err)
+	}
+	want := "sum(count) for event::logs over 2026-01-01 → 2026-01-31 (1d): 12,345\n" +
+		"sum(count) for event::logs over 2026-01-01 01:00 → 2026-01-01 01:30 (1d): no data\n"
+	if buf.String() != want {
+		t.Fatalf("printAggregateSummary =\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	buf.Reset()
+	result.Values = []any{0.5}
+	result.Ranges = result.Ranges[:1]
+	if err := printAggregateSummary(&buf, result, nil, -1, true); err != nil {
+		t.Fatalf("printAggregateSummary returned error: %v", err)
+	}
+	if !strings.HasSuffix(buf.String(), ": \x1b[1m0.5\x1b[0m\n") {
+		t.Fatalf("highlighted summary = %q", buf.String())
+	}
+}
+
+func TestParseCSVDelimiter(t *testing.T) {
+	t.Parallel()
+
+	valid := map[string]rune{"": 0, ",": ',', ";": ';', `\t`: '\t', "\t": '\t', "§": '§'}
+	for input, want := range valid {
+		got, err := parseCSVDelimiter(input)
+		if err != nil {
+			t.Fatalf("parseCSVDelimiter(%q) returned error: %v", input, err)
+		}
+		if got.Delimiter != want {
+			t.Fatalf("parseCSVDelimiter(%q) = %q, want %q", input, got.Delimiter, want)
+		}
+	}
+
+	for _, input := range []string{";;", `"`, "\n", "ab"} {
+		_, err := parseCSVDelimiter(input)
+		if err == nil {
+			t.Fatalf("parseCSVDelimiter(%q) should fail", input)
+		}
+		if _, _, code := classifyError(err); code != exitUsage {
+			t.Fatalf("parseCSVDelimiter(%q) error should be a usage error, got exit %d", input, code)
+		}
+	}
+}
+
+func TestResponseObjects(t *testing.T) {
+	t.Parallel()
+
+	objects, ok := responseObjects([]any{map[string]any{"id": 1}, map[string]any{"id": 2}})
+	if !ok || len(objects) != 2 {
+		t.Fatalf("responseObjects = %v, %v; want two objects", objects, ok)
+	}
+	for _, value := range []any{nil, map[string]any{"id": 1}, []any{"id"}} {
+		if _, ok := responseObjects(value); ok {
+			t.Fatalf("responseObjects(%v) should not be a list of objects", value)
+		}
+	}
+}
+
+func TestExpandValuePath(t *testing.T) {
+	t.Parallel()
+
+	available := []string{"count", "duration.api.p50", "duration.api.p95", "duration.db.p95", "duration.total"}
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{pattern: "duration.*", want: []string{"duration.api.p50", "duration.api.p95", "duration.db.p95", "duration.total"}},
+		{pattern: "duration.*.p95", want: []string{"duration.api.p95", "duration.db.p95"}},
+		{pattern: "*.total", want: []string{"duration.total"}},
+		{pattern: "*", want: available},
+		{pattern: "duration.*.p99", want: []string{}},
+		{pattern: "count", want: []string{"count"}},
+	}
+
+	for _, tt := range tests {
+		got := expandValuePath(tt.pattern, available)
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Fatalf("expandValuePath(%q) = %v, want %v", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestResolveValuePathsNoMatch(t *testing.T) {
+	t.Parallel()
+
+	_, err := resolveValuePaths("duration.*.p99", []string{"count", "duration.api.p95"})
+	if err == nil {
+		t.Fatal("resolveValuePaths error = nil, want no matching data error")
+	}
+	want := "no matching data found for path duration.*.p99 in the selected timeframe (available: count, duration.api.p95)"
+	if err.Error() != want {
+		t.Fatalf("error = %q, want %q", err.Error(), want)
+	}
+
+	paths, err := resolveValuePaths("duration", []string{"duration.api.p95"})
+	if err != nil || len(paths) != 1 || paths[0] != "duration" {
+		t.Fatalf("resolveValuePaths(plain) = %v, %v; want [duration]", paths, err)
+	}
+}
+
+func TestFormatPathsWildcard(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	series := triflestats.SeriesFromResult(triflestats.ValuesResult{
+		At: []time.Time{base, base.Add(time.Hour)},
+		Values: []map[string]any{
+			{"duration": map[string]any{"api": map[string]any{"p95": 10.0}, "db": map[string]any{"p95": 4.0}}},
+			{"duration": map[string]any{"api": map[string]any{"p95": 20.0}, "db": map[string]any{"p95": 6.0}}},
+		},
+	})
+	paths := expandValuePath("duration.*.p95", series.AvailablePaths())
+
+	timeline := formatTimelinePaths(series, paths, 1, nil)
+	if keys := strings.Join(mapKeys(timeline), ","); keys != "duration.api.p95,duration.db.p95" {
+		t.Fatalf("timeline keys = %s, want duration.api.p95,duration.db.p95", keys)
+	}
+
+	category, ok := formatCategoryPaths(series, paths, 1).(map[string]any)
+	if !ok {
+		t.Fatalf("category = %T, want map", formatCategoryPaths(series, paths, 1))
+	}
+	if category["duration.api.p95"] != 30.0 || category["duration.db.p95"] != 10.0 {
+		t.Fatalf("category = %v, want api 30 and db 10", category)
+	}
+
+	sliced, ok := formatCategoryPaths(series, paths, 2).([]map[string]any)
+	if !ok || len(sliced) != 2 {
+		t.Fatalf("sliced category = %v, want 2 slices", formatCategoryPaths(series, paths, 2))
+	}
+	if sliced[1]["duration.db.p95"] != 6.0 {
+		t.Fatalf("sliced[1] = %v, want db 6", sliced[1])
+	}
+}
@@ -15,11 +15,10 @@ import (
 	"time"
 
 	"github.com/trifle-io/trifle-cli/internal/api"
+	"github.com/trifle-io/trifle-cli/internal/output"
 	triflestats "github.com/trifle-io/trifle_stats_go"
 )
 
-const mcpProtocolVersion = "2024-11-05"
-
 func runMCP(args []string) {
 	rc, err := resolveCommandConfig(args)
 	if err != nil {
@@ -31,8 +30,51 @@ func runMCP(args []string) {
 	addSourceFlag(fs, rc.SourceName)
 	opts := addCommonFlags(fs, &rc.Source)
 	driverOpts := addDriverFlags(fs, &rc.Source)
+	listen := fs.String("listen", "", "Serve the streamable HTTP transport on this address (e.g. 127.0.0.1:8787) instead of stdio")
+	toolTimeout := fs.Duration("tool-timeout", defaultMCPToolTimeout, "Abort a tool call after this long (0 disables)")
+	readOnly := fs.Bool("read-only", parseBoolOrDefault(os.Getenv("TRIFLE_MCP_READ_ONLY"), false), "Disable tools that write metrics or change transponders (or TRIFLE_MCP_READ_ONLY)")
+	logLevel := pickString(os.Getenv("TRIFLE_MCP_LOG_LEVEL"), "", "warn")
+	fs.StringVar(&logLevel, "log-level", logLevel, "Log requests to stderr at this level: debug, info, warn, or error (or TRIFLE_MCP_LOG_LEVEL)")
+	fs.StringVar(&logLevel, "mcp-log-level", logLevel, "Alias for --log-level")
+	sourcesFlag := fs.String("sources", "", "Serve several config sources, comma-separated; tools take a source argument and default to the first")
+	if len(args) > 0 && args[0] == "serve" {
+		args = args[1:]
+	}
 	fs.Parse(args)
 
+	level, err := parseLogLevel(logLevel)
+	if err != nil {
+		exitError(err)
+	}
+
+	serve := func(state *mcpState) error {
+		state.ToolTimeout = *toolTimeout
+		state.ReadOnly = *readOnly
+		state.Log = newMCPLogger(os.Stderr, level)
+		if *listen != "" {
+			return serveMCPHTTP(context.Background(), state, *listen)
+		}
+		return serveMCP(context.Background(), state)
+	}
+
+	if *sourcesFlag != "" {
+		if _, explicit, _ := findSourceName(args); explicit {
+			exitError(usageErrorf("use either --source or --sources"))
+		}
+		names, err := parseSourceNames(*sourcesFlag)
+		if err != nil {
+			exitError(err)
+		}
+		state, err := loadMCPSources(rc.Config, names)
+		if err != nil {
+			exitError(err)
+		}
+		if err := serve(state); err != nil {
+			exitError(err)
+		}
+		return
+	}
+
 	driverName := strings.ToLower(strings.TrimSpace(driverOpts.Driver))
 	if driverName == "" {
 		driverName = "api"
@@ -49,7 +91,7 @@ func runMCP(args []string) {
 			Local:  local,
 		}
 
-		if err := serveMCP(context.Background(), state); err != nil {
+		if err := serve(state); err != nil {
 			exitError(err)
 		}
 		return
@@ -65,11 +107,12 @@ func runMCP(args []string) {
 	}
 
 	state := &mcpState{
-		Driver: "api",
-		API:    client,
+		Driver:    "api",
+		API:       client,
+		WeekStart: driverOpts.BeginningOfWeek,
 	}
 
-	if err := serveMCP(context.Background(), state); err != nil {
+	if err := serve(state); err != nil {
 		exitError(err)
 	}
 }
@@ -81,6 +124,12 @@ type rpcRequest struct {
 	Params  json.RawMessage `json:"params,omitempty"`
 }
 
+type rpcNotification struct {
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  any    `json:"params,omitempty"`
+}
+
 type rpcResponse struct {
 	JSONRPC string          `json:"jsonrpc"`
 	ID      json.RawMessage `json:"id,omitempty"`
@@ -105,9 +154,11 @@ func (e *rpcError) Error() string {
 }
 
 type toolDefinition struct {
-	Name        string         `json:"name"`
-	Description string         `json:"description"`
-	InputSchema map[string]any `json:"inputSchema"`
+	Name        string           `json:"name"`
+	Title       string           `json:"title,omitempty"`
+	Description string           `json:"description"`
+	InputSchema map[string]any   `json:"inputSchema"`
+	Annotations *toolAnnotations `json:"annotations,omitempty"`
 }
 
 type toolCallParams struct {
@@ -128,6 +179,13 @@ type resourceDescriptor struct {
 	MimeType    string `json:"mimeType,omitempty"`
 }
 
+type resourceTemplate struct {
+	URITemplate string `json:"uriTemplate"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
 type resourceReadParams struct {
 	URI string `json:"uri"`
 }
@@ -147,59 +205,141 @@ type resourceReadResult struct {
 }
 
 type mcpState struct {
-	Driver string
-	API    *api.Client
-	Local  *localDriverRuntime
+	// Name is the config source this state runs against, when loaded by name.
+	Name      string
+	Driver    string
+	API       *api.Client
+	Local     *localDriverRuntime
+	WeekStart string
+	// Protocol is the revision negotiated by initialize.
+	Protocol string
+	// Sources maps each --sources name to its driver state, the default
+	// (first) source being this state itself. It is empty for sessions bound
+	// to a single source.
+	Sources     map[string]*mcpState
+	SourceNames []string
+	// ToolTimeout bounds each tools/call; zero means no limit.
+	ToolTimeout time.Duration
+	// ReadOnly hides and rejects every tool that is not annotated read-only.
+	ReadOnly bool
+	// Log records requests; nil disables logging.
+	Log *mcpLogger
+
+	calls mcpCalls
 }
 
 func serveMCP(ctx context.Context, state *mcpState) error {
-	decoder := json.NewDecoder(bufio.NewReader(os.Stdin))
-	decoder.UseNumber()
-	encoder := json.NewEncoder(os.Stdout)
-
-	for {
-		var req rpcRequest
-		if err := decoder.Decode(&req); err != nil {
-			if errors.Is(err, io.EOF) {
-				return nil
-			}
-			return err
-		}
+	return serveMCPStream(ctx, state, os.Stdin, os.Stdout)
+}
 
-		if req.JSONRPC == "" {
-			continue
-		}
+// mcpQueueSize is how many stdio requests may wait behind a running one
+// before the reader stops reading, and with it handling cancellations.
+const mcpQueueSize = 256
 
-		response, err := handleMCPRequest(ctx, state, req)
-		if err != nil {
-			if len(req.ID) == 0 {
-				continue
-			}
+type queuedRequest struct {
+	req    rpcRequest
+	ctx    context.Context
+	finish func() bool
+}
 
-			rpcErr, ok := err.(*rpcError)
-			if !ok {
-				rpcErr = &rpcError{Code: -32603, Message: err.Error()}
+// serveMCPStream serves newline-delimited JSON-RPC from in to out. A reader
+// goroutine queues requests as they arrive and acts on cancellations right
+// away, while requests are handled one at a time in order.
+func serveMCPStream(ctx context.Context, state *mcpState, in io.Reader, out io.Writer) error {
+	queue := make(chan queuedRequest, mcpQueueSize)
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(queue)
+		decoder := json.NewDecoder(bufio.NewReader(in))
+		decoder.UseNumber()
+		for {
+			var req rpcRequest
+			if err := decoder.Decode(&req); err != nil {
+				if !errors.Is(err, io.EOF) {
+					readErr <- err
+				}
+				return
 			}
 
-			response = &rpcResponse{
-				JSONRPC: "2.0",
-				ID:      req.ID,
-				Error:   rpcErr,
+			if req.JSONRPC == "2.0" && handleCancellation(state, req) {
+				continue
 			}
+
+			reqCtx, finish := state.calls.track(ctx, req.ID)
+			queue <- queuedRequest{req: req, ctx: reqCtx, finish: finish}
 		}
+	}()
 
-		if response == nil {
+	encoder := json.NewEncoder(out)
+	var writeErr error
+	state.Log.setNotify(func(method string, params any) {
+		if writeErr == nil {
+			writeErr = encoder.Encode(rpcNotification{JSONRPC: "2.0", Method: method, Params: params})
+		}
+	})
+	defer state.Log.setNotify(nil)
+
+	for queued := range queue {
+		response := mcpReply(queued.ctx, state, queued.req)
+		if queued.finish() || response == nil {
 			continue
 		}
 
+		if writeErr != nil {
+			return writeErr
+		}
 		if err := encoder.Encode(response); err != nil {
 			return err
 		}
 
-		if req.Method == "exit" {
+		if queued.req.Method == "exit" {
 			return nil
 		}
 	}
+
+	select {
+	case err := <-readErr:
+		return err
+	default:
+		return nil
+	}
+}
+
+// mcpReply handles req and returns the response to send, with handler
+// errors turned into JSON-RPC errors. Notifications get no response, except
+// that a message without jsonrpc "2.0" is always answered as an invalid
+// request, with a null id when it has none.
+func mcpReply(ctx context.Context, state *mcpState, req rpcRequest) *rpcResponse {
+	start := time.Now()
+	var response *rpcResponse
+	var err error
+	if req.JSONRPC != "2.0" {
+		err = invalidRequestError(`jsonrpc must be "2.0"`)
+	} else {
+		response, err = handleMCPRequest(ctx, state, req)
+	}
+	state.Log.request(ctx, req, response, err, time.Since(start))
+	if err != nil {
+		rpcErr, ok := err.(*rpcError)
+		if !ok {
+			rpcErr = &rpcError{Code: -32603, Message: err.Error()}
+		}
+
+		id := req.ID
+		if len(id) == 0 {
+			if rpcErr.Code != -32600 {
+				return nil
+			}
+			id = json.RawMessage("null")
+		}
+
+		return &rpcResponse{
+			JSONRPC: "2.0",
+			ID:      id,
+			Error:   rpcErr,
+		}
+	}
+	return response
 }
 
 func handleMCPRequest(ctx context.Context, state *mcpState, req rpcRequest) (*rpcResponse, error) {
@@ -212,10 +352,11 @@ func handleMCPRequest(ctx context.Context, state *mcpState, req rpcRequest) (*rp
 			}
 		}
 
-		protocol := params.ProtocolVersion
-		if protocol == "" {
-			protocol = mcpProtocolVersion
+		protocol, err := negotiateProtocolVersion(params.ProtocolVersion)
+		if err != nil {
+			return nil, err
 		}
+		state.Protocol = protocol
 
 		result := map[string]any{
 			"protocolVersion": protocol,
@@ -226,28 +367,43 @@ func handleMCPRequest(ctx context.Context, state *mcpState, req rpcRequest) (*rp
 				"resources": map[string]any{
 					"listChanged": false,
 				},
+				"prompts": map[string]any{
+					"listChanged": false,
+				},
+				"logging": map[string]any{},
 			},
 			"serverInfo": map[string]any{
 				"name":    "trifle-cli",
 				"version": version,
 			},
 		}
+		if state.ReadOnly {
+			result["instructions"] = mcpReadOnlyInstructions
+		}
 
 		return rpcResult(req.ID, result), nil
-	case "initialized":
+	case "initialized", "notifications/initialized":
 		return nil, nil
+	case "logging/setLevel":
+		return handleSetLevel(state, req)
 	case "shutdown":
 		return rpcResult(req.ID, map[string]any{}), nil
 	case "exit":
 		return rpcResult(req.ID, map[string]any{}), nil
 	case "tools/list":
-		return rpcResult(req.ID, map[string]any{"tools": toolDefinitions(state.Driver)}), nil
+		return rpcResult(req.ID, map[string]any{"tools": toolsForProtocol(state.toolList(), state.Protocol)}), nil
 	case "tools/call":
 		return handleToolCall(ctx, state, req)
 	case "resources/list":
-		return rpcResult(req.ID, map[string]any{"resources": resourceList(state.Driver)}), nil
+		return rpcResult(req.ID, map[string]any{"resources": state.resourceList()}), nil
+	case "resources/templates/list":
+		return rpcResult(req.ID, map[string]any{"resourceTemplates": state.resourceTemplateList()}), nil
 	case "resources/read":
 		return handleResourceRead(ctx, state, req)
+	case "prompts/list":
+		return rpcResult(req.ID, map[string]any{"prompts": mcpPrompts}), nil
+	case "prompts/get":
+		return handlePromptGet(req)
 	default:
 		return nil, methodNotFoundError(fmt.Sprintf("method not found: %s", req.Method))
 	}
@@ -271,9 +427,12 @@ func handleToolCall(ctx context.Context, state *mcpState, req rpcRequest) (*rpcR
 		params.Arguments = map[string]any{}
 	}
 
+	ctx, cancel := withToolTimeout(ctx, state)
+	defer cancel()
+
 	result, err := executeTool(ctx, state, params.Name, params.Arguments)
 	if err != nil {
-		return rpcResult(req.ID, toolErrorResult(err)), nil
+		return rpcResult(req.ID, toolErrorResult(toolTimeoutError(ctx, state, params.Name, err))), nil
 	}
 
 	return rpcResult(req.ID, result), nil
@@ -302,63 +461,109 @@ func handleResourceRead(ctx context.Context, state *mcpState, req rpcRequest) (*
 }
 
 func executeTool(ctx context.Context, state *mcpState, name string, args map[string]any) (toolResult, error) {
+	if err := checkReadOnly(state, name); err != nil {
+		return toolResult{}, err
+	}
+	state, err := state.source(getStringArg(args, "source"))
+	if err != nil {
+		return toolResult{}, err
+	}
+	compact := getBoolArg(args, "compact")
 	switch name {
 	case "list_metrics":
 		payload, err := listMetricsPayload(ctx, state, args)
 		if err != nil {
 			return toolResult{}, err
 		}
-		return toolResultFromJSON(payload), nil
+		return toolResultFromJSON(payload, compact), nil
 	case "fetch_series":
 		payload, err := fetchSeriesPayload(ctx, state, args)
 		if err != nil {
 			return toolResult{}, err
 		}
-		return toolResultFromJSON(payload), nil
+		return toolResultFromJSON(payload, compact), nil
 	case "aggregate_series":
 		payload, err := queryPayload(ctx, state, "aggregate", args)
 		if err != nil {
 			return toolResult{}, err
 		}
-		return toolResultFromJSON(payload), nil
+		limitPayloadTable(payload, args)
+		return toolResultFromJSON(payload, compact), nil
 	case "format_timeline":
 		payload, err := queryPayload(ctx, state, "timeline", args)
 		if err != nil {
 			return toolResult{}, err
 		}
-		return toolResultFromJSON(payload), nil
+		limitPayloadTable(payload, args)
+		return toolResultFromJSON(payload, compact), nil
 	case "format_category":
 		payload, err := queryPayload(ctx, state, "category", args)
 		if err != nil {
 			return toolResult{}, err
 		}
-		return toolResultFromJSON(payload), nil
+		limitPayloadTable(payload, args)
+		return toolResultFromJSON(payload, compact), nil
 	case "write_metric":
 		payload, err := writeMetricPayload(ctx, state, args)
 		if err != nil {
 			return toolResult{}, err
 		}
-		return toolResultFromJSON(payload), nil
+		return toolResultFromJSON(payload, compact), nil
+	case "setup_storage":
+		payload, err := setupStoragePayload(ctx, state)
+		if err != nil {
+			return toolResult{}, err
+		}
+		return toolResultFromJSON(payload, compact), nil
 	case "list_transponders":
 		payload, err := listTranspondersPayload(ctx, state)
 		if err != nil {
 			return toolResult{}, err
 		}
-		return toolResultFromJSON(payload), nil
+		return toolResultFromJSON(payload, compact), nil
 	case "delete_transponder":
 		payload, err := deleteTransponderPayload(ctx, state, args)
 		if err != nil {
 			return toolResult{}, err
 		}
-		return toolResultFromJSON(payload), nil
+		return toolResultFromJSON(payload, compact), nil
+	case "test_transponder":
+		payload, err := testTransponderPayload(ctx, state, args)
+		if err != nil {
+			return toolResult{}, err
+		}
+		return toolResultFromJSON(payload, compact), nil
+	case "create_transponder", "update_transponder":
+		payload, err := writeTransponderPayload(ctx, state, args, name == "update_transponder")
+		if err != nil {
+			return toolResult{}, err
+		}
+		return toolResultFromJSON(payload, compact), nil
+	case "pause_transponder", "resume_transponder":
+		status := transponderPaused
+		if name == "resume_transponder" {
+			status = transponderActive
+		}
+		payload, err := transponderStatusPayload(ctx, state, args, status)
+		if err != nil {
+			return toolResult{}, err
+		}
+		return toolResultFromJSON(payload, compact), nil
 	default:
 		return toolResult{}, fmt.Errorf("unknown tool: %s", name)
 	}
 }
 
 func listMetricsPayload(ctx context.Context, state *mcpState, args map[string]any) (map[string]any, error) {
+	if err := validateKeysSelection(getStringArg(args, "sort"), getIntArg(args, "limit", 0)); err != nil {
+		return nil, err
+	}
+	filter, err := newKeysFilter(getStringArg(args, "prefix"), getStringArg(args, "match"))
+	if err != nil {
+		return nil, err
+	}
 	if state != nil && state.Local != nil {
-		return listMetricsPayloadLocal(state, args)
+		return listMetricsPayloadLocal(state, args, filter)
 	}
 
 	if state == nil || state.API == nil {
@@ -366,12 +571,12 @@ func listMetricsPayload(ctx context.Context, state *mcpState, args map[string]an
 	}
 	client := state.API
 
-	from, to, err := resolveTimeRange(getStringArg(args, "from"), getStringArg(args, "to"))
+	from, to, err := resolveTimeRangeArgs(args)
 	if err != nil {
 		return nil, err
 	}
 
-	granularity, err := resolveGranularityValue(ctx, client, getStringArg(args, "granularity"))
+	granularity, err := resolveGranularityValue(ctx, newSourceLookup(client), getStringArg(args, "granularity"))
 	if err != nil {
 		return nil, err
 	}
@@ -383,11 +588,13 @@ func listMetricsPayload(ctx context.Context, state *mcpState, args map[string]an
 	}
 
 	var response metricsResponse
-	if err := client.GetMetrics(ctx, params, &response); err != nil {
+	if err := getMetrics(ctx, client, params, state.WeekStart, &response); err != nil {
 		return nil, err
 	}
 
 	entries := summarizeKeys(response.Data.Values)
+	filtered := filter.apply(entries)
+	selected := selectKeysEntries(filtered, getStringArg(args, "sort"), getBoolArg(args, "desc"), getIntArg(args, "limit", 0))
 
 	payload := map[string]any{
 		"status": "ok",
@@ -396,11 +603,13 @@ func listMetricsPayload(ctx context.Context, state *mcpState, args map[string]an
 			"to":          to,
 			"granularity": granularity,
 		},
-		"paths":       entries,
-		"total_paths": len(entries),
+		"paths":          selected,
+		"total_paths":    len(entries),
+		"returned_paths": len(selected),
 	}
+	filter.annotate(payload, len(filtered))
 
-	return payload, nil
+	return withTimeframeWarning(payload, from, to, granularity), nil
 }
 
 func fetchSeriesPayload(ctx context.Context, state *mcpState, args map[string]any) (map[string]any, error) {
@@ -413,12 +622,12 @@ func fetchSeriesPayload(ctx context.Context, state *mcpState, args map[string]an
 	}
 	client := state.API
 
-	from, to, err := resolveTimeRange(getStringArg(args, "from"), getStringArg(args, "to"))
+	from, to, err := resolveTimeRangeArgs(args)
 	if err != nil {
 		return nil, err
 	}
 
-	granularity, err := resolveGranularityValue(ctx, client, getStringArg(args, "granularity"))
+	granularity, err := resolveGranularityValue(ctx, newSourceLookup(client), getStringArg(args, "granularity"))
 	if err != nil {
 		return nil, err
 	}
@@ -433,11 +642,26 @@ func fetchSeriesPayload(ctx context.Context, state *mcpState, args map[string]an
 	if key != "" {
 		params["key"] = key
 	}
+	if getBoolArg(args, "skip_blanks") {
+		params["skip_blanks"] = "true"
+	}
+
+	fill, err := fillArgs(args)
+	if err != nil {
+		return nil, err
+	}
 
 	var response metricsResponse
-	if err := client.GetMetrics(ctx, params, &response); err != nil {
+	if err := getMetrics(ctx, client, params, state.WeekStart, &response); err != nil {
 		return nil, err
 	}
+	if fill.enabled() {
+		at, err := parseSeriesTimes(response.Data.At)
+		if err != nil {
+			return nil, err
+		}
+		response.Data.Values = fillResult(triflestats.ValuesResult{At: at, Values: response.Data.Values}, fill).Values
+	}
 
 	usedKey := key
 	if usedKey == "" {
@@ -455,7 +679,7 @@ func fetchSeriesPayload(ctx context.Context, state *mcpState, args map[string]an
 		"data": response.Data,
 	}
 
-	return payload, nil
+	return withTimeframeWarning(payload, from, to, granularity), nil
 }
 
 func queryPayload(ctx context.Context, state *mcpState, mode string, args map[string]any) (map[string]any, error) {
@@ -478,12 +702,12 @@ func queryPayload(ctx context.Context, state *mcpState, mode string, args map[st
 		return nil, fmt.Errorf("value_path is required")
 	}
 
-	from, to, err := resolveTimeRange(getStringArg(args, "from"), getStringArg(args, "to"))
+	from, to, err := resolveTimeRangeArgs(args)
 	if err != nil {
 		return nil, err
 	}
 
-	granularity, err := resolveGranularityValue(ctx, client, getStringArg(args, "granularity"))
+	granularity, err := resolveGranularityValue(ctx, newSourceLookup(client), getStringArg(args, "granularity"))
 	if err != nil {
 		return nil, err
 	}
@@ -505,16 +729,57 @@ func queryPayload(ctx context.Context, state *mcpState, mode string, args map[st
 		payload["aggregator"] = aggregator
 	}
 
-	if slicesValue, ok := args["slices"]; ok {
-		payload["slices"] = slicesValue
+	if _, ok := args["slices"]; ok {
+		slices, err := slicesArg(args, from, to, granularity)
+		if err != nil {
+			return nil, err
+		}
+		payload["slices"] = slices
+	}
+
+	var fill fillOptions
+	var transform transformOptions
+	var category categoryOptions
+	if mode == "category" {
+		if category, err = categoryArgs(args); err != nil {
+			return nil, err
+		}
+	}
+	if mode == "timeline" {
+		if getBoolArg(args, "skip_blanks") {
+			payload["skip_blanks"] = true
+		}
+		if fill, err = fillArgs(args); err != nil {
+			return nil, err
+		}
+		if transform, err = transformArgs(args); err != nil {
+			return nil, err
+		}
 	}
 
-	data, err := queryMetrics(ctx, client, payload)
+	data, err := queryMetrics(ctx, client, payload, state.WeekStart)
 	if err != nil {
 		return nil, err
 	}
+	if err := fillQueryData(data, fill); err != nil {
+		return nil, err
+	}
+	if transform.enabled() {
+		resets, err := transformQueryData(data, transform)
+		if err != nil {
+			return nil, err
+		}
+		data["transform"] = transform.Mode
+		data["resets"] = resets
+		if transform.StrictNil {
+			data["strict_nil"] = true
+		}
+	}
+	if category.rollsUp() {
+		applyCategoryRanking(data, category)
+	}
 
-	return data, nil
+	return withTimeframeWarning(data, from, to, granularity), nil
 }
 
 func writeMetricPayload(ctx context.Context, state *mcpState, args map[string]any) (map[string]any, error) {
@@ -532,15 +797,19 @@ func writeMetricPayload(ctx context.Context, state *mcpState, args map[string]an
 		return nil, fmt.Errorf("key is required")
 	}
 
-	values, ok := args["values"]
-	if !ok {
-		return nil, fmt.Errorf("values is required")
+	values, err := writeMetricValues(args)
+	if err != nil {
+		return nil, err
+	}
+
+	// The metrics endpoint only tracks, so any other mode is rejected here
+	// rather than sent and ignored.
+	if _, err := resolveWriteMode(getStringArg(args, "mode"), false); err != nil {
+		return nil, err
 	}
 
-	at := strings.TrimSpace(getStringArg(args, "at"))
-	if at == "" {
-		at = time.Now().UTC().Format(time.RFC3339)
-	} else if err := validateTimestamp("at", at); err != nil {
+	at, err := resolvePushAt(getStringArg(args, "at"))
+	if err != nil {
 		return nil, err
 	}
 
@@ -555,9 +824,59 @@ func writeMetricPayload(ctx context.Context, state *mcpState, args map[string]an
 		return nil, err
 	}
 
+	// The response is passed through as is: only the server can say which
+	// mode it applied.
+	if response == nil {
+		response = map[string]any{}
+	}
 	return response, nil
 }
 
+// suggestSetupTool is maybeSuggestSetup for MCP: it points the agent at
+// the setup_storage tool rather than the CLI command.
+func suggestSetupTool(err error, driverName string) error {
+	if err == nil || !isMissingStorageError(err) {
+		return err
+	}
+	switch normalizeDriverName(driverName) {
+	case "sqlite", "postgres", "mysql", "mongo":
+		return fmt.Errorf("%s (call the setup_storage tool to create it)", err.Error())
+	}
+	return err
+}
+
+// setupStoragePayload creates the local driver's table or collection if it
+// is missing. Running it again is harmless and reports created false.
+func setupStoragePayload(ctx context.Context, state *mcpState) (map[string]any, error) {
+	if state == nil || state.Local == nil {
+		return nil, fmt.Errorf("setup_storage is only available for local drivers")
+	}
+	local := state.Local
+
+	existed, err := local.StorageExists(ctx)
+	if err != nil {
+		return nil, driverError(err)
+	}
+	if err := local.Setup(); err != nil {
+		return nil, driverError(err)
+	}
+
+	target := "table"
+	switch local.DriverName {
+	case "mongo":
+		target = "collection"
+	case "redis":
+		target = "prefix"
+	}
+	return map[string]any{
+		"data": map[string]any{
+			"driver":  local.DriverName,
+			target:    local.TableName,
+			"created": !existed,
+		},
+	}, nil
+}
+
 func listTranspondersPayload(ctx context.Context, state *mcpState) (map[string]any, error) {
 	if state != nil && state.Local != nil {
 		return nil, fmt.Errorf("transponders are only available for api drivers")
@@ -568,7 +887,7 @@ func listTranspondersPayload(ctx context.Context, state *mcpState) (map[string]a
 	client := state.API
 
 	var response map[string]any
-	if err := client.GetTransponders(ctx, &response); err != nil {
+	if err := client.GetTransponders(ctx, nil, &response); err != nil {
 		return nil, err
 	}
 
@@ -597,12 +916,71 @@ func deleteTransponderPayload(ctx context.Context, state *mcpState, args map[str
 	return response, nil
 }
 
-func listMetricsPayloadLocal(state *mcpState, args map[string]any) (map[string]any, error) {
+// transponderToolFields are the transponder fields create_transponder and
+// update_transponder take as top-level arguments; they win over the same
+// fields in payload.
+var transponderToolFields = []string{"name", "kind", "key", "description", "status", "config"}
+
+func writeTransponderPayload(ctx context.Context, state *mcpState, args map[string]any, update bool) (map[string]any, error) {
+	if state != nil && state.Local != nil {
+		return nil, fmt.Errorf("transponders are only available for api drivers")
+	}
+	if state == nil || state.API == nil {
+		return nil, fmt.Errorf("api client is not configured")
+	}
+	client := state.API
+
+	id := strings.TrimSpace(getStringArg(args, "id"))
+	if update && id == "" {
+		return nil, fmt.Errorf("id is required")
+	}
+
+	payload := map[string]any{}
+	if raw, ok := args["payload"]; ok && raw != nil {
+		base, ok := raw.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("payload must be an object")
+		}
+		for field, value := range base {
+			payload[field] = value
+		}
+	}
+	for _, field := range transponderToolFields {
+		if value, ok := args[field]; ok && value != nil {
+			payload[field] = value
+		}
+	}
+	if update && len(payload) == 0 {
+		return nil, fmt.Errorf("nothing to update; pass fields or payload")
+	}
+
+	check := validateTransponderPayload(payload, update)
+	if !check.valid() {
+		problems := make([]string, len(check.Errors))
+		for i, violation := range check.Errors {
+			problems[i] = fmt.Sprintf("%s: %s", violation.Path, violation.Problem)
+		}
+		return nil, fmt.Errorf("invalid transponder payload: %s", strings.Join(problems, "; "))
+	}
+
+	var response map[string]any
+	if update {
+		if err := client.UpdateTransponder(ctx, id, payload, &response); err != nil {
+			return nil, err
+		}
+	} else if err := client.CreateTransponder(ctx, payload, &response); err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func listMetricsPayloadLocal(state *mcpState, args map[string]any, filter keysFilter) (map[string]any, error) {
 	if state == nil || state.Local == nil || state.Local.Config == nil {
 		return nil, fmt.Errorf("local driver is not configured")
 	}
 
-	from, to, err := resolveTimeRange(getStringArg(args, "from"), getStringArg(args, "to"))
+	from, to, err := resolveTimeRangeArgs(args)
 	if err != nil {
 		return nil, err
 	}
@@ -623,10 +1001,12 @@ func listMetricsPayloadLocal(state *mcpState, args map[string]any) (map[string]a
 
 	result, err := triflestats.Values(state.Local.Config, systemMetricsKey, fromTime, toTime, granularity, true)
 	if err != nil {
-		return nil, maybeSuggestSetup(err, state.Local.DriverName, state.Local.TableName)
+		return nil, suggestSetupTool(err, state.Local.DriverName)
 	}
 
 	entries := summarizeSystemKeys(result.Values)
+	filtered := filter.apply(entries)
+	selected := selectKeysEntries(filtered, getStringArg(args, "sort"), getBoolArg(args, "desc"), getIntArg(args, "limit", 0))
 
 	payload := map[string]any{
 		"status": "ok",
@@ -635,11 +1015,13 @@ func listMetricsPayloadLocal(state *mcpState, args map[string]any) (map[string]a
 			"to":          to,
 			"granularity": granularity,
 		},
-		"paths":       entries,
-		"total_paths": len(entries),
+		"paths":          selected,
+		"total_paths":    len(entries),
+		"returned_paths": len(selected),
 	}
+	filter.annotate(payload, len(filtered))
 
-	return payload, nil
+	return withTimeframeWarning(payload, from, to, granularity), nil
 }
 
 func fetchSeriesPayloadLocal(state *mcpState, args map[string]any) (map[string]any, error) {
@@ -647,7 +1029,7 @@ func fetchSeriesPayloadLocal(state *mcpState, args map[string]any) (map[string]a
 		return nil, fmt.Errorf("local driver is not configured")
 	}
 
-	from, to, err := resolveTimeRange(getStringArg(args, "from"), getStringArg(args, "to"))
+	from, to, err := resolveTimeRangeArgs(args)
 	if err != nil {
 		return nil, err
 	}
@@ -671,11 +1053,16 @@ func fetchSeriesPayloadLocal(state *mcpState, args map[string]any) (map[string]a
 	if usedKey == "" {
 		usedKey = systemMetricsKey
 	}
+	fill, err := fillArgs(args)
+	if err != nil {
+		return nil, err
+	}
 
-	result, err := triflestats.Values(state.Local.Config, usedKey, fromTime, toTime, granularity, false)
+	result, err := triflestats.Values(state.Local.Config, usedKey, fromTime, toTime, granularity, getBoolArg(args, "skip_blanks"))
 	if err != nil {
-		return nil, maybeSuggestSetup(err, state.Local.DriverName, state.Local.TableName)
+		return nil, suggestSetupTool(err, state.Local.DriverName)
 	}
+	result = fillResult(result, fill)
 
 	payload := map[string]any{
 		"status":     "ok",
@@ -691,7 +1078,7 @@ func fetchSeriesPayloadLocal(state *mcpState, args map[string]any) (map[string]a
 		},
 	}
 
-	return payload, nil
+	return withTimeframeWarning(payload, from, to, granularity), nil
 }
 
 func queryPayloadLocal(state *mcpState, mode string, args map[string]any) (map[string]any, error) {
@@ -713,7 +1100,7 @@ func queryPayloadLocal(state *mcpState, mode string, args map[string]any) (map[s
 		return nil, err
 	}
 
-	from, to, err := resolveTimeRange(getStringArg(args, "from"), getStringArg(args, "to"))
+	from, to, err := resolveTimeRangeArgs(args)
 	if err != nil {
 		return nil, err
 	}
@@ -723,6 +1110,11 @@ func queryPayloadLocal(state *mcpState, mode string, args map[string]any) (map[s
 		return nil, err
 	}
 
+	slices, err := slicesArg(args, from, to, granularity)
+	if err != nil {
+		return nil, err
+	}
+
 	fromTime, err := time.Parse(time.RFC3339Nano, from)
 	if err != nil {
 		return nil, err
@@ -732,17 +1124,15 @@ func queryPayloadLocal(state *mcpState, mode string, args map[string]any) (map[s
 		return nil, err
 	}
 
-	seriesResult, err := triflestats.Values(state.Local.Config, key, fromTime, toTime, granularity, false)
+	// Only format_timeline declares skip_blanks.
+	skipBlanks := mode == "timeline" && getBoolArg(args, "skip_blanks")
+	seriesResult, err := triflestats.Values(state.Local.Config, key, fromTime, toTime, granularity, skipBlanks)
 	if err != nil {
-		return nil, maybeSuggestSetup(err, state.Local.DriverName, state.Local.TableName)
+		return nil, suggestSetupTool(err, state.Local.DriverName)
 	}
 
 	series := triflestats.SeriesFromResult(seriesResult)
 	available := series.AvailablePaths()
-	slices := getIntArg(args, "slices", 1)
-	if slices < 1 {
-		slices = 1
-	}
 
 	switch strings.ToLower(strings.TrimSpace(mode)) {
 	case "aggregate":
@@ -758,21 +1148,12 @@ func queryPayloadLocal(state *mcpState, mode string, args map[string]any) (map[s
 			return nil, fmt.Errorf("aggregator is required")
 		}
 
-		var values []any
-		switch aggName {
-		case "sum":
-			values = series.AggregateSum(valuePath, slices)
-		case "mean":
-			values = series.AggregateMean(valuePath, slices)
-		case "min":
-			values = series.AggregateMin(valuePath, slices)
-		case "max":
-			values = series.AggregateMax(valuePath, slices)
-		default:
-			return nil, fmt.Errorf("unsupported aggregator %q", aggName)
+		sliceValues, err := aggregateSeriesPath(series, aggName, valuePath, slices)
+		if err != nil {
+			return nil, err
 		}
 
-		values = normalizeNumericSlice(values)
+		values := normalizeNumericSlice(sliceValues)
 		if len(values) == 0 {
 			return nil, fmt.Errorf("no data available for path %s in the selected timeframe", valuePath)
 		}
@@ -785,7 +1166,7 @@ func queryPayloadLocal(state *mcpState, mode string, args map[string]any) (map[s
 			"slices":          slices,
 			"values":          values,
 			"count":           len(values),
-			"timeframe":       buildTimeframePayload(from, to, granularity),
+			"timeframe":       buildTimeframePayload(from, to, granularity, ""),
 			"available_paths": available,
 			"matched_paths":   []string{valuePath},
 		}
@@ -796,13 +1177,35 @@ func queryPayloadLocal(state *mcpState, mode string, args map[string]any) (map[s
 		if table := buildSeriesTable(series, []string{valuePath}); table != nil {
 			payload["table"] = table
 		}
-		return payload, nil
+		if slices > 1 {
+			chunks := aggregateSliceRanges(fromTime, toTime, granularity, state.Local.Config, len(sliceValues))
+			ranges := sliceRangesPayload(chunks)
+			applySliceRanges(payload, ranges, []string{valuePath}, map[string][]any{valuePath: normalizeAggregateValues(sliceValues)})
+		}
+		return withTimeframeWarning(payload, from, to, granularity), nil
 	case "timeline":
+		fill, err := fillArgs(args)
+		if err != nil {
+			return nil, err
+		}
+		transform, err := transformArgs(args)
+		if err != nil {
+			return nil, err
+		}
 		formatted := series.FormatTimeline(valuePath, slices, nil)
 		matched := filterAvailable(mapKeys(formatted), available)
 		if len(matched) == 0 {
 			return nil, fmt.Errorf("no matching data found for path %s in the selected timeframe", valuePath)
 		}
+		if fill.enabled() {
+			series = fillSeries(series, matched, fill)
+			formatted = formatTimelinePaths(series, matched, slices, nullableTimelinePoint)
+		}
+		resets := 0
+		if transform.enabled() {
+			series, resets = transformSeries(series, matched, transform)
+			formatted = formatTimelinePaths(series, matched, slices, nullableTimelinePoint)
+		}
 
 		payload := map[string]any{
 			"status":          "ok",
@@ -810,17 +1213,28 @@ func queryPayloadLocal(state *mcpState, mode string, args map[string]any) (map[s
 			"metric_key":      key,
 			"value_path":      valuePath,
 			"slices":          slices,
-			"timeframe":       buildTimeframePayload(from, to, granularity),
+			"timeframe":       buildTimeframePayload(from, to, granularity, ""),
 			"result":          formatted,
 			"available_paths": available,
 			"matched_paths":   matched,
 		}
+		if transform.enabled() {
+			payload["transform"] = transform.Mode
+			payload["resets"] = resets
+			if transform.StrictNil {
+				payload["strict_nil"] = true
+			}
+		}
 
 		if table := buildSeriesTable(series, matched); table != nil {
 			payload["table"] = table
 		}
-		return payload, nil
+		return withTimeframeWarning(payload, from, to, granularity), nil
 	case "category":
+		category, err := categoryArgs(args)
+		if err != nil {
+			return nil, err
+		}
 		formatted := series.FormatCategory(valuePath, slices, nil)
 		matched := filterAvailable(extractCategoryPaths(formatted), available)
 		if len(matched) == 0 {
@@ -833,16 +1247,18 @@ func queryPayloadLocal(state *mcpState, mode string, args map[string]any) (map[s
 			"metric_key":      key,
 			"value_path":      valuePath,
 			"slices":          slices,
-			"timeframe":       buildTimeframePayload(from, to, granularity),
+			"timeframe":       buildTimeframePayload(from, to, granularity, ""),
 			"result":          formatted,
 			"available_paths": available,
 			"matched_paths":   matched,
 		}
 
-		if table := buildSeriesTable(series, matched); table != nil {
+		if category.rollsUp() {
+			applyCategoryRanking(payload, category)
+		} else if table := buildSeriesTable(series, matched); table != nil {
 			payload["table"] = table
 		}
-		return payload, nil
+		return withTimeframeWarning(payload, from, to, granularity), nil
 	default:
 		return nil, fmt.Errorf("unsupported mode %q", mode)
 	}
@@ -858,15 +1274,13 @@ func writeMetricPayloadLocal(state *mcpState, args map[string]any) (map[string]a
 		return nil, fmt.Errorf("key is required")
 	}
 
-	values, ok := args["values"]
-	if !ok {
-		return nil, fmt.Errorf("values is required")
+	values, err := writeMetricValues(args)
+	if err != nil {
+		return nil, err
 	}
 
-	at := strings.TrimSpace(getStringArg(args, "at"))
-	if at == "" {
-		at = time.Now().UTC().Format(time.RFC3339)
-	} else if err := validateTimestamp("at", at); err != nil {
+	at, err := resolvePushAt(getStringArg(args, "at"))
+	if err != nil {
 		return nil, err
 	}
 
@@ -880,8 +1294,13 @@ func writeMetricPayloadLocal(state *mcpState, args map[string]any) (map[string]a
 		return nil, err
 	}
 
-	if err := performLocalWrite(state.Local.Config, "track", key, atTime, valuesMap); err != nil {
-		return nil, maybeSuggestSetup(err, state.Local.DriverName, state.Local.TableName)
+	mode, err := resolveWriteMode(getStringArg(args, "mode"), true)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := performLocalWrite(state.Local.Config, mode, key, atTime, valuesMap); err != nil {
+		return nil, suggestSetupTool(err, state.Local.DriverName)
 	}
 
 	response := map[string]any{
@@ -889,6 +1308,7 @@ func writeMetricPayloadLocal(state *mcpState, args map[string]any) (map[string]a
 			"key":    key,
 			"at":     atTime,
 			"values": valuesMap,
+			"mode":   mode,
 		},
 	}
 
@@ -922,6 +1342,9 @@ func readResource(ctx context.Context, state *mcpState, uri string) (resourceRea
 	if parsed.Scheme != "trifle" {
 		return resourceReadResult{}, fmt.Errorf("unsupported scheme: %s", parsed.Scheme)
 	}
+	if state != nil {
+		state, parsed = state.resourceSource(parsed)
+	}
 
 	switch parsed.Host {
 	case "source":
@@ -944,17 +1367,43 @@ func readResource(ctx context.Context, state *mcpState, uri string) (resourceRea
 		if state == nil || state.API == nil {
 			return resourceReadResult{}, fmt.Errorf("api client is not configured")
 		}
-		var response map[string]any
-		if err := state.API.GetTransponders(ctx, &response); err != nil {
+		path := strings.Trim(parsed.Path, "/")
+		if path == "" {
+			var response map[string]any
+			if err := state.API.GetTransponders(ctx, nil, &response); err != nil {
+				return resourceReadResult{}, err
+			}
+			return resourceResult(uri, response)
+		}
+		id, rest, _ := strings.Cut(path, "/")
+		if id == "" || rest != "logs" {
+			return resourceReadResult{}, fmt.Errorf("unknown transponders resource: %s", uri)
+		}
+		query := parsed.Query()
+		if err := checkResourceQuery(uri, query, "limit", "cursor"); err != nil {
+			return resourceReadResult{}, err
+		}
+		limit := 50
+		if value := query.Get("limit"); value != "" {
+			if limit, err = strconv.Atoi(value); err != nil || limit < 0 {
+				return resourceReadResult{}, fmt.Errorf("limit must be a non-negative integer")
+			}
+		}
+		response, err := transponderDeliveries(ctx, state.API, id, limit, query.Get("cursor"))
+		if err != nil {
 			return resourceReadResult{}, err
 		}
 		return resourceResult(uri, response)
 	case "metrics":
 		key := strings.TrimPrefix(parsed.Path, "/")
 		query := parsed.Query()
+		if err := checkMetricsQuery(uri, query); err != nil {
+			return resourceReadResult{}, err
+		}
 		args := map[string]any{
 			"from":        query.Get("from"),
 			"to":          query.Get("to"),
+			"last":        query.Get("last"),
 			"granularity": query.Get("granularity"),
 		}
 		if key != "" {
@@ -987,13 +1436,7 @@ func resourceList(driverName string) []resourceDescriptor {
 		{
 			URI:         "trifle://metrics",
 			Name:        "Metrics listing",
-			Description: "Available metrics from __system__key__ (use ?from&to RFC3339, granularity like 1h).",
-			MimeType:    "application/json",
-		},
-		{
-			URI:         "trifle://metrics/{key}",
-			Name:        "Metric series",
-			Description: "Raw series for a metric key (use ?from&to RFC3339, granularity like 1h).",
+			Description: "Available metrics from __system__key__ (use ?from&to as RFC3339 or relative like -7d, or ?last=7d, granularity like 1h).",
 			MimeType:    "application/json",
 		},
 	}
@@ -1010,16 +1453,117 @@ func resourceList(driverName string) []resourceDescriptor {
 	return resources
 }
 
+// resourceTemplates lists the parameterized resources as RFC 6570 URI
+// templates, for resources/templates/list.
+func resourceTemplates(driverName string) []resourceTemplate {
+	templates := []resourceTemplate{
+		{
+			URITemplate: "trifle://metrics/{key}{?from,to,last,granularity}",
+			Name:        "Metric series",
+			Description: "Raw series for a metric key. key: the metric key, e.g. event::signup. " +
+				"from, to: RFC3339 timestamps, epoch seconds, or relative to now like -7d (to defaults to now). " +
+				"last: a window ending now like 24h or 7d, instead of from/to. " +
+				"granularity: bucket size like 1h or 1d (defaults to the source's).",
+			MimeType: "application/json",
+		},
+	}
+
+	if strings.EqualFold(driverName, "api") || strings.TrimSpace(driverName) == "" {
+		templates = append(templates, resourceTemplate{
+			URITemplate: "trifle://transponders/{id}/logs{?limit,cursor}",
+			Name:        "Transponder delivery log",
+			Description: "Recent deliveries of a transponder. id: the transponder id. " +
+				"limit: how many deliveries to return (default 50). " +
+				"cursor: meta.next_cursor from a previous page, for older deliveries.",
+			MimeType: "application/json",
+		})
+	}
+
+	return templates
+}
+
+// checkResourceQuery rejects query parameters a resource does not take, so a
+// typo fails loudly instead of being ignored.
+func checkResourceQuery(uri string, query url.Values, allowed ...string) error {
+	known := map[string]bool{}
+	for _, name := range allowed {
+		known[name] = true
+	}
+	for name := range query {
+		if !known[name] {
+			return fmt.Errorf("unknown parameter %q for %s (use %s)", name, uri, strings.Join(allowed, ", "))
+		}
+	}
+	return nil
+}
+
+// checkMetricsQuery validates the query of a metrics resource before any of
+// it reaches the backend.
+func checkMetricsQuery(uri string, query url.Values) error {
+	if err := checkResourceQuery(uri, query, "from", "to", "last", "granularity"); err != nil {
+		return err
+	}
+	if value := query.Get("granularity"); value != "" {
+		if _, err := validateGranularity(value); err != nil {
+			return fmt.Errorf("invalid granularity %q: %w", value, err)
+		}
+	}
+	if _, _, err := resolveTimeRange(query.Get("from"), query.Get("to"), query.Get("last")); err != nil {
+		return fmt.Errorf("invalid time range: %w", err)
+	}
+	return nil
+}
+
+// transponderToolProperties is the input schema shared by
+// create_transponder and update_transponder, with id for updates.
+func transponderToolProperties(withID bool) map[string]any {
+	properties := map[string]any{
+		"name":        map[string]any{"type": "string"},
+		"kind":        map[string]any{"type": "string", "description": "Transponder kind, e.g. threshold or heartbeat."},
+		"key":         map[string]any{"type": "string", "description": "Metrics key the transponder watches."},
+		"description": map[string]any{"type": "string"},
+		"status":      map[string]any{"type": "string", "enum": []string{transponderActive, transponderPaused}},
+		"config":      map[string]any{"type": "object", "description": "Kind-specific settings, e.g. {\"value_path\": \"status.error\", \"threshold\": 50}."},
+		"payload": map[string]any{
+			"type":        "object",
+			"description": "Any other REST payload fields. Top-level arguments win over the same fields here.",
+		},
+	}
+	if withID {
+		properties["id"] = map[string]any{"type": "string"}
+	}
+	return properties
+}
+
 func toolDefinitions(driverName string) []toolDefinition {
 	timestampSchema := map[string]any{
 		"type":        "string",
-		"description": "RFC3339 timestamp (e.g. 2024-01-02T15:04:05Z).",
+		"description": "RFC3339 timestamp (e.g. 2024-01-02T15:04:05Z) or epoch seconds/milliseconds.",
+	}
+	rangeSchema := map[string]any{
+		"type":        "string",
+		"description": "RFC3339 timestamp (e.g. 2024-01-02T15:04:05Z), epoch seconds/milliseconds, or relative to now (e.g. now, -7d, now-24h). A missing to defaults to now; a missing from looks back 24h from to.",
+	}
+	lastSchema := map[string]any{
+		"type":        "string",
+		"description": "Window ending now as <number><unit> (e.g. 6h, 7d). Cannot be combined with from/to.",
+		"pattern":     "^\\d+(s|m|h|d|w|mo|q|y)$",
 	}
 	granularitySchema := map[string]any{
 		"type":        "string",
 		"description": "Granularity as <number><unit> (e.g. 1m, 1h, 1d).",
 		"pattern":     "^\\d+(s|m|h|d|w|mo|q|y)$",
 	}
+	fillSchema := map[string]any{
+		"type":        "string",
+		"description": "Fill empty buckets with zeros, the previous value, or a linear interpolation between neighbours.",
+		"enum":        []string{"zero", "previous", "linear"},
+	}
+	fillEdgesSchema := map[string]any{
+		"type":        "string",
+		"description": "How fill treats leading/trailing gaps: leave them empty (none, default), write zeros, or repeat the nearest value.",
+		"enum":        []string{"none", "zero", "nearest"},
+	}
 
 	tools := []toolDefinition{
 		{
@@ -1028,9 +1572,15 @@ func toolDefinitions(driverName string) []toolDefinition {
 			InputSchema: map[string]any{
 				"type": "object",
 				"properties": map[string]any{
-					"from":        timestampSchema,
-					"to":          timestampSchema,
+					"from":        rangeSchema,
+					"to":          rangeSchema,
+					"last":        lastSchema,
 					"granularity": granularitySchema,
+					"sort":        map[string]any{"type": "string", "enum": []string{"name", "observations"}},
+					"desc":        map[string]any{"type": "boolean"},
+					"limit":       map[string]any{"type": "integer", "minimum": 0},
+					"prefix":      map[string]any{"type": "string", "description": "Only list keys starting with this prefix."},
+					"match":       map[string]any{"type": "string", "description": "Only list keys matching this Go regular expression."},
 				},
 			},
 		},
@@ -1041,23 +1591,28 @@ func toolDefinitions(driverName string) []toolDefinition {
 				"type": "object",
 				"properties": map[string]any{
 					"key":         map[string]any{"type": "string"},
-					"from":        timestampSchema,
-					"to":          timestampSchema,
+					"from":        rangeSchema,
+					"to":          rangeSchema,
+					"last":        lastSchema,
 					"granularity": granularitySchema,
+					"fill":        fillSchema,
+					"fill_edges":  fillEdgesSchema,
+					"skip_blanks": map[string]any{"type": "boolean", "description": "Leave out buckets with no data instead of returning them empty."},
 				},
 			},
 		},
 		{
 			Name:        "aggregate_series",
-			Description: "Aggregate a metric series (sum, mean, min, max).",
+			Description: "Aggregate a metric series (sum, mean, min, max, population stddev/variance, or p50/p90/p95/p99 percentiles).",
 			InputSchema: map[string]any{
 				"type": "object",
 				"properties": map[string]any{
 					"key":         map[string]any{"type": "string"},
 					"value_path":  map[string]any{"type": "string"},
-					"aggregator":  map[string]any{"type": "string", "enum": []string{"sum", "mean", "min", "max"}},
-					"from":        timestampSchema,
-					"to":          timestampSchema,
+					"aggregator":  map[string]any{"type": "string", "enum": []string{"sum", "mean", "min", "max", "stddev", "variance", "p50", "p90", "p95", "p99"}},
+					"from":        rangeSchema,
+					"to":          rangeSchema,
+					"last":        lastSchema,
 					"granularity": granularitySchema,
 					"slices":      map[string]any{"type": "integer", "minimum": 1},
 				},
@@ -1072,10 +1627,20 @@ func toolDefinitions(driverName string) []toolDefinition {
 				"properties": map[string]any{
 					"key":         map[string]any{"type": "string"},
 					"value_path":  map[string]any{"type": "string"},
-					"from":        timestampSchema,
-					"to":          timestampSchema,
+					"from":        rangeSchema,
+					"to":          rangeSchema,
+					"last":        lastSchema,
 					"granularity": granularitySchema,
 					"slices":      map[string]any{"type": "integer", "minimum": 1},
+					"fill":        fillSchema,
+					"fill_edges":  fillEdgesSchema,
+					"skip_blanks": map[string]any{"type": "boolean", "description": "Leave out buckets with no data instead of returning them empty."},
+					"transform": map[string]any{
+						"type":        "string",
+						"description": "Post-process values after fill: change per bucket (delta), change per second (rate), or running total (cumsum).",
+						"enum":        []string{"delta", "rate", "cumsum"},
+					},
+					"strict_nil": map[string]any{"type": "boolean", "description": "With cumsum, leave empty buckets empty instead of counting them as 0."},
 				},
 				"required": []string{"key", "value_path"},
 			},
@@ -1088,17 +1653,19 @@ func toolDefinitions(driverName string) []toolDefinition {
 				"properties": map[string]any{
 					"key":         map[string]any{"type": "string"},
 					"value_path":  map[string]any{"type": "string"},
-					"from":        timestampSchema,
-					"to":          timestampSchema,
+					"from":        rangeSchema,
+					"to":          rangeSchema,
+					"last":        lastSchema,
 					"granularity": granularitySchema,
 					"slices":      map[string]any{"type": "integer", "minimum": 1},
+					"top":         map[string]any{"type": "integer", "minimum": 0, "description": "Keep the largest N categories, largest first, and sum the rest into \"other\"."},
 				},
 				"required": []string{"key", "value_path"},
 			},
 		},
 		{
 			Name:        "write_metric",
-			Description: "Write a metric event.",
+			Description: "Write a metric event. Local drivers report the mode used in the response.",
 			InputSchema: map[string]any{
 				"type": "object",
 				"properties": map[string]any{
@@ -1107,8 +1674,18 @@ func toolDefinitions(driverName string) []toolDefinition {
 					"values": map[string]any{
 						"type": []string{"object", "array", "string", "number", "boolean", "null"},
 					},
+					"mode": map[string]any{
+						"type":        "string",
+						"enum":        []string{"track", "assert"},
+						"description": "track adds to stored values (default); assert overwrites them and needs a local driver.",
+					},
+					"set": map[string]any{
+						"type":                 "object",
+						"description":          "Shorthand for simple values: dotted paths to numbers, e.g. {\"count\": 1, \"status.ok\": 1}. Merged over values.",
+						"additionalProperties": map[string]any{"type": []string{"number", "string"}},
+					},
 				},
-				"required": []string{"key", "values"},
+				"required": []string{"key"},
 			},
 		},
 	}
@@ -1134,14 +1711,112 @@ func toolDefinitions(driverName string) []toolDefinition {
 					"required": []string{"id"},
 				},
 			},
+			toolDefinition{
+				Name:        "create_transponder",
+				Description: "Create a transponder. name and kind are required; other REST fields go in payload.",
+				InputSchema: map[string]any{
+					"type":       "object",
+					"properties": transponderToolProperties(false),
+					"required":   []string{"name", "kind"},
+				},
+			},
+			toolDefinition{
+				Name:        "update_transponder",
+				Description: "Update a transponder by id with the given fields; fields left out are unchanged.",
+				InputSchema: map[string]any{
+					"type":       "object",
+					"properties": transponderToolProperties(true),
+					"required":   []string{"id"},
+				},
+			},
+			toolDefinition{
+				Name:        "test_transponder",
+				Description: "Fire a transponder once and return the delivery result, including the destination's response when the server reports it.",
+				InputSchema: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"id": map[string]any{"type": "string"},
+					},
+					"required": []string{"id"},
+				},
+			},
+			toolDefinition{
+				Name:        "pause_transponder",
+				Description: "Pause a transponder by id; fails if it does not end up paused.",
+				InputSchema: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"id": map[string]any{"type": "string"},
+					},
+					"required": []string{"id"},
+				},
+			},
+			toolDefinition{
+				Name:        "resume_transponder",
+				Description: "Resume a paused transponder by id; fails if it does not end up active.",
+				InputSchema: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"id": map[string]any{"type": "string"},
+					},
+					"required": []string{"id"},
+				},
+			},
 		)
+	} else {
+		tools = append(tools, toolDefinition{
+			Name:        "setup_storage",
+			Description: "Create the local driver's metrics table or collection if it is missing. Safe to call again; reports whether anything was created.",
+			InputSchema: map[string]any{
+				"type":       "object",
+				"properties": map[string]any{},
+			},
+		})
+	}
+
+	for _, tool := range tools {
+		properties, ok := tool.InputSchema["properties"].(map[string]any)
+		if !ok {
+			continue
+		}
+		properties["compact"] = map[string]any{
+			"type":        "boolean",
+			"description": "Return single-line JSON instead of indented JSON.",
+		}
+		switch tool.Name {
+		case "aggregate_series", "format_timeline", "format_category":
+			properties["table_limit"] = map[string]any{
+				"type":        "integer",
+				"minimum":     0,
+				"description": "Keep only the first N rows of the result table (0 keeps all).",
+			}
+			properties["table_tail"] = map[string]any{
+				"type":        "integer",
+				"minimum":     0,
+				"description": "Keep only the last N rows of the result table (0 keeps all).",
+			}
+		}
 	}
 
 	return tools
 }
 
-func toolResultFromJSON(payload any) toolResult {
-	encoded, err := json.MarshalIndent(payload, "", "  ")
+// limitPayloadTable caps the rows of a query payload's table using the
+// table_limit and table_tail tool arguments.
+func limitPayloadTable(payload map[string]any, args map[string]any) {
+	output.LimitPayloadTable(payload, getIntArg(args, "table_limit", 0), getIntArg(args, "table_tail", 0))
+}
+
+// toolResultFromJSON encodes payload as the tool's text content. Compact
+// results skip indentation to save tokens.
+func toolResultFromJSON(payload any, compact bool) toolResult {
+	var encoded []byte
+	var err error
+	if compact {
+		encoded, err = json.Marshal(payload)
+	} else {
+		encoded, err = json.MarshalIndent(payload, "", "  ")
+	}
 	if err != nil {
 		return toolErrorResult(err)
 	}
@@ -1187,6 +1862,10 @@ func rpcResult(id json.RawMessage, result any) *rpcResponse {
 	}
 }
 
+func invalidRequestError(message string) *rpcError {
+	return &rpcError{Code: -32600, Message: message}
+}
+
 func invalidParamsError(message string) *rpcError {
 	return &rpcError{Code: -32602, Message: message}
 }
@@ -1195,6 +1874,52 @@ func methodNotFoundError(message string) *rpcError {
 	return &rpcError{Code: -32601, Message: message}
 }
 
+// withTimeframeWarning surfaces the oversized-timeframe hint in the tool
+// payload, since MCP clients never see stderr.
+func withTimeframeWarning(payload map[string]any, from, to, granularity string) map[string]any {
+	if warning := timeframePointsWarning(from, to, granularity); warning != "" && payload != nil {
+		payload["warning"] = warning
+	}
+	return payload
+}
+
+func resolveTimeRangeArgs(args map[string]any) (string, string, error) {
+	return resolveTimeRange(getStringArg(args, "from"), getStringArg(args, "to"), getStringArg(args, "last"))
+}
+
+// fillArgs reads the optional fill and fill_edges tool arguments.
+func fillArgs(args map[string]any) (fillOptions, error) {
+	return newFillOptions(getStringArg(args, "fill"), getStringArg(args, "fill_edges"))
+}
+
+// categoryArgs reads the optional top tool argument. Rolled-up breakdowns
+// list the largest categories first.
+// slicesArg reads the slices argument, defaulting to 1, and rejects counts
+// above the number of buckets in the timeframe.
+func slicesArg(args map[string]any, from, to, granularity string) (int, error) {
+	slices := getIntArg(args, "slices", 1)
+	if slices < 1 {
+		return 0, fmt.Errorf("slices must be at least 1")
+	}
+	if buckets, over := slicesExceedBuckets(slices, from, to, granularity); over {
+		return 0, fmt.Errorf("slices %d exceeds the %d %s buckets in the timeframe; use at most %d", slices, buckets, granularity, buckets)
+	}
+	return slices, nil
+}
+
+func categoryArgs(args map[string]any) (categoryOptions, error) {
+	top := getIntArg(args, "top", 0)
+	if top < 0 {
+		return categoryOptions{}, fmt.Errorf("top must be 0 or greater")
+	}
+	return categoryOptions{Sort: "value", Desc: true, Top: top}, nil
+}
+
+// transformArgs reads the optional transform and strict_nil tool arguments.
+func transformArgs(args map[string]any) (transformOptions, error) {
+	return newTransformOptions(getStringArg(args, "transform"), getBoolArg(args, "strict_nil"))
+}
+
 func getStringArg(args map[string]any, key string) string {
 	value, ok := args[key]
 	if !ok || value == nil {
@@ -1211,6 +1936,18 @@ func getStringArg(args map[string]any, key string) string {
 	}
 }
 
+func getBoolArg(args map[string]any, key string) bool {
+	switch v := args[key].(type) {
+	case bool:
+		return v
+	case string:
+		parsed, err := strconv.ParseBool(strings.TrimSpace(v))
+		return err == nil && parsed
+	default:
+		return false
+	}
+}
+
 func getIntArg(args map[string]any, key string, fallback int) int {
 	value, ok := args[key]
 	if !ok || value == nil {
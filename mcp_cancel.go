@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// defaultMCPToolTimeout bounds each tools/call unless --tool-timeout says
+// otherwise.
+const defaultMCPToolTimeout = 60 * time.Second
+
+// mcpCalls tracks the contexts of requests that have been read but not yet
+// answered, so notifications/cancelled can abort them whether they are
+// running or still waiting their turn.
+type mcpCalls struct {
+	mu       sync.Mutex
+	inflight map[string]*inflightCall
+}
+
+type inflightCall struct {
+	cancel    context.CancelFunc
+	cancelled bool
+}
+
+type cancelledParams struct {
+	RequestID json.RawMessage `json:"requestId"`
+	Reason    string          `json:"reason,omitempty"`
+}
+
+// track registers the request with id and returns its context along with a
+// finish func to call once it has been handled. finish reports whether the
+// client cancelled the request, in which case no response should be sent.
+// Notifications have no id and are not tracked.
+func (c *mcpCalls) track(ctx context.Context, id json.RawMessage) (context.Context, func() bool) {
+	if len(id) == 0 {
+		return ctx, func() bool { return false }
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	key := string(bytes.TrimSpace(id))
+	call := &inflightCall{cancel: cancel}
+
+	c.mu.Lock()
+	if c.inflight == nil {
+		c.inflight = map[string]*inflightCall{}
+	}
+	c.inflight[key] = call
+	c.mu.Unlock()
+
+	return ctx, func() bool {
+		cancel()
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.inflight[key] == call {
+			delete(c.inflight, key)
+		}
+		return call.cancelled
+	}
+}
+
+// cancel aborts the request params names. Requests that already finished,
+// or were never seen, are ignored as the protocol asks.
+func (c *mcpCalls) cancel(params json.RawMessage) {
+	var cancelled cancelledParams
+	if err := json.Unmarshal(params, &cancelled); err != nil || len(cancelled.RequestID) == 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if call, ok := c.inflight[string(bytes.TrimSpace(cancelled.RequestID))]; ok {
+		call.cancelled = true
+		call.cancel()
+	}
+}
+
+// handleCancellation handles req when it is a notifications/cancelled
+// message. Transports call it as soon as a message is read, ahead of the
+// requests queued for dispatch.
+func handleCancellation(state *mcpState, req rpcRequest) bool {
+	if req.Method != "notifications/cancelled" {
+		return false
+	}
+	state.calls.cancel(req.Params)
+	return true
+}
+
+// withToolTimeout bounds one tools/call by the configured timeout.
+func withToolTimeout(ctx context.Context, state *mcpState) (context.Context, context.CancelFunc) {
+	if state.ToolTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, state.ToolTimeout)
+}
+
+// toolTimeoutError replaces err with a clearer message when the tool ran out
+// of time.
+func toolTimeoutError(ctx context.Context, state *mcpState, name string, err error) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%s timed out after %s (raise --tool-timeout for longer queries)", name, state.ToolTimeout)
+	}
+	return err
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/trifle-io/trifle-cli/internal/api"
+)
+
+// slowAPI starts a server whose requests hang until the client gives up.
+// started receives when a request arrives and aborted when its client
+// cancels it.
+func slowAPI(t *testing.T) (*api.Client, chan struct{}, chan struct{}) {
+	t.Helper()
+	started := make(chan struct{}, 1)
+	aborted := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		select {
+		case <-r.Context().Done():
+			aborted <- struct{}{}
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	t.Cleanup(server.Close)
+	client, err := api.New(server.URL, "token", 10*time.Second)
+	if err != nil {
+		t.Fatalf("api.New returned error: %v", err)
+	}
+	return client, started, aborted
+}
+
+func waitFor(t *testing.T, ch chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-ch:
+	case <-time.After(3 * time.Second):
+		t.Fatalf("timed out waiting for %s", what)
+	}
+}
+
+func TestMCPToolTimeout(t *testing.T) {
+	t.Parallel()
+
+	client, _, aborted := slowAPI(t)
+	state := &mcpState{Driver: "api", API: client, ToolTimeout: 50 * time.Millisecond}
+	params, _ := json.Marshal(map[string]any{"name": "list_transponders", "arguments": map[string]any{}})
+
+	response, err := handleMCPRequest(context.Background(), state, rpcRequest{JSONRPC: "2.0", ID: json.RawMessage("1"), Method: "tools/call", Params: params})
+	if err != nil {
+		t.Fatalf("tools/call returned error: %v", err)
+	}
+	result := response.Result.(toolResult)
+	if !result.IsError || result.Content[0].Text != "list_transponders timed out after 50ms (raise --tool-timeout for longer queries)" {
+		t.Fatalf("result = %+v, want a timeout error", result)
+	}
+	waitFor(t, aborted, "the API request to abort")
+}
+
+func TestMCPCancelledRequest(t *testing.T) {
+	t.Parallel()
+
+	client, started, aborted := slowAPI(t)
+	state := &mcpState{Driver: "api", API: client}
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- serveMCPStream(context.Background(), state, inR, outW)
+		outW.Close()
+	}()
+
+	encoder := json.NewEncoder(inW)
+	encoder.Encode(map[string]any{"jsonrpc": "2.0", "id": 1, "method": "tools/call", "params": map[string]any{"name": "list_transponders"}})
+	waitFor(t, started, "the API request")
+	encoder.Encode(map[string]any{"jsonrpc": "2.0", "method": "notifications/cancelled", "params": map[string]any{"requestId": 1, "reason": "user abort"}})
+	encoder.Encode(map[string]any{"jsonrpc": "2.0", "id": 2, "method": "tools/list"})
+	waitFor(t, aborted, "the API request to abort")
+
+	decoder := json.NewDecoder(outR)
+	var response struct {
+		ID json.RawMessage `json:"id"`
+	}
+	if err := decoder.Decode(&response); err != nil {
+		t.Fatalf("read response: %v", err)
+	}
+	if string(response.ID) != "2" {
+		t.Fatalf("first response id = %s, want 2 (the cancelled call must not be answered)", response.ID)
+	}
+
+	inW.Close()
+	if err := <-done; err != nil {
+		t.Fatalf("serveMCPStream returned error: %v", err)
+	}
+	if err := decoder.Decode(&response); err != io.EOF {
+		t.Fatalf("extra response after cancellation: %s (err %v)", response.ID, err)
+	}
+}
+
+func TestMCPCallsIgnoreUnknownCancellation(t *testing.T) {
+	t.Parallel()
+
+	var calls mcpCalls
+	ctx, finish := calls.track(context.Background(), json.RawMessage(`"a"`))
+	calls.cancel(json.RawMessage(`{"requestId":"b"}`))
+	calls.cancel(json.RawMessage(`not json`))
+	if ctx.Err() != nil {
+		t.Fatalf("request a cancelled by an unrelated notification")
+	}
+	if finish() {
+		t.Fatalf("finish reported a cancellation that never happened")
+	}
+	if len(calls.inflight) != 0 {
+		t.Fatalf("inflight = %v, want empty after finish", calls.inflight)
+	}
+}
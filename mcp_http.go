@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// mcpHTTPPath is the endpoint trifle mcp --listen serves.
+const mcpHTTPPath = "/mcp"
+
+// maxMCPHTTPBody caps one POSTed JSON-RPC message or batch.
+const maxMCPHTTPBody = 10 << 20
+
+const mcpSessionHeader = "Mcp-Session-Id"
+
+// mcpHTTPServer serves the MCP JSON-RPC handler over the streamable HTTP
+// transport with a single session: initialize starts it, replacing any
+// earlier one, and every later request must carry its Mcp-Session-Id.
+// Each POST gets its response on its own connection, as JSON or as an SSE
+// stream when the client only accepts text/event-stream.
+type mcpHTTPServer struct {
+	state *mcpState
+
+	// handle runs one JSON-RPC message at a time, as the stdio loop does;
+	// the tool handlers were not written for concurrent use.
+	handle sync.Mutex
+
+	mu      sync.Mutex
+	session string
+}
+
+func (s *mcpHTTPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !allowedMCPOrigin(r.Header.Get("Origin")) {
+		http.Error(w, "forbidden origin", http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		s.servePost(w, r)
+	case http.MethodDelete:
+		if !s.checkSession(w, r) {
+			return
+		}
+		s.mu.Lock()
+		s.session = ""
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		// There are no server-initiated messages to stream on GET.
+		w.Header().Set("Allow", "POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *mcpHTTPServer) servePost(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxMCPHTTPBody))
+	if err != nil {
+		writeMCPHTTPError(w, http.StatusRequestEntityTooLarge, &rpcError{Code: -32600, Message: err.Error()})
+		return
+	}
+	requests, batch, err := decodeRPCMessages(body)
+	if err != nil {
+		writeMCPHTTPError(w, http.StatusBadRequest, &rpcError{Code: -32700, Message: err.Error()})
+		return
+	}
+
+	initializing := false
+	for _, req := range requests {
+		if req.Method == "initialize" {
+			initializing = true
+		}
+	}
+	if !initializing && !s.checkSession(w, r) {
+		return
+	}
+
+	// Cancellations skip the queue so they reach a call that is still
+	// running on another connection.
+	pending := make([]queuedRequest, 0, len(requests))
+	for _, req := range requests {
+		if req.JSONRPC == "2.0" && handleCancellation(s.state, req) {
+			continue
+		}
+		ctx, finish := s.state.calls.track(r.Context(), req.ID)
+		pending = append(pending, queuedRequest{req: req, ctx: ctx, finish: finish})
+	}
+
+	responses := make([]*rpcResponse, 0, len(pending))
+	initialized := false
+	// Log notifications raised while handling can only reach the client on
+	// an SSE stream, ahead of the responses.
+	stream := acceptsOnlyEventStream(r.Header.Get("Accept"))
+	var notifications []rpcNotification
+	s.handle.Lock()
+	if stream {
+		s.state.Log.setNotify(func(method string, params any) {
+			notifications = append(notifications, rpcNotification{JSONRPC: "2.0", Method: method, Params: params})
+		})
+	}
+	for _, queued := range pending {
+		req := queued.req
+		response := mcpReply(queued.ctx, s.state, req)
+		if queued.finish() || response == nil {
+			continue
+		}
+		if req.Method == "initialize" && response.Error == nil {
+			initialized = true
+		}
+		responses = append(responses, response)
+	}
+	s.state.Log.setNotify(nil)
+	s.handle.Unlock()
+
+	if initialized {
+		session, err := newMCPSessionID()
+		if err != nil {
+			writeMCPHTTPError(w, http.StatusInternalServerError, &rpcError{Code: -32603, Message: err.Error()})
+			return
+		}
+		s.mu.Lock()
+		s.session = session
+		s.mu.Unlock()
+		w.Header().Set(mcpSessionHeader, session)
+	}
+
+	if len(responses) == 0 {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	if stream {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		flusher, _ := w.(http.Flusher)
+		messages := make([]any, 0, len(notifications)+len(responses))
+		for _, notification := range notifications {
+			messages = append(messages, notification)
+		}
+		for _, response := range responses {
+			messages = append(messages, response)
+		}
+		for _, message := range messages {
+			encoded, err := json.Marshal(message)
+			if err != nil {
+				return
+			}
+			if _, err := fmt.Fprintf(w, "event: message\ndata: %s\n\n", encoded); err != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		return
+	}
+
+	var payload any = responses[0]
+	if batch {
+		payload = responses
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(payload)
+}
+
+// checkSession rejects requests without the current session id: 400 when
+// the header is missing and 404 when it names no live session, which tells
+// the client to initialize again.
+func (s *mcpHTTPServer) checkSession(w http.ResponseWriter, r *http.Request) bool {
+	id := r.Header.Get(mcpSessionHeader)
+	if id == "" {
+		http.Error(w, "missing "+mcpSessionHeader+" header; send initialize first", http.StatusBadRequest)
+		return false
+	}
+	s.mu.Lock()
+	current := s.session
+	s.mu.Unlock()
+	if id != current {
+		http.Error(w, "unknown MCP session; send initialize again", http.StatusNotFound)
+		return false
+	}
+	return true
+}
+
+// decodeRPCMessages reads one JSON-RPC message or a batch of them.
+func decodeRPCMessages(body []byte) ([]rpcRequest, bool, error) {
+	body = bytes.TrimSpace(body)
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if len(body) > 0 && body[0] == '[' {
+		var requests []rpcRequest
+		if err := decoder.Decode(&requests); err != nil {
+			return nil, true, fmt.Errorf("parse JSON-RPC batch: %w", err)
+		}
+		if len(requests) == 0 {
+			return nil, true, fmt.Errorf("empty JSON-RPC batch")
+		}
+		return requests, true, nil
+	}
+	var req rpcRequest
+	if err := decoder.Decode(&req); err != nil {
+		return nil, false, fmt.Errorf("parse JSON-RPC message: %w", err)
+	}
+	return []rpcRequest{req}, false, nil
+}
+
+func writeMCPHTTPError(w http.ResponseWriter, status int, rpcErr *rpcError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(&rpcResponse{JSONRPC: "2.0", Error: rpcErr})
+}
+
+// acceptsOnlyEventStream reports whether the client asked for SSE and not
+// JSON. Clients that accept both get plain JSON responses.
+func acceptsOnlyEventStream(accept string) bool {
+	eventStream, plainJSON := false, false
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, _ := strings.Cut(strings.TrimSpace(part), ";")
+		switch strings.ToLower(strings.TrimSpace(mediaType)) {
+		case "text/event-stream":
+			eventStream = true
+		case "application/json", "*/*", "application/*":
+			plainJSON = true
+		}
+	}
+	return eventStream && !plainJSON
+}
+
+// allowedMCPOrigin accepts requests without an Origin header and browser
+// requests from loopback pages, which keeps other sites from reaching the
+// server through DNS rebinding.
+func allowedMCPOrigin(origin string) bool {
+	if origin == "" {
+		return true
+	}
+	parsed, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return isLoopbackHost(parsed.Hostname())
+}
+
+func isLoopbackHost(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func newMCPSessionID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate session id: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// serveMCPHTTP serves the MCP handler on addr until interrupted.
+func serveMCPHTTP(ctx context.Context, state *mcpState, addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("listen on %s: %w", addr, err)
+	}
+	host, _, _ := net.SplitHostPort(listener.Addr().String())
+	if !isLoopbackHost(host) {
+		fmt.Fprintf(os.Stderr, "warning: %s is not a loopback address; the MCP server has no authentication\n", addr)
+	}
+	fmt.Fprintf(os.Stderr, "trifle mcp listening on http://%s%s\n", listener.Addr(), mcpHTTPPath)
+
+	mux := http.NewServeMux()
+	mux.Handle(mcpHTTPPath, &mcpHTTPServer{state: state})
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mcpHTTPClient posts JSON-RPC messages to a test MCP HTTP server.
+type mcpHTTPClient struct {
+	t       *testing.T
+	url     string
+	session string
+}
+
+func (c *mcpHTTPClient) post(body string, accept string) *http.Response {
+	c.t.Helper()
+	req, err := http.NewRequest(http.MethodPost, c.url, strings.NewReader(body))
+	if err != nil {
+		c.t.Fatalf("NewRequest returned error: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", accept)
+	if c.session != "" {
+		req.Header.Set(mcpSessionHeader, c.session)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		c.t.Fatalf("POST returned error: %v", err)
+	}
+	return resp
+}
+
+// call sends one request and decodes its JSON response.
+func (c *mcpHTTPClient) call(id int, method string, params any) rpcResponse {
+	c.t.Helper()
+	encoded, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": id, "method": method, "params": params})
+	resp := c.post(string(encoded), "application/json, text/event-stream")
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		c.t.Fatalf("%s: status %d: %s", method, resp.StatusCode, body)
+	}
+	var response rpcResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		c.t.Fatalf("%s: decode response: %v", method, err)
+	}
+	if response.Error != nil {
+		c.t.Fatalf("%s: rpc error: %v", method, response.Error)
+	}
+	return response
+}
+
+func TestMCPHTTPTransport(t *testing.T) {
+	server := httptest.NewServer(&mcpHTTPServer{state: newSQLiteMCPState(t)})
+	defer server.Close()
+	client := &mcpHTTPClient{t: t, url: server.URL}
+
+	resp := client.post(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`, "application/json")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("tools/list before initialize: status %d, want 400", resp.StatusCode)
+	}
+
+	resp = client.post(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05"}}`, "application/json, text/event-stream")
+	resp.Body.Close()
+	client.session = resp.Header.Get(mcpSessionHeader)
+	if resp.StatusCode != http.StatusOK || client.session == "" {
+		t.Fatalf("initialize: status %d, session %q", resp.StatusCode, client.session)
+	}
+
+	resp = client.post(`{"jsonrpc":"2.0","method":"notifications/initialized"}`, "application/json, text/event-stream")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("notification: status %d, want 202", resp.StatusCode)
+	}
+
+	tools := client.call(2, "tools/list", nil).Result.(map[string]any)["tools"].([]any)
+	if len(tools) == 0 {
+		t.Fatal("tools/list returned no tools")
+	}
+
+	at := time.Now().UTC().Add(-time.Hour).Format(time.RFC3339)
+	result := client.call(3, "tools/call", map[string]any{
+		"name":      "write_metric",
+		"arguments": map[string]any{"key": "event::logs", "at": at, "values": map[string]any{"count": 1}},
+	}).Result.(map[string]any)
+	if result["isError"] == true {
+		t.Fatalf("write_metric failed: %v", result)
+	}
+
+	// Concurrent calls in the session each get their own, intact response.
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			encoded, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": id, "method": "tools/call", "params": map[string]any{
+				"name":      "fetch_series",
+				"arguments": map[string]any{"key": "event::logs", "last": "1d", "granularity": "1h"},
+			}})
+			resp := client.post(string(encoded), "application/json, text/event-stream")
+			defer resp.Body.Close()
+			var response rpcResponse
+			if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+				errs <- fmt.Errorf("call %d: %w", id, err)
+				return
+			}
+			if string(response.ID) != fmt.Sprint(id) || response.Result.(map[string]any)["isError"] == true {
+				errs <- fmt.Errorf("call %d: got %s %v", id, response.ID, response.Result)
+			}
+		}(100 + i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	// Messages without jsonrpc "2.0" are answered as invalid requests, with a
+	// null id when they have none.
+	resp = client.post(`[{"id":6,"method":"tools/list"},{"jsonrpc":"","method":"ping"}]`, "application/json, text/event-stream")
+	var invalid []rpcResponse
+	err := json.NewDecoder(resp.Body).Decode(&invalid)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("decode invalid batch: %v", err)
+	}
+	if len(invalid) != 2 || string(invalid[0].ID) != "6" || string(invalid[1].ID) != "null" {
+		t.Fatalf("invalid batch = %+v, want responses for id 6 and null", invalid)
+	}
+	for _, response := range invalid {
+		if response.Error == nil || response.Error.Code != -32600 {
+			t.Fatalf("invalid request %s: error = %v, want -32600", response.ID, response.Error)
+		}
+	}
+
+	// A client that only accepts SSE gets the response as an event.
+	resp = client.post(`[{"jsonrpc":"2.0","id":4,"method":"resources/list"},{"jsonrpc":"2.0","method":"notifications/initialized"}]`, "text/event-stream")
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q, want text/event-stream", ct)
+	}
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	var events []string
+	for scanner.Scan() {
+		if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+			events = append(events, data)
+		}
+	}
+	resp.Body.Close()
+	if len(events) != 1 || !strings.Contains(events[0], `"id":4`) {
+		t.Fatalf("events = %q, want the resources/list response", events)
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, server.URL, nil)
+	req.Header.Set(mcpSessionHeader, client.session)
+	if resp, err := http.DefaultClient.Do(req); err != nil || resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("DELETE: %v %v", resp, err)
+	}
+	resp = client.post(`{"jsonrpc":"2.0","id":5,"method":"tools/list"}`, "application/json")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("tools/list after DELETE: status %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestMCPHTTPRejectsForeignOrigin(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(&mcpHTTPServer{state: &mcpState{Driver: "api"}})
+	defer server.Close()
+
+	for origin, want := range map[string]int{
+		"https://evil.example":  http.StatusForbidden,
+		"http://localhost:3000": http.StatusOK,
+		"http://127.0.0.1":      http.StatusOK,
+	} {
+		req, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"initialize"}`))
+		req.Header.Set("Origin", origin)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("POST returned error: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Fatalf("Origin %s: status %d, want %d", origin, resp.StatusCode, want)
+		}
+	}
+}
+
+func TestAcceptsOnlyEventStream(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]bool{
+		"text/event-stream":                   true,
+		"text/event-stream;q=1":               true,
+		"application/json, text/event-stream": false,
+		"*/*":                                 false,
+		"":                                    false,
+	}
+	for accept, want := range tests {
+		if got := acceptsOnlyEventStream(accept); got != want {
+			t.Fatalf("acceptsOnlyEventStream(%q) = %v, want %v", accept, got, want)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// mcpLogger logs each MCP request to stderr, which stays free of protocol
+// frames, and to the client as notifications/message once it has asked for
+// them with logging/setLevel.
+type mcpLogger struct {
+	stderr *slog.Logger
+
+	mu sync.Mutex
+	// client is the level set by logging/setLevel; nil until the client
+	// sets one, so clients that never ask get no log notifications.
+	client *slog.Level
+	// notify sends a notification to the client. Transports set it while
+	// they can deliver one.
+	notify func(method string, params any)
+}
+
+func newMCPLogger(w io.Writer, level slog.Level) *mcpLogger {
+	return &mcpLogger{stderr: slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{Level: level}))}
+}
+
+// parseLogLevel reads a --log-level value.
+func parseLogLevel(value string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return 0, usageErrorf("--log-level must be debug, info, warn, or error")
+	}
+}
+
+// mcpLogLevels maps the syslog levels MCP clients use to slog levels.
+var mcpLogLevels = map[string]slog.Level{
+	"debug":     slog.LevelDebug,
+	"info":      slog.LevelInfo,
+	"notice":    slog.LevelInfo,
+	"warning":   slog.LevelWarn,
+	"error":     slog.LevelError,
+	"critical":  slog.LevelError,
+	"alert":     slog.LevelError,
+	"emergency": slog.LevelError,
+}
+
+func mcpLogLevelName(level slog.Level) string {
+	switch {
+	case level >= slog.LevelError:
+		return "error"
+	case level >= slog.LevelWarn:
+		return "warning"
+	case level >= slog.LevelInfo:
+		return "info"
+	default:
+		return "debug"
+	}
+}
+
+type setLevelParams struct {
+	Level string `json:"level"`
+}
+
+// handleSetLevel serves logging/setLevel.
+func handleSetLevel(state *mcpState, req rpcRequest) (*rpcResponse, error) {
+	var params setLevelParams
+	if len(req.Params) == 0 || json.Unmarshal(req.Params, &params) != nil {
+		return nil, invalidParamsError("invalid logging params")
+	}
+	level, ok := mcpLogLevels[strings.ToLower(strings.TrimSpace(params.Level))]
+	if !ok {
+		return nil, invalidParamsError(fmt.Sprintf("unknown log level: %s", params.Level))
+	}
+	if state.Log != nil {
+		state.Log.mu.Lock()
+		state.Log.client = &level
+		state.Log.mu.Unlock()
+	}
+	return rpcResult(req.ID, map[string]any{}), nil
+}
+
+// setNotify points client notifications at notify, or turns them off when
+// notify is nil.
+func (l *mcpLogger) setNotify(notify func(method string, params any)) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	l.notify = notify
+	l.mu.Unlock()
+}
+
+// request logs one handled request: its method, tool or resource, duration,
+// and error, plus the redacted params and result at debug level.
+func (l *mcpLogger) request(ctx context.Context, req rpcRequest, response *rpcResponse, err error, elapsed time.Duration) {
+	if l == nil {
+		return
+	}
+
+	level := slog.LevelInfo
+	fields := map[string]any{"method": req.Method, "duration_ms": elapsed.Milliseconds()}
+	if len(req.ID) == 0 {
+		level = slog.LevelDebug
+	}
+	var target struct {
+		Name string `json:"name"`
+		URI  string `json:"uri"`
+	}
+	if json.Unmarshal(req.Params, &target) == nil {
+		if target.Name != "" && req.Method == "tools/call" {
+			fields["tool"] = target.Name
+		}
+		if target.URI != "" {
+			fields["uri"] = target.URI
+		}
+	}
+	if message := requestError(response, err); message != "" {
+		fields["error"] = message
+		// Bad requests and failed tools are the client's to fix; internal
+		// errors are ours.
+		level = slog.LevelWarn
+		var rpcErr *rpcError
+		if err != nil && (!errors.As(err, &rpcErr) || rpcErr.Code == -32603) {
+			level = slog.LevelError
+		}
+	}
+
+	attrs := []any{"method", req.Method}
+	for _, name := range []string{"tool", "uri"} {
+		if value, ok := fields[name]; ok {
+			attrs = append(attrs, name, value)
+		}
+	}
+	attrs = append(attrs, "duration", elapsed.Round(time.Microsecond))
+	if message, ok := fields["error"]; ok {
+		attrs = append(attrs, "error", message)
+	}
+	if l.stderr.Enabled(ctx, slog.LevelDebug) {
+		attrs = append(attrs, "params", redactedJSON(req.Params))
+		if response != nil && response.Result != nil {
+			attrs = append(attrs, "result", redactedJSON(response.Result))
+		}
+	}
+	l.stderr.Log(ctx, level, "mcp request", attrs...)
+
+	l.mu.Lock()
+	client, notify := l.client, l.notify
+	l.mu.Unlock()
+	if client == nil || notify == nil || level < *client {
+		return
+	}
+	if *client <= slog.LevelDebug {
+		fields["params"] = redactValue(decodeLogValue(req.Params))
+	}
+	notify("notifications/message", map[string]any{
+		"level":  mcpLogLevelName(level),
+		"logger": "trifle-cli",
+		"data":   fields,
+	})
+}
+
+// requestError is the error a request ended with: a JSON-RPC error or a tool
+// error result.
+func requestError(response *rpcResponse, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	if response == nil {
+		return ""
+	}
+	if result, ok := response.Result.(toolResult); ok && result.IsError && len(result.Content) > 0 {
+		return result.Content[0].Text
+	}
+	return ""
+}
+
+// secretKeyPattern matches field names whose values are redacted from logs.
+// Only whole words count, so metric values like tokens or input_tokens stay.
+var secretKeyPattern = regexp.MustCompile(`(?i)^(.*[_-])?(token|secret|password|passwd|authorization|api[_-]?key|dsn|credentials?)$`)
+
+// redactedJSON encodes v for the log with secret-looking fields hidden.
+func redactedJSON(v any) string {
+	encoded, err := json.Marshal(redactValue(decodeLogValue(v)))
+	if err != nil {
+		return ""
+	}
+	return string(encoded)
+}
+
+// decodeLogValue turns v into plain JSON values so redactValue can walk it.
+func decodeLogValue(v any) any {
+	var raw []byte
+	switch value := v.(type) {
+	case json.RawMessage:
+		raw = value
+	default:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return nil
+		}
+		raw = encoded
+	}
+	if len(raw) == 0 {
+		return nil
+	}
+	var decoded any
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		return nil
+	}
+	return decoded
+}
+
+// redactValue hides the values of secret-looking keys. Strings holding JSON,
+// like tool result text, are decoded and redacted too.
+func redactValue(v any) any {
+	switch value := v.(type) {
+	case map[string]any:
+		redacted := make(map[string]any, len(value))
+		for key, item := range value {
+			if secretKeyPattern.MatchString(key) {
+				redacted[key] = redactedSecret
+				continue
+			}
+			redacted[key] = redactValue(item)
+		}
+		return redacted
+	case []any:
+		redacted := make([]any, len(value))
+		for i, item := range value {
+			redacted[i] = redactValue(item)
+		}
+		return redacted
+	case string:
+		trimmed := strings.TrimSpace(value)
+		if strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+			var decoded any
+			if json.Unmarshal([]byte(trimmed), &decoded) == nil {
+				return redactValue(decoded)
+			}
+		}
+		return value
+	default:
+		return value
+	}
+}
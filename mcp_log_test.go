@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func callMCPTool(state *mcpState, name string, args map[string]any) *rpcResponse {
+	params, _ := json.Marshal(map[string]any{"name": name, "arguments": args})
+	return mcpReply(context.Background(), state, rpcRequest{JSONRPC: "2.0", ID: json.RawMessage("1"), Method: "tools/call", Params: params})
+}
+
+func TestMCPLogRequests(t *testing.T) {
+	t.Parallel()
+
+	var logs bytes.Buffer
+	state := newSQLiteMCPState(t)
+	state.Log = newMCPLogger(&logs, slog.LevelInfo)
+
+	callMCPTool(state, "list_metrics", map[string]any{"last": "1d"})
+	callMCPTool(state, "fetch_series", map[string]any{"granularity": "hourly"})
+	mcpReply(context.Background(), state, rpcRequest{JSONRPC: "2.0", Method: "notifications/initialized"})
+
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("logged %d lines, want 2 (notifications only at debug):\n%s", len(lines), logs.String())
+	}
+	if !strings.Contains(lines[0], "level=INFO msg=\"mcp request\" method=tools/call tool=list_metrics duration=") || strings.Contains(lines[0], "params=") {
+		t.Fatalf("success line = %s", lines[0])
+	}
+	if !strings.Contains(lines[1], "level=WARN") || !strings.Contains(lines[1], "tool=fetch_series") || !strings.Contains(lines[1], "error=\"granularity must be") {
+		t.Fatalf("failure line = %s", lines[1])
+	}
+
+	logs.Reset()
+	state.Log = newMCPLogger(&logs, slog.LevelDebug)
+	callMCPTool(state, "write_metric", map[string]any{"key": "event::usage", "values": map[string]any{"tokens": 5}, "api_token": "sekret"})
+	if out := logs.String(); strings.Contains(out, "sekret") || !strings.Contains(out, redactedSecret) || !strings.Contains(out, `\"tokens\":5`) || !strings.Contains(out, "result=") {
+		t.Fatalf("debug log = %s, want redacted params and the result", out)
+	}
+}
+
+func TestMCPLoggingSetLevel(t *testing.T) {
+	t.Parallel()
+
+	state := newSQLiteMCPState(t)
+	state.Log = newMCPLogger(io.Discard, slog.LevelError)
+	var in bytes.Buffer
+	encoder := json.NewEncoder(&in)
+	encoder.Encode(map[string]any{"jsonrpc": "2.0", "id": 1, "method": "initialize"})
+	encoder.Encode(map[string]any{"jsonrpc": "2.0", "id": 2, "method": "tools/call", "params": map[string]any{"name": "list_metrics"}})
+	encoder.Encode(map[string]any{"jsonrpc": "2.0", "id": 3, "method": "logging/setLevel", "params": map[string]any{"level": "loud"}})
+	encoder.Encode(map[string]any{"jsonrpc": "2.0", "id": 4, "method": "logging/setLevel", "params": map[string]any{"level": "warning"}})
+	encoder.Encode(map[string]any{"jsonrpc": "2.0", "id": 5, "method": "tools/call", "params": map[string]any{"name": "list_metrics"}})
+	encoder.Encode(map[string]any{"jsonrpc": "2.0", "id": 6, "method": "tools/call", "params": map[string]any{"name": "fetch_series", "arguments": map[string]any{"granularity": "hourly"}}})
+
+	var out bytes.Buffer
+	if err := serveMCPStream(context.Background(), state, &in, &out); err != nil {
+		t.Fatalf("serveMCPStream returned error: %v", err)
+	}
+
+	var order []string
+	var notification map[string]any
+	decoder := json.NewDecoder(&out)
+	for {
+		var message struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+			Params map[string]any  `json:"params"`
+			Error  *rpcError       `json:"error"`
+			Result map[string]any  `json:"result"`
+		}
+		if err := decoder.Decode(&message); err != nil {
+			break
+		}
+		if message.Method != "" {
+			order = append(order, message.Method)
+			notification = message.Params
+			continue
+		}
+		order = append(order, string(message.ID))
+		if string(message.ID) == "1" {
+			if _, ok := message.Result["capabilities"].(map[string]any)["logging"]; !ok {
+				t.Fatalf("initialize capabilities = %v, want logging", message.Result["capabilities"])
+			}
+		}
+		if string(message.ID) == "3" && (message.Error == nil || message.Error.Message != "unknown log level: loud") {
+			t.Fatalf("setLevel loud = %+v, want an unknown level error", message.Error)
+		}
+	}
+
+	want := []string{"1", "2", "3", "4", "5", "notifications/message", "6"}
+	if !reflect.DeepEqual(order, want) {
+		t.Fatalf("messages = %v, want %v", order, want)
+	}
+	data := notification["data"].(map[string]any)
+	if notification["level"] != "warning" || notification["logger"] != "trifle-cli" || data["tool"] != "fetch_series" || !strings.HasPrefix(data["error"].(string), "granularity must be") {
+		t.Fatalf("notification = %v", notification)
+	}
+}
+
+func TestRedactValue(t *testing.T) {
+	t.Parallel()
+
+	got := redactValue(map[string]any{
+		"token":        "a",
+		"user_token":   "b",
+		"Password":     "c",
+		"input_tokens": 3,
+		"config":       map[string]any{"webhook_secret": "d", "url": "https://example.com"},
+		"text":         `{"auth":{"api_key":"e"},"count":1}`,
+		"list":         []any{map[string]any{"dsn": "f"}},
+	})
+	want := map[string]any{
+		"token":        redactedSecret,
+		"user_token":   redactedSecret,
+		"Password":     redactedSecret,
+		"input_tokens": 3,
+		"config":       map[string]any{"webhook_secret": redactedSecret, "url": "https://example.com"},
+		"text":         map[string]any{"auth": map[string]any{"api_key": redactedSecret}, "count": float64(1)},
+		"list":         []any{map[string]any{"dsn": redactedSecret}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("redactValue = %#v, want %#v", got, want)
+	}
+}
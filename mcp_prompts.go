@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// promptArgument describes one argument of an MCP prompt. Optional
+// arguments fall back to Default when the client leaves them out.
+type promptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Required    bool   `json:"required,omitempty"`
+	Default     string `json:"-"`
+}
+
+// promptDefinition is a canned prompt served by prompts/list and
+// prompts/get. Text is a text/template rendered with the arguments by name;
+// it may call previous to get the offset of the timeframe before one.
+type promptDefinition struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	Arguments   []promptArgument `json:"arguments"`
+	Text        string           `json:"-"`
+}
+
+var timeframeArgument = promptArgument{Name: "timeframe", Description: "How far back to look, e.g. 24h, 7d, or 1mo.", Default: "7d"}
+
+var mcpPrompts = []promptDefinition{
+	{
+		Name:        "analyze_metric",
+		Description: "Summarize one metric key over a recent timeframe.",
+		Arguments: []promptArgument{
+			{Name: "key", Description: "Metric key to analyze, e.g. event::signup.", Required: true},
+			timeframeArgument,
+			{Name: "granularity", Description: "Bucket size for the series, e.g. 1h or 1d.", Default: "1h"},
+		},
+		Text: `Summarize the metric {{.key}} over the last {{.timeframe}}.
+
+1. Call fetch_series with key "{{.key}}", last "{{.timeframe}}", and granularity "{{.granularity}}" to see which value paths it records.
+2. For the main value paths, call aggregate_series with the same key, last, and granularity, using sum for counts and mean, max, or p95 for measurements.
+3. Call format_timeline for the most important value path to see how it moved over time.
+
+Report the totals, the overall trend, the peaks and quiet periods with their times, and anything that looks anomalous. Say so if the series is empty.`,
+	},
+	{
+		Name:        "compare_timeframes",
+		Description: "Compare a metric over a recent timeframe with the timeframe just before it.",
+		Arguments: []promptArgument{
+			{Name: "key", Description: "Metric key to compare, e.g. event::signup.", Required: true},
+			{Name: "value_path", Description: "Value path to compare, e.g. count.", Default: "count"},
+			timeframeArgument,
+		},
+		Text: `Compare {{.value_path}} of the metric {{.key}} over the last {{.timeframe}} with the {{.timeframe}} before that.
+
+1. Call aggregate_series with key "{{.key}}", value_path "{{.value_path}}", aggregator "sum", and last "{{.timeframe}}".
+2. Call it again with from "-{{previous .timeframe}}" and to "-{{.timeframe}}" for the earlier period.
+3. Repeat both calls with aggregator "mean" and "max" if the value is a measurement rather than a count.
+
+Report both values, the absolute and percentage change, and whether the change looks significant. If either period has no data, say so rather than reporting a change.`,
+	},
+	{
+		Name:        "find_top_keys",
+		Description: "Find the busiest metric keys over a recent timeframe.",
+		Arguments: []promptArgument{
+			timeframeArgument,
+			{Name: "limit", Description: "How many keys to report.", Default: "10"},
+			{Name: "prefix", Description: "Only consider keys starting with this prefix."},
+		},
+		Text: `Find the {{.limit}} busiest metric keys over the last {{.timeframe}}{{if .prefix}} among keys starting with "{{.prefix}}"{{end}}.
+
+1. Call list_metrics with last "{{.timeframe}}", sort "observations", desc true, and limit {{.limit}}{{if .prefix}}, with prefix "{{.prefix}}"{{end}}.
+2. For the top three keys, call fetch_series with the same last to see what they record.
+
+Report the keys in order with their observation counts, and point out any key whose activity looks unexpected.`,
+	},
+}
+
+var promptFuncs = template.FuncMap{
+	// previous turns a timeframe like 7d into 14d, the offset where the
+	// timeframe before it starts. renderPrompt has validated the timeframe.
+	"previous": func(timeframe string) string {
+		amount, unit, _ := splitTimeframe(timeframe)
+		return strconv.Itoa(amount*2) + unit
+	},
+}
+
+// splitTimeframe splits a timeframe like 7d into its amount and unit.
+func splitTimeframe(timeframe string) (int, string, bool) {
+	match := granularityPattern.FindStringSubmatch(timeframe)
+	if match == nil {
+		return 0, "", false
+	}
+	amount, err := strconv.Atoi(strings.TrimSuffix(timeframe, match[1]))
+	if err != nil || amount <= 0 {
+		return 0, "", false
+	}
+	return amount, match[1], true
+}
+
+type promptGetParams struct {
+	Name      string            `json:"name"`
+	Arguments map[string]string `json:"arguments"`
+}
+
+func findPrompt(name string) (promptDefinition, bool) {
+	for _, prompt := range mcpPrompts {
+		if prompt.Name == name {
+			return prompt, true
+		}
+	}
+	return promptDefinition{}, false
+}
+
+// renderPrompt fills prompt's text with args, applying defaults and
+// checking required arguments.
+func renderPrompt(prompt promptDefinition, args map[string]string) (string, error) {
+	values := make(map[string]string, len(prompt.Arguments))
+	for _, arg := range prompt.Arguments {
+		value := strings.TrimSpace(args[arg.Name])
+		if value == "" {
+			if arg.Required {
+				return "", fmt.Errorf("argument %s is required", arg.Name)
+			}
+			value = arg.Default
+		}
+		values[arg.Name] = value
+	}
+	if timeframe, ok := values["timeframe"]; ok {
+		if _, _, valid := splitTimeframe(timeframe); !valid {
+			return "", fmt.Errorf("timeframe must be a positive <number><unit>, e.g. 7d")
+		}
+	}
+	if limit, ok := values["limit"]; ok {
+		if n, err := strconv.Atoi(limit); err != nil || n <= 0 {
+			return "", fmt.Errorf("limit must be a positive integer")
+		}
+	}
+
+	tmpl, err := template.New(prompt.Name).Funcs(promptFuncs).Option("missingkey=zero").Parse(prompt.Text)
+	if err != nil {
+		return "", err
+	}
+	var text strings.Builder
+	if err := tmpl.Execute(&text, values); err != nil {
+		return "", err
+	}
+	return text.String(), nil
+}
+
+func handlePromptGet(req rpcRequest) (*rpcResponse, error) {
+	if len(req.Params) == 0 {
+		return nil, invalidParamsError("missing params")
+	}
+
+	var params promptGetParams
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return nil, invalidParamsError("invalid prompt params")
+	}
+
+	prompt, ok := findPrompt(params.Name)
+	if !ok {
+		return nil, invalidParamsError(fmt.Sprintf("unknown prompt: %s", params.Name))
+	}
+	text, err := renderPrompt(prompt, params.Arguments)
+	if err != nil {
+		return nil, invalidParamsError(err.Error())
+	}
+
+	return rpcResult(req.ID, map[string]any{
+		"description": prompt.Description,
+		"messages": []map[string]any{
+			{
+				"role":    "user",
+				"content": contentItem{Type: "text", Text: text},
+			},
+		},
+	}), nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func getMCPPrompt(t *testing.T, name string, args map[string]string) (*rpcResponse, error) {
+	t.Helper()
+	params, _ := json.Marshal(map[string]any{"name": name, "arguments": args})
+	return handleMCPRequest(context.Background(), &mcpState{Driver: "api"}, rpcRequest{JSONRPC: "2.0", ID: json.RawMessage("3"), Method: "prompts/get", Params: params})
+}
+
+func promptText(t *testing.T, response *rpcResponse) string {
+	t.Helper()
+	messages := response.Result.(map[string]any)["messages"].([]map[string]any)
+	if len(messages) != 1 || messages[0]["role"] != "user" {
+		t.Fatalf("messages = %+v, want one user message", messages)
+	}
+	return messages[0]["content"].(contentItem).Text
+}
+
+func TestMCPPromptsList(t *testing.T) {
+	t.Parallel()
+
+	state := &mcpState{Driver: "api"}
+	response, err := initializeMCP(t, state, "")
+	if err != nil {
+		t.Fatalf("initialize returned error: %v", err)
+	}
+	capabilities := response.Result.(map[string]any)["capabilities"].(map[string]any)
+	if _, ok := capabilities["prompts"]; !ok {
+		t.Fatalf("capabilities = %+v, want prompts", capabilities)
+	}
+
+	response, err = handleMCPRequest(context.Background(), state, rpcRequest{JSONRPC: "2.0", ID: json.RawMessage("2"), Method: "prompts/list"})
+	if err != nil {
+		t.Fatalf("prompts/list returned error: %v", err)
+	}
+	encoded, _ := json.Marshal(response.Result)
+	var listed struct {
+		Prompts []struct {
+			Name      string `json:"name"`
+			Arguments []struct {
+				Name     string `json:"name"`
+				Required bool   `json:"required"`
+			} `json:"arguments"`
+		} `json:"prompts"`
+	}
+	if err := json.Unmarshal(encoded, &listed); err != nil {
+		t.Fatalf("decode prompts: %v", err)
+	}
+	names := map[string]bool{}
+	for _, prompt := range listed.Prompts {
+		names[prompt.Name] = true
+	}
+	for _, name := range []string{"analyze_metric", "compare_timeframes", "find_top_keys"} {
+		if !names[name] {
+			t.Fatalf("prompt %s not listed in %s", name, encoded)
+		}
+	}
+	if listed.Prompts[0].Arguments[0].Name != "key" || !listed.Prompts[0].Arguments[0].Required {
+		t.Fatalf("analyze_metric arguments = %+v, want required key first", listed.Prompts[0].Arguments)
+	}
+}
+
+func TestMCPPromptsGet(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		args map[string]string
+		want []string
+	}{
+		{"analyze_metric", map[string]string{"key": "event::signup"}, []string{`key "event::signup", last "7d", and granularity "1h"`, "aggregate_series", "format_timeline"}},
+		{"compare_timeframes", map[string]string{"key": "event::signup", "timeframe": "24h"}, []string{`value_path "count"`, `last "24h"`, `from "-48h" and to "-24h"`}},
+		{"find_top_keys", map[string]string{"limit": "5", "prefix": "event::"}, []string{"5 busiest", `sort "observations", desc true, and limit 5, with prefix "event::"`}},
+		{"find_top_keys", nil, []string{`last "7d", sort "observations", desc true, and limit 10.`}},
+	}
+	for _, tt := range tests {
+		response, err := getMCPPrompt(t, tt.name, tt.args)
+		if err != nil {
+			t.Fatalf("%s: prompts/get returned error: %v", tt.name, err)
+		}
+		text := promptText(t, response)
+		for _, want := range tt.want {
+			if !strings.Contains(text, want) {
+				t.Fatalf("%s: text missing %q:\n%s", tt.name, want, text)
+			}
+		}
+	}
+}
+
+func TestMCPPromptsGetErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		args map[string]string
+		want string
+	}{
+		{"missing_prompt", nil, "unknown prompt: missing_prompt"},
+		{"analyze_metric", nil, "argument key is required"},
+		{"compare_timeframes", map[string]string{"key": "a", "timeframe": "soon"}, "timeframe must be a positive <number><unit>, e.g. 7d"},
+		{"find_top_keys", map[string]string{"limit": "-1"}, "limit must be a positive integer"},
+	}
+	for _, tt := range tests {
+		_, err := getMCPPrompt(t, tt.name, tt.args)
+		rpcErr, ok := err.(*rpcError)
+		if !ok || rpcErr.Code != -32602 || rpcErr.Message != tt.want {
+			t.Fatalf("%s: err = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"time"
+)
+
+// mcpProtocolVersions lists the MCP protocol revisions the server speaks,
+// oldest first. Revisions are dates, so they order as strings.
+var mcpProtocolVersions = []string{"2024-11-05", "2025-03-26", "2025-06-18"}
+
+// mcpProtocolVersion is assumed for clients that do not send one.
+const mcpProtocolVersion = "2024-11-05"
+
+const (
+	// mcpAnnotationsVersion added tool annotations.
+	mcpAnnotationsVersion = "2025-03-26"
+	// mcpToolTitlesVersion added top-level tool titles.
+	mcpToolTitlesVersion = "2025-06-18"
+)
+
+// negotiateProtocolVersion picks the revision for a client asking for
+// requested: that revision when supported, and the newest supported one for
+// a client newer than the server. Clients that send none are assumed to
+// speak mcpProtocolVersion. Older or malformed revisions are rejected.
+func negotiateProtocolVersion(requested string) (string, error) {
+	if requested == "" {
+		return mcpProtocolVersion, nil
+	}
+	for _, supported := range mcpProtocolVersions {
+		if requested == supported {
+			return requested, nil
+		}
+	}
+	latest := mcpProtocolVersions[len(mcpProtocolVersions)-1]
+	if _, err := time.Parse("2006-01-02", requested); err == nil && requested > latest {
+		return latest, nil
+	}
+	return "", &rpcError{
+		Code:    -32602,
+		Message: "unsupported protocol version",
+		Data: map[string]any{
+			"requested": requested,
+			"supported": mcpProtocolVersions,
+		},
+	}
+}
+
+// protocolAtLeast reports whether the negotiated revision includes the
+// features of revision. An unset protocol counts as mcpProtocolVersion.
+func protocolAtLeast(protocol, revision string) bool {
+	if protocol == "" {
+		protocol = mcpProtocolVersion
+	}
+	return protocol >= revision
+}
+
+// toolAnnotations are the behaviour hints of a tool, sent from
+// mcpAnnotationsVersion on.
+type toolAnnotations struct {
+	Title           string `json:"title,omitempty"`
+	ReadOnlyHint    bool   `json:"readOnlyHint"`
+	DestructiveHint bool   `json:"destructiveHint"`
+	IdempotentHint  bool   `json:"idempotentHint"`
+	OpenWorldHint   bool   `json:"openWorldHint"`
+}
+
+var mcpToolAnnotations = map[string]toolAnnotations{
+	"list_metrics":       {Title: "List metrics", ReadOnlyHint: true, IdempotentHint: true},
+	"fetch_series":       {Title: "Fetch series", ReadOnlyHint: true, IdempotentHint: true},
+	"aggregate_series":   {Title: "Aggregate series", ReadOnlyHint: true, IdempotentHint: true},
+	"format_timeline":    {Title: "Format timeline", ReadOnlyHint: true, IdempotentHint: true},
+	"format_category":    {Title: "Format category", ReadOnlyHint: true, IdempotentHint: true},
+	"write_metric":       {Title: "Write metric"},
+	"setup_storage":      {Title: "Set up storage", IdempotentHint: true},
+	"list_transponders":  {Title: "List transponders", ReadOnlyHint: true, IdempotentHint: true},
+	"create_transponder": {Title: "Create transponder"},
+	"update_transponder": {Title: "Update transponder", DestructiveHint: true, IdempotentHint: true},
+	"delete_transponder": {Title: "Delete transponder", DestructiveHint: true, IdempotentHint: true},
+	"pause_transponder":  {Title: "Pause transponder", IdempotentHint: true},
+	"resume_transponder": {Title: "Resume transponder", IdempotentHint: true},
+	"test_transponder":   {Title: "Test-fire transponder", OpenWorldHint: true},
+}
+
+// toolsForProtocol adds the fields newer revisions define to tools:
+// annotations from mcpAnnotationsVersion and titles from
+// mcpToolTitlesVersion. Older clients get the tools unchanged.
+func toolsForProtocol(tools []toolDefinition, protocol string) []toolDefinition {
+	if !protocolAtLeast(protocol, mcpAnnotationsVersion) {
+		return tools
+	}
+	for i := range tools {
+		annotations, ok := mcpToolAnnotations[tools[i].Name]
+		if !ok {
+			continue
+		}
+		tools[i].Annotations = &annotations
+		if protocolAtLeast(protocol, mcpToolTitlesVersion) {
+			tools[i].Title = annotations.Title
+		}
+	}
+	return tools
+}
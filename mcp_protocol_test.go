@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func initializeMCP(t *testing.T, state *mcpState, version string) (*rpcResponse, error) {
+	t.Helper()
+	params, _ := json.Marshal(map[string]any{"protocolVersion": version})
+	return handleMCPRequest(context.Background(), state, rpcRequest{JSONRPC: "2.0", ID: json.RawMessage("1"), Method: "initialize", Params: params})
+}
+
+func listMCPTools(t *testing.T, state *mcpState) []toolDefinition {
+	t.Helper()
+	response, err := handleMCPRequest(context.Background(), state, rpcRequest{JSONRPC: "2.0", ID: json.RawMessage("2"), Method: "tools/list"})
+	if err != nil {
+		t.Fatalf("tools/list returned error: %v", err)
+	}
+	return response.Result.(map[string]any)["tools"].([]toolDefinition)
+}
+
+func findTool(t *testing.T, tools []toolDefinition, name string) toolDefinition {
+	t.Helper()
+	for _, tool := range tools {
+		if tool.Name == name {
+			return tool
+		}
+	}
+	t.Fatalf("tool %s not listed", name)
+	return toolDefinition{}
+}
+
+func TestMCPProtocolNegotiation(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		requested   string
+		want        string
+		annotations bool
+		titles      bool
+	}{
+		{"no version", "", "2024-11-05", false, false},
+		{"old client", "2024-11-05", "2024-11-05", false, false},
+		{"annotations client", "2025-03-26", "2025-03-26", true, false},
+		{"new client", "2025-06-18", "2025-06-18", true, true},
+		{"future client", "2099-01-01", "2025-06-18", true, true},
+	}
+	for _, tt := range tests {
+		state := &mcpState{Driver: "api"}
+		response, err := initializeMCP(t, state, tt.requested)
+		if err != nil {
+			t.Fatalf("%s: initialize returned error: %v", tt.name, err)
+		}
+		if got := response.Result.(map[string]any)["protocolVersion"]; got != tt.want {
+			t.Fatalf("%s: protocolVersion = %v, want %s", tt.name, got, tt.want)
+		}
+
+		tool := findTool(t, listMCPTools(t, state), "delete_transponder")
+		if (tool.Annotations != nil) != tt.annotations {
+			t.Fatalf("%s: annotations = %+v, want present %v", tt.name, tool.Annotations, tt.annotations)
+		}
+		if tt.annotations && (!tool.Annotations.DestructiveHint || tool.Annotations.ReadOnlyHint) {
+			t.Fatalf("%s: delete_transponder annotations = %+v, want destructive", tt.name, tool.Annotations)
+		}
+		if (tool.Title != "") != tt.titles {
+			t.Fatalf("%s: title = %q, want present %v", tt.name, tool.Title, tt.titles)
+		}
+		encoded, _ := json.Marshal(tool)
+		if !tt.annotations && containsJSONKey(encoded, "annotations") {
+			t.Fatalf("%s: old client got annotations: %s", tt.name, encoded)
+		}
+	}
+}
+
+func TestMCPProtocolRejectsUnsupported(t *testing.T) {
+	t.Parallel()
+
+	for _, version := range []string{"2024-10-07", "latest"} {
+		state := &mcpState{Driver: "api"}
+		_, err := initializeMCP(t, state, version)
+		rpcErr, ok := err.(*rpcError)
+		if !ok || rpcErr.Code != -32602 || rpcErr.Message != "unsupported protocol version" {
+			t.Fatalf("initialize(%s) err = %v, want an unsupported protocol version error", version, err)
+		}
+		if data := fmt.Sprint(rpcErr.Data); data != fmt.Sprint(map[string]any{"requested": version, "supported": mcpProtocolVersions}) {
+			t.Fatalf("initialize(%s) data = %s", version, data)
+		}
+		if state.Protocol != "" {
+			t.Fatalf("initialize(%s) set protocol %q", version, state.Protocol)
+		}
+	}
+}
+
+func TestMCPToolAnnotationsCoverTools(t *testing.T) {
+	t.Parallel()
+
+	for _, driver := range []string{"api", "sqlite"} {
+		for _, tool := range toolDefinitions(driver) {
+			if _, ok := mcpToolAnnotations[tool.Name]; !ok {
+				t.Fatalf("%s has no annotations", tool.Name)
+			}
+		}
+	}
+}
+
+func containsJSONKey(encoded []byte, key string) bool {
+	var fields map[string]any
+	if err := json.Unmarshal(encoded, &fields); err != nil {
+		return false
+	}
+	_, ok := fields[key]
+	return ok
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+)
+
+// mcpReadOnlyInstructions tells agents up front that a read-only server
+// will refuse writes.
+const mcpReadOnlyInstructions = "This server is read-only: tools that write metrics, create storage, or change or fire transponders are disabled. Use it to query and analyze metrics."
+
+// isReadOnlyTool reports whether a tool only reads, going by its
+// annotations. Tools without annotations count as mutating, so a new tool is
+// hidden in read-only mode until it is annotated.
+func isReadOnlyTool(name string) bool {
+	annotations, ok := mcpToolAnnotations[name]
+	return ok && annotations.ReadOnlyHint
+}
+
+// readOnlyTools drops the mutating tools from tools.
+func readOnlyTools(tools []toolDefinition) []toolDefinition {
+	kept := tools[:0]
+	for _, tool := range tools {
+		if isReadOnlyTool(tool.Name) {
+			kept = append(kept, tool)
+		}
+	}
+	return kept
+}
+
+// checkReadOnly rejects a call to a mutating tool on a read-only server.
+func checkReadOnly(state *mcpState, name string) error {
+	if state.ReadOnly && !isReadOnlyTool(name) {
+		return fmt.Errorf("%s is not available: the MCP server is running in read-only mode", name)
+	}
+	return nil
+}
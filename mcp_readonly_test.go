@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestMCPReadOnly(t *testing.T) {
+	t.Parallel()
+
+	state := newSQLiteMCPState(t)
+	state.ReadOnly = true
+	ctx := context.Background()
+
+	response, err := initializeMCP(t, state, "")
+	if err != nil {
+		t.Fatalf("initialize returned error: %v", err)
+	}
+	if instructions, _ := response.Result.(map[string]any)["instructions"].(string); instructions != mcpReadOnlyInstructions {
+		t.Fatalf("instructions = %q, want the read-only notice", instructions)
+	}
+
+	for _, driver := range []string{"api", "sqlite"} {
+		listed := (&mcpState{Driver: driver, ReadOnly: true}).toolList()
+		for _, tool := range listed {
+			if !mcpToolAnnotations[tool.Name].ReadOnlyHint {
+				t.Fatalf("%s: read-only server lists mutating tool %s", driver, tool.Name)
+			}
+		}
+		if len(listed) == 0 || len(listed) == len(toolDefinitions(driver)) {
+			t.Fatalf("%s: read-only server lists %d of %d tools", driver, len(listed), len(toolDefinitions(driver)))
+		}
+	}
+
+	params, _ := json.Marshal(map[string]any{"name": "write_metric", "arguments": map[string]any{"key": "event::logs", "values": map[string]any{"count": 1}}})
+	response, err = handleMCPRequest(ctx, state, rpcRequest{JSONRPC: "2.0", ID: json.RawMessage("2"), Method: "tools/call", Params: params})
+	if err != nil {
+		t.Fatalf("tools/call returned error: %v", err)
+	}
+	result := response.Result.(toolResult)
+	if !result.IsError || !strings.Contains(result.Content[0].Text, "write_metric is not available: the MCP server is running in read-only mode") {
+		t.Fatalf("write_metric result = %+v, want a read-only error", result)
+	}
+	for _, name := range []string{"setup_storage", "delete_transponder", "test_transponder"} {
+		if _, err := executeTool(ctx, state, name, map[string]any{}); err == nil || !strings.Contains(err.Error(), "read-only mode") {
+			t.Fatalf("%s err = %v, want a read-only error", name, err)
+		}
+	}
+	if _, err := executeTool(ctx, state, "list_metrics", map[string]any{}); err != nil {
+		t.Fatalf("list_metrics on a read-only server returned error: %v", err)
+	}
+
+	state.ReadOnly = false
+	response, _ = initializeMCP(t, state, "")
+	if _, ok := response.Result.(map[string]any)["instructions"]; ok {
+		t.Fatal("writable server sent read-only instructions")
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// parseSourceNames splits a --sources value into distinct source names.
+func parseSourceNames(value string) ([]string, error) {
+	var names []string
+	seen := map[string]bool{}
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if seen[name] {
+			return nil, usageErrorf("source %q is listed twice in --sources", name)
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return nil, usageErrorf("--sources requires at least one source name")
+	}
+	return names, nil
+}
+
+// loadMCPSources connects to each named source in cfg. The first one is the
+// session's default: the returned state runs against it, and Sources maps
+// every name, the first included, to its driver state.
+func loadMCPSources(cfg *cliConfig, names []string) (*mcpState, error) {
+	var session *mcpState
+	sources := make(map[string]*mcpState, len(names))
+	for _, name := range names {
+		endpoint, err := resolveMetricsEndpoint(cfg, name)
+		if err != nil {
+			return nil, err
+		}
+		if err := endpoint.connect(); err != nil {
+			return nil, err
+		}
+
+		source := &mcpState{
+			Name:   name,
+			Driver: endpoint.driverName(),
+			API:    endpoint.client,
+			Local:  endpoint.local,
+		}
+		if endpoint.local == nil {
+			source.WeekStart = endpoint.driverOpts.BeginningOfWeek
+		}
+		if session == nil {
+			session = source
+		}
+		sources[name] = source
+	}
+	session.Sources = sources
+	session.SourceNames = names
+	return session, nil
+}
+
+// source returns the driver state a call naming source should run against.
+// An empty name means the default source.
+func (s *mcpState) source(name string) (*mcpState, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || (len(s.Sources) == 0 && name == s.Name) {
+		return s, nil
+	}
+	if target, ok := s.Sources[name]; ok {
+		return target, nil
+	}
+	if len(s.Sources) == 0 {
+		return nil, fmt.Errorf("unknown source %q (start trifle mcp with --sources to use several)", name)
+	}
+	return nil, fmt.Errorf("unknown source %q (available: %s)", name, strings.Join(s.SourceNames, ", "))
+}
+
+// toolList returns the tools the session offers: those of every source,
+// less the mutating ones in read-only mode. With --sources each tool also
+// takes a source argument; tools only some drivers offer are listed once any
+// source supports them.
+func (s *mcpState) toolList() []toolDefinition {
+	tools := s.sourceTools()
+	if s.ReadOnly {
+		return readOnlyTools(tools)
+	}
+	return tools
+}
+
+func (s *mcpState) sourceTools() []toolDefinition {
+	if len(s.Sources) == 0 {
+		return toolDefinitions(s.Driver)
+	}
+
+	var tools []toolDefinition
+	listed := map[string]bool{}
+	for _, name := range s.SourceNames {
+		for _, tool := range toolDefinitions(s.Sources[name].Driver) {
+			if listed[tool.Name] {
+				continue
+			}
+			listed[tool.Name] = true
+			if properties, ok := tool.InputSchema["properties"].(map[string]any); ok {
+				properties["source"] = map[string]any{
+					"type":        "string",
+					"enum":        s.SourceNames,
+					"description": fmt.Sprintf("Source to run against (default %s).", s.SourceNames[0]),
+				}
+			}
+			tools = append(tools, tool)
+		}
+	}
+	return tools
+}
+
+// resourceList returns the session's resources. With --sources every
+// resource is listed once per source, as trifle://<source>/metrics and so on.
+func (s *mcpState) resourceList() []resourceDescriptor {
+	if len(s.Sources) == 0 {
+		return resourceList(s.Driver)
+	}
+
+	var resources []resourceDescriptor
+	for _, name := range s.SourceNames {
+		for _, resource := range resourceList(s.Sources[name].Driver) {
+			resource.URI = "trifle://" + name + "/" + strings.TrimPrefix(resource.URI, "trifle://")
+			resource.Name = fmt.Sprintf("%s (%s)", resource.Name, name)
+			resources = append(resources, resource)
+		}
+	}
+	return resources
+}
+
+// resourceTemplateList returns the session's resource templates, per source
+// like resourceList.
+func (s *mcpState) resourceTemplateList() []resourceTemplate {
+	if len(s.Sources) == 0 {
+		return resourceTemplates(s.Driver)
+	}
+
+	var templates []resourceTemplate
+	for _, name := range s.SourceNames {
+		for _, template := range resourceTemplates(s.Sources[name].Driver) {
+			template.URITemplate = "trifle://" + name + "/" + strings.TrimPrefix(template.URITemplate, "trifle://")
+			template.Name = fmt.Sprintf("%s (%s)", template.Name, name)
+			templates = append(templates, template)
+		}
+	}
+	return templates
+}
+
+// resourceSource splits a per-source URI like trifle://prod/metrics/key into
+// the source's state and the URI it would have on its own,
+// trifle://metrics/key. Other URIs are left to the default source.
+func (s *mcpState) resourceSource(parsed *url.URL) (*mcpState, *url.URL) {
+	target, ok := s.Sources[parsed.Host]
+	if !ok {
+		return s, parsed
+	}
+	kind, rest, _ := strings.Cut(strings.TrimPrefix(parsed.Path, "/"), "/")
+	local := *parsed
+	local.Host = kind
+	local.Path = ""
+	local.RawPath = ""
+	if rest != "" {
+		local.Path = "/" + rest
+	}
+	return target, &local
+}
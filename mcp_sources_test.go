@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestMCPMultipleSources(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":[{"id":"t1","name":"errors"}]}`))
+	}))
+	defer server.Close()
+
+	cfg := &cliConfig{Sources: map[string]sourceConfig{
+		"local": {Driver: "sqlite", DB: filepath.Join(t.TempDir(), "stats.db"), Table: "metrics", TimeZone: "UTC", BufferMode: "off"},
+		"prod":  {Driver: "api", URL: server.URL, Token: "token"},
+	}}
+	state, err := loadMCPSources(cfg, []string{"local", "prod"})
+	if err != nil {
+		t.Fatalf("loadMCPSources returned error: %v", err)
+	}
+	if state.Name != "local" || state.Local == nil || state.Sources["prod"].API == nil || state.Sources["local"] != state {
+		t.Fatalf("state = %+v, want local as the default with prod on the api", state)
+	}
+
+	for _, name := range []string{"fetch_series", "setup_storage", "list_transponders"} {
+		properties := findTool(t, state.toolList(), name).InputSchema["properties"].(map[string]any)
+		source, ok := properties["source"].(map[string]any)
+		if !ok || strings.Join(source["enum"].([]string), ",") != "local,prod" {
+			t.Fatalf("%s source property = %v, want enum local,prod", name, properties["source"])
+		}
+	}
+
+	ctx := context.Background()
+	if _, err := executeTool(ctx, state, "setup_storage", map[string]any{}); err != nil {
+		t.Fatalf("setup_storage on the default source returned error: %v", err)
+	}
+	if _, err := executeTool(ctx, state, "fetch_series", map[string]any{"source": "local", "key": "event::logs", "last": "1d", "granularity": "1h"}); err != nil {
+		t.Fatalf("fetch_series on local returned error: %v", err)
+	}
+	if _, err := executeTool(ctx, state, "list_transponders", map[string]any{}); err == nil {
+		t.Fatal("expected list_transponders to fail on the local default source")
+	}
+	result, err := executeTool(ctx, state, "list_transponders", map[string]any{"source": "prod"})
+	if err != nil {
+		t.Fatalf("list_transponders on prod returned error: %v", err)
+	}
+	if !strings.Contains(result.Content[0].Text, "errors") {
+		t.Fatalf("list_transponders on prod = %s", result.Content[0].Text)
+	}
+	if _, err := executeTool(ctx, state, "list_metrics", map[string]any{"source": "staging"}); err == nil || err.Error() != `unknown source "staging" (available: local, prod)` {
+		t.Fatalf("unknown source err = %v", err)
+	}
+
+	uris := map[string]bool{}
+	for _, resource := range state.resourceList() {
+		uris[resource.URI] = true
+	}
+	for _, uri := range []string{"trifle://local/metrics", "trifle://prod/source", "trifle://prod/transponders"} {
+		if !uris[uri] {
+			t.Fatalf("resources %v missing %s", uris, uri)
+		}
+	}
+	var templates []string
+	for _, template := range state.resourceTemplateList() {
+		templates = append(templates, template.URITemplate)
+	}
+	if strings.Join(templates, " ") != "trifle://local/metrics/{key}{?from,to,last,granularity} trifle://prod/metrics/{key}{?from,to,last,granularity} trifle://prod/transponders/{id}/logs{?limit,cursor}" {
+		t.Fatalf("resource templates = %v", templates)
+	}
+	if uris["trifle://local/transponders"] || uris["trifle://metrics"] {
+		t.Fatalf("resources %v list transponders for sqlite or unprefixed URIs", uris)
+	}
+
+	if _, err := readResource(ctx, state, "trifle://prod/transponders"); err != nil {
+		t.Fatalf("read trifle://prod/transponders returned error: %v", err)
+	}
+	if _, err := readResource(ctx, state, "trifle://local/metrics/event::logs?last=1d&granularity=1h"); err != nil {
+		t.Fatalf("read trifle://local/metrics/event::logs returned error: %v", err)
+	}
+	if _, err := readResource(ctx, state, "trifle://local/transponders"); err == nil {
+		t.Fatal("expected trifle://local/transponders to fail for sqlite")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(paths) != 2 || !strings.HasSuffix(paths[0], "/transponders") || !strings.HasSuffix(paths[1], "/transponders") {
+		t.Fatalf("api paths = %v, want two transponders requests", paths)
+	}
+}
+
+func TestMCPSourceWithoutSources(t *testing.T) {
+	t.Parallel()
+
+	state := &mcpState{Driver: "api"}
+	if target, err := state.source(""); err != nil || target != state {
+		t.Fatalf("source(\"\") = %v, %v, want the state itself", target, err)
+	}
+	if _, err := state.source("prod"); err == nil || !strings.Contains(err.Error(), "--sources") {
+		t.Fatalf("source(prod) err = %v, want a --sources hint", err)
+	}
+	if _, err := parseSourceNames("prod, ,prod"); err == nil {
+		t.Fatal("expected a duplicate source name to be rejected")
+	}
+	if names, err := parseSourceNames(" prod,local "); err != nil || strings.Join(names, ",") != "prod,local" {
+		t.Fatalf("parseSourceNames = %v, %v", names, err)
+	}
+}
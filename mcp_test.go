@@ -0,0 +1,865 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/trifle-io/trifle-cli/internal/api"
+)
+
+func newSQLiteMCPState(t *testing.T) *mcpState {
+	t.Helper()
+
+	local, err := loadLocalConfig(&driverOptions{
+		Driver:          "sqlite",
+		DBPath:          filepath.Join(t.TempDir(), "stats.db"),
+		Table:           "metrics",
+		Joined:          "full",
+		Separator:       "::",
+		TimeZone:        "UTC",
+		BeginningOfWeek: "monday",
+		BufferMode:      "off",
+	})
+	if err != nil {
+		t.Fatalf("loadLocalConfig returned error: %v", err)
+	}
+	if err := local.Setup(); err != nil {
+		t.Fatalf("local.Setup returned error: %v", err)
+	}
+
+	return &mcpState{Driver: local.DriverName, Local: local}
+}
+
+func decodeToolPayload(t *testing.T, result toolResult) map[string]any {
+	t.Helper()
+
+	if result.IsError || len(result.Content) == 0 {
+		t.Fatalf("unexpected tool result: %#v", result)
+	}
+	var payload map[string]any
+	if err := json.Unmarshal([]byte(result.Content[0].Text), &payload); err != nil {
+		t.Fatalf("decode tool payload: %v", err)
+	}
+	return payload
+}
+
+func TestMCPToolsAcceptLast(t *testing.T) {
+	state := newSQLiteMCPState(t)
+	ctx := context.Background()
+
+	_, err := executeTool(ctx, state, "write_metric", map[string]any{
+		"key":    "event::logs",
+		"at":     time.Now().UTC().Add(-time.Hour).Format(time.RFC3339),
+		"values": map[string]any{"count": 2},
+	})
+	if err != nil {
+		t.Fatalf("write_metric returned error: %v", err)
+	}
+
+	tools := []string{"list_metrics", "fetch_series", "aggregate_series", "format_timeline", "format_category"}
+	for _, name := range tools {
+		args := map[string]any{
+			"key":         "event::logs",
+			"value_path":  "count",
+			"aggregator":  "sum",
+			"granularity": "1h",
+			"last":        "6h",
+		}
+		result, err := executeTool(ctx, state, name, args)
+		if err != nil {
+			t.Fatalf("%s returned error: %v", name, err)
+		}
+		payload := decodeToolPayload(t, result)
+
+		timeframe, ok := payload["timeframe"].(map[string]any)
+		if !ok {
+			t.Fatalf("%s: missing timeframe: %#v", name, payload)
+		}
+		from, _ := time.Parse(time.RFC3339, timeframe["from"].(string))
+		to, _ := time.Parse(time.RFC3339, timeframe["to"].(string))
+		if to.Sub(from) != 6*time.Hour {
+			t.Fatalf("%s: window = %s, want 6h", name, to.Sub(from))
+		}
+
+		args["from"] = "-1d"
+		if _, err := executeTool(ctx, state, name, args); err == nil || !strings.Contains(err.Error(), "cannot be combined") {
+			t.Fatalf("%s: expected last/from conflict, got %v", name, err)
+		}
+	}
+}
+
+func TestMCPToolsValidateTimeframe(t *testing.T) {
+	state := newSQLiteMCPState(t)
+	ctx := context.Background()
+
+	args := map[string]any{"granularity": "1h", "from": "now", "to": "-7d"}
+	if _, err := executeTool(ctx, state, "fetch_series", args); err == nil || !strings.Contains(err.Error(), "must be before to") {
+		t.Fatalf("expected ordering error, got %v", err)
+	}
+
+	payload := withTimeframeWarning(map[string]any{"status": "ok"}, "2026-01-01T00:00:00Z", "2026-01-31T00:00:00Z", "1m")
+	if warning, _ := payload["warning"].(string); !strings.Contains(warning, "coarser granularity") {
+		t.Fatalf("expected point-count warning, got %#v", payload["warning"])
+	}
+	payload = withTimeframeWarning(map[string]any{"status": "ok"}, "2026-01-01T00:00:00Z", "2026-01-31T00:00:00Z", "1h")
+	if _, ok := payload["warning"]; ok {
+		t.Fatalf("unexpected warning: %#v", payload["warning"])
+	}
+}
+
+func TestMCPToolsAcceptOpenEndedRange(t *testing.T) {
+	state := newSQLiteMCPState(t)
+	ctx := context.Background()
+
+	result, err := executeTool(ctx, state, "fetch_series", map[string]any{"granularity": "1h", "from": "-6h"})
+	if err != nil {
+		t.Fatalf("fetch_series with only from returned error: %v", err)
+	}
+	timeframe := decodeToolPayload(t, result)["timeframe"].(map[string]any)
+	from, _ := time.Parse(time.RFC3339, timeframe["from"].(string))
+	to, _ := time.Parse(time.RFC3339, timeframe["to"].(string))
+	if to.Sub(from) != 6*time.Hour {
+		t.Fatalf("only from: window = %s, want 6h", to.Sub(from))
+	}
+
+	result, err = executeTool(ctx, state, "fetch_series", map[string]any{"granularity": "1h", "to": "2026-01-05T00:00:00Z"})
+	if err != nil {
+		t.Fatalf("fetch_series with only to returned error: %v", err)
+	}
+	timeframe = decodeToolPayload(t, result)["timeframe"].(map[string]any)
+	if timeframe["from"] != "2026-01-04T00:00:00Z" || timeframe["to"] != "2026-01-05T00:00:00Z" {
+		t.Fatalf("only to: timeframe = %v", timeframe)
+	}
+}
+
+func TestWriteMetricKeepsSubSecondPrecision(t *testing.T) {
+	local, err := loadLocalConfig(&driverOptions{
+		Driver:          "sqlite",
+		DBPath:          ":memory:",
+		Table:           "metrics",
+		Joined:          "full",
+		Separator:       "::",
+		TimeZone:        "UTC",
+		BeginningOfWeek: "monday",
+		BufferMode:      "off",
+	})
+	if err != nil {
+		t.Fatalf("loadLocalConfig returned error: %v", err)
+	}
+	if err := local.Setup(); err != nil {
+		t.Fatalf("local.Setup returned error: %v", err)
+	}
+	state := &mcpState{Driver: local.DriverName, Local: local}
+	ctx := context.Background()
+
+	at := "2026-01-01T12:00:00.123456789Z"
+	result, err := executeTool(ctx, state, "write_metric", map[string]any{
+		"key":    "event::logs",
+		"at":     at,
+		"values": map[string]any{"count": 1},
+	})
+	if err != nil {
+		t.Fatalf("write_metric returned error: %v", err)
+	}
+	data := decodeToolPayload(t, result)["data"].(map[string]any)
+	if data["at"] != at {
+		t.Fatalf("echoed at = %v, want %s", data["at"], at)
+	}
+
+	result, err = executeTool(ctx, state, "fetch_series", map[string]any{
+		"key":         "event::logs",
+		"granularity": "1h",
+		"from":        "2026-01-01T12:00:00Z",
+		"to":          "2026-01-01T12:59:59Z",
+	})
+	if err != nil {
+		t.Fatalf("fetch_series returned error: %v", err)
+	}
+	series := decodeToolPayload(t, result)["data"].(map[string]any)
+	values := series["values"].([]any)
+	if len(values) != 1 || values[0].(map[string]any)["count"] != float64(1) {
+		t.Fatalf("values = %v, want one 12:00 bucket with count 1", values)
+	}
+
+	if got, err := resolvePushAt(" 2026-01-01T12:00:00.5+01:00 "); err != nil || got != "2026-01-01T12:00:00.5+01:00" {
+		t.Fatalf("resolvePushAt = %q (err %v), want the original precision", got, err)
+	}
+	got, err := resolvePushAt("")
+	if err != nil {
+		t.Fatalf("resolvePushAt default returned error: %v", err)
+	}
+	if parsed, err := time.Parse(time.RFC3339Nano, got); err != nil || time.Since(parsed) > time.Minute {
+		t.Fatalf("resolvePushAt default = %q (err %v), want now", got, err)
+	}
+}
+
+func TestWriteMetricModeAndSet(t *testing.T) {
+	state := newSQLiteMCPState(t)
+	ctx := context.Background()
+
+	at := "2026-01-01T12:00:00Z"
+	writes := []map[string]any{
+		{"key": "event::gauge", "at": at, "set": map[string]any{"level": 5, "status.ok": "1"}},
+		{"key": "event::gauge", "at": at, "values": map[string]any{"level": 7}, "mode": "assert"},
+	}
+	for i, args := range writes {
+		result, err := executeTool(ctx, state, "write_metric", args)
+		if err != nil {
+			t.Fatalf("write_metric(%v) returned error: %v", args, err)
+		}
+		want := []string{"track", "assert"}[i]
+		if mode := decodeToolPayload(t, result)["data"].(map[string]any)["mode"]; mode != want {
+			t.Fatalf("write_metric(%v) mode = %v, want %s", args, mode, want)
+		}
+	}
+
+	// An invalid mode is a failed tool call, not a JSON-RPC error.
+	params, _ := json.Marshal(map[string]any{"name": "write_metric", "arguments": map[string]any{"key": "event::gauge", "at": at, "values": map[string]any{"level": 1}, "mode": "overwrite"}})
+	response, err := handleMCPRequest(ctx, state, rpcRequest{JSONRPC: "2.0", ID: json.RawMessage("1"), Method: "tools/call", Params: params})
+	if err != nil || response.Error != nil {
+		t.Fatalf("tools/call with an invalid mode = %v, %v; want a tool result", response, err)
+	}
+	if result := response.Result.(toolResult); !result.IsError || !strings.Contains(result.Content[0].Text, "invalid mode: overwrite") {
+		t.Fatalf("tools/call result = %+v, want an invalid mode tool error", result)
+	}
+
+	result, err := executeTool(ctx, state, "fetch_series", map[string]any{
+		"key":         "event::gauge",
+		"granularity": "1h",
+		"from":        "2026-01-01T12:00:00Z",
+		"to":          "2026-01-01T12:59:59Z",
+	})
+	if err != nil {
+		t.Fatalf("fetch_series returned error: %v", err)
+	}
+	values := decodeToolPayload(t, result)["data"].(map[string]any)["values"].([]any)
+	bucket := values[0].(map[string]any)
+	if bucket["level"] != float64(7) || bucket["status"].(map[string]any)["ok"] != float64(1) {
+		t.Fatalf("bucket = %v, want level asserted to 7 and status.ok tracked as 1", bucket)
+	}
+}
+
+func TestWriteMetricModeAPI(t *testing.T) {
+	t.Parallel()
+
+	var bodies []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		bodies = append(bodies, body)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"data":{"created":true}}`))
+	}))
+	defer server.Close()
+
+	client, err := api.New(server.URL, "token", 5*time.Second)
+	if err != nil {
+		t.Fatalf("api.New returned error: %v", err)
+	}
+	state := &mcpState{Driver: "api", API: client}
+	ctx := context.Background()
+
+	result, err := executeTool(ctx, state, "write_metric", map[string]any{"key": "event::gauge", "values": map[string]any{"level": 1}, "mode": "track"})
+	if err != nil {
+		t.Fatalf("api write_metric returned error: %v", err)
+	}
+	if data := decodeToolPayload(t, result)["data"].(map[string]any); !reflect.DeepEqual(data, map[string]any{"created": true}) {
+		t.Fatalf("api write_metric data = %v, want the server response without a mode it did not report", data)
+	}
+
+	// The endpoint only tracks, so assert fails before anything is posted.
+	_, err = writeMetricPayload(ctx, state, map[string]any{"key": "event::gauge", "values": map[string]any{"level": 1}, "mode": "assert"})
+	if err == nil || !strings.Contains(err.Error(), "assert mode is not supported") {
+		t.Fatalf("api assert error = %v, want an explicit rejection", err)
+	}
+	if len(bodies) != 1 {
+		t.Fatalf("posted %d writes, want only the track", len(bodies))
+	}
+	if _, ok := bodies[0]["mode"]; ok {
+		t.Fatalf("posted body = %v, want no mode", bodies[0])
+	}
+}
+
+func TestMCPListMetricsSortAndLimit(t *testing.T) {
+	state := newSQLiteMCPState(t)
+	ctx := context.Background()
+
+	// Keys without the "::" separator, which would nest them under
+	// __system__key__. Each observation goes to its own hour so the counts
+	// come from separate buckets.
+	now := time.Now().UTC()
+	for key, count := range map[string]int{"logs": 3, "signups": 1, "failures": 2} {
+		for i := 1; i <= count; i++ {
+			at := now.Add(-time.Duration(i) * time.Hour).Format(time.RFC3339)
+			if _, err := executeTool(ctx, state, "write_metric", map[string]any{"key": key, "at": at, "values": map[string]any{"count": 1}}); err != nil {
+				t.Fatalf("write_metric returned error: %v", err)
+			}
+		}
+	}
+
+	tests := []struct {
+		sort string
+		want []string
+	}{
+		{"observations", []string{"logs", "failures"}},
+		{"name", []string{"signups", "logs"}},
+	}
+	for _, tt := range tests {
+		result, err := executeTool(ctx, state, "list_metrics", map[string]any{
+			"last":        "6h",
+			"granularity": "1h",
+			"sort":        tt.sort,
+			"desc":        true,
+			"limit":       2,
+		})
+		if err != nil {
+			t.Fatalf("list_metrics sort %s returned error: %v", tt.sort, err)
+		}
+		payload := decodeToolPayload(t, result)
+
+		if payload["total_paths"] != float64(3) || payload["returned_paths"] != float64(2) {
+			t.Fatalf("sort %s: total_paths = %v, returned_paths = %v, want 3 and 2", tt.sort, payload["total_paths"], payload["returned_paths"])
+		}
+		paths, _ := payload["paths"].([]any)
+		if len(paths) != 2 || paths[0].(map[string]any)["metric_key"] != tt.want[0] || paths[1].(map[string]any)["metric_key"] != tt.want[1] {
+			t.Fatalf("sort %s: paths = %v, want %v", tt.sort, paths, tt.want)
+		}
+	}
+
+	if _, err := executeTool(ctx, state, "list_metrics", map[string]any{"last": "6h", "sort": "size"}); err == nil {
+		t.Fatalf("list_metrics accepted an unknown sort")
+	}
+}
+
+func TestMCPListMetricsFilter(t *testing.T) {
+	state := newSQLiteMCPState(t)
+	ctx := context.Background()
+
+	at := time.Now().UTC().Add(-time.Hour).Format(time.RFC3339)
+	for _, key := range []string{"event::logs::api", "event::logs::web", "event::signups", "billing::charges"} {
+		if _, err := executeTool(ctx, state, "write_metric", map[string]any{"key": key, "at": at, "values": map[string]any{"count": 1}}); err != nil {
+			t.Fatalf("write_metric returned error: %v", err)
+		}
+	}
+
+	result, err := executeTool(ctx, state, "list_metrics", map[string]any{
+		"last":   "6h",
+		"prefix": "event::",
+		"match":  "::(api|signups)$",
+	})
+	if err != nil {
+		t.Fatalf("list_metrics returned error: %v", err)
+	}
+	payload := decodeToolPayload(t, result)
+
+	if payload["total_paths"] != float64(4) || payload["filtered_paths"] != float64(2) {
+		t.Fatalf("total_paths = %v, filtered_paths = %v, want 4 and 2", payload["total_paths"], payload["filtered_paths"])
+	}
+	filter, _ := payload["filter"].(map[string]any)
+	if filter["prefix"] != "event::" || filter["match"] != "::(api|signups)$" {
+		t.Fatalf("filter = %v", filter)
+	}
+
+	if _, err := executeTool(ctx, state, "list_metrics", map[string]any{"last": "6h", "match": "(["}); err == nil {
+		t.Fatalf("list_metrics accepted an invalid match pattern")
+	}
+}
+
+func TestMCPToolsCompact(t *testing.T) {
+	state := newSQLiteMCPState(t)
+	ctx := context.Background()
+
+	args := map[string]any{"last": "6h", "granularity": "1h"}
+	pretty, err := executeTool(ctx, state, "list_metrics", args)
+	if err != nil {
+		t.Fatalf("list_metrics returned error: %v", err)
+	}
+	if !strings.Contains(pretty.Content[0].Text, "\n") {
+		t.Fatalf("default result should be indented: %s", pretty.Content[0].Text)
+	}
+
+	args["compact"] = true
+	compact, err := executeTool(ctx, state, "list_metrics", args)
+	if err != nil {
+		t.Fatalf("list_metrics returned error: %v", err)
+	}
+	if strings.Contains(compact.Content[0].Text, "\n") {
+		t.Fatalf("compact result should be a single line: %s", compact.Content[0].Text)
+	}
+	decodeToolPayload(t, compact)
+
+	for _, tool := range toolDefinitions("api") {
+		properties := tool.InputSchema["properties"].(map[string]any)
+		if _, ok := properties["compact"]; !ok {
+			t.Fatalf("%s schema is missing compact", tool.Name)
+		}
+	}
+}
+
+func TestMCPTableLimit(t *testing.T) {
+	state := newSQLiteMCPState(t)
+	ctx := context.Background()
+
+	_, err := executeTool(ctx, state, "write_metric", map[string]any{
+		"key":    "event::logs",
+		"at":     time.Now().UTC().Add(-time.Hour).Format(time.RFC3339),
+		"values": map[string]any{"count": 2},
+	})
+	if err != nil {
+		t.Fatalf("write_metric returned error: %v", err)
+	}
+
+	args := map[string]any{
+		"key":         "event::logs",
+		"value_path":  "count",
+		"granularity": "1h",
+		"last":        "6h",
+		"table_tail":  2,
+	}
+	result, err := executeTool(ctx, state, "format_timeline", args)
+	if err != nil {
+		t.Fatalf("format_timeline returned error: %v", err)
+	}
+	table := decodeToolPayload(t, result)["table"].(map[string]any)
+	rows := table["rows"].([]any)
+	total, _ := table["total_rows"].(float64)
+	if len(rows) != 2 || int(total) <= 2 {
+		t.Fatalf("table_tail kept %d of %v rows, want 2 of more", len(rows), table["total_rows"])
+	}
+}
+
+func TestMCPFormatTimelineFill(t *testing.T) {
+	state := newSQLiteMCPState(t)
+	ctx := context.Background()
+
+	now := time.Now().UTC().Truncate(time.Hour)
+	for _, write := range []struct {
+		at    time.Time
+		count int
+	}{{now.Add(-3 * time.Hour), 2}, {now.Add(-time.Hour), 6}} {
+		_, err := executeTool(ctx, state, "write_metric", map[string]any{
+			"key":    "event::logs",
+			"at":     write.at.Format(time.RFC3339),
+			"values": map[string]any{"count": write.count},
+		})
+		if err != nil {
+			t.Fatalf("write_metric returned error: %v", err)
+		}
+	}
+
+	result, err := executeTool(ctx, state, "format_timeline", map[string]any{
+		"key":         "event::logs",
+		"value_path":  "count",
+		"granularity": "1h",
+		"from":        now.Add(-3 * time.Hour).Format(time.RFC3339),
+		"to":          now.Add(-time.Hour).Format(time.RFC3339),
+		"fill":        "linear",
+	})
+	if err != nil {
+		t.Fatalf("format_timeline returned error: %v", err)
+	}
+	rows := decodeToolPayload(t, result)["table"].(map[string]any)["rows"].([]any)
+	if len(rows) != 3 || rows[1].([]any)[1] != 4.0 {
+		t.Fatalf("rows = %v, want the middle bucket interpolated to 4", rows)
+	}
+
+	if _, err := executeTool(ctx, state, "fetch_series", map[string]any{"key": "event::logs", "fill": "spline"}); err == nil {
+		t.Fatal("expected an unsupported fill error")
+	}
+
+	result, err = executeTool(ctx, state, "format_timeline", map[string]any{
+		"key":         "event::logs",
+		"value_path":  "count",
+		"granularity": "1h",
+		"from":        now.Add(-3 * time.Hour).Format(time.RFC3339),
+		"to":          now.Add(-time.Hour).Format(time.RFC3339),
+		"transform":   "cumsum",
+	})
+	if err != nil {
+		t.Fatalf("format_timeline cumsum returned error: %v", err)
+	}
+	rows = decodeToolPayload(t, result)["table"].(map[string]any)["rows"].([]any)
+	if len(rows) != 3 || rows[0].([]any)[1] != 2.0 || rows[1].([]any)[1] != 2.0 || rows[2].([]any)[1] != 8.0 {
+		t.Fatalf("rows = %v, want running totals 2, 2, 8", rows)
+	}
+}
+
+func TestMCPSkipBlanks(t *testing.T) {
+	state := newSQLiteMCPState(t)
+	ctx := context.Background()
+
+	now := time.Now().UTC().Truncate(time.Hour)
+	for _, at := range []time.Time{now.Add(-3 * time.Hour), now.Add(-time.Hour)} {
+		if _, err := executeTool(ctx, state, "write_metric", map[string]any{
+			"key":    "event::logs",
+			"at":     at.Format(time.RFC3339),
+			"values": map[string]any{"count": 1},
+		}); err != nil {
+			t.Fatalf("write_metric returned error: %v", err)
+		}
+	}
+	window := map[string]any{
+		"key":         "event::logs",
+		"value_path":  "count",
+		"granularity": "1h",
+		"from":        now.Add(-3 * time.Hour).Format(time.RFC3339),
+		"to":          now.Add(-time.Hour).Format(time.RFC3339),
+		"skip_blanks": true,
+	}
+
+	result, err := executeTool(ctx, state, "fetch_series", window)
+	if err != nil {
+		t.Fatalf("fetch_series returned error: %v", err)
+	}
+	if at := decodeToolPayload(t, result)["data"].(map[string]any)["at"].([]any); len(at) != 2 {
+		t.Fatalf("fetch_series at = %v, want the empty middle bucket skipped", at)
+	}
+	result, err = executeTool(ctx, state, "format_timeline", window)
+	if err != nil {
+		t.Fatalf("format_timeline returned error: %v", err)
+	}
+	if rows := decodeToolPayload(t, result)["table"].(map[string]any)["rows"].([]any); len(rows) != 2 {
+		t.Fatalf("format_timeline rows = %v, want the empty middle bucket skipped", rows)
+	}
+
+	var getQuery, queryBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodGet {
+			getQuery = r.URL.Query().Get("skip_blanks")
+			_, _ = w.Write([]byte(`{"data":{"at":[],"values":[]}}`))
+			return
+		}
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		queryBody = fmt.Sprint(body["skip_blanks"])
+		_, _ = w.Write([]byte(`{"data":{"table":{"columns":["at","count"],"rows":[]}}}`))
+	}))
+	defer server.Close()
+
+	client, err := api.New(server.URL, "token", 5*time.Second)
+	if err != nil {
+		t.Fatalf("api.New returned error: %v", err)
+	}
+	apiState := &mcpState{Driver: "api", API: client}
+	if _, err := executeTool(ctx, apiState, "fetch_series", window); err != nil {
+		t.Fatalf("api fetch_series returned error: %v", err)
+	}
+	if _, err := executeTool(ctx, apiState, "format_timeline", window); err != nil {
+		t.Fatalf("api format_timeline returned error: %v", err)
+	}
+	if getQuery != "true" || queryBody != "true" {
+		t.Fatalf("skip_blanks sent as query %q and body %q, want true for both", getQuery, queryBody)
+	}
+}
+
+func TestMCPFormatCategoryTop(t *testing.T) {
+	state := newSQLiteMCPState(t)
+	ctx := context.Background()
+
+	at := time.Now().UTC().Add(-time.Hour).Format(time.RFC3339)
+	if _, err := executeTool(ctx, state, "write_metric", map[string]any{
+		"key":    "api::requests",
+		"at":     at,
+		"values": map[string]any{"endpoints": map[string]any{"users": 5, "orders": 3, "health": 1, "login": 1}},
+	}); err != nil {
+		t.Fatalf("write_metric returned error: %v", err)
+	}
+
+	result, err := executeTool(ctx, state, "format_category", map[string]any{
+		"key":         "api::requests",
+		"value_path":  "endpoints",
+		"last":        "6h",
+		"granularity": "1h",
+		"top":         2,
+	})
+	if err != nil {
+		t.Fatalf("format_category returned error: %v", err)
+	}
+	payload := decodeToolPayload(t, result)
+	rows := payload["table"].(map[string]any)["rows"].([]any)
+	var got []string
+	for _, row := range rows {
+		cells := row.([]any)
+		got = append(got, fmt.Sprintf("%v=%v", cells[0], cells[1]))
+	}
+	if strings.Join(got, ",") != "endpoints.users=5,endpoints.orders=3,other=2" {
+		t.Fatalf("rows = %v, want the two largest endpoints and other", got)
+	}
+	if payload["other_categories"] != 2.0 {
+		t.Fatalf("other_categories = %v, want 2", payload["other_categories"])
+	}
+
+	if _, err := executeTool(ctx, state, "format_category", map[string]any{"key": "api::requests", "value_path": "endpoints", "top": -1}); err == nil {
+		t.Fatal("expected a negative top error")
+	}
+}
+
+func TestMCPAggregateSliceRanges(t *testing.T) {
+	state := newSQLiteMCPState(t)
+	ctx := context.Background()
+
+	buckets := hourlyBuckets(4)
+	for i, at := range buckets {
+		_, err := executeTool(ctx, state, "write_metric", map[string]any{
+			"key":    "event::logs",
+			"at":     at.Format(time.RFC3339),
+			"values": map[string]any{"count": i + 1},
+		})
+		if err != nil {
+			t.Fatalf("write_metric returned error: %v", err)
+		}
+	}
+
+	result, err := executeTool(ctx, state, "aggregate_series", map[string]any{
+		"key":         "event::logs",
+		"value_path":  "count",
+		"aggregator":  "sum",
+		"granularity": "1h",
+		"from":        buckets[0].Format(time.RFC3339),
+		"to":          buckets[3].Add(30 * time.Minute).Format(time.RFC3339),
+		"slices":      2,
+	})
+	if err != nil {
+		t.Fatalf("aggregate_series returned error: %v", err)
+	}
+	payload := decodeToolPayload(t, result)
+	ranges, ok := payload["slice_ranges"].([]any)
+	if !ok || len(ranges) != 2 {
+		t.Fatalf("slice_ranges = %#v", payload["slice_ranges"])
+	}
+	second := ranges[1].(map[string]any)
+	if second["from"] != "2026-10-15T11:00:00Z" || second["to"] != "2026-10-15T12:30:00Z" {
+		t.Fatalf("second slice = %v, want 11:00..12:30", second)
+	}
+	rows := payload["table"].(map[string]any)["rows"].([]any)
+	if first := rows[0].([]any); first[1] != "2026-10-15T09:00:00Z" || first[3] != 3.0 {
+		t.Fatalf("first row = %v, want 09:00 with sum 3", first)
+	}
+}
+
+func TestMCPSlicesBound(t *testing.T) {
+	state := newSQLiteMCPState(t)
+	ctx := context.Background()
+
+	_, err := executeTool(ctx, state, "format_timeline", map[string]any{
+		"key":         "api::requests",
+		"value_path":  "count",
+		"last":        "6h",
+		"granularity": "1h",
+		"slices":      100000,
+	})
+	if err == nil || !strings.Contains(err.Error(), "slices 100000 exceeds the") {
+		t.Fatalf("err = %v, want a slices bound error", err)
+	}
+
+	if _, err := executeTool(ctx, state, "format_timeline", map[string]any{"key": "api::requests", "value_path": "count", "slices": 0}); err == nil {
+		t.Fatal("expected a slices minimum error")
+	}
+}
+
+func TestMCPCreateUpdateTransponder(t *testing.T) {
+	t.Parallel()
+
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		encoded, _ := json.Marshal(body)
+		requests = append(requests, r.Method+" "+r.URL.Path+" "+string(encoded))
+		json.NewEncoder(w).Encode(map[string]any{"data": body})
+	}))
+	defer server.Close()
+
+	client, err := api.New(server.URL, "token", 5*time.Second)
+	if err != nil {
+		t.Fatalf("api.New returned error: %v", err)
+	}
+	state := &mcpState{Driver: "api", API: client}
+	ctx := context.Background()
+
+	result, err := executeTool(ctx, state, "create_transponder", map[string]any{
+		"name":    "High error rate",
+		"kind":    "threshold",
+		"payload": map[string]any{"name": "ignored", "key": "event::logs", "config": map[string]any{"threshold": 50}},
+	})
+	if err != nil {
+		t.Fatalf("create_transponder returned error: %v", err)
+	}
+	if name := decodeToolPayload(t, result)["data"].(map[string]any)["name"]; name != "High error rate" {
+		t.Fatalf("name = %v, want the top-level name to win over payload", name)
+	}
+	if _, err := executeTool(ctx, state, "update_transponder", map[string]any{"id": "42", "status": "paused"}); err != nil {
+		t.Fatalf("update_transponder returned error: %v", err)
+	}
+
+	errorCases := []struct {
+		tool string
+		args map[string]any
+		want string
+	}{
+		{"create_transponder", map[string]any{"name": "x"}, "kind: required"},
+		{"update_transponder", map[string]any{"status": "paused"}, "id is required"},
+		{"update_transponder", map[string]any{"id": "42"}, "nothing to update"},
+		{"update_transponder", map[string]any{"id": "42", "status": "off"}, "expected one of active, paused"},
+	}
+	for _, tt := range errorCases {
+		if _, err := executeTool(ctx, state, tt.tool, tt.args); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Fatalf("%s(%v) err = %v, want %q", tt.tool, tt.args, err, tt.want)
+		}
+	}
+
+	want := []string{
+		`POST /api/v1/transponders {"config":{"threshold":50},"key":"event::logs","kind":"threshold","name":"High error rate"}`,
+		`PUT /api/v1/transponders/42 {"status":"paused"}`,
+	}
+	if strings.Join(requests, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("requests = %q, want %q", requests, want)
+	}
+
+	for _, tool := range toolDefinitions("sqlite") {
+		if strings.Contains(tool.Name, "transponder") {
+			t.Fatalf("local drivers list %s", tool.Name)
+		}
+	}
+}
+
+func TestMCPSetupStorage(t *testing.T) {
+	t.Parallel()
+
+	local, err := loadLocalConfig(&driverOptions{
+		Driver:          "sqlite",
+		DBPath:          filepath.Join(t.TempDir(), "stats.db"),
+		Table:           "metrics",
+		Joined:          "full",
+		Separator:       "::",
+		TimeZone:        "UTC",
+		BeginningOfWeek: "monday",
+		BufferMode:      "off",
+	})
+	if err != nil {
+		t.Fatalf("loadLocalConfig returned error: %v", err)
+	}
+	state := &mcpState{Driver: local.DriverName, Local: local}
+	ctx := context.Background()
+	args := map[string]any{"key": "event::logs", "last": "1d", "granularity": "1h"}
+
+	if _, err := executeTool(ctx, state, "fetch_series", args); err == nil || !strings.Contains(err.Error(), "call the setup_storage tool") {
+		t.Fatalf("fetch_series before setup err = %v, want a setup_storage hint", err)
+	}
+
+	for _, wantCreated := range []bool{true, false} {
+		result, err := executeTool(ctx, state, "setup_storage", map[string]any{})
+		if err != nil {
+			t.Fatalf("setup_storage returned error: %v", err)
+		}
+		data := decodeToolPayload(t, result)["data"].(map[string]any)
+		if data["driver"] != "sqlite" || data["table"] != "metrics" || data["created"] != wantCreated {
+			t.Fatalf("setup_storage data = %v, want sqlite metrics created=%v", data, wantCreated)
+		}
+	}
+
+	if _, err := executeTool(ctx, state, "fetch_series", args); err != nil {
+		t.Fatalf("fetch_series after setup returned error: %v", err)
+	}
+	if _, err := executeTool(ctx, &mcpState{Driver: "api"}, "setup_storage", map[string]any{}); err == nil {
+		t.Fatal("expected setup_storage to fail for the api driver")
+	}
+	for _, tool := range toolDefinitions("api") {
+		if tool.Name == "setup_storage" {
+			t.Fatal("api drivers list setup_storage")
+		}
+	}
+}
+
+func TestMCPResourceTemplates(t *testing.T) {
+	t.Parallel()
+
+	response, err := handleMCPRequest(context.Background(), &mcpState{Driver: "api"}, rpcRequest{JSONRPC: "2.0", ID: json.RawMessage("1"), Method: "resources/templates/list"})
+	if err != nil {
+		t.Fatalf("resources/templates/list returned error: %v", err)
+	}
+	var uris []string
+	for _, template := range response.Result.(map[string]any)["resourceTemplates"].([]resourceTemplate) {
+		uris = append(uris, template.URITemplate)
+	}
+	want := []string{"trifle://metrics/{key}{?from,to,last,granularity}", "trifle://transponders/{id}/logs{?limit,cursor}"}
+	if !reflect.DeepEqual(uris, want) {
+		t.Fatalf("templates = %v, want %v", uris, want)
+	}
+	if local := resourceTemplates("sqlite"); len(local) != 1 {
+		t.Fatalf("sqlite templates = %+v, want only the metric series", local)
+	}
+
+	for _, resource := range resourceList("api") {
+		if strings.Contains(resource.URI, "{") {
+			t.Fatalf("resources/list has templated URI %s", resource.URI)
+		}
+	}
+}
+
+func TestMCPReadResourceValidatesQuery(t *testing.T) {
+	t.Parallel()
+
+	state := newSQLiteMCPState(t)
+	ctx := context.Background()
+	tests := []struct {
+		uri  string
+		want string
+	}{
+		{"trifle://metrics/event::logs?granularity=hourly", `invalid granularity "hourly": granularity must be <number><unit>`},
+		{"trifle://metrics?last=soon", "invalid time range:"},
+		{"trifle://metrics/event::logs?from=-1d&last=1d", "invalid time range: last cannot be combined with from/to"},
+		{"trifle://metrics/event::logs?granulrity=1h", `unknown parameter "granulrity" for trifle://metrics/event::logs?granulrity=1h (use from, to, last, granularity)`},
+	}
+	for _, tt := range tests {
+		if _, err := readResource(ctx, state, tt.uri); err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+			t.Fatalf("readResource(%s) err = %v, want prefix %q", tt.uri, err, tt.want)
+		}
+	}
+
+	client, err := api.New("http://127.0.0.1:1", "token", time.Second)
+	if err != nil {
+		t.Fatalf("api.New returned error: %v", err)
+	}
+	if _, err := readResource(ctx, &mcpState{Driver: "api", API: client}, "trifle://transponders/42/logs?page=2"); err == nil || !strings.HasPrefix(err.Error(), `unknown parameter "page"`) {
+		t.Fatalf("transponder logs with an unknown parameter err = %v", err)
+	}
+	if _, err := readResource(ctx, state, "trifle://metrics/event%3A%3Alogs?last=1d&granularity=1h"); err != nil {
+		t.Fatalf("readResource with an expanded template returned error: %v", err)
+	}
+}
+
+func TestMCPStreamRejectsMissingVersion(t *testing.T) {
+	t.Parallel()
+
+	in := strings.NewReader(`{"id":1,"method":"tools/list"}` + "\n" + `{"method":"ping"}` + "\n" + `{"jsonrpc":"2.0","id":2,"method":"ping"}` + "\n")
+	var out strings.Builder
+	if err := serveMCPStream(context.Background(), &mcpState{Driver: "api"}, in, &out); err != nil {
+		t.Fatalf("serveMCPStream returned error: %v", err)
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(out.String()))
+	var ids []string
+	for {
+		var response rpcResponse
+		if err := decoder.Decode(&response); err != nil {
+			break
+		}
+		ids = append(ids, string(response.ID))
+		if string(response.ID) != "2" && (response.Error == nil || response.Error.Code != -32600) {
+			t.Fatalf("response %s error = %v, want -32600", response.ID, response.Error)
+		}
+	}
+	if !reflect.DeepEqual(ids, []string{"1", "null", "2"}) {
+		t.Fatalf("response ids = %v, want [1 null 2]", ids)
+	}
+}
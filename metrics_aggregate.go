@@ -0,0 +1,249 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/trifle-io/trifle-cli/internal/output"
+	triflestats "github.com/trifle-io/trifle_stats_go"
+)
+
+const defaultPromMetricName = "trifle_metric"
+
+// aggregateResult holds the values of one aggregate query together with the
+// bucket range each value covers, for the text output formats.
+type aggregateResult struct {
+	Key         string
+	ValuePath   string
+	Aggregator  string
+	Granularity string
+	Values      []any
+	Ranges      []timeChunk
+}
+
+// aggregateSliceRanges returns the time covered by each of count aggregate
+// values. A single value covers from..to; slices run from their first bucket
+// start to the end of their last bucket, mirroring how the aggregators split
+// the timeline (leftover buckets at the start are dropped). The last bucket
+// ends at to when to falls inside it.
+func aggregateSliceRanges(from, to time.Time, granularity string, cfg *triflestats.Config, count int) []timeChunk {
+	ranges := make([]timeChunk, count)
+	if count == 1 {
+		ranges[0] = timeChunk{From: from, To: to}
+		return ranges
+	}
+
+	parser := triflestats.NewParser(granularity)
+	if !parser.Valid() || count == 0 {
+		return ranges
+	}
+	buckets := triflestats.Timeline(from, to, parser.Offset, parser.Unit, cfg)
+	size := len(buckets) / count
+	if size <= 0 {
+		return ranges
+	}
+	bucketEnd := func(start time.Time) time.Time {
+		return triflestats.NewNocturnal(start, cfg).Add(parser.Offset, parser.Unit)
+	}
+	last := buckets[len(buckets)-1]
+	end := bucketEnd(last)
+	if to.After(last) && to.Before(end) {
+		end = to.In(last.Location())
+	}
+	start := len(buckets) - size*count
+	for i := range ranges {
+		sliceEnd := bucketEnd(buckets[start+(i+1)*size-1])
+		if sliceEnd.After(end) {
+			sliceEnd = end
+		}
+		ranges[i] = timeChunk{From: buckets[start+i*size], To: sliceEnd}
+	}
+	return ranges
+}
+
+// sliceRangesPayload describes the time each aggregate slice covers as
+// {from, to}. A slice that holds no whole bucket has nil bounds.
+func sliceRangesPayload(chunks []timeChunk) []any {
+	ranges := make([]any, len(chunks))
+	for i, chunk := range chunks {
+		if chunk.From.IsZero() {
+			ranges[i] = map[string]any{"from": nil, "to": nil}
+			continue
+		}
+		ranges[i] = map[string]any{"from": chunk.From.Format(time.RFC3339), "to": chunk.To.Format(time.RFC3339)}
+	}
+	return ranges
+}
+
+// applySliceRanges adds slice_ranges to an aggregate payload with more than
+// one slice and replaces its table with one row per slice: the range it
+// covers and the value of each path.
+func applySliceRanges(payload map[string]any, ranges []any, paths []string, values map[string][]any) {
+	if len(ranges) < 2 {
+		return
+	}
+	columns := []any{"slice", "from", "to"}
+	for _, path := range paths {
+		columns = append(columns, path)
+	}
+	rows := make([]any, len(ranges))
+	for i, raw := range ranges {
+		bounds, _ := raw.(map[string]any)
+		row := []any{i + 1, bounds["from"], bounds["to"]}
+		for _, path := range paths {
+			var cell any
+			if i < len(values[path]) {
+				cell = values[path][i]
+			}
+			row = append(row, cell)
+		}
+		rows[i] = row
+	}
+	payload["slice_ranges"] = ranges
+	payload["table"] = map[string]any{"columns": columns, "rows": rows}
+}
+
+// apiAggregateSliceRanges computes slice ranges for aggregate values
+// returned by the API, using the driver time zone and week start for bucket
+// boundaries.
+func apiAggregateSliceRanges(fromValue, toValue, granularity string, opts *driverOptions, count int) ([]timeChunk, error) {
+	from, err := time.Parse(time.RFC3339Nano, fromValue)
+	if err != nil {
+		return nil, err
+	}
+	to, err := time.Parse(time.RFC3339Nano, toValue)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := bucketConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	return aggregateSliceRanges(from, to, granularity, cfg, count), nil
+}
+
+// aggregateResponseValues pulls the aggregate values out of an API response,
+// accepting either a values list or a single value.
+func aggregateResponseValues(data map[string]any) []any {
+	if values, ok := data["values"].([]any); ok {
+		return values
+	}
+	if value, ok := data["value"]; ok {
+		return []any{value}
+	}
+	return nil
+}
+
+// buildAggregateSamples stamps each aggregate value with the end of its
+// range. Values that are not numeric are skipped since Prometheus has no null.
+func buildAggregateSamples(result aggregateResult) []output.PromSample {
+	labels := []output.PromLabel{
+		{Name: "key", Value: result.Key},
+		{Name: "path", Value: result.ValuePath},
+		{Name: "aggregator", Value: result.Aggregator},
+	}
+	samples := make([]output.PromSample, 0, len(result.Values))
+	for i, value := range result.Values {
+		number, ok := triflestats.NormalizeNumeric(value).(float64)
+		if !ok {
+			continue
+		}
+		sample := output.PromSample{Labels: labels, Value: number}
+		if i < len(result.Ranges) {
+			sample.At = result.Ranges[i].To
+		}
+		samples = append(samples, sample)
+	}
+	return samples
+}
+
+// writeAggregateProm writes aggregate values in the Prometheus text format,
+// one series per value path.
+func writeAggregateProm(opts *outputOptions, name string, results []aggregateResult) error {
+	var samples []output.PromSample
+	for _, result := range results {
+		samples = append(samples, buildAggregateSamples(result)...)
+	}
+	if len(samples) == 0 {
+		return fmt.Errorf("no numeric values to export for path %s", aggregateResultsPath(results))
+	}
+	return writeCommandOutput(opts, func(w io.Writer) error {
+		return output.PrintPrometheus(w, name, samples)
+	})
+}
+
+// writeAggregateSummary prints one human-readable line per aggregate value.
+func writeAggregateSummary(opts *outputOptions, results []aggregateResult, loc *time.Location, precision int) error {
+	return writeCommandOutput(opts, func(w io.Writer) error {
+		color := output.ColorEnabled(w)
+		for _, result := range results {
+			if err := printAggregateSummary(w, result, loc, precision, color); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func aggregateResultsPath(results []aggregateResult) string {
+	paths := make([]string, len(results))
+	for i, result := range results {
+		paths[i] = result.ValuePath
+	}
+	return strings.Join(paths, ", ")
+}
+
+// printAggregateSummary writes lines like
+// "sum(count) for event::logs over 2026-01-01 → 2026-01-31 (1d): 12,345".
+func printAggregateSummary(w io.Writer, result aggregateResult, loc *time.Location, precision int, color bool) error {
+	if len(result.Values) == 0 {
+		return fmt.Errorf("no data available for path %s in the selected timeframe", result.ValuePath)
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	label := fmt.Sprintf("%s(%s) for %s", result.Aggregator, result.ValuePath, result.Key)
+	for i, value := range result.Values {
+		line := label
+		if i < len(result.Ranges) && !result.Ranges[i].From.IsZero() {
+			line += " over " + formatSummaryRange(result.Ranges[i], loc)
+		}
+		if result.Granularity != "" {
+			line += " (" + result.Granularity + ")"
+		}
+
+		text := "no data"
+		if number, ok := triflestats.NormalizeNumeric(value).(float64); ok {
+			text = output.FormatNumber(number, precision)
+		}
+		if _, err := fmt.Fprintf(w, "%s: %s\n", line, output.Bold(text, color)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatSummaryRange drops the clock when both ends fall on midnight.
+func formatSummaryRange(r timeChunk, loc *time.Location) string {
+	from, to := r.From.In(loc), r.To.In(loc)
+	layout := "2006-01-02 15:04"
+	if isMidnight(from) && isMidnight(to) {
+		layout = "2006-01-02"
+	}
+	return from.Format(layout) + " → " + to.Format(layout)
+}
+
+func isMidnight(t time.Time) bool {
+	return t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 && t.Nanosecond() == 0
+}
+
+// writeAggregateText writes the prom or summary rendering of an aggregate.
+func writeAggregateText(opts *outputOptions, format, metricName string, results []aggregateResult, tableOpts output.TableOptions) error {
+	if format == "prom" {
+		return writeAggregateProm(opts, metricName, results)
+	}
+	return writeAggregateSummary(opts, results, tableOpts.DisplayLoc, tableOpts.Precision)
+}
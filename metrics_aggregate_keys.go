@@ -0,0 +1,333 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/trifle-io/trifle-cli/internal/output"
+	triflestats "github.com/trifle-io/trifle_stats_go"
+)
+
+const defaultMaxCombineKeys = 100
+
+// keyAggregate is one key's aggregate in metrics aggregate --key-prefix output.
+// Value is nil when the key has no data for the path.
+type keyAggregate struct {
+	MetricKey string `json:"metric_key"`
+	Value     any    `json:"value"`
+}
+
+// multiKeyAggregate holds the parsed metrics aggregate flags that apply
+// when --key-prefix aggregates every matching key and combines the results.
+type multiKeyAggregate struct {
+	opts           *commonOptions
+	driverOpts     *driverOptions
+	timeRange      *timeRangeOptions
+	defaultRange   string
+	granularity    string
+	align          bool
+	excludePartial bool
+	keyPrefix      string
+	valuePath      string
+	aggregator     string
+	combine        string
+	maxKeys        int
+	concurrency    int
+	format         string
+	quiet          bool
+	explain        bool
+	outputOpts     *outputOptions
+	tableOpts      output.TableOptions
+	stats          *commandStats
+}
+
+func validateCombine(combine string) (string, error) {
+	value := strings.ToLower(strings.TrimSpace(combine))
+	switch value {
+	case "sum", "mean", "min", "max":
+		return value, nil
+	case "":
+		return "", usageErrorf("--combine is required with --key-prefix (sum|mean|min|max)")
+	default:
+		return "", usageErrorf("unsupported --combine %q (use sum, mean, min, or max)", combine)
+	}
+}
+
+// combineKeyValues folds the numeric per-key values with how, skipping keys
+// without data. It returns nil and 0 when no key has a value.
+func combineKeyValues(values []any, how string) (any, int) {
+	var combined float64
+	counted := 0
+	for _, raw := range values {
+		value, ok := triflestats.NormalizeNumeric(raw).(float64)
+		if !ok {
+			continue
+		}
+		switch {
+		case counted == 0:
+			combined = value
+		case how == "min":
+			combined = math.Min(combined, value)
+		case how == "max":
+			combined = math.Max(combined, value)
+		default:
+			combined += value
+		}
+		counted++
+	}
+	if counted == 0 {
+		return nil, 0
+	}
+	if how == "mean" {
+		combined /= float64(counted)
+	}
+	return combined, counted
+}
+
+// checkMaxKeys refuses to fan out over more keys than maxKeys (0 allows any).
+func checkMaxKeys(keys []string, prefix string, maxKeys int) error {
+	if maxKeys > 0 && len(keys) > maxKeys {
+		return fmt.Errorf("%d keys match --key-prefix %s, more than --max-keys %d; narrow the prefix or raise --max-keys", len(keys), prefix, maxKeys)
+	}
+	return nil
+}
+
+func (m *multiKeyAggregate) run() {
+	var fromValue, toValue, granularityValue string
+	var candidates []string
+	var values []any
+	partialExcluded := false
+
+	resolveWindow := func() {
+		var err error
+		if m.align {
+			fromValue, toValue, err = alignTimeframe(fromValue, toValue, granularityValue, m.driverOpts)
+			if err != nil {
+				exitError(err)
+			}
+		}
+		if m.excludePartial {
+			toValue, partialExcluded, err = excludePartialBucket(fromValue, toValue, granularityValue, m.driverOpts)
+			if err != nil {
+				exitError(err)
+			}
+		}
+		warnTimeframePoints(fromValue, toValue, granularityValue)
+		m.stats.granularity = granularityValue
+	}
+
+	if isLocalDriver(m.driverOpts.Driver) {
+		local, err := prepareLocalConfig(m.driverOpts)
+		if err != nil {
+			exitError(err)
+		}
+		cfg := local.Config
+
+		m.timeRange.Default = m.defaultRange
+		fromValue, toValue, err = resolveCommandTimeRange(m.timeRange, m.driverOpts)
+		if err != nil {
+			exitError(err)
+		}
+		granularityValue, err = resolveGranularityLocal(m.granularity, cfg)
+		if err != nil {
+			exitError(err)
+		}
+		resolveWindow()
+
+		fromTime, err := time.Parse(time.RFC3339Nano, fromValue)
+		if err != nil {
+			exitError(err)
+		}
+		toTime, err := time.Parse(time.RFC3339Nano, toValue)
+		if err != nil {
+			exitError(err)
+		}
+
+		if m.explain {
+			plan := localPlan("metrics aggregate", local, m.driverOpts, map[string]any{
+				"key":         systemMetricsKey,
+				"key_prefix":  m.keyPrefix,
+				"value_path":  m.valuePath,
+				"aggregator":  m.aggregator,
+				"combine":     m.combine,
+				"from":        fromValue,
+				"to":          toValue,
+				"granularity": granularityValue,
+				"max_keys":    m.maxKeys,
+				"concurrency": m.concurrency,
+			})
+			if err := writeJSONOutput(m.outputOpts, plan); err != nil {
+				exitError(err)
+			}
+			return
+		}
+		if err := local.connect(m.driverOpts); err != nil {
+			exitError(err)
+		}
+
+		var keysResult triflestats.ValuesResult
+		err = m.stats.timeQuery(func() (err error) {
+			keysResult, err = triflestats.Values(cfg, systemMetricsKey, fromTime, toTime, granularityValue, true)
+			return err
+		})
+		if err != nil {
+			exitError(driverError(maybeSuggestSetup(err, local.DriverName, local.TableName)))
+		}
+		candidates = filterKeysByPrefix(keysEntryNames(summarizeSystemKeys(keysResult.Values)), m.keyPrefix)
+		if err := checkMaxKeys(candidates, m.keyPrefix, m.maxKeys); err != nil {
+			exitError(err)
+		}
+
+		points := make([]int, len(candidates))
+		err = m.stats.timeQuery(func() error {
+			values, err = mapKeysConcurrently(candidates, m.concurrency, func(key string, i int) (any, error) {
+				result, err := triflestats.Values(cfg, key, fromTime, toTime, granularityValue, false)
+				if err != nil {
+					return nil, driverError(err)
+				}
+				points[i] = len(result.At)
+				aggregated, err := aggregateSeriesPath(triflestats.SeriesFromResult(result), m.aggregator, m.valuePath, 1)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", key, err)
+				}
+				return firstAggregateValue(aggregated), nil
+			})
+			return err
+		})
+		if err != nil {
+			exitError(err)
+		}
+		m.stats.points = len(keysResult.At)
+		for _, count := range points {
+			m.stats.points += count
+		}
+	} else {
+		if !m.explain {
+			if err := ensureToken(m.opts, true); err != nil {
+				exitError(err)
+			}
+		}
+
+		client, err := newClient(m.opts)
+		if err != nil {
+			exitError(err)
+		}
+		source := newSourceLookup(client)
+		m.stats.client = client
+
+		fromValue, toValue, err = resolveSourceTimeRange(context.Background(), source, m.timeRange, m.driverOpts)
+		if err != nil {
+			exitError(err)
+		}
+		granularityValue, err = resolveGranularityValue(context.Background(), source, m.granularity)
+		if err != nil {
+			exitError(err)
+		}
+		resolveWindow()
+
+		params := map[string]string{
+			"from":        fromValue,
+			"to":          toValue,
+			"granularity": granularityValue,
+		}
+		queryPayload := func(key string) map[string]any {
+			return map[string]any{
+				"mode":        "aggregate",
+				"key":         key,
+				"value_path":  m.valuePath,
+				"aggregator":  m.aggregator,
+				"from":        fromValue,
+				"to":          toValue,
+				"granularity": granularityValue,
+				"slices":      1,
+			}
+		}
+
+		if m.explain {
+			request, err := explainGetMetrics(client, params, m.driverOpts.BeginningOfWeek)
+			if err != nil {
+				exitError(err)
+			}
+			plan := apiPlan("metrics aggregate", m.opts, request)
+			perKey, err := explainQueryMetrics(client, queryPayload("<key>"), m.driverOpts.BeginningOfWeek)
+			if err != nil {
+				exitError(err)
+			}
+			plan["per_key"] = apiPlan("metrics aggregate", m.opts, perKey)
+			plan["key_prefix"] = m.keyPrefix
+			plan["combine"] = m.combine
+			plan["max_keys"] = m.maxKeys
+			plan["concurrency"] = m.concurrency
+			if err := writeJSONOutput(m.outputOpts, plan); err != nil {
+				exitError(err)
+			}
+			return
+		}
+
+		var response metricsResponse
+		if err := getMetrics(context.Background(), client, params, m.driverOpts.BeginningOfWeek, &response); err != nil {
+			exitError(err)
+		}
+		candidates = filterKeysByPrefix(keysEntryNames(summarizeKeys(response.Data.Values)), m.keyPrefix)
+		if err := checkMaxKeys(candidates, m.keyPrefix, m.maxKeys); err != nil {
+			exitError(err)
+		}
+
+		values, err = mapKeysConcurrently(candidates, m.concurrency, func(key string, _ int) (any, error) {
+			data, err := queryMetrics(context.Background(), client, queryPayload(key), m.driverOpts.BeginningOfWeek)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			return firstAggregateValue(aggregateResponseValues(data)), nil
+		})
+		if err != nil {
+			exitError(err)
+		}
+		m.stats.points = len(response.Data.At)
+	}
+
+	if len(candidates) == 0 {
+		exitError(fmt.Errorf("no keys match --key-prefix %s in the selected timeframe", m.keyPrefix))
+	}
+	combined, counted := combineKeyValues(values, m.combine)
+	if m.quiet {
+		if err := writeQuietLines(m.outputOpts, quietAggregateLines([]any{combined})); err != nil {
+			exitError(err)
+		}
+		return
+	}
+
+	keys := make([]keyAggregate, len(candidates))
+	rows := make([]any, 0, len(candidates)+1)
+	for i, key := range candidates {
+		keys[i] = keyAggregate{MetricKey: key, Value: values[i]}
+		rows = append(rows, []any{key, values[i]})
+	}
+	rows = append(rows, []any{m.combine + " of keys", combined})
+
+	payload := map[string]any{
+		"status":         "ok",
+		"aggregator":     m.aggregator,
+		"combine":        m.combine,
+		"key_prefix":     m.keyPrefix,
+		"value_path":     m.valuePath,
+		"timeframe":      buildTimeframePayload(fromValue, toValue, granularityValue, m.timeRange.Timeframe),
+		"keys":           keys,
+		"matched_keys":   len(candidates),
+		"keys_with_data": counted,
+		"value":          combined,
+		"table": map[string]any{
+			"columns": []any{"metric_key", m.valuePath},
+			"rows":    rows,
+		},
+	}
+	if m.excludePartial {
+		payload["partial_excluded"] = partialExcluded
+	}
+	if err := writeTableOrJSONOutput(m.outputOpts, payload, m.format, m.tableOpts); err != nil {
+		exitError(err)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCombineKeyValues(t *testing.T) {
+	t.Parallel()
+
+	values := []any{4.0, nil, 1, "n/a", 7.0}
+	tests := []struct {
+		how  string
+		want any
+	}{
+		{"sum", 12.0},
+		{"mean", 4.0},
+		{"min", 1.0},
+		{"max", 7.0},
+	}
+	for _, tt := range tests {
+		got, counted := combineKeyValues(values, tt.how)
+		if got != tt.want || counted != 3 {
+			t.Fatalf("combineKeyValues(%s) = %v over %d keys, want %v over 3", tt.how, got, counted, tt.want)
+		}
+	}
+
+	if got, counted := combineKeyValues([]any{nil, nil}, "sum"); got != nil || counted != 0 {
+		t.Fatalf("combineKeyValues without data = %v over %d keys, want nil over 0", got, counted)
+	}
+}
+
+func TestValidateCombine(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		combine string
+		want    string
+		wantErr string
+	}{
+		{combine: " Sum ", want: "sum"},
+		{combine: "mean", want: "mean"},
+		{combine: "", wantErr: "--combine is required"},
+		{combine: "p95", wantErr: "unsupported --combine"},
+	}
+	for _, tt := range tests {
+		got, err := validateCombine(tt.combine)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("validateCombine(%q) error = %v, want %q", tt.combine, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Fatalf("validateCombine(%q) = %q (err %v), want %q", tt.combine, got, err, tt.want)
+		}
+	}
+}
+
+func TestCheckMaxKeys(t *testing.T) {
+	t.Parallel()
+
+	keys := []string{"event::logs::a", "event::logs::b", "event::logs::c"}
+	if err := checkMaxKeys(keys, "event::logs::", 3); err != nil {
+		t.Fatalf("checkMaxKeys at the limit returned error: %v", err)
+	}
+	if err := checkMaxKeys(keys, "event::logs::", 0); err != nil {
+		t.Fatalf("checkMaxKeys without a limit returned error: %v", err)
+	}
+	err := checkMaxKeys(keys, "event::logs::", 2)
+	if err == nil || !strings.Contains(err.Error(), "3 keys match --key-prefix event::logs::, more than --max-keys 2") {
+		t.Fatalf("checkMaxKeys over the limit = %v", err)
+	}
+}
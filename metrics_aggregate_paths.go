@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/trifle-io/trifle-cli/internal/api"
+	"github.com/trifle-io/trifle-cli/internal/output"
+	triflestats "github.com/trifle-io/trifle_stats_go"
+)
+
+// valuePathsFlag collects repeatable --value-path flags.
+type valuePathsFlag []string
+
+func (v *valuePathsFlag) String() string {
+	return strings.Join(*v, ",")
+}
+
+func (v *valuePathsFlag) Set(value string) error {
+	*v = append(*v, strings.TrimSpace(value))
+	return nil
+}
+
+// first returns the only value path, or "" when none was given.
+func (v valuePathsFlag) first() string {
+	if len(v) == 0 {
+		return ""
+	}
+	return v[0]
+}
+
+// validateMultiplePaths checks a repeated --value-path, which names plain
+// paths and has no comparison or --quiet output.
+func validateMultiplePaths(paths []string, compare bool, quiet bool) error {
+	seen := map[string]bool{}
+	for _, path := range paths {
+		if path == "" {
+			return usageErrorf("--value-path cannot be empty")
+		}
+		if hasPathWildcard(path) {
+			return usageErrorf("repeated --value-path takes plain paths; use a single --value-path with * to match several")
+		}
+		if seen[path] {
+			return usageErrorf("--value-path %s is repeated", path)
+		}
+		seen[path] = true
+	}
+	if compare {
+		return usageErrorf("--compare supports a single --value-path")
+	}
+	if quiet {
+		return usageErrorf("--quiet supports a single --value-path")
+	}
+	return nil
+}
+
+// pathAggregate is the outcome of aggregating one of several value paths;
+// Err is set when the path is unknown or has no data.
+type pathAggregate struct {
+	Path   string
+	Values []any
+	Err    error
+}
+
+// localPathAggregates aggregates each path of an already fetched series.
+func localPathAggregates(series triflestats.Series, aggregator string, paths []string, slices int) []pathAggregate {
+	available := series.AvailablePaths()
+	outcomes := make([]pathAggregate, len(paths))
+	for i, path := range paths {
+		outcomes[i].Path = path
+		if !containsString(available, path) {
+			outcomes[i].Err = fmt.Errorf("unknown path")
+			continue
+		}
+		values, err := aggregateSeriesPath(series, aggregator, path, slices)
+		if err != nil {
+			outcomes[i].Err = err
+			continue
+		}
+		outcomes[i].Values = normalizeAggregateValues(values)
+	}
+	return outcomes
+}
+
+// apiPathAggregates sends one aggregate query per path, a few at a time,
+// with payload as the template. A failed query only fails its path.
+func apiPathAggregates(ctx context.Context, client *api.Client, payload map[string]any, paths []string, weekStart string) []pathAggregate {
+	// Errors are kept per path, so the mapper itself never fails.
+	fetched, _ := mapKeysConcurrently(paths, defaultTopConcurrency, func(path string, _ int) (any, error) {
+		query := make(map[string]any, len(payload))
+		for name, value := range payload {
+			query[name] = value
+		}
+		query["value_path"] = path
+
+		data, err := queryMetrics(ctx, client, query, weekStart)
+		if err != nil {
+			return pathAggregate{Path: path, Err: err}, nil
+		}
+		return pathAggregate{Path: path, Values: normalizeAggregateValues(aggregateResponseValues(data))}, nil
+	})
+	outcomes := make([]pathAggregate, len(paths))
+	for i, outcome := range fetched {
+		outcomes[i] = outcome.(pathAggregate)
+	}
+	return outcomes
+}
+
+// normalizeAggregateValues normalizes numbers in place; unlike
+// normalizeNumericSlice it keeps empty slices as nil so values of different
+// paths stay aligned by slice.
+func normalizeAggregateValues(values []any) []any {
+	out := make([]any, len(values))
+	for i, value := range values {
+		out[i] = triflestats.NormalizeNumeric(value)
+	}
+	return out
+}
+
+// hasAggregateValue reports whether any slice holds a value.
+func hasAggregateValue(values []any) bool {
+	for _, value := range values {
+		if value != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// pathAggregateResults keeps the paths that aggregated, for the prom and
+// summary formats. template supplies the key, aggregator, and granularity;
+// ranges gives the bucket range of each of count values.
+func pathAggregateResults(outcomes []pathAggregate, template aggregateResult, ranges func(count int) []timeChunk) []aggregateResult {
+	var results []aggregateResult
+	for _, outcome := range outcomes {
+		if outcome.Err != nil || !hasAggregateValue(outcome.Values) {
+			continue
+		}
+		result := template
+		result.ValuePath = outcome.Path
+		result.Values = outcome.Values
+		result.Ranges = ranges(len(outcome.Values))
+		results = append(results, result)
+	}
+	return results
+}
+
+// writePathAggregates writes the outcome of a repeated --value-path or, when
+// expr is set, of --expr, with base holding the payload fields shared with a
+// single path.
+func writePathAggregates(opts *outputOptions, format, metricName string, tableOpts output.TableOptions, outcomes []pathAggregate, slices int, expr *metricExpr, base map[string]any, template aggregateResult, ranges func(count int) []timeChunk) error {
+	var payload map[string]any
+	var results []aggregateResult
+	var err error
+	if expr == nil {
+		payload, err = multiplePathsPayload(outcomes, slices)
+		results = pathAggregateResults(outcomes, template, ranges)
+	} else {
+		var values []any
+		payload, values, err = exprAggregatePayload(expr, outcomes, slices)
+		result := template
+		result.ValuePath = expr.Source
+		result.Values = values
+		result.Ranges = ranges(len(values))
+		results = []aggregateResult{result}
+	}
+	if err != nil {
+		return err
+	}
+	warnPathErrors(payload)
+	if format == "prom" || format == "summary" {
+		return writeAggregateText(opts, format, metricName, results, tableOpts)
+	}
+	for name, value := range base {
+		payload[name] = value
+	}
+	return writeTableOrJSONOutput(opts, payload, format, tableOpts)
+}
+
+// multiplePathsPayload maps each path to its values, with a table holding a
+// row per path and a column per slice. Paths that failed are listed under
+// errors; it fails only when every path did.
+func multiplePathsPayload(outcomes []pathAggregate, slices int) (map[string]any, error) {
+	valuesByPath := map[string]any{}
+	valueByPath := map[string]any{}
+	errorsByPath := map[string]any{}
+	var matched, failures []string
+	var rows []any
+
+	for _, outcome := range outcomes {
+		err := outcome.Err
+		if err == nil && !hasAggregateValue(outcome.Values) {
+			err = fmt.Errorf("no data in the selected timeframe")
+		}
+		if err != nil {
+			errorsByPath[outcome.Path] = err.Error()
+			failures = append(failures, fmt.Sprintf("%s: %v", outcome.Path, err))
+			continue
+		}
+		matched = append(matched, outcome.Path)
+		valuesByPath[outcome.Path] = outcome.Values
+		if slices == 1 {
+			valueByPath[outcome.Path] = outcome.Values[0]
+		}
+		rows = append(rows, append([]any{outcome.Path}, outcome.Values...))
+	}
+	if len(matched) == 0 {
+		return nil, fmt.Errorf("no path could be aggregated:\n  %s", strings.Join(failures, "\n  "))
+	}
+	width := 0
+	for _, row := range rows {
+		width = max(width, len(row.([]any))-1)
+	}
+	columns := []any{"path"}
+	if width == 1 {
+		columns = append(columns, "value")
+	} else {
+		for i := 1; i <= width; i++ {
+			columns = append(columns, fmt.Sprintf("slice %d", i))
+		}
+	}
+
+	sort.Strings(matched)
+	payload := map[string]any{
+		"matched_paths": matched,
+		"values":        valuesByPath,
+		"table":         map[string]any{"columns": columns, "rows": rows},
+	}
+	if slices == 1 {
+		payload["value"] = valueByPath
+	}
+	if len(errorsByPath) > 0 {
+		payload["errors"] = errorsByPath
+	}
+	return payload, nil
+}
+
+// exprAggregatePayload evaluates expr slice by slice over the aggregates of
+// the paths it reads. A path that failed or has no value in a slice makes
+// that slice nil, as does a division by zero; failed paths are listed under
+// errors. It fails only when every path did.
+func exprAggregatePayload(expr *metricExpr, outcomes []pathAggregate, slices int) (map[string]any, []any, error) {
+	inputs := map[string]any{}
+	errorsByPath := map[string]any{}
+	var failures []string
+	count := 0
+	for _, outcome := range outcomes {
+		if outcome.Err != nil {
+			errorsByPath[outcome.Path] = outcome.Err.Error()
+			failures = append(failures, fmt.Sprintf("%s: %v", outcome.Path, outcome.Err))
+			continue
+		}
+		inputs[outcome.Path] = outcome.Values
+		count = max(count, len(outcome.Values))
+	}
+	if len(inputs) == 0 {
+		return nil, nil, fmt.Errorf("no path in the expression could be aggregated:\n  %s", strings.Join(failures, "\n  "))
+	}
+
+	paths := expr.Paths()
+	columns := []any{"slice"}
+	for _, path := range paths {
+		columns = append(columns, path)
+	}
+	columns = append(columns, expr.Source)
+
+	values := make([]any, count)
+	rows := make([]any, count)
+	for i := range values {
+		bucket := map[string]float64{}
+		row := []any{i + 1}
+		for _, path := range paths {
+			var cell any
+			if series, ok := inputs[path].([]any); ok && i < len(series) {
+				cell = series[i]
+			}
+			if number, ok := cell.(float64); ok {
+				bucket[path] = number
+			}
+			row = append(row, cell)
+		}
+		values[i] = expr.Eval(bucket)
+		rows[i] = append(row, values[i])
+	}
+
+	payload := map[string]any{
+		"inputs": inputs,
+		"values": values,
+		"count":  len(values),
+		"table":  map[string]any{"columns": columns, "rows": rows},
+	}
+	if slices == 1 && len(values) > 0 {
+		payload["value"] = values[0]
+	}
+	if len(errorsByPath) > 0 {
+		payload["errors"] = errorsByPath
+	}
+	return payload, values, nil
+}
+
+// warnPathErrors reports the paths listed under errors on stderr.
+func warnPathErrors(payload map[string]any) {
+	errors, _ := payload["errors"].(map[string]any)
+	for _, path := range mapKeys(errors) {
+		fmt.Fprintf(os.Stderr, "warning: %s: %v\n", path, errors[path])
+	}
+}
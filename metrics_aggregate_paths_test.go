@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	triflestats "github.com/trifle-io/trifle_stats_go"
+)
+
+func TestLocalPathAggregates(t *testing.T) {
+	t.Parallel()
+
+	series := triflestats.SeriesFromResult(triflestats.ValuesResult{
+		At: hourlyBuckets(3),
+		Values: []map[string]any{
+			{"status": map[string]any{"ok": 4, "error": 1}},
+			{"status": map[string]any{"ok": 6}},
+			{},
+		},
+	})
+	outcomes := localPathAggregates(series, "sum", []string{"status.ok", "status.error", "status.cout"}, 1)
+
+	payload, err := multiplePathsPayload(outcomes, 1)
+	if err != nil {
+		t.Fatalf("multiplePathsPayload error: %v", err)
+	}
+	if want := map[string]any{"status.ok": 10.0, "status.error": 1.0}; !reflect.DeepEqual(payload["value"], want) {
+		t.Fatalf("value = %v, want %v", payload["value"], want)
+	}
+	if want := map[string]any{"status.cout": "unknown path"}; !reflect.DeepEqual(payload["errors"], want) {
+		t.Fatalf("errors = %v, want %v", payload["errors"], want)
+	}
+	table := payload["table"].(map[string]any)
+	want := []any{[]any{"status.ok", 10.0}, []any{"status.error", 1.0}}
+	if !reflect.DeepEqual(table["rows"], want) || !reflect.DeepEqual(table["columns"], []any{"path", "value"}) {
+		t.Fatalf("table = %v, want rows %v", table, want)
+	}
+}
+
+func TestMultiplePathsPayloadAllFail(t *testing.T) {
+	t.Parallel()
+
+	_, err := multiplePathsPayload([]pathAggregate{
+		{Path: "count", Values: []any{}},
+		{Path: "cout", Err: fmt.Errorf("unknown path")},
+	}, 1)
+	if err == nil {
+		t.Fatal("expected an error when every path fails")
+	}
+}
+
+func TestValidateMultiplePaths(t *testing.T) {
+	t.Parallel()
+
+	if err := validateMultiplePaths([]string{"status.ok", "status.error"}, false, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for name, paths := range map[string][]string{
+		"wildcard": {"status.*", "count"},
+		"repeated": {"count", "count"},
+		"empty":    {"count", ""},
+	} {
+		if err := validateMultiplePaths(paths, false, false); err == nil {
+			t.Fatalf("%s: expected an error for %v", name, paths)
+		}
+	}
+	if err := validateMultiplePaths([]string{"a", "b"}, true, false); err == nil {
+		t.Fatal("expected an error with --compare")
+	}
+}
+
+func TestExprAggregatePayload(t *testing.T) {
+	t.Parallel()
+
+	expr, err := parseMetricExpr("status.error / (status.ok + status.error)")
+	if err != nil {
+		t.Fatalf("parseMetricExpr error: %v", err)
+	}
+	outcomes := []pathAggregate{
+		{Path: "status.error", Values: []any{1.0, nil, 0.0}},
+		{Path: "status.ok", Values: []any{3.0, 5.0, 0.0}},
+	}
+	payload, values, err := exprAggregatePayload(expr, outcomes, 3)
+	if err != nil {
+		t.Fatalf("exprAggregatePayload error: %v", err)
+	}
+	if want := []any{0.25, nil, nil}; !reflect.DeepEqual(values, want) {
+		t.Fatalf("values = %v, want %v", values, want)
+	}
+	table := payload["table"].(map[string]any)
+	if want := []any{"slice", "status.error", "status.ok", expr.Source}; !reflect.DeepEqual(table["columns"], want) {
+		t.Fatalf("columns = %v, want %v", table["columns"], want)
+	}
+	if want := []any{1, 1.0, 3.0, 0.25}; !reflect.DeepEqual(table["rows"].([]any)[0], want) {
+		t.Fatalf("first row = %v, want %v", table["rows"].([]any)[0], want)
+	}
+
+	if _, _, err := exprAggregatePayload(expr, []pathAggregate{
+		{Path: "status.error", Err: fmt.Errorf("unknown path")},
+		{Path: "status.ok", Err: fmt.Errorf("unknown path")},
+	}, 1); err == nil {
+		t.Fatal("expected an error when every path fails")
+	}
+}
@@ -0,0 +1,380 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	triflestats "github.com/trifle-io/trifle_stats_go"
+)
+
+const (
+	defaultBenchDuration    = 10 * time.Second
+	defaultBenchConcurrency = 4
+	defaultBenchPayload     = `{"count":1}`
+	defaultBenchReadRange   = "24h"
+)
+
+// benchRun is what the workers of one benchmark measured.
+type benchRun struct {
+	Latencies []time.Duration
+	Errors    int
+	FirstErr  error
+	Elapsed   time.Duration
+}
+
+// runBench calls op from concurrency workers until duration has passed and
+// records the latency of every call. op gets the worker index and the number
+// of calls that worker has made so far.
+func runBench(duration time.Duration, concurrency int, op func(worker, n int) error) benchRun {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	run := benchRun{}
+	start := time.Now()
+	deadline := start.Add(duration)
+
+	for worker := 0; worker < concurrency; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			var latencies []time.Duration
+			var errors int
+			var firstErr error
+			for n := 0; time.Now().Before(deadline); n++ {
+				started := time.Now()
+				err := op(worker, n)
+				latencies = append(latencies, time.Since(started))
+				if err != nil {
+					errors++
+					if firstErr == nil {
+						firstErr = err
+					}
+				}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			run.Latencies = append(run.Latencies, latencies...)
+			run.Errors += errors
+			if run.FirstErr == nil {
+				run.FirstErr = firstErr
+			}
+		}(worker)
+	}
+	wg.Wait()
+	run.Elapsed = time.Since(start)
+	return run
+}
+
+// latencyPercentile returns the nearest-rank percentile p (0-100) of sorted
+// latencies, or 0 when there are none.
+func latencyPercentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	rank = min(max(rank, 1), len(sorted))
+	return sorted[rank-1]
+}
+
+// benchLatency holds latencies in milliseconds.
+type benchLatency struct {
+	Mean float64 `json:"mean"`
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	P99  float64 `json:"p99"`
+	Max  float64 `json:"max"`
+}
+
+type benchReport struct {
+	Mode        string       `json:"mode"`
+	Driver      string       `json:"driver"`
+	Table       string       `json:"table,omitempty"`
+	Buffered    bool         `json:"buffered"`
+	Concurrency int          `json:"concurrency"`
+	Keys        []string     `json:"keys"`
+	Duration    float64      `json:"duration_seconds"`
+	Operations  int          `json:"operations"`
+	Errors      int          `json:"errors"`
+	FirstError  string       `json:"first_error,omitempty"`
+	PerSecond   float64      `json:"per_second"`
+	LatencyMS   benchLatency `json:"latency_ms"`
+	Flush       float64      `json:"flush_seconds,omitempty"`
+	Seeded      int          `json:"seeded_buckets,omitempty"`
+	Granularity string       `json:"granularity,omitempty"`
+	Deleted     *int64       `json:"deleted_rows,omitempty"`
+}
+
+// summarizeBench fills the counts, rate, and latencies of report from run.
+// flush is the time spent writing out a buffer after the workers stopped;
+// the rate counts it so buffered writes are not credited before they land.
+func summarizeBench(report *benchReport, run benchRun, flush time.Duration) {
+	sorted := append([]time.Duration(nil), run.Latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var total time.Duration
+	for _, latency := range sorted {
+		total += latency
+	}
+	milliseconds := func(d time.Duration) float64 {
+		return roundTo(float64(d)/float64(time.Millisecond), 3)
+	}
+
+	report.Operations = len(sorted)
+	report.Errors = run.Errors
+	if run.FirstErr != nil {
+		report.FirstError = run.FirstErr.Error()
+	}
+	elapsed := run.Elapsed + flush
+	report.Duration = roundTo(elapsed.Seconds(), 3)
+	if elapsed > 0 {
+		report.PerSecond = roundTo(float64(len(sorted)-run.Errors)/elapsed.Seconds(), 1)
+	}
+	if flush > 0 {
+		report.Flush = roundTo(flush.Seconds(), 3)
+	}
+	if len(sorted) > 0 {
+		report.LatencyMS = benchLatency{
+			Mean: milliseconds(total / time.Duration(len(sorted))),
+			P50:  milliseconds(latencyPercentile(sorted, 50)),
+			P90:  milliseconds(latencyPercentile(sorted, 90)),
+			P99:  milliseconds(latencyPercentile(sorted, 99)),
+			Max:  milliseconds(sorted[len(sorted)-1]),
+		}
+	}
+}
+
+func roundTo(value float64, places int) float64 {
+	scale := math.Pow(10, float64(places))
+	return math.Round(value*scale) / scale
+}
+
+// writeBenchSummary prints report for a person reading a terminal.
+func writeBenchSummary(w io.Writer, report benchReport) error {
+	noun := "writes"
+	if report.Mode == "read" {
+		noun = "reads"
+	}
+	buffered := "unbuffered"
+	if report.Buffered {
+		buffered = "buffered"
+	}
+	lines := []string{
+		fmt.Sprintf("%s benchmark on %s (%s), %d workers, %d keys", report.Mode, report.Driver, buffered, report.Concurrency, len(report.Keys)),
+		fmt.Sprintf("  %d %s in %.3gs: %.1f %s/sec", report.Operations, noun, report.Duration, report.PerSecond, noun),
+		fmt.Sprintf("  latency ms: mean %.3f, p50 %.3f, p90 %.3f, p99 %.3f, max %.3f",
+			report.LatencyMS.Mean, report.LatencyMS.P50, report.LatencyMS.P90, report.LatencyMS.P99, report.LatencyMS.Max),
+		fmt.Sprintf("  errors: %d", report.Errors),
+	}
+	if report.FirstError != "" {
+		lines = append(lines, "  first error: "+report.FirstError)
+	}
+	if report.Flush > 0 {
+		lines = append(lines, fmt.Sprintf("  buffer flush: %.3gs", report.Flush))
+	}
+	if report.Seeded > 0 {
+		lines = append(lines, fmt.Sprintf("  seeded %d %s buckets per key", report.Seeded, report.Granularity))
+	}
+	if report.Deleted != nil {
+		lines = append(lines, fmt.Sprintf("  cleanup: deleted %d rows", *report.Deleted))
+	}
+	_, err := io.WriteString(w, strings.Join(lines, "\n")+"\n")
+	return err
+}
+
+// cleanupBenchKeys deletes every bucket of keys stored between from and to
+// and takes the keys out of the system key, so metrics keys stops listing
+// them.
+func cleanupBenchKeys(cfg *triflestats.Config, keys []string, from, to time.Time) (int64, error) {
+	pruner, err := newMetricsPruner(cfg)
+	if err != nil {
+		return 0, err
+	}
+	var deleted int64
+	for _, key := range keys {
+		ranges, err := planPruneRanges(key, from, to, cfg.EffectiveGranularities(), cfg)
+		if err != nil {
+			return deleted, err
+		}
+		for _, r := range ranges {
+			n, err := pruner.delete(context.Background(), r)
+			deleted += n
+			if err != nil {
+				return deleted, err
+			}
+			if _, err := pruner.forget(context.Background(), r); err != nil {
+				return deleted, err
+			}
+		}
+	}
+	return deleted, nil
+}
+
+func metricsBench(args []string) {
+	rc, err := resolveCommandConfig(args)
+	if err != nil {
+		exitError(err)
+	}
+
+	fs := flag.NewFlagSet("metrics bench", flag.ExitOnError)
+	addConfigFlag(fs, rc.ConfigPath)
+	addSourceFlag(fs, rc.SourceName)
+	driverOpts := addDriverFlags(fs, &rc.Source)
+	mode := fs.String("mode", "write", "What to measure: write (Track) or read (Values)")
+	duration := fs.Duration("duration", defaultBenchDuration, "How long to run (e.g. 30s)")
+	concurrency := fs.Int("concurrency", defaultBenchConcurrency, "Number of concurrent workers")
+	payload := fs.String("payload", defaultBenchPayload, "Values tracked by each write, as JSON")
+	keyCount := fs.Int("keys", 0, "Number of synthetic keys (default: one per worker)")
+	keyPrefix := fs.String("key-prefix", "", "Prefix of the synthetic keys (default: bench::<unix time>)")
+	readRange := fs.String("range", defaultBenchReadRange, "Read mode: how far back the seeded range reaches (e.g. 24h, 7d)")
+	granularity := fs.String("granularity", "", "Read mode: granularity to seed and read (default: the first configured)")
+	cleanup := fs.Bool("cleanup", false, "Delete the synthetic keys when done")
+	format := fs.String("format", "json", "Output format: json|summary")
+	outputOpts := addOutputFlags(fs)
+	fs.Parse(args)
+
+	outputFormat := strings.ToLower(strings.TrimSpace(*format))
+	switch outputFormat {
+	case "json", "summary":
+	default:
+		exitError(usageErrorf("unsupported format %q (use json or summary)", *format))
+	}
+	if err := outputOpts.validate(outputFormat); err != nil {
+		exitError(err)
+	}
+	benchMode := strings.ToLower(strings.TrimSpace(*mode))
+	if benchMode != "write" && benchMode != "read" {
+		exitError(usageErrorf("unsupported --mode %q (use write or read)", *mode))
+	}
+	if !isLocalDriver(driverOpts.Driver) {
+		exitError(usageErrorf("metrics bench is only available for local drivers (sqlite/postgres/mysql/redis/mongo)"))
+	}
+	if *duration <= 0 {
+		exitError(usageErrorf("--duration must be positive"))
+	}
+	if *concurrency < 1 {
+		exitError(usageErrorf("--concurrency must be at least 1"))
+	}
+	if *keyCount < 0 {
+		exitError(usageErrorf("--keys cannot be negative"))
+	}
+	if *keyCount == 0 {
+		*keyCount = *concurrency
+	}
+	raw, err := readJSONPayload(os.Stdin, *payload, "")
+	if err != nil {
+		exitError(usageErrorf("invalid --payload: %v", err))
+	}
+	values, err := ensureValuesMap(raw)
+	if err != nil {
+		exitError(usageErrorf("invalid --payload: %v", err))
+	}
+	prefix := strings.TrimSpace(*keyPrefix)
+	if prefix == "" {
+		prefix = fmt.Sprintf("bench::%d", time.Now().Unix())
+	}
+	keys := make([]string, *keyCount)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("%s::%d", prefix, i)
+	}
+
+	local, err := prepareLocalConfig(driverOpts)
+	if err != nil {
+		exitError(err)
+	}
+	cfg := local.Config
+	if err := local.connect(driverOpts); err != nil {
+		exitError(err)
+	}
+	wrapDriverErr := func(err error) error {
+		return driverError(maybeSuggestSetup(err, local.DriverName, local.TableName))
+	}
+
+	report := benchReport{
+		Mode:        benchMode,
+		Driver:      local.DriverName,
+		Table:       local.TableName,
+		Buffered:    cfg.BufferEnabled,
+		Concurrency: *concurrency,
+		Keys:        keys,
+	}
+	cleanupFrom := time.Now()
+
+	var run benchRun
+	var flush time.Duration
+	if benchMode == "write" {
+		run = runBench(*duration, *concurrency, func(worker, n int) error {
+			key := keys[(worker+n*(*concurrency))%len(keys)]
+			return triflestats.Track(cfg, key, time.Now(), values)
+		})
+		started := time.Now()
+		if err := cfg.ShutdownBuffer(); err != nil {
+			exitError(wrapDriverErr(fmt.Errorf("flush buffer: %w", err)))
+		}
+		flush = time.Since(started)
+	} else {
+		granularityValue, err := resolveGranularityLocal(*granularity, cfg)
+		if err != nil {
+			exitError(err)
+		}
+		fromValue, toValue, err := resolveCommandTimeRange(&timeRangeOptions{Last: *readRange}, driverOpts)
+		if err != nil {
+			exitError(err)
+		}
+		fromTime, err := time.Parse(time.RFC3339Nano, fromValue)
+		if err != nil {
+			exitError(err)
+		}
+		toTime, err := time.Parse(time.RFC3339Nano, toValue)
+		if err != nil {
+			exitError(err)
+		}
+		cleanupFrom = fromTime
+
+		parser := triflestats.NewParser(granularityValue)
+		buckets := triflestats.Timeline(fromTime, toTime, parser.Offset, parser.Unit, cfg)
+		for _, key := range keys {
+			for _, at := range buckets {
+				if err := triflestats.Track(cfg, key, at, values); err != nil {
+					exitError(wrapDriverErr(fmt.Errorf("seed %s: %w", key, err)))
+				}
+			}
+		}
+		if err := cfg.ShutdownBuffer(); err != nil {
+			exitError(wrapDriverErr(fmt.Errorf("flush buffer: %w", err)))
+		}
+		report.Seeded = len(buckets)
+		report.Granularity = granularityValue
+
+		run = runBench(*duration, *concurrency, func(worker, n int) error {
+			key := keys[(worker+n*(*concurrency))%len(keys)]
+			_, err := triflestats.Values(cfg, key, fromTime, toTime, granularityValue, false)
+			return err
+		})
+	}
+	summarizeBench(&report, run, flush)
+
+	if *cleanup {
+		deleted, err := cleanupBenchKeys(cfg, keys, cleanupFrom, time.Now())
+		if err != nil {
+			exitError(wrapDriverErr(fmt.Errorf("cleanup stopped after %d rows: %w", deleted, err)))
+		}
+		report.Deleted = &deleted
+	}
+
+	if outputFormat == "summary" {
+		err = writeCommandOutput(outputOpts, func(w io.Writer) error { return writeBenchSummary(w, report) })
+	} else {
+		err = writeJSONOutput(outputOpts, map[string]any{"data": report})
+	}
+	if err != nil {
+		exitError(err)
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	triflestats "github.com/trifle-io/trifle_stats_go"
+)
+
+func TestLatencyPercentile(t *testing.T) {
+	t.Parallel()
+
+	sorted := make([]time.Duration, 100)
+	for i := range sorted {
+		sorted[i] = time.Duration(i+1) * time.Millisecond
+	}
+	tests := []struct {
+		p    float64
+		want time.Duration
+	}{
+		{p: 50, want: 50 * time.Millisecond},
+		{p: 99, want: 99 * time.Millisecond},
+		{p: 100, want: 100 * time.Millisecond},
+		{p: 0, want: time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := latencyPercentile(sorted, tt.p); got != tt.want {
+			t.Fatalf("latencyPercentile(p%v) = %s, want %s", tt.p, got, tt.want)
+		}
+	}
+	if got := latencyPercentile(nil, 50); got != 0 {
+		t.Fatalf("latencyPercentile(nil) = %s, want 0", got)
+	}
+}
+
+func TestRunBenchCountsOperationsAndErrors(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int64
+	failure := errors.New("boom")
+	run := runBench(20*time.Millisecond, 3, func(worker, n int) error {
+		calls.Add(1)
+		if n%2 == 1 {
+			return failure
+		}
+		return nil
+	})
+	if len(run.Latencies) != int(calls.Load()) {
+		t.Fatalf("latencies = %d, want one per call (%d)", len(run.Latencies), calls.Load())
+	}
+	if run.Errors == 0 || !errors.Is(run.FirstErr, failure) {
+		t.Fatalf("errors = %d, first = %v", run.Errors, run.FirstErr)
+	}
+	if run.Elapsed < 20*time.Millisecond {
+		t.Fatalf("elapsed = %s, want at least the duration", run.Elapsed)
+	}
+}
+
+func TestSummarizeBench(t *testing.T) {
+	t.Parallel()
+
+	run := benchRun{
+		Latencies: []time.Duration{4 * time.Millisecond, time.Millisecond, 2 * time.Millisecond, 3 * time.Millisecond},
+		Errors:    1,
+		FirstErr:  errors.New("locked"),
+		Elapsed:   1500 * time.Millisecond,
+	}
+	report := benchReport{Mode: "write", Driver: "sqlite", Concurrency: 2, Keys: []string{"a", "b"}}
+	summarizeBench(&report, run, 500*time.Millisecond)
+
+	if report.Operations != 4 || report.Errors != 1 || report.FirstError != "locked" {
+		t.Fatalf("unexpected counts: %#v", report)
+	}
+	if report.PerSecond != 1.5 || report.Duration != 2 || report.Flush != 0.5 {
+		t.Fatalf("rate = %v over %vs (flush %v), want 1.5 over 2s", report.PerSecond, report.Duration, report.Flush)
+	}
+	want := benchLatency{Mean: 2.5, P50: 2, P90: 4, P99: 4, Max: 4}
+	if report.LatencyMS != want {
+		t.Fatalf("latency = %#v, want %#v", report.LatencyMS, want)
+	}
+
+	var out bytes.Buffer
+	if err := writeBenchSummary(&out, report); err != nil {
+		t.Fatalf("writeBenchSummary: %v", err)
+	}
+	for _, fragment := range []string{"4 writes in 2s: 1.5 writes/sec", "p99 4.000", "errors: 1", "first error: locked"} {
+		if !strings.Contains(out.String(), fragment) {
+			t.Fatalf("summary missing %q:\n%s", fragment, out.String())
+		}
+	}
+}
+
+func TestCleanupBenchKeys(t *testing.T) {
+	t.Parallel()
+
+	local, err := loadLocalConfig(&driverOptions{
+		Driver:          "sqlite",
+		DBPath:          filepath.Join(t.TempDir(), "stats.db"),
+		Table:           "metrics",
+		Joined:          "full",
+		Separator:       "::",
+		TimeZone:        "UTC",
+		BeginningOfWeek: "monday",
+		Granularities:   "1h,1d",
+		BufferMode:      "off",
+	})
+	if err != nil {
+		t.Fatalf("loadLocalConfig returned error: %v", err)
+	}
+	if err := local.Setup(); err != nil {
+		t.Fatalf("local.Setup returned error: %v", err)
+	}
+	cfg := local.Config
+
+	keys := []string{"bench::1::0", "bench::1::1"}
+	for _, at := range hourlyBuckets(3) {
+		for _, key := range append(keys, "event::logs") {
+			if err := triflestats.Track(cfg, key, at, map[string]any{"count": 1}); err != nil {
+				t.Fatalf("Track returned error: %v", err)
+			}
+		}
+	}
+
+	buckets := hourlyBuckets(3)
+	deleted, err := cleanupBenchKeys(cfg, keys, buckets[0], buckets[2])
+	if err != nil {
+		t.Fatalf("cleanupBenchKeys returned error: %v", err)
+	}
+	// Three hourly rows and one daily row per key.
+	if deleted != 8 {
+		t.Fatalf("deleted = %d, want 8", deleted)
+	}
+	result, err := triflestats.Values(cfg, "event::logs", buckets[0], buckets[2], "1h", true)
+	if err != nil {
+		t.Fatalf("Values returned error: %v", err)
+	}
+	if len(result.At) != 3 {
+		t.Fatalf("event::logs buckets = %d, want 3 left untouched", len(result.At))
+	}
+	for _, granularity := range []string{"1h", "1d"} {
+		if got := listedKeys(t, cfg, buckets[0], buckets[2], granularity); !reflect.DeepEqual(got, []string{"event::logs"}) {
+			t.Fatalf("metrics keys lists %v in %s after cleanup, want only event::logs", got, granularity)
+		}
+	}
+}
+
+// listedKeys returns the keys metrics keys would list for from..to.
+func listedKeys(t *testing.T, cfg *triflestats.Config, from, to time.Time, granularity string) []string {
+	t.Helper()
+	result, err := triflestats.Values(cfg, systemMetricsKey, from, to, granularity, true)
+	if err != nil {
+		t.Fatalf("Values returned error: %v", err)
+	}
+	return keysEntryNames(summarizeSystemKeys(result.Values))
+}
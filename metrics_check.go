@@ -0,0 +1,362 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	triflestats "github.com/trifle-io/trifle_stats_go"
+)
+
+// Check exit codes follow the Nagios plugin convention.
+const (
+	checkOK       = 0
+	checkWarning  = 1
+	checkCritical = 2
+	checkUnknown  = 3
+)
+
+var checkStateNames = map[int]string{
+	checkOK:       "OK",
+	checkWarning:  "WARNING",
+	checkCritical: "CRITICAL",
+	checkUnknown:  "UNKNOWN",
+}
+
+// checkThresholds holds the parsed --warn/--crit/--compare/--missing-ok flags.
+type checkThresholds struct {
+	Warn      *float64
+	Crit      *float64
+	Below     bool
+	MissingOK bool
+}
+
+func parseThreshold(name, value string) (*float64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, usageErrorf("--%s must be a number", name)
+	}
+	return &number, nil
+}
+
+func newCheckThresholds(warn, crit, compare string, missingOK bool) (checkThresholds, error) {
+	t := checkThresholds{MissingOK: missingOK}
+	var err error
+	if t.Warn, err = parseThreshold("warn", warn); err != nil {
+		return t, err
+	}
+	if t.Crit, err = parseThreshold("crit", crit); err != nil {
+		return t, err
+	}
+	if t.Warn == nil && t.Crit == nil {
+		return t, usageErrorf("at least one of --warn or --crit is required")
+	}
+
+	switch strings.ToLower(strings.TrimSpace(compare)) {
+	case "", "gt":
+	case "lt":
+		t.Below = true
+	default:
+		return t, usageErrorf("unsupported --compare %q (use gt or lt)", compare)
+	}
+
+	if t.Warn != nil && t.Crit != nil {
+		if !t.Below && *t.Crit < *t.Warn {
+			return t, usageErrorf("--crit must be at least --warn with --compare gt")
+		}
+		if t.Below && *t.Crit > *t.Warn {
+			return t, usageErrorf("--crit must be at most --warn with --compare lt")
+		}
+	}
+	return t, nil
+}
+
+func (t checkThresholds) breached(value, threshold float64) bool {
+	if t.Below {
+		return value < threshold
+	}
+	return value > threshold
+}
+
+// evaluate returns the check state for an aggregated value; nil means the
+// window had no data.
+func (t checkThresholds) evaluate(value any) int {
+	number, ok := triflestats.NormalizeNumeric(value).(float64)
+	if !ok {
+		if t.MissingOK {
+			return checkOK
+		}
+		return checkCritical
+	}
+	if t.Crit != nil && t.breached(number, *t.Crit) {
+		return checkCritical
+	}
+	if t.Warn != nil && t.breached(number, *t.Warn) {
+		return checkWarning
+	}
+	return checkOK
+}
+
+// checkResult is the outcome of one threshold check.
+type checkResult struct {
+	State      string   `json:"state"`
+	Code       int      `json:"code"`
+	Key        string   `json:"key"`
+	ValuePath  string   `json:"value_path"`
+	Aggregator string   `json:"aggregator"`
+	Value      any      `json:"value"`
+	Warn       *float64 `json:"warn,omitempty"`
+	Crit       *float64 `json:"crit,omitempty"`
+	Compare    string   `json:"compare"`
+	From       string   `json:"from"`
+	To         string   `json:"to"`
+}
+
+func newCheckResult(t checkThresholds, key, valuePath, aggregator string, value any, fromValue, toValue string) checkResult {
+	code := t.evaluate(value)
+	compare := "gt"
+	if t.Below {
+		compare = "lt"
+	}
+	return checkResult{
+		State:      checkStateNames[code],
+		Code:       code,
+		Key:        key,
+		ValuePath:  valuePath,
+		Aggregator: aggregator,
+		Value:      triflestats.NormalizeNumeric(value),
+		Warn:       t.Warn,
+		Crit:       t.Crit,
+		Compare:    compare,
+		From:       fromValue,
+		To:         toValue,
+	}
+}
+
+// line renders the result as a one-line status with Nagios perfdata.
+func (r checkResult) line() string {
+	formatThreshold := func(value *float64) string {
+		if value == nil {
+			return ""
+		}
+		return strconv.FormatFloat(*value, 'f', -1, 64)
+	}
+	if r.Value == nil {
+		return fmt.Sprintf("%s - %s %s %s: no data between %s and %s", r.State, r.Key, r.ValuePath, r.Aggregator, r.From, r.To)
+	}
+	value := fmt.Sprint(r.Value)
+	if number, ok := r.Value.(float64); ok {
+		value = strconv.FormatFloat(number, 'f', -1, 64)
+	}
+	var limits []string
+	if r.Warn != nil {
+		limits = append(limits, "warn "+formatThreshold(r.Warn))
+	}
+	if r.Crit != nil {
+		limits = append(limits, "crit "+formatThreshold(r.Crit))
+	}
+	return fmt.Sprintf("%s - %s %s %s = %s (%s, %s) | %s=%s;%s;%s",
+		r.State, r.Key, r.ValuePath, r.Aggregator, value, strings.Join(limits, ", "), r.Compare,
+		r.ValuePath, value, formatThreshold(r.Warn), formatThreshold(r.Crit))
+}
+
+// seriesHasPath reports whether any bucket holds a numeric value at path.
+// Sum and friends aggregate an empty window to 0, which would otherwise hide
+// a metric that stopped reporting.
+func seriesHasPath(series triflestats.Series, path string) bool {
+	for _, row := range series.Values {
+		if _, ok := triflestats.NormalizeNumeric(triflestats.FetchPath(row, path)).(float64); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// exitCheckUnknown reports err as an UNKNOWN check and exits 3, so
+// monitoring never mistakes a failed query for a threshold breach.
+func exitCheckUnknown(err error) {
+	fmt.Fprintf(os.Stdout, "UNKNOWN - %v\n", err)
+	os.Exit(checkUnknown)
+}
+
+func metricsCheck(args []string) {
+	rc, err := resolveCommandConfig(args)
+	if err != nil {
+		exitCheckUnknown(err)
+	}
+
+	// A mistyped flag must not exit 2, which monitoring reads as CRITICAL.
+	fs := flag.NewFlagSet("metrics check", flag.ContinueOnError)
+	addConfigFlag(fs, rc.ConfigPath)
+	addSourceFlag(fs, rc.SourceName)
+	opts := addCommonFlags(fs, &rc.Source)
+	driverOpts := addDriverFlags(fs, &rc.Source)
+	key := fs.String("key", "", "Metrics key")
+	valuePath := fs.String("value-path", "", "Value path to aggregate")
+	aggregator := fs.String("aggregator", "", "Aggregator (sum|mean|min|max|stddev|variance|p50|p90|p95|p99)")
+	timeRange := addTimeRangeFlags(fs)
+	granularity := fs.String("granularity", "", "Granularity (e.g. 1h, 1d)")
+	warn := fs.String("warn", "", "Exit 1 (WARNING) when the value passes this threshold")
+	crit := fs.String("crit", "", "Exit 2 (CRITICAL) when the value passes this threshold")
+	compare := fs.String("compare", "gt", "Direction: gt (above the threshold is bad) or lt (below is bad)")
+	missingOK := fs.Bool("missing-ok", false, "Treat a window without data as OK instead of CRITICAL")
+	format := fs.String("format", "text", "Output format: text|json")
+	explain := addExplainFlag(fs)
+	outputOpts := addOutputFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		exitCheckUnknown(usageErrorf("%v", err))
+	}
+
+	outputFormat := strings.ToLower(strings.TrimSpace(*format))
+	if outputFormat != "text" && outputFormat != "json" {
+		exitCheckUnknown(usageErrorf("unsupported format %q (use text or json)", *format))
+	}
+	if err := outputOpts.validate(outputFormat); err != nil {
+		exitCheckUnknown(err)
+	}
+	if *key == "" || *valuePath == "" || *aggregator == "" {
+		exitCheckUnknown(usageErrorf("--key, --value-path, and --aggregator are required"))
+	}
+	if err := ensureNoWildcards(*valuePath); err != nil {
+		exitCheckUnknown(usageErrorf("%v", err))
+	}
+	thresholds, err := newCheckThresholds(*warn, *crit, *compare, *missingOK)
+	if err != nil {
+		exitCheckUnknown(err)
+	}
+	aggName := strings.ToLower(strings.TrimSpace(*aggregator))
+
+	var fromValue, toValue string
+	var value any
+
+	if isLocalDriver(driverOpts.Driver) {
+		local, err := prepareLocalConfig(driverOpts)
+		if err != nil {
+			exitCheckUnknown(err)
+		}
+		cfg := local.Config
+
+		timeRange.Default = rc.Source.DefaultTimeframe
+		fromValue, toValue, err = resolveCommandTimeRange(timeRange, driverOpts)
+		if err != nil {
+			exitCheckUnknown(err)
+		}
+		granularityValue, err := resolveGranularityLocal(*granularity, cfg)
+		if err != nil {
+			exitCheckUnknown(err)
+		}
+
+		if *explain {
+			plan := localPlan("metrics check", local, driverOpts, map[string]any{
+				"key":         *key,
+				"value_path":  *valuePath,
+				"aggregator":  aggName,
+				"from":        fromValue,
+				"to":          toValue,
+				"granularity": granularityValue,
+			})
+			if err := writeJSONOutput(outputOpts, plan); err != nil {
+				exitCheckUnknown(err)
+			}
+			return
+		}
+		if err := local.connect(driverOpts); err != nil {
+			exitCheckUnknown(err)
+		}
+
+		fromTime, err := time.Parse(time.RFC3339Nano, fromValue)
+		if err != nil {
+			exitCheckUnknown(err)
+		}
+		toTime, err := time.Parse(time.RFC3339Nano, toValue)
+		if err != nil {
+			exitCheckUnknown(err)
+		}
+		result, err := triflestats.Values(cfg, *key, fromTime, toTime, granularityValue, false)
+		if err != nil {
+			exitCheckUnknown(maybeSuggestSetup(err, local.DriverName, local.TableName))
+		}
+		series := triflestats.SeriesFromResult(result)
+		aggregated, err := aggregateSeriesPath(series, aggName, *valuePath, 1)
+		if err != nil {
+			exitCheckUnknown(err)
+		}
+		if seriesHasPath(series, *valuePath) {
+			value = firstAggregateValue(aggregated)
+		}
+	} else {
+		if !*explain {
+			if err := ensureToken(opts, false); err != nil {
+				exitCheckUnknown(err)
+			}
+		}
+		client, err := newClient(opts)
+		if err != nil {
+			exitCheckUnknown(err)
+		}
+		source := newSourceLookup(client)
+
+		fromValue, toValue, err = resolveSourceTimeRange(context.Background(), source, timeRange, driverOpts)
+		if err != nil {
+			exitCheckUnknown(err)
+		}
+		granularityValue, err := resolveGranularityValue(context.Background(), source, *granularity)
+		if err != nil {
+			exitCheckUnknown(err)
+		}
+		payload := map[string]any{
+			"mode":        "aggregate",
+			"key":         *key,
+			"value_path":  *valuePath,
+			"aggregator":  aggName,
+			"from":        fromValue,
+			"to":          toValue,
+			"granularity": granularityValue,
+			"slices":      1,
+		}
+
+		if *explain {
+			request, err := explainQueryMetrics(client, payload, driverOpts.BeginningOfWeek)
+			if err != nil {
+				exitCheckUnknown(err)
+			}
+			if err := writeJSONOutput(outputOpts, apiPlan("metrics check", opts, request)); err != nil {
+				exitCheckUnknown(err)
+			}
+			return
+		}
+
+		data, err := queryMetrics(context.Background(), client, payload, driverOpts.BeginningOfWeek)
+		if err != nil {
+			exitCheckUnknown(err)
+		}
+		value = firstAggregateValue(aggregateResponseValues(data))
+	}
+
+	result := newCheckResult(thresholds, *key, *valuePath, aggName, value, fromValue, toValue)
+	if outputFormat == "json" {
+		err = writeJSONOutput(outputOpts, map[string]any{"data": result})
+	} else {
+		err = writeCommandOutput(outputOpts, func(w io.Writer) error {
+			_, err := fmt.Fprintln(w, result.line())
+			return err
+		})
+	}
+	if err != nil {
+		exitCheckUnknown(err)
+	}
+	os.Exit(result.Code)
+}
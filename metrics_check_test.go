@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	triflestats "github.com/trifle-io/trifle_stats_go"
+)
+
+func TestCheckThresholdsEvaluate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		warn      string
+		crit      string
+		compare   string
+		missingOK bool
+		value     any
+		want      int
+	}{
+		{name: "ok below warn", warn: "100", crit: "500", value: 42, want: checkOK},
+		{name: "equal to warn is ok", warn: "100", crit: "500", value: 100.0, want: checkOK},
+		{name: "warning", warn: "100", crit: "500", value: 101, want: checkWarning},
+		{name: "critical", warn: "100", crit: "500", value: 612, want: checkCritical},
+		{name: "crit only", crit: "500", value: 300, want: checkOK},
+		{name: "warn only", warn: "100", value: 900, want: checkWarning},
+		{name: "lt ok", warn: "10", crit: "5", compare: "lt", value: 12, want: checkOK},
+		{name: "lt warning", warn: "10", crit: "5", compare: "lt", value: 7, want: checkWarning},
+		{name: "lt critical", warn: "10", crit: "5", compare: "lt", value: 0, want: checkCritical},
+		{name: "missing is critical", warn: "100", crit: "500", value: nil, want: checkCritical},
+		{name: "missing ok", warn: "100", crit: "500", missingOK: true, value: nil, want: checkOK},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			thresholds, err := newCheckThresholds(tt.warn, tt.crit, tt.compare, tt.missingOK)
+			if err != nil {
+				t.Fatalf("newCheckThresholds returned error: %v", err)
+			}
+			if got := thresholds.evaluate(tt.value); got != tt.want {
+				t.Fatalf("evaluate(%v) = %d, want %d", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewCheckThresholdsErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		warn    string
+		crit    string
+		compare string
+	}{
+		{name: "no thresholds"},
+		{name: "not a number", warn: "lots"},
+		{name: "unknown compare", warn: "1", compare: "eq"},
+		{name: "crit below warn", warn: "500", crit: "100"},
+		{name: "lt crit above warn", warn: "5", crit: "10", compare: "lt"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := newCheckThresholds(tt.warn, tt.crit, tt.compare, false); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestCheckResultLine(t *testing.T) {
+	t.Parallel()
+
+	thresholds, err := newCheckThresholds("100", "500", "gt", false)
+	if err != nil {
+		t.Fatalf("newCheckThresholds returned error: %v", err)
+	}
+
+	result := newCheckResult(thresholds, "event::errors", "count", "sum", 612, "2026-10-15T09:00:00Z", "2026-10-15T10:00:00Z")
+	if result.Code != checkCritical || result.State != "CRITICAL" {
+		t.Fatalf("result = %s (%d), want CRITICAL (2)", result.State, result.Code)
+	}
+	want := "CRITICAL - event::errors count sum = 612 (warn 100, crit 500, gt) | count=612;100;500"
+	if got := result.line(); got != want {
+		t.Fatalf("line = %q, want %q", got, want)
+	}
+
+	missing := newCheckResult(thresholds, "event::errors", "count", "sum", nil, "2026-10-15T09:00:00Z", "2026-10-15T10:00:00Z")
+	want = "CRITICAL - event::errors count sum: no data between 2026-10-15T09:00:00Z and 2026-10-15T10:00:00Z"
+	if got := missing.line(); got != want {
+		t.Fatalf("line = %q, want %q", got, want)
+	}
+}
+
+func TestSeriesHasPath(t *testing.T) {
+	t.Parallel()
+
+	series := triflestats.Series{
+		At:     hourlyBuckets(2),
+		Values: []map[string]any{{}, {"count": 3}},
+	}
+	if !seriesHasPath(series, "count") {
+		t.Fatal("expected count to be present")
+	}
+	if seriesHasPath(series, "duration") {
+		t.Fatal("expected duration to be missing")
+	}
+	if seriesHasPath(triflestats.Series{}, "count") {
+		t.Fatal("expected an empty series to have no data")
+	}
+}
+
+// TestMetricsCheckBadFlagIsUnknown runs metrics check in a child process,
+// since it exits, to see a mistyped flag exit 3 rather than 2 (CRITICAL).
+func TestMetricsCheckBadFlagIsUnknown(t *testing.T) {
+	if os.Getenv("TRIFLE_CHECK_CHILD") == "1" {
+		metricsCheck([]string{"--config", os.DevNull, "--key", "event::logs", "--crti", "5"})
+		return
+	}
+	t.Parallel()
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestMetricsCheckBadFlagIsUnknown$")
+	cmd.Env = append(os.Environ(), "TRIFLE_CHECK_CHILD=1")
+	out, err := cmd.Output()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != checkUnknown {
+		t.Fatalf("metrics check --crti exited with %v, want %d (UNKNOWN)", err, checkUnknown)
+	}
+	if !strings.HasPrefix(string(out), "UNKNOWN - flag provided but not defined: -crti") {
+		t.Fatalf("output = %q, want an UNKNOWN line", out)
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/trifle-io/trifle-cli/internal/api"
+	"github.com/trifle-io/trifle-cli/internal/output"
+	triflestats "github.com/trifle-io/trifle_stats_go"
+)
+
+type timeChunk struct {
+	From time.Time
+	To   time.Time
+}
+
+// resolveChunkPoints returns the number of buckets per sub-query, or 0 when
+// the range fits in a single query. An explicit chunkSize always wins;
+// otherwise ranges estimated above maxPoints are split into maxPoints chunks.
+func resolveChunkPoints(chunkSize, maxPoints int, from, to, granularity string) int {
+	if chunkSize > 0 {
+		return chunkSize
+	}
+	if maxPoints <= 0 {
+		return 0
+	}
+	if estimateTimeframePoints(from, to, granularity) <= int64(maxPoints) {
+		return 0
+	}
+	return maxPoints
+}
+
+// planChunks splits from..to into consecutive ranges of at most chunkPoints
+// buckets. Each chunk ends on its last bucket start, which Values includes.
+func planChunks(from, to time.Time, granularity string, cfg *triflestats.Config, chunkPoints int) []timeChunk {
+	parser := triflestats.NewParser(granularity)
+	if chunkPoints <= 0 || !parser.Valid() {
+		return []timeChunk{{From: from, To: to}}
+	}
+
+	buckets := triflestats.Timeline(from, to, parser.Offset, parser.Unit, cfg)
+	if len(buckets) <= chunkPoints {
+		return []timeChunk{{From: from, To: to}}
+	}
+
+	chunks := make([]timeChunk, 0, (len(buckets)+chunkPoints-1)/chunkPoints)
+	for start := 0; start < len(buckets); start += chunkPoints {
+		end := start + chunkPoints - 1
+		if end >= len(buckets) {
+			end = len(buckets) - 1
+		}
+		chunk := timeChunk{From: buckets[start], To: buckets[end]}
+		if start == 0 {
+			chunk.From = from
+		}
+		if end == len(buckets)-1 {
+			chunk.To = to
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+// fetchChunked runs fetch for each chunk in order and stitches the results.
+func fetchChunked(chunks []timeChunk, fetch func(from, to time.Time) (triflestats.ValuesResult, error)) (triflestats.ValuesResult, error) {
+	merged := triflestats.ValuesResult{At: []time.Time{}, Values: []map[string]any{}}
+	err := streamChunked(chunks, fetch, func(at time.Time, values map[string]any) error {
+		merged.At = append(merged.At, at)
+		merged.Values = append(merged.Values, values)
+		return nil
+	})
+	if err != nil {
+		return triflestats.ValuesResult{}, err
+	}
+	return merged, nil
+}
+
+// streamChunked runs fetch for each chunk in order and hands every bucket to
+// emit as soon as its chunk arrives. Buckets that are not after the last one
+// emitted are dropped so overlapping boundaries appear once.
+func streamChunked(chunks []timeChunk, fetch func(from, to time.Time) (triflestats.ValuesResult, error), emit func(at time.Time, values map[string]any) error) error {
+	var last time.Time
+	emitted := false
+	for _, chunk := range chunks {
+		result, err := fetch(chunk.From, chunk.To)
+		if err != nil {
+			return err
+		}
+		for i, at := range result.At {
+			if emitted && !at.After(last) {
+				continue
+			}
+			var values map[string]any
+			if i < len(result.Values) {
+				values = result.Values[i]
+			}
+			if err := emit(at, values); err != nil {
+				return err
+			}
+			last = at
+			emitted = true
+		}
+	}
+	return nil
+}
+
+// fetchMetricsChunked splits an API GetMetrics call into sequential chunks.
+func fetchMetricsChunked(ctx context.Context, client *api.Client, params map[string]string, driverOpts *driverOptions, chunkPoints int) (triflestats.ValuesResult, error) {
+	result, _, err := fetchMetricsResponseChunked(ctx, client, params, driverOpts, chunkPoints)
+	return result, err
+}
+
+// fetchMetricsResponseChunked is fetchMetricsChunked that also returns the
+// first chunk's top-level response fields other than data.
+func fetchMetricsResponseChunked(ctx context.Context, client *api.Client, params map[string]string, driverOpts *driverOptions, chunkPoints int) (triflestats.ValuesResult, map[string]any, error) {
+	merged := triflestats.ValuesResult{At: []time.Time{}, Values: []map[string]any{}}
+	fields := map[string]any{}
+	err := streamMetricsChunks(ctx, client, params, driverOpts, chunkPoints, fields, func(at time.Time, values map[string]any) error {
+		merged.At = append(merged.At, at)
+		merged.Values = append(merged.Values, values)
+		return nil
+	})
+	if err != nil {
+		return triflestats.ValuesResult{}, nil, err
+	}
+	return merged, fields, nil
+}
+
+// streamMetricsChunked streams API GetMetrics buckets chunk by chunk. Buckets
+// are planned with the CLI time zone and week start; if the server buckets
+// differently, overlapping boundaries are dropped.
+func streamMetricsChunked(ctx context.Context, client *api.Client, params map[string]string, driverOpts *driverOptions, chunkPoints int, emit func(at time.Time, values map[string]any) error) error {
+	return streamMetricsChunks(ctx, client, params, driverOpts, chunkPoints, nil, emit)
+}
+
+// streamMetricsChunks does the work of streamMetricsChunked. When fields is
+// not nil it receives the first chunk's top-level fields other than data.
+func streamMetricsChunks(ctx context.Context, client *api.Client, params map[string]string, driverOpts *driverOptions, chunkPoints int, fields map[string]any, emit func(at time.Time, values map[string]any) error) error {
+	fromTime, err := time.Parse(time.RFC3339Nano, params["from"])
+	if err != nil {
+		return err
+	}
+	toTime, err := time.Parse(time.RFC3339Nano, params["to"])
+	if err != nil {
+		return err
+	}
+	cfg, err := bucketConfig(driverOpts)
+	if err != nil {
+		return err
+	}
+
+	chunks := planChunks(fromTime, toTime, params["granularity"], cfg, chunkPoints)
+	first := true
+	return streamChunked(chunks, func(from, to time.Time) (triflestats.ValuesResult, error) {
+		chunkParams := make(map[string]string, len(params))
+		for key, value := range params {
+			chunkParams[key] = value
+		}
+		chunkParams["from"] = from.UTC().Format(time.RFC3339Nano)
+		chunkParams["to"] = to.UTC().Format(time.RFC3339Nano)
+
+		var response map[string]json.RawMessage
+		if err := getMetrics(ctx, client, chunkParams, driverOpts.BeginningOfWeek, &response); err != nil {
+			return triflestats.ValuesResult{}, err
+		}
+		var data triflestats.ValuesResult
+		if raw, ok := response["data"]; ok {
+			if err := json.Unmarshal(raw, &data); err != nil {
+				return triflestats.ValuesResult{}, err
+			}
+		}
+		if first && fields != nil {
+			for name, raw := range response {
+				if name == "data" {
+					continue
+				}
+				var value any
+				if err := json.Unmarshal(raw, &value); err != nil {
+					return triflestats.ValuesResult{}, err
+				}
+				fields[name] = value
+			}
+		}
+		first = false
+		return data, nil
+	}, emit)
+}
+
+type seriesPoint struct {
+	At     time.Time      `json:"at"`
+	Values map[string]any `json:"values"`
+}
+
+// ndjsonPoints writes each bucket as its own JSON line.
+func ndjsonPoints(writer *output.NDJSONWriter) func(at time.Time, values map[string]any) error {
+	return func(at time.Time, values map[string]any) error {
+		return writer.Write(seriesPoint{At: at, Values: values})
+	}
+}
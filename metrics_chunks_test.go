@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/trifle-io/trifle-cli/internal/api"
+	"github.com/trifle-io/trifle-cli/internal/output"
+	triflestats "github.com/trifle-io/trifle_stats_go"
+)
+
+func TestResolveChunkPoints(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		chunkSize, maxPoints int
+		from, to             string
+		granularity          string
+		want                 int
+	}{
+		{0, 10000, "2026-01-01T00:00:00Z", "2026-01-31T00:00:00Z", "1h", 0},
+		{0, 10000, "2025-01-01T00:00:00Z", "2026-01-01T00:00:00Z", "1m", 10000},
+		{0, 0, "2025-01-01T00:00:00Z", "2026-01-01T00:00:00Z", "1m", 0},
+		{24, 10000, "2026-01-01T00:00:00Z", "2026-01-31T00:00:00Z", "1h", 24},
+		{0, 100, "2026-01-01T00:00:00Z", "2026-01-31T00:00:00Z", "1h", 100},
+	}
+
+	for _, tt := range cases {
+		if got := resolveChunkPoints(tt.chunkSize, tt.maxPoints, tt.from, tt.to, tt.granularity); got != tt.want {
+			t.Fatalf("resolveChunkPoints(%d, %d, %s, %s, %s) = %d, want %d", tt.chunkSize, tt.maxPoints, tt.from, tt.to, tt.granularity, got, tt.want)
+		}
+	}
+}
+
+func TestPlanChunks(t *testing.T) {
+	t.Parallel()
+
+	cfg := triflestats.DefaultConfig()
+	from := time.Date(2026, 1, 1, 0, 30, 0, 0, time.UTC)
+	to := time.Date(2026, 1, 1, 9, 15, 0, 0, time.UTC)
+
+	chunks := planChunks(from, to, "1h", cfg, 4)
+	want := []timeChunk{
+		{From: from, To: time.Date(2026, 1, 1, 3, 0, 0, 0, time.UTC)},
+		{From: time.Date(2026, 1, 1, 4, 0, 0, 0, time.UTC), To: time.Date(2026, 1, 1, 7, 0, 0, 0, time.UTC)},
+		{From: time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC), To: to},
+	}
+	if len(chunks) != len(want) {
+		t.Fatalf("planChunks returned %d chunks, want %d: %v", len(chunks), len(want), chunks)
+	}
+	for i := range want {
+		if !chunks[i].From.Equal(want[i].From) || !chunks[i].To.Equal(want[i].To) {
+			t.Fatalf("chunk %d = %s..%s, want %s..%s", i, chunks[i].From, chunks[i].To, want[i].From, want[i].To)
+		}
+	}
+
+	if chunks := planChunks(from, to, "1h", cfg, 10); len(chunks) != 1 {
+		t.Fatalf("planChunks with room to spare returned %d chunks, want 1", len(chunks))
+	}
+	if chunks := planChunks(from, to, "1h", cfg, 0); len(chunks) != 1 {
+		t.Fatalf("planChunks without a chunk size returned %d chunks, want 1", len(chunks))
+	}
+}
+
+func TestFetchChunkedMergesBoundaries(t *testing.T) {
+	t.Parallel()
+
+	hour := func(h int) time.Time { return time.Date(2026, 1, 1, h, 0, 0, 0, time.UTC) }
+	value := func(n int) map[string]any { return map[string]any{"count": n} }
+
+	results := []triflestats.ValuesResult{
+		{At: []time.Time{hour(0), hour(1), hour(2)}, Values: []map[string]any{value(0), value(1), value(2)}},
+		{At: []time.Time{hour(2).In(time.FixedZone("CET", 3600)), hour(3)}, Values: []map[string]any{value(20), value(3)}},
+		{},
+		{At: []time.Time{hour(4), hour(5)}, Values: []map[string]any{value(4)}},
+	}
+	chunks := make([]timeChunk, len(results))
+	calls := 0
+	merged, err := fetchChunked(chunks, func(from, to time.Time) (triflestats.ValuesResult, error) {
+		calls++
+		return results[calls-1], nil
+	})
+	if err != nil {
+		t.Fatalf("fetchChunked returned error: %v", err)
+	}
+
+	if len(merged.At) != 6 || len(merged.Values) != 6 {
+		t.Fatalf("merged %d at / %d values, want 6", len(merged.At), len(merged.Values))
+	}
+	for i, at := range merged.At {
+		if !at.Equal(hour(i)) {
+			t.Fatalf("merged.At[%d] = %s, want %s", i, at, hour(i))
+		}
+	}
+	if merged.Values[2]["count"] != 2 {
+		t.Fatalf("boundary bucket should keep the first chunk's value, got %v", merged.Values[2])
+	}
+	if merged.Values[5] != nil {
+		t.Fatalf("missing values should stay nil, got %v", merged.Values[5])
+	}
+}
+
+func TestFetchChunkedMatchesSingleQuery(t *testing.T) {
+	state := newSQLiteMCPState(t)
+	cfg := state.Local.Config
+
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 1, 2, 23, 0, 0, 0, time.UTC)
+	for at := from; !at.After(to); at = at.Add(5 * time.Hour) {
+		if err := triflestats.Track(cfg, "event::logs", at, map[string]any{"count": 1}); err != nil {
+			t.Fatalf("Track returned error: %v", err)
+		}
+	}
+
+	fetch := func(from, to time.Time) (triflestats.ValuesResult, error) {
+		return triflestats.Values(cfg, "event::logs", from, to, "1h", false)
+	}
+	single, err := fetch(from, to)
+	if err != nil {
+		t.Fatalf("Values returned error: %v", err)
+	}
+	chunked, err := fetchChunked(planChunks(from, to, "1h", cfg, 7), fetch)
+	if err != nil {
+		t.Fatalf("fetchChunked returned error: %v", err)
+	}
+
+	if len(chunked.At) != len(single.At) {
+		t.Fatalf("chunked returned %d buckets, want %d", len(chunked.At), len(single.At))
+	}
+	for i := range single.At {
+		if !chunked.At[i].Equal(single.At[i]) || chunked.Values[i]["count"] != single.Values[i]["count"] {
+			t.Fatalf("bucket %d = %s %v, want %s %v", i, chunked.At[i], chunked.Values[i], single.At[i], single.Values[i])
+		}
+	}
+}
+
+func TestStreamChunkedNDJSON(t *testing.T) {
+	state := newSQLiteMCPState(t)
+	cfg := state.Local.Config
+
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 1, 1, 23, 59, 0, 0, time.UTC)
+	if err := triflestats.Track(cfg, "event::logs", from.Add(90*time.Minute), map[string]any{"count": 2}); err != nil {
+		t.Fatalf("Track returned error: %v", err)
+	}
+
+	fetch := func(from, to time.Time) (triflestats.ValuesResult, error) {
+		return triflestats.Values(cfg, "event::logs", from, to, "1h", false)
+	}
+	var buf bytes.Buffer
+	writer := output.NewNDJSONWriter(&buf, true)
+	if err := streamChunked(planChunks(from, to, "1h", cfg, 5), fetch, ndjsonPoints(writer)); err != nil {
+		t.Fatalf("streamChunked returned error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 24 {
+		t.Fatalf("ndjson produced %d lines, want 24 points", len(lines))
+	}
+	var point struct {
+		At     time.Time      `json:"at"`
+		Values map[string]any `json:"values"`
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &point); err != nil {
+		t.Fatalf("decode line: %v", err)
+	}
+	if !point.At.Equal(from.Add(time.Hour)) || point.Values["count"] != float64(2) {
+		t.Fatalf("line 1 = %s, want the 01:00 bucket with count 2", lines[1])
+	}
+}
+
+func TestFetchMetricsResponseChunkedKeepsFields(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		from, _ := time.Parse(time.RFC3339Nano, r.URL.Query().Get("from"))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"data":{"at":[%q],"values":[{"count":%d}]},"meta":{"chunk":%d}}`, from.Format(time.RFC3339), n, n)
+	}))
+	defer server.Close()
+
+	client, err := api.New(server.URL, "token", 5*time.Second)
+	if err != nil {
+		t.Fatalf("api.New returned error: %v", err)
+	}
+	params := map[string]string{"from": "2026-01-01T00:00:00Z", "to": "2026-01-01T03:00:00Z", "granularity": "1h"}
+	result, fields, err := fetchMetricsResponseChunked(context.Background(), client, params, &driverOptions{TimeZone: "UTC", BeginningOfWeek: "monday"}, 2)
+	if err != nil {
+		t.Fatalf("fetchMetricsResponseChunked returned error: %v", err)
+	}
+	if calls.Load() != 2 || len(result.At) != 2 {
+		t.Fatalf("calls = %d, points = %d, want 2 and 2", calls.Load(), len(result.At))
+	}
+	if !reflect.DeepEqual(fields, map[string]any{"meta": map[string]any{"chunk": float64(1)}}) {
+		t.Fatalf("fields = %v, want the first chunk's meta", fields)
+	}
+}
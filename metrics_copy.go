@@ -0,0 +1,333 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/trifle-io/trifle-cli/internal/api"
+	triflestats "github.com/trifle-io/trifle_stats_go"
+)
+
+// metricsEndpoint is one side of a copy: a named source resolved to either a
+// local driver runtime or an API client.
+type metricsEndpoint struct {
+	Name       string
+	Source     sourceConfig
+	opts       *commonOptions
+	driverOpts *driverOptions
+	local      *localDriverRuntime
+	client     *api.Client
+}
+
+// resolveMetricsEndpoint builds the connection options for a named source the
+// same way a command run with --source name would, without parsing flags.
+func resolveMetricsEndpoint(cfg *cliConfig, name string) (*metricsEndpoint, error) {
+	if cfg == nil || len(cfg.Sources) == 0 {
+		return nil, usageErrorf("no sources configured; add %q to the config file", name)
+	}
+	src, err := resolveSourceConfig(cfg, name)
+	if err != nil {
+		return nil, usageErrorf("%v", err)
+	}
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	endpoint := &metricsEndpoint{
+		Name:       name,
+		Source:     src,
+		opts:       addCommonFlags(fs, &src),
+		driverOpts: addDriverFlags(fs, &src),
+	}
+	if endpoint.isLocal() {
+		local, err := prepareLocalConfig(endpoint.driverOpts)
+		if err != nil {
+			return nil, fmt.Errorf("source %s: %w", name, err)
+		}
+		endpoint.local = local
+		return endpoint, nil
+	}
+
+	client, err := newClient(endpoint.opts)
+	if err != nil {
+		return nil, fmt.Errorf("source %s: %w", name, err)
+	}
+	endpoint.client = client
+	return endpoint, nil
+}
+
+func (e *metricsEndpoint) isLocal() bool {
+	return isLocalDriver(e.driverOpts.Driver)
+}
+
+func (e *metricsEndpoint) driverName() string {
+	if e.local != nil {
+		return e.local.DriverName
+	}
+	return "api"
+}
+
+// connect opens the local driver or makes sure an API token is present.
+func (e *metricsEndpoint) connect() error {
+	if e.local != nil {
+		if err := e.local.connect(e.driverOpts); err != nil {
+			return fmt.Errorf("source %s: %w", e.Name, err)
+		}
+		return nil
+	}
+	if err := ensureToken(e.opts, false); err != nil {
+		return fmt.Errorf("source %s: %w", e.Name, err)
+	}
+	e.client.SetToken(e.opts.Token)
+	return nil
+}
+
+// resolveRange resolves the time range and granularity against this source's
+// defaults.
+func (e *metricsEndpoint) resolveRange(timeRange *timeRangeOptions, granularity string) (string, string, string, error) {
+	if e.local != nil {
+		timeRange.Default = e.Source.DefaultTimeframe
+		fromValue, toValue, err := resolveCommandTimeRange(timeRange, e.driverOpts)
+		if err != nil {
+			return "", "", "", err
+		}
+		granularityValue, err := resolveGranularityLocal(granularity, e.local.Config)
+		return fromValue, toValue, granularityValue, err
+	}
+
+	source := newSourceLookup(e.client)
+	fromValue, toValue, err := resolveSourceTimeRange(context.Background(), source, timeRange, e.driverOpts)
+	if err != nil {
+		return "", "", "", err
+	}
+	granularityValue, err := resolveGranularityValue(context.Background(), source, granularity)
+	return fromValue, toValue, granularityValue, err
+}
+
+// stream hands every bucket of key in from..to to emit, chunk by chunk.
+func (e *metricsEndpoint) stream(key, fromValue, toValue, granularity string, chunkSize int, emit func(at time.Time, values map[string]any) error) error {
+	if e.local == nil {
+		params := map[string]string{
+			"key":         key,
+			"from":        fromValue,
+			"to":          toValue,
+			"granularity": granularity,
+		}
+		return streamMetricsChunked(context.Background(), e.client, params, e.driverOpts, chunkSize, emit)
+	}
+
+	fromTime, err := time.Parse(time.RFC3339Nano, fromValue)
+	if err != nil {
+		return err
+	}
+	toTime, err := time.Parse(time.RFC3339Nano, toValue)
+	if err != nil {
+		return err
+	}
+	cfg := e.local.Config
+	chunks := planChunks(fromTime, toTime, granularity, cfg, chunkSize)
+	err = streamChunked(chunks, func(from, to time.Time) (triflestats.ValuesResult, error) {
+		return triflestats.Values(cfg, key, from, to, granularity, true)
+	}, emit)
+	if err != nil {
+		return driverError(maybeSuggestSetup(err, e.local.DriverName, e.local.TableName))
+	}
+	return nil
+}
+
+// write stores one bucket's values under key.
+func (e *metricsEndpoint) write(mode, key string, at time.Time, values map[string]any) error {
+	if e.local != nil {
+		return performLocalWrite(e.local.Config, mode, key, at, values)
+	}
+	payload := map[string]any{
+		"key":    key,
+		"at":     at.UTC().Format(time.RFC3339Nano),
+		"values": values,
+	}
+	return e.client.PostMetrics(context.Background(), payload, nil)
+}
+
+// flush writes out anything still held in a local buffer.
+func (e *metricsEndpoint) flush() error {
+	if e.local == nil {
+		return nil
+	}
+	if err := e.local.Config.ShutdownBuffer(); err != nil {
+		return driverError(maybeSuggestSetup(err, e.local.DriverName, e.local.TableName))
+	}
+	return nil
+}
+
+func (e *metricsEndpoint) plan(command string, request map[string]any) map[string]any {
+	if e.local != nil {
+		plan := localPlan(command, e.local, e.driverOpts, request)
+		plan["source"] = e.Name
+		return plan
+	}
+	plan := map[string]any{
+		"source":  e.Name,
+		"driver":  "api",
+		"url":     e.opts.BaseURL,
+		"request": request,
+	}
+	if e.opts.Token != "" {
+		plan["token"] = maskToken(e.opts.Token)
+	}
+	return plan
+}
+
+// copySummary reports what a copy read and wrote.
+type copySummary struct {
+	FromSource string            `json:"from_source"`
+	FromDriver string            `json:"from_driver"`
+	ToSource   string            `json:"to_source"`
+	ToDriver   string            `json:"to_driver"`
+	Key        string            `json:"key"`
+	Mode       string            `json:"mode"`
+	Timeframe  map[string]string `json:"timeframe"`
+	Buckets    int               `json:"buckets"`
+	Copied     int               `json:"copied"`
+	Skipped    int               `json:"skipped"`
+	Existing   int               `json:"existing"`
+	LastCopied string            `json:"last_copied,omitempty"`
+}
+
+// copyBuckets streams buckets from read into write, skipping empty ones.
+func copyBuckets(summary *copySummary, read func(emit func(at time.Time, values map[string]any) error) error, write func(at time.Time, values map[string]any) error) error {
+	return read(func(at time.Time, values map[string]any) error {
+		summary.Buckets++
+		if len(values) == 0 {
+			summary.Skipped++
+			return nil
+		}
+		if err := write(at, values); err != nil {
+			return fmt.Errorf("write %s: %w", at.UTC().Format(time.RFC3339), err)
+		}
+		summary.Copied++
+		summary.LastCopied = at.UTC().Format(time.RFC3339)
+		return nil
+	})
+}
+
+func metricsCopy(args []string) {
+	rc, err := resolveCommandConfig(args)
+	if err != nil {
+		exitError(err)
+	}
+
+	fs := flag.NewFlagSet("metrics copy", flag.ExitOnError)
+	addConfigFlag(fs, rc.ConfigPath)
+	fromSource := fs.String("from-source", "", "Source to read from (name in the config file)")
+	toSource := fs.String("to-source", "", "Source to write to (name in the config file)")
+	key := fs.String("key", "", "Metrics key to copy")
+	timeRange := addTimeRangeFlags(fs)
+	granularity := fs.String("granularity", "", "Granularity to read and write (e.g. 1h)")
+	mode := fs.String("mode", "track", "Mode: track adds to existing values, assert overwrites them (local destinations)")
+	chunkSize := fs.Int("chunk-size", defaultExportChunkSize, "Buckets fetched per query")
+	explain := addExplainFlag(fs)
+	outputOpts := addOutputFlags(fs)
+	fs.Parse(args)
+
+	if err := outputOpts.validate(""); err != nil {
+		exitError(err)
+	}
+	if *fromSource == "" || *toSource == "" {
+		exitError(usageErrorf("--from-source and --to-source are required"))
+	}
+	if *fromSource == *toSource {
+		exitError(usageErrorf("--from-source and --to-source must differ"))
+	}
+	if *key == "" {
+		exitError(usageErrorf("--key is required"))
+	}
+	if *chunkSize < 1 {
+		exitError(usageErrorf("--chunk-size must be at least 1"))
+	}
+
+	from, err := resolveMetricsEndpoint(rc.Config, *fromSource)
+	if err != nil {
+		exitError(err)
+	}
+	to, err := resolveMetricsEndpoint(rc.Config, *toSource)
+	if err != nil {
+		exitError(err)
+	}
+	modeName, err := resolveWriteMode(*mode, to.isLocal())
+	if err != nil {
+		exitError(err)
+	}
+
+	if !*explain {
+		if err := from.connect(); err != nil {
+			exitError(err)
+		}
+	}
+	fromValue, toValue, granularityValue, err := from.resolveRange(timeRange, *granularity)
+	if err != nil {
+		exitError(err)
+	}
+
+	if *explain {
+		read := map[string]any{
+			"key":         *key,
+			"from":        fromValue,
+			"to":          toValue,
+			"granularity": granularityValue,
+			"chunk_size":  *chunkSize,
+		}
+		plan := map[string]any{
+			"command": "metrics copy",
+			"read":    from.plan("metrics copy", read),
+			"write":   to.plan("metrics copy", map[string]any{"key": *key, "mode": modeName}),
+		}
+		if err := writeJSONOutput(outputOpts, plan); err != nil {
+			exitError(err)
+		}
+		return
+	}
+	if err := to.connect(); err != nil {
+		exitError(err)
+	}
+
+	summary := copySummary{
+		FromSource: from.Name,
+		FromDriver: from.driverName(),
+		ToSource:   to.Name,
+		ToDriver:   to.driverName(),
+		Key:        *key,
+		Mode:       modeName,
+		Timeframe:  buildTimeframePayload(fromValue, toValue, granularityValue, timeRange.Timeframe),
+	}
+
+	err = to.stream(*key, fromValue, toValue, granularityValue, *chunkSize, func(_ time.Time, values map[string]any) error {
+		if len(values) > 0 {
+			summary.Existing++
+		}
+		return nil
+	})
+	if err != nil {
+		exitError(fmt.Errorf("check destination: %w", err))
+	}
+	if summary.Existing > 0 && modeName == "track" {
+		fmt.Fprintf(os.Stderr, "warning: %s already has %d %s buckets for %s in range; track adds to them (use --mode assert to overwrite)\n", to.Name, summary.Existing, granularityValue, *key)
+	}
+
+	err = copyBuckets(&summary, func(emit func(at time.Time, values map[string]any) error) error {
+		return from.stream(*key, fromValue, toValue, granularityValue, *chunkSize, emit)
+	}, func(at time.Time, values map[string]any) error {
+		return to.write(modeName, *key, at, values)
+	})
+	if flushErr := to.flush(); err == nil {
+		err = flushErr
+	}
+	if err != nil {
+		exitError(fmt.Errorf("copy stopped after %d buckets: %w", summary.Copied, err))
+	}
+
+	fmt.Fprintf(os.Stderr, "copied %d of %d buckets from %s to %s\n", summary.Copied, summary.Buckets, from.Name, to.Name)
+	if err := writeJSONOutput(outputOpts, map[string]any{"data": summary}); err != nil {
+		exitError(err)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCopyBuckets(t *testing.T) {
+	t.Parallel()
+
+	at := hourlyBuckets(3)
+	var written []time.Time
+	var summary copySummary
+	err := copyBuckets(&summary, func(emit func(at time.Time, values map[string]any) error) error {
+		for i, bucket := range at {
+			values := map[string]any{"count": i + 1}
+			if i == 1 {
+				values = nil
+			}
+			if err := emit(bucket, values); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, func(at time.Time, values map[string]any) error {
+		written = append(written, at)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("copyBuckets error: %v", err)
+	}
+
+	if len(written) != 2 || !written[0].Equal(at[0]) || !written[1].Equal(at[2]) {
+		t.Fatalf("written = %v", written)
+	}
+	if summary.Buckets != 3 || summary.Copied != 2 || summary.Skipped != 1 || summary.LastCopied != "2026-10-15T11:00:00Z" {
+		t.Fatalf("summary = %+v", summary)
+	}
+}
+
+func TestCopyBucketsStopsOnWriteError(t *testing.T) {
+	t.Parallel()
+
+	at := hourlyBuckets(2)
+	var summary copySummary
+	err := copyBuckets(&summary, func(emit func(at time.Time, values map[string]any) error) error {
+		for _, bucket := range at {
+			if err := emit(bucket, map[string]any{"count": 1}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, func(at time.Time, values map[string]any) error {
+		if summary.Copied == 1 {
+			return errors.New("disk full")
+		}
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "write 2026-10-15T10:00:00Z: disk full") {
+		t.Fatalf("error = %v", err)
+	}
+	if summary.Copied != 1 {
+		t.Fatalf("copied = %d, want 1", summary.Copied)
+	}
+}
+
+func TestResolveMetricsEndpoint(t *testing.T) {
+	cfg := &cliConfig{Sources: map[string]sourceConfig{
+		"local": {Driver: "sqlite", DB: "./stats.db", Table: "events"},
+		"prod":  {Driver: "api", URL: "https://trifle.example.com", Token: "secret"},
+	}}
+	for _, name := range []string{"TRIFLE_DRIVER", "TRIFLE_DB", "TRIFLE_TABLE", "TRIFLE_URL", "TRIFLE_TOKEN"} {
+		t.Setenv(name, "")
+	}
+
+	local, err := resolveMetricsEndpoint(cfg, "local")
+	if err != nil {
+		t.Fatalf("local endpoint error: %v", err)
+	}
+	if !local.isLocal() || local.driverName() != "sqlite" || local.driverOpts.DBPath != "./stats.db" || local.local.TableName != "events" {
+		t.Fatalf("local endpoint = %+v", local.driverOpts)
+	}
+
+	prod, err := resolveMetricsEndpoint(cfg, "prod")
+	if err != nil {
+		t.Fatalf("prod endpoint error: %v", err)
+	}
+	if prod.isLocal() || prod.client == nil || prod.opts.Token != "secret" {
+		t.Fatalf("prod endpoint = %+v", prod.opts)
+	}
+
+	if _, err := resolveMetricsEndpoint(cfg, "missing"); err == nil || !strings.Contains(err.Error(), `unknown source "missing"`) {
+		t.Fatalf("missing endpoint error = %v", err)
+	}
+	if _, err := resolveMetricsEndpoint(&cliConfig{}, "local"); err == nil {
+		t.Fatal("expected an error without configured sources")
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"math"
+	"strings"
+	"time"
+
+	triflestats "github.com/trifle-io/trifle_stats_go"
+)
+
+// pathProfile summarizes the numeric values one path holds across a series.
+type pathProfile struct {
+	Path    string  `json:"path"`
+	Buckets int     `json:"buckets"`
+	Min     float64 `json:"min"`
+	Max     float64 `json:"max"`
+	Mean    float64 `json:"mean"`
+	Sum     float64 `json:"sum"`
+}
+
+// keyProfile is the result of metrics describe.
+type keyProfile struct {
+	Key             string        `json:"key"`
+	From            string        `json:"from"`
+	To              string        `json:"to"`
+	Granularity     string        `json:"granularity"`
+	Buckets         int           `json:"buckets"`
+	NonEmptyBuckets int           `json:"non_empty_buckets"`
+	FirstBucket     *time.Time    `json:"first_bucket"`
+	LastBucket      *time.Time    `json:"last_bucket"`
+	ValuePaths      []string      `json:"value_paths"`
+	Paths           []pathProfile `json:"paths"`
+}
+
+// describeSeries profiles a raw series. Paths are packed (a.b.c), and only
+// numeric values count towards a path's statistics; paths that never hold a
+// number are still listed in ValuePaths.
+func describeSeries(result triflestats.ValuesResult) keyProfile {
+	profile := keyProfile{Buckets: len(result.At), ValuePaths: []string{}, Paths: []pathProfile{}}
+	stats := map[string]*pathProfile{}
+	seen := map[string]struct{}{}
+
+	for i, at := range result.At {
+		if i >= len(result.Values) || len(result.Values[i]) == 0 {
+			continue
+		}
+		at := at
+		profile.NonEmptyBuckets++
+		if profile.FirstBucket == nil {
+			profile.FirstBucket = &at
+		}
+		profile.LastBucket = &at
+
+		for path, value := range triflestats.Pack(result.Values[i]) {
+			seen[path] = struct{}{}
+			number, ok := triflestats.NormalizeNumeric(value).(float64)
+			if !ok || math.IsNaN(number) {
+				continue
+			}
+			entry, ok := stats[path]
+			if !ok {
+				entry = &pathProfile{Path: path, Min: number, Max: number}
+				stats[path] = entry
+			}
+			entry.Buckets++
+			entry.Sum += number
+			entry.Min = math.Min(entry.Min, number)
+			entry.Max = math.Max(entry.Max, number)
+		}
+	}
+
+	profile.ValuePaths = sortedKeys(seen)
+	for _, path := range profile.ValuePaths {
+		entry, ok := stats[path]
+		if !ok {
+			continue
+		}
+		entry.Mean = entry.Sum / float64(entry.Buckets)
+		profile.Paths = append(profile.Paths, *entry)
+	}
+	return profile
+}
+
+// describeTable lays out the per-path statistics as a table payload.
+func describeTable(profile keyProfile) map[string]any {
+	rows := make([]any, 0, len(profile.Paths))
+	for _, path := range profile.Paths {
+		rows = append(rows, []any{path.Path, path.Buckets, path.Min, path.Max, path.Mean, path.Sum})
+	}
+	return map[string]any{
+		"columns": []any{"path", "buckets", "min", "max", "mean", "sum"},
+		"rows":    rows,
+	}
+}
+
+func metricsDescribe(args []string) {
+	rc, err := resolveCommandConfig(args)
+	if err != nil {
+		exitError(err)
+	}
+
+	fs := flag.NewFlagSet("metrics describe", flag.ExitOnError)
+	addConfigFlag(fs, rc.ConfigPath)
+	addSourceFlag(fs, rc.SourceName)
+	opts := addCommonFlags(fs, &rc.Source)
+	driverOpts := addDriverFlags(fs, &rc.Source)
+	key := fs.String("key", "", "Metrics key")
+	timeRange := addTimeRangeFlags(fs)
+	granularity := fs.String("granularity", "", "Granularity (e.g. 1h, 1d)")
+	format := fs.String("format", "json", "Output format: json|table|csv|markdown")
+	tableFlags := addTableFlags(fs)
+	explain := addExplainFlag(fs)
+	showStats := addStatsFlag(fs)
+	outputOpts := addOutputFlags(fs)
+	if err := parseKeyArgs(fs, args, key); err != nil {
+		exitError(err)
+	}
+
+	outputFormat := strings.ToLower(strings.TrimSpace(*format))
+	switch outputFormat {
+	case "json", "table", "csv", "markdown":
+	default:
+		exitError(usageErrorf("unsupported format %q (use json, table, csv, or markdown)", *format))
+	}
+	if err := outputOpts.validate(outputFormat); err != nil {
+		exitError(err)
+	}
+	if *key == "" {
+		exitError(usageErrorf("--key is required"))
+	}
+	tableOpts, err := tableFlags.options(nil)
+	if err != nil {
+		exitError(err)
+	}
+
+	stats := newCommandStats(*showStats && !*explain, statsDriverName(driverOpts.Driver))
+	defer stats.finish()
+
+	var fromValue, toValue, granularityValue string
+	var result triflestats.ValuesResult
+
+	if isLocalDriver(driverOpts.Driver) {
+		local, err := prepareLocalConfig(driverOpts)
+		if err != nil {
+			exitError(err)
+		}
+		cfg := local.Config
+
+		timeRange.Default = rc.Source.DefaultTimeframe
+		fromValue, toValue, err = resolveCommandTimeRange(timeRange, driverOpts)
+		if err != nil {
+			exitError(err)
+		}
+		granularityValue, err = resolveGranularityLocal(*granularity, cfg)
+		if err != nil {
+			exitError(err)
+		}
+		warnTimeframePoints(fromValue, toValue, granularityValue)
+
+		if *explain {
+			plan := localPlan("metrics describe", local, driverOpts, map[string]any{
+				"key":         *key,
+				"from":        fromValue,
+				"to":          toValue,
+				"granularity": granularityValue,
+			})
+			if err := writeJSONOutput(outputOpts, plan); err != nil {
+				exitError(err)
+			}
+			return
+		}
+		if err := local.connect(driverOpts); err != nil {
+			exitError(err)
+		}
+
+		fromTime, err := time.Parse(time.RFC3339Nano, fromValue)
+		if err != nil {
+			exitError(err)
+		}
+		toTime, err := time.Parse(time.RFC3339Nano, toValue)
+		if err != nil {
+			exitError(err)
+		}
+		stats.granularity = granularityValue
+		err = stats.timeQuery(func() (err error) {
+			result, err = triflestats.Values(cfg, *key, fromTime, toTime, granularityValue, false)
+			return err
+		})
+		if err != nil {
+			exitError(driverError(maybeSuggestSetup(err, local.DriverName, local.TableName)))
+		}
+	} else {
+		if !*explain {
+			if err := ensureToken(opts, true); err != nil {
+				exitError(err)
+			}
+		}
+		client, err := newClient(opts)
+		if err != nil {
+			exitError(err)
+		}
+		source := newSourceLookup(client)
+		stats.client = client
+
+		fromValue, toValue, err = resolveSourceTimeRange(context.Background(), source, timeRange, driverOpts)
+		if err != nil {
+			exitError(err)
+		}
+		granularityValue, err = resolveGranularityValue(context.Background(), source, *granularity)
+		if err != nil {
+			exitError(err)
+		}
+		warnTimeframePoints(fromValue, toValue, granularityValue)
+		stats.granularity = granularityValue
+
+		params := map[string]string{
+			"key":         *key,
+			"from":        fromValue,
+			"to":          toValue,
+			"granularity": granularityValue,
+		}
+		if *explain {
+			request, err := explainGetMetrics(client, params, driverOpts.BeginningOfWeek)
+			if err != nil {
+				exitError(err)
+			}
+			if err := writeJSONOutput(outputOpts, apiPlan("metrics describe", opts, request)); err != nil {
+				exitError(err)
+			}
+			return
+		}
+
+		result, err = fetchMetricsChunked(context.Background(), client, params, driverOpts, 0)
+		if err != nil {
+			exitError(err)
+		}
+	}
+	stats.points = len(result.At)
+
+	profile := describeSeries(result)
+	profile.Key = *key
+	profile.From = fromValue
+	profile.To = toValue
+	profile.Granularity = granularityValue
+
+	payload := map[string]any{"data": profile}
+	if outputFormat != "json" {
+		payload = map[string]any{"table": describeTable(profile)}
+	}
+	if err := writeTableOrJSONOutput(outputOpts, payload, outputFormat, tableOpts); err != nil {
+		exitError(err)
+	}
+}
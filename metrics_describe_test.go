@@ -0,0 +1,56 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	triflestats "github.com/trifle-io/trifle_stats_go"
+)
+
+func TestDescribeSeries(t *testing.T) {
+	t.Parallel()
+
+	at := hourlyBuckets(4)
+	profile := describeSeries(triflestats.ValuesResult{
+		At: at,
+		Values: []map[string]any{
+			{},
+			{"count": 2, "duration": map[string]any{"sum": 10.5}, "status": "ok"},
+			{"count": 6},
+			{},
+		},
+	})
+
+	if profile.Buckets != 4 || profile.NonEmptyBuckets != 2 {
+		t.Fatalf("buckets = %d/%d, want 4/2", profile.NonEmptyBuckets, profile.Buckets)
+	}
+	if profile.FirstBucket == nil || !profile.FirstBucket.Equal(at[1]) {
+		t.Fatalf("first bucket = %v, want %s", profile.FirstBucket, at[1])
+	}
+	if profile.LastBucket == nil || !profile.LastBucket.Equal(at[2]) {
+		t.Fatalf("last bucket = %v, want %s", profile.LastBucket, at[2])
+	}
+	if want := []string{"count", "duration.sum", "status"}; !reflect.DeepEqual(profile.ValuePaths, want) {
+		t.Fatalf("value paths = %v, want %v", profile.ValuePaths, want)
+	}
+
+	want := []pathProfile{
+		{Path: "count", Buckets: 2, Min: 2, Max: 6, Mean: 4, Sum: 8},
+		{Path: "duration.sum", Buckets: 1, Min: 10.5, Max: 10.5, Mean: 10.5, Sum: 10.5},
+	}
+	if !reflect.DeepEqual(profile.Paths, want) {
+		t.Fatalf("paths = %+v, want %+v", profile.Paths, want)
+	}
+}
+
+func TestDescribeSeriesEmpty(t *testing.T) {
+	t.Parallel()
+
+	profile := describeSeries(triflestats.ValuesResult{At: hourlyBuckets(2), Values: []map[string]any{{}, {}}})
+	if profile.NonEmptyBuckets != 0 || profile.FirstBucket != nil || profile.LastBucket != nil {
+		t.Fatalf("profile = %+v, want no data", profile)
+	}
+	if len(profile.ValuePaths) != 0 || len(profile.Paths) != 0 {
+		t.Fatalf("profile paths = %v / %v, want empty", profile.ValuePaths, profile.Paths)
+	}
+}
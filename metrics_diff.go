@@ -0,0 +1,265 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	triflestats "github.com/trifle-io/trifle_stats_go"
+)
+
+// diffSample is one bucket's value for the compared path; nil means the
+// bucket is missing or has no value at the path.
+type diffSample struct {
+	At    time.Time
+	Value any
+}
+
+// diffPoint is a bucket whose values differ beyond the tolerance.
+type diffPoint struct {
+	At      string  `json:"at"`
+	A       any     `json:"a"`
+	B       any     `json:"b"`
+	Diff    float64 `json:"diff"`
+	Missing string  `json:"missing,omitempty"`
+}
+
+// diffResult summarizes the comparison of two series.
+type diffResult struct {
+	Buckets     int         `json:"buckets"`
+	Mismatches  int         `json:"mismatches"`
+	MaxAbsDiff  float64     `json:"max_abs_diff"`
+	Differences []diffPoint `json:"differences"`
+}
+
+// diffSeries compares a and b bucket by bucket. A bucket missing on one side
+// counts as 0 there, so a copy that skipped empty buckets still matches; the
+// missing side is recorded on reported differences. Buckets empty on both
+// sides are not compared.
+func diffSeries(a, b []diffSample, tolerance float64) diffResult {
+	type pair struct {
+		at   time.Time
+		a, b any
+	}
+	buckets := map[int64]*pair{}
+	add := func(samples []diffSample, set func(p *pair, value any)) {
+		for _, sample := range samples {
+			value := triflestats.NormalizeNumeric(sample.Value)
+			if _, ok := value.(float64); !ok {
+				continue
+			}
+			unix := sample.At.UnixNano()
+			p, ok := buckets[unix]
+			if !ok {
+				p = &pair{at: sample.At}
+				buckets[unix] = p
+			}
+			set(p, value)
+		}
+	}
+	add(a, func(p *pair, value any) { p.a = value })
+	add(b, func(p *pair, value any) { p.b = value })
+
+	keys := make([]int64, 0, len(buckets))
+	for unix := range buckets {
+		keys = append(keys, unix)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+	result := diffResult{Buckets: len(keys), Differences: []diffPoint{}}
+	for _, unix := range keys {
+		p := buckets[unix]
+		av, _ := p.a.(float64)
+		bv, _ := p.b.(float64)
+		diff := math.Abs(av - bv)
+		if diff <= tolerance {
+			continue
+		}
+		point := diffPoint{At: p.at.UTC().Format(time.RFC3339), A: p.a, B: p.b, Diff: diff}
+		switch {
+		case p.a == nil:
+			point.Missing = "a"
+		case p.b == nil:
+			point.Missing = "b"
+		}
+		result.Mismatches++
+		result.MaxAbsDiff = math.Max(result.MaxAbsDiff, diff)
+		result.Differences = append(result.Differences, point)
+	}
+	return result
+}
+
+// readDiffSamples streams key from endpoint and keeps the value at path.
+func readDiffSamples(endpoint *metricsEndpoint, key, path, fromValue, toValue, granularity string, chunkSize int) ([]diffSample, error) {
+	var samples []diffSample
+	err := endpoint.stream(key, fromValue, toValue, granularity, chunkSize, func(at time.Time, values map[string]any) error {
+		samples = append(samples, diffSample{At: at, Value: triflestats.FetchPath(values, path)})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("source %s: %w", endpoint.Name, err)
+	}
+	return samples, nil
+}
+
+func metricsDiff(args []string) {
+	rc, err := resolveCommandConfig(args)
+	if err != nil {
+		exitError(err)
+	}
+
+	fs := flag.NewFlagSet("metrics diff", flag.ExitOnError)
+	addConfigFlag(fs, rc.ConfigPath)
+	sourceA := fs.String("source-a", "", "First source (name in the config file)")
+	sourceB := fs.String("source-b", "", "Second source (default: --source-a)")
+	key := fs.String("key", "", "Metrics key to compare")
+	keyB := fs.String("key-b", "", "Key to read from the second source (default: --key)")
+	valuePath := fs.String("value-path", "", "Value path to compare")
+	timeRange := addTimeRangeFlags(fs)
+	granularity := fs.String("granularity", "", "Granularity (e.g. 1h)")
+	tolerance := fs.Float64("tolerance", 0, "Largest absolute difference still treated as equal")
+	chunkSize := fs.Int("chunk-size", defaultExportChunkSize, "Buckets fetched per query")
+	format := fs.String("format", "json", "Output format: json|table|csv|markdown")
+	tableFlags := addTableFlags(fs)
+	explain := addExplainFlag(fs)
+	outputOpts := addOutputFlags(fs)
+	fs.Parse(args)
+
+	outputFormat := strings.ToLower(strings.TrimSpace(*format))
+	switch outputFormat {
+	case "json", "table", "csv", "markdown":
+	default:
+		exitError(usageErrorf("unsupported format %q (use json, table, csv, or markdown)", *format))
+	}
+	if err := outputOpts.validate(outputFormat); err != nil {
+		exitError(err)
+	}
+	if *sourceA == "" {
+		exitError(usageErrorf("--source-a is required"))
+	}
+	if *sourceB == "" {
+		*sourceB = *sourceA
+	}
+	if *key == "" || *valuePath == "" {
+		exitError(usageErrorf("--key and --value-path are required"))
+	}
+	if *keyB == "" {
+		*keyB = *key
+	}
+	if *sourceA == *sourceB && *key == *keyB {
+		exitError(usageErrorf("nothing to compare: pass a different --source-b or --key-b"))
+	}
+	if err := ensureNoWildcards(*valuePath); err != nil {
+		exitError(usageErrorf("%v", err))
+	}
+	if *tolerance < 0 {
+		exitError(usageErrorf("--tolerance must be 0 or greater"))
+	}
+	if *chunkSize < 1 {
+		exitError(usageErrorf("--chunk-size must be at least 1"))
+	}
+	tableOpts, err := tableFlags.options(nil)
+	if err != nil {
+		exitError(err)
+	}
+
+	a, err := resolveMetricsEndpoint(rc.Config, *sourceA)
+	if err != nil {
+		exitError(err)
+	}
+	b := a
+	if *sourceB != *sourceA {
+		if b, err = resolveMetricsEndpoint(rc.Config, *sourceB); err != nil {
+			exitError(err)
+		}
+	}
+
+	if !*explain {
+		if err := a.connect(); err != nil {
+			exitError(err)
+		}
+	}
+	fromValue, toValue, granularityValue, err := a.resolveRange(timeRange, *granularity)
+	if err != nil {
+		exitError(err)
+	}
+
+	if *explain {
+		read := func(key string) map[string]any {
+			return map[string]any{
+				"key":         key,
+				"value_path":  *valuePath,
+				"from":        fromValue,
+				"to":          toValue,
+				"granularity": granularityValue,
+				"chunk_size":  *chunkSize,
+			}
+		}
+		plan := map[string]any{
+			"command":   "metrics diff",
+			"a":         a.plan("metrics diff", read(*key)),
+			"b":         b.plan("metrics diff", read(*keyB)),
+			"tolerance": *tolerance,
+		}
+		if err := writeJSONOutput(outputOpts, plan); err != nil {
+			exitError(err)
+		}
+		return
+	}
+	if b != a {
+		if err := b.connect(); err != nil {
+			exitError(err)
+		}
+	}
+
+	samplesA, err := readDiffSamples(a, *key, *valuePath, fromValue, toValue, granularityValue, *chunkSize)
+	if err != nil {
+		exitError(err)
+	}
+	samplesB, err := readDiffSamples(b, *keyB, *valuePath, fromValue, toValue, granularityValue, *chunkSize)
+	if err != nil {
+		exitError(err)
+	}
+	result := diffSeries(samplesA, samplesB, *tolerance)
+
+	labelA, labelB := a.Name, b.Name
+	if labelA == labelB {
+		labelA, labelB = *key, *keyB
+	}
+	rows := make([]any, len(result.Differences))
+	for i, point := range result.Differences {
+		rows[i] = []any{point.At, point.A, point.B, point.Diff}
+	}
+	payload := map[string]any{
+		"data": map[string]any{
+			"source_a":   a.Name,
+			"source_b":   b.Name,
+			"key_a":      *key,
+			"key_b":      *keyB,
+			"value_path": *valuePath,
+			"tolerance":  *tolerance,
+			"timeframe":  buildTimeframePayload(fromValue, toValue, granularityValue, timeRange.Timeframe),
+			"result":     result,
+		},
+	}
+	if outputFormat != "json" {
+		payload = map[string]any{
+			"table": map[string]any{
+				"columns": []any{"at", labelA, labelB, "diff"},
+				"rows":    rows,
+			},
+		}
+	}
+	if err := writeTableOrJSONOutput(outputOpts, payload, outputFormat, tableOpts); err != nil {
+		exitError(err)
+	}
+
+	if result.Mismatches > 0 {
+		fmt.Fprintf(os.Stderr, "%d of %d buckets differ (max abs diff %g)\n", result.Mismatches, result.Buckets, result.MaxAbsDiff)
+		os.Exit(exitGeneral)
+	}
+}
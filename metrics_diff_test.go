@@ -0,0 +1,48 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffSeries(t *testing.T) {
+	t.Parallel()
+
+	at := hourlyBuckets(5)
+	a := []diffSample{
+		{At: at[0], Value: 1},
+		{At: at[1], Value: 2},
+		{At: at[2], Value: 3},
+		{At: at[3], Value: nil},
+	}
+	b := []diffSample{
+		{At: at[0], Value: 1},
+		{At: at[1], Value: 2.25},
+		{At: at[2], Value: 7},
+		{At: at[4], Value: 4},
+	}
+
+	got := diffSeries(a, b, 0.5)
+	want := diffResult{
+		Buckets:    4,
+		Mismatches: 2,
+		MaxAbsDiff: 4,
+		Differences: []diffPoint{
+			{At: "2026-10-15T11:00:00Z", A: 3.0, B: 7.0, Diff: 4},
+			{At: "2026-10-15T13:00:00Z", A: nil, B: 4.0, Diff: 4, Missing: "a"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("diffSeries = %+v, want %+v", got, want)
+	}
+}
+
+func TestDiffSeriesMissingZeroMatches(t *testing.T) {
+	t.Parallel()
+
+	at := hourlyBuckets(2)
+	got := diffSeries([]diffSample{{At: at[0], Value: 0}, {At: at[1], Value: 5}}, []diffSample{{At: at[1], Value: 5}}, 0)
+	if got.Mismatches != 0 || got.Buckets != 2 || len(got.Differences) != 0 {
+		t.Fatalf("diffSeries = %+v, want no mismatches", got)
+	}
+}
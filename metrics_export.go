@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/trifle-io/trifle-cli/internal/output"
+	triflestats "github.com/trifle-io/trifle_stats_go"
+)
+
+const defaultExportChunkSize = 1000
+
+// exportRecord is one line of a metrics export dump.
+type exportRecord struct {
+	Key    string         `json:"key"`
+	At     time.Time      `json:"at"`
+	Values map[string]any `json:"values"`
+}
+
+// exportProgress tracks how far an export got, for the summary and for the
+// error report when a chunk fails.
+type exportProgress struct {
+	Records int
+	Keys    int
+	Key     string
+	At      time.Time
+}
+
+func (p exportProgress) String() string {
+	if p.At.IsZero() {
+		return fmt.Sprintf("%d records from %d keys", p.Records, p.Keys)
+	}
+	return fmt.Sprintf("%d records from %d keys (last %s at %s)", p.Records, p.Keys, p.Key, p.At.UTC().Format(time.RFC3339))
+}
+
+// exportSeries streams one key into writer through stream, dropping empty
+// buckets and counting what was written in progress.
+func exportSeries(writer *output.NDJSONWriter, key string, progress *exportProgress, stream func(emit func(at time.Time, values map[string]any) error) error) error {
+	err := stream(func(at time.Time, values map[string]any) error {
+		if len(values) == 0 {
+			return nil
+		}
+		if err := writer.Write(exportRecord{Key: key, At: at, Values: values}); err != nil {
+			return err
+		}
+		progress.Records++
+		progress.Key = key
+		progress.At = at
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	progress.Keys++
+	return nil
+}
+
+// writeExportOutput is writeCommandOutput for exports. When write fails part
+// way through an --output file, the records written so far are kept as
+// <output>.partial, whose path is returned with the error.
+func writeExportOutput(opts *outputOptions, write func(w io.Writer) error) (string, error) {
+	path := opts.Path
+	if path == "" || path == "-" {
+		return "", write(os.Stdout)
+	}
+
+	file, err := output.CreateFile(path)
+	if err != nil {
+		return "", fmt.Errorf("create output %s: %w", path, err)
+	}
+	if err := write(file); err != nil {
+		partial := path + ".partial"
+		if commitErr := file.CommitAs(partial); commitErr != nil {
+			return "", err
+		}
+		return partial, err
+	}
+	if err := file.Commit(); err != nil {
+		return "", fmt.Errorf("write output %s: %w", path, err)
+	}
+	fmt.Fprintf(os.Stderr, "wrote %s\n", path)
+	return "", nil
+}
+
+func metricsExport(args []string) {
+	rc, err := resolveCommandConfig(args)
+	if err != nil {
+		exitError(err)
+	}
+
+	fs := flag.NewFlagSet("metrics export", flag.ExitOnError)
+	addConfigFlag(fs, rc.ConfigPath)
+	addSourceFlag(fs, rc.SourceName)
+	opts := addCommonFlags(fs, &rc.Source)
+	driverOpts := addDriverFlags(fs, &rc.Source)
+	key := fs.String("key", "", "Metrics key to export")
+	allKeys := fs.Bool("all-keys", false, "Export every key listed in the system series for the range")
+	timeRange := addTimeRangeFlags(fs)
+	granularity := fs.String("granularity", "", "Granularity (e.g. 1h, 1d)")
+	chunkSize := fs.Int("chunk-size", defaultExportChunkSize, "Buckets fetched per query")
+	explain := addExplainFlag(fs)
+	showStats := addStatsFlag(fs)
+	outputOpts := addOutputFlags(fs)
+	fs.StringVar(&outputOpts.Path, "out", "", "Alias for --output")
+	fs.Parse(args)
+
+	if err := outputOpts.validate("ndjson"); err != nil {
+		exitError(err)
+	}
+	if (*key == "") == !*allKeys {
+		exitError(usageErrorf("exactly one of --key or --all-keys is required"))
+	}
+	if *chunkSize < 1 {
+		exitError(usageErrorf("--chunk-size must be at least 1"))
+	}
+
+	stats := newCommandStats(*showStats && !*explain, statsDriverName(driverOpts.Driver))
+	defer stats.finish()
+
+	var progress exportProgress
+	var partialPath string
+	var exportErr error
+
+	if isLocalDriver(driverOpts.Driver) {
+		local, err := prepareLocalConfig(driverOpts)
+		if err != nil {
+			exitError(err)
+		}
+		cfg := local.Config
+
+		timeRange.Default = rc.Source.DefaultTimeframe
+		fromValue, toValue, err := resolveCommandTimeRange(timeRange, driverOpts)
+		if err != nil {
+			exitError(err)
+		}
+		granularityValue, err := resolveGranularityLocal(*granularity, cfg)
+		if err != nil {
+			exitError(err)
+		}
+		stats.granularity = granularityValue
+
+		fromTime, err := time.Parse(time.RFC3339Nano, fromValue)
+		if err != nil {
+			exitError(err)
+		}
+		toTime, err := time.Parse(time.RFC3339Nano, toValue)
+		if err != nil {
+			exitError(err)
+		}
+		chunks := planChunks(fromTime, toTime, granularityValue, cfg, *chunkSize)
+
+		if *explain {
+			plan := localPlan("metrics export", local, driverOpts, map[string]any{
+				"key":         *key,
+				"all_keys":    *allKeys,
+				"from":        fromValue,
+				"to":          toValue,
+				"granularity": granularityValue,
+				"chunks":      len(chunks),
+			})
+			if err := writeJSONOutput(outputOpts, plan); err != nil {
+				exitError(err)
+			}
+			return
+		}
+		if err := local.connect(driverOpts); err != nil {
+			exitError(err)
+		}
+
+		fetch := func(metricKey string) func(from, to time.Time) (triflestats.ValuesResult, error) {
+			return func(from, to time.Time) (result triflestats.ValuesResult, err error) {
+				err = stats.timeQuery(func() error {
+					result, err = triflestats.Values(cfg, metricKey, from, to, granularityValue, true)
+					return err
+				})
+				return result, err
+			}
+		}
+
+		keys := []string{*key}
+		if *allKeys {
+			system, err := fetchChunked(chunks, fetch(systemMetricsKey))
+			if err != nil {
+				exitError(driverError(maybeSuggestSetup(err, local.DriverName, local.TableName)))
+			}
+			keys = keysEntryNames(summarizeSystemKeys(system.Values))
+		}
+
+		partialPath, exportErr = writeExportOutput(outputOpts, func(w io.Writer) error {
+			writer := output.NewNDJSONWriter(w, false)
+			for i, metricKey := range keys {
+				err := exportSeries(writer, metricKey, &progress, func(emit func(at time.Time, values map[string]any) error) error {
+					return streamChunked(chunks, fetch(metricKey), stats.countPoints(emit))
+				})
+				if err != nil {
+					writer.Flush()
+					return driverError(err)
+				}
+				fmt.Fprintf(os.Stderr, "exported %s (%d/%d keys, %d records)\n", metricKey, i+1, len(keys), progress.Records)
+			}
+			return writer.Flush()
+		})
+	} else {
+		if !*explain {
+			if err := ensureToken(opts, true); err != nil {
+				exitError(err)
+			}
+		}
+
+		client, err := newClient(opts)
+		if err != nil {
+			exitError(err)
+		}
+		source := newSourceLookup(client)
+		stats.client = client
+
+		fromValue, toValue, err := resolveSourceTimeRange(context.Background(), source, timeRange, driverOpts)
+		if err != nil {
+			exitError(err)
+		}
+		granularityValue, err := resolveGranularityValue(context.Background(), source, *granularity)
+		if err != nil {
+			exitError(err)
+		}
+		stats.granularity = granularityValue
+
+		params := map[string]string{
+			"from":        fromValue,
+			"to":          toValue,
+			"granularity": granularityValue,
+		}
+		keyParams := func(metricKey string) map[string]string {
+			withKey := make(map[string]string, len(params)+1)
+			for name, value := range params {
+				withKey[name] = value
+			}
+			withKey["key"] = metricKey
+			return withKey
+		}
+
+		if *explain {
+			request, err := explainGetMetrics(client, keyParams(firstNonEmpty(*key, "<key>")), driverOpts.BeginningOfWeek)
+			if err != nil {
+				exitError(err)
+			}
+			plan := apiPlan("metrics export", opts, request)
+			plan["chunk_points"] = *chunkSize
+			plan["all_keys"] = *allKeys
+			if err := writeJSONOutput(outputOpts, plan); err != nil {
+				exitError(err)
+			}
+			return
+		}
+
+		keys := []string{*key}
+		if *allKeys {
+			system, err := fetchMetricsChunked(context.Background(), client, params, driverOpts, *chunkSize)
+			if err != nil {
+				exitError(err)
+			}
+			keys = keysEntryNames(summarizeKeys(system.Values))
+		}
+
+		partialPath, exportErr = writeExportOutput(outputOpts, func(w io.Writer) error {
+			writer := output.NewNDJSONWriter(w, false)
+			for i, metricKey := range keys {
+				err := exportSeries(writer, metricKey, &progress, func(emit func(at time.Time, values map[string]any) error) error {
+					return streamMetricsChunked(context.Background(), client, keyParams(metricKey), driverOpts, *chunkSize, stats.countPoints(emit))
+				})
+				if err != nil {
+					writer.Flush()
+					return err
+				}
+				fmt.Fprintf(os.Stderr, "exported %s (%d/%d keys, %d records)\n", metricKey, i+1, len(keys), progress.Records)
+			}
+			return writer.Flush()
+		})
+	}
+
+	if exportErr != nil {
+		if partialPath != "" {
+			exitError(fmt.Errorf("export stopped after %s, kept in %s: %w", progress, partialPath, exportErr))
+		}
+		exitError(fmt.Errorf("export stopped after %s: %w", progress, exportErr))
+	}
+	fmt.Fprintf(os.Stderr, "exported %s\n", progress)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/trifle-io/trifle-cli/internal/output"
+)
+
+func TestExportSeries(t *testing.T) {
+	t.Parallel()
+
+	at := hourlyBuckets(3)
+	var buf bytes.Buffer
+	writer := output.NewNDJSONWriter(&buf, false)
+	var progress exportProgress
+
+	err := exportSeries(writer, "event::logs", &progress, func(emit func(at time.Time, values map[string]any) error) error {
+		for i, bucket := range at {
+			values := map[string]any{"count": i + 1}
+			if i == 1 {
+				values = map[string]any{}
+			}
+			if err := emit(bucket, values); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("exportSeries error: %v", err)
+	}
+	if err := writer.Flush(); err != nil {
+		t.Fatalf("flush error: %v", err)
+	}
+
+	want := `{"key":"event::logs","at":"2026-10-15T09:00:00Z","values":{"count":1}}
+{"key":"event::logs","at":"2026-10-15T11:00:00Z","values":{"count":3}}
+`
+	if buf.String() != want {
+		t.Fatalf("output = %q, want %q", buf.String(), want)
+	}
+	if progress.Records != 2 || progress.Keys != 1 || !progress.At.Equal(at[2]) {
+		t.Fatalf("progress = %+v", progress)
+	}
+}
+
+func TestExportSeriesReportsProgressOnFailure(t *testing.T) {
+	t.Parallel()
+
+	at := hourlyBuckets(1)
+	writer := output.NewNDJSONWriter(&bytes.Buffer{}, false)
+	progress := exportProgress{Records: 4, Keys: 1}
+
+	boom := errors.New("chunk failed")
+	err := exportSeries(writer, "event::signups", &progress, func(emit func(at time.Time, values map[string]any) error) error {
+		if err := emit(at[0], map[string]any{"count": 1}); err != nil {
+			return err
+		}
+		return boom
+	})
+	if !errors.Is(err, boom) {
+		t.Fatalf("error = %v, want %v", err, boom)
+	}
+	if progress.Keys != 1 {
+		t.Fatalf("failed key was counted as done: %+v", progress)
+	}
+	if got := progress.String(); !strings.Contains(got, "5 records") || !strings.Contains(got, "event::signups at 2026-10-15T09:00:00Z") {
+		t.Fatalf("progress = %q", got)
+	}
+}
+
+func TestWriteExportOutputKeepsPartial(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "dump.ndjson")
+	boom := errors.New("chunk failed")
+	partial, err := writeExportOutput(&outputOptions{Path: path}, func(w io.Writer) error {
+		writer := output.NewNDJSONWriter(w, false)
+		if err := writer.Write(exportRecord{Key: "event::logs"}); err != nil {
+			return err
+		}
+		writer.Flush()
+		return boom
+	})
+	if !errors.Is(err, boom) || partial != path+".partial" {
+		t.Fatalf("writeExportOutput = %q, %v; want %s.partial and the chunk error", partial, err, path)
+	}
+	if contents, err := os.ReadFile(partial); err != nil || !strings.Contains(string(contents), `"key":"event::logs"`) {
+		t.Fatalf("partial file = %q (err %v), want the record written before the failure", contents, err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("output %s exists after a failed export (err %v)", path, err)
+	}
+
+	partial, err = writeExportOutput(&outputOptions{Path: path}, func(w io.Writer) error { return nil })
+	if err != nil || partial != "" {
+		t.Fatalf("writeExportOutput = %q, %v; want a plain commit", partial, err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("output not written: %v", err)
+	}
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	triflestats "github.com/trifle-io/trifle_stats_go"
+)
+
+// histogramBucket counts the points in [Lower, Upper); the last bucket also
+// holds points equal to its upper edge.
+type histogramBucket struct {
+	Range   string  `json:"range"`
+	Lower   float64 `json:"lower"`
+	Upper   float64 `json:"upper"`
+	Count   int     `json:"count"`
+	Percent float64 `json:"percent"`
+}
+
+// histogramResult is the distribution of one path's values. Below and Above
+// count points outside the edges; percentages are of all points.
+type histogramResult struct {
+	Points  int               `json:"points"`
+	Edges   []float64         `json:"edges"`
+	Buckets []histogramBucket `json:"buckets"`
+	Below   int               `json:"below"`
+	Above   int               `json:"above"`
+}
+
+// parseHistogramEdges reads --buckets as a comma-separated list of at least
+// two increasing edges. "auto" returns nil edges.
+func parseHistogramEdges(raw string) ([]float64, error) {
+	raw = strings.TrimSpace(raw)
+	if strings.EqualFold(raw, "auto") {
+		return nil, nil
+	}
+	var edges []float64
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		edge, err := strconv.ParseFloat(part, 64)
+		if err != nil || math.IsNaN(edge) || math.IsInf(edge, 0) {
+			return nil, usageErrorf("invalid bucket edge %q in --buckets", part)
+		}
+		if len(edges) > 0 && edge <= edges[len(edges)-1] {
+			return nil, usageErrorf("--buckets edges must be increasing (%g after %g)", edge, edges[len(edges)-1])
+		}
+		edges = append(edges, edge)
+	}
+	if len(edges) < 2 {
+		return nil, usageErrorf("--buckets needs at least two edges (e.g. 0,0.1,0.5,1) or auto")
+	}
+	return edges, nil
+}
+
+// autoHistogramEdges spreads Sturges' rule, ceil(log2(n)) + 1 buckets, evenly
+// between the smallest and largest value. A constant series gets a single
+// bucket around its value.
+func autoHistogramEdges(values []float64) []float64 {
+	if len(values) == 0 {
+		return nil
+	}
+	low, high := values[0], values[0]
+	for _, value := range values[1:] {
+		low = math.Min(low, value)
+		high = math.Max(high, value)
+	}
+	if low == high {
+		return []float64{low, high}
+	}
+	count := int(math.Ceil(math.Log2(float64(len(values))))) + 1
+	width := (high - low) / float64(count)
+	edges := make([]float64, count+1)
+	for i := range edges {
+		edges[i] = low + width*float64(i)
+	}
+	edges[count] = high
+	return edges
+}
+
+// buildHistogram counts values into the ranges between edges.
+func buildHistogram(values []float64, edges []float64) histogramResult {
+	result := histogramResult{Points: len(values), Edges: edges, Buckets: []histogramBucket{}}
+	if len(edges) == 0 {
+		return result
+	}
+	counts := make([]int, len(edges)-1)
+	last := edges[len(edges)-1]
+	for _, value := range values {
+		switch {
+		case value < edges[0]:
+			result.Below++
+		case value > last:
+			result.Above++
+		case value == last:
+			counts[len(counts)-1]++
+		default:
+			for i := range counts {
+				if value < edges[i+1] {
+					counts[i]++
+					break
+				}
+			}
+		}
+	}
+
+	for i, count := range counts {
+		closing := ")"
+		if i == len(counts)-1 {
+			closing = "]"
+		}
+		result.Buckets = append(result.Buckets, histogramBucket{
+			Range:   fmt.Sprintf("[%g, %g%s", edges[i], edges[i+1], closing),
+			Lower:   edges[i],
+			Upper:   edges[i+1],
+			Count:   count,
+			Percent: histogramPercent(count, len(values)),
+		})
+	}
+	return result
+}
+
+func histogramPercent(count, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(count) * 100 / float64(total)
+}
+
+// histogramValues collects the numeric values at path, one per bucket.
+func histogramValues(result triflestats.ValuesResult, path string) []float64 {
+	var values []float64
+	for _, row := range result.Values {
+		if len(row) == 0 {
+			continue
+		}
+		number, ok := triflestats.NormalizeNumeric(triflestats.FetchPath(row, path)).(float64)
+		if ok && !math.IsNaN(number) {
+			values = append(values, number)
+		}
+	}
+	return values
+}
+
+// histogramTable lays out the buckets as a table payload, with rows for
+// points outside the edges when there are any.
+func histogramTable(result histogramResult) map[string]any {
+	rows := make([]any, 0, len(result.Buckets)+2)
+	if result.Below > 0 {
+		rows = append(rows, []any{fmt.Sprintf("< %g", result.Edges[0]), result.Below, histogramPercent(result.Below, result.Points)})
+	}
+	for _, bucket := range result.Buckets {
+		rows = append(rows, []any{bucket.Range, bucket.Count, bucket.Percent})
+	}
+	if result.Above > 0 {
+		rows = append(rows, []any{fmt.Sprintf("> %g", result.Edges[len(result.Edges)-1]), result.Above, histogramPercent(result.Above, result.Points)})
+	}
+	return map[string]any{
+		"columns": []any{"range", "count", "percent"},
+		"rows":    rows,
+	}
+}
+
+func metricsHistogram(args []string) {
+	rc, err := resolveCommandConfig(args)
+	if err != nil {
+		exitError(err)
+	}
+
+	fs := flag.NewFlagSet("metrics histogram", flag.ExitOnError)
+	addConfigFlag(fs, rc.ConfigPath)
+	addSourceFlag(fs, rc.SourceName)
+	opts := addCommonFlags(fs, &rc.Source)
+	driverOpts := addDriverFlags(fs, &rc.Source)
+	key := fs.String("key", "", "Metrics key")
+	valuePath := fs.String("value-path", "", "Value path to bucket (e.g. duration)")
+	timeRange := addTimeRangeFlags(fs)
+	granularity := fs.String("granularity", "", "Granularity (e.g. 1h, 1d)")
+	buckets := fs.String("buckets", "auto", "Bucket edges (e.g. 0,0.1,0.5,1,5) or auto (Sturges' rule over min..max)")
+	format := fs.String("format", "json", "Output format: json|table|csv|markdown")
+	tableFlags := addTableFlags(fs)
+	explain := addExplainFlag(fs)
+	showStats := addStatsFlag(fs)
+	outputOpts := addOutputFlags(fs)
+	fs.Parse(args)
+
+	outputFormat := strings.ToLower(strings.TrimSpace(*format))
+	switch outputFormat {
+	case "json", "table", "csv", "markdown":
+	default:
+		exitError(usageErrorf("unsupported format %q (use json, table, csv, or markdown)", *format))
+	}
+	if err := outputOpts.validate(outputFormat); err != nil {
+		exitError(err)
+	}
+	if *key == "" || *valuePath == "" {
+		exitError(usageErrorf("--key and --value-path are required"))
+	}
+	if err := ensureNoWildcards(*valuePath); err != nil {
+		exitError(usageErrorf("%v", err))
+	}
+	edges, err := parseHistogramEdges(*buckets)
+	if err != nil {
+		exitError(err)
+	}
+	tableOpts, err := tableFlags.options(nil)
+	if err != nil {
+		exitError(err)
+	}
+
+	stats := newCommandStats(*showStats && !*explain, statsDriverName(driverOpts.Driver))
+	defer stats.finish()
+
+	var fromValue, toValue, granularityValue string
+	var result triflestats.ValuesResult
+
+	if isLocalDriver(driverOpts.Driver) {
+		local, err := prepareLocalConfig(driverOpts)
+		if err != nil {
+			exitError(err)
+		}
+		cfg := local.Config
+
+		timeRange.Default = rc.Source.DefaultTimeframe
+		fromValue, toValue, err = resolveCommandTimeRange(timeRange, driverOpts)
+		if err != nil {
+			exitError(err)
+		}
+		granularityValue, err = resolveGranularityLocal(*granularity, cfg)
+		if err != nil {
+			exitError(err)
+		}
+		warnTimeframePoints(fromValue, toValue, granularityValue)
+
+		if *explain {
+			plan := localPlan("metrics histogram", local, driverOpts, map[string]any{
+				"key":         *key,
+				"value_path":  *valuePath,
+				"from":        fromValue,
+				"to":          toValue,
+				"granularity": granularityValue,
+				"buckets":     *buckets,
+			})
+			if err := writeJSONOutput(outputOpts, plan); err != nil {
+				exitError(err)
+			}
+			return
+		}
+		if err := local.connect(driverOpts); err != nil {
+			exitError(err)
+		}
+
+		fromTime, err := time.Parse(time.RFC3339Nano, fromValue)
+		if err != nil {
+			exitError(err)
+		}
+		toTime, err := time.Parse(time.RFC3339Nano, toValue)
+		if err != nil {
+			exitError(err)
+		}
+		stats.granularity = granularityValue
+		err = stats.timeQuery(func() (err error) {
+			result, err = triflestats.Values(cfg, *key, fromTime, toTime, granularityValue, false)
+			return err
+		})
+		if err != nil {
+			exitError(driverError(maybeSuggestSetup(err, local.DriverName, local.TableName)))
+		}
+	} else {
+		if !*explain {
+			if err := ensureToken(opts, true); err != nil {
+				exitError(err)
+			}
+		}
+		client, err := newClient(opts)
+		if err != nil {
+			exitError(err)
+		}
+		source := newSourceLookup(client)
+		stats.client = client
+
+		fromValue, toValue, err = resolveSourceTimeRange(context.Background(), source, timeRange, driverOpts)
+		if err != nil {
+			exitError(err)
+		}
+		granularityValue, err = resolveGranularityValue(context.Background(), source, *granularity)
+		if err != nil {
+			exitError(err)
+		}
+		warnTimeframePoints(fromValue, toValue, granularityValue)
+		stats.granularity = granularityValue
+
+		params := map[string]string{
+			"key":         *key,
+			"from":        fromValue,
+			"to":          toValue,
+			"granularity": granularityValue,
+		}
+		if *explain {
+			request, err := explainGetMetrics(client, params, driverOpts.BeginningOfWeek)
+			if err != nil {
+				exitError(err)
+			}
+			plan := apiPlan("metrics histogram", opts, request)
+			plan["value_path"] = *valuePath
+			plan["buckets"] = *buckets
+			if err := writeJSONOutput(outputOpts, plan); err != nil {
+				exitError(err)
+			}
+			return
+		}
+
+		result, err = fetchMetricsChunked(context.Background(), client, params, driverOpts, 0)
+		if err != nil {
+			exitError(err)
+		}
+	}
+	stats.points = len(result.At)
+
+	values := histogramValues(result, *valuePath)
+	if len(values) == 0 {
+		exitError(fmt.Errorf("no numeric values found for path %s in the selected timeframe", *valuePath))
+	}
+	if edges == nil {
+		edges = autoHistogramEdges(values)
+	}
+	histogram := buildHistogram(values, edges)
+
+	payload := map[string]any{
+		"data": map[string]any{
+			"key":        *key,
+			"value_path": *valuePath,
+			"timeframe":  buildTimeframePayload(fromValue, toValue, granularityValue, timeRange.Timeframe),
+			"result":     histogram,
+		},
+	}
+	if outputFormat != "json" {
+		payload = map[string]any{"table": histogramTable(histogram)}
+	}
+	if err := writeTableOrJSONOutput(outputOpts, payload, outputFormat, tableOpts); err != nil {
+		exitError(err)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	triflestats "github.com/trifle-io/trifle_stats_go"
+)
+
+func TestParseHistogramEdges(t *testing.T) {
+	t.Parallel()
+
+	edges, err := parseHistogramEdges(" 0, 0.1,0.5,1 ,5")
+	if err != nil {
+		t.Fatalf("parseHistogramEdges error: %v", err)
+	}
+	if want := []float64{0, 0.1, 0.5, 1, 5}; !reflect.DeepEqual(edges, want) {
+		t.Fatalf("edges = %v, want %v", edges, want)
+	}
+	if edges, err := parseHistogramEdges("AUTO"); err != nil || edges != nil {
+		t.Fatalf("auto = %v, %v, want nil edges", edges, err)
+	}
+	for _, input := range []string{"", "1", "0,abc", "0,5,5", "5,1"} {
+		if _, err := parseHistogramEdges(input); err == nil {
+			t.Fatalf("expected error for %q", input)
+		}
+	}
+}
+
+func TestBuildHistogram(t *testing.T) {
+	t.Parallel()
+
+	result := buildHistogram([]float64{-1, 0, 0.05, 0.3, 0.7, 1, 5, 9}, []float64{0, 0.1, 0.5, 1, 5})
+	if result.Points != 8 || result.Below != 1 || result.Above != 1 {
+		t.Fatalf("points/below/above = %d/%d/%d, want 8/1/1", result.Points, result.Below, result.Above)
+	}
+	var counts []int
+	var ranges []string
+	for _, bucket := range result.Buckets {
+		counts = append(counts, bucket.Count)
+		ranges = append(ranges, bucket.Range)
+	}
+	if want := []int{2, 1, 1, 2}; !reflect.DeepEqual(counts, want) {
+		t.Fatalf("counts = %v, want %v", counts, want)
+	}
+	if want := []string{"[0, 0.1)", "[0.1, 0.5)", "[0.5, 1)", "[1, 5]"}; !reflect.DeepEqual(ranges, want) {
+		t.Fatalf("ranges = %v, want %v", ranges, want)
+	}
+	if result.Buckets[0].Percent != 25 {
+		t.Fatalf("percent = %v, want 25", result.Buckets[0].Percent)
+	}
+
+	rows := histogramTable(result)["rows"].([]any)
+	if len(rows) != 6 || rows[0].([]any)[0] != "< 0" || rows[5].([]any)[0] != "> 5" {
+		t.Fatalf("table rows = %v, want below and above rows around the buckets", rows)
+	}
+}
+
+func TestAutoHistogramEdges(t *testing.T) {
+	t.Parallel()
+
+	values := []float64{2, 4, 6, 8, 10, 3, 5, 7}
+	edges := autoHistogramEdges(values)
+	if want := []float64{2, 4, 6, 8, 10}; !reflect.DeepEqual(edges, want) {
+		t.Fatalf("edges = %v, want %v", edges, want)
+	}
+	total := 0
+	for _, bucket := range buildHistogram(values, edges).Buckets {
+		total += bucket.Count
+	}
+	if total != len(values) {
+		t.Fatalf("bucketed %d points, want %d", total, len(values))
+	}
+
+	if edges := autoHistogramEdges([]float64{3, 3}); !reflect.DeepEqual(edges, []float64{3, 3}) {
+		t.Fatalf("constant edges = %v, want [3 3]", edges)
+	}
+}
+
+func TestHistogramValues(t *testing.T) {
+	t.Parallel()
+
+	values := histogramValues(triflestats.ValuesResult{
+		At: hourlyBuckets(4),
+		Values: []map[string]any{
+			{"duration": map[string]any{"sum": 1.5}},
+			{},
+			{"duration": map[string]any{"sum": "n/a"}},
+			{"duration": map[string]any{"sum": 4}},
+		},
+	}, "duration.sum")
+	if want := []float64{1.5, 4}; !reflect.DeepEqual(values, want) {
+		t.Fatalf("values = %v, want %v", values, want)
+	}
+}
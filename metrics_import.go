@@ -0,0 +1,296 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultImportConcurrency = 4
+	maxImportLineBytes       = 16 * 1024 * 1024
+)
+
+// importRecord is one parsed line of an import file.
+type importRecord struct {
+	Line   int
+	Key    string
+	At     string
+	Values map[string]any
+}
+
+type importFailure struct {
+	Line  int    `json:"line"`
+	Key   string `json:"key,omitempty"`
+	Error string `json:"error"`
+}
+
+type importSummary struct {
+	File     string          `json:"file"`
+	Mode     string          `json:"mode"`
+	DryRun   bool            `json:"dry_run,omitempty"`
+	Total    int             `json:"total"`
+	Imported int             `json:"imported"`
+	Failed   int             `json:"failed"`
+	Keys     int             `json:"keys"`
+	Failures []importFailure `json:"failures"`
+}
+
+// parseImportLine decodes one NDJSON line as written by metrics export.
+// Records without a key fall back to defaultKey.
+func parseImportLine(line []byte, number int, defaultKey string) (importRecord, error) {
+	record := importRecord{Line: number}
+	var raw map[string]any
+	if err := json.Unmarshal(line, &raw); err != nil {
+		return record, fmt.Errorf("parse JSON: %w", err)
+	}
+
+	key, _ := raw["key"].(string)
+	record.Key = firstNonEmpty(strings.TrimSpace(key), defaultKey)
+	if record.Key == "" {
+		return record, fmt.Errorf("key is required (set it in the record or pass --key)")
+	}
+	if raw["at"] == nil {
+		return record, fmt.Errorf("at is required")
+	}
+
+	at, values, err := parsePushPoint(raw, "")
+	record.At = at
+	if err != nil {
+		return record, err
+	}
+	record.Values = values
+	return record, nil
+}
+
+// importRecords reads NDJSON records from r and hands each valid one to
+// write, running at most workers writes at once. Invalid lines and failed
+// writes are recorded in the summary instead of stopping the import. A nil
+// write only validates the records.
+func importRecords(r io.Reader, defaultKey string, workers int, write func(record importRecord) error) (importSummary, error) {
+	summary := importSummary{Failures: []importFailure{}}
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		keys = map[string]struct{}{}
+	)
+	fail := func(record importRecord, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		summary.Failures = append(summary.Failures, importFailure{Line: record.Line, Key: record.Key, Error: err.Error()})
+	}
+
+	jobs := make(chan importRecord)
+	for w := 0; w < max(workers, 1); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for record := range jobs {
+				if write != nil {
+					if err := write(record); err != nil {
+						fail(record, err)
+						continue
+					}
+				}
+				mu.Lock()
+				summary.Imported++
+				keys[record.Key] = struct{}{}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxImportLineBytes)
+	number := 0
+	for scanner.Scan() {
+		number++
+		line := scanner.Bytes()
+		if strings.TrimSpace(string(line)) == "" {
+			continue
+		}
+		summary.Total++
+		record, err := parseImportLine(line, number, defaultKey)
+		if err != nil {
+			fail(record, err)
+			continue
+		}
+		jobs <- record
+	}
+	close(jobs)
+	wg.Wait()
+
+	sort.Slice(summary.Failures, func(i, j int) bool {
+		return summary.Failures[i].Line < summary.Failures[j].Line
+	})
+	summary.Failed = len(summary.Failures)
+	summary.Keys = len(keys)
+	if err := scanner.Err(); err != nil {
+		return summary, fmt.Errorf("read line %d: %w", number+1, err)
+	}
+	return summary, nil
+}
+
+func openImportFile(path string) (io.ReadCloser, error) {
+	if path == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+	file, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("open import file: %w", err)
+	}
+	return file, nil
+}
+
+func metricsImport(args []string) {
+	rc, err := resolveCommandConfig(args)
+	if err != nil {
+		exitError(err)
+	}
+
+	fs := flag.NewFlagSet("metrics import", flag.ExitOnError)
+	addConfigFlag(fs, rc.ConfigPath)
+	addSourceFlag(fs, rc.SourceName)
+	opts := addCommonFlags(fs, &rc.Source)
+	driverOpts := addDriverFlags(fs, &rc.Source)
+	file := fs.String("file", "", "NDJSON file written by metrics export (- for stdin)")
+	key := fs.String("key", "", "Key for records that do not carry one")
+	mode := fs.String("mode", "track", "Mode: track|assert (assert needs a local driver)")
+	concurrency := fs.Int("concurrency", defaultImportConcurrency, "Number of records posted at once (api driver)")
+	dryRun := fs.Bool("dry-run", false, "Validate the file without writing anything")
+	explain := fs.Bool("explain", false, "Print the resolved request and exit without running it")
+	outputOpts := addOutputFlags(fs)
+	fs.Parse(args)
+
+	if err := outputOpts.validate(""); err != nil {
+		exitError(err)
+	}
+	if *file == "" {
+		exitError(usageErrorf("--file is required"))
+	}
+	if *concurrency < 1 {
+		exitError(usageErrorf("--concurrency must be at least 1"))
+	}
+	modeName, err := resolveWriteMode(*mode, isLocalDriver(driverOpts.Driver))
+	if err != nil {
+		exitError(err)
+	}
+
+	reader, err := openImportFile(*file)
+	if err != nil {
+		exitError(err)
+	}
+	defer reader.Close()
+
+	run := func(workers int, write func(record importRecord) error) importSummary {
+		if *dryRun {
+			write = nil
+		}
+		summary, err := importRecords(reader, *key, workers, write)
+		summary.File = *file
+		summary.Mode = modeName
+		summary.DryRun = *dryRun
+		if err != nil {
+			exitError(fmt.Errorf("import stopped after %d records: %w", summary.Total, err))
+		}
+		return summary
+	}
+
+	if isLocalDriver(driverOpts.Driver) {
+		local, err := prepareLocalConfig(driverOpts)
+		if err != nil {
+			exitError(err)
+		}
+		cfg := local.Config
+
+		if *explain {
+			plan := localPlan("metrics import", local, driverOpts, map[string]any{
+				"file": *file,
+				"key":  *key,
+				"mode": modeName,
+			})
+			if err := writeJSONOutput(outputOpts, plan); err != nil {
+				exitError(err)
+			}
+			return
+		}
+		if *dryRun {
+			printImportSummary(outputOpts, run(1, nil))
+			return
+		}
+		if err := local.connect(driverOpts); err != nil {
+			exitError(err)
+		}
+
+		// Local writes go through the config's buffer one at a time; the
+		// buffer batches them and the final shutdown flushes what is left.
+		summary := run(1, func(record importRecord) error {
+			atTime, err := time.Parse(time.RFC3339Nano, record.At)
+			if err != nil {
+				return err
+			}
+			return performLocalWrite(cfg, modeName, record.Key, atTime, record.Values)
+		})
+		if err := cfg.ShutdownBuffer(); err != nil {
+			exitError(driverError(maybeSuggestSetup(err, local.DriverName, local.TableName)))
+		}
+		printImportSummary(outputOpts, summary)
+		return
+	}
+
+	if !*explain && !*dryRun {
+		if err := ensureToken(opts, true); err != nil {
+			exitError(err)
+		}
+	}
+
+	client, err := newClient(opts)
+	if err != nil {
+		exitError(err)
+	}
+
+	if *explain {
+		plan := apiPlan("metrics import", opts, client.DescribePostMetrics(nil))
+		plan["file"] = *file
+		plan["concurrency"] = *concurrency
+		if err := writeJSONOutput(outputOpts, plan); err != nil {
+			exitError(err)
+		}
+		return
+	}
+
+	// The metrics endpoint takes a single point per request, so records are
+	// posted one by one with a bounded number in flight.
+	summary := run(*concurrency, func(record importRecord) error {
+		payload := map[string]any{
+			"key":    record.Key,
+			"at":     record.At,
+			"values": record.Values,
+		}
+		return client.PostMetrics(context.Background(), payload, nil)
+	})
+	printImportSummary(outputOpts, summary)
+}
+
+func printImportSummary(outputOpts *outputOptions, summary importSummary) {
+	verb := "imported"
+	if summary.DryRun {
+		verb = "validated"
+	}
+	fmt.Fprintf(os.Stderr, "%s %d of %d records (%d failed)\n", verb, summary.Imported, summary.Total, summary.Failed)
+	if err := writeJSONOutput(outputOpts, map[string]any{"data": summary}); err != nil {
+		exitError(err)
+	}
+	if summary.Failed > 0 {
+		os.Exit(1)
+	}
+}
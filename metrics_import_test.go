@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestParseImportLine(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		line       string
+		defaultKey string
+		want       importRecord
+		wantErr    string
+	}{
+		{
+			name: "export record",
+			line: `{"key":"event::logs","at":"2026-10-15T09:00:00Z","values":{"count":2}}`,
+			want: importRecord{Line: 1, Key: "event::logs", At: "2026-10-15T09:00:00Z", Values: map[string]any{"count": float64(2)}},
+		},
+		{
+			name:       "default key",
+			line:       `{"at":1792054800,"values":{"count":1}}`,
+			defaultKey: "event::signups",
+			want:       importRecord{Line: 1, Key: "event::signups", At: "2026-10-15T09:00:00Z", Values: map[string]any{"count": float64(1)}},
+		},
+		{
+			name:    "missing key",
+			line:    `{"at":"2026-10-15T09:00:00Z","values":{"count":1}}`,
+			wantErr: "key is required",
+		},
+		{
+			name:    "missing at",
+			line:    `{"key":"event::logs","values":{"count":1}}`,
+			wantErr: "at is required",
+		},
+		{
+			name:    "values not an object",
+			line:    `{"key":"event::logs","at":"2026-10-15T09:00:00Z","values":[1]}`,
+			wantErr: "values must be a JSON object",
+		},
+		{
+			name:    "invalid JSON",
+			line:    `{"key":`,
+			wantErr: "parse JSON",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := parseImportLine([]byte(tt.line), 1, tt.defaultKey)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("record = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImportRecordsCountsFailures(t *testing.T) {
+	t.Parallel()
+
+	input := strings.Join([]string{
+		`{"key":"event::logs","at":"2026-10-15T09:00:00Z","values":{"count":1}}`,
+		``,
+		`not json`,
+		`{"key":"event::logs","at":"2026-10-15T10:00:00Z","values":{"count":2}}`,
+		`{"key":"event::signups","at":"2026-10-15T11:00:00Z","values":{"count":3}}`,
+	}, "\n")
+
+	var writes atomic.Int32
+	summary, err := importRecords(strings.NewReader(input), "", 3, func(record importRecord) error {
+		writes.Add(1)
+		if record.Key == "event::signups" {
+			return errors.New("server rejected point")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("importRecords error: %v", err)
+	}
+
+	if writes.Load() != 3 {
+		t.Fatalf("writes = %d, want 3", writes.Load())
+	}
+	if summary.Total != 4 || summary.Imported != 2 || summary.Failed != 2 || summary.Keys != 1 {
+		t.Fatalf("summary = %+v", summary)
+	}
+	if summary.Failures[0].Line != 3 || summary.Failures[1].Line != 5 || summary.Failures[1].Key != "event::signups" {
+		t.Fatalf("failures = %+v", summary.Failures)
+	}
+}
+
+func TestImportRecordsDryRunOnlyValidates(t *testing.T) {
+	t.Parallel()
+
+	input := `{"at":"2026-10-15T09:00:00Z","values":{"count":1}}` + "\n" + `{"at":"bad","values":{"count":1}}` + "\n"
+	summary, err := importRecords(strings.NewReader(input), "event::logs", 1, nil)
+	if err != nil {
+		t.Fatalf("importRecords error: %v", err)
+	}
+	if summary.Total != 2 || summary.Imported != 1 || summary.Failed != 1 {
+		t.Fatalf("summary = %+v", summary)
+	}
+}
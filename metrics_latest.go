@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	triflestats "github.com/trifle-io/trifle_stats_go"
+)
+
+const defaultLatestWindow = "24h"
+
+// latestValue finds the most recent bucket holding a value at path. It
+// reports false when no bucket in the series does.
+func latestValue(result triflestats.ValuesResult, path string) (time.Time, any, bool) {
+	for i := len(result.At) - 1; i >= 0; i-- {
+		if i >= len(result.Values) || len(result.Values[i]) == 0 {
+			continue
+		}
+		if value := triflestats.NormalizeNumeric(triflestats.FetchPath(result.Values[i], path)); value != nil {
+			return result.At[i], value, true
+		}
+	}
+	return time.Time{}, nil, false
+}
+
+func metricsLatest(args []string) {
+	rc, err := resolveCommandConfig(args)
+	if err != nil {
+		exitError(err)
+	}
+
+	fs := flag.NewFlagSet("metrics latest", flag.ExitOnError)
+	addConfigFlag(fs, rc.ConfigPath)
+	addSourceFlag(fs, rc.SourceName)
+	opts := addCommonFlags(fs, &rc.Source)
+	driverOpts := addDriverFlags(fs, &rc.Source)
+	key := fs.String("key", "", "Metrics key")
+	valuePath := fs.String("value-path", "", "Value path (e.g. depth)")
+	window := fs.String("window", defaultLatestWindow, "How far back to look for a value (e.g. 1h, 7d)")
+	granularity := fs.String("granularity", "", "Granularity (e.g. 1m, 1h)")
+	format := fs.String("format", "json", "Output format: json|table|csv|markdown")
+	displayTZ := addDisplayTimeZoneFlag(fs, &rc.Source)
+	tableFlags := addTableFlags(fs)
+	explain := addExplainFlag(fs)
+	showStats := addStatsFlag(fs)
+	quiet := addQuietFlag(fs)
+	outputOpts := addOutputFlags(fs)
+	fs.Parse(args)
+
+	outputFormat := strings.ToLower(strings.TrimSpace(*format))
+	switch outputFormat {
+	case "json", "table", "csv", "markdown":
+	default:
+		exitError(usageErrorf("unsupported format %q (use json, table, csv, or markdown)", *format))
+	}
+	if err := outputOpts.validate(outputFormat); err != nil {
+		exitError(err)
+	}
+	if *quiet {
+		if err := validateQuiet(outputOpts, outputFormat); err != nil {
+			exitError(err)
+		}
+	}
+	if *key == "" || *valuePath == "" {
+		exitError(usageErrorf("--key and --value-path are required"))
+	}
+	if err := ensureNoWildcards(*valuePath); err != nil {
+		exitError(usageErrorf("%v", err))
+	}
+	*window = strings.ToLower(strings.TrimSpace(*window))
+	if !granularityPattern.MatchString(*window) {
+		exitError(usageErrorf("invalid --window %q (e.g. 1h, 24h, 7d)", *window))
+	}
+	displayLoc, err := resolveDisplayLocation(*displayTZ)
+	if err != nil {
+		exitError(err)
+	}
+	tableOpts, err := tableFlags.options(displayLoc)
+	if err != nil {
+		exitError(err)
+	}
+
+	stats := newCommandStats(*showStats && !*explain, statsDriverName(driverOpts.Driver))
+	defer stats.finish()
+
+	timeRange := &timeRangeOptions{Last: *window}
+	var fromValue, toValue, granularityValue string
+	var result triflestats.ValuesResult
+
+	if isLocalDriver(driverOpts.Driver) {
+		local, err := prepareLocalConfig(driverOpts)
+		if err != nil {
+			exitError(err)
+		}
+		cfg := local.Config
+
+		fromValue, toValue, err = resolveCommandTimeRange(timeRange, driverOpts)
+		if err != nil {
+			exitError(err)
+		}
+		granularityValue, err = resolveGranularityLocal(*granularity, cfg)
+		if err != nil {
+			exitError(err)
+		}
+		warnTimeframePoints(fromValue, toValue, granularityValue)
+
+		if *explain {
+			plan := localPlan("metrics latest", local, driverOpts, map[string]any{
+				"key":         *key,
+				"value_path":  *valuePath,
+				"from":        fromValue,
+				"to":          toValue,
+				"granularity": granularityValue,
+				"window":      *window,
+			})
+			if err := writeJSONOutput(outputOpts, plan); err != nil {
+				exitError(err)
+			}
+			return
+		}
+		if err := local.connect(driverOpts); err != nil {
+			exitError(err)
+		}
+
+		fromTime, err := time.Parse(time.RFC3339Nano, fromValue)
+		if err != nil {
+			exitError(err)
+		}
+		toTime, err := time.Parse(time.RFC3339Nano, toValue)
+		if err != nil {
+			exitError(err)
+		}
+		stats.granularity = granularityValue
+		err = stats.timeQuery(func() (err error) {
+			result, err = triflestats.Values(cfg, *key, fromTime, toTime, granularityValue, false)
+			return err
+		})
+		if err != nil {
+			exitError(driverError(maybeSuggestSetup(err, local.DriverName, local.TableName)))
+		}
+	} else {
+		if !*explain {
+			if err := ensureToken(opts, true); err != nil {
+				exitError(err)
+			}
+		}
+		client, err := newClient(opts)
+		if err != nil {
+			exitError(err)
+		}
+		source := newSourceLookup(client)
+		stats.client = client
+
+		fromValue, toValue, err = resolveCommandTimeRange(timeRange, driverOpts)
+		if err != nil {
+			exitError(err)
+		}
+		granularityValue, err = resolveGranularityValue(context.Background(), source, *granularity)
+		if err != nil {
+			exitError(err)
+		}
+		warnTimeframePoints(fromValue, toValue, granularityValue)
+		stats.granularity = granularityValue
+
+		params := map[string]string{
+			"key":         *key,
+			"from":        fromValue,
+			"to":          toValue,
+			"granularity": granularityValue,
+		}
+		if *explain {
+			request, err := explainGetMetrics(client, params, driverOpts.BeginningOfWeek)
+			if err != nil {
+				exitError(err)
+			}
+			plan := apiPlan("metrics latest", opts, request)
+			plan["value_path"] = *valuePath
+			plan["window"] = *window
+			if err := writeJSONOutput(outputOpts, plan); err != nil {
+				exitError(err)
+			}
+			return
+		}
+
+		result, err = fetchMetricsChunked(context.Background(), client, params, driverOpts, 0)
+		if err != nil {
+			exitError(err)
+		}
+	}
+	stats.points = len(result.At)
+
+	at, value, ok := latestValue(result, *valuePath)
+	if !ok {
+		exitError(fmt.Errorf("no value for path %s of %s in the last %s", *valuePath, *key, *window))
+	}
+
+	if *quiet {
+		if err := writeQuietLines(outputOpts, quietAggregateLines([]any{value})); err != nil {
+			exitError(err)
+		}
+		return
+	}
+
+	payload := map[string]any{
+		"status":     "ok",
+		"metric_key": *key,
+		"value_path": *valuePath,
+		"value":      value,
+		"at":         at.UTC().Format(time.RFC3339),
+		"window":     *window,
+		"timeframe":  buildTimeframePayload(fromValue, toValue, granularityValue, ""),
+	}
+	if outputFormat != "json" {
+		payload = map[string]any{
+			"table": map[string]any{
+				"columns": []any{"at", *valuePath},
+				"rows":    []any{[]any{at.UTC().Format(time.RFC3339), value}},
+			},
+		}
+	}
+	if err := writeTableOrJSONOutput(outputOpts, payload, outputFormat, tableOpts); err != nil {
+		exitError(err)
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+
+	triflestats "github.com/trifle-io/trifle_stats_go"
+)
+
+func TestLatestValue(t *testing.T) {
+	t.Parallel()
+
+	at := hourlyBuckets(5)
+	result := triflestats.ValuesResult{
+		At: at,
+		Values: []map[string]any{
+			{"depth": 3},
+			{"depth": 7, "workers": 2},
+			{"workers": 4},
+			{},
+		},
+	}
+
+	got, value, ok := latestValue(result, "depth")
+	if !ok || !got.Equal(at[1]) || value != 7.0 {
+		t.Fatalf("latestValue(depth) = %s, %v, %v, want %s, 7", got, value, ok, at[1])
+	}
+	got, value, ok = latestValue(result, "workers")
+	if !ok || !got.Equal(at[2]) || value != 4.0 {
+		t.Fatalf("latestValue(workers) = %s, %v, %v, want %s, 4", got, value, ok, at[2])
+	}
+	if _, _, ok := latestValue(result, "missing"); ok {
+		t.Fatal("expected no value for a missing path")
+	}
+	if _, _, ok := latestValue(triflestats.ValuesResult{}, "depth"); ok {
+		t.Fatal("expected no value for an empty series")
+	}
+}
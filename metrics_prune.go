@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// pruneGranularity is the per-granularity part of a prune summary.
+type pruneGranularity struct {
+	Granularity string `json:"granularity"`
+	From        string `json:"from"`
+	To          string `json:"to"`
+	Matched     int64  `json:"matched"`
+	Deleted     int64  `json:"deleted"`
+}
+
+type pruneSummary struct {
+	Key           string             `json:"key"`
+	Driver        string             `json:"driver"`
+	Table         string             `json:"table,omitempty"`
+	DryRun        bool               `json:"dry_run,omitempty"`
+	Matched       int64              `json:"matched"`
+	Deleted       int64              `json:"deleted"`
+	Granularities []pruneGranularity `json:"granularities"`
+}
+
+// confirmPrune asks on out and reads a yes/no answer from in.
+func confirmPrune(in io.Reader, out io.Writer, question string) (bool, error) {
+	fmt.Fprintf(out, "%s [y/N]: ", question)
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, fmt.Errorf("read confirmation: %w", err)
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
+
+func stdinIsTerminal() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func metricsPrune(args []string) {
+	rc, err := resolveCommandConfig(args)
+	if err != nil {
+		exitError(err)
+	}
+
+	fs := flag.NewFlagSet("metrics prune", flag.ExitOnError)
+	addConfigFlag(fs, rc.ConfigPath)
+	addSourceFlag(fs, rc.SourceName)
+	driverOpts := addDriverFlags(fs, &rc.Source)
+	key := fs.String("key", "", "Metrics key to prune")
+	timeRange := addTimeRangeFlags(fs)
+	granularity := fs.String("granularity", "", "Only prune this granularity (default: every configured granularity)")
+	yes := fs.Bool("yes", false, "Delete without asking for confirmation")
+	dryRun := fs.Bool("dry-run", false, "Print how many rows would be deleted without deleting them")
+	explain := fs.Bool("explain", false, "Print the resolved request and exit without running it")
+	outputOpts := addOutputFlags(fs)
+	fs.Parse(args)
+
+	if err := outputOpts.validate(""); err != nil {
+		exitError(err)
+	}
+	if !isLocalDriver(driverOpts.Driver) {
+		exitError(usageErrorf("metrics prune is only available for local drivers (sqlite/postgres/mysql/redis/mongo)"))
+	}
+	if *key == "" {
+		exitError(usageErrorf("--key is required"))
+	}
+	if timeRange.isEmpty() {
+		exitError(usageErrorf("a time range is required (--from/--to, --last, or --timeframe)"))
+	}
+
+	local, err := prepareLocalConfig(driverOpts)
+	if err != nil {
+		exitError(err)
+	}
+	cfg := local.Config
+
+	fromValue, toValue, err := resolveCommandTimeRange(timeRange, driverOpts)
+	if err != nil {
+		exitError(err)
+	}
+	fromTime, err := time.Parse(time.RFC3339Nano, fromValue)
+	if err != nil {
+		exitError(err)
+	}
+	toTime, err := time.Parse(time.RFC3339Nano, toValue)
+	if err != nil {
+		exitError(err)
+	}
+
+	granularities := cfg.EffectiveGranularities()
+	if strings.TrimSpace(*granularity) != "" {
+		value, err := validateGranularity(*granularity)
+		if err != nil {
+			exitError(err)
+		}
+		granularities = []string{value}
+	}
+	ranges, err := planPruneRanges(*key, fromTime, toTime, granularities, cfg)
+	if err != nil {
+		exitError(err)
+	}
+
+	summary := pruneSummary{
+		Key:           *key,
+		Driver:        local.DriverName,
+		Table:         local.TableName,
+		DryRun:        *dryRun,
+		Granularities: make([]pruneGranularity, len(ranges)),
+	}
+	for i, r := range ranges {
+		summary.Granularities[i] = pruneGranularity{
+			Granularity: r.Granularity,
+			From:        r.From.Format(time.RFC3339),
+			To:          r.To.Format(time.RFC3339),
+		}
+	}
+
+	if *explain {
+		plan := localPlan("metrics prune", local, driverOpts, map[string]any{
+			"key":           *key,
+			"from":          fromValue,
+			"to":            toValue,
+			"granularities": summary.Granularities,
+		})
+		if err := writeJSONOutput(outputOpts, plan); err != nil {
+			exitError(err)
+		}
+		return
+	}
+	if err := local.connect(driverOpts); err != nil {
+		exitError(err)
+	}
+	pruner, err := newMetricsPruner(cfg)
+	if err != nil {
+		exitError(err)
+	}
+
+	ctx := context.Background()
+	for i, r := range ranges {
+		matched, err := pruner.count(ctx, r)
+		if err != nil {
+			exitError(driverError(maybeSuggestSetup(err, local.DriverName, local.TableName)))
+		}
+		summary.Granularities[i].Matched = matched
+		summary.Matched += matched
+	}
+
+	if !*dryRun && summary.Matched > 0 && !*yes {
+		if !stdinIsTerminal() {
+			exitError(usageErrorf("refusing to delete %d rows without --yes (use --dry-run to only count them)", summary.Matched))
+		}
+		question := fmt.Sprintf("Delete %d rows for %s between %s and %s from %s?", summary.Matched, *key, fromValue, toValue, local.DriverName)
+		ok, err := confirmPrune(os.Stdin, os.Stderr, question)
+		if err != nil {
+			exitError(err)
+		}
+		if !ok {
+			exitError(fmt.Errorf("prune cancelled"))
+		}
+	}
+
+	if !*dryRun {
+		for i, r := range ranges {
+			if summary.Granularities[i].Matched == 0 {
+				continue
+			}
+			deleted, err := pruner.delete(ctx, r)
+			summary.Granularities[i].Deleted = deleted
+			summary.Deleted += deleted
+			if err == nil {
+				_, err = pruner.forget(ctx, r)
+			}
+			if err != nil {
+				exitError(driverError(fmt.Errorf("prune stopped after %d rows: %w", summary.Deleted, err)))
+			}
+		}
+		fmt.Fprintf(os.Stderr, "deleted %d rows for %s\n", summary.Deleted, *key)
+	}
+
+	if err := writeJSONOutput(outputOpts, map[string]any{"data": summary}); err != nil {
+		exitError(err)
+	}
+}
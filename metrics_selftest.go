@@ -0,0 +1,228 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	triflestats "github.com/trifle-io/trifle_stats_go"
+)
+
+// selftestValuePath is the value path the selftest writes and reads back.
+const selftestValuePath = "selftest"
+
+type selftestStep struct {
+	Name     string  `json:"name"`
+	Status   string  `json:"status"`
+	Duration float64 `json:"duration_ms"`
+	Detail   string  `json:"detail,omitempty"`
+}
+
+type selftestReport struct {
+	Status      string         `json:"status"`
+	Driver      string         `json:"driver"`
+	Table       string         `json:"table,omitempty"`
+	Buffered    bool           `json:"buffered"`
+	Key         string         `json:"key"`
+	At          string         `json:"at"`
+	Granularity string         `json:"granularity"`
+	Steps       []selftestStep `json:"steps"`
+}
+
+// failedStep returns the first step that failed, or nil when all passed.
+func (r selftestReport) failedStep() *selftestStep {
+	for i := range r.Steps {
+		if r.Steps[i].Status == "failed" {
+			return &r.Steps[i]
+		}
+	}
+	return nil
+}
+
+// runSelftest tracks value under key at at, flushes the buffer, reads the
+// granularity bucket back with Values, and deletes what it wrote unless keep
+// is set. A failed write or flush skips the read and the cleanup, since
+// nothing reached storage.
+func runSelftest(local *localDriverRuntime, key string, at time.Time, granularity string, value int64, keep bool) selftestReport {
+	cfg := local.Config
+	report := selftestReport{
+		Status:      "ok",
+		Driver:      local.DriverName,
+		Table:       local.TableName,
+		Buffered:    cfg.BufferEnabled,
+		Key:         key,
+		At:          at.UTC().Format(time.RFC3339Nano),
+		Granularity: granularity,
+	}
+	step := func(name string, fn func() (string, error)) bool {
+		started := time.Now()
+		detail, err := fn()
+		result := selftestStep{
+			Name:     name,
+			Status:   "ok",
+			Duration: roundTo(float64(time.Since(started))/float64(time.Millisecond), 3),
+			Detail:   detail,
+		}
+		if err != nil {
+			result.Status = "failed"
+			result.Detail = maybeSuggestSetup(err, local.DriverName, local.TableName).Error()
+			report.Status = "failed"
+		}
+		report.Steps = append(report.Steps, result)
+		return err == nil
+	}
+	skip := func(name, reason string) {
+		report.Steps = append(report.Steps, selftestStep{Name: name, Status: "skipped", Detail: reason})
+	}
+
+	written := step("write", func() (string, error) {
+		if err := triflestats.Track(cfg, key, at, map[string]any{selftestValuePath: value}); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("tracked %s=%d", selftestValuePath, value), nil
+	})
+
+	flushed := written
+	if !cfg.BufferEnabled {
+		skip("flush", "buffer disabled")
+	} else if written {
+		flushed = step("flush", func() (string, error) {
+			return "", cfg.ShutdownBuffer()
+		})
+	} else {
+		skip("flush", "write failed")
+	}
+
+	if flushed {
+		step("read", func() (string, error) {
+			result, err := triflestats.Values(cfg, key, at, at, granularity, false)
+			if err != nil {
+				return "", err
+			}
+			for _, values := range result.Values {
+				got := triflestats.NormalizeNumeric(triflestats.FetchPath(values, selftestValuePath))
+				if got == nil {
+					continue
+				}
+				if got != float64(value) {
+					return "", fmt.Errorf("read %s=%v back, wrote %d", selftestValuePath, got, value)
+				}
+				return fmt.Sprintf("read %s=%d back from the %s bucket", selftestValuePath, value, granularity), nil
+			}
+			return "", fmt.Errorf("the %s bucket holding %s is empty; the write is not visible to reads", granularity, report.At)
+		})
+	} else {
+		skip("read", "nothing to read")
+	}
+
+	switch {
+	case keep:
+		skip("cleanup", "--keep set")
+	case !flushed:
+		skip("cleanup", "nothing written")
+	default:
+		step("cleanup", func() (string, error) {
+			deleted, err := cleanupBenchKeys(cfg, []string{key}, at, at)
+			if err != nil {
+				return "", err
+			}
+			if deleted == 0 {
+				return "", fmt.Errorf("no rows found to delete for %s", key)
+			}
+			return fmt.Sprintf("deleted %d rows", deleted), nil
+		})
+	}
+	return report
+}
+
+// writeSelftestSummary prints report for a person reading a terminal.
+func writeSelftestSummary(w io.Writer, report selftestReport) error {
+	buffered := "unbuffered"
+	if report.Buffered {
+		buffered = "buffered"
+	}
+	lines := []string{fmt.Sprintf("selftest on %s (%s), key %s at %s", report.Driver, buffered, report.Key, report.At)}
+	for _, step := range report.Steps {
+		line := fmt.Sprintf("  %-7s %-8s", step.Status, step.Name)
+		if step.Status != "skipped" {
+			line += fmt.Sprintf(" %9.3fms", step.Duration)
+		}
+		if step.Detail != "" {
+			line += "  " + step.Detail
+		}
+		lines = append(lines, line)
+	}
+	lines = append(lines, "result: "+report.Status)
+	_, err := io.WriteString(w, strings.Join(lines, "\n")+"\n")
+	return err
+}
+
+func metricsSelftest(args []string) {
+	rc, err := resolveCommandConfig(args)
+	if err != nil {
+		exitError(err)
+	}
+
+	fs := flag.NewFlagSet("metrics selftest", flag.ExitOnError)
+	addConfigFlag(fs, rc.ConfigPath)
+	addSourceFlag(fs, rc.SourceName)
+	driverOpts := addDriverFlags(fs, &rc.Source)
+	keyPrefix := fs.String("key-prefix", "selftest", "Prefix of the temporary key; the run's unix time in ns is appended")
+	granularity := fs.String("granularity", "", "Granularity to read back (default: the first configured)")
+	keep := fs.Bool("keep", false, "Leave the written data in place instead of deleting it")
+	format := fs.String("format", "json", "Output format: json|summary")
+	outputOpts := addOutputFlags(fs)
+	fs.Parse(args)
+
+	outputFormat := strings.ToLower(strings.TrimSpace(*format))
+	switch outputFormat {
+	case "json", "summary":
+	default:
+		exitError(usageErrorf("unsupported format %q (use json or summary)", *format))
+	}
+	if err := outputOpts.validate(outputFormat); err != nil {
+		exitError(err)
+	}
+	if !isLocalDriver(driverOpts.Driver) {
+		exitError(usageErrorf("metrics selftest is only available for local drivers (sqlite/postgres/mysql/redis/mongo)"))
+	}
+
+	local, err := prepareLocalConfig(driverOpts)
+	if err != nil {
+		exitError(err)
+	}
+	granularityValue, err := resolveGranularityLocal(*granularity, local.Config)
+	if err != nil {
+		exitError(err)
+	}
+	if err := local.connect(driverOpts); err != nil {
+		exitError(err)
+	}
+
+	now := time.Now().UTC()
+	prefix := strings.TrimSpace(*keyPrefix)
+	if prefix == "" {
+		exitError(usageErrorf("--key-prefix cannot be empty"))
+	}
+	testKey := fmt.Sprintf("%s::%d", prefix, now.UnixNano())
+	// The value changes every run, so a read served from stale data does
+	// not pass by accident.
+	value := now.UnixNano()%1_000_000 + 1
+
+	report := runSelftest(local, testKey, now, granularityValue, value, *keep)
+	if outputFormat == "summary" {
+		err = writeCommandOutput(outputOpts, func(w io.Writer) error { return writeSelftestSummary(w, report) })
+	} else {
+		err = writeJSONOutput(outputOpts, map[string]any{"data": report})
+	}
+	if err != nil {
+		exitError(err)
+	}
+	if failed := report.failedStep(); failed != nil {
+		fmt.Fprintf(os.Stderr, "selftest failed at %s: %s\n", failed.Name, failed.Detail)
+		os.Exit(exitGeneral)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	triflestats "github.com/trifle-io/trifle_stats_go"
+)
+
+func newSelftestRuntime(t *testing.T, setup bool) *localDriverRuntime {
+	t.Helper()
+	local, err := loadLocalConfig(&driverOptions{
+		Driver:          "sqlite",
+		DBPath:          filepath.Join(t.TempDir(), "stats.db"),
+		Table:           "metrics",
+		Joined:          "full",
+		Separator:       "::",
+		TimeZone:        "UTC",
+		BeginningOfWeek: "monday",
+		Granularities:   "1h,1d",
+		BufferMode:      "off",
+	})
+	if err != nil {
+		t.Fatalf("loadLocalConfig returned error: %v", err)
+	}
+	if setup {
+		if err := local.Setup(); err != nil {
+			t.Fatalf("local.Setup returned error: %v", err)
+		}
+	}
+	return local
+}
+
+func selftestStatuses(report selftestReport) []string {
+	statuses := make([]string, len(report.Steps))
+	for i, step := range report.Steps {
+		statuses[i] = step.Name + ":" + step.Status
+	}
+	return statuses
+}
+
+func TestRunSelftest(t *testing.T) {
+	t.Parallel()
+
+	local := newSelftestRuntime(t, true)
+	at := hourlyBuckets(1)[0].Add(17 * time.Minute)
+	report := runSelftest(local, "selftest::1", at, "1h", 42, false)
+
+	want := []string{"write:ok", "flush:skipped", "read:ok", "cleanup:ok"}
+	if got := selftestStatuses(report); !reflect.DeepEqual(got, want) {
+		t.Fatalf("steps = %v, want %v (%+v)", got, want, report.Steps)
+	}
+	if report.Status != "ok" || report.failedStep() != nil {
+		t.Fatalf("status = %s, want ok", report.Status)
+	}
+	if report.Steps[3].Detail != "deleted 2 rows" {
+		t.Fatalf("cleanup detail = %q, want one hourly and one daily row", report.Steps[3].Detail)
+	}
+	result, err := triflestats.Values(local.Config, "selftest::1", at, at, "1h", true)
+	if err != nil {
+		t.Fatalf("Values returned error: %v", err)
+	}
+	if len(result.At) != 0 {
+		t.Fatalf("cleanup left %d buckets behind", len(result.At))
+	}
+	if got := listedKeys(t, local.Config, at, at, "1h"); len(got) != 0 {
+		t.Fatalf("metrics keys lists %v after cleanup, want nothing", got)
+	}
+
+	kept := runSelftest(local, "selftest::2", at, "1h", 7, true)
+	if got := selftestStatuses(kept); got[3] != "cleanup:skipped" {
+		t.Fatalf("steps = %v, want cleanup skipped with keep", got)
+	}
+	if got := listedKeys(t, local.Config, at, at, "1h"); !reflect.DeepEqual(got, []string{"selftest::2"}) {
+		t.Fatalf("metrics keys lists %v with keep, want selftest::2", got)
+	}
+
+	var out bytes.Buffer
+	if err := writeSelftestSummary(&out, report); err != nil {
+		t.Fatalf("writeSelftestSummary: %v", err)
+	}
+	for _, fragment := range []string{"selftest on sqlite (unbuffered)", "read selftest=42 back from the 1h bucket", "result: ok"} {
+		if !strings.Contains(out.String(), fragment) {
+			t.Fatalf("summary missing %q:\n%s", fragment, out.String())
+		}
+	}
+}
+
+func TestRunSelftestReadMismatch(t *testing.T) {
+	t.Parallel()
+
+	local := newSelftestRuntime(t, true)
+	at := hourlyBuckets(1)[0]
+	if err := triflestats.Track(local.Config, "selftest::1", at, map[string]any{"selftest": 5}); err != nil {
+		t.Fatalf("Track returned error: %v", err)
+	}
+	report := runSelftest(local, "selftest::1", at, "1h", 42, false)
+
+	failed := report.failedStep()
+	if report.Status != "failed" || failed == nil || failed.Name != "read" {
+		t.Fatalf("steps = %+v, want the read to fail", report.Steps)
+	}
+	if failed.Detail != "read selftest=47 back, wrote 42" {
+		t.Fatalf("detail = %q", failed.Detail)
+	}
+}
+
+func TestRunSelftestMissingSetup(t *testing.T) {
+	t.Parallel()
+
+	local := newSelftestRuntime(t, false)
+	report := runSelftest(local, "selftest::1", hourlyBuckets(1)[0], "1h", 42, false)
+
+	want := []string{"write:failed", "flush:skipped", "read:skipped", "cleanup:skipped"}
+	if got := selftestStatuses(report); !reflect.DeepEqual(got, want) {
+		t.Fatalf("steps = %v, want %v", got, want)
+	}
+	if detail := report.failedStep().Detail; !strings.Contains(detail, "trifle metrics setup --driver sqlite") {
+		t.Fatalf("detail = %q, want a setup hint", detail)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	triflestats "github.com/trifle-io/trifle_stats_go"
+)
+
+const defaultMaxTimelineGroups = 20
+
+// timelineGroupOptions configures metrics timeline --group-by-suffix.
+type timelineGroupOptions struct {
+	Prefix      string
+	ValuePath   string
+	Slices      int
+	MaxGroups   int
+	Concurrency int
+	Fill        fillOptions
+	Summary     bool
+}
+
+func (o timelineGroupOptions) validate() error {
+	if o.Prefix == "" {
+		return usageErrorf("--group-by-suffix requires --key-prefix")
+	}
+	if o.ValuePath == "" {
+		return usageErrorf("--value-path is required")
+	}
+	if err := ensureNoWildcards(o.ValuePath); err != nil {
+		return usageErrorf("--group-by-suffix needs a single value path: %v", err)
+	}
+	if o.MaxGroups < 1 {
+		return usageErrorf("--max-groups must be at least 1")
+	}
+	if o.Concurrency < 1 {
+		return usageErrorf("--concurrency must be at least 1")
+	}
+	return nil
+}
+
+// groupKeysBySuffix keeps the keys under prefix that have a non-empty
+// suffix, sorted, and fails when more than maxGroups match so a broad prefix
+// does not fan out into hundreds of queries.
+func groupKeysBySuffix(keys []string, prefix string, maxGroups int) ([]string, error) {
+	var matched []string
+	for _, key := range filterKeysByPrefix(keys, prefix) {
+		if key != prefix {
+			matched = append(matched, key)
+		}
+	}
+	sort.Strings(matched)
+	if len(matched) == 0 {
+		return nil, fmt.Errorf("no keys match --key-prefix %q in this timeframe", prefix)
+	}
+	if len(matched) > maxGroups {
+		return nil, usageErrorf("%d keys match --key-prefix %q, more than --max-groups %d; use a narrower prefix or raise --max-groups", len(matched), prefix, maxGroups)
+	}
+	return matched, nil
+}
+
+// buildTimelineGroups fetches each key's series and returns a timeline per
+// suffix plus a table with one column per suffix. Every series covers the
+// same timeframe, so rows follow the buckets of the first one. It also
+// returns the number of points fetched.
+func buildTimelineGroups(keys []string, opts timelineGroupOptions, fetch func(key string) (triflestats.ValuesResult, error)) (map[string]any, int, error) {
+	fetched, err := mapKeysConcurrently(keys, opts.Concurrency, func(key string, _ int) (any, error) {
+		result, err := fetch(key)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		return triflestats.SeriesFromResult(result), nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	groups := make(map[string]any, len(keys))
+	suffixes := make([]string, len(keys))
+	series := make([]triflestats.Series, len(keys))
+	points := 0
+	for i, key := range keys {
+		suffixes[i] = strings.TrimPrefix(key, opts.Prefix)
+		series[i] = fetched[i].(triflestats.Series)
+		points += len(series[i].At)
+		var point triflestats.TimelineTransform
+		if opts.Fill.enabled() {
+			series[i] = fillSeries(series[i], []string{opts.ValuePath}, opts.Fill)
+			point = nullableTimelinePoint
+		}
+		groups[suffixes[i]] = series[i].FormatTimeline(opts.ValuePath, opts.Slices, point)[opts.ValuePath]
+	}
+
+	columns := make([]any, 0, len(suffixes)+1)
+	columns = append(columns, "at")
+	for _, suffix := range suffixes {
+		columns = append(columns, suffix)
+	}
+	var at []time.Time
+	if len(series) > 0 {
+		at = series[0].At
+	}
+	rows := make([]any, 0, len(at))
+	for row, bucket := range at {
+		cells := make([]any, 0, len(series)+1)
+		cells = append(cells, bucket.Format(time.RFC3339))
+		for _, s := range series {
+			var cell any
+			if row < len(s.Values) && s.Values[row] != nil {
+				cell = triflestats.NormalizeNumeric(triflestats.FetchPath(s.Values[row], opts.ValuePath))
+			}
+			cells = append(cells, cell)
+		}
+		rows = append(rows, cells)
+	}
+
+	payload := map[string]any{
+		"status":     "ok",
+		"formatter":  "timeline",
+		"key_prefix": opts.Prefix,
+		"value_path": opts.ValuePath,
+		"slices":     opts.Slices,
+		"keys":       keys,
+		"groups":     groups,
+		"table":      map[string]any{"columns": columns, "rows": rows},
+	}
+	if opts.Fill.enabled() {
+		payload["fill"] = opts.Fill.Mode
+		payload["fill_edges"] = opts.Fill.Edges
+	}
+	if opts.Summary {
+		applyTimelineSummary(payload)
+	}
+	return payload, points, nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	triflestats "github.com/trifle-io/trifle_stats_go"
+)
+
+func TestGroupKeysBySuffix(t *testing.T) {
+	t.Parallel()
+
+	keys := []string{"event::logs::tenant-b", "event::logs", "event::logs::tenant-a", "event::other::x", "event::logs::"}
+	got, err := groupKeysBySuffix(keys, "event::logs::", 5)
+	if err != nil {
+		t.Fatalf("groupKeysBySuffix returned error: %v", err)
+	}
+	if want := []string{"event::logs::tenant-a", "event::logs::tenant-b"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("keys = %v, want %v", got, want)
+	}
+
+	if _, err := groupKeysBySuffix(keys, "event::logs::", 1); err == nil || !strings.Contains(err.Error(), "--max-groups 1") {
+		t.Fatalf("expected a max-groups error, got %v", err)
+	}
+	if _, err := groupKeysBySuffix(keys, "event::none::", 5); err == nil {
+		t.Fatal("expected an error when no keys match")
+	}
+}
+
+func TestBuildTimelineGroups(t *testing.T) {
+	t.Parallel()
+
+	at := hourlyBuckets(2)
+	series := map[string]triflestats.ValuesResult{
+		"event::logs::tenant-a": {At: at, Values: []map[string]any{{"count": 1}, {"count": 2}}},
+		"event::logs::tenant-b": {At: at, Values: []map[string]any{{}, {"count": 5}}},
+	}
+	opts := timelineGroupOptions{Prefix: "event::logs::", ValuePath: "count", Slices: 1, MaxGroups: 5, Concurrency: 2}
+	payload, points, err := buildTimelineGroups([]string{"event::logs::tenant-a", "event::logs::tenant-b"}, opts, func(key string) (triflestats.ValuesResult, error) {
+		return series[key], nil
+	})
+	if err != nil {
+		t.Fatalf("buildTimelineGroups returned error: %v", err)
+	}
+	if points != 4 {
+		t.Fatalf("points = %d, want 4", points)
+	}
+
+	groups := payload["groups"].(map[string]any)
+	if _, ok := groups["tenant-a"]; !ok {
+		t.Fatalf("groups = %v, want tenant-a", groups)
+	}
+	if _, ok := groups["tenant-b"]; !ok {
+		t.Fatalf("groups = %v, want tenant-b", groups)
+	}
+
+	table := payload["table"].(map[string]any)
+	if want := []any{"at", "tenant-a", "tenant-b"}; !reflect.DeepEqual(table["columns"], want) {
+		t.Fatalf("columns = %v, want %v", table["columns"], want)
+	}
+	want := []any{
+		[]any{"2026-10-15T09:00:00Z", float64(1), nil},
+		[]any{"2026-10-15T10:00:00Z", float64(2), float64(5)},
+	}
+	if !reflect.DeepEqual(table["rows"], want) {
+		t.Fatalf("rows = %v, want %v", table["rows"], want)
+	}
+
+	_, _, err = buildTimelineGroups([]string{"event::logs::tenant-a"}, opts, func(string) (triflestats.ValuesResult, error) {
+		return triflestats.ValuesResult{}, errors.New("boom")
+	})
+	if err == nil || !strings.Contains(err.Error(), "event::logs::tenant-a: boom") {
+		t.Fatalf("expected the failing key in the error, got %v", err)
+	}
+}
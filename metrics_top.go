@@ -0,0 +1,383 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/trifle-io/trifle-cli/internal/output"
+	triflestats "github.com/trifle-io/trifle_stats_go"
+)
+
+const defaultTopConcurrency = 4
+
+// topEntry is one ranked key in metrics top output.
+type topEntry struct {
+	Rank      int    `json:"rank"`
+	MetricKey string `json:"metric_key"`
+	Value     any    `json:"value"`
+}
+
+func metricsTop(args []string) {
+	rc, err := resolveCommandConfig(args)
+	if err != nil {
+		exitError(err)
+	}
+
+	fs := flag.NewFlagSet("metrics top", flag.ExitOnError)
+	addConfigFlag(fs, rc.ConfigPath)
+	addSourceFlag(fs, rc.SourceName)
+	opts := addCommonFlags(fs, &rc.Source)
+	driverOpts := addDriverFlags(fs, &rc.Source)
+	valuePath := fs.String("value-path", "", "Value path to aggregate for each key")
+	aggregator := fs.String("aggregator", "", "Aggregator (sum|mean|min|max|stddev|variance|p50|p90|p95|p99)")
+	timeRange := addTimeRangeFlags(fs)
+	granularity := fs.String("granularity", "", "Granularity (e.g. 1h, 1d)")
+	limit := fs.Int("limit", 10, "Number of keys to rank (0 for all)")
+	keyPrefix := fs.String("key-prefix", "", "Only rank keys starting with this prefix (e.g. event::)")
+	ascending := fs.Bool("asc", false, "Rank the lowest values first")
+	concurrency := fs.Int("concurrency", defaultTopConcurrency, "Number of keys to query at once")
+	format := fs.String("format", "json", "Output format: json|table|csv|markdown")
+	var csvDelimiter string
+	addCSVDelimiterFlag(fs, &csvDelimiter)
+	displayTZ := addDisplayTimeZoneFlag(fs, &rc.Source)
+	explain := addExplainFlag(fs)
+	showStats := addStatsFlag(fs)
+	quiet := addQuietFlag(fs)
+	outputOpts := addOutputFlags(fs)
+	fs.Parse(args)
+
+	if err := outputOpts.validate(*format); err != nil {
+		exitError(err)
+	}
+	if *quiet {
+		if err := validateQuiet(outputOpts, strings.ToLower(*format)); err != nil {
+			exitError(err)
+		}
+	}
+	if *valuePath == "" || *aggregator == "" {
+		exitError(usageErrorf("--value-path and --aggregator are required"))
+	}
+	if *limit < 0 {
+		exitError(usageErrorf("--limit must be 0 or greater"))
+	}
+	if *concurrency < 1 {
+		exitError(usageErrorf("--concurrency must be at least 1"))
+	}
+	aggName := strings.ToLower(strings.TrimSpace(*aggregator))
+
+	csvOpts, err := parseCSVDelimiter(csvDelimiter)
+	if err != nil {
+		exitError(err)
+	}
+	displayLoc, err := resolveDisplayLocation(*displayTZ)
+	if err != nil {
+		exitError(err)
+	}
+	tableOpts := output.TableOptions{DisplayLoc: displayLoc, CSV: csvOpts}
+
+	stats := newCommandStats(*showStats && !*explain, statsDriverName(driverOpts.Driver))
+	defer stats.finish()
+
+	var fromValue, toValue, granularityValue string
+	var values []any
+	var candidates []string
+
+	if isLocalDriver(driverOpts.Driver) {
+		local, err := prepareLocalConfig(driverOpts)
+		if err != nil {
+			exitError(err)
+		}
+		cfg := local.Config
+
+		timeRange.Default = rc.Source.DefaultTimeframe
+		fromValue, toValue, err = resolveCommandTimeRange(timeRange, driverOpts)
+		if err != nil {
+			exitError(err)
+		}
+		granularityValue, err = resolveGranularityLocal(*granularity, cfg)
+		if err != nil {
+			exitError(err)
+		}
+		warnTimeframePoints(fromValue, toValue, granularityValue)
+		stats.granularity = granularityValue
+
+		fromTime, err := time.Parse(time.RFC3339Nano, fromValue)
+		if err != nil {
+			exitError(err)
+		}
+		toTime, err := time.Parse(time.RFC3339Nano, toValue)
+		if err != nil {
+			exitError(err)
+		}
+
+		if *explain {
+			plan := localPlan("metrics top", local, driverOpts, map[string]any{
+				"key":         systemMetricsKey,
+				"key_prefix":  *keyPrefix,
+				"value_path":  *valuePath,
+				"aggregator":  aggName,
+				"from":        fromValue,
+				"to":          toValue,
+				"granularity": granularityValue,
+				"concurrency": *concurrency,
+			})
+			if err := writeJSONOutput(outputOpts, plan); err != nil {
+				exitError(err)
+			}
+			return
+		}
+		if err := local.connect(driverOpts); err != nil {
+			exitError(err)
+		}
+
+		var keysResult triflestats.ValuesResult
+		err = stats.timeQuery(func() (err error) {
+			keysResult, err = triflestats.Values(cfg, systemMetricsKey, fromTime, toTime, granularityValue, true)
+			return err
+		})
+		if err != nil {
+			exitError(driverError(maybeSuggestSetup(err, local.DriverName, local.TableName)))
+		}
+		candidates = filterKeysByPrefix(keysEntryNames(summarizeSystemKeys(keysResult.Values)), *keyPrefix)
+
+		points := make([]int, len(candidates))
+		err = stats.timeQuery(func() error {
+			values, err = mapKeysConcurrently(candidates, *concurrency, func(key string, i int) (any, error) {
+				result, err := triflestats.Values(cfg, key, fromTime, toTime, granularityValue, false)
+				if err != nil {
+					return nil, driverError(err)
+				}
+				points[i] = len(result.At)
+				aggregated, err := aggregateSeriesPath(triflestats.SeriesFromResult(result), aggName, *valuePath, 1)
+				if err != nil {
+					return nil, err
+				}
+				return firstAggregateValue(aggregated), nil
+			})
+			return err
+		})
+		if err != nil {
+			exitError(err)
+		}
+		stats.points = len(keysResult.At)
+		for _, count := range points {
+			stats.points += count
+		}
+	} else {
+		if !*explain {
+			if err := ensureToken(opts, true); err != nil {
+				exitError(err)
+			}
+		}
+
+		client, err := newClient(opts)
+		if err != nil {
+			exitError(err)
+		}
+		source := newSourceLookup(client)
+		stats.client = client
+
+		fromValue, toValue, err = resolveSourceTimeRange(context.Background(), source, timeRange, driverOpts)
+		if err != nil {
+			exitError(err)
+		}
+		granularityValue, err = resolveGranularityValue(context.Background(), source, *granularity)
+		if err != nil {
+			exitError(err)
+		}
+		warnTimeframePoints(fromValue, toValue, granularityValue)
+		stats.granularity = granularityValue
+
+		params := map[string]string{
+			"from":        fromValue,
+			"to":          toValue,
+			"granularity": granularityValue,
+		}
+		queryPayload := func(key string) map[string]any {
+			return map[string]any{
+				"mode":        "aggregate",
+				"key":         key,
+				"value_path":  *valuePath,
+				"aggregator":  aggName,
+				"from":        fromValue,
+				"to":          toValue,
+				"granularity": granularityValue,
+				"slices":      1,
+			}
+		}
+
+		if *explain {
+			request, err := explainGetMetrics(client, params, driverOpts.BeginningOfWeek)
+			if err != nil {
+				exitError(err)
+			}
+			plan := apiPlan("metrics top", opts, request)
+			perKey, err := explainQueryMetrics(client, queryPayload("<key>"), driverOpts.BeginningOfWeek)
+			if err != nil {
+				exitError(err)
+			}
+			plan["per_key"] = apiPlan("metrics top", opts, perKey)
+			plan["key_prefix"] = *keyPrefix
+			plan["concurrency"] = *concurrency
+			if err := writeJSONOutput(outputOpts, plan); err != nil {
+				exitError(err)
+			}
+			return
+		}
+
+		var response metricsResponse
+		if err := getMetrics(context.Background(), client, params, driverOpts.BeginningOfWeek, &response); err != nil {
+			exitError(err)
+		}
+		candidates = filterKeysByPrefix(keysEntryNames(summarizeKeys(response.Data.Values)), *keyPrefix)
+
+		values, err = mapKeysConcurrently(candidates, *concurrency, func(key string, _ int) (any, error) {
+			data, err := queryMetrics(context.Background(), client, queryPayload(key), driverOpts.BeginningOfWeek)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			return firstAggregateValue(aggregateResponseValues(data)), nil
+		})
+		if err != nil {
+			exitError(err)
+		}
+		stats.points = len(response.Data.At)
+	}
+
+	ranked := rankTopEntries(candidates, values, *ascending, *limit)
+	if *quiet {
+		lines := make([]string, len(ranked))
+		for i, entry := range ranked {
+			lines[i] = entry.MetricKey
+		}
+		if err := writeQuietLines(outputOpts, lines); err != nil {
+			exitError(err)
+		}
+		return
+	}
+
+	rows := make([]any, len(ranked))
+	for i, entry := range ranked {
+		rows[i] = []any{entry.Rank, entry.MetricKey, entry.Value}
+	}
+	payload := map[string]any{
+		"status":     "ok",
+		"aggregator": aggName,
+		"value_path": *valuePath,
+		"timeframe":  buildTimeframePayload(fromValue, toValue, granularityValue, timeRange.Timeframe),
+		"candidates": len(candidates),
+		"keys":       ranked,
+		"table": map[string]any{
+			"columns": []any{"rank", "metric_key", *valuePath},
+			"rows":    rows,
+		},
+	}
+	if *keyPrefix != "" {
+		payload["key_prefix"] = *keyPrefix
+	}
+	if err := writeTableOrJSONOutput(outputOpts, payload, strings.ToLower(*format), tableOpts); err != nil {
+		exitError(err)
+	}
+}
+
+func filterKeysByPrefix(keys []string, prefix string) []string {
+	if prefix == "" {
+		return keys
+	}
+	filtered := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if strings.HasPrefix(key, prefix) {
+			filtered = append(filtered, key)
+		}
+	}
+	return filtered
+}
+
+// mapKeysConcurrently calls fetch for every key with at most workers calls in
+// flight and returns the values in key order. The first error stops new
+// calls from starting.
+func mapKeysConcurrently(keys []string, workers int, fetch func(key string, i int) (any, error)) ([]any, error) {
+	values := make([]any, len(keys))
+	jobs := make(chan int)
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	failed := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return firstErr != nil
+	}
+
+	for w := 0; w < min(workers, len(keys)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if failed() {
+					continue
+				}
+				value, err := fetch(keys[i], i)
+				if err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+					continue
+				}
+				values[i] = value
+			}
+		}()
+	}
+	for i := range keys {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return values, nil
+}
+
+// rankTopEntries orders keys by value, highest first unless ascending, and
+// keeps the first limit (0 keeps all). Keys without a numeric value have no
+// data for the path and are left out; ties are broken by key name.
+func rankTopEntries(keys []string, values []any, ascending bool, limit int) []topEntry {
+	type scored struct {
+		key   string
+		value float64
+	}
+	entries := make([]scored, 0, len(keys))
+	for i, key := range keys {
+		if number, ok := triflestats.NormalizeNumeric(values[i]).(float64); ok {
+			entries = append(entries, scored{key: key, value: number})
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].value != entries[j].value {
+			if ascending {
+				return entries[i].value < entries[j].value
+			}
+			return entries[i].value > entries[j].value
+		}
+		return entries[i].key < entries[j].key
+	})
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+
+	ranked := make([]topEntry, len(entries))
+	for i, entry := range entries {
+		ranked[i] = topEntry{Rank: i + 1, MetricKey: entry.key, Value: entry.value}
+	}
+	return ranked
+}
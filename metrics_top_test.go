@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRankTopEntries(t *testing.T) {
+	t.Parallel()
+
+	keys := []string{"a", "b", "c", "d", "e"}
+	values := []any{5.0, nil, 12, 5.0, 1.5}
+
+	got := rankTopEntries(keys, values, false, 3)
+	want := []topEntry{
+		{Rank: 1, MetricKey: "c", Value: 12.0},
+		{Rank: 2, MetricKey: "a", Value: 5.0},
+		{Rank: 3, MetricKey: "d", Value: 5.0},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("rankTopEntries = %+v, want %+v", got, want)
+	}
+
+	ascending := rankTopEntries(keys, values, true, 0)
+	if len(ascending) != 4 || ascending[0].MetricKey != "e" {
+		t.Fatalf("ascending = %+v", ascending)
+	}
+}
+
+func TestMapKeysConcurrently(t *testing.T) {
+	t.Parallel()
+
+	keys := []string{"a", "b", "c", "d", "e", "f"}
+	var inFlight, peak int32
+	values, err := mapKeysConcurrently(keys, 2, func(key string, i int) (any, error) {
+		current := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			seen := atomic.LoadInt32(&peak)
+			if current <= seen || atomic.CompareAndSwapInt32(&peak, seen, current) {
+				break
+			}
+		}
+		return key + "!", nil
+	})
+	if err != nil {
+		t.Fatalf("mapKeysConcurrently error: %v", err)
+	}
+	if want := []any{"a!", "b!", "c!", "d!", "e!", "f!"}; !reflect.DeepEqual(values, want) {
+		t.Fatalf("values = %v, want %v", values, want)
+	}
+	if peak > 2 {
+		t.Fatalf("peak concurrency = %d, want at most 2", peak)
+	}
+
+	boom := errors.New("boom")
+	if _, err := mapKeysConcurrently(keys, 3, func(key string, _ int) (any, error) {
+		if key == "c" {
+			return nil, boom
+		}
+		return 1.0, nil
+	}); !errors.Is(err, boom) {
+		t.Fatalf("error = %v, want %v", err, boom)
+	}
+}
+
+func TestFilterKeysByPrefix(t *testing.T) {
+	t.Parallel()
+
+	got := filterKeysByPrefix([]string{"event::logs", "api::requests", "event::signups"}, "event::")
+	if want := []string{"event::logs", "event::signups"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("filterKeysByPrefix = %v, want %v", got, want)
+	}
+}
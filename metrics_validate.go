@@ -0,0 +1,120 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// pointValidation is the schema check of one pushed point.
+type pointValidation struct {
+	Index      int               `json:"index"`
+	At         string            `json:"at,omitempty"`
+	Violations []schemaViolation `json:"violations"`
+}
+
+// validatePayload checks a values object, or each {at, values} point of an
+// array, against schema. Points that cannot be parsed are reported as a
+// violation of the whole point.
+func validatePayload(schema keySchema, payload any) ([]pointValidation, error) {
+	points, batch := payload.([]any)
+	if !batch {
+		values, err := ensureValuesMap(payload)
+		if err != nil {
+			return nil, err
+		}
+		return []pointValidation{{Violations: validateSchemaValues(schema, values)}}, nil
+	}
+
+	results := make([]pointValidation, len(points))
+	for i, point := range points {
+		at, values, err := parsePushPoint(point, "")
+		results[i] = pointValidation{Index: i, At: at}
+		if err != nil {
+			results[i].Violations = []schemaViolation{{Path: "", Problem: err.Error()}}
+			continue
+		}
+		results[i].Violations = validateSchemaValues(schema, values)
+	}
+	return results, nil
+}
+
+func metricsValidate(args []string) {
+	// Schemas are shared by every source, so only the config file is
+	// resolved; --source is accepted like on the other metrics commands.
+	cfg, configPath, err := resolveConfig(args)
+	if err != nil {
+		exitError(err)
+	}
+
+	fs := flag.NewFlagSet("metrics validate", flag.ExitOnError)
+	addConfigFlag(fs, configPath)
+	addSourceFlag(fs, "")
+	key := fs.String("key", "", "Metrics key whose schema to check against")
+	valuesJSON := fs.String("values", "", "Values to check, as JSON (- reads them from stdin)")
+	valuesFile := fs.String("values-file", "", "JSON file with the values to check, or an array of {at, values} points to check one by one (- for stdin)")
+	var sets setFlag
+	fs.Var(&sets, "set", "Set one value path, e.g. count=1 (repeatable; wins over --values)")
+	outputOpts := addOutputFlags(fs)
+	fs.Parse(args)
+
+	if err := outputOpts.validate(""); err != nil {
+		exitError(err)
+	}
+	if *key == "" {
+		exitError(usageErrorf("--key is required"))
+	}
+	schema, err := lookupSchema(cfg, *key)
+	if err != nil {
+		exitError(err)
+	}
+
+	payload, err := readJSONPayload(os.Stdin, *valuesJSON, *valuesFile)
+	if err != nil {
+		exitError(err)
+	}
+	if len(sets) > 0 {
+		setValues, err := buildSetValues(sets)
+		if err != nil {
+			exitError(usageErrorf("%v", err))
+		}
+		if payload == nil {
+			payload = setValues
+		} else {
+			base, ok := payload.(map[string]any)
+			if !ok {
+				exitError(usageErrorf("--set can only be combined with a values object"))
+			}
+			payload = mergeSetValues(base, setValues)
+		}
+	}
+	if payload == nil {
+		exitError(usageErrorf("--values, --values-file, or --set is required"))
+	}
+
+	results, err := validatePayload(schema, payload)
+	if err != nil {
+		exitError(usageErrorf("%v", err))
+	}
+	invalid := 0
+	for _, result := range results {
+		if len(result.Violations) > 0 {
+			invalid++
+		}
+	}
+
+	response := map[string]any{
+		"key":     *key,
+		"valid":   invalid == 0,
+		"points":  len(results),
+		"invalid": invalid,
+		"results": results,
+	}
+	if err := writeJSONOutput(outputOpts, response); err != nil {
+		exitError(err)
+	}
+	if invalid > 0 {
+		fmt.Fprintf(os.Stderr, "%d of %d points do not match the schema for %s\n", invalid, len(results), *key)
+		os.Exit(exitGeneral)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+	"strings"
+)
+
+// splitPositionalKey pulls the metrics key given as a plain argument out of
+// args, wherever it sits among the flags: trifle metrics get event::logs
+// --last 24h. It asks fs which flags take a value so that a value like 24h
+// is never mistaken for the key. Arguments after "--" are plain arguments
+// too. More than one plain argument is an error.
+func splitPositionalKey(fs *flag.FlagSet, args []string) (string, []string, error) {
+	var positional []string
+	rest := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			positional = append(positional, args[i+1:]...)
+			break
+		}
+		if len(arg) < 2 || arg[0] != '-' {
+			positional = append(positional, arg)
+			continue
+		}
+		rest = append(rest, arg)
+		name := strings.TrimLeft(arg, "-")
+		if strings.Contains(name, "=") {
+			continue
+		}
+		if flagTakesValue(fs, name) && i+1 < len(args) {
+			i++
+			rest = append(rest, args[i])
+		}
+	}
+
+	switch len(positional) {
+	case 0:
+		return "", rest, nil
+	case 1:
+		return strings.TrimSpace(positional[0]), rest, nil
+	default:
+		return "", nil, usageErrorf("unexpected argument %q (only the metrics key may be given without a flag)", positional[1])
+	}
+}
+
+// flagTakesValue reports whether the named flag of fs consumes the next
+// argument. Unknown flags are left for fs.Parse to reject.
+func flagTakesValue(fs *flag.FlagSet, name string) bool {
+	defined := fs.Lookup(name)
+	if defined == nil {
+		return false
+	}
+	if boolFlag, ok := defined.Value.(interface{ IsBoolFlag() bool }); ok && boolFlag.IsBoolFlag() {
+		return false
+	}
+	return true
+}
+
+// parseKeyArgs parses args into fs, accepting the metrics key either as
+// --key or as a plain argument. Both may be given only when they agree.
+func parseKeyArgs(fs *flag.FlagSet, args []string, key *string) error {
+	positional, rest, err := splitPositionalKey(fs, args)
+	if err != nil {
+		return err
+	}
+	fs.Parse(rest)
+	if positional == "" {
+		return nil
+	}
+	if flagKey := strings.TrimSpace(*key); flagKey != "" && flagKey != positional {
+		return usageErrorf("key %q conflicts with --key %q", positional, flagKey)
+	}
+	*key = positional
+	return nil
+}
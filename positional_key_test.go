@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func newPositionalKeyFlagSet() (*flag.FlagSet, *string, *string, *bool) {
+	fs := flag.NewFlagSet("metrics get", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	key := fs.String("key", "", "Metrics key")
+	last := fs.String("last", "", "Window")
+	explain := fs.Bool("explain", false, "Explain")
+	return fs, key, last, explain
+}
+
+func TestParseKeyArgs(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		args        []string
+		wantKey     string
+		wantLast    string
+		wantExplain bool
+		wantErr     bool
+	}{
+		{name: "flags after key", args: []string{"event::logs", "--last", "24h"}, wantKey: "event::logs", wantLast: "24h"},
+		{name: "flags before key", args: []string{"--last", "24h", "event::logs"}, wantKey: "event::logs", wantLast: "24h"},
+		{name: "flags around key", args: []string{"--explain", "event::logs", "--last=6h"}, wantKey: "event::logs", wantLast: "6h", wantExplain: true},
+		{name: "bool flag does not take the key", args: []string{"--explain", "event::logs"}, wantKey: "event::logs", wantExplain: true},
+		{name: "flag value that looks like a key", args: []string{"--last", "event::logs"}, wantLast: "event::logs"},
+		{name: "key flag only", args: []string{"--key", "event::logs", "--last", "1h"}, wantKey: "event::logs", wantLast: "1h"},
+		{name: "matching key flag", args: []string{"event::logs", "--key", "event::logs"}, wantKey: "event::logs"},
+		{name: "after double dash", args: []string{"--last", "1h", "--", "event::logs"}, wantKey: "event::logs", wantLast: "1h"},
+		{name: "conflicting key flag", args: []string{"event::logs", "--key", "event::other"}, wantErr: true},
+		{name: "extra argument", args: []string{"event::logs", "event::other"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			fs, key, last, explain := newPositionalKeyFlagSet()
+			err := parseKeyArgs(fs, tt.args, key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseKeyArgs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if *key != tt.wantKey || *last != tt.wantLast || *explain != tt.wantExplain {
+				t.Fatalf("key=%q last=%q explain=%v, want key=%q last=%q explain=%v", *key, *last, *explain, tt.wantKey, tt.wantLast, tt.wantExplain)
+			}
+		})
+	}
+}
+
+func TestSplitPositionalKeyKeepsFlagOrder(t *testing.T) {
+	t.Parallel()
+
+	fs, _, _, _ := newPositionalKeyFlagSet()
+	key, rest, err := splitPositionalKey(fs, []string{"--last", "24h", "event::logs", "--explain", "--unknown"})
+	if err != nil {
+		t.Fatalf("splitPositionalKey returned error: %v", err)
+	}
+	if key != "event::logs" || !reflect.DeepEqual(rest, []string{"--last", "24h", "--explain", "--unknown"}) {
+		t.Fatalf("splitPositionalKey = %q, %v", key, rest)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	triflestats "github.com/trifle-io/trifle_stats_go"
+)
+
+// errPointSkipped marks an if-absent point whose bucket already held values.
+// Batch and stream pushes count it as skipped rather than failed.
+var errPointSkipped = errors.New("bucket already has values")
+
+// resolvePushMode extends resolveWriteMode with if-absent, which only
+// metrics push offers since it reports the points it skipped.
+func resolvePushMode(mode string, local bool) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case "", "track", "assert":
+		return resolveWriteMode(mode, local)
+	case "if-absent":
+		if !local {
+			return "", usageErrorf("if-absent mode is not supported by the api driver (the metrics endpoint cannot check a bucket before tracking); use a local driver")
+		}
+		return "if-absent", nil
+	default:
+		return "", usageErrorf("invalid mode: %s (expected track, assert, or if-absent)", mode)
+	}
+}
+
+// ifAbsentWriter tracks a point only when its bucket at the finest tracked
+// granularity is empty, for idempotent backfills. Buckets it has written are
+// remembered, so a batch cannot fill one twice while writes sit in the buffer.
+type ifAbsentWriter struct {
+	cfg         *triflestats.Config
+	granularity string
+	written     map[string]bool
+}
+
+func newIfAbsentWriter(cfg *triflestats.Config) (*ifAbsentWriter, error) {
+	granularity := finestGranularity(cfg.EffectiveGranularities())
+	if granularity == "" {
+		return nil, fmt.Errorf("if-absent mode needs at least one tracked granularity")
+	}
+	return &ifAbsentWriter{cfg: cfg, granularity: granularity, written: map[string]bool{}}, nil
+}
+
+// write tracks values at at unless the bucket already has values, and
+// reports whether the point was skipped.
+func (w *ifAbsentWriter) write(key string, at time.Time, values map[string]any) (bool, error) {
+	parser := triflestats.NewParser(w.granularity)
+	bucket := triflestats.NewNocturnal(at, w.cfg).Floor(parser.Offset, parser.Unit)
+	seen := key + "\x00" + bucket.UTC().Format(time.RFC3339Nano)
+	if w.written[seen] {
+		return true, nil
+	}
+
+	result, err := triflestats.Values(w.cfg, key, bucket, bucket, w.granularity, false)
+	if err != nil {
+		return false, err
+	}
+	for _, existing := range result.Values {
+		if len(existing) > 0 {
+			return true, nil
+		}
+	}
+
+	if err := triflestats.Track(w.cfg, key, at, values); err != nil {
+		return false, err
+	}
+	w.written[seen] = true
+	return false, nil
+}
+
+// finestGranularity returns the valid granularity with the shortest bucket,
+// or "" when there is none.
+func finestGranularity(granularities []string) string {
+	finest := ""
+	var shortest time.Duration
+	for _, granularity := range granularities {
+		parser := triflestats.NewParser(granularity)
+		if !parser.Valid() {
+			continue
+		}
+		size := approxUnitDurations[parser.Unit] * time.Duration(parser.Offset)
+		if finest == "" || size < shortest {
+			finest, shortest = granularity, size
+		}
+	}
+	return finest
+}
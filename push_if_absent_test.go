@@ -0,0 +1,110 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	triflestats "github.com/trifle-io/trifle_stats_go"
+)
+
+func TestResolvePushMode(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		mode    string
+		local   bool
+		want    string
+		wantErr string
+	}{
+		{mode: "", local: false, want: "track"},
+		{mode: "assert", local: true, want: "assert"},
+		{mode: "If-Absent", local: true, want: "if-absent"},
+		{mode: "if-absent", local: false, wantErr: "not supported by the api driver"},
+		{mode: "assert", local: false, wantErr: "not supported by the api driver"},
+		{mode: "replace", local: true, wantErr: "expected track, assert, or if-absent"},
+	}
+	for _, tt := range tests {
+		got, err := resolvePushMode(tt.mode, tt.local)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("resolvePushMode(%q, %v) error = %v, want %q", tt.mode, tt.local, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Fatalf("resolvePushMode(%q, %v) = %q (err %v), want %q", tt.mode, tt.local, got, err, tt.want)
+		}
+	}
+}
+
+func TestFinestGranularity(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		granularities []string
+		want          string
+	}{
+		{[]string{"1d", "1h", "1w"}, "1h"},
+		{[]string{"1mo", "15m", "1m"}, "1m"},
+		{[]string{"bogus", "1d"}, "1d"},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		if got := finestGranularity(tt.granularities); got != tt.want {
+			t.Fatalf("finestGranularity(%v) = %q, want %q", tt.granularities, got, tt.want)
+		}
+	}
+}
+
+func TestIfAbsentWriter(t *testing.T) {
+	t.Parallel()
+
+	local := newSelftestRuntime(t, true)
+	writer, err := newIfAbsentWriter(local.Config)
+	if err != nil {
+		t.Fatalf("newIfAbsentWriter returned error: %v", err)
+	}
+	if writer.granularity != "1h" {
+		t.Fatalf("granularity = %q, want 1h", writer.granularity)
+	}
+
+	hour := hourlyBuckets(2)
+	writes := []struct {
+		at          time.Time
+		wantSkipped bool
+	}{
+		{hour[0].Add(5 * time.Minute), false},
+		{hour[0].Add(40 * time.Minute), true},
+		{hour[1], false},
+	}
+	for _, w := range writes {
+		skipped, err := writer.write("backfill::points", w.at, map[string]any{"count": 1})
+		if err != nil {
+			t.Fatalf("write(%s) returned error: %v", w.at, err)
+		}
+		if skipped != w.wantSkipped {
+			t.Fatalf("write(%s) skipped = %v, want %v", w.at, skipped, w.wantSkipped)
+		}
+	}
+
+	// A fresh writer has no memory of the run above, so it reads the stored
+	// bucket to decide.
+	rerun, err := newIfAbsentWriter(local.Config)
+	if err != nil {
+		t.Fatalf("newIfAbsentWriter returned error: %v", err)
+	}
+	if skipped, err := rerun.write("backfill::points", hour[0], map[string]any{"count": 1}); err != nil || !skipped {
+		t.Fatalf("rerun write = %v (err %v), want skipped", skipped, err)
+	}
+
+	result, err := triflestats.Values(local.Config, "backfill::points", hour[0], hour[1], "1h", false)
+	if err != nil {
+		t.Fatalf("Values returned error: %v", err)
+	}
+	for i, values := range result.Values {
+		if triflestats.NormalizeNumeric(values["count"]) != 1.0 {
+			t.Fatalf("bucket %d count = %v, want 1", i, values["count"])
+		}
+	}
+}
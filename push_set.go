@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// setFlag collects repeatable --set path=value assignments.
+type setFlag []string
+
+func (s *setFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *setFlag) Set(value string) error {
+	if _, _, err := splitSetAssignment(value); err != nil {
+		return err
+	}
+	*s = append(*s, value)
+	return nil
+}
+
+func splitSetAssignment(assignment string) (string, string, error) {
+	path, value, ok := strings.Cut(assignment, "=")
+	path = strings.TrimSpace(path)
+	if !ok || path == "" {
+		return "", "", fmt.Errorf("expected path=value, got %q", assignment)
+	}
+	for _, segment := range strings.Split(path, ".") {
+		if segment == "" {
+			return "", "", fmt.Errorf("invalid path %q", path)
+		}
+	}
+	return path, strings.TrimSpace(value), nil
+}
+
+// parseSetValue reads value as a number when it is one and keeps it as a
+// string otherwise.
+func parseSetValue(value string) any {
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(number) || math.IsInf(number, 0) {
+		return value
+	}
+	return number
+}
+
+// buildSetValues turns path=value assignments into a nested values map,
+// splitting paths on dots. A later assignment to the same path wins.
+func buildSetValues(assignments []string) (map[string]any, error) {
+	paths := map[string]any{}
+	for _, assignment := range assignments {
+		path, value, err := splitSetAssignment(assignment)
+		if err != nil {
+			return nil, err
+		}
+		paths[path] = parseSetValue(value)
+	}
+	return nestSetPaths(paths)
+}
+
+// nestSetPaths expands dotted paths into nested maps.
+func nestSetPaths(paths map[string]any) (map[string]any, error) {
+	names := make([]string, 0, len(paths))
+	for path := range paths {
+		names = append(names, path)
+	}
+	sort.Strings(names)
+
+	values := map[string]any{}
+	for _, path := range names {
+		segments := strings.Split(path, ".")
+		node := values
+		for i, segment := range segments[:len(segments)-1] {
+			switch child := node[segment].(type) {
+			case nil:
+				next := map[string]any{}
+				node[segment] = next
+				node = next
+			case map[string]any:
+				node = child
+			default:
+				return nil, fmt.Errorf("set path %s conflicts with %s", path, strings.Join(segments[:i+1], "."))
+			}
+		}
+		last := segments[len(segments)-1]
+		if _, ok := node[last].(map[string]any); ok {
+			return nil, fmt.Errorf("set path %s conflicts with nested paths under it", path)
+		}
+		node[last] = paths[path]
+	}
+	return values, nil
+}
+
+// mergeSetValues deep-merges set into base; values from set win.
+func mergeSetValues(base, set map[string]any) map[string]any {
+	merged := make(map[string]any, len(base)+len(set))
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range set {
+		nested, setIsMap := value.(map[string]any)
+		existing, baseIsMap := merged[key].(map[string]any)
+		if setIsMap && baseIsMap {
+			merged[key] = mergeSetValues(existing, nested)
+			continue
+		}
+		merged[key] = value
+	}
+	return merged
+}
+
+// setArgValues reads the MCP write_metric "set" argument, an object of
+// dotted paths to values, into a nested values map. String values are read
+// as numbers when they are numbers, like --set.
+func setArgValues(raw any) (map[string]any, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	entries, ok := raw.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("set must be an object of path: value pairs")
+	}
+	paths := make(map[string]any, len(entries))
+	for path, value := range entries {
+		if _, _, err := splitSetAssignment(path + "="); err != nil {
+			return nil, err
+		}
+		switch typed := value.(type) {
+		case string:
+			paths[path] = parseSetValue(strings.TrimSpace(typed))
+		case float64, int, int64:
+			paths[path] = typed
+		default:
+			return nil, fmt.Errorf("set %s must be a number or string", path)
+		}
+	}
+	return nestSetPaths(paths)
+}
+
+// writeMetricValues resolves the write_metric values, merging the "set"
+// shorthand over "values" when both are given.
+func writeMetricValues(args map[string]any) (any, error) {
+	values, hasValues := args["values"]
+	set, err := setArgValues(args["set"])
+	if err != nil {
+		return nil, err
+	}
+	if set == nil {
+		if !hasValues {
+			return nil, fmt.Errorf("values or set is required")
+		}
+		return values, nil
+	}
+	if values == nil {
+		return set, nil
+	}
+	base, ok := values.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("set can only be combined with a values object")
+	}
+	return mergeSetValues(base, set), nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuildSetValues(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		assignments []string
+		want        map[string]any
+		wantErr     string
+	}{
+		{
+			name:        "numbers and nested paths",
+			assignments: []string{"count=1", "duration=2.4", "status.ok=1", "status.failed=0"},
+			want: map[string]any{
+				"count":    float64(1),
+				"duration": 2.4,
+				"status":   map[string]any{"ok": float64(1), "failed": float64(0)},
+			},
+		},
+		{
+			name:        "strings stay strings",
+			assignments: []string{"region=eu-west", "build=NaN", "note="},
+			want:        map[string]any{"region": "eu-west", "build": "NaN", "note": ""},
+		},
+		{
+			name:        "later assignment wins",
+			assignments: []string{"count=1", "count=2"},
+			want:        map[string]any{"count": float64(2)},
+		},
+		{
+			name:        "leaf and branch conflict",
+			assignments: []string{"status=1", "status.ok=1"},
+			wantErr:     "conflicts",
+		},
+		{
+			name:        "missing equals",
+			assignments: []string{"count"},
+			wantErr:     "expected path=value",
+		},
+		{
+			name:        "empty segment",
+			assignments: []string{"status..ok=1"},
+			wantErr:     "invalid path",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := buildSetValues(tt.assignments)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("values = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeSetValues(t *testing.T) {
+	t.Parallel()
+
+	base := map[string]any{"count": float64(5), "status": map[string]any{"ok": float64(1), "failed": float64(2)}}
+	set := map[string]any{"count": float64(1), "status": map[string]any{"failed": float64(0)}, "duration": 2.4}
+	got := mergeSetValues(base, set)
+	want := map[string]any{
+		"count":    float64(1),
+		"duration": 2.4,
+		"status":   map[string]any{"ok": float64(1), "failed": float64(0)},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("merged = %#v, want %#v", got, want)
+	}
+	if base["count"] != float64(5) {
+		t.Fatalf("base was modified: %#v", base)
+	}
+}
+
+func TestWriteMetricValues(t *testing.T) {
+	t.Parallel()
+
+	got, err := writeMetricValues(map[string]any{
+		"values": map[string]any{"count": float64(3)},
+		"set":    map[string]any{"count": float64(1), "status.ok": "1", "region": "eu"},
+	})
+	if err != nil {
+		t.Fatalf("writeMetricValues error: %v", err)
+	}
+	want := map[string]any{"count": float64(1), "status": map[string]any{"ok": float64(1)}, "region": "eu"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("values = %#v, want %#v", got, want)
+	}
+
+	if _, err := writeMetricValues(map[string]any{}); err == nil || !strings.Contains(err.Error(), "values or set is required") {
+		t.Fatalf("missing values error = %v", err)
+	}
+	if _, err := writeMetricValues(map[string]any{"values": []any{}, "set": map[string]any{"count": 1}}); err == nil {
+		t.Fatal("expected an error merging set into an array")
+	}
+	if _, err := writeMetricValues(map[string]any{"set": map[string]any{"count": true}}); err == nil {
+		t.Fatal("expected an error for a boolean set value")
+	}
+}
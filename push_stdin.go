@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// stdinArg is the --values / --values-file value that reads from stdin.
+const stdinArg = "-"
+
+// readJSONPayload is loadJSONPayload with stdin made explicit, so either flag
+// set to "-" reads the payload from stdin.
+func readJSONPayload(stdin io.Reader, rawJSON, filePath string) (any, error) {
+	if rawJSON == stdinArg && filePath == stdinArg {
+		return nil, usageErrorf("only one of --values or --values-file can read from stdin")
+	}
+	if rawJSON == stdinArg || filePath == stdinArg {
+		contents, err := io.ReadAll(stdin)
+		if err != nil {
+			return nil, fmt.Errorf("read payload from stdin: %w", err)
+		}
+		return loadJSONPayload(string(contents), "")
+	}
+	return loadJSONPayload(rawJSON, filePath)
+}
+
+// pushStream decodes {at, values} points from r one at a time and writes each
+// as soon as it is read, so a slow producer's points land as they arrive.
+// Points without at are stamped by defaultAt when read. Malformed JSON ends
+// the stream since the decoder cannot resynchronize; other failures are
+// recorded like pushBatch unless failFast is set.
+func pushStream(key string, r io.Reader, defaultAt func() (string, error), failFast bool, write func(at string, values map[string]any) error) pushSummary {
+	summary := pushSummary{Key: key, Failures: []pushFailure{}}
+	decoder := json.NewDecoder(r)
+	for index := 0; ; index++ {
+		var point any
+		if err := decoder.Decode(&point); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			summary.Total++
+			summary.Failures = append(summary.Failures, pushFailure{Index: index, Error: fmt.Sprintf("parse JSON: %v", err)})
+			break
+		}
+		summary.Total++
+
+		at, err := defaultAt()
+		if err == nil {
+			var values map[string]any
+			at, values, err = parsePushPoint(point, at)
+			if err == nil {
+				err = write(at, values)
+			}
+		}
+		if errors.Is(err, errPointSkipped) {
+			summary.Skipped++
+			continue
+		}
+		if err != nil {
+			summary.Failures = append(summary.Failures, pushFailure{Index: index, At: at, Error: err.Error()})
+			if failFast {
+				break
+			}
+			continue
+		}
+		summary.Written++
+	}
+	summary.Failed = len(summary.Failures)
+	return summary
+}
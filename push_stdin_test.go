@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadJSONPayloadFromStdin(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name     string
+		rawJSON  string
+		filePath string
+	}{
+		{name: "values", rawJSON: "-"},
+		{name: "values-file", filePath: "-"},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			reader, writer := io.Pipe()
+			go func() {
+				writer.Write([]byte(`{"count": 3}`))
+				writer.Close()
+			}()
+
+			payload, err := readJSONPayload(reader, tt.rawJSON, tt.filePath)
+			if err != nil {
+				t.Fatalf("readJSONPayload error: %v", err)
+			}
+			if !reflect.DeepEqual(payload, map[string]any{"count": float64(3)}) {
+				t.Fatalf("payload = %#v", payload)
+			}
+		})
+	}
+}
+
+func TestReadJSONPayloadRejectsDoubleStdin(t *testing.T) {
+	t.Parallel()
+
+	if _, err := readJSONPayload(strings.NewReader("{}"), "-", "-"); err == nil {
+		t.Fatal("expected an error when both flags read stdin")
+	}
+}
+
+func TestPushStreamWritesPointsAsTheyArrive(t *testing.T) {
+	t.Parallel()
+
+	reader, writer := io.Pipe()
+	written := make(chan string, 2)
+	go func() {
+		defer writer.Close()
+		writer.Write([]byte(`{"at":"2026-10-15T09:00:00Z","values":{"count":1}}` + "\n"))
+		// The second point is only sent once the first has been written.
+		<-written
+		writer.Write([]byte(`{"values":{"count":2}}` + "\n" + `{"at":"2026-10-15T11:00:00Z","values":[1]}` + "\n"))
+	}()
+
+	var got []string
+	summary := pushStream("event::logs", reader, func() (string, error) {
+		return "2026-10-15T10:00:00Z", nil
+	}, false, func(at string, values map[string]any) error {
+		got = append(got, at)
+		written <- at
+		return nil
+	})
+
+	if !reflect.DeepEqual(got, []string{"2026-10-15T09:00:00Z", "2026-10-15T10:00:00Z"}) {
+		t.Fatalf("written = %v", got)
+	}
+	if summary.Total != 3 || summary.Written != 2 || summary.Failed != 1 {
+		t.Fatalf("summary = %+v", summary)
+	}
+	if summary.Failures[0].Index != 2 || summary.Failures[0].Error != "values must be a JSON object" {
+		t.Fatalf("failures = %+v", summary.Failures)
+	}
+}
+
+func TestPushStreamStopsOnMalformedJSON(t *testing.T) {
+	t.Parallel()
+
+	input := `{"values":{"count":1}}` + "\n" + `{"values":` + "\n" + `{"values":{"count":2}}` + "\n"
+	writes := 0
+	summary := pushStream("event::logs", strings.NewReader(input), func() (string, error) {
+		return "2026-10-15T10:00:00Z", nil
+	}, false, func(at string, values map[string]any) error {
+		writes++
+		return nil
+	})
+
+	if writes != 1 || summary.Total != 2 || summary.Failed != 1 {
+		t.Fatalf("writes = %d, summary = %+v", writes, summary)
+	}
+	if !strings.HasPrefix(summary.Failures[0].Error, "parse JSON") {
+		t.Fatalf("failure = %+v", summary.Failures[0])
+	}
+}
+
+func TestPushStreamFailFast(t *testing.T) {
+	t.Parallel()
+
+	input := `{"values":{"count":1}}` + "\n" + `{"values":{"count":2}}` + "\n"
+	summary := pushStream("event::logs", strings.NewReader(input), func() (string, error) {
+		return "2026-10-15T10:00:00Z", nil
+	}, true, func(at string, values map[string]any) error {
+		return errors.New("server unavailable")
+	})
+
+	if summary.Total != 1 || summary.Written != 0 || summary.Failed != 1 {
+		t.Fatalf("summary = %+v", summary)
+	}
+}
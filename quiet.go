@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// addQuietFlag registers --quiet and its -q shorthand.
+func addQuietFlag(fs *flag.FlagSet) *bool {
+	quiet := new(bool)
+	fs.BoolVar(quiet, "quiet", false, "Print only the primary value, one per line")
+	fs.BoolVar(quiet, "q", false, "Shorthand for --quiet")
+	return quiet
+}
+
+// validateQuiet rejects flags that would change what --quiet prints.
+func validateQuiet(opts *outputOptions, format string) error {
+	if format != "" && format != "json" {
+		return usageErrorf("--quiet cannot be combined with --format %s", format)
+	}
+	if opts.Query != "" || opts.Template != "" {
+		return usageErrorf("--quiet cannot be combined with --query or --template")
+	}
+	return nil
+}
+
+// writeQuietLines writes each line followed by a newline and nothing else,
+// so the output can be captured with $(...).
+func writeQuietLines(opts *outputOptions, lines []string) error {
+	return writeCommandOutput(opts, func(w io.Writer) error {
+		return printQuietLines(w, lines)
+	})
+}
+
+func printQuietLines(w io.Writer, lines []string) error {
+	for _, line := range lines {
+		if _, err := io.WriteString(w, line+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// quietAggregateLines formats aggregate values without exponents or
+// thousands separators. Values with no data are skipped, as in JSON output.
+func quietAggregateLines(values []any) []string {
+	normalized := normalizeNumericSlice(values)
+	lines := make([]string, 0, len(normalized))
+	for _, value := range normalized {
+		if number, ok := value.(float64); ok {
+			lines = append(lines, strconv.FormatFloat(number, 'f', -1, 64))
+			continue
+		}
+		lines = append(lines, fmt.Sprint(value))
+	}
+	return lines
+}
+
+// transponderID pulls the id from a create response, accepting both
+// {"data": {"id": ...}} and a bare {"id": ...}.
+func transponderID(response map[string]any) (string, error) {
+	record := response
+	if data, ok := response["data"].(map[string]any); ok {
+		record = data
+	}
+	id := strings.TrimSpace(fmt.Sprint(record["id"]))
+	if record["id"] == nil || id == "" {
+		return "", fmt.Errorf("missing id in response")
+	}
+	return id, nil
+}
+
+func keysEntryNames(entries []keysEntry) []string {
+	names := make([]string, len(entries))
+	for i, entry := range entries {
+		names[i] = entry.MetricKey
+	}
+	return names
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestPrintQuietLines(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		lines []string
+		want  string
+	}{
+		{name: "aggregate single", lines: quietAggregateLines([]any{float64(12345)}), want: "12345\n"},
+		{name: "aggregate slices", lines: quietAggregateLines([]any{json.Number("1e6"), nil, 0.25, int64(-3)}), want: "1000000\n0.25\n-3\n"},
+		{name: "keys", lines: keysEntryNames([]keysEntry{{MetricKey: "event::logs", Observations: 4}, {MetricKey: "event::signups"}}), want: "event::logs\nevent::signups\n"},
+		{name: "empty", lines: nil, want: ""},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			if err := printQuietLines(&buf, tt.lines); err != nil {
+				t.Fatalf("printQuietLines returned error: %v", err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Fatalf("output = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransponderID(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		response map[string]any
+		want     string
+		wantErr  bool
+	}{
+		{name: "data wrapper", response: map[string]any{"data": map[string]any{"id": "tr_123", "name": "x"}}, want: "tr_123"},
+		{name: "bare", response: map[string]any{"id": json.Number("42")}, want: "42"},
+		{name: "missing", response: map[string]any{"data": map[string]any{"name": "x"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := transponderID(tt.response)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("transponderID error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("transponderID = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateQuiet(t *testing.T) {
+	t.Parallel()
+
+	if err := validateQuiet(&outputOptions{}, "json"); err != nil {
+		t.Fatalf("validateQuiet(json) = %v, want nil", err)
+	}
+	if err := validateQuiet(&outputOptions{}, "table"); err == nil {
+		t.Fatal("validateQuiet(table) = nil, want error")
+	}
+	if err := validateQuiet(&outputOptions{Query: "value"}, ""); err == nil {
+		t.Fatal("validateQuiet with --query = nil, want error")
+	}
+}
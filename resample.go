@@ -0,0 +1,167 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	triflestats "github.com/trifle-io/trifle_stats_go"
+)
+
+// nominalUnitSeconds sizes granularity units so --resample can reject a
+// target that is not coarser than the fetched buckets.
+var nominalUnitSeconds = map[triflestats.Unit]float64{
+	triflestats.UnitSecond:  1,
+	triflestats.UnitMinute:  60,
+	triflestats.UnitHour:    3600,
+	triflestats.UnitDay:     86400,
+	triflestats.UnitWeek:    7 * 86400,
+	triflestats.UnitMonth:   30 * 86400,
+	triflestats.UnitQuarter: 91 * 86400,
+	triflestats.UnitYear:    365 * 86400,
+}
+
+// resampleOptions holds --resample and --resample-agg; an empty Granularity
+// turns resampling off.
+type resampleOptions struct {
+	Granularity string
+	Aggregator  string
+}
+
+func newResampleOptions(granularity, aggregator string) (resampleOptions, error) {
+	opts := resampleOptions{
+		Granularity: strings.TrimSpace(granularity),
+		Aggregator:  strings.ToLower(strings.TrimSpace(aggregator)),
+	}
+	switch opts.Aggregator {
+	case "":
+		opts.Aggregator = "sum"
+	case "sum", "mean", "max", "min", "last":
+	default:
+		return resampleOptions{}, usageErrorf("unsupported resample aggregator %q (use sum, mean, max, min, or last)", aggregator)
+	}
+	if opts.Granularity == "" {
+		return opts, nil
+	}
+	if !triflestats.NewParser(opts.Granularity).Valid() {
+		return resampleOptions{}, usageErrorf("invalid --resample granularity %q (e.g. 1h, 1d)", opts.Granularity)
+	}
+	return opts, nil
+}
+
+func (o resampleOptions) enabled() bool {
+	return o.Granularity != ""
+}
+
+// checkSource fails unless the resample granularity is coarser than the
+// granularity the series was fetched at.
+func (o resampleOptions) checkSource(source string) error {
+	from := triflestats.NewParser(source)
+	to := triflestats.NewParser(o.Granularity)
+	if !from.Valid() {
+		return usageErrorf("invalid granularity: %s", source)
+	}
+	if float64(to.Offset)*nominalUnitSeconds[to.Unit] <= float64(from.Offset)*nominalUnitSeconds[from.Unit] {
+		return usageErrorf("--resample %s must be coarser than --granularity %s", o.Granularity, source)
+	}
+	return nil
+}
+
+// timeframe adds the resampled and source granularity to a timeframe
+// payload.
+func (o resampleOptions) timeframe(timeframe map[string]string, source string) map[string]string {
+	if o.enabled() {
+		timeframe["granularity"] = o.Granularity
+		timeframe["source_granularity"] = source
+		timeframe["resample_aggregator"] = o.Aggregator
+	}
+	return timeframe
+}
+
+// resampleResult groups fine buckets into the coarser buckets of
+// opts.Granularity, with boundaries from cfg's time zone and week start. A
+// source bucket belongs to the coarse bucket holding its start. Numeric
+// values are combined per packed path with the aggregator; other values keep
+// the last one seen. Coarse buckets whose source buckets are all empty stay
+// empty.
+func resampleResult(result triflestats.ValuesResult, opts resampleOptions, cfg *triflestats.Config) triflestats.ValuesResult {
+	if !opts.enabled() {
+		return result
+	}
+	parser := triflestats.NewParser(opts.Granularity)
+
+	out := triflestats.ValuesResult{At: []time.Time{}, Values: []map[string]any{}}
+	var current time.Time
+	var group []map[string]any
+	flush := func() {
+		if group != nil {
+			out.At = append(out.At, current)
+			out.Values = append(out.Values, combineBuckets(group, opts.Aggregator))
+		}
+	}
+
+	for i, at := range result.At {
+		start := triflestats.NewNocturnal(at, cfg).Floor(parser.Offset, parser.Unit)
+		if group == nil || !start.Equal(current) {
+			flush()
+			current, group = start, []map[string]any{}
+		}
+		if i < len(result.Values) && len(result.Values[i]) > 0 {
+			group = append(group, triflestats.Pack(result.Values[i]))
+		}
+	}
+	flush()
+	return out
+}
+
+// combineBuckets merges packed buckets path by path.
+func combineBuckets(buckets []map[string]any, aggregator string) map[string]any {
+	type accumulator struct {
+		count    int
+		sum      float64
+		min, max float64
+		last     any
+	}
+	paths := map[string]*accumulator{}
+
+	for _, bucket := range buckets {
+		for path, value := range bucket {
+			acc, ok := paths[path]
+			if !ok {
+				acc = &accumulator{min: math.Inf(1), max: math.Inf(-1)}
+				paths[path] = acc
+			}
+			acc.last = value
+			number, ok := triflestats.NormalizeNumeric(value).(float64)
+			if !ok {
+				continue
+			}
+			acc.count++
+			acc.sum += number
+			acc.min = math.Min(acc.min, number)
+			acc.max = math.Max(acc.max, number)
+			acc.last = number
+		}
+	}
+
+	combined := make(map[string]any, len(paths))
+	for path, acc := range paths {
+		if acc.count == 0 {
+			combined[path] = acc.last
+			continue
+		}
+		switch aggregator {
+		case "mean":
+			combined[path] = acc.sum / float64(acc.count)
+		case "max":
+			combined[path] = acc.max
+		case "min":
+			combined[path] = acc.min
+		case "last":
+			combined[path] = acc.last
+		default:
+			combined[path] = acc.sum
+		}
+	}
+	return triflestats.Unpack(combined)
+}
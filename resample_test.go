@@ -0,0 +1,191 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	triflestats "github.com/trifle-io/trifle_stats_go"
+)
+
+func resampleConfig(t *testing.T, zone string, weekStart time.Weekday) *triflestats.Config {
+	t.Helper()
+
+	cfg := triflestats.DefaultConfig()
+	cfg.TimeZone = zone
+	cfg.BeginningOfWeek = weekStart
+	return cfg
+}
+
+func hourlyResult(start time.Time, counts ...int) triflestats.ValuesResult {
+	result := triflestats.ValuesResult{}
+	for i, count := range counts {
+		result.At = append(result.At, start.Add(time.Duration(i)*time.Hour))
+		if count < 0 {
+			result.Values = append(result.Values, map[string]any{})
+			continue
+		}
+		result.Values = append(result.Values, map[string]any{"count": count})
+	}
+	return result
+}
+
+func TestResampleResultAggregators(t *testing.T) {
+	t.Parallel()
+
+	// 22:00..01:00 UTC on both sides of midnight.
+	start := time.Date(2026, 10, 15, 22, 0, 0, 0, time.UTC)
+	result := hourlyResult(start, 1, 5, 2, 4)
+	cfg := resampleConfig(t, "UTC", time.Monday)
+
+	tests := []struct {
+		aggregator string
+		want       []any
+	}{
+		{aggregator: "sum", want: []any{6.0, 6.0}},
+		{aggregator: "mean", want: []any{3.0, 3.0}},
+		{aggregator: "max", want: []any{5.0, 4.0}},
+		{aggregator: "min", want: []any{1.0, 2.0}},
+		{aggregator: "last", want: []any{5.0, 4.0}},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.aggregator, func(t *testing.T) {
+			t.Parallel()
+
+			opts, err := newResampleOptions("1d", tt.aggregator)
+			if err != nil {
+				t.Fatalf("newResampleOptions returned error: %v", err)
+			}
+			got := resampleResult(result, opts, cfg)
+			wantAt := []time.Time{
+				time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC),
+				time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC),
+			}
+			if len(got.At) != len(wantAt) {
+				t.Fatalf("at = %v, want %v", got.At, wantAt)
+			}
+			for i := range wantAt {
+				if !got.At[i].Equal(wantAt[i]) {
+					t.Fatalf("at[%d] = %s, want %s", i, got.At[i], wantAt[i])
+				}
+				if value := got.Values[i]["count"]; value != tt.want[i] {
+					t.Fatalf("bucket %d count = %v, want %v", i, value, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestResampleResultTimeZoneBoundaries(t *testing.T) {
+	t.Parallel()
+
+	// Berlin is UTC+2 in October, so its day starts at 22:00 UTC.
+	start := time.Date(2026, 10, 15, 21, 0, 0, 0, time.UTC)
+	result := hourlyResult(start, 1, 2, 3)
+	opts, _ := newResampleOptions("1d", "sum")
+
+	got := resampleResult(result, opts, resampleConfig(t, "Europe/Berlin", time.Monday))
+	if len(got.At) != 2 {
+		t.Fatalf("at = %v, want two Berlin days", got.At)
+	}
+	if want := time.Date(2026, 10, 15, 22, 0, 0, 0, time.UTC); !got.At[1].Equal(want) {
+		t.Fatalf("second bucket = %s, want %s", got.At[1].UTC(), want)
+	}
+	if got.Values[0]["count"] != 1.0 || got.Values[1]["count"] != 5.0 {
+		t.Fatalf("values = %v, want 1 and 5", got.Values)
+	}
+}
+
+func TestResampleResultWeekStart(t *testing.T) {
+	t.Parallel()
+
+	// Saturday 2026-10-17 through Monday 2026-10-19, one bucket per day.
+	start := time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+	result := triflestats.ValuesResult{}
+	for i := 0; i < 3; i++ {
+		result.At = append(result.At, start.AddDate(0, 0, i))
+		result.Values = append(result.Values, map[string]any{"count": i + 1})
+	}
+	opts, _ := newResampleOptions("1w", "sum")
+
+	tests := []struct {
+		weekStart time.Weekday
+		want      []any
+	}{
+		{weekStart: time.Monday, want: []any{3.0, 3.0}},
+		{weekStart: time.Sunday, want: []any{1.0, 5.0}},
+		{weekStart: time.Saturday, want: []any{6.0}},
+	}
+	for _, tt := range tests {
+		got := resampleResult(result, opts, resampleConfig(t, "UTC", tt.weekStart))
+		values := make([]any, len(got.Values))
+		for i, row := range got.Values {
+			values[i] = row["count"]
+		}
+		if !reflect.DeepEqual(values, tt.want) {
+			t.Fatalf("week start %s: values = %v, want %v", tt.weekStart, values, tt.want)
+		}
+		if got.At[0].Weekday() != tt.weekStart {
+			t.Fatalf("week start %s: first bucket %s starts on %s", tt.weekStart, got.At[0], got.At[0].Weekday())
+		}
+	}
+}
+
+func TestResampleResultEmptyAndNested(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC)
+	result := triflestats.ValuesResult{
+		At: []time.Time{start, start.Add(time.Hour), start.Add(2 * time.Hour), start.Add(3 * time.Hour)},
+		Values: []map[string]any{
+			{},
+			{},
+			{"duration": map[string]any{"sum": 2}, "status": "ok"},
+			{"duration": map[string]any{"sum": 3}, "status": "slow"},
+		},
+	}
+	opts, _ := newResampleOptions("2h", "sum")
+	got := resampleResult(result, opts, resampleConfig(t, "UTC", time.Monday))
+
+	if len(got.At) != 2 {
+		t.Fatalf("at = %v, want 2 buckets", got.At)
+	}
+	if len(got.Values[0]) != 0 {
+		t.Fatalf("first bucket = %v, want empty", got.Values[0])
+	}
+	want := map[string]any{"duration": map[string]any{"sum": 5.0}, "status": "slow"}
+	if !reflect.DeepEqual(got.Values[1], want) {
+		t.Fatalf("second bucket = %v, want %v", got.Values[1], want)
+	}
+}
+
+func TestResampleOptionsValidation(t *testing.T) {
+	t.Parallel()
+
+	if _, err := newResampleOptions("1d", "median"); err == nil {
+		t.Fatal("expected an unsupported aggregator error")
+	}
+	if _, err := newResampleOptions("daily", "sum"); err == nil {
+		t.Fatal("expected an invalid granularity error")
+	}
+
+	opts, err := newResampleOptions("1d", "")
+	if err != nil || opts.Aggregator != "sum" {
+		t.Fatalf("opts = %+v (err %v), want sum by default", opts, err)
+	}
+	if err := opts.checkSource("1h"); err != nil {
+		t.Fatalf("checkSource(1h) returned error: %v", err)
+	}
+	for _, source := range []string{"1d", "2d", "1w"} {
+		if err := opts.checkSource(source); err == nil {
+			t.Fatalf("checkSource(%s) should fail", source)
+		}
+	}
+
+	timeframe := opts.timeframe(map[string]string{"granularity": "1h"}, "1h")
+	if timeframe["granularity"] != "1d" || timeframe["source_granularity"] != "1h" {
+		t.Fatalf("timeframe = %v, want 1d from 1h", timeframe)
+	}
+}
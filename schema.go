@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	triflestats "github.com/trifle-io/trifle_stats_go"
+)
+
+// keySchema maps value path patterns to the type their values must have. A
+// "*" segment matches one segment, or the rest of the path in last position.
+type keySchema map[string]string
+
+var schemaTypes = []string{"number", "string", "boolean", "any"}
+
+// validate checks every pattern and type in the schema.
+func (s keySchema) validate() error {
+	for _, pattern := range sortedSchemaPatterns(s) {
+		for _, segment := range strings.Split(pattern, ".") {
+			if segment == "" {
+				return fmt.Errorf("invalid path %q", pattern)
+			}
+		}
+		if !containsString(schemaTypes, s[pattern]) {
+			return fmt.Errorf("path %s: unsupported type %q (use %s)", pattern, s[pattern], strings.Join(schemaTypes, ", "))
+		}
+	}
+	return nil
+}
+
+// schemaViolation is one path of a payload the schema rejects.
+type schemaViolation struct {
+	Path    string `json:"path"`
+	Problem string `json:"problem"`
+}
+
+// validateSchemaValues lists the leaf paths of values that no pattern
+// declares, or whose value has none of the declared types, sorted by path.
+func validateSchemaValues(schema keySchema, values map[string]any) []schemaViolation {
+	violations := []schemaViolation{}
+	packed := triflestats.Pack(values)
+	paths := make([]string, 0, len(packed))
+	for path := range packed {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	patterns := sortedSchemaPatterns(schema)
+	for _, path := range paths {
+		segments := strings.Split(path, ".")
+		var expected []string
+		for _, pattern := range patterns {
+			if matchPathSegments(strings.Split(pattern, "."), segments) {
+				expected = append(expected, schema[pattern])
+			}
+		}
+		if len(expected) == 0 {
+			violations = append(violations, schemaViolation{Path: path, Problem: "undeclared path"})
+			continue
+		}
+		actual := schemaValueType(packed[path])
+		if !containsString(expected, actual) && !containsString(expected, "any") {
+			violations = append(violations, schemaViolation{
+				Path:    path,
+				Problem: fmt.Sprintf("expected %s, got %s", strings.Join(uniqueStrings(expected), " or "), actual),
+			})
+		}
+	}
+	return violations
+}
+
+// schemaValueType names the JSON type of value. Strings are checked first
+// since NormalizeNumeric also accepts numeric strings.
+func schemaValueType(value any) string {
+	switch value.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case nil:
+		return "null"
+	case []any:
+		return "array"
+	}
+	if _, ok := triflestats.NormalizeNumeric(value).(float64); ok {
+		return "number"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func sortedSchemaPatterns(schema keySchema) []string {
+	patterns := make([]string, 0, len(schema))
+	for pattern := range schema {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	return patterns
+}
+
+// schemaError reports the violations of one payload.
+type schemaError struct {
+	Key        string
+	Violations []schemaViolation
+}
+
+func (e *schemaError) Error() string {
+	lines := make([]string, len(e.Violations))
+	for i, violation := range e.Violations {
+		lines[i] = fmt.Sprintf("%s: %s", violation.Path, violation.Problem)
+	}
+	return fmt.Sprintf("values do not match the schema for %s: %s", e.Key, strings.Join(lines, "; "))
+}
+
+// lookupSchema returns the schema declared for key in the config.
+func lookupSchema(cfg *cliConfig, key string) (keySchema, error) {
+	if cfg != nil {
+		if schema, ok := cfg.Schemas[key]; ok {
+			if schema == nil {
+				schema = keySchema{}
+			}
+			return schema, nil
+		}
+	}
+	return nil, usageErrorf("no schema declared for %s (add it under schemas: in the config file)", key)
+}
+
+// checkSchemaValues returns a *schemaError when values break schema.
+func checkSchemaValues(schema keySchema, key string, values map[string]any) error {
+	if violations := validateSchemaValues(schema, values); len(violations) > 0 {
+		return &schemaError{Key: key, Violations: violations}
+	}
+	return nil
+}
+
+// schemaCheckedWrite wraps write so each point is checked against schema
+// before it is written; a nil schema leaves write unchanged.
+func schemaCheckedWrite(schema keySchema, key string, write func(at string, values map[string]any) error) func(at string, values map[string]any) error {
+	if schema == nil {
+		return write
+	}
+	return func(at string, values map[string]any) error {
+		if err := checkSchemaValues(schema, key, values); err != nil {
+			return err
+		}
+		return write(at, values)
+	}
+}
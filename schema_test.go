@@ -0,0 +1,113 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestValidateSchemaValues(t *testing.T) {
+	t.Parallel()
+
+	schema := keySchema{"count": "number", "duration": "number", "status.*": "number", "note": "string"}
+	tests := []struct {
+		name   string
+		values map[string]any
+		want   []schemaViolation
+	}{
+		{
+			name:   "matching payload",
+			values: map[string]any{"count": 1, "duration": 2.5, "status": map[string]any{"ok": 1, "error": 0}, "note": "x"},
+			want:   []schemaViolation{},
+		},
+		{
+			name:   "undeclared path",
+			values: map[string]any{"cout": 1},
+			want:   []schemaViolation{{Path: "cout", Problem: "undeclared path"}},
+		},
+		{
+			name:   "wrong types",
+			values: map[string]any{"count": "1", "status": map[string]any{"ok": true}},
+			want: []schemaViolation{
+				{Path: "count", Problem: "expected number, got string"},
+				{Path: "status.ok", Problem: "expected number, got boolean"},
+			},
+		},
+		{
+			name:   "wildcard in last position matches deeper paths",
+			values: map[string]any{"status": map[string]any{"http": map[string]any{"500": 2}}},
+			want:   []schemaViolation{},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := validateSchemaValues(schema, tt.values); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("validateSchemaValues() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeySchemaValidate(t *testing.T) {
+	t.Parallel()
+
+	if err := (keySchema{"status.*": "number", "label": "any"}).validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := (keySchema{"count": "integer"}).validate(); err == nil {
+		t.Fatalf("expected unsupported type to fail")
+	}
+	if err := (keySchema{"status..ok": "number"}).validate(); err == nil {
+		t.Fatalf("expected empty segment to fail")
+	}
+}
+
+func TestLoadConfigFileReadsSchemas(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := "schemas:\n  event::logs:\n    count: number\n    status.*: Number\n    ok: bool\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cfg, err := loadConfigFile(path)
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	schema, err := lookupSchema(cfg, "event::logs")
+	if err != nil {
+		t.Fatalf("lookup schema: %v", err)
+	}
+	want := keySchema{"count": "number", "status.*": "number", "ok": "boolean"}
+	if !reflect.DeepEqual(schema, want) {
+		t.Fatalf("schema = %#v, want %#v", schema, want)
+	}
+	if _, err := lookupSchema(cfg, "event::other"); err == nil {
+		t.Fatalf("expected missing schema to fail")
+	}
+}
+
+func TestValidatePayloadChecksEachPoint(t *testing.T) {
+	t.Parallel()
+
+	schema := keySchema{"count": "number"}
+	payload := []any{
+		map[string]any{"at": "2026-10-15T09:00:00Z", "values": map[string]any{"count": 1}},
+		map[string]any{"at": "2026-10-15T10:00:00Z", "values": map[string]any{"cnt": 1}},
+	}
+	results, err := validatePayload(schema, payload)
+	if err != nil {
+		t.Fatalf("validatePayload: %v", err)
+	}
+	if len(results) != 2 || len(results[0].Violations) != 0 || len(results[1].Violations) != 1 {
+		t.Fatalf("unexpected results: %#v", results)
+	}
+	if results[1].Violations[0].Path != "cnt" {
+		t.Fatalf("unexpected violation: %#v", results[1].Violations[0])
+	}
+}
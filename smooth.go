@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	triflestats "github.com/trifle-io/trifle_stats_go"
+)
+
+// validateSmoothWindow checks --smooth; 0 turns smoothing off.
+func validateSmoothWindow(window int) error {
+	if window < 0 || window == 1 {
+		return usageErrorf("--smooth must be 0 (off) or a window of at least 2 buckets")
+	}
+	return nil
+}
+
+// movingAverage returns the trailing average over window buckets. Buckets
+// before the window fills are nil; empty buckets inside a window are left
+// out of the average, and a window with no values at all yields nil.
+func movingAverage(values []any, window int) []any {
+	out := make([]any, len(values))
+	for i := window - 1; i < len(values); i++ {
+		sum, count := 0.0, 0
+		for _, value := range values[i-window+1 : i+1] {
+			if number, ok := triflestats.NormalizeNumeric(value).(float64); ok {
+				sum += number
+				count++
+			}
+		}
+		if count > 0 {
+			out[i] = sum / float64(count)
+		}
+	}
+	return out
+}
+
+// smoothSeries returns a series holding the moving average of each path.
+func smoothSeries(series triflestats.Series, paths []string, window int) triflestats.Series {
+	return mapSeriesPaths(series, paths, func(_ []time.Time, values []any) []any {
+		return movingAverage(values, window)
+	})
+}
+
+func smoothColumnName(column any, window int) string {
+	return fmt.Sprintf("%v (ma%d)", column, window)
+}
+
+// appendSmoothedColumns adds a "<column> (maN)" column after the existing
+// ones for every value column in a timeline table.
+func appendSmoothedColumns(table map[string]any, window int) {
+	columns, _ := table["columns"].([]any)
+	rows, _ := table["rows"].([]any)
+	if len(columns) < 2 {
+		return
+	}
+
+	existing := make(map[string]bool, len(columns))
+	for _, column := range columns {
+		existing[fmt.Sprint(column)] = true
+	}
+
+	original := len(columns)
+	for column := 1; column < original; column++ {
+		name := smoothColumnName(columns[column], window)
+		if existing[name] {
+			continue
+		}
+		values := make([]any, len(rows))
+		for i, raw := range rows {
+			if row, ok := raw.([]any); ok && column < len(row) {
+				values[i] = row[column]
+			}
+		}
+		columns = append(columns, name)
+		for i, value := range movingAverage(values, window) {
+			if row, ok := rows[i].([]any); ok {
+				rows[i] = append(row, value)
+			}
+		}
+	}
+	table["columns"] = columns
+}
+
+// smoothResultData returns a copy of an API timeline result with each
+// path's points replaced by their moving average. Sliced results are
+// averaged as one continuous series, matching --transform.
+func smoothResultData(raw any, window int) map[string]any {
+	result, ok := raw.(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	smoothed := make(map[string]any, len(result))
+	for path, series := range result {
+		copied := copyTimelinePoints(series)
+		points := flattenTimelinePoints(copied)
+		values := make([]any, len(points))
+		for i, point := range points {
+			values[i] = point["value"]
+		}
+		for i, value := range movingAverage(values, window) {
+			points[i]["value"] = value
+		}
+		smoothed[path] = copied
+	}
+	return smoothed
+}
+
+func copyTimelinePoints(raw any) any {
+	switch value := raw.(type) {
+	case []any:
+		copied := make([]any, len(value))
+		for i, entry := range value {
+			copied[i] = copyTimelinePoints(entry)
+		}
+		return copied
+	case map[string]any:
+		copied := make(map[string]any, len(value))
+		for key, entry := range value {
+			copied[key] = entry
+		}
+		return copied
+	default:
+		return raw
+	}
+}
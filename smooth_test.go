@@ -0,0 +1,75 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMovingAverage(t *testing.T) {
+	t.Parallel()
+
+	got := movingAverage([]any{1, 2, 3, nil, 5, "n/a", nil, nil}, 3)
+	want := []any{nil, nil, 2.0, 2.5, 4.0, 5.0, 5.0, nil}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("movingAverage = %v, want %v", got, want)
+	}
+}
+
+func TestAppendSmoothedColumns(t *testing.T) {
+	t.Parallel()
+
+	table := map[string]any{
+		"columns": []any{"at", "count"},
+		"rows": []any{
+			[]any{"2026-10-15T09:00:00Z", 2.0},
+			[]any{"2026-10-15T10:00:00Z", 4.0},
+			[]any{"2026-10-15T11:00:00Z", 9.0},
+		},
+	}
+	appendSmoothedColumns(table, 2)
+
+	if want := []any{"at", "count", "count (ma2)"}; !reflect.DeepEqual(table["columns"], want) {
+		t.Fatalf("columns = %v, want %v", table["columns"], want)
+	}
+	rows := table["rows"].([]any)
+	got := []any{rows[0].([]any)[2], rows[1].([]any)[2], rows[2].([]any)[2]}
+	if want := []any{nil, 3.0, 6.5}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("smoothed values = %v, want %v", got, want)
+	}
+}
+
+func TestSmoothResultDataKeepsRaw(t *testing.T) {
+	t.Parallel()
+
+	result := map[string]any{
+		"count": []any{
+			map[string]any{"at": "2026-10-15T09:00:00Z", "value": 2.0},
+			map[string]any{"at": "2026-10-15T10:00:00Z", "value": 4.0},
+		},
+	}
+	smoothed := smoothResultData(result, 2)
+
+	points := flattenTimelinePoints(smoothed["count"])
+	if got := []any{points[0]["value"], points[1]["value"]}; !reflect.DeepEqual(got, []any{nil, 3.0}) {
+		t.Fatalf("smoothed values = %v", got)
+	}
+	raw := flattenTimelinePoints(result["count"])
+	if raw[1]["value"] != 4.0 {
+		t.Fatalf("raw result was modified: %v", raw[1]["value"])
+	}
+}
+
+func TestValidateSmoothWindow(t *testing.T) {
+	t.Parallel()
+
+	for _, window := range []int{0, 2, 7} {
+		if err := validateSmoothWindow(window); err != nil {
+			t.Fatalf("validateSmoothWindow(%d) error: %v", window, err)
+		}
+	}
+	for _, window := range []int{-1, 1} {
+		if err := validateSmoothWindow(window); err == nil {
+			t.Fatalf("expected error for window %d", window)
+		}
+	}
+}
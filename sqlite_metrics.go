@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"sort"
 	"strings"
@@ -9,11 +10,14 @@ import (
 	triflestats "github.com/trifle-io/trifle_stats_go"
 )
 
-func buildTimeframePayload(fromValue, toValue, granularity string) map[string]string {
+func buildTimeframePayload(fromValue, toValue, granularity, label string) map[string]string {
+	if label == "" {
+		label = "custom"
+	}
 	return map[string]string{
 		"from":        fromValue,
 		"to":          toValue,
-		"label":       "custom",
+		"label":       label,
 		"granularity": granularity,
 	}
 }
@@ -56,6 +60,62 @@ func buildSeriesTable(series triflestats.Series, paths []string) map[string]any
 	}
 }
 
+// buildValuesTable lays out a raw series with one column per packed value
+// path. Cells keep their raw values; lists are encoded as JSON and missing
+// paths are left blank. When maxColumns is positive, paths past it are
+// dropped and the number of hidden paths is returned.
+func buildValuesTable(result triflestats.ValuesResult, maxColumns int) (map[string]any, int) {
+	packed := make([]map[string]any, len(result.At))
+	var paths []string
+	for i := range result.At {
+		if i >= len(result.Values) || result.Values[i] == nil {
+			continue
+		}
+		packed[i] = triflestats.Pack(result.Values[i])
+		for path := range packed[i] {
+			paths = append(paths, path)
+		}
+	}
+	paths = uniqueStrings(paths)
+
+	hidden := 0
+	if maxColumns > 0 && len(paths) > maxColumns {
+		hidden = len(paths) - maxColumns
+		paths = paths[:maxColumns]
+	}
+
+	columns := make([]any, 0, len(paths)+1)
+	columns = append(columns, "at")
+	for _, path := range paths {
+		columns = append(columns, path)
+	}
+
+	rows := make([]any, 0, len(result.At))
+	for i, at := range result.At {
+		row := make([]any, 0, len(paths)+1)
+		row = append(row, at.Format(time.RFC3339))
+		for _, path := range paths {
+			row = append(row, rawTableCell(packed[i][path]))
+		}
+		rows = append(rows, row)
+	}
+
+	return map[string]any{"columns": columns, "rows": rows}, hidden
+}
+
+func rawTableCell(value any) any {
+	switch value.(type) {
+	case []any:
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Sprint(value)
+		}
+		return string(encoded)
+	default:
+		return value
+	}
+}
+
 func uniqueStrings(values []string) []string {
 	seen := map[string]struct{}{}
 	for _, value := range values {
@@ -157,3 +217,131 @@ func ensureNoWildcards(value string) error {
 	}
 	return nil
 }
+
+func hasPathWildcard(path string) bool {
+	for _, segment := range strings.Split(path, ".") {
+		if segment == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// expandValuePath returns the available paths matching pattern. A "*"
+// segment matches exactly one segment, except in last position where it
+// matches the rest of the path (so duration.* covers duration.p95.max).
+func expandValuePath(pattern string, available []string) []string {
+	patternSegments := strings.Split(pattern, ".")
+	matched := []string{}
+	for _, path := range available {
+		if matchPathSegments(patternSegments, strings.Split(path, ".")) {
+			matched = append(matched, path)
+		}
+	}
+	return matched
+}
+
+func matchPathSegments(pattern, path []string) bool {
+	for i, segment := range pattern {
+		if i >= len(path) {
+			return false
+		}
+		if segment == "*" && i == len(pattern)-1 {
+			return true
+		}
+		if segment != "*" && segment != path[i] {
+			return false
+		}
+	}
+	return len(pattern) == len(path)
+}
+
+// resolveValuePaths expands a wildcard value path against the series'
+// available paths. Plain paths are returned unchanged for the formatters to
+// resolve.
+func resolveValuePaths(valuePath string, available []string) ([]string, error) {
+	if !hasPathWildcard(valuePath) {
+		return []string{valuePath}, nil
+	}
+	matched := expandValuePath(valuePath, available)
+	if len(matched) == 0 {
+		return nil, noMatchingPathError(valuePath, available)
+	}
+	return matched, nil
+}
+
+func noMatchingPathError(valuePath string, available []string) error {
+	if len(available) == 0 {
+		return fmt.Errorf("no matching data found for path %s in the selected timeframe", valuePath)
+	}
+	return fmt.Errorf("no matching data found for path %s in the selected timeframe (available: %s)", valuePath, strings.Join(available, ", "))
+}
+
+// formatTimelinePaths runs the timeline formatter for each path and merges
+// the results.
+func formatTimelinePaths(series triflestats.Series, paths []string, slices int, transform triflestats.TimelineTransform) map[string]any {
+	merged := map[string]any{}
+	for _, path := range paths {
+		for key, value := range series.FormatTimeline(path, slices, transform) {
+			merged[key] = value
+		}
+	}
+	return merged
+}
+
+// formatCategoryPaths runs the category formatter for each path and merges
+// the results, slice by slice when slices > 1.
+func formatCategoryPaths(series triflestats.Series, paths []string, slices int) any {
+	if len(paths) == 1 {
+		return series.FormatCategory(paths[0], slices, nil)
+	}
+
+	merged := map[string]any{}
+	var sliced []map[string]any
+	for _, path := range paths {
+		switch value := series.FormatCategory(path, slices, nil).(type) {
+		case map[string]any:
+			for key, entry := range value {
+				merged[key] = entry
+			}
+		case []map[string]any:
+			for i, slice := range value {
+				if i >= len(sliced) {
+					sliced = append(sliced, map[string]any{})
+				}
+				for key, entry := range slice {
+					sliced[i][key] = entry
+				}
+			}
+		}
+	}
+	if slices > 1 {
+		if sliced == nil {
+			return []map[string]any{}
+		}
+		return sliced
+	}
+	return merged
+}
+
+// aggregateSeriesPath runs the named aggregator over one value path.
+func aggregateSeriesPath(series triflestats.Series, aggregator, path string, slices int) ([]any, error) {
+	switch aggregator {
+	case "sum":
+		return series.AggregateSum(path, slices), nil
+	case "mean":
+		return series.AggregateMean(path, slices), nil
+	case "min":
+		return series.AggregateMin(path, slices), nil
+	case "max":
+		return series.AggregateMax(path, slices), nil
+	case "stddev":
+		return aggregateStddev(series, path, slices), nil
+	case "variance":
+		return aggregateVariance(series, path, slices), nil
+	}
+	if rank, ok := percentileAggregators[aggregator]; ok {
+		return aggregatePercentile(series, path, slices, rank), nil
+	}
+	return nil, fmt.Errorf("unsupported aggregator %q", aggregator)
+}
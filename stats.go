@@ -0,0 +1,113 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/trifle-io/trifle-cli/internal/api"
+)
+
+// commandStats collects what --stats reports once a query command has
+// written its output.
+type commandStats struct {
+	enabled     bool
+	start       time.Time
+	driver      string
+	granularity string
+	points      int
+	query       time.Duration
+	client      *api.Client
+}
+
+func addStatsFlag(fs *flag.FlagSet) *bool {
+	return fs.Bool("stats", false, "Print timing and point counts to stderr after the output")
+}
+
+func newCommandStats(enabled bool, driver string) *commandStats {
+	return &commandStats{enabled: enabled, start: time.Now(), driver: driver}
+}
+
+// timeQuery runs fn and adds its duration to the local driver query time.
+func (s *commandStats) timeQuery(fn func() error) error {
+	started := time.Now()
+	err := fn()
+	s.query += time.Since(started)
+	return err
+}
+
+// finish prints the footer. Commands defer it, so error exits (which go
+// through os.Exit) never print one.
+func (s *commandStats) finish() {
+	if !s.enabled {
+		return
+	}
+	writeStatsLine(os.Stderr, s, time.Since(s.start))
+}
+
+func writeStatsLine(w io.Writer, s *commandStats, elapsed time.Duration) {
+	fields := []string{"driver=" + s.driver}
+	if s.granularity != "" {
+		fields = append(fields, "buckets="+s.granularity)
+	}
+	if s.client != nil {
+		clientStats := s.client.Stats()
+		fields = append(fields,
+			fmt.Sprintf("status=%d", clientStats.LastStatus),
+			fmt.Sprintf("retries=%d", clientStats.Retries),
+		)
+	} else {
+		fields = append(fields, "query="+formatStatsDuration(s.query))
+	}
+	fmt.Fprintf(w, "fetched %d points in %s (%s)\n", s.points, formatStatsDuration(elapsed), strings.Join(fields, ", "))
+}
+
+// formatStatsDuration rounds to milliseconds, or microseconds below that.
+func formatStatsDuration(d time.Duration) string {
+	if d < time.Millisecond {
+		return d.Round(time.Microsecond).String()
+	}
+	return d.Round(time.Millisecond).String()
+}
+
+// responsePoints counts the buckets in a query response from its table, or
+// estimates them from the timeframe when the server sends no table.
+func responsePoints(data map[string]any, from, to, granularity string) int {
+	if table, ok := data["table"].(map[string]any); ok {
+		if rows, ok := table["rows"].([]any); ok {
+			return len(rows)
+		}
+	}
+	return int(estimateTimeframePoints(from, to, granularity))
+}
+
+// countPoints wraps emit to count the buckets it is handed.
+func (s *commandStats) countPoints(emit func(at time.Time, values map[string]any) error) func(at time.Time, values map[string]any) error {
+	return func(at time.Time, values map[string]any) error {
+		s.points++
+		return emit(at, values)
+	}
+}
+
+// seriesPoints counts the buckets in a raw series response.
+func seriesPoints(response map[string]any) int {
+	data, _ := response["data"].(map[string]any)
+	switch at := data["at"].(type) {
+	case []any:
+		return len(at)
+	case []time.Time:
+		return len(at)
+	default:
+		return 0
+	}
+}
+
+func statsDriverName(name string) string {
+	if normalized := normalizeDriverName(name); normalized != "" {
+		return normalized
+	}
+	return "api"
+}
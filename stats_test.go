@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/trifle-io/trifle-cli/internal/api"
+)
+
+func TestWriteStatsLineLocal(t *testing.T) {
+	t.Parallel()
+
+	stats := &commandStats{driver: "postgres", granularity: "1h", points: 744, query: 287400 * time.Microsecond}
+	var buf bytes.Buffer
+	writeStatsLine(&buf, stats, 312400*time.Microsecond)
+
+	want := "fetched 744 points in 312ms (driver=postgres, buckets=1h, query=287ms)\n"
+	if got := buf.String(); got != want {
+		t.Fatalf("stats line = %q, want %q", got, want)
+	}
+}
+
+func TestWriteStatsLineAPI(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"data":{}}`))
+	}))
+	defer server.Close()
+
+	client, err := api.New(server.URL, "token", 0)
+	if err != nil {
+		t.Fatalf("api.New returned error: %v", err)
+	}
+	if err := client.GetSource(context.Background(), nil); err != nil {
+		t.Fatalf("GetSource returned error: %v", err)
+	}
+	client.RecordRetry()
+
+	stats := &commandStats{driver: "api", granularity: "1d", points: 31, client: client}
+	var buf bytes.Buffer
+	writeStatsLine(&buf, stats, 1204*time.Millisecond)
+
+	want := "fetched 31 points in 1.204s (driver=api, buckets=1d, status=200, retries=1)\n"
+	if got := buf.String(); got != want {
+		t.Fatalf("stats line = %q, want %q", got, want)
+	}
+}
+
+func TestFormatStatsDuration(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		input time.Duration
+		want  string
+	}{
+		{input: 312400 * time.Microsecond, want: "312ms"},
+		{input: 2500 * time.Millisecond, want: "2.5s"},
+		{input: 850400 * time.Nanosecond, want: "850µs"},
+	}
+
+	for _, tt := range tests {
+		if got := formatStatsDuration(tt.input); got != tt.want {
+			t.Fatalf("formatStatsDuration(%v) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestResponsePoints(t *testing.T) {
+	t.Parallel()
+
+	withTable := map[string]any{"table": map[string]any{"rows": []any{[]any{}, []any{}, []any{}}}}
+	if got := responsePoints(withTable, "", "", ""); got != 3 {
+		t.Fatalf("responsePoints(table) = %d, want 3", got)
+	}
+	if got := responsePoints(map[string]any{}, "2026-01-01T00:00:00Z", "2026-01-02T00:00:00Z", "1h"); got != 25 {
+		t.Fatalf("responsePoints(estimate) = %d, want 25", got)
+	}
+}
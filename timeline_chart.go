@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/trifle-io/trifle-cli/internal/output"
+)
+
+const (
+	defaultChartWidth  = 960
+	defaultChartHeight = 400
+)
+
+// chartFlags holds the --chart flags of metrics timeline.
+type chartFlags struct {
+	Path   string
+	Width  int
+	Height int
+}
+
+func addChartFlags(fs *flag.FlagSet) *chartFlags {
+	c := &chartFlags{}
+	fs.StringVar(&c.Path, "chart", "", "Write the timeline as an SVG line chart to this file instead of printing it")
+	fs.IntVar(&c.Width, "chart-width", defaultChartWidth, "Chart width in pixels")
+	fs.IntVar(&c.Height, "chart-height", defaultChartHeight, "Chart height in pixels")
+	return c
+}
+
+func (c *chartFlags) enabled() bool {
+	return c.Path != ""
+}
+
+// validate checks the chart path and size. The chart replaces the command
+// output, so flags that shape that output cannot be combined with it.
+func (c *chartFlags) validate(opts *outputOptions) error {
+	if !c.enabled() {
+		return nil
+	}
+	switch strings.ToLower(filepath.Ext(c.Path)) {
+	case ".svg":
+	case ".png":
+		return usageErrorf("PNG charts are not supported yet; write an .svg file instead")
+	default:
+		return usageErrorf("--chart must name an .svg file")
+	}
+	if c.Width <= 0 || c.Height <= 0 {
+		return usageErrorf("--chart-width and --chart-height must be positive")
+	}
+	if opts.Path != "" || opts.Query != "" || opts.Template != "" {
+		return usageErrorf("--chart cannot be combined with --output, --query, or --template")
+	}
+	return nil
+}
+
+// write renders the payload table as an SVG chart titled with the key and
+// value path, with the timeframe underneath.
+func (c *chartFlags) write(payload map[string]any, loc *time.Location) error {
+	table, ok := output.ExtractTable(payload, output.TableOptions{Precision: -1})
+	if !ok || len(table.Rows) == 0 {
+		return fmt.Errorf("no timeline rows to chart")
+	}
+	chartOpts := output.ChartOptions{
+		Width:      c.Width,
+		Height:     c.Height,
+		Title:      chartTitle(payload),
+		Subtitle:   chartSubtitle(payload["timeframe"]),
+		DisplayLoc: loc,
+	}
+	err := writeCommandOutput(&outputOptions{Path: c.Path}, func(w io.Writer) error {
+		return output.WriteSVGChart(w, table, chartOpts)
+	})
+	if errors.Is(err, output.ErrEmptyChart) {
+		return fmt.Errorf("%w in the selected timeframe", err)
+	}
+	return err
+}
+
+func chartTitle(payload map[string]any) string {
+	key, _ := payload["metric_key"].(string)
+	if key == "" {
+		key, _ = payload["key"].(string)
+	}
+	valuePath, _ := payload["value_path"].(string)
+	return strings.TrimSpace(key + " " + valuePath)
+}
+
+func chartSubtitle(timeframe any) string {
+	var from, to, granularity string
+	switch value := timeframe.(type) {
+	case map[string]string:
+		from, to, granularity = value["from"], value["to"], value["granularity"]
+	case map[string]any:
+		from, _ = value["from"].(string)
+		to, _ = value["to"].(string)
+		granularity, _ = value["granularity"].(string)
+	}
+	if from == "" || to == "" {
+		return ""
+	}
+	subtitle := from + " to " + to
+	if granularity != "" {
+		subtitle += " (" + granularity + ")"
+	}
+	return subtitle
+}
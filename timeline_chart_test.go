@@ -0,0 +1,79 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestChartFlagsValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		chart  chartFlags
+		output outputOptions
+		err    string
+	}{
+		{name: "disabled", chart: chartFlags{}},
+		{name: "svg", chart: chartFlags{Path: "out.SVG", Width: 640, Height: 320}},
+		{name: "png", chart: chartFlags{Path: "out.png", Width: 640, Height: 320}, err: "PNG charts are not supported"},
+		{name: "no extension", chart: chartFlags{Path: "out", Width: 640, Height: 320}, err: "must name an .svg file"},
+		{name: "zero size", chart: chartFlags{Path: "out.svg", Width: 0, Height: 320}, err: "must be positive"},
+		{name: "with output", chart: chartFlags{Path: "out.svg", Width: 640, Height: 320}, output: outputOptions{Path: "out.json"}, err: "cannot be combined"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := tt.chart.validate(&tt.output)
+			if tt.err == "" && err != nil {
+				t.Fatalf("validate returned error: %v", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("validate error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestChartFlagsWrite(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "logs.svg")
+	chart := chartFlags{Path: path, Width: 640, Height: 320}
+	payload := map[string]any{
+		"metric_key": "event::logs",
+		"value_path": "count",
+		"timeframe":  buildTimeframePayload("2026-10-15T09:00:00Z", "2026-10-15T11:00:00Z", "1h", ""),
+		"table": map[string]any{
+			"columns": []any{"at", "count"},
+			"rows": []any{
+				[]any{"2026-10-15T09:00:00Z", 2},
+				[]any{"2026-10-15T10:00:00Z", 5},
+			},
+		},
+	}
+	if err := chart.write(payload, nil); err != nil {
+		t.Fatalf("write returned error: %v", err)
+	}
+	svg, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read chart: %v", err)
+	}
+	for _, fragment := range []string{">event::logs count</text>", ">2026-10-15T09:00:00Z to 2026-10-15T11:00:00Z (1h)</text>", "<polyline"} {
+		if !strings.Contains(string(svg), fragment) {
+			t.Fatalf("chart missing %q:\n%s", fragment, svg)
+		}
+	}
+
+	payload["table"] = map[string]any{"columns": []any{"at", "count"}, "rows": []any{[]any{"2026-10-15T09:00:00Z", nil}}}
+	empty := chartFlags{Path: filepath.Join(t.TempDir(), "empty.svg"), Width: 640, Height: 320}
+	if err := empty.write(payload, nil); err == nil || !strings.Contains(err.Error(), "no numeric values to chart") {
+		t.Fatalf("empty chart error = %v", err)
+	}
+	if _, err := os.Stat(empty.Path); !os.IsNotExist(err) {
+		t.Fatalf("empty chart left a file behind (stat err %v)", err)
+	}
+}
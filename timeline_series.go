@@ -0,0 +1,80 @@
+package main
+
+import (
+	triflestats "github.com/trifle-io/trifle_stats_go"
+)
+
+// timelineSeriesRequest describes how metrics timeline formats a series it
+// fetched itself rather than through the API timeline query.
+type timelineSeriesRequest struct {
+	Key       string
+	ValuePath string
+	Slices    int
+	Fill      fillOptions
+	Transform transformOptions
+	Smooth    int
+	Anomalies float64
+	Summary   bool
+}
+
+// timelineSeriesPayload formats series into the timeline payload: the
+// result per matched path, a table, and the fill, transform, smoothing,
+// anomaly, and summary extras when they are requested.
+func timelineSeriesPayload(series triflestats.Series, req timelineSeriesRequest) (map[string]any, error) {
+	available := series.AvailablePaths()
+	paths, err := resolveValuePaths(req.ValuePath, available)
+	if err != nil {
+		return nil, err
+	}
+	formatted := formatTimelinePaths(series, paths, req.Slices, nil)
+	matched := filterAvailable(mapKeys(formatted), available)
+	if len(matched) == 0 {
+		return nil, noMatchingPathError(req.ValuePath, available)
+	}
+	if req.Fill.enabled() {
+		series = fillSeries(series, matched, req.Fill)
+		formatted = formatTimelinePaths(series, matched, req.Slices, nullableTimelinePoint)
+	}
+	resets := 0
+	if req.Transform.enabled() {
+		series, resets = transformSeries(series, matched, req.Transform)
+		formatted = formatTimelinePaths(series, matched, req.Slices, nullableTimelinePoint)
+	}
+
+	payload := map[string]any{
+		"status":          "ok",
+		"formatter":       "timeline",
+		"metric_key":      req.Key,
+		"value_path":      req.ValuePath,
+		"slices":          req.Slices,
+		"result":          formatted,
+		"available_paths": available,
+		"matched_paths":   matched,
+	}
+	if req.Fill.enabled() {
+		payload["fill"] = req.Fill.Mode
+		payload["fill_edges"] = req.Fill.Edges
+	}
+	if req.Transform.enabled() {
+		payload["transform"] = req.Transform.Mode
+		payload["resets"] = resets
+		if req.Transform.StrictNil {
+			payload["strict_nil"] = true
+		}
+	}
+	if req.Smooth > 0 {
+		payload["smooth"] = req.Smooth
+		payload["result_smoothed"] = formatTimelinePaths(smoothSeries(series, matched, req.Smooth), matched, req.Slices, nullableTimelinePoint)
+	}
+
+	if table := buildSeriesTable(series, matched); table != nil {
+		payload["table"] = table
+	}
+	if err := applyTimelineAnomalies(payload, req.Anomalies, req.Smooth); err != nil {
+		return nil, err
+	}
+	if req.Summary {
+		applyTimelineSummary(payload)
+	}
+	return payload, nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+
+	triflestats "github.com/trifle-io/trifle_stats_go"
+)
+
+// timelineSummaryStats are the footer rows --summary adds to a timeline
+// table, in order.
+var timelineSummaryStats = []string{"min", "max", "mean", "last"}
+
+// timelineSummary computes min, max, mean, and last over the non-nil numeric
+// cells of each value column of a timeline table, keyed by column name.
+// Columns without a single number, such as the anomaly column, are left out.
+func timelineSummary(table map[string]any) map[string]map[string]any {
+	columns, _ := table["columns"].([]any)
+	rows, _ := table["rows"].([]any)
+	summary := map[string]map[string]any{}
+	for column := 1; column < len(columns); column++ {
+		count := 0
+		var minValue, maxValue, sum, last float64
+		for _, raw := range rows {
+			row, ok := raw.([]any)
+			if !ok || column >= len(row) {
+				continue
+			}
+			number, ok := timelineSummaryNumber(row[column])
+			if !ok {
+				continue
+			}
+			if count == 0 || number < minValue {
+				minValue = number
+			}
+			if count == 0 || number > maxValue {
+				maxValue = number
+			}
+			sum += number
+			last = number
+			count++
+		}
+		if count == 0 {
+			continue
+		}
+		summary[fmt.Sprint(columns[column])] = map[string]any{
+			"min":   minValue,
+			"max":   maxValue,
+			"mean":  sum / float64(count),
+			"last":  last,
+			"count": count,
+		}
+	}
+	return summary
+}
+
+// timelineSummaryNumber reads a table cell as a number. Strings are never
+// numbers here, even when they parse as one, so labels stay out.
+func timelineSummaryNumber(cell any) (float64, bool) {
+	switch cell.(type) {
+	case nil, string, bool:
+		return 0, false
+	}
+	number, ok := triflestats.NormalizeNumeric(cell).(float64)
+	return number, ok
+}
+
+// applyTimelineSummary adds the summary of a timeline payload's table under
+// summary and appends it to the table as footer rows, after any smoothed or
+// anomaly columns were added. Payloads without a table are left alone.
+func applyTimelineSummary(data map[string]any) {
+	table, ok := data["table"].(map[string]any)
+	if !ok {
+		return
+	}
+	summary := timelineSummary(table)
+	data["summary"] = summary
+
+	columns, _ := table["columns"].([]any)
+	footer := make([]any, len(timelineSummaryStats))
+	for i, stat := range timelineSummaryStats {
+		row := make([]any, len(columns))
+		row[0] = stat
+		for column := 1; column < len(columns); column++ {
+			if stats, ok := summary[fmt.Sprint(columns[column])]; ok {
+				row[column] = stats[stat]
+			}
+		}
+		footer[i] = row
+	}
+	table["footer"] = footer
+}
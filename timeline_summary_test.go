@@ -0,0 +1,52 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestApplyTimelineSummary(t *testing.T) {
+	t.Parallel()
+
+	data := map[string]any{
+		"table": map[string]any{
+			"columns": []any{"at", "count", "duration", "anomaly"},
+			"rows": []any{
+				[]any{"2026-10-15T09:00:00Z", 4.0, nil, ""},
+				[]any{"2026-10-15T10:00:00Z", int64(2), 1.5, "count z=2.10"},
+				[]any{"2026-10-15T11:00:00Z", nil, 0.5, ""},
+				[]any{"2026-10-15T12:00:00Z", 6, nil, ""},
+			},
+		},
+	}
+	applyTimelineSummary(data)
+
+	want := map[string]map[string]any{
+		"count":    {"min": 2.0, "max": 6.0, "mean": 4.0, "last": 6.0, "count": 3},
+		"duration": {"min": 0.5, "max": 1.5, "mean": 1.0, "last": 0.5, "count": 2},
+	}
+	if !reflect.DeepEqual(data["summary"], want) {
+		t.Fatalf("summary = %#v, want %#v", data["summary"], want)
+	}
+
+	footer := data["table"].(map[string]any)["footer"].([]any)
+	wantFooter := []any{
+		[]any{"min", 2.0, 0.5, nil},
+		[]any{"max", 6.0, 1.5, nil},
+		[]any{"mean", 4.0, 1.0, nil},
+		[]any{"last", 6.0, 0.5, nil},
+	}
+	if !reflect.DeepEqual(footer, wantFooter) {
+		t.Fatalf("footer = %#v, want %#v", footer, wantFooter)
+	}
+}
+
+func TestApplyTimelineSummaryWithoutTable(t *testing.T) {
+	t.Parallel()
+
+	data := map[string]any{"result": map[string]any{}}
+	applyTimelineSummary(data)
+	if _, ok := data["summary"]; ok {
+		t.Fatalf("summary added without a table: %#v", data)
+	}
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	triflestats "github.com/trifle-io/trifle_stats_go"
+)
+
+// validateTimelineTransform normalizes a --transform value; "" means none.
+func validateTimelineTransform(name string) (string, error) {
+	switch mode := strings.ToLower(strings.TrimSpace(name)); mode {
+	case "", "delta", "rate", "cumsum":
+		return mode, nil
+	default:
+		return "", usageErrorf("unsupported transform %q (use delta, rate, or cumsum)", name)
+	}
+}
+
+// transformOptions holds --transform and --strict-nil; an empty Mode turns
+// the transform off.
+type transformOptions struct {
+	Mode      string
+	StrictNil bool
+}
+
+// newTransformOptions validates --transform; --strict-nil only applies to
+// cumsum.
+func newTransformOptions(mode string, strictNil bool) (transformOptions, error) {
+	name, err := validateTimelineTransform(mode)
+	if err != nil {
+		return transformOptions{}, err
+	}
+	if strictNil && name != "cumsum" {
+		return transformOptions{}, usageErrorf("--strict-nil requires --transform cumsum")
+	}
+	return transformOptions{Mode: name, StrictNil: strictNil}, nil
+}
+
+func (o transformOptions) enabled() bool {
+	return o.Mode != ""
+}
+
+// transformValues replaces each bucket value with its change from the
+// previous value (delta), that change per second of elapsed time (rate), or
+// the running total up to it (cumsum). For delta and rate, empty buckets stay
+// nil and are bridged: the next value is compared with the last one seen,
+// over the time since it. The first value becomes nil, as do counter resets
+// (negative deltas), which are counted.
+func transformValues(opts transformOptions, at []time.Time, values []any) ([]any, int) {
+	if opts.Mode == "cumsum" {
+		return cumulativeValues(values, opts.StrictNil), 0
+	}
+	mode := opts.Mode
+	out := make([]any, len(values))
+	resets := 0
+	last := -1
+	var previous float64
+	for i, value := range values {
+		current, ok := triflestats.NormalizeNumeric(value).(float64)
+		if !ok {
+			continue
+		}
+		if last >= 0 {
+			out[i] = bucketChange(mode, current-previous, at, last, i, &resets)
+		}
+		last, previous = i, current
+	}
+	return out, resets
+}
+
+// cumulativeValues sums values in bucket order. Empty buckets count as zero
+// and carry the running total, unless strictNil keeps them nil.
+func cumulativeValues(values []any, strictNil bool) []any {
+	out := make([]any, len(values))
+	total := 0.0
+	for i, value := range values {
+		current, ok := triflestats.NormalizeNumeric(value).(float64)
+		if !ok && strictNil {
+			continue
+		}
+		if ok {
+			total += current
+		}
+		out[i] = total
+	}
+	return out
+}
+
+func bucketChange(mode string, delta float64, at []time.Time, from, to int, resets *int) any {
+	if delta < 0 {
+		*resets++
+		return nil
+	}
+	if mode != "rate" {
+		return delta
+	}
+	if to >= len(at) {
+		return nil
+	}
+	seconds := at[to].Sub(at[from]).Seconds()
+	if seconds <= 0 {
+		return nil
+	}
+	return delta / seconds
+}
+
+// transformSeries applies opts to each path and returns a series holding
+// only the transformed paths, plus the total number of counter resets.
+func transformSeries(series triflestats.Series, paths []string, opts transformOptions) (triflestats.Series, int) {
+	resets := 0
+	transformed := mapSeriesPaths(series, paths, func(at []time.Time, values []any) []any {
+		out, pathResets := transformValues(opts, at, values)
+		resets += pathResets
+		return out
+	})
+	return transformed, resets
+}
+
+// mapSeriesPaths rebuilds series from fn's output for each path; paths not
+// listed are dropped.
+func mapSeriesPaths(series triflestats.Series, paths []string, fn func(at []time.Time, values []any) []any) triflestats.Series {
+	rows := make([]map[string]any, len(series.At))
+	for i := range rows {
+		rows[i] = map[string]any{}
+	}
+
+	for _, path := range paths {
+		values := make([]any, len(series.At))
+		for i := range values {
+			if i < len(series.Values) {
+				values[i] = triflestats.FetchPath(series.Values[i], path)
+			}
+		}
+		for i, value := range fn(series.At, values) {
+			setValuePath(rows[i], path, value)
+		}
+	}
+	return triflestats.Series{At: series.At, Values: rows}
+}
+
+func setValuePath(row map[string]any, path string, value any) {
+	segments := strings.Split(path, ".")
+	for _, segment := range segments[:len(segments)-1] {
+		next, ok := row[segment].(map[string]any)
+		if !ok {
+			next = map[string]any{}
+			row[segment] = next
+		}
+		row = next
+	}
+	row[segments[len(segments)-1]] = value
+}
+
+// nullableTimelinePoint keeps missing values as null; the library's
+// TimelinePoint turns them into 0, which would read as a real delta.
+func nullableTimelinePoint(at time.Time, value any) any {
+	return map[string]any{"at": at, "value": triflestats.NormalizeNumeric(value)}
+}
+
+// transformQueryData applies opts to a timeline response from the API,
+// rewriting its table columns and result series in place. Resets are
+// counted from the table when there is one, otherwise from the result.
+func transformQueryData(data map[string]any, opts transformOptions) (int, error) {
+	tableResets, hasTable, err := transformTableData(data["table"], opts)
+	if err != nil {
+		return 0, err
+	}
+	resultResets, err := transformResultData(data["result"], opts)
+	if err != nil {
+		return 0, err
+	}
+	if hasTable {
+		return tableResets, nil
+	}
+	return resultResets, nil
+}
+
+func transformTableData(raw any, opts transformOptions) (int, bool, error) {
+	resets := 0
+	hasTable, err := mapTableColumns(raw, func(at []time.Time, values []any) []any {
+		out, columnResets := transformValues(opts, at, values)
+		resets += columnResets
+		return out
+	})
+	return resets, hasTable, err
+}
+
+// transformResultData rewrites each path's points; sliced results are
+// treated as one continuous series so slice boundaries keep their deltas.
+func transformResultData(raw any, opts transformOptions) (int, error) {
+	resets := 0
+	err := mapResultPoints(raw, func(at []time.Time, values []any) []any {
+		out, pathResets := transformValues(opts, at, values)
+		resets += pathResets
+		return out
+	})
+	return resets, err
+}
+
+// mapTableColumns replaces every value column of a timeline table with fn's
+// output, in place. It reports whether raw held a table.
+func mapTableColumns(raw any, fn func(at []time.Time, values []any) []any) (bool, error) {
+	table, ok := raw.(map[string]any)
+	if !ok {
+		return false, nil
+	}
+	rows, ok := table["rows"].([]any)
+	if !ok {
+		return false, nil
+	}
+	columns, _ := table["columns"].([]any)
+
+	at := make([]time.Time, len(rows))
+	cells := make([][]any, len(rows))
+	for i, raw := range rows {
+		row, ok := raw.([]any)
+		if !ok || len(row) == 0 {
+			return true, fmt.Errorf("unexpected table row %v", raw)
+		}
+		parsed, err := parseBucketTime(row[0])
+		if err != nil {
+			return true, err
+		}
+		at[i] = parsed
+		cells[i] = row
+	}
+
+	for column := 1; column < len(columns); column++ {
+		values := make([]any, len(cells))
+		for i, row := range cells {
+			if column < len(row) {
+				values[i] = row[column]
+			}
+		}
+		mapped := fn(at, values)
+		for i, row := range cells {
+			if column < len(row) {
+				row[column] = mapped[i]
+			}
+		}
+	}
+	return true, nil
+}
+
+// mapResultPoints replaces the point values of each path in a timeline
+// result with fn's output, in place.
+func mapResultPoints(raw any, fn func(at []time.Time, values []any) []any) error {
+	result, ok := raw.(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	for _, series := range result {
+		points := flattenTimelinePoints(series)
+		if len(points) == 0 {
+			continue
+		}
+		at := make([]time.Time, len(points))
+		values := make([]any, len(points))
+		for i, point := range points {
+			parsed, err := parseBucketTime(point["at"])
+			if err != nil {
+				return err
+			}
+			at[i] = parsed
+			values[i] = point["value"]
+		}
+		for i, value := range fn(at, values) {
+			points[i]["value"] = value
+		}
+	}
+	return nil
+}
+
+func flattenTimelinePoints(raw any) []map[string]any {
+	list, ok := raw.([]any)
+	if !ok {
+		return nil
+	}
+	var points []map[string]any
+	for _, entry := range list {
+		switch value := entry.(type) {
+		case map[string]any:
+			points = append(points, value)
+		case []any:
+			points = append(points, flattenTimelinePoints(value)...)
+		}
+	}
+	return points
+}
+
+func parseBucketTime(raw any) (time.Time, error) {
+	switch value := raw.(type) {
+	case time.Time:
+		return value, nil
+	case string:
+		parsed, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("parse bucket time %q: %w", value, err)
+		}
+		return parsed, nil
+	default:
+		return time.Time{}, fmt.Errorf("unexpected bucket time %v", raw)
+	}
+}
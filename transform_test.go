@@ -0,0 +1,152 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func hourlyBuckets(n int) []time.Time {
+	start := time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC)
+	at := make([]time.Time, n)
+	for i := range at {
+		at[i] = start.Add(time.Duration(i) * time.Hour)
+	}
+	return at
+}
+
+func TestTransformValues(t *testing.T) {
+	t.Parallel()
+
+	at := hourlyBuckets(6)
+	tests := []struct {
+		name       string
+		mode       string
+		strictNil  bool
+		values     []any
+		want       []any
+		wantResets int
+	}{
+		{
+			name:   "delta",
+			mode:   "delta",
+			values: []any{10, 25, 25.5, 40},
+			want:   []any{nil, 15.0, 0.5, 14.5},
+		},
+		{
+			name:   "rate per second",
+			mode:   "rate",
+			values: []any{0, 3600, 10800},
+			want:   []any{nil, 1.0, 2.0},
+		},
+		{
+			name:   "gap bridged over elapsed time",
+			mode:   "rate",
+			values: []any{0, nil, 7200},
+			want:   []any{nil, nil, 1.0},
+		},
+		{
+			name:       "reset clamps to nil",
+			mode:       "delta",
+			values:     []any{100, 120, 5, 8},
+			want:       []any{nil, 20.0, nil, 3.0},
+			wantResets: 1,
+		},
+		{
+			name:   "leading empty buckets",
+			mode:   "delta",
+			values: []any{nil, "n/a", 4, 6},
+			want:   []any{nil, nil, nil, 2.0},
+		},
+		{
+			name:   "cumsum counts empty buckets as zero",
+			mode:   "cumsum",
+			values: []any{nil, 2, nil, 3.5, "n/a"},
+			want:   []any{0.0, 2.0, 2.0, 5.5, 5.5},
+		},
+		{
+			name:      "cumsum strict nil",
+			mode:      "cumsum",
+			strictNil: true,
+			values:    []any{nil, 2, nil, 3.5},
+			want:      []any{nil, 2.0, nil, 5.5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, resets := transformValues(transformOptions{Mode: tt.mode, StrictNil: tt.strictNil}, at[:len(tt.values)], tt.values)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("transformValues = %v, want %v", got, tt.want)
+			}
+			if resets != tt.wantResets {
+				t.Fatalf("resets = %d, want %d", resets, tt.wantResets)
+			}
+		})
+	}
+}
+
+func TestTransformQueryDataTable(t *testing.T) {
+	t.Parallel()
+
+	data := map[string]any{
+		"table": map[string]any{
+			"columns": []any{"at", "count"},
+			"rows": []any{
+				[]any{"2026-10-15T09:00:00Z", 10.0},
+				[]any{"2026-10-15T10:00:00Z", 3610.0},
+				[]any{"2026-10-15T11:00:00Z", 20.0},
+			},
+		},
+		"result": map[string]any{
+			"count": []any{
+				[]any{
+					map[string]any{"at": "2026-10-15T09:00:00Z", "value": 10.0},
+					map[string]any{"at": "2026-10-15T10:00:00Z", "value": 3610.0},
+				},
+				[]any{
+					map[string]any{"at": "2026-10-15T11:00:00Z", "value": 20.0},
+				},
+			},
+		},
+	}
+
+	resets, err := transformQueryData(data, transformOptions{Mode: "rate"})
+	if err != nil {
+		t.Fatalf("transformQueryData error: %v", err)
+	}
+	if resets != 1 {
+		t.Fatalf("resets = %d, want 1", resets)
+	}
+
+	rows := data["table"].(map[string]any)["rows"].([]any)
+	gotTable := []any{rows[0].([]any)[1], rows[1].([]any)[1], rows[2].([]any)[1]}
+	if want := []any{nil, 1.0, nil}; !reflect.DeepEqual(gotTable, want) {
+		t.Fatalf("table values = %v, want %v", gotTable, want)
+	}
+
+	points := flattenTimelinePoints(data["result"].(map[string]any)["count"])
+	gotResult := []any{points[0]["value"], points[1]["value"], points[2]["value"]}
+	if want := []any{nil, 1.0, nil}; !reflect.DeepEqual(gotResult, want) {
+		t.Fatalf("result values = %v, want %v", gotResult, want)
+	}
+}
+
+func TestValidateTimelineTransform(t *testing.T) {
+	t.Parallel()
+
+	for input, want := range map[string]string{"": "", "delta": "delta", " RATE ": "rate", "cumsum": "cumsum"} {
+		got, err := validateTimelineTransform(input)
+		if err != nil || got != want {
+			t.Fatalf("validateTimelineTransform(%q) = %q, %v, want %q", input, got, err, want)
+		}
+	}
+	if _, err := validateTimelineTransform("derivative"); err == nil {
+		t.Fatalf("expected error for unsupported transform")
+	}
+	if _, err := newTransformOptions("delta", true); err == nil {
+		t.Fatalf("expected error for --strict-nil without cumsum")
+	}
+}
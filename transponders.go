@@ -0,0 +1,264 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// transponderField describes one top-level field of a transponder payload.
+type transponderField struct {
+	Type     string
+	Required bool
+	Enum     []string
+}
+
+// transponderSchema is the minimal shape the API accepts for create and
+// update. Fields outside it are warned about, not rejected, so payloads for
+// newer servers still go through.
+var transponderSchema = map[string]transponderField{
+	"name":        {Type: "string", Required: true},
+	"kind":        {Type: "string", Required: true},
+	"key":         {Type: "string"},
+	"description": {Type: "string"},
+	"status":      {Type: "string", Enum: []string{transponderActive, transponderPaused}},
+	"config":      {Type: "object"},
+	"order":       {Type: "number"},
+}
+
+// transponderKind lists the config fields a kind takes from the shorthand
+// flags of transponders create. Required fields must be set by a flag or by
+// the config of --payload.
+type transponderKind struct {
+	Required []string
+	Optional []string
+}
+
+var transponderKinds = map[string]transponderKind{
+	"threshold": {Required: []string{"value_path", "threshold"}, Optional: []string{"interval"}},
+	"heartbeat": {Required: []string{"interval"}, Optional: []string{"value_path"}},
+}
+
+// transponderFlags holds the shorthand flags of transponders create.
+type transponderFlags struct {
+	Name      string
+	Kind      string
+	Key       string
+	ValuePath string
+	Threshold string
+	Interval  string
+}
+
+func addTransponderFlags(fs *flag.FlagSet) *transponderFlags {
+	f := &transponderFlags{}
+	fs.StringVar(&f.Name, "name", "", "Transponder name")
+	fs.StringVar(&f.Kind, "kind", "", "Transponder kind (threshold|heartbeat have flag shorthand; others need --payload)")
+	fs.StringVar(&f.Key, "key", "", "Metrics key the transponder watches")
+	fs.StringVar(&f.ValuePath, "value-path", "", "Value path the transponder reads (threshold, heartbeat)")
+	fs.StringVar(&f.Threshold, "threshold", "", "Value that triggers the transponder (threshold)")
+	fs.StringVar(&f.Interval, "interval", "", "Check interval, e.g. 5m or 1h (threshold, heartbeat)")
+	return f
+}
+
+func (f *transponderFlags) set() bool {
+	return *f != transponderFlags{}
+}
+
+// merge applies the flags over base, flags winning, with the kind-specific
+// flags going into base's config. It returns base unchanged when no flag is
+// set.
+func (f *transponderFlags) merge(base map[string]any) (map[string]any, error) {
+	if !f.set() {
+		return base, nil
+	}
+	payload := make(map[string]any, len(base)+4)
+	for field, value := range base {
+		payload[field] = value
+	}
+	for field, value := range map[string]string{"name": f.Name, "kind": f.Kind, "key": f.Key} {
+		if value != "" {
+			payload[field] = value
+		}
+	}
+
+	config := map[string]any{}
+	if existing, ok := payload["config"].(map[string]any); ok {
+		for field, value := range existing {
+			config[field] = value
+		}
+	}
+	shorthand := map[string]any{}
+	if f.ValuePath != "" {
+		shorthand["value_path"] = f.ValuePath
+	}
+	if f.Threshold != "" {
+		threshold, err := strconv.ParseFloat(strings.TrimSpace(f.Threshold), 64)
+		if err != nil {
+			return nil, usageErrorf("--threshold must be a number")
+		}
+		shorthand["threshold"] = threshold
+	}
+	if f.Interval != "" {
+		interval, err := validateGranularity(f.Interval)
+		if err != nil {
+			return nil, usageErrorf("--interval must be <number><unit>, e.g. 5m or 1h")
+		}
+		shorthand["interval"] = interval
+	}
+
+	kindName, _ := payload["kind"].(string)
+	if kindName == "" && base == nil {
+		return nil, usageErrorf("--kind is required (threshold, heartbeat, or any kind with --payload)")
+	}
+	kind, known := transponderKinds[kindName]
+	if !known {
+		if len(shorthand) > 0 {
+			return nil, usageErrorf("kind %q has no flag shorthand; pass its config with --payload or --payload-file", kindName)
+		}
+		if base == nil {
+			return nil, usageErrorf("kind %q has no flag shorthand; pass its fields with --payload or --payload-file (shorthand kinds: threshold, heartbeat)", kindName)
+		}
+		return payload, nil
+	}
+
+	for field, value := range shorthand {
+		if !containsString(kind.Required, field) && !containsString(kind.Optional, field) {
+			return nil, usageErrorf("--%s does not apply to %s transponders", strings.ReplaceAll(field, "_", "-"), kindName)
+		}
+		config[field] = value
+	}
+	if key, _ := payload["key"].(string); key == "" {
+		return nil, usageErrorf("--key is required for %s transponders", kindName)
+	}
+	for _, field := range kind.Required {
+		if _, ok := config[field]; !ok {
+			return nil, usageErrorf("--%s is required for %s transponders", strings.ReplaceAll(field, "_", "-"), kindName)
+		}
+	}
+	payload["config"] = config
+	return payload, nil
+}
+
+// transponderCheck is the result of validating one transponder payload.
+type transponderCheck struct {
+	Errors   []schemaViolation `json:"errors"`
+	Warnings []schemaViolation `json:"warnings"`
+}
+
+func (c transponderCheck) valid() bool {
+	return len(c.Errors) == 0
+}
+
+// validateTransponderPayload checks payload against transponderSchema.
+// Updates may send any subset of fields, so required fields are only
+// enforced when update is false.
+func validateTransponderPayload(payload map[string]any, update bool) transponderCheck {
+	check := transponderCheck{Errors: []schemaViolation{}, Warnings: []schemaViolation{}}
+
+	fields := make([]string, 0, len(transponderSchema))
+	for field := range transponderSchema {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		spec := transponderSchema[field]
+		value, ok := payload[field]
+		if !ok {
+			if spec.Required && !update {
+				check.Errors = append(check.Errors, schemaViolation{Path: field, Problem: "required"})
+			}
+			continue
+		}
+		actual := transponderValueType(value)
+		if actual != spec.Type {
+			check.Errors = append(check.Errors, schemaViolation{Path: field, Problem: fmt.Sprintf("expected %s, got %s", spec.Type, actual)})
+			continue
+		}
+		text, _ := value.(string)
+		if spec.Required && strings.TrimSpace(text) == "" && spec.Type == "string" {
+			check.Errors = append(check.Errors, schemaViolation{Path: field, Problem: "must not be empty"})
+			continue
+		}
+		if len(spec.Enum) > 0 && !containsString(spec.Enum, text) {
+			check.Errors = append(check.Errors, schemaViolation{Path: field, Problem: fmt.Sprintf("expected one of %s, got %q", strings.Join(spec.Enum, ", "), text)})
+		}
+	}
+
+	unknown := make([]string, 0)
+	for field := range payload {
+		if _, ok := transponderSchema[field]; !ok {
+			unknown = append(unknown, field)
+		}
+	}
+	sort.Strings(unknown)
+	for _, field := range unknown {
+		check.Warnings = append(check.Warnings, schemaViolation{Path: field, Problem: "unknown field"})
+	}
+	return check
+}
+
+func transponderValueType(value any) string {
+	if _, ok := value.(map[string]any); ok {
+		return "object"
+	}
+	return schemaValueType(value)
+}
+
+// checkTransponderPayload validates payload before it is sent, printing
+// warnings to stderr and failing on errors.
+func checkTransponderPayload(payload map[string]any, update bool) error {
+	check := validateTransponderPayload(payload, update)
+	for _, warning := range check.Warnings {
+		fmt.Fprintf(os.Stderr, "warning: transponder field %s: %s (pass --no-validate to skip the check)\n", warning.Path, warning.Problem)
+	}
+	if check.valid() {
+		return nil
+	}
+	problems := make([]string, len(check.Errors))
+	for i, violation := range check.Errors {
+		problems[i] = fmt.Sprintf("%s: %s", violation.Path, violation.Problem)
+	}
+	return fmt.Errorf("invalid transponder payload: %s (pass --no-validate to send it anyway)", strings.Join(problems, "; "))
+}
+
+func transpondersValidate(args []string) {
+	fs := flag.NewFlagSet("transponders validate", flag.ExitOnError)
+	payloadJSON := fs.String("payload", "", "JSON payload for transponder")
+	payloadFile := fs.String("payload-file", "", "Path to JSON file for payload")
+	update := fs.Bool("update", false, "Check the payload as a partial update, where no field is required")
+	outputOpts := addOutputFlags(fs)
+	fs.Parse(args)
+
+	if err := outputOpts.validate(""); err != nil {
+		exitError(err)
+	}
+
+	payload, err := loadJSONPayload(*payloadJSON, *payloadFile)
+	if err != nil {
+		exitError(err)
+	}
+	if payload == nil {
+		exitError(usageErrorf("--payload or --payload-file is required"))
+	}
+	payloadMap, ok := payload.(map[string]any)
+	if !ok {
+		exitError(fmt.Errorf("payload must be a JSON object"))
+	}
+
+	check := validateTransponderPayload(payloadMap, *update)
+	response := map[string]any{
+		"valid":    check.valid(),
+		"errors":   check.Errors,
+		"warnings": check.Warnings,
+	}
+	if err := writeJSONOutput(outputOpts, response); err != nil {
+		exitError(err)
+	}
+	if !check.valid() {
+		fmt.Fprintf(os.Stderr, "transponder payload has %d problems\n", len(check.Errors))
+		os.Exit(exitGeneral)
+	}
+}
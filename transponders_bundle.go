@@ -0,0 +1,374 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/trifle-io/trifle-cli/internal/api"
+	"gopkg.in/yaml.v3"
+)
+
+// transponderDefinition strips the server-managed fields (id and *_at
+// timestamps) from a transponder, leaving what create and update accept.
+func transponderDefinition(record map[string]any) map[string]any {
+	definition := make(map[string]any, len(record))
+	for field, value := range record {
+		if field == "id" || strings.HasSuffix(field, "_at") {
+			continue
+		}
+		definition[field] = value
+	}
+	return definition
+}
+
+// plainValue replaces the json.Number values of an API response with int64
+// or float64, so they encode to YAML as numbers rather than strings.
+func plainValue(value any) any {
+	switch v := value.(type) {
+	case json.Number:
+		if parsed, err := v.Int64(); err == nil {
+			return parsed
+		}
+		if parsed, err := v.Float64(); err == nil {
+			return parsed
+		}
+		return v.String()
+	case map[string]any:
+		plain := make(map[string]any, len(v))
+		for key, item := range v {
+			plain[key] = plainValue(item)
+		}
+		return plain
+	case []any:
+		plain := make([]any, len(v))
+		for i, item := range v {
+			plain[i] = plainValue(item)
+		}
+		return plain
+	default:
+		return value
+	}
+}
+
+// sameValue compares two decoded values through their JSON encoding, so a
+// YAML int and an API json.Number holding the same number are equal.
+func sameValue(a, b any) bool {
+	left, errLeft := json.Marshal(a)
+	right, errRight := json.Marshal(b)
+	return errLeft == nil && errRight == nil && string(left) == string(right)
+}
+
+// liveTransponders lists every transponder as objects.
+func liveTransponders(ctx context.Context, client *api.Client) ([]map[string]any, error) {
+	response, err := listTransponders(ctx, client, &transponderListOptions{All: true})
+	if err != nil {
+		return nil, err
+	}
+	objects, ok := responseObjects(response["data"])
+	if !ok {
+		return nil, fmt.Errorf("unexpected transponders list response")
+	}
+	return objects, nil
+}
+
+// exportTransponders turns live transponders into bundle definitions.
+func exportTransponders(objects []map[string]any) []any {
+	bundle := make([]any, len(objects))
+	for i, object := range objects {
+		bundle[i] = plainValue(transponderDefinition(object))
+	}
+	return bundle
+}
+
+// readTransponderBundle loads a YAML list of transponder definitions.
+func readTransponderBundle(path string) ([]map[string]any, error) {
+	contents, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("read bundle: %w", err)
+	}
+	var bundle []map[string]any
+	if err := yaml.Unmarshal(contents, &bundle); err != nil {
+		return nil, fmt.Errorf("parse bundle %s: %w", path, err)
+	}
+	return bundle, nil
+}
+
+// checkPruneBundle refuses to prune against a bundle with no entries, such as
+// an empty or comment-only file, since that deletes every live transponder.
+// allowEmpty says that is what the user wants.
+func checkPruneBundle(bundle []map[string]any, prune, allowEmpty bool) error {
+	if prune && len(bundle) == 0 && !allowEmpty {
+		return usageErrorf("the bundle has no transponders, so --prune would delete every live one (pass --allow-empty to do that)")
+	}
+	return nil
+}
+
+// transponderAction is one step of a transponders apply plan, and its
+// outcome once run.
+type transponderAction struct {
+	Action string   `json:"action"`
+	Name   string   `json:"name"`
+	ID     string   `json:"id,omitempty"`
+	Fields []string `json:"fields,omitempty"`
+	Status string   `json:"status,omitempty"`
+	Error  string   `json:"error,omitempty"`
+
+	payload map[string]any
+}
+
+// planTransponderApply matches the bundle against the live transponders by
+// name. Missing ones are created and ones whose bundle fields differ are
+// updated; fields the bundle leaves out are not touched. With prune, live
+// transponders absent from the bundle are deleted.
+func planTransponderApply(live, bundle []map[string]any, prune bool) ([]transponderAction, int, error) {
+	byName := make(map[string]map[string]any, len(live))
+	for _, object := range live {
+		name, _ := object["name"].(string)
+		if _, dup := byName[name]; dup {
+			return nil, 0, fmt.Errorf("several live transponders are named %q; rename or delete one before applying", name)
+		}
+		byName[name] = object
+	}
+
+	actions := []transponderAction{}
+	unchanged := 0
+	wanted := make(map[string]bool, len(bundle))
+	for i, definition := range bundle {
+		name, _ := definition["name"].(string)
+		if strings.TrimSpace(name) == "" {
+			return nil, 0, fmt.Errorf("bundle entry %d has no name", i+1)
+		}
+		if wanted[name] {
+			return nil, 0, fmt.Errorf("bundle lists %q more than once", name)
+		}
+		wanted[name] = true
+		definition = transponderDefinition(definition)
+
+		current, exists := byName[name]
+		if !exists {
+			actions = append(actions, transponderAction{Action: "create", Name: name, payload: definition})
+			continue
+		}
+		id, err := transponderID(current)
+		if err != nil {
+			return nil, 0, err
+		}
+		changed := []string{}
+		for field, value := range definition {
+			if !sameValue(value, current[field]) {
+				changed = append(changed, field)
+			}
+		}
+		if len(changed) == 0 {
+			unchanged++
+			continue
+		}
+		sort.Strings(changed)
+		update := make(map[string]any, len(changed))
+		for _, field := range changed {
+			update[field] = definition[field]
+		}
+		actions = append(actions, transponderAction{Action: "update", Name: name, ID: id, Fields: changed, payload: update})
+	}
+
+	if prune {
+		for _, object := range live {
+			name, _ := object["name"].(string)
+			if wanted[name] {
+				continue
+			}
+			id, err := transponderID(object)
+			if err != nil {
+				return nil, 0, err
+			}
+			actions = append(actions, transponderAction{Action: "delete", Name: name, ID: id})
+		}
+	}
+	return actions, unchanged, nil
+}
+
+// runTransponderAction carries out one planned action against the API.
+func runTransponderAction(ctx context.Context, client *api.Client, action transponderAction) error {
+	switch action.Action {
+	case "create":
+		return client.CreateTransponder(ctx, action.payload, nil)
+	case "update":
+		return client.UpdateTransponder(ctx, action.ID, action.payload, nil)
+	case "delete":
+		return client.DeleteTransponder(ctx, action.ID, nil)
+	}
+	return fmt.Errorf("unknown action %q", action.Action)
+}
+
+func transpondersExport(args []string) {
+	rc, err := resolveCommandConfig(args)
+	if err != nil {
+		exitError(err)
+	}
+
+	fs := flag.NewFlagSet("transponders export", flag.ExitOnError)
+	addConfigFlag(fs, rc.ConfigPath)
+	addSourceFlag(fs, rc.SourceName)
+	opts := addCommonFlags(fs, &rc.Source)
+	out := fs.String("out", "", "Write the YAML bundle to this file instead of stdout (- for stdout)")
+	fs.Parse(args)
+
+	if driverNameFromSource(rc.Source) != "api" {
+		exitError(errors.New("transponders are only available for api drivers (use --source <api-source>)"))
+	}
+
+	if err := ensureToken(opts, true); err != nil {
+		exitError(err)
+	}
+
+	client, err := newClient(opts)
+	if err != nil {
+		exitError(err)
+	}
+
+	objects, err := liveTransponders(context.Background(), client)
+	if err != nil {
+		exitError(err)
+	}
+	bundle := exportTransponders(objects)
+	err = writeCommandOutput(&outputOptions{Path: *out}, func(w io.Writer) error {
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(bundle); err != nil {
+			return fmt.Errorf("encode bundle: %w", err)
+		}
+		return encoder.Close()
+	})
+	if err != nil {
+		exitError(err)
+	}
+}
+
+func transpondersApply(args []string) {
+	rc, err := resolveCommandConfig(args)
+	if err != nil {
+		exitError(err)
+	}
+
+	fs := flag.NewFlagSet("transponders apply", flag.ExitOnError)
+	addConfigFlag(fs, rc.ConfigPath)
+	addSourceFlag(fs, rc.SourceName)
+	opts := addCommonFlags(fs, &rc.Source)
+	file := fs.String("file", "", "YAML bundle written by transponders export")
+	prune := fs.Bool("prune", false, "Delete live transponders that are not in the bundle")
+	allowEmpty := fs.Bool("allow-empty", false, "With --prune, accept a bundle with no transponders and delete every live one")
+	yes := fs.Bool("yes", false, "Delete pruned transponders without asking for confirmation")
+	dryRun := fs.Bool("dry-run", false, "Print the planned create/update/delete actions without running them")
+	noValidate := fs.Bool("no-validate", false, "Send bundle entries without checking them against the transponder schema")
+	outputOpts := addOutputFlags(fs)
+	fs.Parse(args)
+
+	if err := outputOpts.validate(""); err != nil {
+		exitError(err)
+	}
+
+	if driverNameFromSource(rc.Source) != "api" {
+		exitError(errors.New("transponders are only available for api drivers (use --source <api-source>)"))
+	}
+
+	if *file == "" {
+		exitError(usageErrorf("--file is required"))
+	}
+	bundle, err := readTransponderBundle(*file)
+	if err != nil {
+		exitError(err)
+	}
+	if err := checkPruneBundle(bundle, *prune, *allowEmpty); err != nil {
+		exitError(err)
+	}
+	if !*noValidate {
+		for _, definition := range bundle {
+			if err := checkTransponderPayload(transponderDefinition(definition), false); err != nil {
+				name, _ := definition["name"].(string)
+				exitError(fmt.Errorf("%s: %w", name, err))
+			}
+		}
+	}
+
+	if err := ensureToken(opts, true); err != nil {
+		exitError(err)
+	}
+
+	client, err := newClient(opts)
+	if err != nil {
+		exitError(err)
+	}
+	ctx := context.Background()
+
+	live, err := liveTransponders(ctx, client)
+	if err != nil {
+		exitError(err)
+	}
+	actions, unchanged, err := planTransponderApply(live, bundle, *prune)
+	if err != nil {
+		exitError(err)
+	}
+
+	var deletions []transponderAction
+	for _, action := range actions {
+		if action.Action == "delete" {
+			deletions = append(deletions, action)
+		}
+	}
+	if len(deletions) > 0 && !*dryRun && !*yes {
+		if !stdinIsTerminal() {
+			exitError(usageErrorf("refusing to prune %d transponders without --yes", len(deletions)))
+		}
+		fmt.Fprintln(os.Stderr, "Transponders to delete:")
+		for _, action := range deletions {
+			fmt.Fprintf(os.Stderr, "  %s\t%s\n", action.ID, action.Name)
+		}
+		ok, err := confirmPrune(os.Stdin, os.Stderr, fmt.Sprintf("Delete %d transponders?", len(deletions)))
+		if err != nil {
+			exitError(err)
+		}
+		if !ok {
+			exitError(fmt.Errorf("apply cancelled"))
+		}
+	}
+
+	counts := map[string]int{}
+	failed := 0
+	for i := range actions {
+		counts[actions[i].Action]++
+		if *dryRun {
+			continue
+		}
+		if err := runTransponderAction(ctx, client, actions[i]); err != nil {
+			actions[i].Status = "failed"
+			actions[i].Error = err.Error()
+			failed++
+			continue
+		}
+		actions[i].Status = "done"
+	}
+
+	summary := map[string]any{
+		"dry_run":   *dryRun,
+		"create":    counts["create"],
+		"update":    counts["update"],
+		"delete":    counts["delete"],
+		"unchanged": unchanged,
+		"failed":    failed,
+		"actions":   actions,
+	}
+	if err := writeJSONOutput(outputOpts, map[string]any{"data": summary}); err != nil {
+		exitError(err)
+	}
+	if failed > 0 {
+		exitError(fmt.Errorf("%d of %d transponder actions failed", failed, len(actions)))
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestExportTranspondersRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	live := []map[string]any{{
+		"id":         json.Number("7"),
+		"name":       "High error rate",
+		"kind":       "threshold",
+		"key":        "event::logs",
+		"status":     "active",
+		"created_at": "2026-01-01T00:00:00Z",
+		"updated_at": "2026-01-02T00:00:00Z",
+		"config":     map[string]any{"value_path": "status.error", "threshold": json.Number("50"), "ratio": json.Number("0.5")},
+	}}
+
+	encoded, err := yaml.Marshal(exportTransponders(live))
+	if err != nil {
+		t.Fatalf("yaml.Marshal returned error: %v", err)
+	}
+	text := string(encoded)
+	for _, stripped := range []string{"id:", "created_at", "updated_at"} {
+		if strings.Contains(text, stripped) {
+			t.Fatalf("bundle kept %s:\n%s", stripped, text)
+		}
+	}
+	if !strings.Contains(text, "threshold: 50\n") || !strings.Contains(text, "ratio: 0.5\n") {
+		t.Fatalf("bundle did not write numbers as numbers:\n%s", text)
+	}
+
+	path := filepath.Join(t.TempDir(), "transponders.yaml")
+	if err := os.WriteFile(path, encoded, 0o600); err != nil {
+		t.Fatalf("write bundle: %v", err)
+	}
+	bundle, err := readTransponderBundle(path)
+	if err != nil {
+		t.Fatalf("readTransponderBundle returned error: %v", err)
+	}
+	actions, unchanged, err := planTransponderApply(live, bundle, true)
+	if err != nil {
+		t.Fatalf("planTransponderApply returned error: %v", err)
+	}
+	if len(actions) != 0 || unchanged != 1 {
+		t.Fatalf("applying an export planned %+v (%d unchanged), want nothing to do", actions, unchanged)
+	}
+}
+
+func TestPlanTransponderApply(t *testing.T) {
+	t.Parallel()
+
+	live := []map[string]any{
+		{"id": json.Number("1"), "name": "errors", "kind": "threshold", "key": "event::logs", "config": map[string]any{"threshold": json.Number("50")}},
+		{"id": json.Number("2"), "name": "heartbeat", "kind": "heartbeat", "key": "event::logs", "status": "active"},
+		{"id": json.Number("3"), "name": "loadtest", "kind": "heartbeat", "key": "event::load"},
+	}
+	bundle := []map[string]any{
+		{"name": "errors", "kind": "threshold", "key": "event::logs", "config": map[string]any{"threshold": 80}},
+		{"name": "heartbeat", "kind": "heartbeat", "key": "event::logs"},
+		{"name": "signups", "kind": "heartbeat", "key": "event::signups", "config": map[string]any{"interval": "1h"}},
+	}
+
+	summarize := func(actions []transponderAction) []string {
+		lines := make([]string, len(actions))
+		for i, action := range actions {
+			lines[i] = action.Action + " " + action.Name + " " + action.ID + " " + strings.Join(action.Fields, ",")
+		}
+		return lines
+	}
+
+	actions, unchanged, err := planTransponderApply(live, bundle, false)
+	if err != nil {
+		t.Fatalf("planTransponderApply returned error: %v", err)
+	}
+	want := []string{"update errors 1 config", "create signups  "}
+	if got := summarize(actions); !reflect.DeepEqual(got, want) || unchanged != 1 {
+		t.Fatalf("plan = %q (%d unchanged), want %q (1 unchanged)", got, unchanged, want)
+	}
+	if !reflect.DeepEqual(actions[0].payload, map[string]any{"config": map[string]any{"threshold": 80}}) {
+		t.Fatalf("update payload = %v, want only the changed config", actions[0].payload)
+	}
+
+	actions, _, err = planTransponderApply(live, bundle, true)
+	if err != nil {
+		t.Fatalf("planTransponderApply returned error: %v", err)
+	}
+	if got := summarize(actions); got[len(got)-1] != "delete loadtest 3 " {
+		t.Fatalf("plan with prune = %q, want it to end by deleting loadtest", got)
+	}
+
+	errorCases := []struct {
+		name   string
+		live   []map[string]any
+		bundle []map[string]any
+		want   string
+	}{
+		{"unnamed entry", live, []map[string]any{{"kind": "heartbeat"}}, "bundle entry 1 has no name"},
+		{"duplicate entry", live, []map[string]any{{"name": "a"}, {"name": "a"}}, `bundle lists "a" more than once`},
+		{"duplicate live", []map[string]any{{"id": "1", "name": "a"}, {"id": "2", "name": "a"}}, nil, `several live transponders are named "a"`},
+	}
+	for _, tt := range errorCases {
+		_, _, err := planTransponderApply(tt.live, tt.bundle, false)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Fatalf("%s: err = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestCheckPruneBundle(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "transponders.yaml")
+	if err := os.WriteFile(path, []byte("# nothing here yet\n"), 0o600); err != nil {
+		t.Fatalf("write bundle: %v", err)
+	}
+	empty, err := readTransponderBundle(path)
+	if err != nil {
+		t.Fatalf("readTransponderBundle returned error: %v", err)
+	}
+
+	tests := []struct {
+		bundle     []map[string]any
+		prune      bool
+		allowEmpty bool
+		wantErr    bool
+	}{
+		{bundle: empty, prune: true, wantErr: true},
+		{bundle: empty, prune: true, allowEmpty: true},
+		{bundle: empty, prune: false},
+		{bundle: []map[string]any{{"name": "errors"}}, prune: true},
+	}
+	for _, tt := range tests {
+		err := checkPruneBundle(tt.bundle, tt.prune, tt.allowEmpty)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("checkPruneBundle(%d entries, prune %v, allow empty %v) = %v, want error %v", len(tt.bundle), tt.prune, tt.allowEmpty, err, tt.wantErr)
+		}
+	}
+}
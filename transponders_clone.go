@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"strings"
+
+	"github.com/trifle-io/trifle-cli/internal/api"
+)
+
+// cloneTransponderPayload turns a fetched transponder into a create payload:
+// its definition with name and the --set overrides applied.
+func cloneTransponderPayload(response map[string]any, name string, sets []string) (map[string]any, error) {
+	record := response
+	if data, ok := response["data"].(map[string]any); ok {
+		record = data
+	}
+	payload := transponderDefinition(record)
+	payload["name"] = name
+
+	if len(sets) > 0 {
+		overrides, err := buildSetValues(sets)
+		if err != nil {
+			return nil, usageErrorf("%v", err)
+		}
+		payload = mergeSetValues(payload, overrides)
+	}
+	return payload, nil
+}
+
+// cloneTransponder fetches id and creates a copy named name, returning the
+// create response.
+func cloneTransponder(ctx context.Context, client *api.Client, id, name string, sets []string) (map[string]any, error) {
+	var source map[string]any
+	if err := client.GetTransponder(ctx, id, &source); err != nil {
+		return nil, err
+	}
+	payload, err := cloneTransponderPayload(source, name, sets)
+	if err != nil {
+		return nil, err
+	}
+	var response map[string]any
+	if err := client.CreateTransponder(ctx, payload, &response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func transpondersClone(args []string) {
+	rc, err := resolveCommandConfig(args)
+	if err != nil {
+		exitError(err)
+	}
+
+	fs := flag.NewFlagSet("transponders clone", flag.ExitOnError)
+	addConfigFlag(fs, rc.ConfigPath)
+	addSourceFlag(fs, rc.SourceName)
+	opts := addCommonFlags(fs, &rc.Source)
+	id := fs.String("id", "", "ID of the transponder to copy")
+	name := fs.String("name", "", "Name of the new transponder")
+	var sets setFlag
+	fs.Var(&sets, "set", "Override one field of the copy, e.g. key=event::other or config.threshold=10 (repeatable)")
+	quiet := addQuietFlag(fs)
+	outputOpts := addOutputFlags(fs)
+	fs.Parse(args)
+
+	if err := outputOpts.validate(""); err != nil {
+		exitError(err)
+	}
+	if *quiet {
+		if err := validateQuiet(outputOpts, ""); err != nil {
+			exitError(err)
+		}
+	}
+
+	if driverNameFromSource(rc.Source) != "api" {
+		exitError(errors.New("transponders are only available for api drivers (use --source <api-source>)"))
+	}
+
+	if *id == "" || strings.TrimSpace(*name) == "" {
+		exitError(usageErrorf("--id and --name are required"))
+	}
+
+	if err := ensureToken(opts, true); err != nil {
+		exitError(err)
+	}
+
+	client, err := newClient(opts)
+	if err != nil {
+		exitError(err)
+	}
+
+	response, err := cloneTransponder(context.Background(), client, *id, strings.TrimSpace(*name), sets)
+	if err != nil {
+		exitError(err)
+	}
+	newID, err := transponderID(response)
+	if err != nil {
+		exitError(err)
+	}
+
+	if *quiet {
+		if err := writeQuietLines(outputOpts, []string{newID}); err != nil {
+			exitError(err)
+		}
+		return
+	}
+
+	payload := map[string]any{
+		"source_id": *id,
+		"id":        newID,
+		"data":      response["data"],
+	}
+	if payload["data"] == nil {
+		payload["data"] = response
+	}
+	if err := writeJSONOutput(outputOpts, payload); err != nil {
+		exitError(err)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/trifle-io/trifle-cli/internal/api"
+)
+
+func TestCloneTransponder(t *testing.T) {
+	t.Parallel()
+
+	var created map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v1/transponders/42":
+			json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{
+				"id":         42,
+				"name":       "Errors",
+				"kind":       "threshold",
+				"key":        "event::logs",
+				"status":     "active",
+				"config":     map[string]any{"value_path": "status.error", "threshold": 50, "interval": "5m"},
+				"created_at": "2026-10-01T00:00:00Z",
+				"updated_at": "2026-10-02T00:00:00Z",
+			}})
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v1/transponders":
+			_ = json.NewDecoder(r.Body).Decode(&created)
+			json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"id": 99}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client, err := api.New(server.URL, "token", 5*time.Second)
+	if err != nil {
+		t.Fatalf("api.New returned error: %v", err)
+	}
+	response, err := cloneTransponder(context.Background(), client, "42", "Checkout errors", []string{"key=event::checkout", "config.threshold=20"})
+	if err != nil {
+		t.Fatalf("cloneTransponder returned error: %v", err)
+	}
+	if id, _ := transponderID(response); id != "99" {
+		t.Fatalf("new id = %q, want 99", id)
+	}
+
+	want := map[string]any{
+		"name":   "Checkout errors",
+		"kind":   "threshold",
+		"key":    "event::checkout",
+		"status": "active",
+		"config": map[string]any{"value_path": "status.error", "threshold": 20.0, "interval": "5m"},
+	}
+	if !reflect.DeepEqual(created, want) {
+		t.Fatalf("created payload = %v, want %v", created, want)
+	}
+}
+
+func TestCloneTransponderPayloadConflict(t *testing.T) {
+	t.Parallel()
+
+	source := map[string]any{"name": "a", "kind": "threshold"}
+	if _, err := cloneTransponderPayload(source, "b", []string{"config=1", "config.threshold=2"}); err == nil {
+		t.Fatal("expected a conflicting --set error")
+	}
+}
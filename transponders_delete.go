@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/trifle-io/trifle-cli/internal/api"
+)
+
+// idsFlag collects repeatable --id flags.
+type idsFlag []string
+
+func (v *idsFlag) String() string {
+	return strings.Join(*v, ",")
+}
+
+func (v *idsFlag) Set(value string) error {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return fmt.Errorf("id cannot be empty")
+	}
+	*v = append(*v, value)
+	return nil
+}
+
+// transponderMatch selects transponders whose string field matches a
+// regular expression, written field~regex (a bare regex matches the name).
+type transponderMatch struct {
+	Field string
+	Re    *regexp.Regexp
+}
+
+func parseTransponderMatch(value string) (transponderMatch, error) {
+	field, pattern, ok := strings.Cut(value, "~")
+	if !ok {
+		field, pattern = "name", value
+	}
+	field = strings.TrimSpace(field)
+	if field == "" || pattern == "" {
+		return transponderMatch{}, usageErrorf("--match must be field~regex, e.g. name~^loadtest-")
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return transponderMatch{}, usageErrorf("invalid --match regex: %v", err)
+	}
+	return transponderMatch{Field: field, Re: re}, nil
+}
+
+func (m transponderMatch) matches(object map[string]any) bool {
+	value, ok := object[m.Field].(string)
+	return ok && m.Re.MatchString(value)
+}
+
+// transponderDeletion is the outcome of deleting one transponder.
+type transponderDeletion struct {
+	ID     string `json:"id"`
+	Name   string `json:"name,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type transponderDeleteSummary struct {
+	Matched int                   `json:"matched"`
+	Deleted int                   `json:"deleted"`
+	Failed  int                   `json:"failed"`
+	Results []transponderDeletion `json:"results"`
+}
+
+// matchingTransponders lists every transponder and keeps those m matches.
+func matchingTransponders(ctx context.Context, client *api.Client, m transponderMatch) ([]transponderDeletion, error) {
+	response, err := listTransponders(ctx, client, &transponderListOptions{All: true})
+	if err != nil {
+		return nil, err
+	}
+	objects, ok := responseObjects(response["data"])
+	if !ok {
+		return nil, fmt.Errorf("unexpected transponders list response")
+	}
+	targets := []transponderDeletion{}
+	for _, object := range objects {
+		if !m.matches(object) {
+			continue
+		}
+		id, err := transponderID(object)
+		if err != nil {
+			return nil, err
+		}
+		name, _ := object["name"].(string)
+		targets = append(targets, transponderDeletion{ID: id, Name: name})
+	}
+	return targets, nil
+}
+
+// deleteTransponders deletes each target, carrying on past failures.
+func deleteTransponders(ctx context.Context, targets []transponderDeletion, remove func(ctx context.Context, id string) error) transponderDeleteSummary {
+	summary := transponderDeleteSummary{Matched: len(targets), Results: make([]transponderDeletion, len(targets))}
+	for i, target := range targets {
+		result := target
+		if err := remove(ctx, target.ID); err != nil {
+			result.Status = "failed"
+			result.Error = err.Error()
+			summary.Failed++
+		} else {
+			result.Status = "deleted"
+			summary.Deleted++
+		}
+		summary.Results[i] = result
+	}
+	return summary
+}
+
+func transpondersDelete(args []string) {
+	rc, err := resolveCommandConfig(args)
+	if err != nil {
+		exitError(err)
+	}
+
+	fs := flag.NewFlagSet("transponders delete", flag.ExitOnError)
+	addConfigFlag(fs, rc.ConfigPath)
+	addSourceFlag(fs, rc.SourceName)
+	opts := addCommonFlags(fs, &rc.Source)
+	var ids idsFlag
+	fs.Var(&ids, "id", "Transponder ID (repeatable to delete several)")
+	match := fs.String("match", "", "Delete every transponder whose field matches a regex, e.g. name~^loadtest-")
+	yes := fs.Bool("yes", false, "Delete several transponders without asking for confirmation")
+	outputOpts := addOutputFlags(fs)
+	fs.Parse(args)
+
+	if err := outputOpts.validate(""); err != nil {
+		exitError(err)
+	}
+
+	if driverNameFromSource(rc.Source) != "api" {
+		exitError(errors.New("transponders are only available for api drivers (use --source <api-source>)"))
+	}
+
+	if len(ids) == 0 && *match == "" {
+		exitError(usageErrorf("--id or --match is required"))
+	}
+	if len(ids) > 0 && *match != "" {
+		exitError(usageErrorf("--id cannot be combined with --match"))
+	}
+	var matcher transponderMatch
+	if *match != "" {
+		if matcher, err = parseTransponderMatch(*match); err != nil {
+			exitError(err)
+		}
+	}
+
+	if err := ensureToken(opts, true); err != nil {
+		exitError(err)
+	}
+
+	client, err := newClient(opts)
+	if err != nil {
+		exitError(err)
+	}
+	ctx := context.Background()
+
+	if len(ids) == 1 {
+		var response map[string]any
+		if err := client.DeleteTransponder(ctx, ids[0], &response); err != nil {
+			exitError(err)
+		}
+		if err := writeJSONOutput(outputOpts, response); err != nil {
+			exitError(err)
+		}
+		return
+	}
+
+	var targets []transponderDeletion
+	if *match != "" {
+		if targets, err = matchingTransponders(ctx, client, matcher); err != nil {
+			exitError(err)
+		}
+	} else {
+		for _, id := range ids {
+			targets = append(targets, transponderDeletion{ID: id})
+		}
+	}
+
+	if len(targets) > 0 && !*yes {
+		if !stdinIsTerminal() {
+			exitError(usageErrorf("refusing to delete %d transponders without --yes", len(targets)))
+		}
+		fmt.Fprintln(os.Stderr, "Transponders to delete:")
+		for _, target := range targets {
+			fmt.Fprintf(os.Stderr, "  %s\t%s\n", target.ID, target.Name)
+		}
+		ok, err := confirmPrune(os.Stdin, os.Stderr, fmt.Sprintf("Delete %d transponders?", len(targets)))
+		if err != nil {
+			exitError(err)
+		}
+		if !ok {
+			exitError(fmt.Errorf("delete cancelled"))
+		}
+	}
+
+	summary := deleteTransponders(ctx, targets, func(ctx context.Context, id string) error {
+		return client.DeleteTransponder(ctx, id, nil)
+	})
+	if err := writeJSONOutput(outputOpts, map[string]any{"data": summary}); err != nil {
+		exitError(err)
+	}
+	if summary.Failed > 0 {
+		exitError(fmt.Errorf("%d of %d transponder deletions failed", summary.Failed, summary.Matched))
+	}
+}
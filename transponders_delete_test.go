@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/trifle-io/trifle-cli/internal/api"
+)
+
+func TestParseTransponderMatch(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		value     string
+		wantField string
+		match     string
+		wantErr   string
+	}{
+		{"name~^loadtest-", "name", "loadtest-1", ""},
+		{"^loadtest-", "name", "loadtest-1", ""},
+		{"key~^event::", "key", "event::logs", ""},
+		{"name~", "", "", "--match must be field~regex"},
+		{"~^a", "", "", "--match must be field~regex"},
+		{"name~(", "", "", "invalid --match regex"},
+	}
+	for _, tt := range tests {
+		got, err := parseTransponderMatch(tt.value)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("parseTransponderMatch(%q) = %v, want %q", tt.value, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("parseTransponderMatch(%q) returned error: %v", tt.value, err)
+		}
+		if got.Field != tt.wantField || !got.matches(map[string]any{tt.wantField: tt.match}) {
+			t.Fatalf("parseTransponderMatch(%q) = %s~%v, want it to match %s=%q", tt.value, got.Field, got.Re, tt.wantField, tt.match)
+		}
+	}
+}
+
+func TestDeleteMatchingTranspondersContinuesPastFailures(t *testing.T) {
+	t.Parallel()
+
+	var deleted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			json.NewEncoder(w).Encode(map[string]any{"data": []any{
+				map[string]any{"id": json.Number("1"), "name": "loadtest-a"},
+				map[string]any{"id": json.Number("2"), "name": "billing"},
+				map[string]any{"id": json.Number("3"), "name": "loadtest-b"},
+				map[string]any{"id": json.Number("4"), "name": "loadtest-c"},
+			}})
+			return
+		}
+		id := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		deleted = append(deleted, id)
+		if id == "3" {
+			http.Error(w, `{"error":"locked"}`, http.StatusConflict)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client, err := api.New(server.URL, "token", 5*time.Second)
+	if err != nil {
+		t.Fatalf("api.New returned error: %v", err)
+	}
+	match, err := parseTransponderMatch("name~^loadtest-")
+	if err != nil {
+		t.Fatalf("parseTransponderMatch returned error: %v", err)
+	}
+	ctx := context.Background()
+	targets, err := matchingTransponders(ctx, client, match)
+	if err != nil {
+		t.Fatalf("matchingTransponders returned error: %v", err)
+	}
+	summary := deleteTransponders(ctx, targets, func(ctx context.Context, id string) error {
+		return client.DeleteTransponder(ctx, id, nil)
+	})
+
+	if want := []string{"1", "3", "4"}; !reflect.DeepEqual(deleted, want) {
+		t.Fatalf("deleted = %v, want %v", deleted, want)
+	}
+	if summary.Matched != 3 || summary.Deleted != 2 || summary.Failed != 1 {
+		t.Fatalf("summary = %+v, want 3 matched, 2 deleted, 1 failed", summary)
+	}
+	failed := summary.Results[1]
+	if failed.ID != "3" || failed.Name != "loadtest-b" || failed.Status != "failed" || !strings.Contains(failed.Error, "locked") {
+		t.Fatalf("results[1] = %+v, want loadtest-b failed with the server error", failed)
+	}
+	if summary.Results[2].Status != "deleted" {
+		t.Fatalf("results[2] = %+v, want deleted after the failure", summary.Results[2])
+	}
+}
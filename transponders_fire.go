@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/trifle-io/trifle-cli/internal/api"
+)
+
+// testTransponder asks the server to fire id once. Servers without the test
+// endpoint answer 404, which is reported as such.
+func testTransponder(ctx context.Context, client *api.Client, id string) (map[string]any, error) {
+	var response map[string]any
+	if err := client.TestTransponder(ctx, id, &response); err != nil {
+		var apiErr *api.Error
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("server does not support test firing (or transponder %s does not exist): %w", id, err)
+		}
+		return nil, err
+	}
+	if response == nil {
+		response = map[string]any{}
+	}
+	return response, nil
+}
+
+// testDelivered reads whether a test delivery succeeded from a boolean
+// delivered or success field, or from status. A response that says neither
+// counts as delivered, since the server accepted the request.
+func testDelivered(response map[string]any) (bool, string) {
+	record := response
+	if data, ok := response["data"].(map[string]any); ok {
+		record = data
+	}
+	message, _ := record["message"].(string)
+	if message == "" {
+		message, _ = record["error"].(string)
+	}
+	for _, field := range []string{"delivered", "success"} {
+		if delivered, ok := record[field].(bool); ok {
+			return delivered, message
+		}
+	}
+	status, _ := record["status"].(string)
+	switch strings.ToLower(strings.TrimSpace(status)) {
+	case "failed", "failure", "error":
+		return false, message
+	}
+	return true, message
+}
+
+func transpondersTest(args []string) {
+	rc, err := resolveCommandConfig(args)
+	if err != nil {
+		exitError(err)
+	}
+
+	fs := flag.NewFlagSet("transponders test", flag.ExitOnError)
+	addConfigFlag(fs, rc.ConfigPath)
+	addSourceFlag(fs, rc.SourceName)
+	opts := addCommonFlags(fs, &rc.Source)
+	id := fs.String("id", "", "Transponder ID")
+	outputOpts := addOutputFlags(fs)
+	fs.Parse(args)
+
+	if err := outputOpts.validate(""); err != nil {
+		exitError(err)
+	}
+
+	if driverNameFromSource(rc.Source) != "api" {
+		exitError(errors.New("transponders are only available for api drivers (use --source <api-source>)"))
+	}
+
+	if *id == "" {
+		exitError(usageErrorf("--id is required"))
+	}
+
+	if err := ensureToken(opts, true); err != nil {
+		exitError(err)
+	}
+
+	client, err := newClient(opts)
+	if err != nil {
+		exitError(err)
+	}
+
+	response, err := testTransponder(context.Background(), client, *id)
+	if err != nil {
+		exitError(err)
+	}
+	if err := writeJSONOutput(outputOpts, response); err != nil {
+		exitError(err)
+	}
+	if delivered, message := testDelivered(response); !delivered {
+		if message == "" {
+			message = "see the response for details"
+		}
+		exitError(fmt.Errorf("test delivery of transponder %s failed: %s", *id, message))
+	}
+}
+
+func testTransponderPayload(ctx context.Context, state *mcpState, args map[string]any) (map[string]any, error) {
+	if state != nil && state.Local != nil {
+		return nil, fmt.Errorf("transponders are only available for api drivers")
+	}
+	if state == nil || state.API == nil {
+		return nil, fmt.Errorf("api client is not configured")
+	}
+
+	id := strings.TrimSpace(getStringArg(args, "id"))
+	if id == "" {
+		return nil, fmt.Errorf("id is required")
+	}
+
+	return testTransponder(ctx, state.API, id)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/trifle-io/trifle-cli/internal/api"
+)
+
+func TestTestDelivered(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		response    map[string]any
+		want        bool
+		wantMessage string
+	}{
+		{map[string]any{"data": map[string]any{"delivered": true, "response_body": "ok"}}, true, ""},
+		{map[string]any{"data": map[string]any{"delivered": false, "error": "connection refused"}}, false, "connection refused"},
+		{map[string]any{"success": false, "message": "webhook returned 500"}, false, "webhook returned 500"},
+		{map[string]any{"data": map[string]any{"status": "Failed"}}, false, ""},
+		{map[string]any{"data": map[string]any{"status": "queued"}}, true, ""},
+		{map[string]any{}, true, ""},
+	}
+	for _, tt := range tests {
+		got, message := testDelivered(tt.response)
+		if got != tt.want || message != tt.wantMessage {
+			t.Fatalf("testDelivered(%v) = %v, %q; want %v, %q", tt.response, got, message, tt.want, tt.wantMessage)
+		}
+	}
+}
+
+func TestMCPTestTransponder(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v1/transponders/42/test" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"delivered": true, "response_body": "accepted"}})
+	}))
+	defer server.Close()
+
+	client, err := api.New(server.URL, "token", 5*time.Second)
+	if err != nil {
+		t.Fatalf("api.New returned error: %v", err)
+	}
+	state := &mcpState{Driver: "api", API: client}
+	ctx := context.Background()
+
+	result, err := executeTool(ctx, state, "test_transponder", map[string]any{"id": "42"})
+	if err != nil {
+		t.Fatalf("test_transponder returned error: %v", err)
+	}
+	if body := decodeToolPayload(t, result)["data"].(map[string]any)["response_body"]; body != "accepted" {
+		t.Fatalf("response_body = %v, want accepted", body)
+	}
+
+	_, err = executeTool(ctx, state, "test_transponder", map[string]any{"id": "7"})
+	if err == nil || !strings.Contains(err.Error(), "server does not support test firing") {
+		t.Fatalf("err = %v, want a test firing unsupported error", err)
+	}
+	if _, _, code := classifyError(err); code != exitAPIClient {
+		t.Fatalf("exit code = %d, want %d", code, exitAPIClient)
+	}
+}
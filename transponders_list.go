@@ -0,0 +1,300 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/trifle-io/trifle-cli/internal/api"
+	"github.com/trifle-io/trifle-cli/internal/output"
+	triflestats "github.com/trifle-io/trifle_stats_go"
+)
+
+// maxTransponderPages stops --all from following a server that never
+// reports a last page.
+const maxTransponderPages = 1000
+
+// transponderListOptions holds the filter and pagination flags of
+// transponders list.
+type transponderListOptions struct {
+	Status       string
+	NameContains string
+	Limit        int
+	Page         int
+	Cursor       string
+	All          bool
+}
+
+func addTransponderListFlags(fs *flag.FlagSet) *transponderListOptions {
+	o := &transponderListOptions{}
+	fs.StringVar(&o.Status, "status", "", "Only list transponders with this status: active|paused")
+	fs.StringVar(&o.NameContains, "name-contains", "", "Only list transponders whose name contains this text (case-insensitive)")
+	fs.IntVar(&o.Limit, "limit", 0, "Maximum transponders per page (0 for the server default)")
+	fs.IntVar(&o.Page, "page", 0, "Page number to fetch")
+	fs.StringVar(&o.Cursor, "cursor", "", "Pagination cursor from a previous response")
+	fs.BoolVar(&o.All, "all", false, "Follow pagination and list every page")
+	return o
+}
+
+func (o *transponderListOptions) validate() error {
+	o.Status = strings.ToLower(strings.TrimSpace(o.Status))
+	switch o.Status {
+	case "", "active", "paused":
+	default:
+		return usageErrorf("unsupported --status %q (use active or paused)", o.Status)
+	}
+	if o.Limit < 0 {
+		return usageErrorf("--limit must be 0 or greater")
+	}
+	if o.Page < 0 {
+		return usageErrorf("--page must be 0 or greater")
+	}
+	if o.Page > 0 && o.Cursor != "" {
+		return usageErrorf("--page cannot be combined with --cursor")
+	}
+	return nil
+}
+
+// params returns the query parameters for GetTransponders; empty values are
+// dropped by the client.
+func (o *transponderListOptions) params() map[string]string {
+	params := map[string]string{
+		"status":        o.Status,
+		"name_contains": o.NameContains,
+		"cursor":        o.Cursor,
+	}
+	if o.Limit > 0 {
+		params["limit"] = strconv.Itoa(o.Limit)
+	}
+	if o.Page > 0 {
+		params["page"] = strconv.Itoa(o.Page)
+	}
+	return params
+}
+
+// listTransponders fetches one page, or every page with --all, and applies
+// the filters again on the client in case the server ignored them.
+func listTransponders(ctx context.Context, client *api.Client, opts *transponderListOptions) (map[string]any, error) {
+	params := opts.params()
+	var response map[string]any
+	if err := client.GetTransponders(ctx, params, &response); err != nil {
+		return nil, err
+	}
+	if response == nil {
+		response = map[string]any{}
+	}
+
+	objects, ok := response["data"].([]any)
+	if !ok {
+		return response, nil
+	}
+
+	if opts.All {
+		pages := 1
+		seen := map[string]bool{}
+		for {
+			next, ok := nextTransponderPage(response, params)
+			if !ok {
+				break
+			}
+			marker := next["page"] + "\x00" + next["cursor"]
+			if seen[marker] {
+				break
+			}
+			seen[marker] = true
+			if pages >= maxTransponderPages {
+				return nil, fmt.Errorf("stopped after %d transponder pages; the server keeps reporting a next page", maxTransponderPages)
+			}
+
+			response = nil
+			if err := client.GetTransponders(ctx, next, &response); err != nil {
+				return nil, fmt.Errorf("page %d: %w", pages+1, err)
+			}
+			page, _ := response["data"].([]any)
+			objects = append(objects, page...)
+			params = next
+			pages++
+		}
+		delete(response, "meta")
+		delete(response, "pagination")
+		response["pages"] = pages
+	}
+
+	objects = filterTransponders(objects, opts)
+	if !opts.All && opts.Limit > 0 && len(objects) > opts.Limit {
+		objects = objects[:opts.Limit]
+	}
+	response["data"] = objects
+	return response, nil
+}
+
+// nextTransponderPage reads the pagination metadata of a response, from
+// either "meta" or "pagination", and returns the params for the following
+// page. A next_cursor wins over next_page, which wins over page/total_pages.
+func nextTransponderPage(response map[string]any, params map[string]string) (map[string]string, bool) {
+	meta, ok := response["meta"].(map[string]any)
+	if !ok {
+		if meta, ok = response["pagination"].(map[string]any); !ok {
+			return nil, false
+		}
+	}
+
+	next := make(map[string]string, len(params))
+	for key, value := range params {
+		next[key] = value
+	}
+	if cursor, _ := meta["next_cursor"].(string); cursor != "" {
+		delete(next, "page")
+		next["cursor"] = cursor
+		return next, true
+	}
+
+	page, hasPage := metaInt(meta["next_page"])
+	if !hasPage {
+		current, okCurrent := metaInt(meta["page"])
+		total, okTotal := metaInt(meta["total_pages"])
+		if !okCurrent || !okTotal || current >= total {
+			return nil, false
+		}
+		page = current + 1
+	}
+	if page <= 0 {
+		return nil, false
+	}
+	delete(next, "cursor")
+	next["page"] = strconv.Itoa(page)
+	return next, true
+}
+
+func metaInt(value any) (int, bool) {
+	switch v := triflestats.NormalizeNumeric(value).(type) {
+	case float64:
+		return int(v), true
+	case string:
+		parsed, err := strconv.Atoi(v)
+		return parsed, err == nil
+	}
+	return 0, false
+}
+
+// filterTransponders keeps the transponders matching --status and
+// --name-contains. Entries that are not objects are kept as-is.
+func filterTransponders(objects []any, opts *transponderListOptions) []any {
+	if opts.Status == "" && opts.NameContains == "" {
+		return objects
+	}
+	needle := strings.ToLower(opts.NameContains)
+	filtered := make([]any, 0, len(objects))
+	for _, item := range objects {
+		object, ok := item.(map[string]any)
+		if !ok {
+			filtered = append(filtered, item)
+			continue
+		}
+		if opts.Status != "" {
+			status, _ := object["status"].(string)
+			if !strings.EqualFold(status, opts.Status) {
+				continue
+			}
+		}
+		if needle != "" {
+			name, _ := object["name"].(string)
+			if !strings.Contains(strings.ToLower(name), needle) {
+				continue
+			}
+		}
+		filtered = append(filtered, object)
+	}
+	return filtered
+}
+
+// transponderText decodes any JSON scalar as text, so a field that an older
+// or newer server sends as a number, boolean, or null still fills a cell.
+type transponderText string
+
+func (t *transponderText) UnmarshalJSON(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return err
+	}
+	switch v := value.(type) {
+	case nil:
+		*t = ""
+	case string:
+		*t = transponderText(v)
+	case json.Number:
+		*t = transponderText(v.String())
+	case bool:
+		*t = transponderText(strconv.FormatBool(v))
+	default:
+		*t = transponderText(bytes.TrimSpace(data))
+	}
+	return nil
+}
+
+// transponderRow is the curated view of one transponder in list tables.
+// Fields a server does not send are left blank.
+type transponderRow struct {
+	ID        transponderText `json:"id"`
+	Name      transponderText `json:"name"`
+	Kind      transponderText `json:"kind"`
+	Status    transponderText `json:"status"`
+	Key       transponderText `json:"key"`
+	UpdatedAt transponderText `json:"updated_at"`
+}
+
+// transponderColumns are the columns of the default transponders list
+// table, in transponderRow order; --wide adds the other keys after them.
+var transponderColumns = []string{"id", "name", "kind", "status", "key", "updated_at"}
+
+func (r transponderRow) cells() []string {
+	return []string{string(r.ID), string(r.Name), string(r.Kind), string(r.Status), string(r.Key), string(r.UpdatedAt)}
+}
+
+func decodeTransponderRows(objects []map[string]any) ([]transponderRow, error) {
+	encoded, err := json.Marshal(objects)
+	if err != nil {
+		return nil, err
+	}
+	var rows []transponderRow
+	if err := json.Unmarshal(encoded, &rows); err != nil {
+		return nil, fmt.Errorf("decode transponders: %w", err)
+	}
+	return rows, nil
+}
+
+func transponderTable(objects []map[string]any) (output.Table, error) {
+	rows, err := decodeTransponderRows(objects)
+	if err != nil {
+		return output.Table{}, err
+	}
+	table := output.Table{Columns: transponderColumns, Rows: make([][]string, len(rows))}
+	for i, row := range rows {
+		table.Rows[i] = row.cells()
+	}
+	return table, nil
+}
+
+// writeTranspondersOutput prints transponders list results: the curated
+// columns for the table formats, every key with wide, and the whole
+// response for json.
+func writeTranspondersOutput(opts *outputOptions, response map[string]any, format string, wide bool, tableOpts output.TableOptions) error {
+	objects, ok := responseObjects(response["data"])
+	if format == "json" || !ok || wide {
+		return writeObjectsOutput(opts, response, format, transponderColumns, tableOpts)
+	}
+	table, err := transponderTable(objects)
+	if err != nil {
+		return err
+	}
+	return writeCommandOutput(opts, func(w io.Writer) error {
+		return output.WriteTable(w, table, format, tableOpts)
+	})
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/trifle-io/trifle-cli/internal/api"
+	"github.com/trifle-io/trifle-cli/internal/output"
+)
+
+func transponderNames(t *testing.T, response map[string]any) []string {
+	t.Helper()
+	objects, ok := responseObjects(response["data"])
+	if !ok {
+		t.Fatalf("data = %#v, want a list of objects", response["data"])
+	}
+	names := make([]string, len(objects))
+	for i, object := range objects {
+		names[i], _ = object["name"].(string)
+	}
+	return names
+}
+
+func TestListTranspondersFollowsPages(t *testing.T) {
+	t.Parallel()
+
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.RawQuery)
+		response := map[string]any{}
+		switch r.URL.Query().Get("cursor") {
+		case "":
+			response["data"] = []any{map[string]any{"name": "billing-a", "status": "active"}}
+			response["meta"] = map[string]any{"next_cursor": "c2"}
+		case "c2":
+			response["data"] = []any{map[string]any{"name": "billing-b", "status": "paused"}}
+			response["meta"] = map[string]any{"next_cursor": ""}
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	client, err := api.New(server.URL, "token", 5*time.Second)
+	if err != nil {
+		t.Fatalf("api.New returned error: %v", err)
+	}
+	opts := &transponderListOptions{Status: "paused", Limit: 1, All: true}
+	response, err := listTransponders(context.Background(), client, opts)
+	if err != nil {
+		t.Fatalf("listTransponders returned error: %v", err)
+	}
+
+	if want := []string{"limit=1&status=paused", "cursor=c2&limit=1&status=paused"}; !reflect.DeepEqual(queries, want) {
+		t.Fatalf("queries = %v, want %v", queries, want)
+	}
+	// The server ignored status, so the client filters the merged pages.
+	if names := transponderNames(t, response); !reflect.DeepEqual(names, []string{"billing-b"}) {
+		t.Fatalf("names = %v, want [billing-b]", names)
+	}
+	if response["pages"] != 2 {
+		t.Fatalf("pages = %v, want 2", response["pages"])
+	}
+	if _, ok := response["meta"]; ok {
+		t.Fatal("merged response kept the last page's meta")
+	}
+}
+
+func TestListTranspondersClientFallback(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"data": []any{
+			map[string]any{"name": "Billing sync", "status": "active"},
+			map[string]any{"name": "search", "status": "active"},
+			map[string]any{"name": "billing export", "status": "active"},
+			map[string]any{"name": "billing audit", "status": "active"},
+		}})
+	}))
+	defer server.Close()
+
+	client, err := api.New(server.URL, "token", 5*time.Second)
+	if err != nil {
+		t.Fatalf("api.New returned error: %v", err)
+	}
+	opts := &transponderListOptions{NameContains: "BILLING", Limit: 2}
+	response, err := listTransponders(context.Background(), client, opts)
+	if err != nil {
+		t.Fatalf("listTransponders returned error: %v", err)
+	}
+	if names := transponderNames(t, response); !reflect.DeepEqual(names, []string{"Billing sync", "billing export"}) {
+		t.Fatalf("names = %v, want the first two billing transponders", names)
+	}
+}
+
+func TestNextTransponderPage(t *testing.T) {
+	t.Parallel()
+
+	params := map[string]string{"status": "active", "page": "1"}
+	tests := []struct {
+		name     string
+		response map[string]any
+		want     map[string]string
+	}{
+		{"cursor", map[string]any{"meta": map[string]any{"next_cursor": "abc"}}, map[string]string{"status": "active", "cursor": "abc"}},
+		{"next page", map[string]any{"pagination": map[string]any{"next_page": json.Number("2")}}, map[string]string{"status": "active", "page": "2"}},
+		{"total pages", map[string]any{"meta": map[string]any{"page": 1.0, "total_pages": 3.0}}, map[string]string{"status": "active", "page": "2"}},
+		{"last page", map[string]any{"meta": map[string]any{"page": 3.0, "total_pages": 3.0}}, nil},
+		{"null next page", map[string]any{"meta": map[string]any{"next_page": nil}}, nil},
+		{"no meta", map[string]any{"data": []any{}}, nil},
+	}
+	for _, tt := range tests {
+		got, ok := nextTransponderPage(tt.response, params)
+		if ok != (tt.want != nil) || (ok && !reflect.DeepEqual(got, tt.want)) {
+			t.Fatalf("%s: nextTransponderPage = %v (%v), want %v", tt.name, got, ok, tt.want)
+		}
+	}
+}
+
+func TestTransponderListOptionsValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		opts    transponderListOptions
+		wantErr string
+	}{
+		{transponderListOptions{Status: " Active "}, ""},
+		{transponderListOptions{Status: "deleted"}, "unsupported --status"},
+		{transponderListOptions{Limit: -1}, "--limit must be 0 or greater"},
+		{transponderListOptions{Page: 2, Cursor: "abc"}, "--page cannot be combined with --cursor"},
+	}
+	for _, tt := range tests {
+		opts := tt.opts
+		err := opts.validate()
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Fatalf("validate(%+v) returned error: %v", tt.opts, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Fatalf("validate(%+v) = %v, want %q", tt.opts, err, tt.wantErr)
+		}
+	}
+}
+
+func TestTransponderTable(t *testing.T) {
+	t.Parallel()
+
+	objects := []map[string]any{
+		{"id": json.Number("7"), "name": "errors", "kind": "threshold", "status": "active", "key": "event::logs", "updated_at": "2026-01-02T00:00:00Z", "config": map[string]any{"threshold": 50}},
+		// An older server: no key or updated_at, and a null status.
+		{"id": "abc", "name": "heartbeat", "kind": "heartbeat", "status": nil},
+	}
+	table, err := transponderTable(objects)
+	if err != nil {
+		t.Fatalf("transponderTable returned error: %v", err)
+	}
+	if !reflect.DeepEqual(table.Columns, transponderColumns) {
+		t.Fatalf("columns = %v, want %v", table.Columns, transponderColumns)
+	}
+	want := [][]string{
+		{"7", "errors", "threshold", "active", "event::logs", "2026-01-02T00:00:00Z"},
+		{"abc", "heartbeat", "heartbeat", "", "", ""},
+	}
+	if !reflect.DeepEqual(table.Rows, want) {
+		t.Fatalf("rows = %q, want %q", table.Rows, want)
+	}
+
+	var csv strings.Builder
+	if err := output.WriteTable(&csv, table, "csv", output.TableOptions{}); err != nil {
+		t.Fatalf("WriteTable returned error: %v", err)
+	}
+	if !strings.HasPrefix(csv.String(), "id,name,kind,status,key,updated_at\n7,errors,") {
+		t.Fatalf("csv = %q, want the curated header and rows", csv.String())
+	}
+}
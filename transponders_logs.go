@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/trifle-io/trifle-cli/internal/api"
+	"github.com/trifle-io/trifle-cli/internal/output"
+)
+
+// deliveryColumns are the columns of the transponders logs table.
+var deliveryColumns = []string{"timestamp", "status", "message"}
+
+// deliveryRow reduces one delivery to the logs table columns, taking the
+// first field the server set for each.
+func deliveryRow(delivery map[string]any) map[string]any {
+	pick := func(fields ...string) any {
+		for _, field := range fields {
+			if value, ok := delivery[field]; ok && value != nil && value != "" {
+				return value
+			}
+		}
+		return nil
+	}
+	return map[string]any{
+		"timestamp": pick("timestamp", "at", "delivered_at", "created_at"),
+		"status":    pick("status", "state"),
+		"message":   pick("message", "error", "response"),
+	}
+}
+
+// transponderDeliveries fetches one page of a transponder's delivery log.
+func transponderDeliveries(ctx context.Context, client *api.Client, id string, limit int, cursor string) (map[string]any, error) {
+	params := map[string]string{"cursor": cursor}
+	if limit > 0 {
+		params["limit"] = strconv.Itoa(limit)
+	}
+	var response map[string]any
+	if err := client.GetTransponderDeliveries(ctx, id, params, &response); err != nil {
+		return nil, err
+	}
+	if response == nil {
+		response = map[string]any{}
+	}
+	return response, nil
+}
+
+// nextDeliveriesCursor returns the cursor of the following page, if the
+// server reported one.
+func nextDeliveriesCursor(response map[string]any) string {
+	next, ok := nextTransponderPage(response, nil)
+	if !ok {
+		return ""
+	}
+	return next["cursor"]
+}
+
+func transpondersLogs(args []string) {
+	rc, err := resolveCommandConfig(args)
+	if err != nil {
+		exitError(err)
+	}
+
+	fs := flag.NewFlagSet("transponders logs", flag.ExitOnError)
+	addConfigFlag(fs, rc.ConfigPath)
+	addSourceFlag(fs, rc.SourceName)
+	opts := addCommonFlags(fs, &rc.Source)
+	id := fs.String("id", "", "Transponder ID")
+	limit := fs.Int("limit", 50, "Maximum deliveries to fetch")
+	cursor := fs.String("cursor", "", "Pagination cursor from a previous page")
+	format := fs.String("format", "table", "Output format: table|csv|markdown|json (json keeps every field)")
+	var csvDelimiter string
+	addCSVDelimiterFlag(fs, &csvDelimiter)
+	outputOpts := addOutputFlags(fs)
+	fs.Parse(args)
+
+	if err := outputOpts.validate(*format); err != nil {
+		exitError(err)
+	}
+	csvOpts, err := parseCSVDelimiter(csvDelimiter)
+	if err != nil {
+		exitError(err)
+	}
+	formatValue := strings.ToLower(strings.TrimSpace(*format))
+	switch formatValue {
+	case "table", "csv", "markdown", "json":
+	default:
+		exitError(usageErrorf("unsupported --format %q (use table, csv, markdown, or json)", *format))
+	}
+
+	if driverNameFromSource(rc.Source) != "api" {
+		exitError(errors.New("transponders are only available for api drivers (use --source <api-source>)"))
+	}
+
+	if *id == "" {
+		exitError(usageErrorf("--id is required"))
+	}
+	if *limit < 0 {
+		exitError(usageErrorf("--limit must be 0 or greater"))
+	}
+
+	if err := ensureToken(opts, true); err != nil {
+		exitError(err)
+	}
+
+	client, err := newClient(opts)
+	if err != nil {
+		exitError(err)
+	}
+
+	response, err := transponderDeliveries(context.Background(), client, *id, *limit, *cursor)
+	if err != nil {
+		exitError(err)
+	}
+
+	deliveries, ok := responseObjects(response["data"])
+	if formatValue == "json" || !ok {
+		if err := writeJSONOutput(outputOpts, response); err != nil {
+			exitError(err)
+		}
+	} else {
+		rows := make([]map[string]any, len(deliveries))
+		for i, delivery := range deliveries {
+			rows[i] = deliveryRow(delivery)
+		}
+		table := output.TableFromObjects(rows, deliveryColumns)
+		table.Columns = deliveryColumns
+		err := writeCommandOutput(outputOpts, func(w io.Writer) error {
+			return output.WriteTable(w, table, formatValue, output.TableOptions{CSV: csvOpts})
+		})
+		if err != nil {
+			exitError(err)
+		}
+	}
+
+	if next := nextDeliveriesCursor(response); next != "" {
+		fmt.Fprintf(os.Stderr, "more deliveries: rerun with --cursor %s\n", next)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/trifle-io/trifle-cli/internal/api"
+)
+
+func TestDeliveryRow(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		delivery map[string]any
+		want     map[string]any
+	}{
+		{
+			map[string]any{"timestamp": "2026-01-01T00:00:00Z", "status": "ok", "message": "sent", "id": 1},
+			map[string]any{"timestamp": "2026-01-01T00:00:00Z", "status": "ok", "message": "sent"},
+		},
+		{
+			map[string]any{"created_at": "2026-01-02T00:00:00Z", "state": "failed", "message": "", "error": "timeout"},
+			map[string]any{"timestamp": "2026-01-02T00:00:00Z", "status": "failed", "message": "timeout"},
+		},
+		{
+			map[string]any{},
+			map[string]any{"timestamp": nil, "status": nil, "message": nil},
+		},
+	}
+	for _, tt := range tests {
+		if got := deliveryRow(tt.delivery); !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("deliveryRow(%v) = %v, want %v", tt.delivery, got, tt.want)
+		}
+	}
+}
+
+func TestTransponderLogsResource(t *testing.T) {
+	t.Parallel()
+
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.Path+"?"+r.URL.RawQuery)
+		json.NewEncoder(w).Encode(map[string]any{
+			"data": []any{map[string]any{"at": "2026-01-01T00:00:00Z", "status": "ok"}},
+			"meta": map[string]any{"next_cursor": "c2"},
+		})
+	}))
+	defer server.Close()
+
+	client, err := api.New(server.URL, "token", 5*time.Second)
+	if err != nil {
+		t.Fatalf("api.New returned error: %v", err)
+	}
+	state := &mcpState{Driver: "api", API: client}
+	ctx := context.Background()
+
+	result, err := readResource(ctx, state, "trifle://transponders/42/logs?limit=5&cursor=c1")
+	if err != nil {
+		t.Fatalf("readResource returned error: %v", err)
+	}
+	var payload map[string]any
+	if err := json.Unmarshal([]byte(result.Contents[0]["text"].(string)), &payload); err != nil {
+		t.Fatalf("decode resource: %v", err)
+	}
+	if nextDeliveriesCursor(payload) != "c2" {
+		t.Fatalf("payload = %v, want the server response with its next cursor", payload)
+	}
+	if want := []string{"/api/v1/transponders/42/deliveries?cursor=c1&limit=5"}; !reflect.DeepEqual(queries, want) {
+		t.Fatalf("queries = %v, want %v", queries, want)
+	}
+
+	for _, uri := range []string{"trifle://transponders/42", "trifle://transponders/42/events", "trifle://transponders/42/logs?limit=x"} {
+		if _, err := readResource(ctx, state, uri); err == nil {
+			t.Fatalf("readResource(%s) succeeded, want an error", uri)
+		}
+	}
+	if _, err := readResource(ctx, &mcpState{Driver: "sqlite", Local: &localDriverRuntime{}}, "trifle://transponders/42/logs"); err == nil || !strings.Contains(err.Error(), "api drivers") {
+		t.Fatalf("local readResource err = %v, want an api drivers error", err)
+	}
+}
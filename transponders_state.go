@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/trifle-io/trifle-cli/internal/api"
+)
+
+const (
+	transponderActive = "active"
+	transponderPaused = "paused"
+)
+
+// transponderStatus pulls the status from an update response, accepting
+// both {"data": {"status": ...}} and a bare {"status": ...}.
+func transponderStatus(response map[string]any) string {
+	record := response
+	if data, ok := response["data"].(map[string]any); ok {
+		record = data
+	}
+	status, _ := record["status"].(string)
+	return strings.ToLower(strings.TrimSpace(status))
+}
+
+// setTransponderStatus updates a transponder to status and returns the
+// response. It fails, still returning the response, when the server reports
+// any other status afterwards.
+func setTransponderStatus(ctx context.Context, client *api.Client, id, status string) (map[string]any, error) {
+	var response map[string]any
+	if err := client.UpdateTransponder(ctx, id, map[string]any{"status": status}, &response); err != nil {
+		return nil, err
+	}
+	switch got := transponderStatus(response); got {
+	case status:
+		return response, nil
+	case "":
+		return response, fmt.Errorf("transponder %s update did not report a status; check it with transponders list", id)
+	default:
+		return response, fmt.Errorf("transponder %s is still %s, not %s", id, got, status)
+	}
+}
+
+// transpondersSetStatus runs transponders pause and resume.
+func transpondersSetStatus(command, status string, args []string) {
+	rc, err := resolveCommandConfig(args)
+	if err != nil {
+		exitError(err)
+	}
+
+	fs := flag.NewFlagSet("transponders "+command, flag.ExitOnError)
+	addConfigFlag(fs, rc.ConfigPath)
+	addSourceFlag(fs, rc.SourceName)
+	opts := addCommonFlags(fs, &rc.Source)
+	id := fs.String("id", "", "Transponder ID")
+	outputOpts := addOutputFlags(fs)
+	fs.Parse(args)
+
+	if err := outputOpts.validate(""); err != nil {
+		exitError(err)
+	}
+
+	if driverNameFromSource(rc.Source) != "api" {
+		exitError(errors.New("transponders are only available for api drivers (use --source <api-source>)"))
+	}
+
+	if *id == "" {
+		exitError(usageErrorf("--id is required"))
+	}
+
+	if err := ensureToken(opts, true); err != nil {
+		exitError(err)
+	}
+
+	client, err := newClient(opts)
+	if err != nil {
+		exitError(err)
+	}
+
+	response, statusErr := setTransponderStatus(context.Background(), client, *id, status)
+	if response == nil {
+		exitError(statusErr)
+	}
+	if err := writeJSONOutput(outputOpts, response); err != nil {
+		exitError(err)
+	}
+	if statusErr != nil {
+		exitError(statusErr)
+	}
+}
+
+func transponderStatusPayload(ctx context.Context, state *mcpState, args map[string]any, status string) (map[string]any, error) {
+	if state != nil && state.Local != nil {
+		return nil, fmt.Errorf("transponders are only available for api drivers")
+	}
+	if state == nil || state.API == nil {
+		return nil, fmt.Errorf("api client is not configured")
+	}
+
+	id := strings.TrimSpace(getStringArg(args, "id"))
+	if id == "" {
+		return nil, fmt.Errorf("id is required")
+	}
+
+	response, err := setTransponderStatus(ctx, state.API, id, status)
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
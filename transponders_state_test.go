@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/trifle-io/trifle-cli/internal/api"
+)
+
+// newTransponderServer answers updates with the requested status, except
+// for ids listed in stuck, which keep reporting "active".
+func newTransponderServer(t *testing.T, stuck ...string) (*api.Client, *[]string) {
+	t.Helper()
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		id := strings.TrimPrefix(r.URL.Path, "/api/v1/transponders/")
+		requests = append(requests, r.Method+" "+id+" "+body["status"].(string))
+		status := body["status"]
+		for _, s := range stuck {
+			if s == id {
+				status = "active"
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"id": id, "status": status}})
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := api.New(server.URL, "token", 5*time.Second)
+	if err != nil {
+		t.Fatalf("api.New returned error: %v", err)
+	}
+	return client, &requests
+}
+
+func TestSetTransponderStatus(t *testing.T) {
+	t.Parallel()
+
+	client, requests := newTransponderServer(t, "7")
+	response, err := setTransponderStatus(context.Background(), client, "42", transponderPaused)
+	if err != nil {
+		t.Fatalf("setTransponderStatus returned error: %v", err)
+	}
+	if transponderStatus(response) != "paused" {
+		t.Fatalf("status = %q, want paused", transponderStatus(response))
+	}
+
+	response, err = setTransponderStatus(context.Background(), client, "7", transponderPaused)
+	if err == nil || !strings.Contains(err.Error(), "transponder 7 is still active, not paused") {
+		t.Fatalf("err = %v, want a still active error", err)
+	}
+	if response == nil {
+		t.Fatal("expected the response alongside the unchanged error")
+	}
+
+	if got := strings.Join(*requests, ","); got != "PUT 42 paused,PUT 7 paused" {
+		t.Fatalf("requests = %s", got)
+	}
+}
+
+func TestTransponderStatus(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		response map[string]any
+		want     string
+	}{
+		{map[string]any{"data": map[string]any{"status": "Paused"}}, "paused"},
+		{map[string]any{"status": "active"}, "active"},
+		{map[string]any{"data": map[string]any{"id": 1}}, ""},
+	}
+	for _, tt := range tests {
+		if got := transponderStatus(tt.response); got != tt.want {
+			t.Fatalf("transponderStatus(%v) = %q, want %q", tt.response, got, tt.want)
+		}
+	}
+}
+
+func TestMCPPauseResumeTransponder(t *testing.T) {
+	t.Parallel()
+
+	client, requests := newTransponderServer(t)
+	state := &mcpState{Driver: "api", API: client}
+	ctx := context.Background()
+
+	result, err := executeTool(ctx, state, "resume_transponder", map[string]any{"id": "9"})
+	if err != nil {
+		t.Fatalf("resume_transponder returned error: %v", err)
+	}
+	if status := decodeToolPayload(t, result)["data"].(map[string]any)["status"]; status != "active" {
+		t.Fatalf("status = %v, want active", status)
+	}
+	if _, err := executeTool(ctx, state, "pause_transponder", map[string]any{}); err == nil {
+		t.Fatal("expected an id required error")
+	}
+	if got := strings.Join(*requests, ","); got != "PUT 9 active" {
+		t.Fatalf("requests = %s", got)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateTransponderPayload(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		payload      map[string]any
+		update       bool
+		wantErrors   []schemaViolation
+		wantWarnings []schemaViolation
+	}{
+		{
+			name:    "valid create",
+			payload: map[string]any{"name": "ratio", "kind": "ratio", "config": map[string]any{"left": "a"}, "order": 1.0, "status": "active"},
+		},
+		{
+			name:       "missing required",
+			payload:    map[string]any{"kind": "ratio"},
+			wantErrors: []schemaViolation{{Path: "name", Problem: "required"}},
+		},
+		{
+			name:    "partial update",
+			payload: map[string]any{"status": "paused"},
+			update:  true,
+		},
+		{
+			name:    "wrong types and typo",
+			payload: map[string]any{"name": " ", "kind": 3.0, "status": "stopped", "confg": map[string]any{}},
+			wantErrors: []schemaViolation{
+				{Path: "kind", Problem: "expected string, got number"},
+				{Path: "name", Problem: "must not be empty"},
+				{Path: "status", Problem: `expected one of active, paused, got "stopped"`},
+			},
+			wantWarnings: []schemaViolation{{Path: "confg", Problem: "unknown field"}},
+		},
+		{
+			name:       "config must be an object",
+			payload:    map[string]any{"config": []any{}},
+			update:     true,
+			wantErrors: []schemaViolation{{Path: "config", Problem: "expected object, got array"}},
+		},
+	}
+	for _, tt := range tests {
+		check := validateTransponderPayload(tt.payload, tt.update)
+		wantErrors, wantWarnings := tt.wantErrors, tt.wantWarnings
+		if wantErrors == nil {
+			wantErrors = []schemaViolation{}
+		}
+		if wantWarnings == nil {
+			wantWarnings = []schemaViolation{}
+		}
+		if !reflect.DeepEqual(check.Errors, wantErrors) {
+			t.Fatalf("%s: errors = %v, want %v", tt.name, check.Errors, wantErrors)
+		}
+		if !reflect.DeepEqual(check.Warnings, wantWarnings) {
+			t.Fatalf("%s: warnings = %v, want %v", tt.name, check.Warnings, wantWarnings)
+		}
+	}
+}
+
+func TestTransponderFlagsMerge(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		flags   transponderFlags
+		base    map[string]any
+		want    map[string]any
+		wantErr string
+	}{
+		{
+			name:  "threshold from flags",
+			flags: transponderFlags{Name: "errors", Kind: "threshold", Key: "event::logs", ValuePath: "status.error", Threshold: "50", Interval: "5M"},
+			want: map[string]any{"name": "errors", "kind": "threshold", "key": "event::logs", "config": map[string]any{
+				"value_path": "status.error", "threshold": 50.0, "interval": "5m",
+			}},
+		},
+		{
+			name:  "flags win over payload",
+			flags: transponderFlags{Name: "renamed", Threshold: "10"},
+			base: map[string]any{"name": "old", "kind": "threshold", "key": "event::logs", "order": 2.0,
+				"config": map[string]any{"value_path": "count", "threshold": 1.0}},
+			want: map[string]any{"name": "renamed", "kind": "threshold", "key": "event::logs", "order": 2.0,
+				"config": map[string]any{"value_path": "count", "threshold": 10.0}},
+		},
+		{
+			name: "no flags keeps payload",
+			base: map[string]any{"name": "raw"},
+			want: map[string]any{"name": "raw"},
+		},
+		{
+			name:  "unknown kind with payload",
+			flags: transponderFlags{Name: "hook", Kind: "webhook"},
+			base:  map[string]any{"config": map[string]any{"url": "https://example.test"}},
+			want:  map[string]any{"name": "hook", "kind": "webhook", "config": map[string]any{"url": "https://example.test"}},
+		},
+		{
+			name:    "unknown kind needs payload",
+			flags:   transponderFlags{Name: "hook", Kind: "webhook"},
+			wantErr: `kind "webhook" has no flag shorthand`,
+		},
+		{
+			name:    "missing threshold",
+			flags:   transponderFlags{Name: "errors", Kind: "threshold", Key: "event::logs", ValuePath: "count"},
+			wantErr: "--threshold is required for threshold transponders",
+		},
+		{
+			name:    "flag outside kind",
+			flags:   transponderFlags{Name: "beat", Kind: "heartbeat", Key: "event::logs", Interval: "1h", Threshold: "3"},
+			wantErr: "--threshold does not apply to heartbeat transponders",
+		},
+		{
+			name:    "missing key",
+			flags:   transponderFlags{Name: "beat", Kind: "heartbeat", Interval: "1h"},
+			wantErr: "--key is required",
+		},
+		{
+			name:    "missing kind",
+			flags:   transponderFlags{Name: "beat"},
+			wantErr: "--kind is required",
+		},
+		{
+			name:    "bad threshold",
+			flags:   transponderFlags{Kind: "threshold", Threshold: "lots"},
+			wantErr: "--threshold must be a number",
+		},
+	}
+	for _, tt := range tests {
+		flags := tt.flags
+		got, err := flags.merge(tt.base)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("%s: err = %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: merge returned error: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("%s: payload = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}